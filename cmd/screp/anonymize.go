@@ -0,0 +1,60 @@
+// This file contains the anonymize subcommand which writes an anonymized copy of a replay.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/icza/screp/repparser"
+)
+
+// readReplayArg reads the replay given as a subcommand argument, "-" means standard input.
+func readReplayArg(name string) ([]byte, error) {
+	if name == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(name)
+}
+
+// anonymize runs the anonymize subcommand.
+func anonymize(args []string) {
+	fs := flag.NewFlagSet("anonymize", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Println("Usage:")
+		fmt.Printf("\t%s anonymize repfile.rep output.rep\n", os.Args[0])
+		fmt.Println("Player names are replaced with \"Player 1\", \"Player 2\" etc., the game title is cleared,")
+		fmt.Println("chat messages and custom (3rd party) sections are removed.")
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(ExitCodeMissingArguments)
+	}
+
+	repData, err := readReplayArg(fs.Arg(0))
+	if err != nil {
+		fmt.Printf("Failed to read replay: %v\n", err)
+		os.Exit(ExitCodeFailedToParseReplay)
+	}
+
+	anon, err := repparser.Anonymize(repData)
+	if err == nil {
+		// Verify the result is a valid replay:
+		_, err = repparser.Parse(anon)
+	}
+	if err != nil {
+		fmt.Printf("Failed to anonymize replay: %v\n", err)
+		os.Exit(ExitCodeFailedToParseReplay)
+	}
+
+	if err := os.WriteFile(fs.Arg(1), anon, 0644); err != nil {
+		fmt.Printf("Failed to write output file: %v\n", err)
+		os.Exit(ExitCodeFailedToCreateOutputFile)
+	}
+}
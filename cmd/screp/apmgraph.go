@@ -0,0 +1,217 @@
+// This file contains the apm subcommand which renders the APM of players over time.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcore"
+	"github.com/icza/screp/repparser"
+)
+
+// apmSeries is the APM (or EAPM) series of a player.
+type apmSeries struct {
+	Name   string
+	Color  color.Color
+	Values []int32
+}
+
+// chartMarkers are the markers of players in ASCII charts.
+const chartMarkers = "123456789abcdefghijklmnopqrstuvwxyz"
+
+// chartMax returns the maximum value of the series rounded up to a multiple of 50 (at least 50).
+func chartMax(series []*apmSeries) int32 {
+	maxVal := int32(1)
+	for _, s := range series {
+		for _, v := range s.Values {
+			maxVal = max(maxVal, v)
+		}
+	}
+	return (maxVal + 49) / 50 * 50
+}
+
+// writeASCIIChart writes the series as an ASCII chart having the given number of rows.
+// Each column is a window of the given length.
+func writeASCIIChart(w io.Writer, series []*apmSeries, window repcore.Frame, rows int) {
+	maxVal := chartMax(series)
+	cols := 0
+	for _, s := range series {
+		cols = max(cols, len(s.Values))
+	}
+
+	for row := rows; row >= 1; row-- {
+		line := []byte(strings.Repeat(" ", cols))
+		for i, s := range series {
+			for col, v := range s.Values {
+				if int(int64(v)*int64(rows)*2/int64(maxVal)+1)/2 == row {
+					line[col] = chartMarkers[i%len(chartMarkers)]
+				}
+			}
+		}
+		label := "    "
+		if row == rows {
+			label = fmt.Sprintf("%4d", maxVal)
+		} else if row == (rows+1)/2 {
+			label = fmt.Sprintf("%4d", maxVal*int32(row)/int32(rows))
+		}
+		fmt.Fprintf(w, "%s |%s\n", label, strings.TrimRight(string(line), " "))
+	}
+	fmt.Fprintf(w, "   0 +%s\n", strings.Repeat("-", cols))
+
+	// Time labels below every 10th column:
+	labels := []byte(strings.Repeat(" ", cols+10))
+	for col := 0; col < cols; col += 10 {
+		copy(labels[col:], (window * repcore.Frame(col)).String())
+	}
+	fmt.Fprintf(w, "      %s\n", strings.TrimRight(string(labels), " "))
+
+	for i, s := range series {
+		fmt.Fprintf(w, "%c = %s\n", chartMarkers[i%len(chartMarkers)], s.Name)
+	}
+}
+
+// renderPNGChart renders the series as a line chart image.
+// Horizontal grid lines are drawn at every 100 APM, vertical ones at every 10 windows.
+func renderPNGChart(series []*apmSeries) image.Image {
+	const width, height, margin = 800, 400, 10
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = 0x10
+		if i%4 == 3 {
+			img.Pix[i] = 0xff
+		}
+	}
+
+	maxVal := chartMax(series)
+	cols := 1
+	for _, s := range series {
+		cols = max(cols, len(s.Values))
+	}
+	x := func(col int) int { return margin + col*(width-2*margin)/max(cols-1, 1) }
+	y := func(v int32) int { return height - margin - int(int64(v)*(height-2*margin)/int64(maxVal)) }
+
+	grid := color.RGBA{0x40, 0x40, 0x40, 0xff}
+	for v := int32(0); v <= maxVal; v += 100 {
+		drawLine(img, x(0), y(v), x(cols-1), y(v), grid)
+	}
+	for col := 0; col < cols; col += 10 {
+		drawLine(img, x(col), y(0), x(col), y(maxVal), grid)
+	}
+
+	for _, s := range series {
+		for col := 1; col < len(s.Values); col++ {
+			drawLine(img, x(col-1), y(s.Values[col-1]), x(col), y(s.Values[col]), s.Color)
+		}
+	}
+
+	return img
+}
+
+// drawLine draws a line between 2 points using Bresenham's algorithm.
+func drawLine(img *image.RGBA, x0, y0, x1, y1 int, c color.Color) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	for e := dx + dy; ; {
+		img.Set(x0, y0, c)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * e
+		if e2 >= dy {
+			e += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			e += dx
+			y0 += sy
+		}
+	}
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// apmGraph runs the apm subcommand.
+func apmGraph(args []string) {
+	fs := flag.NewFlagSet("apm", flag.ExitOnError)
+	window := fs.Duration("window", 30*time.Second, "length of the windows APM is calculated in")
+	eapm := fs.Bool("eapm", false, "render EAPM instead of APM")
+	rows := fs.Int("rows", 15, "number of rows of the ASCII chart")
+	outFile := fs.String("outfile", "", "render a PNG image to this file instead of an ASCII chart")
+	fs.Usage = func() {
+		fmt.Println("Usage:")
+		fmt.Printf("\t%s apm [FLAGS] repfile.rep\n", os.Args[0])
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 || *rows < 1 {
+		fs.Usage()
+		os.Exit(ExitCodeMissingArguments)
+	}
+
+	r, err := parseReplayArg(fs.Arg(0), repparser.Config{Commands: true})
+	if err != nil {
+		fmt.Printf("Failed to parse replay: %v\n", err)
+		os.Exit(ExitCodeFailedToParseReplay)
+	}
+	r.Compute()
+
+	series := playerAPMSeries(r, repcore.Duration2Frame(*window), *eapm)
+
+	if *outFile == "" {
+		writeASCIIChart(os.Stdout, series, repcore.Duration2Frame(*window), *rows)
+		return
+	}
+
+	f, err := os.Create(*outFile)
+	if err != nil {
+		fmt.Printf("Failed to create output file: %v\n", err)
+		os.Exit(ExitCodeFailedToCreateOutputFile)
+	}
+	defer f.Close()
+	if err := png.Encode(f, renderPNGChart(series)); err != nil {
+		fmt.Printf("Failed to write PNG: %v\n", err)
+	}
+}
+
+// playerAPMSeries returns the APM (or EAPM) series of the non-observer players of a computed replay.
+func playerAPMSeries(r *rep.Replay, window repcore.Frame, eapm bool) []*apmSeries {
+	var series []*apmSeries
+	for _, p := range r.Header.Players {
+		if p.Observer {
+			continue
+		}
+		apms, eapms := r.APMSeries(p.ID, window)
+		if eapm {
+			apms = eapms
+		}
+		var c color.Color = color.White
+		if p.Color != nil && p.Color.RGB != 0 {
+			c = color.RGBA{byte(p.Color.RGB >> 16), byte(p.Color.RGB >> 8), byte(p.Color.RGB), 0xff}
+		}
+		series = append(series, &apmSeries{Name: p.Name, Color: c, Values: apms})
+	}
+	return series
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestWriteASCIIChart(t *testing.T) {
+	series := []*apmSeries{
+		{Name: "Flash", Values: []int32{0, 100, 200, 100}},
+		{Name: "Jaedong", Values: []int32{50, 50, 150}},
+	}
+
+	sb := &strings.Builder{}
+	writeASCIIChart(sb, series, 714, 4)
+
+	exp := ` 200 |  1
+     |  2
+ 100 | 1 1
+     |22
+   0 +----
+      00:00
+1 = Flash
+2 = Jaedong
+`
+	if got := sb.String(); got != exp {
+		t.Errorf("Expected:\n%s\ngot:\n%s", exp, got)
+	}
+}
+
+func TestRenderPNGChart(t *testing.T) {
+	red := color.RGBA{0xff, 0, 0, 0xff}
+	img := renderPNGChart([]*apmSeries{{Name: "Flash", Color: red, Values: []int32{0, 100}}})
+
+	b := img.Bounds()
+	if b.Dx() != 800 || b.Dy() != 400 {
+		t.Errorf("Expected: %v, got: %v", "800x400", b.Size())
+	}
+	// Line from bottom left to top right (max is 100):
+	for _, pt := range [][2]int{{10, 390}, {790, 10}, {400, 200}} {
+		if c := img.At(pt[0], pt[1]); c != red {
+			t.Errorf("[%v] Expected: %v, got: %v", pt, red, c)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+// This file contains the bench subcommand which measures the parsing performance.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/icza/screp/repparser"
+)
+
+// benchResult is the result of a benchmark.
+type benchResult struct {
+	Replays    int // Number of replays parsed in an iteration
+	Failed     int // Number of replays that failed to parse in an iteration
+	Iterations int
+	Bytes      int64 // Total size of the replays
+
+	Duration      time.Duration // Total duration of all iterations
+	ReplaysPerSec float64
+	MBPerSec      float64
+
+	AllocsPerReplay     uint64 // Number of heap allocations per replay
+	AllocBytesPerReplay uint64 // Heap allocated bytes per replay
+
+	Sections []*sectionTiming
+}
+
+// sectionTiming is the timing of a section summed up for all parsed replays.
+type sectionTiming struct {
+	Name   string
+	Count  int // Number of times the section was present
+	Decode time.Duration
+	Parse  time.Duration
+}
+
+// sectionName returns the name of a section.
+func sectionName(s *repparser.Section) string {
+	switch s {
+	case repparser.SectionReplayID:
+		return "ReplayID"
+	case repparser.SectionHeader:
+		return "Header"
+	case repparser.SectionCommands:
+		return "Commands"
+	case repparser.SectionMapData:
+		return "MapData"
+	case repparser.SectionPlayerNames:
+		return "PlayerNames"
+	}
+	if s.StrID != "" {
+		return s.StrID
+	}
+	return fmt.Sprint("Section ", s.ID)
+}
+
+// runBench parses the given replays n times with the given parser config.
+func runBench(reps [][]byte, n int, cfg repparser.Config) *benchResult {
+	res := &benchResult{Replays: len(reps), Iterations: n}
+	for _, data := range reps {
+		res.Bytes += int64(len(data))
+	}
+
+	timings := map[*repparser.Section]*sectionTiming{}
+	cfg.SectionDone = func(s *repparser.Section, decode, parse time.Duration) {
+		st := timings[s]
+		if st == nil {
+			st = &sectionTiming{Name: sectionName(s)}
+			timings[s] = st
+			res.Sections = append(res.Sections, st)
+		}
+		st.Count++
+		st.Decode += decode
+		st.Parse += parse
+	}
+
+	var ms0, ms1 runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&ms0)
+	start := time.Now()
+
+	for i := 0; i < n; i++ {
+		failed := 0
+		for _, data := range reps {
+			if _, err := repparser.ParseConfig(data, cfg); err != nil {
+				failed++
+			}
+		}
+		res.Failed = failed
+	}
+
+	res.Duration = time.Since(start)
+	runtime.ReadMemStats(&ms1)
+
+	if count := uint64(len(reps) * n); count > 0 {
+		res.AllocsPerReplay = (ms1.Mallocs - ms0.Mallocs) / count
+		res.AllocBytesPerReplay = (ms1.TotalAlloc - ms0.TotalAlloc) / count
+	}
+	if secs := res.Duration.Seconds(); secs > 0 {
+		res.ReplaysPerSec = float64(len(reps)*n) / secs
+		res.MBPerSec = float64(res.Bytes*int64(n)) / (1 << 20) / secs
+	}
+
+	return res
+}
+
+// printBench prints the benchmark result in human-readable form.
+func printBench(w io.Writer, res *benchResult) {
+	fmt.Fprintf(w, "Replays   : %d (%d failed), %.2f MB\n", res.Replays, res.Failed, float64(res.Bytes)/(1<<20))
+	fmt.Fprintf(w, "Iterations: %d\n", res.Iterations)
+	fmt.Fprintf(w, "Duration  : %v\n", res.Duration)
+	fmt.Fprintf(w, "Throughput: %.1f replays/s, %.2f MB/s\n", res.ReplaysPerSec, res.MBPerSec)
+	fmt.Fprintf(w, "Allocs    : %d allocs/replay, %d bytes/replay\n", res.AllocsPerReplay, res.AllocBytesPerReplay)
+
+	var total time.Duration
+	for _, st := range res.Sections {
+		total += st.Decode + st.Parse
+	}
+	fmt.Fprintln(w, "Section         Count       Decode        Parse  Share")
+	for _, st := range res.Sections {
+		share := 0.0
+		if total > 0 {
+			share = float64(st.Decode+st.Parse) * 100 / float64(total)
+		}
+		fmt.Fprintf(w, "%-12s %8d %12v %12v %5.1f%%\n", st.Name, st.Count, st.Decode, st.Parse, share)
+	}
+}
+
+// bench runs the bench subcommand.
+func bench(args []string) {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	n := fs.Int("n", 3, "number of times to parse the replays")
+	recursive := fs.Bool("r", false, "search replays recursively in the subfolders of folder arguments")
+	parseCmds := fs.Bool("cmds", true, "parse the commands section")
+	parseMap := fs.Bool("map", true, "parse the map data section")
+	jsonOut := fs.Bool("json", false, "print the result in JSON format")
+	fs.Usage = func() {
+		fmt.Println("Usage:")
+		fmt.Printf("\t%s bench [FLAGS] replayfolder...\n", os.Args[0])
+		fmt.Println("\tReplays are read into memory first, so only the parsing is measured.")
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 || *n < 1 {
+		fs.Usage()
+		os.Exit(ExitCodeMissingArguments)
+	}
+
+	files, _, err := replayFiles(fs.Args(), *recursive)
+	if err != nil {
+		fmt.Printf("Failed to list replay files: %v\n", err)
+		os.Exit(ExitCodeMissingArguments)
+	}
+	var reps [][]byte
+	for _, name := range files {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			fmt.Printf("Failed to read replay %s: %v\n", name, err)
+			os.Exit(ExitCodeFailedToParseReplay)
+		}
+		reps = append(reps, data)
+	}
+
+	res := runBench(reps, *n, repparser.Config{Commands: *parseCmds, MapData: *parseMap})
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(res); err != nil {
+			fmt.Printf("Failed to encode output: %v\n", err)
+		}
+		return
+	}
+
+	printBench(os.Stdout, res)
+}
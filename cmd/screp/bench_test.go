@@ -0,0 +1,19 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/icza/screp/repparser"
+)
+
+func TestRunBench(t *testing.T) {
+	reps := [][]byte{[]byte("not a replay, just some text of at least 30 bytes")}
+	res := runBench(reps, 2, repparser.Config{})
+
+	if res.Replays != 1 || res.Failed != 1 || res.Iterations != 2 {
+		t.Errorf("Expected: %v, got: %v", "1 replay, 1 failed, 2 iterations", res)
+	}
+	if exp := int64(len(reps[0])); res.Bytes != exp {
+		t.Errorf("Expected: %v, got: %v", exp, res.Bytes)
+	}
+}
@@ -0,0 +1,123 @@
+// This file contains the buildorder subcommand which prints the build orders of players.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/repparser"
+)
+
+// Build order formats
+const (
+	boFormatText    = "text"
+	boFormatJSON    = "json"
+	boFormatCompact = "compact"
+)
+
+// playerBuildOrder is the build order of a player.
+type playerBuildOrder struct {
+	PlayerID byte
+	Name     string
+	Race     string
+	Items    []*rep.BuildOrderItem
+}
+
+// buildOrders returns the build orders of the (non-observer) players of a computed replay.
+func buildOrders(r *rep.Replay) []*playerBuildOrder {
+	bos := []*playerBuildOrder{}
+	for _, p := range r.Header.Players {
+		if p.Observer {
+			continue
+		}
+		bos = append(bos, &playerBuildOrder{
+			PlayerID: p.ID,
+			Name:     p.Name,
+			Race:     p.Race.Name,
+			Items:    r.BuildOrder(p.ID),
+		})
+	}
+	return bos
+}
+
+// printBuildOrders prints the build orders in the given format (text or compact).
+//
+// The compact format is the Liquipedia style "supply - item" list,
+// where consecutive identical items are merged (e.g. "12 - Zergling x3").
+func printBuildOrders(w io.Writer, bos []*playerBuildOrder, format string) {
+	for i, bo := range bos {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s (%s):\n", bo.Name, bo.Race)
+
+		if format == boFormatText {
+			for _, item := range bo.Items {
+				fmt.Fprintf(w, "%8s %4d  %s\n", item.Frame, item.Supply, item.Name)
+			}
+			continue
+		}
+
+		for j := 0; j < len(bo.Items); {
+			item, count := bo.Items[j], 1
+			for j+count < len(bo.Items) && bo.Items[j+count].Name == item.Name {
+				count++
+			}
+			if count > 1 {
+				fmt.Fprintf(w, "%d - %s x%d\n", item.Supply, item.Name, count)
+			} else {
+				fmt.Fprintf(w, "%d - %s\n", item.Supply, item.Name)
+			}
+			j += count
+		}
+	}
+}
+
+// buildOrder runs the buildorder subcommand.
+func buildOrder(args []string) {
+	fs := flag.NewFlagSet("buildorder", flag.ExitOnError)
+	format := fs.String("format", boFormatText, "output format; valid values are 'text', 'json', 'compact' (Liquipedia style)")
+	fs.Usage = func() {
+		fmt.Println("Usage:")
+		fmt.Printf("\t%s buildorder [FLAGS] repfile.rep\n", os.Args[0])
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	switch *format {
+	case boFormatText, boFormatJSON, boFormatCompact:
+	default:
+		fmt.Printf("Invalid format: %v\n", *format)
+		os.Exit(ExitCodeInvalidFormat)
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(ExitCodeMissingArguments)
+	}
+
+	r, err := parseReplayArg(fs.Arg(0), repparser.Config{Commands: true})
+	if err != nil {
+		fmt.Printf("Failed to parse replay: %v\n", err)
+		os.Exit(ExitCodeFailedToParseReplay)
+	}
+	r.Compute()
+
+	bos := buildOrders(r)
+	if *format == boFormatJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(bos); err != nil {
+			fmt.Printf("Failed to encode output: %v\n", err)
+		}
+		return
+	}
+
+	printBuildOrders(os.Stdout, bos, *format)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/icza/screp/rep"
+)
+
+func TestPrintBuildOrders(t *testing.T) {
+	bos := []*playerBuildOrder{
+		{Name: "Flash", Race: "Terran", Items: []*rep.BuildOrderItem{
+			{Frame: 1429, Supply: 9, Name: "Supply Depot"},
+			{Frame: 2000, Supply: 11, Name: "Barracks"},
+			{Frame: 2500, Supply: 12, Name: "Marine"},
+			{Frame: 2600, Supply: 13, Name: "Marine"},
+		}},
+		{Name: "Jaedong", Race: "Zerg"},
+	}
+
+	cases := []struct {
+		format, exp string
+	}{
+		{boFormatText, `Flash (Terran):
+   01:00    9  Supply Depot
+   01:24   11  Barracks
+   01:45   12  Marine
+   01:49   13  Marine
+
+Jaedong (Zerg):
+`},
+		{boFormatCompact, `Flash (Terran):
+9 - Supply Depot
+11 - Barracks
+12 - Marine x2
+
+Jaedong (Zerg):
+`},
+	}
+	for _, c := range cases {
+		sb := &strings.Builder{}
+		printBuildOrders(sb, bos, c.format)
+		if got := sb.String(); got != c.exp {
+			t.Errorf("[%s] Expected: %q, got: %q", c.format, c.exp, got)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+// This file contains the BWAPI action dump command listing format.
+//
+// The format follows the conventions of BWAPI's libReplayTool action dumps
+// (https://github.com/bwapi/bwapi/tree/master/bwapi/libReplayTool): actions are named after
+// libReplayTool's action classes, and unit types, orders, techs and upgrades are identified
+// by their IDs (which are the same in BWAPI's UnitTypes, Orders, TechTypes and UpgradeTypes).
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/icza/screp/rep/repcmd"
+)
+
+// bwapiActionNames holds the action names differing from the command type names (with spaces removed).
+var bwapiActionNames = map[byte]string{
+	repcmd.TypeIDSelectAdd:        "ShiftSelect",
+	repcmd.TypeIDSelectAdd121:     "ShiftSelect",
+	repcmd.TypeIDSelectRemove:     "ShiftDeselect",
+	repcmd.TypeIDSelectRemove121:  "ShiftDeselect",
+	repcmd.TypeIDAlliance:         "Ally",
+	repcmd.TypeIDTargetedOrder:    "TargetClick",
+	repcmd.TypeIDTargetedOrder121: "TargetClick",
+	repcmd.TypeIDCloack:           "Cloak",
+	repcmd.TypeIDDecloack:         "Decloak",
+	repcmd.TypeIDLiftOff:          "Lift",
+	repcmd.TypeIDTech:             "Research",
+	repcmd.TypeIDCancelTech:       "CancelResearch",
+}
+
+// bwapiUnitTypeNone is the ID of BWAPI's UnitTypes::None.
+const bwapiUnitTypeNone = 228
+
+// bwapiAction returns the action name of a command type.
+func bwapiAction(t *repcmd.Type) string {
+	if name, ok := bwapiActionNames[t.ID]; ok {
+		return name
+	}
+	name := strings.TrimPrefix(t.Name, "[Lobby] ")
+	return strings.ReplaceAll(name, " ", "")
+}
+
+// bwapiParams returns the parameters of a command in a fixed order:
+// positions in pixels (x y), unit tags, and IDs of unit types, orders, techs, upgrades and other enumerations.
+func bwapiParams(cmd repcmd.Cmd) []string {
+	var ps []string
+	add := func(vs ...any) {
+		for _, v := range vs {
+			ps = append(ps, fmt.Sprint(v))
+		}
+	}
+	unitID := func(u *repcmd.Unit) uint16 {
+		if u == nil {
+			return bwapiUnitTypeNone
+		}
+		return u.ID
+	}
+
+	switch x := cmd.(type) {
+	case *repcmd.SelectCmd:
+		for _, tag := range x.UnitTags {
+			add(uint16(tag))
+		}
+	case *repcmd.BuildCmd:
+		add(x.Order.ID, x.Pos.X, x.Pos.Y, unitID(x.Unit))
+	case *repcmd.LandCmd:
+		add(x.Order.ID, x.Pos.X, x.Pos.Y, unitID(x.Unit))
+	case *repcmd.GameSpeedCmd:
+		add(x.Speed.ID)
+	case *repcmd.HotkeyCmd:
+		add(x.HotkeyType.ID, x.Group)
+	case *repcmd.LeaveGameCmd:
+		add(x.Reason.ID)
+	case *repcmd.TrainCmd:
+		add(unitID(x.Unit))
+	case *repcmd.BuildingMorphCmd:
+		add(unitID(x.Unit))
+	case *repcmd.QueueableCmd:
+		add(boolInt(x.Queued))
+	case *repcmd.RightClickCmd:
+		add(x.Pos.X, x.Pos.Y, uint16(x.UnitTag), unitID(x.Unit), boolInt(x.Queued))
+	case *repcmd.TargetedOrderCmd:
+		add(x.Pos.X, x.Pos.Y, uint16(x.UnitTag), unitID(x.Unit), x.Order.ID, boolInt(x.Queued))
+	case *repcmd.UnloadCmd:
+		add(uint16(x.UnitTag))
+	case *repcmd.CancelTrainCmd:
+		add(uint16(x.UnitTag))
+	case *repcmd.MinimapPingCmd:
+		add(x.Pos.X, x.Pos.Y)
+	case *repcmd.LiftOffCmd:
+		add(x.Pos.X, x.Pos.Y)
+	case *repcmd.ChatCmd:
+		add(x.SenderSlotID, strconv.Quote(x.Message))
+	case *repcmd.VisionCmd:
+		for _, id := range x.SlotIDs {
+			add(id)
+		}
+	case *repcmd.AllianceCmd:
+		for _, id := range x.SlotIDs {
+			add(id)
+		}
+		add(boolInt(x.AlliedVictory))
+	case *repcmd.TechCmd:
+		add(x.Tech.ID)
+	case *repcmd.UpgradeCmd:
+		add(x.Upgrade.ID)
+	case *repcmd.LatencyCmd:
+		add(x.Latency.ID)
+	case *repcmd.GeneralCmd:
+		if len(x.Data) > 0 {
+			add(fmt.Sprintf("%x", x.Data))
+		}
+	}
+
+	return ps
+}
+
+// boolInt returns 1 if b is true, 0 otherwise.
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
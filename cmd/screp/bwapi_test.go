@@ -0,0 +1,54 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/icza/screp/rep/repcmd"
+	"github.com/icza/screp/rep/repcore"
+)
+
+func TestBWAPIAction(t *testing.T) {
+	cases := []struct {
+		typeID byte
+		exp    string
+	}{
+		{repcmd.TypeIDRightClick, "RightClick"},
+		{repcmd.TypeIDSelectAdd121, "ShiftSelect"},
+		{repcmd.TypeIDCloack, "Cloak"},
+		{repcmd.TypeIDTech, "Research"},
+		{repcmd.TypeIDStartGame, "StartGame"},
+	}
+
+	for _, c := range cases {
+		if got := bwapiAction(repcmd.TypeByID(c.typeID)); got != c.exp {
+			t.Errorf("[%d] Expected: %v, got: %v", c.typeID, c.exp, got)
+		}
+	}
+}
+
+func TestBWAPIParams(t *testing.T) {
+	base := &repcmd.Base{}
+	cases := []struct {
+		name string
+		cmd  repcmd.Cmd
+		exp  []string
+	}{
+		{"select", &repcmd.SelectCmd{Base: base, UnitTags: []repcmd.UnitTag{1, 2}}, []string{"1", "2"}},
+		{"build", &repcmd.BuildCmd{Base: base, Order: repcmd.OrderByID(0x1e), Pos: repcore.Point{X: 10, Y: 20}, Unit: repcmd.UnitByID(0x8e)},
+			[]string{"30", "10", "20", "142"}},
+		{"right click no unit", &repcmd.RightClickCmd{Base: base, Pos: repcore.Point{X: 1, Y: 2}, Queued: true},
+			[]string{"1", "2", "0", "228", "1"}},
+		{"targeted order", &repcmd.TargetedOrderCmd{Base: base, UnitTag: 5, Unit: repcmd.UnitByID(0), Order: repcmd.OrderByID(0x0a)},
+			[]string{"0", "0", "5", "0", "10", "0"}},
+		{"alliance", &repcmd.AllianceCmd{Base: base, SlotIDs: repcmd.Bytes{0, 3}, AlliedVictory: true}, []string{"0", "3", "1"}},
+		{"general", &repcmd.GeneralCmd{Base: base, Data: []byte{0xab, 0x01}}, []string{"ab01"}},
+		{"no params", &repcmd.GeneralCmd{Base: base}, nil},
+	}
+
+	for _, c := range cases {
+		if got := bwapiParams(c.cmd); !reflect.DeepEqual(got, c.exp) {
+			t.Errorf("[%s] Expected: %v, got: %v", c.name, c.exp, got)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// This file contains the chat subcommand which prints the chat transcript of a replay.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcore"
+	"github.com/icza/screp/repparser"
+)
+
+// chatLine is a line of the chat transcript.
+type chatLine struct {
+	Frame        repcore.Frame
+	Time         string
+	SenderSlotID byte
+	Sender       string
+	Message      string
+}
+
+// chatTranscript returns the chat transcript of a computed replay.
+// Senders are resolved from their slot IDs, color codes are removed from messages.
+func chatTranscript(r *rep.Replay) []*chatLine {
+	lines := []*chatLine{}
+	for _, cc := range r.Computed.ChatCmds {
+		sender := fmt.Sprint("Slot ", cc.SenderSlotID)
+		for _, p := range r.Header.Players {
+			if p.SlotID == uint16(cc.SenderSlotID) {
+				sender = p.Name
+				break
+			}
+		}
+		lines = append(lines, &chatLine{
+			Frame:        cc.Frame,
+			Time:         cc.Frame.String(),
+			SenderSlotID: cc.SenderSlotID,
+			Sender:       sender,
+			Message:      repcore.ParseStyledText(cc.Message).Plain,
+		})
+	}
+	return lines
+}
+
+// parseReplayArg parses the replay given as a subcommand argument, "-" means standard input.
+func parseReplayArg(name string, cfg repparser.Config) (*rep.Replay, error) {
+	if name != "-" {
+		return repparser.ParseFileConfig(name, cfg)
+	}
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return nil, err
+	}
+	return repparser.ParseConfig(data, cfg)
+}
+
+// chat runs the chat subcommand.
+func chat(args []string) {
+	fs := flag.NewFlagSet("chat", flag.ExitOnError)
+	jsonOut := fs.Bool("json", false, "print the transcript in JSON format instead of plain text")
+	fs.Usage = func() {
+		fmt.Println("Usage:")
+		fmt.Printf("\t%s chat [FLAGS] repfile.rep\n", os.Args[0])
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(ExitCodeMissingArguments)
+	}
+
+	r, err := parseReplayArg(fs.Arg(0), repparser.Config{Commands: true})
+	if err != nil {
+		fmt.Printf("Failed to parse replay: %v\n", err)
+		os.Exit(ExitCodeFailedToParseReplay)
+	}
+	r.Compute()
+
+	lines := chatTranscript(r)
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(lines); err != nil {
+			fmt.Printf("Failed to encode output: %v\n", err)
+		}
+		return
+	}
+
+	for _, l := range lines {
+		fmt.Printf("[%s] %s: %s\n", l.Time, l.Sender, l.Message)
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcmd"
+)
+
+func TestChatTranscript(t *testing.T) {
+	r := &rep.Replay{
+		Header: &rep.Header{Players: []*rep.Player{{SlotID: 2, ID: 1, Name: "Flash"}}},
+		Computed: &rep.Computed{ChatCmds: []*repcmd.ChatCmd{
+			{Base: &repcmd.Base{Frame: 1429}, SenderSlotID: 2, Message: "\x07gl hf"},
+			{Base: &repcmd.Base{Frame: 2858}, SenderSlotID: 5, Message: "hi"},
+		}},
+	}
+
+	lines := chatTranscript(r)
+	exp := []chatLine{
+		{Frame: 1429, Time: "01:00", SenderSlotID: 2, Sender: "Flash", Message: "gl hf"},
+		{Frame: 2858, Time: "02:00", SenderSlotID: 5, Sender: "Slot 5", Message: "hi"},
+	}
+	if len(lines) != len(exp) {
+		t.Fatalf("Expected: %v lines, got: %v", len(exp), len(lines))
+	}
+	for i, l := range lines {
+		if *l != exp[i] {
+			t.Errorf("[%d] Expected: %+v, got: %+v", i, exp[i], *l)
+		}
+	}
+}
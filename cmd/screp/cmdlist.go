@@ -0,0 +1,82 @@
+// This file contains the command listing formats.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/icza/screp/rep"
+)
+
+// Command listing formats
+const (
+	cmdsFormatText  = "text"
+	cmdsFormatTSV   = "tsv"
+	cmdsFormatBWAPI = "bwapi"
+	cmdsFormatJSSUH = "jssuh"
+)
+
+const validCmdsFormats = "valid values are 'text', 'tsv', 'bwapi' and 'jssuh';\n" +
+	"'tsv' columns are frame, time, player, type and params (preceded by the file name when processing multiple replays);\n" +
+	"'bwapi' is a BWAPI (libReplayTool) style action dump, tab separated columns are frame, player ID, action and\n" +
+	"space separated params (preceded by the file name when processing multiple replays);\n" +
+	"'jssuh' is a jssuh style action dump, 1 JSON object per line (having a file field when processing multiple replays)"
+
+// tsvReplacer replaces characters having special meaning in TSV.
+var tsvReplacer = strings.NewReplacer("\t", " ", "\n", " ", "\r", " ")
+
+// writeCmds writes the commands of the replay, 1 line per command in the given format.
+// If file is not empty, it is included in each line.
+func writeCmds(w io.Writer, r *rep.Replay, format, file string) error {
+	if r.Commands == nil {
+		return nil
+	}
+
+	for _, cmd := range r.Commands.Cmds {
+		b := cmd.BaseCmd()
+		player := fmt.Sprint("PID ", b.PlayerID)
+		if p := r.Header.PIDPlayers[b.PlayerID]; p != nil {
+			player = p.Name
+		}
+
+		var err error
+		switch format {
+		case cmdsFormatBWAPI:
+			fields := []string{fmt.Sprint(int32(b.Frame)), fmt.Sprint(b.PlayerID), bwapiAction(b.Type), strings.Join(bwapiParams(cmd), " ")}
+			if file != "" {
+				fields = append([]string{file}, fields...)
+			}
+			for i, f := range fields {
+				fields[i] = tsvReplacer.Replace(f)
+			}
+			_, err = fmt.Fprintln(w, strings.Join(fields, "\t"))
+		case cmdsFormatJSSUH:
+			var line []byte
+			if line, err = jssuhLine(cmd, file); err == nil {
+				_, err = fmt.Fprintf(w, "%s\n", line)
+			}
+		case cmdsFormatTSV:
+			fields := []string{fmt.Sprint(int32(b.Frame)), b.Frame.String(), player, b.Type.Name, cmd.Params(true)}
+			if file != "" {
+				fields = append([]string{file}, fields...)
+			}
+			for i, f := range fields {
+				fields[i] = tsvReplacer.Replace(f)
+			}
+			_, err = fmt.Fprintln(w, strings.Join(fields, "\t"))
+		default:
+			prefix := ""
+			if file != "" {
+				prefix = file + ": "
+			}
+			_, err = fmt.Fprintf(w, "%s%s %6d  %-15s %-20s %s\n", prefix, b.Frame, b.Frame, player, b.Type.Name, cmd.Params(true))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcmd"
+	"github.com/icza/screp/rep/repcore"
+)
+
+func TestWriteCmds(t *testing.T) {
+	p := &rep.Player{ID: 0, Name: "Alice"}
+	r := &rep.Replay{
+		Header: &rep.Header{PIDPlayers: map[byte]*rep.Player{0: p}},
+		Commands: &rep.Commands{Cmds: []repcmd.Cmd{
+			&repcmd.TrainCmd{
+				Base: &repcmd.Base{Frame: 1000, PlayerID: 0, Type: repcmd.TypeByID(repcmd.TypeIDTrain)},
+				Unit: repcmd.UnitByID(0x29),
+			},
+			&repcmd.ChatCmd{
+				Base:    &repcmd.Base{Frame: repcore.Frame(1429), PlayerID: 5, Type: repcmd.TypeByID(repcmd.TypeIDChat)},
+				Message: "gl hf",
+			},
+		}},
+	}
+
+	cases := []struct {
+		format, file, exp string
+	}{
+		{cmdsFormatText, "",
+			"00:42   1000  Alice           Train                Unit: Drone\n" +
+				"01:00   1429  PID 5           Chat                 SenderSlotID: 0, Message: \"gl hf\"\n"},
+		{cmdsFormatTSV, "a.rep",
+			"a.rep\t1000\t00:42\tAlice\tTrain\tUnit: Drone\n" +
+				"a.rep\t1429\t01:00\tPID 5\tChat\tSenderSlotID: 0, Message: \"gl hf\"\n"},
+		{cmdsFormatBWAPI, "",
+			"1000\t0\tTrain\t41\n" +
+				"1429\t5\tChat\t0 \"gl hf\"\n"},
+		{cmdsFormatJSSUH, "a.rep",
+			`{"file":"a.rep","frame":1000,"player":0,"action":"Train","unit_id":41}` + "\n" +
+				`{"file":"a.rep","frame":1429,"player":5,"action":"Chat","sender":0,"message":"gl hf"}` + "\n"},
+	}
+
+	for _, c := range cases {
+		buf := &bytes.Buffer{}
+		if err := writeCmds(buf, r, c.format, c.file); err != nil {
+			t.Fatalf("[%s] Failed to write commands: %v", c.format, err)
+		}
+		if got := buf.String(); got != c.exp {
+			t.Errorf("[%s] Expected: %q, got: %q", c.format, c.exp, got)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+// This file contains the handling of the compute flag.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/icza/screp/rep"
+)
+
+// defaultComputations is the default value of the compute flag, same as rep.Replay.Compute().
+const defaultComputations = "eapm,winners"
+
+// validComputations lists the valid values of the compute flag.
+const validComputations = "valid values are 'eapm', 'winners', 'buildorders', 'mapanalysis', 'all' and 'none';\n" +
+	"command counts, APMs, chat, start locations and teams are always computed (unless 'none')"
+
+// computeNone is the value of the compute flag which excludes computed data from the output.
+const computeNone = "none"
+
+// computeCfg is the compute configuration built from the compute flag.
+var computeCfg, _ = parseComputations(defaultComputations)
+
+// parseComputations parses a comma separated list of computations.
+func parseComputations(s string) (cfg rep.ComputeConfig, err error) {
+	for _, name := range strings.Split(s, ",") {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "eapm":
+			cfg.EAPM = true
+		case "winners":
+			cfg.Winners = true
+		case "buildorders":
+			cfg.BuildOrders = true
+		case "mapanalysis":
+			cfg.MapAnalysis = true
+		case "all":
+			cfg.EAPM, cfg.Winners, cfg.BuildOrders, cfg.MapAnalysis = true, true, true, true
+		case computeNone, "":
+		default:
+			return cfg, fmt.Errorf("invalid computation: %q", name)
+		}
+	}
+	return
+}
+
+// computeReplay computes the replay according to the compute flag.
+func computeReplay(r *rep.Replay) {
+	r.ComputeConfig(computeCfg)
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/icza/screp/rep"
+)
+
+func TestParseComputations(t *testing.T) {
+	cases := []struct {
+		s   string
+		cfg rep.ComputeConfig
+		ok  bool
+	}{
+		{defaultComputations, rep.ComputeConfig{EAPM: true, Winners: true}, true},
+		{"all", rep.ComputeConfig{EAPM: true, Winners: true, BuildOrders: true, MapAnalysis: true}, true},
+		{"none", rep.ComputeConfig{}, true},
+		{" Winners, buildorders", rep.ComputeConfig{Winners: true, BuildOrders: true}, true},
+		{"winners,apm", rep.ComputeConfig{}, false},
+	}
+
+	for _, c := range cases {
+		cfg, err := parseComputations(c.s)
+		if ok := err == nil; ok != c.ok || ok && cfg != c.cfg {
+			t.Errorf("[%q] Expected: %+v %v, got: %+v %v", c.s, c.cfg, c.ok, cfg, ok)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+// This file contains the CSV output format.
+
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/icza/screp/rep"
+)
+
+// defaultCSVColumns is the default value of the csvcols flag.
+const defaultCSVColumns = "file,date,map,matchup,players,apm,winner,duration,error"
+
+// csvColumns maps from CSV column name to the function producing the column value.
+// The "file" and "error" columns are not listed here, they are not derived from the replay.
+var csvColumns = map[string]func(r *rep.Replay) string{
+	"date": func(r *rep.Replay) string {
+		return r.Header.StartTime.Format("2006-01-02 15:04:05")
+	},
+	"engine": func(r *rep.Replay) string {
+		return r.Header.Engine.ShortName
+	},
+	"version": func(r *rep.Replay) string {
+		return r.Header.Version
+	},
+	"title": func(r *rep.Replay) string {
+		return r.Header.StyledTitle().Plain
+	},
+	"map": func(r *rep.Replay) string {
+		if r.MapData != nil && r.MapData.Name != "" {
+			return r.MapData.StyledName().Plain
+		}
+		return r.Header.StyledMap().Plain
+	},
+	"type": func(r *rep.Replay) string {
+		return r.Header.Type.Name
+	},
+	"matchup": func(r *rep.Replay) string {
+		return r.Header.Matchup()
+	},
+	"players": func(r *rep.Replay) string {
+		return r.Header.PlayerNames()
+	},
+	"apm": func(r *rep.Replay) string {
+		apms := make([]string, len(r.Header.Players))
+		for i, p := range r.Header.Players {
+			var apm int32
+			if pd := r.Computed.PIDPlayerDescs[p.ID]; pd != nil {
+				apm = pd.APM
+			}
+			apms[i] = fmt.Sprint(apm)
+		}
+		return strings.Join(apms, ", ")
+	},
+	"winner": func(r *rep.Replay) string {
+		if r.Computed.WinnerTeam == 0 {
+			return ""
+		}
+		var names []string
+		for _, p := range r.Header.Players {
+			if !p.Observer && p.Team == r.Computed.WinnerTeam {
+				names = append(names, p.Name)
+			}
+		}
+		return strings.Join(names, ", ")
+	},
+	"duration": func(r *rep.Replay) string {
+		return r.Header.Frames.String()
+	},
+	"frames": func(r *rep.Replay) string {
+		return fmt.Sprint(int32(r.Header.Frames))
+	},
+}
+
+// validCSVColumns lists the valid CSV column names.
+const validCSVColumns = "valid columns are 'file', 'date', 'engine', 'version', 'title', 'map', 'type', 'matchup', 'players', 'apm', 'winner', 'duration', 'frames', 'error'"
+
+// rowOutput writes 1 row per replay (the CSV and Parquet formats).
+type rowOutput interface {
+	// write writes the row of a replay.
+	// If err is not nil, only the file and error columns are filled.
+	write(file string, r *rep.Replay, err error) error
+
+	// flush flushes the buffered rows.
+	flush() error
+}
+
+// csvOutput writes 1 CSV row per replay.
+type csvOutput struct {
+	w    *csv.Writer
+	cols []string
+}
+
+// newCSVOutput creates a new csvOutput with the given comma separated column list,
+// and writes the header row.
+func newCSVOutput(w io.Writer, cols string) (*csvOutput, error) {
+	co := &csvOutput{w: csv.NewWriter(w)}
+	for _, col := range strings.Split(cols, ",") {
+		col = strings.ToLower(strings.TrimSpace(col))
+		if _, ok := csvColumns[col]; !ok && col != "file" && col != "error" {
+			return nil, fmt.Errorf("invalid CSV column: %q", col)
+		}
+		co.cols = append(co.cols, col)
+	}
+
+	return co, co.w.Write(co.cols)
+}
+
+// write writes the row of a replay.
+// If err is not nil, only the file and error columns are filled.
+func (co *csvOutput) write(file string, r *rep.Replay, err error) error {
+	if err == nil {
+		computeReplay(r)
+	}
+
+	row := make([]string, len(co.cols))
+	for i, col := range co.cols {
+		switch {
+		case col == "file":
+			row[i] = file
+		case col == "error":
+			if err != nil {
+				row[i] = err.Error()
+			}
+		case err == nil:
+			row[i] = csvColumns[col](r)
+		}
+	}
+
+	return co.w.Write(row)
+}
+
+// flush flushes the buffered rows.
+func (co *csvOutput) flush() error {
+	co.w.Flush()
+	return co.w.Error()
+}
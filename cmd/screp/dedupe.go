@@ -0,0 +1,185 @@
+// This file contains the dedupe subcommand which detects duplicate replays.
+
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/repparser"
+)
+
+// Dedupe actions
+const (
+	dedupeReport   = "report"
+	dedupeRemove   = "remove"
+	dedupeHardlink = "hardlink"
+)
+
+// replayContent is a distinct replay content with the files having it.
+type replayContent struct {
+	hash   string
+	files  []string
+	header *rep.Header // nil if the replay could not be parsed
+}
+
+// dupGroup is a group of duplicate replays.
+type dupGroup struct {
+	// sameGame tells if the group consists of different replays of the same game
+	// (else the files have identical content)
+	sameGame bool
+
+	// key is the content hash or game fingerprint
+	key string
+
+	// keep is the file to keep, dups are the duplicates of keep
+	keep string
+	dups []string
+}
+
+// findDuplicates groups the given replay contents into duplicate groups.
+// Identical files form a group, keeping the first file.
+// Different (first files of) replays of the same game (e.g. saved by 2 players) form a group,
+// keeping the longest replay (the most complete one).
+func findDuplicates(contents []*replayContent) (groups []*dupGroup) {
+	for _, c := range contents {
+		if len(c.files) > 1 {
+			groups = append(groups, &dupGroup{key: c.hash, keep: c.files[0], dups: c.files[1:]})
+		}
+	}
+
+	var fps []string // To keep the order of games
+	games := map[string][]*replayContent{}
+	for _, c := range contents {
+		if c.header == nil {
+			continue
+		}
+		fp := c.header.GameFingerprint()
+		if games[fp] == nil {
+			fps = append(fps, fp)
+		}
+		games[fp] = append(games[fp], c)
+	}
+
+	for _, fp := range fps {
+		cs := games[fp]
+		if len(cs) < 2 {
+			continue
+		}
+		keep := cs[0]
+		for _, c := range cs[1:] {
+			if c.header.Frames > keep.header.Frames {
+				keep = c
+			}
+		}
+		g := &dupGroup{sameGame: true, key: fp, keep: keep.files[0]}
+		for _, c := range cs {
+			if c != keep {
+				// Other files of c are listed in their identical content group
+				g.dups = append(g.dups, c.files[0])
+			}
+		}
+		groups = append(groups, g)
+	}
+
+	return
+}
+
+// dedupe runs the dedupe subcommand.
+func dedupe(args []string) {
+	fs := flag.NewFlagSet("dedupe", flag.ExitOnError)
+	action := fs.String("action", dedupeReport, "action to perform on duplicates; valid values are:\n"+
+		"'report': only report duplicates\n"+
+		"'remove': remove duplicates (keeping the longest replay of the same game)\n"+
+		"'hardlink': replace identical files with hard links (replays of the same game are only reported)")
+	recursive := fs.Bool("r", false, "search replays recursively in the subfolders of folder arguments")
+	fs.Usage = func() {
+		fmt.Println("Usage:")
+		fmt.Printf("\t%s dedupe [FLAGS] repfile.rep \"replays/*.rep\" replayfolder...\n", os.Args[0])
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	switch *action {
+	case dedupeReport, dedupeRemove, dedupeHardlink:
+	default:
+		fmt.Printf("Invalid action: %v\n", *action)
+		os.Exit(ExitCodeMissingArguments)
+	}
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(ExitCodeMissingArguments)
+	}
+
+	files, _, err := replayFiles(fs.Args(), *recursive)
+	if err != nil {
+		fmt.Printf("Failed to list replay files: %v\n", err)
+		os.Exit(ExitCodeMissingArguments)
+	}
+
+	var contents []*replayContent
+	hashContents := map[string]*replayContent{}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Printf("Failed to read replay %s: %v\n", file, err)
+			continue
+		}
+		sum := sha1.Sum(data)
+		hash := hex.EncodeToString(sum[:])
+		if c := hashContents[hash]; c != nil {
+			c.files = append(c.files, file)
+			continue
+		}
+
+		c := &replayContent{hash: hash, files: []string{file}}
+		if r, err := repparser.ParseConfig(data, repparser.Config{}); err == nil {
+			c.header = r.Header
+		} else {
+			fmt.Printf("Failed to parse replay %s: %v\n", file, err)
+		}
+		contents = append(contents, c)
+		hashContents[hash] = c
+	}
+
+	for _, g := range findDuplicates(contents) {
+		if g.sameGame {
+			fmt.Printf("Same game (fingerprint %s):\n", g.key)
+		} else {
+			fmt.Printf("Identical content (SHA-1 %s):\n", g.key)
+		}
+		fmt.Println("  keep:", g.keep)
+		for _, dup := range g.dups {
+			fmt.Println("  dup: ", dup)
+			switch {
+			case *action == dedupeRemove:
+				if err := os.Remove(dup); err != nil {
+					fmt.Printf("Failed to remove replay: %v\n", err)
+				}
+			case *action == dedupeHardlink && !g.sameGame:
+				if err := hardlink(g.keep, dup); err != nil {
+					fmt.Printf("Failed to hard-link replay: %v\n", err)
+				}
+			}
+		}
+	}
+}
+
+// hardlink replaces the dup file with a hard link to the keep file.
+func hardlink(keep, dup string) error {
+	tmp := dup + ".screp-tmp"
+	if err := os.Link(keep, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dup); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcore"
+)
+
+func TestFindDuplicates(t *testing.T) {
+	newHeader := func(start int64, frames repcore.Frame) *rep.Header {
+		return &rep.Header{
+			StartTime: time.Unix(start, 0),
+			Frames:    frames,
+			Players:   []*rep.Player{{RawName: "A", Race: repcore.RaceTerran}},
+		}
+	}
+
+	contents := []*replayContent{
+		{hash: "h1", files: []string{"a.rep", "a-copy.rep"}, header: newHeader(1, 100)},
+		{hash: "h2", files: []string{"b.rep"}, header: newHeader(2, 100)},
+		{hash: "h3", files: []string{"a-other-saver.rep", "a-other-saver-copy.rep"}, header: newHeader(1, 200)},
+		{hash: "h4", files: []string{"invalid.rep", "invalid-copy.rep"}},
+	}
+
+	groups := findDuplicates(contents)
+
+	exp := []string{
+		"false h1 a.rep [a-copy.rep]",
+		"false h3 a-other-saver.rep [a-other-saver-copy.rep]",
+		"false h4 invalid.rep [invalid-copy.rep]",
+		fmt.Sprintf("true %s a-other-saver.rep [a.rep]", newHeader(1, 0).GameFingerprint()),
+	}
+	if len(groups) != len(exp) {
+		t.Fatalf("Expected: %v groups, got: %v", len(exp), len(groups))
+	}
+	for i, g := range groups {
+		if got := fmt.Sprint(g.sameGame, " ", g.key, " ", g.keep, " ", g.dups); got != exp[i] {
+			t.Errorf("[%d] Expected: %v, got: %v", i, exp[i], got)
+		}
+	}
+}
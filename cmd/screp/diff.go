@@ -0,0 +1,183 @@
+// This file contains the diff subcommand which compares 2 replays.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcmd"
+	"github.com/icza/screp/repparser"
+)
+
+// replayDiff is the result of comparing 2 replays (A and B).
+type replayDiff struct {
+	// SameGame tells if the replays record the same game (see rep.Header.GameFingerprint())
+	SameGame bool
+
+	// Header lists the differing header fields
+	Header []*fieldDiff
+
+	// Players lists the differing player properties
+	Players []*fieldDiff
+
+	// Map lists the differing map properties
+	Map []*fieldDiff
+
+	// CmdCounts are the number of commands in A and B
+	CmdCounts [2]int
+
+	// Divergence is the first differing command, nil if the command streams are identical,
+	// or one of them is the prefix of the other (e.g. the game saved by 2 players leaving at different times)
+	Divergence *cmdDiff `json:",omitempty"`
+}
+
+// fieldDiff is a differing field.
+type fieldDiff struct {
+	Field string
+	A, B  string
+}
+
+// cmdDiff describes the point where 2 command streams diverge.
+type cmdDiff struct {
+	// Index of the first differing command
+	Index int
+
+	// A and B are the differing commands
+	A, B string
+}
+
+// cmdString returns a string representation of a command for comparison.
+func cmdString(cmd repcmd.Cmd) string {
+	b := cmd.BaseCmd()
+	return fmt.Sprintf("frame %d, player %d, %s: %s", b.Frame, b.PlayerID, b.Type, cmd.Params(true))
+}
+
+// diffReplays compares 2 replays.
+func diffReplays(a, b *rep.Replay) *replayDiff {
+	d := &replayDiff{SameGame: a.Header.GameFingerprint() == b.Header.GameFingerprint()}
+
+	add := func(diffs *[]*fieldDiff, field string, va, vb any) {
+		sa, sb := fmt.Sprint(va), fmt.Sprint(vb)
+		if sa != sb {
+			*diffs = append(*diffs, &fieldDiff{Field: field, A: sa, B: sb})
+		}
+	}
+
+	ha, hb := a.Header, b.Header
+	add(&d.Header, "Engine", ha.Engine.Name, hb.Engine.Name)
+	add(&d.Header, "Version", ha.Version, hb.Version)
+	add(&d.Header, "Frames", ha.Frames, hb.Frames)
+	add(&d.Header, "StartTime", ha.StartTime, hb.StartTime)
+	add(&d.Header, "Title", ha.Title, hb.Title)
+	add(&d.Header, "MapSize", ha.MapSize(), hb.MapSize())
+	add(&d.Header, "Speed", ha.Speed.Name, hb.Speed.Name)
+	add(&d.Header, "Type", ha.Type.Name, hb.Type.Name)
+	add(&d.Header, "SubType", ha.SubType, hb.SubType)
+	add(&d.Header, "Host", ha.Host, hb.Host)
+	add(&d.Header, "Map", ha.Map, hb.Map)
+
+	add(&d.Players, "Count", len(ha.Players), len(hb.Players))
+	for i := range min(len(ha.Players), len(hb.Players)) {
+		pa, pb := ha.Players[i], hb.Players[i]
+		prefix := fmt.Sprintf("Player %d ", i+1)
+		add(&d.Players, prefix+"Name", pa.Name, pb.Name)
+		add(&d.Players, prefix+"SlotID", pa.SlotID, pb.SlotID)
+		add(&d.Players, prefix+"Race", pa.Race.Name, pb.Race.Name)
+		add(&d.Players, prefix+"Team", pa.Team, pb.Team)
+	}
+
+	if a.MapData != nil && b.MapData != nil {
+		add(&d.Map, "CHKHash", a.MapData.CHKHash, b.MapData.CHKHash)
+		add(&d.Map, "Fingerprint", a.MapData.Fingerprint, b.MapData.Fingerprint)
+	}
+
+	var ca, cb []repcmd.Cmd
+	if a.Commands != nil {
+		ca = a.Commands.Cmds
+	}
+	if b.Commands != nil {
+		cb = b.Commands.Cmds
+	}
+	d.CmdCounts = [2]int{len(ca), len(cb)}
+	for i := range min(len(ca), len(cb)) {
+		if sa, sb := cmdString(ca[i]), cmdString(cb[i]); sa != sb {
+			d.Divergence = &cmdDiff{Index: i, A: sa, B: sb}
+			break
+		}
+	}
+
+	return d
+}
+
+// printDiff prints the diff in human-readable form.
+func printDiff(w io.Writer, d *replayDiff) {
+	fmt.Fprintln(w, "Same game:", d.SameGame)
+
+	section := func(name string, diffs []*fieldDiff) {
+		if len(diffs) == 0 {
+			fmt.Fprintf(w, "%s: identical\n", name)
+			return
+		}
+		fmt.Fprintf(w, "%s:\n", name)
+		for _, fd := range diffs {
+			fmt.Fprintf(w, "  %s: %s | %s\n", fd.Field, fd.A, fd.B)
+		}
+	}
+	section("Header", d.Header)
+	section("Players", d.Players)
+	section("Map", d.Map)
+
+	switch {
+	case d.Divergence != nil:
+		fmt.Fprintf(w, "Commands: diverge at command #%d\n  %s\n  %s\n", d.Divergence.Index, d.Divergence.A, d.Divergence.B)
+	case d.CmdCounts[0] == d.CmdCounts[1]:
+		fmt.Fprintf(w, "Commands: identical (%d commands)\n", d.CmdCounts[0])
+	default:
+		fmt.Fprintf(w, "Commands: identical up to the shorter stream (%d | %d commands)\n", d.CmdCounts[0], d.CmdCounts[1])
+	}
+}
+
+// diff runs the diff subcommand.
+func diff(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	jsonOut := fs.Bool("json", false, "print the differences in JSON format")
+	fs.Usage = func() {
+		fmt.Println("Usage:")
+		fmt.Printf("\t%s diff [FLAGS] a.rep b.rep\n", os.Args[0])
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(ExitCodeMissingArguments)
+	}
+
+	var reps [2]*rep.Replay
+	for i := range reps {
+		r, err := repparser.ParseFileConfig(fs.Arg(i), repparser.Config{Commands: true, MapData: true})
+		if err != nil {
+			fmt.Printf("Failed to parse replay %s: %v\n", fs.Arg(i), err)
+			os.Exit(ExitCodeFailedToParseReplay)
+		}
+		reps[i] = r
+	}
+
+	d := diffReplays(reps[0], reps[1])
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(d); err != nil {
+			fmt.Printf("Failed to encode output: %v\n", err)
+		}
+		return
+	}
+
+	printDiff(os.Stdout, d)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcmd"
+	"github.com/icza/screp/rep/repcore"
+)
+
+func TestDiffReplays(t *testing.T) {
+	newReplay := func(frames repcore.Frame, name string, cmdFrames ...repcore.Frame) *rep.Replay {
+		var cmds []repcmd.Cmd
+		for _, f := range cmdFrames {
+			cmds = append(cmds, &repcmd.GeneralCmd{Base: &repcmd.Base{Frame: f, Type: repcmd.TypeSelect}, Data: []byte{1}})
+		}
+		return &rep.Replay{
+			Header: &rep.Header{
+				Engine: repcore.EngineBroodWar, Speed: repcore.SpeedFastest, Type: repcore.GameTypeMelee,
+				Frames: frames, StartTime: time.Unix(1e9, 0),
+				Players: []*rep.Player{{Name: name, RawName: name, Race: repcore.RaceZerg}},
+			},
+			MapData:  &rep.MapData{CHKHash: "h"},
+			Commands: &rep.Commands{Cmds: cmds},
+		}
+	}
+
+	a := newReplay(100, "Jaedong", 1, 2, 3)
+
+	cases := []struct {
+		name string
+		b    *rep.Replay
+		exp  string
+	}{
+		{"identical", newReplay(100, "Jaedong", 1, 2, 3), "true 0 0 0 [3 3] <nil>"},
+		{"other saver", newReplay(90, "Jaedong", 1, 2), "true 1 0 0 [3 2] <nil>"},
+		{"tampered", newReplay(100, "Flash", 1, 5, 3), "false 0 1 0 [3 3] &{1 frame 2, player 0, Select: Data: [01] frame 5, player 0, Select: Data: [01]}"},
+	}
+	for _, c := range cases {
+		d := diffReplays(a, c.b)
+		got := fmt.Sprint(d.SameGame, " ", len(d.Header), " ", len(d.Players), " ", len(d.Map), " ", d.CmdCounts, " ", d.Divergence)
+		if got != c.exp {
+			t.Errorf("[%s] Expected: %v, got: %v", c.name, c.exp, got)
+		}
+	}
+
+	sb := &strings.Builder{}
+	printDiff(sb, diffReplays(a, newReplay(90, "Jaedong", 1, 2)))
+	exp := `Same game: true
+Header:
+  Frames: 00:04 | 00:03
+Players: identical
+Map: identical
+Commands: identical up to the shorter stream (3 | 2 commands)
+`
+	if got := sb.String(); got != exp {
+		t.Errorf("Expected: %q, got: %q", exp, got)
+	}
+}
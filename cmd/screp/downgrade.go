@@ -0,0 +1,57 @@
+// This file contains the downgrade subcommand which converts modern replays to the legacy format.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/icza/screp/repparser"
+)
+
+// downgrade runs the downgrade subcommand.
+func downgrade(args []string) {
+	fs := flag.NewFlagSet("downgrade", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Println("Usage:")
+		fmt.Printf("\t%s downgrade repfile.rep output.rep\n", os.Args[0])
+		fmt.Println("Converts a modern replay into the legacy (pre 1.18) replay format, playable on 1.16.1.")
+		fmt.Println("Replays having commands introduced in 1.21 cannot be converted.")
+		fmt.Println("Player names longer than 24 bytes are truncated, modern sections are dropped.")
+		fmt.Println("Legacy replays are written unchanged.")
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(ExitCodeMissingArguments)
+	}
+
+	repData, err := readReplayArg(fs.Arg(0))
+	if err != nil {
+		fmt.Printf("Failed to read replay: %v\n", err)
+		os.Exit(ExitCodeFailedToParseReplay)
+	}
+
+	legacy, err := repparser.ToLegacy(repData)
+	if err == nil {
+		// Verify the result is a valid replay:
+		_, err = repparser.Parse(legacy)
+	}
+	if err != nil {
+		fmt.Printf("Failed to convert replay: %v\n", err)
+		os.Exit(ExitCodeFailedToParseReplay)
+	}
+	if bytes.Equal(legacy, repData) {
+		fmt.Println("Replay is already in the legacy format.")
+	}
+
+	if err := os.WriteFile(fs.Arg(1), legacy, 0644); err != nil {
+		fmt.Printf("Failed to write output file: %v\n", err)
+		os.Exit(ExitCodeFailedToCreateOutputFile)
+	}
+}
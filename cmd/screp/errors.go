@@ -0,0 +1,128 @@
+// This file contains the machine-readable error output and the exit code matrix.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+
+	"github.com/icza/screp/repparser"
+)
+
+// Error formats
+const (
+	errorFormatText = "text"
+	errorFormatJSON = "json"
+)
+
+const validErrorFormats = "valid values are 'text', 'json'"
+
+// Error classes of the JSON error output
+const (
+	errClassIO        = "io"         // The replay could not be read (e.g. file does not exist)
+	errClassNotReplay = "not_replay" // The input is not a replay file
+	errClassParsing   = "parsing"    // The replay is corrupt or unsupported
+	errClassOutput    = "output"     // The output file could not be created
+)
+
+// exitCodesHelp documents the exit codes. The values are stable, scripts may rely on them.
+const exitCodesHelp = `Exit codes:
+	0	success
+	1	missing or invalid arguments (ExitCodeMissingArguments)
+	2	failed to read or parse a replay (ExitCodeFailedToParseReplay)
+	3	failed to create the output file (ExitCodeFailedToCreateOutputFile)
+	4	invalid map data hash algorithm (ExitCodeInvalidMapDataHash)
+	5	invalid format, template, filter or computation list (ExitCodeInvalidFormat)
+	6	invalid replay found by the lint subcommand (ExitCodeInvalidReplay)`
+
+// errorOutput is the JSON error object printed on failure if the error format is JSON.
+type errorOutput struct {
+	// Error is the error message
+	Error string
+
+	// Class of the error, one of the errClassXXX constants
+	Class string
+
+	// Location of the error in the replay, if known (see repparser.ParseError)
+	Location *errorLocation `json:",omitempty"`
+
+	// Warnings logged by the parser before the error occurred
+	Warnings []string `json:",omitempty"`
+
+	// ExitCode the app exits with (not set by the serve subcommand)
+	ExitCode int `json:",omitempty"`
+}
+
+// errorLocation is the location of a replay parsing error.
+type errorLocation struct {
+	// SectionID and Section name where parsing failed, omitted if unknown
+	SectionID *int   `json:",omitempty"`
+	Section   string `json:",omitempty"`
+
+	// Offset is the byte offset in the (decoded) section data, omitted if unknown
+	Offset *int `json:",omitempty"`
+
+	// Frame, PlayerID and Command (type name) of the command being parsed, omitted if unknown
+	Frame    *int32 `json:",omitempty"`
+	PlayerID *byte  `json:",omitempty"`
+	Command  string `json:",omitempty"`
+}
+
+// newErrorLocation returns the location of err, nil if err is not a *repparser.ParseError.
+func newErrorLocation(err error) *errorLocation {
+	var pe *repparser.ParseError
+	if !errors.As(err, &pe) {
+		return nil
+	}
+	loc := &errorLocation{}
+	if pe.Section != nil {
+		id := pe.Section.ID
+		loc.SectionID, loc.Section = &id, pe.Section.Name()
+	}
+	if offset := pe.Offset; offset >= 0 {
+		loc.Offset = &offset
+	}
+	if pe.Cmd != nil {
+		frame, playerID := int32(pe.Cmd.Frame), pe.Cmd.PlayerID
+		loc.Frame, loc.PlayerID = &frame, &playerID
+		if pe.Cmd.Type != nil {
+			loc.Command = pe.Cmd.Type.Name
+		}
+	}
+	return loc
+}
+
+// errorClass returns the class of a replay parsing error.
+func errorClass(err error) string {
+	switch {
+	case errors.Is(err, repparser.ErrNotReplayFile):
+		return errClassNotReplay
+	case errors.Is(err, repparser.ErrParsing):
+		return errClassParsing
+	case errors.As(err, new(*fs.PathError)):
+		return errClassIO
+	}
+	return errClassParsing
+}
+
+// writeError writes an error in the given error format.
+// msg is the prefix of the error in text format.
+func writeError(w io.Writer, errFormat, msg string, err error, class string, warnings []string, exitCode int) {
+	if errFormat != errorFormatJSON {
+		fmt.Fprintf(w, "%s: %v\n", msg, err)
+		return
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(&errorOutput{
+		Error:    err.Error(),
+		Class:    class,
+		Location: newErrorLocation(err),
+		Warnings: warnings,
+		ExitCode: exitCode,
+	})
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/icza/screp/rep/repcmd"
+	"github.com/icza/screp/repparser"
+)
+
+func TestErrorClass(t *testing.T) {
+	_, errNotExist := os.Open("non-existing-file.rep")
+
+	cases := []struct {
+		name  string
+		err   error
+		class string
+	}{
+		{"not replay", repparser.ErrNotReplayFile, errClassNotReplay},
+		{"wrapped not replay", fmt.Errorf("x: %w", repparser.ErrNotReplayFile), errClassNotReplay},
+		{"parsing", repparser.ErrParsing, errClassParsing},
+		{"not exist", errNotExist, errClassIO},
+		{"other", errors.New("other"), errClassParsing},
+	}
+
+	for _, c := range cases {
+		if got := errorClass(c.err); got != c.class {
+			t.Errorf("[%s] Expected: %v, got: %v", c.name, c.class, got)
+		}
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	buf := &bytes.Buffer{}
+	writeError(buf, errorFormatText, "Failed to parse replay", repparser.ErrParsing, errClassParsing, nil, ExitCodeFailedToParseReplay)
+	if exp, got := "Failed to parse replay: parsing\n", buf.String(); got != exp {
+		t.Errorf("Expected: %v, got: %v", exp, got)
+	}
+
+	wc := newWarningCollector(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	logger := slog.New(wc)
+	logger.Warn("Unknown modern section", "id", "abcd")
+	logger.Info("Not a warning")
+
+	buf.Reset()
+	writeError(buf, errorFormatJSON, "Failed to parse replay", repparser.ErrParsing, errClassParsing, wc.Warnings(), ExitCodeFailedToParseReplay)
+	var eo errorOutput
+	if err := json.Unmarshal(buf.Bytes(), &eo); err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+	exp := errorOutput{Error: "parsing", Class: errClassParsing, Warnings: []string{"Unknown modern section id=abcd"}, ExitCode: ExitCodeFailedToParseReplay}
+	if fmt.Sprint(eo) != fmt.Sprint(exp) {
+		t.Errorf("Expected: %v, got: %v", exp, eo)
+	}
+}
+
+func TestErrorLocation(t *testing.T) {
+	pe := &repparser.ParseError{Section: repparser.SectionCommands, Offset: 0x10, Err: repparser.ErrParsing,
+		Cmd: &repcmd.Base{Frame: 10, PlayerID: 1, Type: repcmd.TypeChat}}
+
+	cases := []struct {
+		name string
+		err  error
+		exp  string // Expected JSON
+	}{
+		{"not a parse error", repparser.ErrParsing, `null`},
+		{"unknown location", &repparser.ParseError{Offset: -1, Err: repparser.ErrParsing}, `{}`},
+		{"command", fmt.Errorf("x: %w", pe), `{"SectionID":2,"Section":"commands","Offset":16,"Frame":10,"PlayerID":1,"Command":"Chat"}`},
+	}
+
+	for _, c := range cases {
+		data, err := json.Marshal(newErrorLocation(c.err))
+		if err != nil {
+			t.Errorf("[%s] Expected no error, got: %v", c.name, err)
+			continue
+		}
+		if got := string(data); got != c.exp {
+			t.Errorf("[%s] Expected: %v, got: %v", c.name, c.exp, got)
+		}
+	}
+}
@@ -0,0 +1,371 @@
+// This file contains the filter expression language used to select replays.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/icza/screp/rep"
+)
+
+// filterFields maps from filter field name (lowercased) to the function producing the field values.
+// A condition on a field is true if any of its values satisfies it.
+var filterFields = map[string]func(r *rep.Replay) []string{
+	"player": playerNames,
+	"race": func(r *rep.Replay) (races []string) {
+		for _, p := range r.Header.Players {
+			if !p.Observer {
+				races = append(races, p.Race.Name)
+			}
+		}
+		return
+	},
+	"winner": func(r *rep.Replay) (names []string) {
+		if r.Computed.WinnerTeam == 0 {
+			return
+		}
+		for _, p := range r.Header.Players {
+			if !p.Observer && p.Team == r.Computed.WinnerTeam {
+				names = append(names, p.Name)
+			}
+		}
+		return
+	},
+	"players": func(r *rep.Replay) []string {
+		return []string{fmt.Sprint(len(playerNames(r)))}
+	},
+	"durationmin": func(r *rep.Replay) []string {
+		return []string{fmt.Sprint(r.Header.Duration().Minutes())}
+	},
+	"durationsec": func(r *rep.Replay) []string {
+		return []string{fmt.Sprint(r.Header.Duration().Seconds())}
+	},
+}
+
+// playerNames returns the names of the non-observer players.
+func playerNames(r *rep.Replay) (names []string) {
+	for _, p := range r.Header.Players {
+		if !p.Observer {
+			names = append(names, p.Name)
+		}
+	}
+	return
+}
+
+func init() {
+	// All single-valued CSV columns are also available as filter fields:
+	for name, f := range csvColumns {
+		if name == "apm" || name == "winner" || name == "players" {
+			continue
+		}
+		filterFields[name] = func(r *rep.Replay) []string { return []string{f(r)} }
+	}
+}
+
+// validFilter describes the filter expression language.
+const validFilter = `conditions are in the form of: field op value
+ops are '==', '!=', '<', '<=', '>', '>=', '~' (regexp match), '!~' (regexp not match)
+values are quoted strings or numbers; numbers are compared numerically
+conditions can be combined with '&&', '||', '!' and parenthesis, e.g.
+  matchup=="TvZ" && durationMin>10 && player~"(?i)flash"
+fields are 'player', 'race', 'winner' (true if any player matches), 'players' (player count),
+'durationMin', 'durationSec', 'date', 'engine', 'version', 'title', 'map', 'type', 'matchup', 'duration', 'frames'`
+
+// filterExpr is a parsed filter expression.
+type filterExpr interface {
+	// eval evaluates the expression on a computed replay.
+	eval(r *rep.Replay) bool
+}
+
+type andExpr struct{ left, right filterExpr }
+
+func (e *andExpr) eval(r *rep.Replay) bool { return e.left.eval(r) && e.right.eval(r) }
+
+type orExpr struct{ left, right filterExpr }
+
+func (e *orExpr) eval(r *rep.Replay) bool { return e.left.eval(r) || e.right.eval(r) }
+
+type notExpr struct{ expr filterExpr }
+
+func (e *notExpr) eval(r *rep.Replay) bool { return !e.expr.eval(r) }
+
+// condExpr is a condition on a field.
+type condExpr struct {
+	field  func(r *rep.Replay) []string
+	op     string
+	value  string
+	num    float64
+	isNum  bool           // Tells if value is a number
+	regexp *regexp.Regexp // Only for the '~' and '!~' ops
+}
+
+func (e *condExpr) eval(r *rep.Replay) bool {
+	// '!=' and '!~' are the negations of '==' and '~':
+	switch e.op {
+	case "!=":
+		return !e.any(r, "==")
+	case "!~":
+		return !e.any(r, "~")
+	}
+	return e.any(r, e.op)
+}
+
+// any tells if any value of the field satisfies the condition with the given operator.
+func (e *condExpr) any(r *rep.Replay, op string) bool {
+	for _, v := range e.field(r) {
+		if op == "~" {
+			if e.regexp.MatchString(v) {
+				return true
+			}
+			continue
+		}
+
+		var c int
+		if e.isNum {
+			n, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			switch {
+			case n < e.num:
+				c = -1
+			case n > e.num:
+				c = 1
+			}
+		} else {
+			c = strings.Compare(v, e.value)
+		}
+
+		var ok bool
+		switch op {
+		case "==":
+			ok = c == 0
+		case "<":
+			ok = c < 0
+		case "<=":
+			ok = c <= 0
+		case ">":
+			ok = c > 0
+		case ">=":
+			ok = c >= 0
+		}
+		if ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Token kinds of the filter expression.
+const (
+	tokenEOF = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenOp
+)
+
+type token struct {
+	kind int
+	text string // Unquoted in case of tokenString
+	pos  int
+}
+
+// filterOps lists the operators, longer ones first.
+var filterOps = []string{"&&", "||", "==", "!=", "<=", ">=", "!~", "<", ">", "~", "!", "(", ")"}
+
+// tokenize splits the filter expression into tokens.
+func tokenize(s string) (tokens []token, err error) {
+	for i := 0; i < len(s); {
+		ch := s[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			i++
+			continue
+		case ch == '"':
+			end := i + 1
+			for ; end < len(s) && s[end] != '"'; end++ {
+				if s[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(s) {
+				return nil, fmt.Errorf("unterminated string at position %d", i)
+			}
+			text, err := strconv.Unquote(s[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string at position %d: %w", i, err)
+			}
+			tokens = append(tokens, token{tokenString, text, i})
+			i = end + 1
+			continue
+		case ch >= '0' && ch <= '9' || ch == '-' || ch == '.':
+			end := i + 1
+			for end < len(s) && (s[end] >= '0' && s[end] <= '9' || s[end] == '.') {
+				end++
+			}
+			tokens = append(tokens, token{tokenNumber, s[i:end], i})
+			i = end
+			continue
+		case ch == '_' || unicode.IsLetter(rune(ch)):
+			end := i + 1
+			for end < len(s) && (s[end] == '_' || unicode.IsLetter(rune(s[end])) || unicode.IsDigit(rune(s[end]))) {
+				end++
+			}
+			tokens = append(tokens, token{tokenIdent, s[i:end], i})
+			i = end
+			continue
+		}
+
+		found := false
+		for _, op := range filterOps {
+			if strings.HasPrefix(s[i:], op) {
+				tokens = append(tokens, token{tokenOp, op, i})
+				i += len(op)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unexpected character %q at position %d", ch, i)
+		}
+	}
+
+	return append(tokens, token{tokenEOF, "", len(s)}), nil
+}
+
+// filterParser is a recursive descent parser of filter expressions.
+type filterParser struct {
+	tokens []token
+	pos    int
+}
+
+// parseFilter parses a filter expression.
+func parseFilter(s string) (filterExpr, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &filterParser{tokens: tokens}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.next(); t.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %q at position %d", t.text, t.pos)
+	}
+
+	return e, nil
+}
+
+// next returns the next token and advances the position.
+func (p *filterParser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// acceptOp advances the position if the next token is the given operator.
+func (p *filterParser) acceptOp(op string) bool {
+	if t := p.tokens[p.pos]; t.kind == tokenOp && t.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *filterParser) parseOr() (filterExpr, error) {
+	e, err := p.parseAnd()
+	for err == nil && p.acceptOp("||") {
+		var right filterExpr
+		if right, err = p.parseAnd(); err == nil {
+			e = &orExpr{e, right}
+		}
+	}
+	return e, err
+}
+
+func (p *filterParser) parseAnd() (filterExpr, error) {
+	e, err := p.parseUnary()
+	for err == nil && p.acceptOp("&&") {
+		var right filterExpr
+		if right, err = p.parseUnary(); err == nil {
+			e = &andExpr{e, right}
+		}
+	}
+	return e, err
+}
+
+func (p *filterParser) parseUnary() (filterExpr, error) {
+	if p.acceptOp("!") {
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notExpr{e}, nil
+	}
+
+	if p.acceptOp("(") {
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.acceptOp(")") {
+			t := p.next()
+			return nil, fmt.Errorf("expected ')' at position %d", t.pos)
+		}
+		return e, nil
+	}
+
+	return p.parseCond()
+}
+
+func (p *filterParser) parseCond() (filterExpr, error) {
+	t := p.next()
+	if t.kind != tokenIdent {
+		return nil, fmt.Errorf("expected field name at position %d", t.pos)
+	}
+	field := filterFields[strings.ToLower(t.text)]
+	if field == nil {
+		return nil, fmt.Errorf("unknown field %q at position %d", t.text, t.pos)
+	}
+
+	opt := p.next()
+	switch opt.text {
+	case "==", "!=", "<", "<=", ">", ">=", "~", "!~":
+	default:
+		return nil, fmt.Errorf("expected comparison operator at position %d", opt.pos)
+	}
+
+	vt := p.next()
+	e := &condExpr{field: field, op: opt.text, value: vt.text}
+	switch vt.kind {
+	case tokenString:
+	case tokenNumber:
+		num, err := strconv.ParseFloat(vt.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at position %d", vt.text, vt.pos)
+		}
+		e.num, e.isNum = num, true
+	default:
+		return nil, fmt.Errorf("expected value at position %d", vt.pos)
+	}
+
+	if e.op == "~" || e.op == "!~" {
+		re, err := regexp.Compile(e.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regexp at position %d: %w", vt.pos, err)
+		}
+		e.regexp = re
+	}
+
+	return e, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcore"
+)
+
+func TestFilter(t *testing.T) {
+	p1 := &rep.Player{ID: 0, Name: "Flash", Team: 1, Race: repcore.RaceTerran, Type: repcore.PlayerTypeHuman}
+	p2 := &rep.Player{ID: 1, Name: "Jaedong", Team: 2, Race: repcore.RaceZerg, Type: repcore.PlayerTypeHuman}
+	r := &rep.Replay{
+		Header: &rep.Header{
+			Map:        "\x03Fighting Spirit",
+			Frames:     24000, // 16:48
+			Engine:     repcore.EngineBroodWar,
+			Type:       repcore.GameType1on1,
+			Players:    []*rep.Player{p1, p2},
+			PIDPlayers: map[byte]*rep.Player{p1.ID: p1, p2.ID: p2},
+		},
+	}
+	r.Compute()
+
+	cases := []struct {
+		filter string
+		match  bool
+	}{
+		{`matchup=="TvZ"`, true},
+		{`matchup != "TvZ"`, false},
+		{`matchup=="TvZ" && durationMin>10 && player~"Flash"`, true},
+		{`durationMin>20 || player=="Jaedong"`, true},
+		{`!(durationMin>=15) || map=="Fighting Spirit"`, true},
+		{`durationMin<15`, false},
+		{`player~"(?i)^jae"`, true},
+		{`player!~"Flash"`, false},
+		{`players==2 && race=="Zerg"`, true},
+		{`frames > -1 && durationSec == 1008`, true},
+	}
+	for _, c := range cases {
+		fe, err := parseFilter(c.filter)
+		if err != nil {
+			t.Errorf("[%s] Unexpected error: %v", c.filter, err)
+			continue
+		}
+		if match := fe.eval(r); match != c.match {
+			t.Errorf("[%s] Expected: %v, got: %v", c.filter, c.match, match)
+		}
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	filters := []string{
+		``,
+		`matchup`,
+		`foo=="x"`,
+		`matchup=="TvZ" &&`,
+		`(matchup=="TvZ"`,
+		`matchup=="TvZ")`,
+		`matchup=="TvZ`,
+		`matchup==TvZ`,
+		`player~"("`,
+		`matchup=="TvZ" # x`,
+	}
+	for _, f := range filters {
+		if _, err := parseFilter(f); err == nil {
+			t.Errorf("[%s] Expected error, got: nil", f)
+		}
+	}
+}
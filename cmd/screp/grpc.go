@@ -0,0 +1,41 @@
+// This file contains the grpc subcommand which serves the gRPC ReplayService.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+
+	"github.com/icza/screp/repgrpc"
+)
+
+// grpcCmd runs the grpc subcommand.
+func grpcCmd(args []string) {
+	fs := flag.NewFlagSet("grpc", flag.ExitOnError)
+	addr := fs.String("addr", "localhost:8081", "address to listen on")
+	fs.Usage = func() {
+		fmt.Println("Usage:")
+		fmt.Printf("\t%s grpc [FLAGS]\n", os.Args[0])
+		fmt.Println("\tStarts a gRPC server serving the ReplayService (Parse and ParseStream) defined in proto/screp.proto.")
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 0 {
+		fs.Usage()
+		os.Exit(ExitCodeMissingArguments)
+	}
+
+	lis, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Listening on %s", *addr)
+	if err := repgrpc.NewServer().Serve(lis); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,118 @@
+// This file contains the localization of the overview output.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// catalogs holds the message catalogs of the supported languages (besides English),
+// mapping from English messages (labels, race and game type names) to their translations.
+var catalogs = map[string]map[string]string{
+	"ko": {
+		// Labels
+		"File":    "파일",
+		"Error":   "오류",
+		"Engine":  "엔진",
+		"Date":    "날짜",
+		"Length":  "게임 시간",
+		"Title":   "제목",
+		"Map":     "맵",
+		"Type":    "게임 유형",
+		"Matchup": "매치업",
+		"Winner":  "승자",
+		"Team %d": "%d팀",
+		"Team":    "팀",
+		"R":       "종족",
+		"Name":    "이름",
+
+		// Races
+		"Zerg":    "저그",
+		"Terran":  "테란",
+		"Protoss": "프로토스",
+
+		// Game types
+		"Melee":                 "밀리",
+		"Free For All":          "개인전",
+		"One on One":            "1:1",
+		"Capture The Flag":      "깃발 뺏기",
+		"Greed":                 "탐욕",
+		"Slaughter":             "학살",
+		"Sudden Death":          "서든 데스",
+		"Ladder":                "래더",
+		"Use map settings":      "맵 설정 사용",
+		"Team Melee":            "팀 밀리",
+		"Team Free For All":     "팀 개인전",
+		"Team Capture The Flag": "팀 깃발 뺏기",
+		"Top vs Bottom":         "상대 진영전",
+	},
+}
+
+// validLangs lists the valid values of the lang flag.
+const validLangs = "valid values are 'en', 'ko'"
+
+// translator translates messages using a message catalog.
+// The zero value (nil) leaves messages in English.
+type translator map[string]string
+
+// newTranslator returns the translator of the given language.
+func newTranslator(lang string) (translator, error) {
+	if lang == "" || lang == "en" {
+		return nil, nil
+	}
+	catalog, ok := catalogs[lang]
+	if !ok {
+		return nil, fmt.Errorf("unsupported language: %q", lang)
+	}
+	return translator(catalog), nil
+}
+
+// T returns the translation of the message, or the message itself if it has no translation.
+func (tr translator) T(msg string) string {
+	if s, ok := tr[msg]; ok {
+		return s
+	}
+	return msg
+}
+
+// overviewLabels are the labels of the overview output.
+var overviewLabels = []string{"File", "Error", "Engine", "Date", "Length", "Title", "Map", "Type", "Matchup", "Winner"}
+
+// label returns the translated label padded to the width of the longest label, followed by a colon.
+func (tr translator) label(name string) string {
+	width := 0
+	for _, l := range overviewLabels {
+		width = max(width, displayWidth(tr.T(l)))
+	}
+	return padRight(tr.T(name), width+1) + ":"
+}
+
+// displayWidth returns the number of columns the text occupies in a terminal:
+// wide (east asian) characters take 2 columns.
+func displayWidth(s string) int {
+	width := 0
+	for _, r := range s {
+		width++
+		if unicode.In(r, unicode.Hangul, unicode.Han, unicode.Hiragana, unicode.Katakana) {
+			width++
+		}
+	}
+	return width
+}
+
+// padRight pads the text with spaces to the given display width.
+func padRight(s string, width int) string {
+	if w := displayWidth(s); w < width {
+		return s + strings.Repeat(" ", width-w)
+	}
+	return s
+}
+
+// firstRune returns the first character of the text.
+func firstRune(s string) string {
+	_, size := utf8.DecodeRuneInString(s)
+	return s[:size]
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcore"
+)
+
+func TestPrintOverview(t *testing.T) {
+	defer func() { overviewTr = nil }()
+
+	newReplay := func() *rep.Replay {
+		return &rep.Replay{
+			Header: &rep.Header{
+				Engine:    repcore.EngineBroodWar,
+				Frames:    repcore.Duration2Frame(10 * time.Minute),
+				StartTime: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+				Map:       "Fighting Spirit",
+				Type:      repcore.GameTypeMelee,
+				Players: []*rep.Player{
+					{ID: 0, Name: "Alice", Race: repcore.RaceTerran, Team: 1, Type: repcore.PlayerTypeHuman},
+					{ID: 1, Name: "Bob", Race: repcore.RaceZerg, Team: 2, Type: repcore.PlayerTypeHuman},
+				},
+			},
+			Commands: &rep.Commands{},
+			MapData:  &rep.MapData{},
+		}
+	}
+
+	cases := []struct {
+		lang  string
+		lines []string
+	}{
+		{"en", []string{
+			"Engine  : BW",
+			"Date    : 2020-01-02 03:04:05 +00:00",
+			"Type    : Melee",
+			"Team  R  APM EAPM   @  Name ",
+			"  1   T    0    0   0  Alice",
+		}},
+		{"ko", []string{
+			"엔진      : BW",
+			"날짜      : 2020-01-02 03:04:05 +00:00",
+			"게임 유형 : 밀리",
+			"팀    종족  APM EAPM   @  이름 ",
+			"  2   저      0    0   0  Bob",
+		}},
+	}
+
+	for _, c := range cases {
+		tr, err := newTranslator(c.lang)
+		if err != nil {
+			t.Fatalf("[%s] Unexpected error: %v", c.lang, err)
+		}
+		overviewTr = tr
+		buf := &bytes.Buffer{}
+		printOverview(buf, newReplay())
+		for _, line := range c.lines {
+			if !strings.Contains(buf.String(), line+"\n") {
+				t.Errorf("[%s] Expected: %q, got: %s", c.lang, line, buf)
+			}
+		}
+	}
+
+	if _, err := newTranslator("xx"); err == nil {
+		t.Errorf("Expected: %v, got: %v", "error", err)
+	}
+}
@@ -0,0 +1,59 @@
+// This file contains the index subcommand which maintains a replay library index.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/icza/screp/repindex"
+)
+
+// defaultIndexFile is the default index file of the index related subcommands.
+const defaultIndexFile = "screp-index.json"
+
+// loadIndex loads the index from the given file.
+func loadIndex(name string) *repindex.Index {
+	idx, err := repindex.Load(name)
+	if err != nil {
+		fmt.Printf("Failed to load index: %v\n", err)
+		os.Exit(ExitCodeFailedToParseReplay)
+	}
+	return idx
+}
+
+// index runs the index subcommand.
+func index(args []string) {
+	fs := flag.NewFlagSet("index", flag.ExitOnError)
+	indexFile := fs.String("index", defaultIndexFile, "index file to update")
+	recursive := fs.Bool("r", false, "search replays recursively in the subfolders of folder arguments")
+	fs.Usage = func() {
+		fmt.Println("Usage:")
+		fmt.Printf("\t%s index [FLAGS] replayfolder...\n", os.Args[0])
+		fmt.Println("\tAdds new and modified replays of the folders to the index, removes deleted ones.")
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(ExitCodeMissingArguments)
+	}
+
+	idx := loadIndex(*indexFile)
+	stats, err := idx.Update(fs.Args(), *recursive)
+	if err != nil {
+		fmt.Printf("Failed to update index: %v\n", err)
+		os.Exit(ExitCodeMissingArguments)
+	}
+	if err := idx.Save(*indexFile); err != nil {
+		fmt.Printf("Failed to save index: %v\n", err)
+		os.Exit(ExitCodeFailedToCreateOutputFile)
+	}
+
+	fmt.Printf("Added: %d, updated: %d, removed: %d, unchanged: %d, failed to parse: %d\n",
+		stats.Added, stats.Updated, stats.Removed, stats.Unchanged, stats.Failed)
+	fmt.Printf("Indexed files: %d, games: %d\n", len(idx.Files), len(idx.Games))
+}
@@ -0,0 +1,130 @@
+// This file contains the options of the JSON output.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/icza/screp/repjson"
+	"github.com/icza/screp/repmsgpack"
+)
+
+// Frame formats of the JSON output
+const (
+	frameFormatFrames  = "frames"
+	frameFormatSeconds = "seconds"
+	frameFormatClock   = "clock"
+)
+
+// Enum formats of the JSON output
+const (
+	enumFormatStruct = "struct"
+	enumFormatIDName = "idname"
+	enumFormatID     = "id"
+)
+
+const validJSONOptions = "valid frameformat values are 'frames', 'seconds', 'clock' (mm:ss);\n" +
+	"valid enumformat values are 'struct' (all fields), 'idname' (ID and Name only), 'id' (bare ID)"
+
+// Flag variables of the JSON output options, see addJSONOptionFlags()
+var (
+	frameFormat = frameFormatFrames
+	enumFormat  = enumFormatStruct
+	rawFields   bool
+	camelCase   bool
+	tileCoords  bool
+)
+
+// addJSONOptionFlags registers the flags of the JSON output options.
+func addJSONOptionFlags(fs *flag.FlagSet) {
+	fs.StringVar(&frameFormat, "frameformat", frameFormat, "format of frames (game times) in the JSON output;\n"+validJSONOptions)
+	fs.StringVar(&enumFormat, "enumformat", enumFormat, "format of enumerations (e.g. races, game types) in the JSON output;\n"+validJSONOptions)
+	fs.BoolVar(&rawFields, "raw", rawFields, "include the Raw* fields (undecoded texts) and Debug fields (raw section data) in the JSON output")
+	fs.BoolVar(&camelCase, "camelcase", camelCase, "use lowerCamelCase keys in the JSON output, e.g. 'playerID' instead of 'PlayerID'")
+	fs.BoolVar(&tileCoords, "tilecoords", tileCoords, "add the tile coordinates of points (TileX and TileY) next to their pixel coordinates in the JSON output")
+}
+
+// jsonOpts are the JSON output options built from the flags.
+var jsonOpts repjson.Options
+
+// parseJSONOptions parses the JSON output options.
+func parseJSONOptions(frameFormat, enumFormat string, raw, camelCase, tileCoords bool) (repjson.Options, error) {
+	opts := repjson.Options{Raw: raw, CamelCase: camelCase, TileCoords: tileCoords}
+
+	switch frameFormat {
+	case frameFormatFrames:
+		opts.Frames = repjson.FramesAsFrames
+	case frameFormatSeconds:
+		opts.Frames = repjson.FramesAsSeconds
+	case frameFormatClock:
+		opts.Frames = repjson.FramesAsClock
+	default:
+		return opts, fmt.Errorf("invalid frameformat: %q", frameFormat)
+	}
+
+	switch enumFormat {
+	case enumFormatStruct:
+		opts.Enums = repjson.EnumsAsStructs
+	case enumFormatIDName:
+		opts.Enums = repjson.EnumsAsIDName
+	case enumFormatID:
+		opts.Enums = repjson.EnumsAsID
+	default:
+		return opts, fmt.Errorf("invalid enumformat: %q", enumFormat)
+	}
+
+	return opts, nil
+}
+
+// outputKey returns the key of a field in the JSON output according to the JSON output options.
+func outputKey(name string) string {
+	if jsonOpts.CamelCase {
+		return repjson.LowerCamel(name)
+	}
+	return name
+}
+
+// marshalJSON returns the JSON encoding of v according to the JSON output options.
+func marshalJSON(v any) ([]byte, error) {
+	if jsonOpts == (repjson.Options{}) {
+		return json.Marshal(v)
+	}
+	return repjson.Marshal(v, jsonOpts)
+}
+
+// newJSONEncoder returns a JSON encoder writing to w according to the JSON output options.
+func newJSONEncoder(w io.Writer, indent bool) valueEncoder {
+	if jsonOpts == (repjson.Options{}) {
+		enc := json.NewEncoder(w)
+		if indent {
+			enc.SetIndent("", "  ")
+		}
+		return enc
+	}
+	enc := repjson.NewEncoder(w, jsonOpts)
+	if indent {
+		enc.SetIndent("", "  ")
+	}
+	return enc
+}
+
+// msgpackEncoder encodes values in MessagePack format according to the JSON output options.
+type msgpackEncoder struct {
+	w io.Writer
+}
+
+// Encode implements valueEncoder.
+func (enc msgpackEncoder) Encode(v any) error {
+	data, err := marshalJSON(v)
+	if err != nil {
+		return err
+	}
+	if data, err = repmsgpack.FromJSON(data); err != nil {
+		return err
+	}
+	_, err = enc.w.Write(data)
+	return err
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/icza/screp/repjson"
+)
+
+func TestParseJSONOptions(t *testing.T) {
+	cases := []struct {
+		frameFormat, enumFormat string
+		exp                     repjson.Options
+		valid                   bool
+	}{
+		{frameFormatFrames, enumFormatStruct, repjson.Options{}, true},
+		{frameFormatSeconds, enumFormatIDName, repjson.Options{Frames: repjson.FramesAsSeconds, Enums: repjson.EnumsAsIDName}, true},
+		{frameFormatClock, enumFormatID, repjson.Options{Frames: repjson.FramesAsClock, Enums: repjson.EnumsAsID}, true},
+		{"minutes", enumFormatStruct, repjson.Options{}, false},
+		{frameFormatFrames, "name", repjson.Options{}, false},
+	}
+
+	for _, c := range cases {
+		opts, err := parseJSONOptions(c.frameFormat, c.enumFormat, false, false, false)
+		if (err == nil) != c.valid || c.valid && opts != c.exp {
+			t.Errorf("[%s, %s] Expected: %v, %v, got: %v, %v", c.frameFormat, c.enumFormat, c.exp, c.valid, opts, err)
+		}
+	}
+}
+
+func TestOutputKey(t *testing.T) {
+	defer func() { jsonOpts = repjson.Options{} }()
+
+	if got := outputKey("SchemaVersion"); got != "SchemaVersion" {
+		t.Errorf("Expected: %v, got: %v", "SchemaVersion", got)
+	}
+	jsonOpts.CamelCase = true
+	if got := outputKey("SchemaVersion"); got != "schemaVersion" {
+		t.Errorf("Expected: %v, got: %v", "schemaVersion", got)
+	}
+}
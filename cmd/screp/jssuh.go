@@ -0,0 +1,165 @@
+// This file contains the jssuh command listing format.
+//
+// The format follows the naming and structure of the actions of the jssuh replay parser
+// (https://github.com/neivv/jssuh), so the outputs of the 2 parsers can be cross-validated:
+// 1 JSON object per line, having the frame, player and action fields, followed by the
+// fields of the action in snake_case. Unit types, orders, techs and upgrades are given by their IDs.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/icza/screp/rep/repcmd"
+)
+
+// jssuhActionNames holds the action names differing from the command type names (with spaces removed).
+var jssuhActionNames = map[byte]string{
+	repcmd.TypeIDSelectAdd:       "SelectionAdd",
+	repcmd.TypeIDSelectAdd121:    "SelectionAdd",
+	repcmd.TypeIDSelectRemove:    "SelectionRemove",
+	repcmd.TypeIDSelectRemove121: "SelectionRemove",
+	repcmd.TypeIDAlliance:        "Ally",
+	repcmd.TypeIDCloack:          "Cloak",
+	repcmd.TypeIDDecloack:        "Decloak",
+	repcmd.TypeIDLiftOff:         "Lift",
+	repcmd.TypeIDTech:            "Research",
+	repcmd.TypeIDCancelTech:      "CancelResearch",
+}
+
+// jssuhAction returns the action name of a command type.
+func jssuhAction(t *repcmd.Type) string {
+	if name, ok := jssuhActionNames[t.ID]; ok {
+		return name
+	}
+	name := strings.TrimPrefix(t.Name, "[Lobby] ")
+	return strings.ReplaceAll(name, " ", "")
+}
+
+// jssuhLine returns the JSON line of a command (without the line terminator).
+// If file is not empty, it is included as the first field.
+func jssuhLine(cmd repcmd.Cmd, file string) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	var err error
+	add := func(key string, v any) {
+		if err != nil {
+			return
+		}
+		if buf.Len() == 0 {
+			buf.WriteByte('{')
+		} else {
+			buf.WriteByte(',')
+		}
+		fmt.Fprintf(buf, "%q:", key)
+		if err = enc.Encode(v); err == nil {
+			buf.Truncate(buf.Len() - 1) // Encode() appends a newline
+		}
+	}
+	unitID := func(u *repcmd.Unit) uint16 {
+		if u == nil {
+			return bwapiUnitTypeNone
+		}
+		return u.ID
+	}
+
+	b := cmd.BaseCmd()
+	if file != "" {
+		add("file", file)
+	}
+	add("frame", b.Frame)
+	add("player", b.PlayerID)
+	add("action", jssuhAction(b.Type))
+
+	switch x := cmd.(type) {
+	case *repcmd.SelectCmd:
+		units := make([]uint16, len(x.UnitTags))
+		for i, tag := range x.UnitTags {
+			units[i] = uint16(tag)
+		}
+		add("units", units)
+	case *repcmd.BuildCmd:
+		add("order", x.Order.ID)
+		add("x", x.Pos.X)
+		add("y", x.Pos.Y)
+		add("unit_id", unitID(x.Unit))
+	case *repcmd.LandCmd:
+		add("order", x.Order.ID)
+		add("x", x.Pos.X)
+		add("y", x.Pos.Y)
+		add("unit_id", unitID(x.Unit))
+	case *repcmd.GameSpeedCmd:
+		add("speed", x.Speed.ID)
+	case *repcmd.HotkeyCmd:
+		add("hotkey_type", x.HotkeyType.ID)
+		add("group", x.Group)
+	case *repcmd.LeaveGameCmd:
+		add("reason", x.Reason.ID)
+	case *repcmd.TrainCmd:
+		add("unit_id", unitID(x.Unit))
+	case *repcmd.BuildingMorphCmd:
+		add("unit_id", unitID(x.Unit))
+	case *repcmd.QueueableCmd:
+		add("queued", x.Queued)
+	case *repcmd.RightClickCmd:
+		add("x", x.Pos.X)
+		add("y", x.Pos.Y)
+		add("target", x.UnitTag)
+		add("unit_id", unitID(x.Unit))
+		add("queued", x.Queued)
+	case *repcmd.TargetedOrderCmd:
+		add("x", x.Pos.X)
+		add("y", x.Pos.Y)
+		add("target", x.UnitTag)
+		add("unit_id", unitID(x.Unit))
+		add("order", x.Order.ID)
+		add("queued", x.Queued)
+	case *repcmd.UnloadCmd:
+		add("unit", x.UnitTag)
+	case *repcmd.CancelTrainCmd:
+		add("unit", x.UnitTag)
+	case *repcmd.MinimapPingCmd:
+		add("x", x.Pos.X)
+		add("y", x.Pos.Y)
+	case *repcmd.LiftOffCmd:
+		add("x", x.Pos.X)
+		add("y", x.Pos.Y)
+	case *repcmd.ChatCmd:
+		add("sender", x.SenderSlotID)
+		add("message", x.Message)
+	case *repcmd.VisionCmd:
+		add("slots", jssuhSlots(x.SlotIDs))
+	case *repcmd.AllianceCmd:
+		add("slots", jssuhSlots(x.SlotIDs))
+		add("allied_victory", x.AlliedVictory)
+	case *repcmd.TechCmd:
+		add("tech", x.Tech.ID)
+	case *repcmd.UpgradeCmd:
+		add("upgrade", x.Upgrade.ID)
+	case *repcmd.LatencyCmd:
+		add("latency", x.Latency.ID)
+	case *repcmd.GeneralCmd:
+		if len(x.Data) > 0 {
+			add("data", fmt.Sprintf("%x", x.Data))
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// jssuhSlots returns the slot IDs as numbers (byte slices would be marshaled as base64 strings).
+func jssuhSlots(slotIDs repcmd.Bytes) []int {
+	slots := make([]int, len(slotIDs))
+	for i, id := range slotIDs {
+		slots[i] = int(id)
+	}
+	return slots
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/icza/screp/rep/repcmd"
+	"github.com/icza/screp/rep/repcore"
+)
+
+func TestJSSUHLine(t *testing.T) {
+	base := func(typeID byte) *repcmd.Base {
+		return &repcmd.Base{Frame: 10, PlayerID: 1, Type: repcmd.TypeByID(typeID)}
+	}
+	cases := []struct {
+		name string
+		cmd  repcmd.Cmd
+		exp  string
+	}{
+		{"select add", &repcmd.SelectCmd{Base: base(repcmd.TypeIDSelectAdd121), UnitTags: []repcmd.UnitTag{1, 2}},
+			`{"frame":10,"player":1,"action":"SelectionAdd","units":[1,2]}`},
+		{"targeted order", &repcmd.TargetedOrderCmd{Base: base(repcmd.TypeIDTargetedOrder), Pos: repcore.Point{X: 3, Y: 4}, UnitTag: 5, Order: repcmd.OrderByID(0x0a), Queued: true},
+			`{"frame":10,"player":1,"action":"TargetedOrder","x":3,"y":4,"target":5,"unit_id":228,"order":10,"queued":true}`},
+		{"vision", &repcmd.VisionCmd{Base: base(repcmd.TypeIDVision), SlotIDs: repcmd.Bytes{0, 2}},
+			`{"frame":10,"player":1,"action":"Vision","slots":[0,2]}`},
+		{"research", &repcmd.TechCmd{Base: base(repcmd.TypeIDTech), Tech: repcmd.TechByID(0)},
+			`{"frame":10,"player":1,"action":"Research","tech":0}`},
+		{"chat escaping", &repcmd.ChatCmd{Base: base(repcmd.TypeIDChat), Message: `"<gg>"`},
+			`{"frame":10,"player":1,"action":"Chat","sender":0,"message":"\"<gg>\""}`},
+	}
+
+	for _, c := range cases {
+		got, err := jssuhLine(c.cmd, "")
+		if err != nil {
+			t.Errorf("[%s] Unexpected error: %v", c.name, err)
+			continue
+		}
+		if string(got) != c.exp {
+			t.Errorf("[%s] Expected: %s, got: %s", c.name, c.exp, got)
+		}
+	}
+}
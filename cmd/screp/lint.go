@@ -0,0 +1,91 @@
+// This file contains the lint subcommand which validates replays.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/icza/screp/repparser"
+)
+
+// lintReport is the validation report of a replay.
+type lintReport struct {
+	File string
+
+	// Valid tells if the replay passed validation
+	Valid bool
+
+	Issues []*repparser.Issue
+}
+
+// lintReplay validates the replay data.
+// Warnings make the replay invalid only if strict is true.
+func lintReplay(file string, repData []byte, strict bool) *lintReport {
+	lr := &lintReport{File: file, Valid: true, Issues: repparser.Validate(repData)}
+	for _, issue := range lr.Issues {
+		if strict || issue.Severity == repparser.SeverityError {
+			lr.Valid = false
+		}
+	}
+	if lr.Issues == nil {
+		lr.Issues = []*repparser.Issue{}
+	}
+	return lr
+}
+
+// lint runs the lint subcommand.
+func lint(args []string) {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	strict := fs.Bool("strict", false, "treat warnings as errors")
+	recursive := fs.Bool("r", false, "search replays recursively in the subfolders of folder arguments")
+	fs.Usage = func() {
+		fmt.Println("Usage:")
+		fmt.Printf("\t%s lint [FLAGS] repfile.rep \"replays/*.rep\" replayfolder...\n", os.Args[0])
+		fmt.Println("Prints a JSON array of reports, exits with code", ExitCodeInvalidReplay, "if any replay is invalid.")
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(ExitCodeMissingArguments)
+	}
+
+	files := []string{"-"}
+	if fs.NArg() != 1 || fs.Arg(0) != "-" {
+		var err error
+		if files, _, err = replayFiles(fs.Args(), *recursive); err != nil {
+			fmt.Printf("Failed to list replay files: %v\n", err)
+			os.Exit(ExitCodeMissingArguments)
+		}
+	}
+
+	reports := []*lintReport{}
+	valid := true
+	for _, file := range files {
+		var lr *lintReport
+		if repData, err := readReplayArg(file); err != nil {
+			lr = &lintReport{File: file, Issues: []*repparser.Issue{{
+				Severity: repparser.SeverityError, Section: "File", Message: err.Error(),
+			}}}
+		} else {
+			lr = lintReplay(file, repData, *strict)
+		}
+		valid = valid && lr.Valid
+		reports = append(reports, lr)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(reports); err != nil {
+		fmt.Printf("Failed to encode output: %v\n", err)
+	}
+
+	if !valid {
+		os.Exit(ExitCodeInvalidReplay)
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestLintReplay(t *testing.T) {
+	cases := []struct {
+		name    string
+		repData []byte
+		strict  bool
+		valid   bool
+	}{
+		{"empty", nil, false, false},
+		{"not a replay", []byte("not a replay, just some text of at least 30 bytes"), true, false},
+	}
+
+	for _, c := range cases {
+		lr := lintReplay(c.name, c.repData, c.strict)
+		if lr.Valid != c.valid || len(lr.Issues) == 0 {
+			t.Errorf("[%s] Expected: %v, got: %v (issues: %v)", c.name, c.valid, lr.Valid, lr.Issues)
+		}
+	}
+}
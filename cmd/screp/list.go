@@ -0,0 +1,167 @@
+// This file contains the list subcommand which prints a compact line per game, sorted and grouped.
+
+package main
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/icza/screp/repindex"
+)
+
+// List sort orders
+const (
+	listSortDate     = "date"
+	listSortDuration = "duration"
+	listSortAPM      = "apm"
+)
+
+const validListSorts = "valid values are 'date', 'duration', 'apm' (average APM of the players)"
+
+// List groupings
+const (
+	listGroupMap     = "map"
+	listGroupMatchup = "matchup"
+	listGroupPlayer  = "player"
+)
+
+const validListGroups = "valid values are 'map', 'matchup', 'player'"
+
+// gameGroup is a group of games.
+type gameGroup struct {
+	Name  string
+	Games []*repindex.Game
+}
+
+// avgAPM returns the average APM of the (non-observer) players of a game.
+func avgAPM(g *repindex.Game) int32 {
+	var sum, count int32
+	for _, p := range g.Players {
+		if !p.Observer {
+			sum += p.APM
+			count++
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+	return sum / count
+}
+
+// sortGames sorts the games by the given order.
+func sortGames(games []*repindex.Game, sortBy string, desc bool) {
+	slices.SortStableFunc(games, func(a, b *repindex.Game) int {
+		var c int
+		switch sortBy {
+		case listSortDuration:
+			c = cmp.Compare(a.Frames, b.Frames)
+		case listSortAPM:
+			c = cmp.Compare(avgAPM(a), avgAPM(b))
+		default:
+			c = a.StartTime.Compare(b.StartTime)
+		}
+		if desc {
+			c = -c
+		}
+		return c
+	})
+}
+
+// groupGames groups the games by the given grouping, groups are sorted by name.
+// Games of multiple players are listed in the groups of all their (non-observer) players.
+func groupGames(games []*repindex.Game, groupBy string) []*gameGroup {
+	m := map[string]*gameGroup{}
+	var groups []*gameGroup
+	add := func(name string, g *repindex.Game) {
+		gg := m[name]
+		if gg == nil {
+			gg = &gameGroup{Name: name}
+			m[name] = gg
+			groups = append(groups, gg)
+		}
+		gg.Games = append(gg.Games, g)
+	}
+
+	for _, g := range games {
+		switch groupBy {
+		case listGroupMap:
+			add(g.Map, g)
+		case listGroupMatchup:
+			add(g.Matchup, g)
+		case listGroupPlayer:
+			for _, p := range g.Players {
+				if !p.Observer {
+					add(p.Name, g)
+				}
+			}
+		}
+	}
+
+	slices.SortFunc(groups, func(a, b *gameGroup) int { return cmp.Compare(a.Name, b.Name) })
+	return groups
+}
+
+// writeList writes the games as compact lines, in groups if groupBy is not empty.
+func writeList(w io.Writer, games []*repindex.Game, groupBy string) {
+	if groupBy == "" {
+		for _, g := range games {
+			writeGameLine(w, g)
+		}
+		return
+	}
+
+	for i, gg := range groupGames(games, groupBy) {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "== %s (%d) ==\n", gg.Name, len(gg.Games))
+		for _, g := range gg.Games {
+			writeGameLine(w, g)
+		}
+	}
+}
+
+// list runs the list subcommand.
+func list(args []string) {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	recursive := fs.Bool("r", false, "search replays recursively in the subfolders of folder arguments")
+	indexFile := fs.String("index", "", "optional index file to use (and update), see the index subcommand")
+	sortBy := fs.String("sort", listSortDate, "order of the games;\n"+validListSorts)
+	desc := fs.Bool("desc", false, "sort in descending order")
+	groupBy := fs.String("groupby", "", "optional grouping of the games;\n"+validListGroups)
+	fs.Usage = func() {
+		fmt.Println("Usage:")
+		fmt.Printf("\t%s list [FLAGS] replayfolder...\n", os.Args[0])
+		fmt.Println("\tPrints a compact line per game (winners are marked with '*'). Replays of the same game are listed once.")
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(ExitCodeMissingArguments)
+	}
+	switch *sortBy {
+	case listSortDate, listSortDuration, listSortAPM:
+	default:
+		fmt.Printf("Invalid sort: %v\n", *sortBy)
+		fmt.Println(validListSorts)
+		os.Exit(ExitCodeInvalidFormat)
+	}
+	switch *groupBy {
+	case "", listGroupMap, listGroupMatchup, listGroupPlayer:
+	default:
+		fmt.Printf("Invalid groupby: %v\n", *groupBy)
+		fmt.Println(validListGroups)
+		os.Exit(ExitCodeInvalidFormat)
+	}
+
+	games := corpusGames(fs.Args(), *recursive, *indexFile)
+	sortGames(games, *sortBy, *desc)
+	writeList(os.Stdout, games, *groupBy)
+}
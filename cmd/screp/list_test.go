@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/icza/screp/repindex"
+)
+
+func TestSortAndGroupGames(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2023, 1, d, 0, 0, 0, 0, time.UTC) }
+	g1 := &repindex.Game{Files: []string{"a.rep"}, StartTime: day(3), Frames: 100, Map: "Fighting Spirit", Matchup: "TvZ",
+		Players: []*repindex.Player{{Name: "Flash", APM: 300}, {Name: "Jaedong", Team: 1, APM: 400}}}
+	g2 := &repindex.Game{Files: []string{"b.rep"}, StartTime: day(1), Frames: 300, Map: "Circuit Breaker", Matchup: "PvT",
+		Players: []*repindex.Player{{Name: "Bisu", APM: 100}, {Name: "Flash", Team: 1, APM: 200}}}
+	g3 := &repindex.Game{Files: []string{"c.rep"}, StartTime: day(2), Frames: 200, Map: "Fighting Spirit", Matchup: "PvT",
+		Players: []*repindex.Player{{Name: "Bisu", APM: 250}, {Name: "Obs", Observer: true, APM: 1000}}}
+
+	files := func(games []*repindex.Game) (s string) {
+		for _, g := range games {
+			s += g.Files[0][:1]
+		}
+		return
+	}
+
+	cases := []struct {
+		sortBy string
+		desc   bool
+		exp    string
+	}{
+		{listSortDate, false, "bca"},
+		{listSortDate, true, "acb"},
+		{listSortDuration, false, "acb"},
+		{listSortAPM, true, "acb"},
+	}
+	for _, c := range cases {
+		games := []*repindex.Game{g1, g2, g3}
+		sortGames(games, c.sortBy, c.desc)
+		if got := files(games); got != c.exp {
+			t.Errorf("[%s, %v] Expected: %v, got: %v", c.sortBy, c.desc, c.exp, got)
+		}
+	}
+
+	groupCases := []struct {
+		groupBy string
+		exp     string
+	}{
+		{listGroupMap, "Circuit Breaker:b|Fighting Spirit:ac|"},
+		{listGroupMatchup, "PvT:bc|TvZ:a|"},
+		{listGroupPlayer, "Bisu:bc|Flash:ab|Jaedong:a|"},
+	}
+	for _, c := range groupCases {
+		got := ""
+		for _, gg := range groupGames([]*repindex.Game{g1, g2, g3}, c.groupBy) {
+			got += gg.Name + ":" + files(gg.Games) + "|"
+		}
+		if got != c.exp {
+			t.Errorf("[%s] Expected: %v, got: %v", c.groupBy, c.exp, got)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	writeList(buf, []*repindex.Game{g1, g2, g3}, listGroupMatchup)
+	if exp := "== PvT (2) ==\n"; !strings.HasPrefix(buf.String(), exp) {
+		t.Errorf("Expected: %v, got: %v", exp, buf)
+	}
+}
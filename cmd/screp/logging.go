@@ -0,0 +1,129 @@
+// This file contains the logging of the parser's warnings.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// Log formats
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+const validLogFormats = "valid values are 'text', 'json'"
+
+// Flag variables of the logging, see addLogFlags()
+var (
+	quiet     bool
+	verbose   bool
+	logFormat = logFormatText
+)
+
+// addLogFlags registers the flags of the logging of the parser's warnings.
+func addLogFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&quiet, "quiet", quiet, "do not log the parser's warnings (to standard error)")
+	fs.BoolVar(&verbose, "v", verbose, "log the parser's debug records too (e.g. processed sections)")
+	fs.StringVar(&logFormat, "logformat", logFormat, "format of the log records;\n"+validLogFormats)
+}
+
+// levelQuiet is a log level above all used levels, disabling all records.
+const levelQuiet = slog.Level(100)
+
+// newLogHandler returns the log handler writing to w in the given format.
+// Warnings and errors are logged by default, nothing if quiet, and debug records too if verbose.
+func newLogHandler(w io.Writer, format string, quiet, verbose bool) (slog.Handler, error) {
+	opts := &slog.HandlerOptions{Level: slog.LevelWarn}
+	switch {
+	case quiet:
+		opts.Level = levelQuiet
+	case verbose:
+		opts.Level = slog.LevelDebug
+	}
+
+	switch format {
+	case logFormatText:
+		return slog.NewTextHandler(w, opts), nil
+	case logFormatJSON:
+		return slog.NewJSONHandler(w, opts), nil
+	}
+	return nil, fmt.Errorf("invalid log format: %q", format)
+}
+
+// warningCollector is a log handler which collects the warnings (and errors) logged,
+// and passes the records on to another handler.
+// Warnings are collected even if the other handler discards them.
+type warningCollector struct {
+	slog.Handler
+
+	attrs []slog.Attr // Attributes added by WithAttrs()
+
+	mu       *sync.Mutex
+	warnings *[]string
+}
+
+// newWarningCollector creates a new warningCollector passing records on to h.
+func newWarningCollector(h slog.Handler) *warningCollector {
+	return &warningCollector{Handler: h, mu: &sync.Mutex{}, warnings: new([]string)}
+}
+
+// Enabled implements slog.Handler.
+func (wc *warningCollector) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= slog.LevelWarn || wc.Handler.Enabled(ctx, level)
+}
+
+// Handle implements slog.Handler.
+func (wc *warningCollector) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level >= slog.LevelWarn {
+		sb := &strings.Builder{}
+		sb.WriteString(r.Message)
+		addAttr := func(a slog.Attr) bool {
+			if a.Key != "stack" { // Stack traces are not part of the warning
+				fmt.Fprintf(sb, " %s=%v", a.Key, a.Value)
+			}
+			return true
+		}
+		for _, a := range wc.attrs {
+			addAttr(a)
+		}
+		r.Attrs(addAttr)
+
+		wc.mu.Lock()
+		*wc.warnings = append(*wc.warnings, sb.String())
+		wc.mu.Unlock()
+	}
+
+	if !wc.Handler.Enabled(ctx, r.Level) {
+		return nil
+	}
+	return wc.Handler.Handle(ctx, r)
+}
+
+// WithAttrs implements slog.Handler.
+func (wc *warningCollector) WithAttrs(attrs []slog.Attr) slog.Handler {
+	wc2 := *wc
+	wc2.Handler = wc.Handler.WithAttrs(attrs)
+	wc2.attrs = append(wc.attrs[:len(wc.attrs):len(wc.attrs)], attrs...)
+	return &wc2
+}
+
+// WithGroup implements slog.Handler.
+func (wc *warningCollector) WithGroup(name string) slog.Handler {
+	wc2 := *wc
+	wc2.Handler = wc.Handler.WithGroup(name)
+	return &wc2
+}
+
+// Warnings returns the collected warnings.
+func (wc *warningCollector) Warnings() []string {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+	return *wc.warnings
+}
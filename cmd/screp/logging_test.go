@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestLogHandler(t *testing.T) {
+	cases := []struct {
+		name           string
+		format         string
+		quiet, verbose bool
+		lines          int // Number of lines logged
+	}{
+		{"default", logFormatText, false, false, 2},
+		{"quiet", logFormatText, true, false, 0},
+		{"verbose", logFormatText, false, true, 3},
+		{"json", logFormatJSON, false, false, 2},
+	}
+
+	for _, c := range cases {
+		buf := &bytes.Buffer{}
+		h, err := newLogHandler(buf, c.format, c.quiet, c.verbose)
+		if err != nil {
+			t.Fatalf("[%s] Unexpected error: %v", c.name, err)
+		}
+		wc := newWarningCollector(h)
+		logger := slog.New(wc).With("file", "a.rep")
+		logger.Debug("Section processed", "id", 1)
+		logger.Warn("Unknown modern section", "id", "abcd")
+		logger.Error("Parsing error", "error", "x", "stack", "...")
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if buf.Len() == 0 {
+			lines = nil
+		}
+		if len(lines) != c.lines {
+			t.Errorf("[%s] Expected: %v, got: %v", c.name, c.lines, len(lines))
+		}
+		if c.format == logFormatJSON {
+			for _, line := range lines {
+				if !json.Valid([]byte(line)) {
+					t.Errorf("[%s] Expected: %v, got: %v", c.name, "valid JSON", line)
+				}
+			}
+		}
+
+		exp := []string{"Unknown modern section file=a.rep id=abcd", "Parsing error file=a.rep error=x"}
+		if got := wc.Warnings(); strings.Join(got, "|") != strings.Join(exp, "|") {
+			t.Errorf("[%s] Expected: %v, got: %v", c.name, exp, got)
+		}
+	}
+
+	if _, err := newLogHandler(nil, "xml", false, false); err == nil {
+		t.Errorf("Expected: %v, got: %v", "error", err)
+	}
+}
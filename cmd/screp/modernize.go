@@ -0,0 +1,56 @@
+// This file contains the modernize subcommand which converts legacy replays to the modern format.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/icza/screp/repparser"
+)
+
+// modernize runs the modernize subcommand.
+func modernize(args []string) {
+	fs := flag.NewFlagSet("modernize", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Println("Usage:")
+		fmt.Printf("\t%s modernize repfile.rep output.rep\n", os.Args[0])
+		fmt.Println("Converts a legacy (pre 1.18) replay into the modern replay format, retaining all data.")
+		fmt.Println("Header strings stored in a legacy encoding are converted to UTF-8.")
+		fmt.Println("Modern replays are written unchanged.")
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(ExitCodeMissingArguments)
+	}
+
+	repData, err := readReplayArg(fs.Arg(0))
+	if err != nil {
+		fmt.Printf("Failed to read replay: %v\n", err)
+		os.Exit(ExitCodeFailedToParseReplay)
+	}
+
+	modern, err := repparser.ToModern(repData)
+	if err == nil {
+		// Verify the result is a valid replay:
+		_, err = repparser.Parse(modern)
+	}
+	if err != nil {
+		fmt.Printf("Failed to convert replay: %v\n", err)
+		os.Exit(ExitCodeFailedToParseReplay)
+	}
+	if bytes.Equal(modern, repData) {
+		fmt.Println("Replay is already in the modern format.")
+	}
+
+	if err := os.WriteFile(fs.Arg(1), modern, 0644); err != nil {
+		fmt.Printf("Failed to write output file: %v\n", err)
+		os.Exit(ExitCodeFailedToCreateOutputFile)
+	}
+}
@@ -0,0 +1,351 @@
+// This file contains the Parquet output format.
+//
+// The writer implements the minimal subset of the format needed for flat tables
+// (https://github.com/apache/parquet-format): a single row group, a single
+// uncompressed, PLAIN encoded data page per column, required columns only.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/icza/screp/rep"
+)
+
+// parquetColumn is a column of a Parquet table, either of strings or of int64 values.
+type parquetColumn struct {
+	name  string
+	isInt bool
+	strs  []string
+	ints  []int64
+}
+
+// Parquet physical and converted types, encodings and other enum values used.
+const (
+	parquetTypeInt64     = 2
+	parquetTypeByteArray = 6
+
+	parquetConvertedUTF8 = 0
+	parquetRequired      = 0
+	parquetPageData      = 0
+	parquetEncodingPlain = 0
+	parquetEncodingRLE   = 3
+	parquetCodecNone     = 0
+)
+
+// parquetMagic is the magic at the start and at the end of Parquet files.
+const parquetMagic = "PAR1"
+
+// writeParquet writes the table of the given columns in Parquet format.
+// All columns must have the same number of values.
+func writeParquet(w io.Writer, cols []*parquetColumn) error {
+	var rows int
+	if len(cols) > 0 {
+		rows = max(len(cols[0].strs), len(cols[0].ints))
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteString(parquetMagic)
+
+	// Column chunks: page header and PLAIN encoded values.
+	offsets := make([]int64, len(cols))
+	sizes := make([]int64, len(cols))
+	for i, c := range cols {
+		var values []byte
+		if c.isInt {
+			for _, v := range c.ints {
+				values = binary.LittleEndian.AppendUint64(values, uint64(v))
+			}
+		} else {
+			for _, s := range c.strs {
+				values = binary.LittleEndian.AppendUint32(values, uint32(len(s)))
+				values = append(values, s...)
+			}
+		}
+
+		ph := &thriftWriter{}
+		ph.i32(1, parquetPageData)
+		ph.i32(2, int32(len(values))) // Uncompressed size
+		ph.i32(3, int32(len(values))) // Compressed size
+		ph.structBegin(5)             // DataPageHeader
+		ph.i32(1, int32(rows))
+		ph.i32(2, parquetEncodingPlain)
+		ph.i32(3, parquetEncodingRLE) // Definition levels (there are none as all columns are required)
+		ph.i32(4, parquetEncodingRLE) // Repetition levels
+		ph.structEnd()
+		ph.stop()
+
+		offsets[i] = int64(buf.Len())
+		sizes[i] = int64(len(ph.buf) + len(values))
+		buf.Write(ph.buf)
+		buf.Write(values)
+	}
+
+	// File metadata
+	fm := &thriftWriter{}
+	fm.i32(1, 1) // Version
+	fm.listBegin(2, thriftStruct, len(cols)+1)
+	fm.elemBegin() // Root of the schema
+	fm.binary(4, "schema")
+	fm.i32(5, int32(len(cols)))
+	fm.structEnd()
+	for _, c := range cols {
+		fm.elemBegin()
+		if c.isInt {
+			fm.i32(1, parquetTypeInt64)
+		} else {
+			fm.i32(1, parquetTypeByteArray)
+		}
+		fm.i32(3, parquetRequired)
+		fm.binary(4, c.name)
+		if !c.isInt {
+			fm.i32(6, parquetConvertedUTF8)
+		}
+		fm.structEnd()
+	}
+	fm.i64(3, int64(rows))
+	fm.listBegin(4, thriftStruct, 1) // Row groups
+	fm.elemBegin()
+	fm.listBegin(1, thriftStruct, len(cols))
+	var totalSize int64
+	for i, c := range cols {
+		fm.elemBegin()
+		fm.i64(2, offsets[i])
+		fm.structBegin(3) // ColumnMetaData
+		if c.isInt {
+			fm.i32(1, parquetTypeInt64)
+		} else {
+			fm.i32(1, parquetTypeByteArray)
+		}
+		fm.listBegin(2, thriftI32, 1)
+		fm.varint(parquetEncodingPlain)
+		fm.listBegin(3, thriftBinary, 1)
+		fm.str(c.name)
+		fm.i32(4, parquetCodecNone)
+		fm.i64(5, int64(rows))
+		fm.i64(6, sizes[i])
+		fm.i64(7, sizes[i])
+		fm.i64(9, offsets[i])
+		fm.structEnd()
+		fm.structEnd()
+		totalSize += sizes[i]
+	}
+	fm.i64(2, totalSize)
+	fm.i64(3, int64(rows))
+	fm.structEnd()
+	fm.binary(6, appName+" "+appVersion) // Created by
+	fm.stop()
+
+	buf.Write(fm.buf)
+	buf.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(fm.buf))))
+	buf.WriteString(parquetMagic)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Thrift compact protocol types used.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes structs using the Thrift compact protocol (Parquet metadata is Thrift encoded).
+type thriftWriter struct {
+	buf []byte
+
+	// lastID is the last written field ID of the current struct,
+	// lastIDs holds the last field IDs of the enclosing structs.
+	lastID  int16
+	lastIDs []int16
+}
+
+// fieldHeader writes a field header, using the short form (delta encoded ID) if possible.
+func (tw *thriftWriter) fieldHeader(id int16, typ byte) {
+	if delta := id - tw.lastID; delta > 0 && delta <= 15 {
+		tw.buf = append(tw.buf, byte(delta)<<4|typ)
+	} else {
+		tw.buf = append(tw.buf, typ)
+		tw.varint(int64(id))
+	}
+	tw.lastID = id
+}
+
+// varint writes a zigzag encoded varint.
+func (tw *thriftWriter) varint(v int64) {
+	tw.buf = binary.AppendUvarint(tw.buf, uint64(v<<1^v>>63))
+}
+
+// str writes a string (without field header).
+func (tw *thriftWriter) str(s string) {
+	tw.buf = binary.AppendUvarint(tw.buf, uint64(len(s)))
+	tw.buf = append(tw.buf, s...)
+}
+
+func (tw *thriftWriter) i32(id int16, v int32) {
+	tw.fieldHeader(id, thriftI32)
+	tw.varint(int64(v))
+}
+
+func (tw *thriftWriter) i64(id int16, v int64) {
+	tw.fieldHeader(id, thriftI64)
+	tw.varint(v)
+}
+
+func (tw *thriftWriter) binary(id int16, s string) {
+	tw.fieldHeader(id, thriftBinary)
+	tw.str(s)
+}
+
+// listBegin writes the header of a list field, the elements must follow.
+func (tw *thriftWriter) listBegin(id int16, elemType byte, size int) {
+	tw.fieldHeader(id, thriftList)
+	if size < 15 {
+		tw.buf = append(tw.buf, byte(size)<<4|elemType)
+	} else {
+		tw.buf = append(tw.buf, 0xf0|elemType)
+		tw.buf = binary.AppendUvarint(tw.buf, uint64(size))
+	}
+}
+
+// structBegin writes the header of a struct field, the struct must be closed with structEnd().
+func (tw *thriftWriter) structBegin(id int16) {
+	tw.fieldHeader(id, thriftStruct)
+	tw.elemBegin()
+}
+
+// elemBegin begins a struct which is a list element, it must be closed with structEnd().
+func (tw *thriftWriter) elemBegin() {
+	tw.lastIDs = append(tw.lastIDs, tw.lastID)
+	tw.lastID = 0
+}
+
+// structEnd closes a struct.
+func (tw *thriftWriter) structEnd() {
+	tw.stop()
+	tw.lastID, tw.lastIDs = tw.lastIDs[len(tw.lastIDs)-1], tw.lastIDs[:len(tw.lastIDs)-1]
+}
+
+// stop writes the stop field marking the end of a struct.
+func (tw *thriftWriter) stop() {
+	tw.buf = append(tw.buf, 0)
+}
+
+// parquetOutput collects 1 row per replay, and optionally 1 row per command,
+// and writes them in Parquet format when flushed.
+type parquetOutput struct {
+	w    io.Writer
+	cols []*parquetColumn
+
+	// cmdsFile is the name of the file to write the commands table to, optional
+	cmdsFile string
+	cmdCols  []*parquetColumn
+}
+
+// parquetIntColumns lists the columns of int64 type, the rest are strings.
+var parquetIntColumns = map[string]bool{
+	"frames":    true,
+	"frame":     true,
+	"player_id": true,
+}
+
+// parquetCmdColumns lists the columns of the commands table.
+var parquetCmdColumns = []string{"file", "frame", "player_id", "player", "type", "params"}
+
+// newParquetOutput creates a new parquetOutput with the given comma separated column list
+// (same as for the CSV format).
+// If cmdsFile is not empty, the commands table is written to it.
+func newParquetOutput(w io.Writer, cols, cmdsFile string) (*parquetOutput, error) {
+	po := &parquetOutput{w: w, cmdsFile: cmdsFile}
+	for _, col := range strings.Split(cols, ",") {
+		col = strings.ToLower(strings.TrimSpace(col))
+		if _, ok := csvColumns[col]; !ok && col != "file" && col != "error" {
+			return nil, fmt.Errorf("invalid column: %q", col)
+		}
+		po.cols = append(po.cols, &parquetColumn{name: col, isInt: parquetIntColumns[col]})
+	}
+	for _, col := range parquetCmdColumns {
+		po.cmdCols = append(po.cmdCols, &parquetColumn{name: col, isInt: parquetIntColumns[col]})
+	}
+
+	return po, nil
+}
+
+// add adds a value to a column.
+func (c *parquetColumn) add(v string, i int64) {
+	if c.isInt {
+		c.ints = append(c.ints, i)
+	} else {
+		c.strs = append(c.strs, v)
+	}
+}
+
+// write adds the row of a replay, and its commands to the commands table.
+// If err is not nil, only the file and error columns are filled.
+func (po *parquetOutput) write(file string, r *rep.Replay, err error) error {
+	if err == nil {
+		computeReplay(r)
+	}
+
+	for _, c := range po.cols {
+		switch {
+		case c.name == "file":
+			c.add(file, 0)
+		case c.name == "error" && err != nil:
+			c.add(err.Error(), 0)
+		case c.name == "frames" && err == nil:
+			c.add("", int64(r.Header.Frames))
+		case c.name != "error" && err == nil:
+			c.add(csvColumns[c.name](r), 0)
+		default:
+			c.add("", 0)
+		}
+	}
+
+	if po.cmdsFile == "" || err != nil || r.Commands == nil {
+		return nil
+	}
+	for _, cmd := range r.Commands.Cmds {
+		b := cmd.BaseCmd()
+		var player string
+		if p := r.Header.PIDPlayers[b.PlayerID]; p != nil {
+			player = p.Name
+		}
+		po.cmdCols[0].add(file, 0)
+		po.cmdCols[1].add("", int64(b.Frame))
+		po.cmdCols[2].add("", int64(b.PlayerID))
+		po.cmdCols[3].add(player, 0)
+		po.cmdCols[4].add(b.Type.Name, 0)
+		po.cmdCols[5].add(cmd.Params(false), 0)
+	}
+
+	return nil
+}
+
+// flush writes the collected tables.
+func (po *parquetOutput) flush() error {
+	if err := writeParquet(po.w, po.cols); err != nil {
+		return err
+	}
+	if po.cmdsFile == "" {
+		return nil
+	}
+
+	f, err := os.Create(po.cmdsFile)
+	if err != nil {
+		return err
+	}
+	if err := writeParquet(f, po.cmdCols); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"testing"
+)
+
+func TestThriftWriter(t *testing.T) {
+	tw := &thriftWriter{}
+	tw.i32(1, 1)
+	tw.structBegin(2)
+	tw.i64(1, 3)
+	tw.structEnd()
+	tw.binary(3, "ab")
+	tw.i32(20, -1) // Long form field header
+	tw.listBegin(21, thriftI32, 2)
+	tw.varint(1)
+	tw.varint(2)
+	tw.stop()
+
+	exp := []byte{0x15, 0x02, 0x1c, 0x16, 0x06, 0x00, 0x18, 0x02, 'a', 'b', 0x05, 0x28, 0x01, 0x19, 0x25, 0x02, 0x04, 0x00}
+	if !bytes.Equal(tw.buf, exp) {
+		t.Errorf("Expected: % x, got: % x", exp, tw.buf)
+	}
+}
+
+func TestWriteParquet(t *testing.T) {
+	cols := []*parquetColumn{
+		{name: "file", strs: []string{"a.rep", "b.rep"}},
+		{name: "frames", isInt: true, ints: []int64{100, 200}},
+	}
+	buf := &bytes.Buffer{}
+	if err := writeParquet(buf, cols); err != nil {
+		t.Fatalf("Failed to write Parquet: %v", err)
+	}
+	data := buf.Bytes()
+
+	if !bytes.HasPrefix(data, []byte(parquetMagic)) || !bytes.HasSuffix(data, []byte(parquetMagic)) {
+		t.Errorf("Expected: %s magic at both ends", parquetMagic)
+	}
+	footerSize := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	if footerSize <= 0 || footerSize > len(data)-12 {
+		t.Errorf("Invalid footer size: %d", footerSize)
+	}
+
+	// PLAIN encoded values of the columns:
+	for i, exp := range [][]byte{
+		[]byte("\x05\x00\x00\x00a.rep\x05\x00\x00\x00b.rep"),
+		binary.LittleEndian.AppendUint64(binary.LittleEndian.AppendUint64(nil, 100), 200),
+	} {
+		if !bytes.Contains(data, exp) {
+			t.Errorf("[%d] Expected values not found: % x", i, exp)
+		}
+	}
+
+	// Schema in the footer:
+	footer := data[len(data)-8-footerSize : len(data)-8]
+	for _, exp := range []string{"schema", "file", "frames", fmt.Sprint(appName, " ", appVersion)} {
+		if !bytes.Contains(footer, []byte(exp)) {
+			t.Errorf("Expected %q in the footer", exp)
+		}
+	}
+}
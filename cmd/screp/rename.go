@@ -0,0 +1,162 @@
+// This file contains the rename subcommand which renames / organizes replays by their metadata.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/repparser"
+)
+
+const defaultRenamePattern = "{date}_{matchup}_{map}_{winner}.rep"
+
+// renameFields maps from pattern placeholder name to the function producing its value.
+// All CSV columns are also available (see init()).
+var renameFields = map[string]func(r *rep.Replay) string{
+	"date": func(r *rep.Replay) string {
+		return r.Header.StartTime.Format("2006-01-02")
+	},
+	"time": func(r *rep.Replay) string {
+		return r.Header.StartTime.Format("1504")
+	},
+	"duration": func(r *rep.Replay) string {
+		return strings.ReplaceAll(r.Header.Frames.String(), ":", "m")
+	},
+	"winner": func(r *rep.Replay) string {
+		if w := csvColumns["winner"](r); w != "" {
+			return w
+		}
+		return "unknown"
+	},
+}
+
+func init() {
+	for name, f := range csvColumns {
+		if _, ok := renameFields[name]; !ok {
+			renameFields[name] = f
+		}
+	}
+}
+
+// placeholderRegexp matches placeholders of rename patterns.
+var placeholderRegexp = regexp.MustCompile(`\{(\w+)\}`)
+
+// invalidFileNameChars are replaced in substituted values.
+var invalidFileNameChars = regexp.MustCompile(`[<>:"/\\|?*\x00-\x1f]`)
+
+// renamePath returns the new path of a replay according to the pattern
+// (which may contain folders). Placeholder values are sanitized to be valid file names.
+func renamePath(pattern, file string, r *rep.Replay) string {
+	p := placeholderRegexp.ReplaceAllStringFunc(pattern, func(ph string) string {
+		name := ph[1 : len(ph)-1]
+		var v string
+		if name == "name" {
+			v = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		} else {
+			v = renameFields[name](r)
+		}
+		v = invalidFileNameChars.ReplaceAllString(v, "_")
+		return strings.TrimSpace(v)
+	})
+	return filepath.FromSlash(p)
+}
+
+// uniquePath returns path if it is not taken, else inserts the lowest " (n)" suffix (n>=2)
+// before the extension that makes it unique.
+// taken is called to check if a path is taken.
+func uniquePath(path string, taken func(path string) bool) string {
+	if !taken(path) {
+		return path
+	}
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for n := 2; ; n++ {
+		if p := fmt.Sprintf("%s (%d)%s", base, n, ext); !taken(p) {
+			return p
+		}
+	}
+}
+
+// rename runs the rename subcommand.
+func rename(args []string) {
+	fs := flag.NewFlagSet("rename", flag.ExitOnError)
+	pattern := fs.String("pattern", defaultRenamePattern, "pattern of the new file name, may contain folders (use '/');\n"+
+		"placeholders: {name} (original name without extension), {date}, {time}, {duration},\n"+
+		"and the CSV columns, e.g. {map}, {matchup}, {players}, {winner}")
+	dest := fs.String("dest", "", "destination folder (new names are relative to it);\nif empty, replays remain in their current folder")
+	dryRun := fs.Bool("dryrun", false, "only print what would be done")
+	recursive := fs.Bool("r", false, "search replays recursively in the subfolders of folder arguments")
+	fs.Usage = func() {
+		fmt.Println("Usage:")
+		fmt.Printf("\t%s rename [FLAGS] repfile.rep \"replays/*.rep\" replayfolder...\n", os.Args[0])
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	for _, m := range placeholderRegexp.FindAllStringSubmatch(*pattern, -1) {
+		if _, ok := renameFields[m[1]]; !ok && m[1] != "name" {
+			fmt.Printf("Invalid placeholder: %s\n", m[0])
+			os.Exit(ExitCodeMissingArguments)
+		}
+	}
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(ExitCodeMissingArguments)
+	}
+
+	files, _, err := replayFiles(fs.Args(), *recursive)
+	if err != nil {
+		fmt.Printf("Failed to list replay files: %v\n", err)
+		os.Exit(ExitCodeMissingArguments)
+	}
+
+	cfg := repparser.Config{Commands: true, MapData: true}
+	targets := map[string]bool{} // New paths chosen so far (relevant in dry-run mode)
+	taken := func(path string) bool {
+		if targets[path] {
+			return true
+		}
+		_, err := os.Stat(path)
+		return err == nil
+	}
+
+	for _, file := range files {
+		r, err := repparser.ParseFileConfig(file, cfg)
+		if err != nil {
+			fmt.Printf("Failed to parse replay %s: %v\n", file, err)
+			continue
+		}
+		r.Compute()
+
+		dir := *dest
+		if dir == "" {
+			dir = filepath.Dir(file)
+		}
+		target := filepath.Join(dir, renamePath(*pattern, file, r))
+		if target == filepath.Clean(file) {
+			continue
+		}
+		target = uniquePath(target, taken)
+		targets[target] = true
+
+		fmt.Printf("%s -> %s\n", file, target)
+		if *dryRun {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			fmt.Printf("Failed to create folder: %v\n", err)
+			continue
+		}
+		if err := os.Rename(file, target); err != nil {
+			fmt.Printf("Failed to rename replay: %v\n", err)
+		}
+	}
+}
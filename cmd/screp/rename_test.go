@@ -0,0 +1,56 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcore"
+)
+
+func TestRenamePath(t *testing.T) {
+	p1 := &rep.Player{ID: 0, Name: "Flash", Team: 1, Race: repcore.RaceTerran, Type: repcore.PlayerTypeHuman}
+	p2 := &rep.Player{ID: 1, Name: "Jae/dong", Team: 2, Race: repcore.RaceZerg, Type: repcore.PlayerTypeHuman}
+	r := &rep.Replay{
+		Header: &rep.Header{
+			StartTime:  time.Date(2023, 5, 6, 7, 8, 9, 0, time.UTC),
+			Map:        "\x03Fighting Spirit",
+			Frames:     24000,
+			Players:    []*rep.Player{p1, p2},
+			PIDPlayers: map[byte]*rep.Player{p1.ID: p1, p2.ID: p2},
+		},
+	}
+	r.Compute()
+
+	cases := []struct {
+		pattern, exp string
+	}{
+		{defaultRenamePattern, "2023-05-06_TvZ_Fighting Spirit_unknown.rep"},
+		{"{matchup}/{date}_{time}_{name}.rep", "TvZ/2023-05-06_0708_orig.rep"},
+		{"{players} {duration}.rep", "Flash VS Jae_dong 16m48.rep"},
+	}
+	for _, c := range cases {
+		if got, exp := renamePath(c.pattern, "replays/orig.rep", r), filepath.FromSlash(c.exp); got != exp {
+			t.Errorf("[%s] Expected: %v, got: %v", c.pattern, exp, got)
+		}
+	}
+}
+
+func TestUniquePath(t *testing.T) {
+	taken := map[string]bool{"a.rep": true, "a (2).rep": true, "b.rep": true}
+	isTaken := func(path string) bool { return taken[path] }
+
+	cases := []struct {
+		path, exp string
+	}{
+		{"c.rep", "c.rep"},
+		{"b.rep", "b (2).rep"},
+		{"a.rep", "a (3).rep"},
+	}
+	for _, c := range cases {
+		if got := uniquePath(c.path, isTaken); got != c.exp {
+			t.Errorf("[%s] Expected: %v, got: %v", c.path, c.exp, got)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+// This file contains the schema subcommand which prints the JSON Schema of the output.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/icza/screp/rep/repcmd"
+	"github.com/icza/screp/repparser"
+)
+
+// schemaGenerator generates JSON Schema from Go types, following the rules of encoding/json.
+// Named struct types are placed under "$defs" and referenced.
+type schemaGenerator struct {
+	defs map[string]any
+}
+
+var (
+	timeType      = reflect.TypeFor[time.Time]()
+	bytesType     = reflect.TypeFor[repcmd.Bytes]()
+	marshalerType = reflect.TypeFor[json.Marshaler]()
+)
+
+// outputSchema returns the JSON Schema document of the output of a replay.
+func outputSchema() map[string]any {
+	g := &schemaGenerator{defs: map[string]any{}}
+	root := g.structSchema(reflect.TypeFor[output]())
+	root["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	root["title"] = "screp replay output, schema version " + schemaVersion
+	root["description"] = fmt.Sprintf("Output of %s %s (parser %s) for a replay; multiple replays produce an array of these.",
+		appName, appVersion, repparser.Version)
+	root["$defs"] = g.defs
+	return root
+}
+
+// schema returns the schema of the given type.
+// Pointers, slices and maps may also be null.
+func (g *schemaGenerator) schema(t reflect.Type) map[string]any {
+	switch t.Kind() {
+	case reflect.Pointer:
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		return nullable(g.schema(t))
+	case reflect.Slice, reflect.Map:
+		if t != bytesType {
+			return nullable(g.nonNullSchema(t))
+		}
+	}
+	return g.nonNullSchema(t)
+}
+
+// nullable returns a schema which also allows null besides the given schema.
+func nullable(s map[string]any) map[string]any {
+	switch typ := s["type"].(type) {
+	case nil:
+		if len(s) == 0 {
+			return s // Anything, including null
+		}
+		return map[string]any{"anyOf": []any{s, map[string]any{"type": "null"}}}
+	case string:
+		s["type"] = []string{typ, "null"}
+	}
+	return s
+}
+
+// nonNullSchema returns the schema of the given non-pointer type.
+func (g *schemaGenerator) nonNullSchema(t reflect.Type) map[string]any {
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == bytesType:
+		return map[string]any{"type": []string{"array", "null"}, "items": map[string]any{"type": "integer"}}
+	case t.Implements(marshalerType) || reflect.PointerTo(t).Implements(marshalerType):
+		return map[string]any{} // Custom marshaling, anything
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Interface:
+		return map[string]any{} // E.g. commands: fields depend on the command type
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		name := t.String() // Package qualified, e.g. "rep.Header"
+		if _, ok := g.defs[name]; !ok {
+			g.defs[name] = nil // Reserve the name to handle recursive types
+			g.defs[name] = g.structSchema(t)
+		}
+		return map[string]any{"$ref": "#/$defs/" + name}
+	}
+
+	return map[string]any{}
+}
+
+// structSchema returns the schema of a struct type.
+// Fields of embedded structs are promoted, on name conflicts the shallower field wins.
+func (g *schemaGenerator) structSchema(t reflect.Type) map[string]any {
+	props := map[string]any{}
+	depths := map[string]int{}
+	required := []string{}
+
+	var addFields func(t reflect.Type, depth int)
+	addFields = func(t reflect.Type, depth int) {
+		for i := range t.NumField() {
+			f := t.Field(i)
+			tag := f.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+			ft := f.Type
+			for ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+				addFields(ft, depth+1)
+				continue
+			}
+			if !f.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			if d, ok := depths[name]; ok && d <= depth {
+				continue
+			}
+			depths[name] = depth
+			props[name] = g.schema(f.Type)
+			if !strings.Contains(opts, "omitempty") {
+				required = append(required, name)
+			}
+		}
+	}
+	addFields(t, 0)
+
+	return map[string]any{"type": "object", "properties": props, "required": required}
+}
+
+// schema runs the schema subcommand.
+func schema(args []string) {
+	fs := flag.NewFlagSet("schema", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Println("Usage:")
+		fmt.Printf("\t%s schema\n", os.Args[0])
+		fmt.Println("Prints the JSON Schema of the JSON output of a replay.")
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 0 {
+		fs.Usage()
+		os.Exit(ExitCodeMissingArguments)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(outputSchema()); err != nil {
+		fmt.Printf("Failed to encode output: %v\n", err)
+	}
+}
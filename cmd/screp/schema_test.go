@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcore"
+)
+
+func TestOutputSchema(t *testing.T) {
+	s := outputSchema()
+	defs := s["$defs"].(map[string]any)
+
+	// Top-level keys of an actual output must be described:
+	out := &output{File: "a.rep", Replay: &rep.Replay{Header: &rep.Header{Engine: repcore.EngineBroodWar}}}
+	data, err := json.Marshal(out)
+	if err != nil {
+		t.Fatalf("Failed to marshal output: %v", err)
+	}
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatalf("Failed to unmarshal output: %v", err)
+	}
+	props := s["properties"].(map[string]any)
+	for key := range m {
+		if props[key] == nil {
+			t.Errorf("Expected property %q in the schema", key)
+		}
+	}
+
+	header := defs["rep.Header"].(map[string]any)
+	headerProps := header["properties"].(map[string]any)
+	for _, c := range []struct {
+		name     string
+		exists   bool
+		required bool
+	}{
+		{"Players", true, true},
+		{"Title", true, true},
+		{"RawTitle", false, false}, // json:"-"
+		{"Debug", false, false},
+	} {
+		required := slices.Contains(header["required"].([]string), c.name)
+		if exists := headerProps[c.name] != nil; exists != c.exists || required != c.required {
+			t.Errorf("[%s] Expected: %v %v, got: %v %v", c.name, c.exists, c.required, exists, required)
+		}
+	}
+
+	// Omitempty fields are not required, custom marshaled Bytes is an array:
+	md := defs["rep.MapData"].(map[string]any)
+	if slices.Contains(md["required"].([]string), "FogMask") {
+		t.Errorf("Expected FogMask not to be required")
+	}
+	fogMask := md["properties"].(map[string]any)["FogMask"].(map[string]any)
+	if typ := fogMask["type"].([]string); typ[0] != "array" {
+		t.Errorf("Expected: array, got: %v", typ)
+	}
+}
@@ -0,0 +1,71 @@
+// This file contains the versioning of the JSON output schema.
+//
+// Compatibility policy of the JSON output (see README.md):
+//   - The schema version is "major.minor", embedded in the output as the SchemaVersion field.
+//   - The minor version is incremented when fields are added (consumers must ignore unknown fields).
+//   - The major version is incremented when fields are renamed, removed or their type changes.
+//     A shim converting the output to the previous major version is added then,
+//     which can be requested with the schemaversion flag.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Version of the JSON output schema
+const (
+	schemaMajor = 1
+	schemaMinor = 0
+)
+
+// schemaVersion is the version of the JSON output schema embedded in the output.
+var schemaVersion = fmt.Sprintf("%d.%d", schemaMajor, schemaMinor)
+
+// schemaShims convert an output of a major schema version (the key) to the previous major version.
+var schemaShims = map[int]func(v map[string]any){
+	// Version 0 is the unversioned output of screp before schema versioning was introduced.
+	1: func(v map[string]any) {
+		delete(v, outputKey("SchemaVersion"))
+	},
+}
+
+const validSchemaVersions = "valid values are '1' (the current major version) and '0' (the previous major version)"
+
+// outputSchemaMajor is the major schema version of the output, parsed from the schemaversion flag.
+var outputSchemaMajor = schemaMajor
+
+// parseSchemaMajor parses the major schema version to emit.
+func parseSchemaMajor(s string) (int, error) {
+	major, err := strconv.Atoi(s)
+	if err != nil || major < 0 || major > schemaMajor {
+		return 0, fmt.Errorf("unsupported schema version: %q", s)
+	}
+	return major, nil
+}
+
+// toJSONValue converts v to a generic JSON value (maps, slices, json.Number etc.).
+func toJSONValue(v any) (any, error) {
+	data, err := marshalJSON(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var jv any
+	if err := dec.Decode(&jv); err != nil {
+		return nil, err
+	}
+	return jv, nil
+}
+
+// downgradeOutput converts an output JSON value to the given major schema version
+// by applying the shims of the majors in between.
+func downgradeOutput(v map[string]any, major int) {
+	for m := schemaMajor; m > major; m-- {
+		schemaShims[m](v)
+	}
+}
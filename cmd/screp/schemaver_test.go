@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/icza/screp/rep"
+)
+
+func TestParseSchemaMajor(t *testing.T) {
+	cases := []struct {
+		s     string
+		major int
+		valid bool
+	}{
+		{"1", 1, true},
+		{"0", 0, true},
+		{"2", 0, false},
+		{"-1", 0, false},
+		{"1.0", 0, false},
+	}
+
+	for _, c := range cases {
+		major, err := parseSchemaMajor(c.s)
+		if (err == nil) != c.valid || major != c.major {
+			t.Errorf("[%s] Expected: %v, %v, got: %v, %v", c.s, c.major, c.valid, major, err)
+		}
+	}
+}
+
+func TestSchemaShims(t *testing.T) {
+	for m := 1; m <= schemaMajor; m++ {
+		if schemaShims[m] == nil {
+			t.Errorf("Missing shim of schema version %d", m)
+		}
+	}
+}
+
+func TestSelectFieldsSchemaVersion(t *testing.T) {
+	defer func() { outputSchemaMajor = schemaMajor }()
+
+	out := &output{SchemaVersion: schemaVersion, File: "a.rep", Replay: &rep.Replay{Header: &rep.Header{Map: "Fighting Spirit"}}}
+
+	cases := []struct {
+		major      int
+		hasVersion bool
+	}{
+		{schemaMajor, true},
+		{0, false},
+	}
+
+	for _, c := range cases {
+		outputSchemaMajor = c.major
+		v, err := selectFields(out)
+		if err != nil {
+			t.Errorf("[%d] Expected no error, got: %v", c.major, err)
+			continue
+		}
+		data, _ := json.Marshal(v)
+		var m map[string]any
+		json.Unmarshal(data, &m)
+		if _, got := m["SchemaVersion"]; got != c.hasVersion {
+			t.Errorf("[%d] Expected: %v, got: %v", c.major, c.hasVersion, got)
+		}
+		if m["File"] != "a.rep" {
+			t.Errorf("[%d] Expected: %v, got: %v", c.major, "a.rep", m["File"])
+		}
+	}
+}
@@ -10,16 +10,23 @@ import (
 	"crypto/sha256"
 	"crypto/sha512"
 	"encoding/hex"
-	"encoding/json"
 	"flag"
 	"fmt"
 	"hash"
+	"image/png"
 	"io"
+	"io/fs"
+	"log/slog"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
+	"text/template"
+	"time"
 
 	"github.com/icza/screp/rep"
+	"github.com/icza/screp/repmap"
+	"github.com/icza/screp/repmap/mapfile"
 	"github.com/icza/screp/repparser"
 )
 
@@ -30,59 +37,290 @@ const (
 	appHome    = "https://github.com/icza/screp"
 )
 
-// Used exit codes
+// Used exit codes, see exitCodesHelp
 const (
 	ExitCodeMissingArguments         = 1
 	ExitCodeFailedToParseReplay      = 2
 	ExitCodeFailedToCreateOutputFile = 3
 	ExitCodeInvalidMapDataHash       = 4
+	ExitCodeInvalidFormat            = 5
+	ExitCodeInvalidReplay            = 6
 )
 
 const validMapDataHashes = "valid values are 'sha1', 'sha256', 'sha512', 'md5'"
 
-// Flag variables
+// Output formats
+const (
+	formatJSON    = "json"
+	formatNDJSON  = "ndjson"
+	formatCSV     = "csv"
+	formatParquet = "parquet"
+	formatMsgpack = "msgpack"
+)
+
+const validFormats = "valid values are 'json', 'ndjson', 'csv', 'parquet', 'msgpack'"
+
+// Flag variables, holding the default values of the flags.
+// Flags are registered in the flag sets of the commands using them (see the add*Flags() functions):
+// the default command (invoked without a subcommand) registers all of them.
 var (
-	version = flag.Bool("version", false, "print version info and exit")
-
-	overview    = flag.Bool("overview", false, "print replay overview in human-readable form (no JSON)\nother flags (except 'outFile') are ignored")
-	header      = flag.Bool("header", true, "print replay header")
-	mapData     = flag.Bool("map", false, "print map data")
-	mapTiles    = flag.Bool("maptiles", false, "print map data tiles; valid with 'map'")
-	mapResLoc   = flag.Bool("mapres", false, "print map data resource locations (minerals and geysers); valid with 'map'")
-	mapGfx      = flag.Bool("mapgfx", false, "print map graphics related data; valid with 'map'")
-	cmds        = flag.Bool("cmds", false, "print player commands")
-	computed    = flag.Bool("computed", true, "print computed / derived data")
-	mapDataHash = flag.String("mapDataHash", "", "calculate and print the hash of map data section too using the given algorithm;\n"+validMapDataHashes)
-	dumpMapData = flag.Bool("dumpMapData", false, "dump the raw map data (CHK) instead of JSON replay info\nuse it with the 'outfile' flag")
-	stdin       = flag.Bool("stdin", false, "read replay content from standard input instead of a file")
-	outFile     = flag.String("outfile", "", "optional output file name")
-
-	indent = flag.Bool("indent", true, "use indentation when formatting output")
+	version bool
+
+	overview    bool
+	lang        = "en"
+	header      = true
+	mapData     bool
+	mapTiles    bool
+	mapResLoc   bool
+	mapGfx      bool
+	mapTrigs    bool
+	mapRestr    bool
+	mapStrs     bool
+	cmds        bool
+	cmdsFormat  string
+	computed    = true
+	compute     = defaultComputations
+	mapDataHash string
+	dumpMapData bool
+	exportMap   bool
+	stdin       bool
+	recursive   bool
+	outFile     string
+	format      = formatJSON
+	tmpl        string
+	filter      string
+	watch       bool
+	watchIntv   = 2 * time.Second
+	execCmd     string
+	mapImage    string
+	mapImgLays  = "startlocs,resources"
+	mapImgTile  = 4
+	csvCols     = defaultCSVColumns
+	parquetCmds string
+	sidecars    bool
+
+	schemaVer = fmt.Sprint(schemaMajor)
+
+	indent = true
+
+	errorFormat = errorFormatText
 )
 
+// addDefaultFlags registers the flags of the default command (invoked without a subcommand).
+func addDefaultFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&version, "version", version, "print version info and exit")
+	addInputFlags(fs)
+	addLogFlags(fs)
+	addOutputFlags(fs)
+	addSectionFlags(fs)
+	addInfoFlags(fs)
+	addMapFlags(fs, "map")
+	addMapDataHashFlag(fs)
+	addCmdsFormatFlag(fs, "cmdsformat")
+	fs.BoolVar(&computed, "computed", computed, "print computed / derived data (deprecated: use 'compute=none' to exclude it)")
+	fs.BoolVar(&dumpMapData, "dumpMapData", dumpMapData, "dump the raw map data (CHK) instead of JSON replay info\nuse it with the 'outfile' flag")
+	fs.BoolVar(&exportMap, "exportMap", exportMap, "export the map as a playable map file (*.scm / *.scx) instead of JSON replay info\nuse it with the 'outfile' flag")
+}
+
+// addInputFlags registers the flags selecting the replays to process, and the flags of the output file.
+func addInputFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&stdin, "stdin", stdin, "read replay content from standard input instead of a file\n(same as passing '-' as the replay file)")
+	fs.BoolVar(&recursive, "r", recursive, "search replays recursively in the subfolders of folder arguments")
+	fs.StringVar(&filter, "filter", filter, "filter expression to select replays, e.g. 'matchup==\"TvZ\" && durationMin>10';\nrun with '-filter help' for details")
+	fs.StringVar(&outFile, "outfile", outFile, "optional output file name")
+	fs.StringVar(&errorFormat, "errorformat", errorFormat, "format of the error printed (to standard output) if a replay can't be parsed;\n"+validErrorFormats+"\n'json' prints an object with the error class and the warnings gathered so far")
+}
+
+// addOutputFlags registers the flags of the JSON output.
+func addOutputFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&header, "header", header, "print replay header")
+	fs.StringVar(&compute, "compute", compute, "comma separated list of computations to perform for the computed / derived data;\n"+validComputations)
+	fs.StringVar(&schemaVer, "schemaversion", schemaVer, "major version of the JSON output schema to emit;\n"+validSchemaVersions)
+	fs.BoolVar(&indent, "indent", indent, "use indentation when formatting output")
+	addSelectFlag(fs)
+	addJSONOptionFlags(fs)
+	addTimeZoneFlag(fs)
+}
+
+// addSectionFlags registers the flags including the commands and the map data in the JSON output.
+func addSectionFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&cmds, "cmds", cmds, "print player commands")
+	fs.BoolVar(&mapData, "map", mapData, "print map data and map analysis")
+	addMapOutputFlags(fs, "map")
+}
+
+// addInfoFlags registers the flags of the output formats other than JSON, and of the watch mode.
+func addInfoFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&overview, "overview", overview, "print replay overview in human-readable form (no JSON)\nother flags (except 'outFile') are ignored")
+	fs.StringVar(&lang, "lang", lang, "language of the overview;\n"+validLangs)
+	fs.StringVar(&format, "format", format, "output format (ignored if 'overview' is true);\n"+validFormats+"\n'ndjson' emits 1 JSON object per replay per line, including the file name\n'csv' emits 1 row per replay, see 'csvcols'\n'parquet' emits a Parquet table of 1 row per replay having the 'csvcols' columns, see 'parquetcmds'\n'msgpack' emits the JSON output in MessagePack format")
+	fs.StringVar(&csvCols, "csvcols", csvCols, "comma separated list of columns of the CSV format;\n"+validCSVColumns)
+	fs.StringVar(&parquetCmds, "parquetcmds", parquetCmds, "also write a Parquet table of 1 row per command to this file (only with 'format=parquet')")
+	fs.StringVar(&tmpl, "template", tmpl, "Go template (text/template) to render each replay with instead of 'format';\nthe template text or a template file name prefixed with '@';\nthe context is the computed replay (plus the 'File' field)")
+	fs.BoolVar(&watch, "watch", watch, "watch the folder arguments and process new replays as they appear;\nnew replays are output in NDJSON format (unless 'template', 'format=csv' or 'format=msgpack' is given)")
+	fs.DurationVar(&watchIntv, "watchInterval", watchIntv, "polling interval of the watched folders; valid with 'watch'")
+	fs.StringVar(&execCmd, "exec", execCmd, "command to run for each new replay instead of printing it, the replay file name\nis appended as the last argument; valid with 'watch'")
+	fs.BoolVar(&sidecars, "sidecar", sidecars, "also write a compact sidecar file (replay file name + '.json') next to each processed replay,\nholding the header and a computed summary (no commands); ignored when reading from standard input")
+}
+
+// validWithMap returns the usage note of map flags named with the given prefix.
+func validWithMap(prefix string) string {
+	if prefix == "" {
+		return "" // Flag of the map subcommand
+	}
+	return "; valid with 'map'"
+}
+
+// addMapOutputFlags registers the flags including parts of the map data in the JSON output,
+// named with the given prefix.
+func addMapOutputFlags(fs *flag.FlagSet, prefix string) {
+	fs.BoolVar(&mapTiles, prefix+"tiles", mapTiles, "print map data tiles and fog mask"+validWithMap(prefix))
+	fs.BoolVar(&mapResLoc, prefix+"res", mapResLoc, "print map data resource locations (minerals and geysers)"+validWithMap(prefix))
+}
+
+// addMapFlags registers the flags of the map data parsing and the map image, named with the given prefix.
+func addMapFlags(fs *flag.FlagSet, prefix string) {
+	fs.BoolVar(&mapGfx, prefix+"gfx", mapGfx, "print map graphics related data"+validWithMap(prefix))
+	fs.BoolVar(&mapTrigs, prefix+"trigs", mapTrigs, "print map triggers and mission briefing triggers"+validWithMap(prefix))
+	fs.BoolVar(&mapRestr, prefix+"restr", mapRestr, "print map upgrade and tech restrictions"+validWithMap(prefix))
+	fs.BoolVar(&mapStrs, prefix+"strs", mapStrs, "print the strings table of the map"+validWithMap(prefix))
+	fs.StringVar(&mapImage, prefix+"image", mapImage, "render the map as a PNG image to this file (in addition to the normal output)")
+	fs.StringVar(&mapImgLays, prefix+"imagelayers", mapImgLays, "comma separated list of layers to draw over the terrain of the map image;\nvalid layers are 'startlocs', 'resources', 'heatmap' (command target positions)")
+	fs.IntVar(&mapImgTile, prefix+"imagetile", mapImgTile, "size of a tile in pixels in the map image")
+}
+
+// addMapDataHashFlag registers the mapDataHash flag.
+func addMapDataHashFlag(fs *flag.FlagSet) {
+	fs.StringVar(&mapDataHash, "mapDataHash", mapDataHash, "calculate and print the hash of map data section too using the given algorithm;\n"+validMapDataHashes)
+}
+
+// addCmdsFormatFlag registers the flag of the commands format under the given name.
+func addCmdsFormatFlag(fs *flag.FlagSet, name string) {
+	fs.StringVar(&cmdsFormat, name, cmdsFormat, "list the player commands 1 per line in the given format instead of the JSON output;\n"+validCmdsFormats)
+}
+
+// subcommands holds the subcommands by name.
+var subcommands = map[string]func(args []string){
+	"info":       info,
+	"cmds":       cmdsCmd,
+	"map":        mapCmd,
+	"export":     export,
+	"serve":      serve,
+	"grpc":       grpcCmd,
+	"bench":      bench,
+	"index":      index,
+	"stats":      stats,
+	"search":     search,
+	"top":        top,
+	"list":       list,
+	"rename":     rename,
+	"dedupe":     dedupe,
+	"chat":       chat,
+	"buildorder": buildOrder,
+	"apm":        apmGraph,
+	"diff":       diff,
+	"anonymize":  anonymize,
+	"trim":       trim,
+	"modernize":  modernize,
+	"downgrade":  downgrade,
+	"lint":       lint,
+	"schema":     schema,
+}
+
+// subcommand returns the subcommand named by the first argument, nil if it doesn't name a subcommand.
+// If a file or folder exists having the name of a subcommand, it is not run: the argument is
+// processed as a replay like before subcommands were added.
+func subcommand(args []string) func(args []string) {
+	if len(args) == 0 {
+		return nil
+	}
+	cmd := subcommands[args[0]]
+	if cmd != nil {
+		if _, err := os.Stat(args[0]); err == nil {
+			return nil
+		}
+	}
+	return cmd
+}
+
 func main() {
+	if cmd := subcommand(os.Args[1:]); cmd != nil {
+		cmd(os.Args[2:])
+		return
+	}
+
+	addDefaultFlags(flag.CommandLine)
 	flag.Parse()
 
-	if *version {
+	if version {
 		printVersion()
 		return
 	}
 
-	args := flag.Args()
-	if !*stdin && len(args) < 1 {
+	run(flag.Args())
+}
+
+// run runs the replay processing (the default mode, also used by the info, cmds, map
+// and export subcommands) on the given non-flag arguments according to the flags.
+func run(args []string) {
+	if len(args) == 1 && args[0] == "-" {
+		// "-" as the input means standard input
+		stdin, args = true, nil
+	}
+	if !stdin && len(args) < 1 {
 		printUsage()
 		os.Exit(ExitCodeMissingArguments)
 	}
 
+	switch errorFormat {
+	case errorFormatText, errorFormatJSON:
+	default:
+		fmt.Printf("Invalid errorformat: %v\n", errorFormat)
+		fmt.Println(validErrorFormats)
+		os.Exit(ExitCodeInvalidFormat)
+	}
+
+	var warnings *warningCollector
+	if h, err := newLogHandler(os.Stderr, logFormat, quiet, verbose); err != nil {
+		fmt.Println(err)
+		fmt.Println(validLogFormats)
+		os.Exit(ExitCodeInvalidFormat)
+	} else {
+		warnings = newWarningCollector(h)
+	}
+	// The parser logs to the default logger
+	slog.SetDefault(slog.New(warnings))
+
+	fail := func(msg string, err error, class string, exitCode int) {
+		writeError(os.Stdout, errorFormat, msg, err, class, warnings.Warnings(), exitCode)
+		os.Exit(exitCode)
+	}
+
 	cfg := repparser.Config{
 		Commands: true,
 		MapData:  true,
 	}
 
+	if cfg, err := parseComputations(compute); err != nil {
+		fmt.Println(err)
+		fmt.Println(validComputations)
+		os.Exit(ExitCodeInvalidFormat)
+	} else {
+		computeCfg = cfg
+	}
+
+	if tr, err := newTranslator(lang); err != nil {
+		fmt.Println(err)
+		fmt.Println(validLangs)
+		os.Exit(ExitCodeInvalidFormat)
+	} else {
+		overviewTr = tr
+	}
+
 	var mapDataHasher hash.Hash
-	if *mapDataHash != "" {
+	if mapDataHash != "" {
 		cfg.Debug = true
-		switch strings.ToLower(*mapDataHash) {
+		switch strings.ToLower(mapDataHash) {
 		case "md5":
 			mapDataHasher = md5.New()
 		case "sha1":
@@ -92,127 +330,569 @@ func main() {
 		case "sha512":
 			mapDataHasher = sha512.New()
 		default:
-			fmt.Printf("Invalid mapDataHash: %v\n", *mapDataHash)
+			fmt.Printf("Invalid mapDataHash: %v\n", mapDataHash)
 			fmt.Println(validMapDataHashes)
 			os.Exit(ExitCodeInvalidMapDataHash)
 		}
 	}
 
-	if *mapGfx {
+	if mapGfx {
 		cfg.MapGraphics = true
 	}
 
-	if *dumpMapData {
+	if mapTrigs {
+		cfg.MapTriggers = true
+	}
+
+	if mapRestr {
+		cfg.MapRestrictions = true
+	}
+
+	if mapStrs {
+		cfg.MapStrings = true
+	}
+
+	if dumpMapData || exportMap || rawFields {
 		cfg.Debug = true
 	}
 
+	if loc, err := parseTimeZone(timeZone); err != nil {
+		fmt.Printf("Invalid tz: %v\n", err)
+		os.Exit(ExitCodeInvalidFormat)
+	} else {
+		startTimeLoc, cfg.Location = loc, loc
+	}
+
+	switch format {
+	case formatJSON, formatNDJSON, formatCSV, formatParquet, formatMsgpack:
+	default:
+		fmt.Printf("Invalid format: %v\n", format)
+		fmt.Println(validFormats)
+		os.Exit(ExitCodeInvalidFormat)
+	}
+	ndjson := format == formatNDJSON
+
+	switch cmdsFormat {
+	case "", cmdsFormatText, cmdsFormatTSV, cmdsFormatBWAPI, cmdsFormatJSSUH:
+	default:
+		fmt.Printf("Invalid cmdsformat: %v\n", cmdsFormat)
+		fmt.Println(validCmdsFormats)
+		os.Exit(ExitCodeInvalidFormat)
+	}
+
+	if major, err := parseSchemaMajor(schemaVer); err != nil {
+		fmt.Printf("Invalid schemaversion: %v\n", err)
+		fmt.Println(validSchemaVersions)
+		os.Exit(ExitCodeInvalidFormat)
+	} else {
+		outputSchemaMajor = major
+	}
+
+	if opts, err := parseJSONOptions(frameFormat, enumFormat, rawFields, camelCase, tileCoords); err != nil {
+		fmt.Printf("Invalid JSON options: %v\n", err)
+		fmt.Println(validJSONOptions)
+		os.Exit(ExitCodeInvalidFormat)
+	} else {
+		jsonOpts = opts
+	}
+
+	if sel, err := parseSelection(selectPaths); err != nil {
+		fmt.Printf("Invalid select: %v\n", err)
+		fmt.Println(validSelect)
+		os.Exit(ExitCodeInvalidFormat)
+	} else {
+		outputSel = sel
+	}
+
+	var destination = os.Stdout
+
+	if outFile != "" {
+		foutput, err := os.Create(outFile)
+		if err != nil {
+			fail("Failed to create output file", err, errClassOutput, ExitCodeFailedToCreateOutputFile)
+		}
+		defer func() {
+			if err := foutput.Close(); err != nil {
+				panic(err)
+			}
+		}()
+
+		destination = foutput
+	}
+
+	var enc valueEncoder
+	if format == formatMsgpack {
+		enc = msgpackEncoder{destination}
+	} else {
+		enc = newJSONEncoder(destination, indent && !ndjson)
+	}
+
+	var co rowOutput
+	if (format == formatCSV || format == formatParquet) && !overview {
+		var err error
+		if format == formatCSV {
+			co, err = newCSVOutput(destination, csvCols)
+		} else {
+			co, err = newParquetOutput(destination, csvCols, parquetCmds)
+		}
+		if err != nil {
+			fmt.Println(err)
+			fmt.Println(validCSVColumns)
+			os.Exit(ExitCodeInvalidFormat)
+		}
+		defer func() {
+			if err := co.flush(); err != nil {
+				fmt.Printf("Failed to write %s output: %v\n", format, err)
+			}
+		}()
+	}
+
+	if filter == "help" {
+		fmt.Println(validFilter)
+		return
+	}
+	var fe filterExpr
+	if filter != "" {
+		var err error
+		if fe, err = parseFilter(filter); err != nil {
+			fmt.Printf("Invalid filter: %v\n", err)
+			fmt.Println(validFilter)
+			os.Exit(ExitCodeInvalidFormat)
+		}
+	}
+
+	var t *template.Template
+	if tmpl != "" && !overview {
+		var err error
+		if t, err = newTemplate(tmpl); err != nil {
+			fmt.Printf("Invalid template: %v\n", err)
+			os.Exit(ExitCodeInvalidFormat)
+		}
+	}
+
+	// processed is called with each successfully parsed replay matching the filter.
+	processed := func(name string, r *rep.Replay) {
+		if sidecars && name != "" {
+			if err := writeSidecar(name, r); err != nil {
+				fmt.Printf("Failed to write sidecar of %s: %v\n", name, err)
+			}
+		}
+	}
+
+	if watch {
+		for _, arg := range args {
+			if fi, err := os.Stat(arg); err != nil || !fi.IsDir() {
+				fmt.Printf("Not a folder: %s\n", arg)
+				os.Exit(ExitCodeMissingArguments)
+			}
+		}
+		if stdin {
+			printUsage()
+			os.Exit(ExitCodeMissingArguments)
+		}
+		if format == formatParquet {
+			fmt.Println("The 'parquet' format can't be used in watch mode")
+			os.Exit(ExitCodeInvalidFormat)
+		}
+
+		if format != formatMsgpack {
+			enc = newJSONEncoder(destination, false) // NDJSON, never indented
+		}
+		watchReplays(args, recursive, watchIntv, nil, func(name string) {
+			r, err := repparser.ParseFileConfig(name, cfg)
+			if err != nil {
+				fmt.Printf("Failed to parse replay %s: %v\n", name, err)
+				return
+			}
+			if !matchFilter(fe, r) {
+				return
+			}
+			processed(name, r)
+			switch {
+			case execCmd != "":
+				err = runCommand(execCmd, name)
+			case t != nil:
+				err = executeTemplate(destination, t, name, r)
+			case cmdsFormat != "":
+				err = writeCmds(destination, r, cmdsFormat, name)
+			case co != nil:
+				if err = co.write(name, r, nil); err == nil {
+					err = co.flush()
+				}
+			default:
+				out := newOutput(r, mapDataHasher)
+				out.File = name
+				err = encodeOutput(enc, out)
+			}
+			if err != nil {
+				fmt.Printf("Failed to process replay %s: %v\n", name, err)
+			}
+		})
+		return
+	}
+
+	var files []string
+	var batch bool
+	if !stdin {
+		var err error
+		if files, batch, err = replayFiles(args, recursive); err != nil {
+			fmt.Printf("Failed to list replay files: %v\n", err)
+			os.Exit(ExitCodeMissingArguments)
+		}
+	}
+
+	if batch {
+		if dumpMapData || exportMap || mapImage != "" {
+			fmt.Println("The 'dumpMapData', 'exportMap' and 'mapimage' flags can't be used with multiple replays")
+			os.Exit(ExitCodeMissingArguments)
+		}
+
+		// Aggregated output: an array of replay outputs (or an overview of each replay).
+		outs := []any{}
+		for _, name := range files {
+			r, err := repparser.ParseFileConfig(name, cfg)
+			if err == nil {
+				if !matchFilter(fe, r) {
+					continue
+				}
+				processed(name, r)
+			}
+			if overview {
+				fmt.Fprintln(destination, overviewTr.label("File"), name)
+				if err != nil {
+					fmt.Fprintln(destination, overviewTr.label("Error"), err)
+				} else {
+					printOverview(destination, r)
+				}
+				fmt.Fprintln(destination)
+				continue
+			}
+			if t != nil {
+				if err != nil {
+					fmt.Printf("Failed to parse replay %s: %v\n", name, err)
+				} else if err := executeTemplate(destination, t, name, r); err != nil {
+					fmt.Printf("Failed to execute template: %v\n", err)
+				}
+				continue
+			}
+			if cmdsFormat != "" {
+				if err != nil {
+					fmt.Printf("Failed to parse replay %s: %v\n", name, err)
+				} else if err := writeCmds(destination, r, cmdsFormat, name); err != nil {
+					fmt.Printf("Failed to write commands: %v\n", err)
+				}
+				continue
+			}
+			if co != nil {
+				if err := co.write(name, r, err); err != nil {
+					fmt.Printf("Failed to write %s output: %v\n", format, err)
+				}
+				continue
+			}
+			var out *output
+			if err != nil {
+				out = &output{SchemaVersion: schemaVersion, File: name, Error: err.Error()}
+			} else {
+				out = newOutput(r, mapDataHasher)
+				out.File = name
+			}
+			if ndjson {
+				if err := encodeOutput(enc, out); err != nil {
+					fmt.Printf("Failed to encode output: %v\n", err)
+				}
+				continue
+			}
+			v, err := selectFields(out)
+			if err != nil {
+				fmt.Printf("Failed to encode output: %v\n", err)
+				continue
+			}
+			outs = append(outs, v)
+		}
+		if overview || ndjson || co != nil || t != nil || cmdsFormat != "" {
+			return
+		}
+		if err := enc.Encode(outs); err != nil {
+			fmt.Printf("Failed to encode output: %v\n", err)
+		}
+		return
+	}
+
 	// Parse replay now
 	var (
 		r   *rep.Replay
 		err error
 	)
 
-	if *stdin {
+	if stdin {
 		var data []byte
 		data, err = io.ReadAll(os.Stdin)
 		if err != nil {
-			fmt.Printf("Failed to read from stdin: %v\n", err)
-			os.Exit(ExitCodeFailedToParseReplay)
+			fail("Failed to read from stdin", err, errClassIO, ExitCodeFailedToParseReplay)
 		}
 		r, err = repparser.ParseConfig(data, cfg)
 	} else {
-		r, err = repparser.ParseFileConfig(args[0], cfg)
+		r, err = repparser.ParseFileConfig(files[0], cfg)
 	}
 
 	if err != nil {
-		fmt.Printf("Failed to parse replay: %v\n", err)
-		os.Exit(ExitCodeFailedToParseReplay)
+		fail("Failed to parse replay", err, errorClass(err), ExitCodeFailedToParseReplay)
 	}
 
-	var destination = os.Stdout
+	if !matchFilter(fe, r) {
+		return
+	}
 
-	if *outFile != "" {
-		foutput, err := os.Create(*outFile)
-		if err != nil {
-			fmt.Printf("Failed to create output file: %v\n", err)
-			os.Exit(ExitCodeFailedToCreateOutputFile)
-		}
-		defer func() {
-			if err := foutput.Close(); err != nil {
-				panic(err)
-			}
-		}()
+	var name string
+	if !stdin {
+		name = files[0]
+	}
+	processed(name, r)
 
-		destination = foutput
+	if mapImage != "" {
+		if err := writeMapImage(mapImage, r); err != nil {
+			fmt.Printf("Failed to render map image: %v\n", err)
+		}
 	}
 
-	if *overview {
+	if overview {
 		printOverview(destination, r)
 		return
 	}
 
-	if *dumpMapData {
+	if dumpMapData {
 		if _, err := destination.Write(r.MapData.Debug.Data); err != nil {
 			fmt.Printf("Failed to write map data: %v\n", err)
 		}
 		return
 	}
 
-	// custom holds any custom data we want in the output and is not part of rep.Replay
-	custom := map[string]any{}
+	if exportMap {
+		if err := mapfile.WriteReplayMap(destination, r); err != nil {
+			fmt.Printf("Failed to export map: %v\n", err)
+		}
+		return
+	}
+
+	if t != nil {
+		if err := executeTemplate(destination, t, name, r); err != nil {
+			fmt.Printf("Failed to execute template: %v\n", err)
+		}
+		return
+	}
+
+	if cmdsFormat != "" {
+		if err := writeCmds(destination, r, cmdsFormat, ""); err != nil {
+			fmt.Printf("Failed to write commands: %v\n", err)
+		}
+		return
+	}
+
+	if co != nil {
+		if err := co.write(name, r, nil); err != nil {
+			fmt.Printf("Failed to write %s output: %v\n", format, err)
+		}
+		return
+	}
+
+	out := newOutput(r, mapDataHasher)
+	if ndjson {
+		out.File = name
+	}
+	if err := encodeOutput(enc, out); err != nil {
+		fmt.Printf("Failed to encode output: %v\n", err)
+	}
+}
+
+// valueEncoder encodes values to the output, e.g. *json.Encoder.
+type valueEncoder interface {
+	Encode(v any) error
+}
 
-	if *computed {
-		r.Compute()
+// encodeOutput encodes the output projected according to the select flag.
+func encodeOutput(enc valueEncoder, out *output) error {
+	v, err := selectFields(out)
+	if err != nil {
+		return err
+	}
+	return enc.Encode(v)
+}
+
+// output is the JSON output of a replay.
+type output struct {
+	// SchemaVersion is the version of the output schema (see schemaver.go)
+	SchemaVersion string
+
+	// File is the replay file name, only set when processing multiple replays or in NDJSON format
+	File string `json:",omitempty"`
+
+	// Error is the parsing error, only set when processing multiple replays
+	Error string `json:",omitempty"`
+
+	*rep.Replay
+
+	// Custom holds any custom data we want in the output and is not part of rep.Replay
+	Custom map[string]any `json:",omitempty"`
+}
+
+// newOutput creates the output of a parsed replay according to the flags.
+func newOutput(r *rep.Replay, mapDataHasher hash.Hash) *output {
+	out := &output{SchemaVersion: schemaVersion, Replay: r, Custom: map[string]any{}}
+
+	if computed && compute != computeNone {
+		computeReplay(r)
+	} else {
+		r.Computed = nil // Filtering may have computed it
 	}
 
 	if mapDataHasher != nil {
+		mapDataHasher.Reset()
 		mapDataHasher.Write(r.MapData.Debug.Data)
-		custom["MapDataHash"] = hex.EncodeToString(mapDataHasher.Sum(nil))
+		out.Custom["MapDataHash"] = hex.EncodeToString(mapDataHasher.Sum(nil))
 	}
 
 	// Zero values in replay the user do not wish to see:
-	if !*header {
+	if !header {
 		r.Header = nil
 	}
-	if !*mapData {
+	if !mapData {
 		r.MapData = nil
+		if r.Computed != nil {
+			r.Computed.MapAnalysis = nil
+		}
 	} else {
-		if !*mapTiles {
+		if !mapTiles {
 			r.MapData.Tiles = nil
+			r.MapData.FogMask = nil
 		}
-		if !*mapResLoc {
+		if !mapResLoc {
 			r.MapData.MineralFields = nil
 			r.MapData.Geysers = nil
 		}
 	}
-	if !*cmds {
+	if !cmds {
 		r.Commands = nil
 	}
 
-	enc := json.NewEncoder(destination)
+	return out
+}
+
+// writeMapImage renders the map of the replay to a PNG file according to the flags.
+func writeMapImage(name string, r *rep.Replay) error {
+	opts := repmap.MinimapOptions{TileSize: mapImgTile}
+	for _, layer := range strings.Split(mapImgLays, ",") {
+		switch strings.TrimSpace(layer) {
+		case "startlocs":
+			opts.StartLocations = true
+		case "resources":
+			opts.Resources = true
+		case "heatmap":
+			opts.Heatmap = true
+		case "":
+		default:
+			return fmt.Errorf("invalid layer: %q", layer)
+		}
+	}
+
+	img, err := repmap.Minimap(r, opts)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
 
-	if *indent {
-		enc.SetIndent("", "  ")
+// matchFilter tells if the replay matches the filter expression.
+// The replay is computed (according to the compute flag) if there is a filter.
+func matchFilter(fe filterExpr, r *rep.Replay) bool {
+	if fe == nil {
+		return true
 	}
+	computeReplay(r)
+	return fe.eval(r)
+}
 
-	var valueToEncode any = r
+// replayFiles returns the replay files denoted by the arguments, which may be
+// files, glob patterns and directories (searched recursively if recursive is true).
+// batch tells if the arguments denote multiple replays (even if only 1 or no replay is found).
+func replayFiles(args []string, recursive bool) (files []string, batch bool, err error) {
+	batch = len(args) > 1
+	for _, arg := range args {
+		names := []string{arg}
+		if strings.ContainsAny(arg, "*?[") {
+			batch = true
+			if names, err = filepath.Glob(arg); err != nil {
+				return nil, false, err
+			}
+		}
 
-	// If there are custom data, wrap (embed) the replay in a struct that holds the custom data too:
-	if len(custom) > 0 {
-		valueToEncode = struct {
-			*rep.Replay
-			Custom map[string]any
-		}{r, custom}
+		for _, name := range names {
+			fi, err := os.Stat(name)
+			if err != nil || !fi.IsDir() {
+				files = append(files, name) // Parsing will report the error
+				continue
+			}
+
+			batch = true
+			err = filepath.WalkDir(name, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if d.IsDir() {
+					if path != name && !recursive {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				if strings.EqualFold(filepath.Ext(path), ".rep") {
+					files = append(files, path)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, false, err
+			}
+		}
 	}
 
-	if err := enc.Encode(valueToEncode); err != nil {
-		fmt.Printf("Failed to encode output: %v\n", err)
+	return
+}
+
+// timeZone is the value of the tz flag, see addTimeZoneFlag().
+var timeZone string
+
+// addTimeZoneFlag registers the tz flag.
+func addTimeZoneFlag(fs *flag.FlagSet) {
+	fs.StringVar(&timeZone, "tz", timeZone, "time zone of the start time of games in the output, e.g. 'UTC' or 'Europe/Berlin';\ndefault is the local time zone")
+}
+
+// startTimeLoc is the time zone of the start time of games, parsed from the tz flag (nil for the local time zone).
+var startTimeLoc *time.Location
+
+// parseTimeZone returns the location of the given time zone name (see the tz flag),
+// nil for the local time zone if name is empty.
+func parseTimeZone(name string) (*time.Location, error) {
+	if name == "" {
+		return nil, nil
 	}
+	return time.LoadLocation(name)
 }
 
-func printOverview(out *os.File, rep *rep.Replay) {
-	rep.Compute()
+// overviewTr is the translator of the overview output built from the lang flag.
+var overviewTr translator
+
+func printOverview(out io.Writer, rep *rep.Replay) {
+	computeReplay(rep)
 
+	tr := overviewTr
 	engine := rep.Header.Engine.ShortName
 	if rep.Header.Version != "" {
 		engine = engine + " " + rep.Header.Version
@@ -223,19 +903,25 @@ func printOverview(out *os.File, rep *rep.Replay) {
 	}
 	winner := ""
 	if rep.Computed.WinnerTeam != 0 {
-		winner = fmt.Sprint("Team ", rep.Computed.WinnerTeam)
+		winner = fmt.Sprintf(tr.T("Team %d"), rep.Computed.WinnerTeam)
 	}
 
-	fmt.Fprintln(out, "Engine  :", engine)
-	fmt.Fprintln(out, "Date    :", rep.Header.StartTime.Format("2006-01-02 15:04:05 -07:00"))
-	fmt.Fprintln(out, "Length  :", rep.Header.Frames.String())
-	fmt.Fprintln(out, "Title   :", rep.Header.Title)
-	fmt.Fprintln(out, "Map     :", mapName)
-	fmt.Fprintln(out, "Type    :", rep.Header.Type.Name)
-	fmt.Fprintln(out, "Matchup :", rep.Header.Matchup())
-	fmt.Fprintln(out, "Winner  :", winner)
+	fmt.Fprintln(out, tr.label("Engine"), engine)
+	fmt.Fprintln(out, tr.label("Date"), rep.Header.StartTime.Format("2006-01-02 15:04:05 -07:00"))
+	fmt.Fprintln(out, tr.label("Length"), rep.Header.Frames.String())
+	fmt.Fprintln(out, tr.label("Title"), rep.Header.Title)
+	fmt.Fprintln(out, tr.label("Map"), mapName)
+	fmt.Fprintln(out, tr.label("Type"), tr.T(rep.Header.Type.Name))
+	fmt.Fprintln(out, tr.label("Matchup"), rep.Header.Matchup())
+	fmt.Fprintln(out, tr.label("Winner"), winner)
+
+	// Width of the race column
+	raceWidth := displayWidth(tr.T("R"))
+	for _, p := range rep.Header.Players {
+		raceWidth = max(raceWidth, displayWidth(firstRune(tr.T(p.Race.Name))))
+	}
 
-	fmt.Fprintln(out, "Team  R  APM EAPM   @  Name ")
+	fmt.Fprintf(out, "%s  %s  APM EAPM   @  %s \n", padRight(tr.T("Team"), 4), padRight(tr.T("R"), raceWidth), tr.T("Name"))
 	for i, p := range rep.Header.Players {
 		pd := rep.Computed.PlayerDescs[i]
 		mins := pd.LastCmdFrame.Duration().Minutes()
@@ -246,7 +932,8 @@ func printOverview(out *os.File, rep *rep.Replay) {
 		if pd.EffectiveCmdCount > 0 {
 			eapm = int(float64(pd.EffectiveCmdCount)/mins + 0.5)
 		}
-		fmt.Fprintf(out, "%3d   %s %4d %4d  %2d  %s\n", p.Team, p.Race.Name[:1], apm, eapm, pd.StartDirection, p.Name)
+		race := padRight(firstRune(tr.T(p.Race.Name)), raceWidth)
+		fmt.Fprintf(out, "%3d   %s %4d %4d  %2d  %s\n", p.Team, race, apm, eapm, pd.StartDirection, p.Name)
 	}
 }
 
@@ -264,5 +951,58 @@ func printUsage() {
 	fmt.Println("Usage:")
 	name := os.Args[0]
 	fmt.Printf("\t%s [FLAGS] repfile.rep\n", name)
+	fmt.Printf("\t%s [FLAGS] - (read replay from standard input)\n", name)
+	fmt.Printf("\t%s [FLAGS] repfile1.rep \"replays/*.rep\" replayfolder...\n", name)
+	fmt.Println("\tMultiple replays (files, glob patterns, folders) produce a JSON array.")
+	fmt.Println("\tA replay file or folder named like a subcommand (e.g. 'map') is processed as a replay.")
+	fmt.Printf("\t%s info [FLAGS] repfiles...\n", name)
+	fmt.Println("\tSame as above, with only the flags affecting the header and computed data, run with 'info -h' for details.")
+	fmt.Printf("\t%s cmds [FLAGS] repfiles...\n", name)
+	fmt.Println("\tPrints the player commands of replays, run with 'cmds -h' for details.")
+	fmt.Printf("\t%s map [FLAGS] repfiles...\n", name)
+	fmt.Println("\tPrints the map data of replays, run with 'map -h' for details.")
+	fmt.Printf("\t%s export [FLAGS] repfile.rep output.scx\n", name)
+	fmt.Println("\tExports the map of a replay, run with 'export -h' for details.")
+	fmt.Printf("\t%s serve [FLAGS]\n", name)
+	fmt.Println("\tParses replays POSTed over HTTP, run with 'serve -h' for details.")
+	fmt.Printf("\t%s grpc [FLAGS]\n", name)
+	fmt.Println("\tServes the gRPC ReplayService of proto/screp.proto, run with 'grpc -h' for details.")
+	fmt.Printf("\t%s rename [FLAGS] repfiles...\n", name)
+	fmt.Println("\tRenames / organizes replays by their metadata, run with 'rename -h' for details.")
+	fmt.Printf("\t%s dedupe [FLAGS] repfiles...\n", name)
+	fmt.Println("\tDetects duplicate replays, run with 'dedupe -h' for details.")
+	fmt.Printf("\t%s chat [FLAGS] repfile.rep\n", name)
+	fmt.Println("\tPrints the chat transcript of a replay, run with 'chat -h' for details.")
+	fmt.Printf("\t%s buildorder [FLAGS] repfile.rep\n", name)
+	fmt.Println("\tPrints the build orders of players, run with 'buildorder -h' for details.")
+	fmt.Printf("\t%s apm [FLAGS] repfile.rep\n", name)
+	fmt.Println("\tRenders the APM of players over time, run with 'apm -h' for details.")
+	fmt.Printf("\t%s diff [FLAGS] a.rep b.rep\n", name)
+	fmt.Println("\tCompares 2 replays, run with 'diff -h' for details.")
+	fmt.Printf("\t%s anonymize repfile.rep output.rep\n", name)
+	fmt.Println("\tWrites a copy of a replay with player names, title and chat removed, run with 'anonymize -h' for details.")
+	fmt.Printf("\t%s trim [FLAGS] repfile.rep output.rep\n", name)
+	fmt.Println("\tWrites a copy of a replay truncated at a game time, run with 'trim -h' for details.")
+	fmt.Printf("\t%s modernize repfile.rep output.rep\n", name)
+	fmt.Println("\tConverts a legacy (pre 1.18) replay into the modern replay format, run with 'modernize -h' for details.")
+	fmt.Printf("\t%s downgrade repfile.rep output.rep\n", name)
+	fmt.Println("\tConverts a modern replay into the legacy (pre 1.18) replay format, run with 'downgrade -h' for details.")
+	fmt.Printf("\t%s lint [FLAGS] repfiles...\n", name)
+	fmt.Println("\tValidates replays and prints a JSON report, run with 'lint -h' for details.")
+	fmt.Printf("\t%s bench [FLAGS] replayfolder...\n", name)
+	fmt.Println("\tMeasures parsing throughput, allocations and per-section timings, run with 'bench -h' for details.")
+	fmt.Printf("\t%s index [FLAGS] replayfolder...\n", name)
+	fmt.Println("\tMaintains an incremental index of replay libraries, run with 'index -h' for details.")
+	fmt.Printf("\t%s search [FLAGS]\n", name)
+	fmt.Println("\tLists the indexed games by player, map, matchup and date, run with 'search -h' for details.")
+	fmt.Printf("\t%s stats [FLAGS] replayfolder...\n", name)
+	fmt.Println("\tPrints statistics of replays (matchups, maps, race win rates, APM, game lengths), run with 'stats -h' for details.")
+	fmt.Printf("\t%s list [FLAGS] replayfolder...\n", name)
+	fmt.Println("\tPrints a compact line per game, sorted and grouped, run with 'list -h' for details.")
+	fmt.Printf("\t%s top [FLAGS] replayfolder...\n", name)
+	fmt.Println("\tPrints a leaderboard of players (games, win rate, APM, favorite race and map), run with 'top -h' for details.")
+	fmt.Printf("\t%s schema\n", name)
+	fmt.Println("\tPrints the JSON Schema of the JSON output of a replay.")
 	fmt.Println("\tRun with '-h' to see a list of available flags.")
+	fmt.Println(exitCodesHelp)
 }
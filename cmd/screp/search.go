@@ -0,0 +1,112 @@
+// This file contains the search subcommand which queries the replay library index.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/icza/screp/repindex"
+)
+
+// searchDateLayout is the layout of dates of the search subcommand.
+const searchDateLayout = "2006-01-02"
+
+// parseSearchDate parses a date of the search subcommand, the empty string results in the zero time.
+func parseSearchDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(searchDateLayout, s)
+}
+
+// gamePlayers returns the names of the players of a game in team order,
+// inserting " VS " between different teams, marking winners with a '*'.
+// Observers are excluded.
+func gamePlayers(g *repindex.Game) string {
+	sb := &strings.Builder{}
+	first, prevTeam := true, byte(0)
+	for _, p := range g.Players {
+		if p.Observer {
+			continue
+		}
+		if !first {
+			if p.Team != prevTeam {
+				sb.WriteString(" VS ")
+			} else {
+				sb.WriteString(", ")
+			}
+		}
+		sb.WriteString(p.Name)
+		if p.Winner {
+			sb.WriteByte('*')
+		}
+		first, prevTeam = false, p.Team
+	}
+	return sb.String()
+}
+
+// writeGameLine writes a compact line of a game.
+func writeGameLine(w io.Writer, g *repindex.Game) {
+	file := ""
+	if len(g.Files) > 0 {
+		file = g.Files[0]
+	}
+	fmt.Fprintf(w, "%s  %6s  %-7s  %-24s  %s  %s\n", g.StartTime.Format("2006-01-02 15:04"), g.Frames,
+		g.Matchup, g.Map, gamePlayers(g), file)
+}
+
+// search runs the search subcommand.
+func search(args []string) {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	indexFile := fs.String("index", defaultIndexFile, "index file to search, see the index subcommand")
+	player := fs.String("player", "", "name of a player of the game (case-insensitive)")
+	mapName := fs.String("map", "", "part of the map name (case-insensitive)")
+	matchup := fs.String("matchup", "", "matchup of the game, e.g. 'TvZ' (the order of teams does not matter)")
+	after := fs.String("after", "", "list games started after this date (format: yyyy-mm-dd)")
+	before := fs.String("before", "", "list games started before this date (format: yyyy-mm-dd)")
+	jsonOut := fs.Bool("json", false, "print the matching games in JSON format")
+	fs.Usage = func() {
+		fmt.Println("Usage:")
+		fmt.Printf("\t%s search [FLAGS]\n", os.Args[0])
+		fmt.Println("\tLists the indexed games matching all the given criteria (winners are marked with '*').")
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 0 {
+		fs.Usage()
+		os.Exit(ExitCodeMissingArguments)
+	}
+
+	q := repindex.Query{Player: *player, Map: *mapName, Matchup: *matchup}
+	var err error
+	if q.After, err = parseSearchDate(*after); err == nil {
+		q.Before, err = parseSearchDate(*before)
+	}
+	if err != nil {
+		fmt.Printf("Invalid date: %v\n", err)
+		os.Exit(ExitCodeInvalidFormat)
+	}
+
+	games := loadIndex(*indexFile).Query(q)
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(games); err != nil {
+			fmt.Printf("Failed to encode output: %v\n", err)
+		}
+		return
+	}
+
+	for _, g := range games {
+		writeGameLine(os.Stdout, g)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/icza/screp/rep/repcore"
+	"github.com/icza/screp/repindex"
+)
+
+func TestParseSearchDate(t *testing.T) {
+	cases := []struct {
+		s     string
+		exp   time.Time
+		valid bool
+	}{
+		{"", time.Time{}, true},
+		{"2023-01-02", time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC), true},
+		{"2023.01.02", time.Time{}, false},
+	}
+
+	for _, c := range cases {
+		got, err := parseSearchDate(c.s)
+		if (err == nil) != c.valid || !got.Equal(c.exp) {
+			t.Errorf("[%s] Expected: %v, %v, got: %v, %v", c.s, c.exp, c.valid, got, err)
+		}
+	}
+}
+
+func TestWriteGameLine(t *testing.T) {
+	g := &repindex.Game{
+		Files:     []string{"a.rep"},
+		StartTime: time.Date(2023, 1, 2, 3, 4, 0, 0, time.UTC),
+		Frames:    repcore.Duration2Frame(12*time.Minute + 30*time.Second),
+		Map:       "Fighting Spirit",
+		Matchup:   "TvZ",
+		Players: []*repindex.Player{
+			{Name: "Flash", Team: 1, Winner: true},
+			{Name: "Jaedong", Team: 2},
+			{Name: "Obs", Team: 3, Observer: true},
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	writeGameLine(buf, g)
+	exp := "2023-01-02 03:04   12:29  TvZ      Fighting Spirit           Flash* VS Jaedong  a.rep\n"
+	if buf.String() != exp {
+		t.Errorf("Expected: %q, got: %q", exp, buf)
+	}
+}
@@ -0,0 +1,134 @@
+// This file contains the field projection of the JSON output (the select flag).
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+const validSelect = "comma separated list of field paths, e.g. 'Header.Map,Header.Players[].Name,Computed.WinnerTeam';\n" +
+	"path elements are separated by dots, '[]' after a field name selects from all elements of an array"
+
+// fieldSelection is a parsed field projection: a list of field paths.
+type fieldSelection [][]string
+
+// selectPaths is the value of the select flag, see addSelectFlag().
+var selectPaths string
+
+// addSelectFlag registers the select flag.
+func addSelectFlag(fs *flag.FlagSet) {
+	fs.StringVar(&selectPaths, "select", selectPaths, "select only the given fields of the JSON output (the 'File', 'Error' and 'SchemaVersion' fields are always kept);\n"+validSelect)
+}
+
+// outputSel is the field selection built from the select flag.
+var outputSel fieldSelection
+
+// parseSelection parses a comma separated list of field paths.
+func parseSelection(s string) (fieldSelection, error) {
+	var sel fieldSelection
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		path := strings.Split(p, ".")
+		for _, name := range path {
+			if name = strings.TrimSuffix(name, "[]"); name == "" || strings.ContainsAny(name, "[]") {
+				return nil, fmt.Errorf("invalid field path: %q", p)
+			}
+		}
+		sel = append(sel, path)
+	}
+	return sel, nil
+}
+
+// selectFields returns the projection of the output according to the select flag,
+// converted to the major schema version requested by the schemaversion flag.
+// The File, Error and SchemaVersion fields are always kept (they identify the replay
+// in multi-replay output, and the schema of the output).
+// The output itself is returned if there is no field selection and no conversion.
+func selectFields(out *output) (any, error) {
+	if len(outputSel) == 0 && outputSchemaMajor == schemaMajor {
+		return out, nil
+	}
+
+	v, err := toJSONValue(out)
+	if err != nil {
+		return nil, err
+	}
+	if m, ok := v.(map[string]any); ok {
+		downgradeOutput(m, outputSchemaMajor)
+	}
+	if len(outputSel) == 0 {
+		return v, nil
+	}
+
+	var result any = map[string]any{}
+	for _, path := range append(outputSel, []string{outputKey("File")}, []string{outputKey("Error")}, []string{outputKey("SchemaVersion")}) {
+		if pv, ok := project(v, path); ok {
+			result = mergeValues(result, pv)
+		}
+	}
+	return result, nil
+}
+
+// project returns the value having only the field denoted by path.
+// ok is false if the field does not exist.
+func project(v any, path []string) (pv any, ok bool) {
+	if len(path) == 0 {
+		return v, true
+	}
+
+	m, isMap := v.(map[string]any)
+	if !isMap {
+		return nil, false
+	}
+	name, isArray := strings.CutSuffix(path[0], "[]")
+	child, exists := m[name]
+	if !exists {
+		return nil, false
+	}
+
+	if arr, isArr := child.([]any); isArray && isArr {
+		parr := make([]any, len(arr))
+		for i, elem := range arr {
+			if parr[i], ok = project(elem, path[1:]); !ok {
+				parr[i] = map[string]any{}
+			}
+		}
+		return map[string]any{name: parr}, true
+	}
+
+	if child == nil {
+		return map[string]any{name: nil}, true // Keep null values (e.g. no computed data)
+	}
+	if pv, ok = project(child, path[1:]); !ok {
+		return nil, false
+	}
+	return map[string]any{name: pv}, true
+}
+
+// mergeValues merges b into a: objects are merged by keys, arrays of the same length by elements.
+func mergeValues(a, b any) any {
+	switch av := a.(type) {
+	case map[string]any:
+		if bv, ok := b.(map[string]any); ok {
+			for k, v := range bv {
+				if old, exists := av[k]; exists {
+					v = mergeValues(old, v)
+				}
+				av[k] = v
+			}
+			return av
+		}
+	case []any:
+		if bv, ok := b.([]any); ok && len(av) == len(bv) {
+			for i := range av {
+				av[i] = mergeValues(av[i], bv[i])
+			}
+			return av
+		}
+	}
+	return b
+}
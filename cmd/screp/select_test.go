@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcore"
+)
+
+func TestParseSelection(t *testing.T) {
+	cases := []struct {
+		s     string
+		paths int
+		valid bool
+	}{
+		{"", 0, true},
+		{"Header.Map, Computed.WinnerTeam", 2, true},
+		{"Header.Players[].Name", 1, true},
+		{"Header..Map", 0, false},
+		{"Header.Pla[yers", 0, false},
+	}
+
+	for _, c := range cases {
+		sel, err := parseSelection(c.s)
+		if (err == nil) != c.valid || len(sel) != c.paths {
+			t.Errorf("[%s] Expected: %v, %v, got: %v, %v", c.s, c.paths, c.valid, len(sel), err)
+		}
+	}
+}
+
+func TestSelectFields(t *testing.T) {
+	defer func() { outputSel = nil }()
+
+	out := &output{
+		SchemaVersion: "1.0",
+		File:          "a.rep",
+		Replay: &rep.Replay{
+			Header: &rep.Header{
+				Frames:    repcore.Duration2Frame(time.Minute),
+				Map:       "Fighting Spirit",
+				Players:   []*rep.Player{{Name: "Alice", Team: 1}, {Name: "Bob", Team: 2}},
+				StartTime: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+			},
+		},
+	}
+
+	cases := []struct {
+		sel string
+		exp string
+	}{
+		{"Header.Map", `{"File":"a.rep","Header":{"Map":"Fighting Spirit"},"SchemaVersion":"1.0"}`},
+		{"Header.Map,Header.Players[].Name,Header.Players[].Team",
+			`{"File":"a.rep","Header":{"Map":"Fighting Spirit","Players":[{"Name":"Alice","Team":1},{"Name":"Bob","Team":2}]},"SchemaVersion":"1.0"}`},
+		{"Header.Players[].Team", `{"File":"a.rep","Header":{"Players":[{"Team":1},{"Team":2}]},"SchemaVersion":"1.0"}`},
+		{"Computed.WinnerTeam", `{"Computed":null,"File":"a.rep","SchemaVersion":"1.0"}`},
+		{"Header.NoSuchField", `{"File":"a.rep","SchemaVersion":"1.0"}`},
+	}
+
+	for _, c := range cases {
+		var err error
+		if outputSel, err = parseSelection(c.sel); err != nil {
+			t.Fatalf("[%s] Unexpected error: %v", c.sel, err)
+		}
+		v, err := selectFields(out)
+		if err != nil {
+			t.Fatalf("[%s] Unexpected error: %v", c.sel, err)
+		}
+		got, _ := json.Marshal(v)
+		if string(got) != c.exp {
+			t.Errorf("[%s] Expected: %v, got: %v", c.sel, c.exp, string(got))
+		}
+	}
+}
@@ -0,0 +1,129 @@
+// This file contains the serve subcommand which parses replays over HTTP.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/icza/screp/rep"
+
+	"github.com/icza/screp/repgraphql"
+	"github.com/icza/screp/repindex"
+	"github.com/icza/screp/repparser"
+)
+
+// maxServeRepSize is the max size of replays accepted by the server.
+const maxServeRepSize = 32 << 20
+
+// replayPool is the pool of replays reused by the server, so the large commands slice
+// of replays is not reallocated for each request.
+var replayPool = sync.Pool{
+	New: func() any { return new(rep.Replay) },
+}
+
+// replayHandler returns the HTTP handler which parses replays posted to it.
+//
+// The replay is the request body. The output is the same JSON as the default output
+// (controlled by the flags of the serve subcommand). Errors are reported as JSON error objects (see errorOutput).
+func replayHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		sendError := func(status int, err error, class string) {
+			w.WriteHeader(status)
+			writeError(w, errorFormatJSON, "", err, class, nil, 0)
+		}
+
+		if req.Method != http.MethodPost {
+			sendError(http.StatusMethodNotAllowed, fmt.Errorf("method not allowed: %s", req.Method), errClassIO)
+			return
+		}
+
+		data, err := io.ReadAll(http.MaxBytesReader(w, req.Body, maxServeRepSize))
+		if err != nil {
+			sendError(http.StatusBadRequest, err, errClassIO)
+			return
+		}
+
+		r := replayPool.Get().(*rep.Replay)
+		defer replayPool.Put(r)
+		if err := repparser.ParseConfigInto(data, repparser.Config{Commands: true, MapData: true, Location: startTimeLoc}, r); err != nil {
+			sendError(http.StatusUnprocessableEntity, err, errorClass(err))
+			return
+		}
+
+		out := newOutput(r, nil)
+		if err := encodeOutput(newJSONEncoder(w, indent), out); err != nil {
+			log.Printf("Failed to encode output: %v", err)
+		}
+	})
+}
+
+// serve runs the serve subcommand.
+func serve(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", "localhost:8080", "address to listen on")
+	indexFile := fs.String("index", "", "optional index file of the replay library to query via /graphql, see the index subcommand")
+	addOutputFlags(fs)
+	addSectionFlags(fs)
+	fs.Usage = func() {
+		fmt.Println("Usage:")
+		fmt.Printf("\t%s serve [FLAGS]\n", os.Args[0])
+		fmt.Println("\tStarts an HTTP server which parses replays POSTed to /parse and responds with their JSON output.")
+		fmt.Println("\tGraphQL queries of replays (and of the indexed library) are served at /graphql.")
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 0 {
+		fs.Usage()
+		os.Exit(ExitCodeMissingArguments)
+	}
+
+	cfg, err := parseComputations(compute)
+	if err != nil {
+		fmt.Println(err)
+		fmt.Println(validComputations)
+		os.Exit(ExitCodeInvalidFormat)
+	}
+	computeCfg = cfg
+
+	if outputSel, err = parseSelection(selectPaths); err != nil {
+		fmt.Printf("Invalid select: %v\n", err)
+		fmt.Println(validSelect)
+		os.Exit(ExitCodeInvalidFormat)
+	}
+	if jsonOpts, err = parseJSONOptions(frameFormat, enumFormat, rawFields, camelCase, tileCoords); err != nil {
+		fmt.Printf("Invalid JSON options: %v\n", err)
+		fmt.Println(validJSONOptions)
+		os.Exit(ExitCodeInvalidFormat)
+	}
+	if startTimeLoc, err = parseTimeZone(timeZone); err != nil {
+		fmt.Printf("Invalid tz: %v\n", err)
+		os.Exit(ExitCodeInvalidFormat)
+	}
+	if outputSchemaMajor, err = parseSchemaMajor(schemaVer); err != nil {
+		fmt.Printf("Invalid schemaversion: %v\n", err)
+		fmt.Println(validSchemaVersions)
+		os.Exit(ExitCodeInvalidFormat)
+	}
+
+	var idx *repindex.Index
+	if *indexFile != "" {
+		idx = loadIndex(*indexFile)
+	}
+
+	http.Handle("/parse", replayHandler())
+	http.Handle("/graphql", repgraphql.Handler(idx))
+	log.Printf("Listening on %s", *addr)
+	if err := http.ListenAndServe(*addr, nil); err != nil {
+		log.Fatal(err)
+	}
+}
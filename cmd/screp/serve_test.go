@@ -0,0 +1,38 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReplayHandler(t *testing.T) {
+	cases := []struct {
+		name   string
+		method string
+		body   string
+		status int
+		class  string
+	}{
+		{"get", http.MethodGet, "", http.StatusMethodNotAllowed, errClassIO},
+		{"not replay", http.MethodPost, "not a replay, just some text of at least 30 bytes", http.StatusUnprocessableEntity, errClassNotReplay},
+	}
+
+	h := replayHandler()
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(c.method, "/parse", strings.NewReader(c.body)))
+		if rec.Code != c.status {
+			t.Errorf("[%s] Expected: %v, got: %v", c.name, c.status, rec.Code)
+		}
+		var eo errorOutput
+		if err := json.Unmarshal(rec.Body.Bytes(), &eo); err != nil {
+			t.Errorf("[%s] Failed to unmarshal: %v", c.name, err)
+		}
+		if eo.Class != c.class {
+			t.Errorf("[%s] Expected: %v, got: %v", c.name, c.class, eo.Class)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+// This file contains the generation of sidecar files: compact metadata files
+// written next to replays so replay managers can list them without parsing.
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/icza/screp/rep"
+)
+
+// sidecarExt is appended to the replay file name to get the name of its sidecar file,
+// e.g. "game.rep" => "game.rep.json".
+const sidecarExt = ".json"
+
+// sidecarVersion is the version of the sidecar format.
+// Incremented when fields are renamed or removed (consumers must ignore unknown fields).
+const sidecarVersion = 1
+
+// sidecarComputeCfg is the computation configuration of the sidecar summary.
+// It's independent of the flags so sidecars are the same regardless of how they were generated.
+var sidecarComputeCfg = rep.ComputeConfig{EAPM: true, Winners: true}
+
+// sidecar is the content of a sidecar file.
+type sidecar struct {
+	// SidecarVersion is the version of the sidecar format
+	SidecarVersion int
+
+	// Generator is the name and version of the app that generated the sidecar
+	Generator string
+
+	// ReplaySize is the size of the replay file in bytes
+	ReplaySize int64
+
+	// ReplayModTime is the modification time of the replay file.
+	// The sidecar is stale if the size or modification time of the replay differs.
+	ReplayModTime time.Time
+
+	// Header is the header of the replay
+	Header *rep.Header
+
+	// Summary is the computed summary of the replay
+	Summary *sidecarSummary
+}
+
+// sidecarSummary is the computed summary of a replay in a sidecar.
+type sidecarSummary struct {
+	// Matchup of the game, e.g. "PvT"
+	Matchup string
+
+	// WinnerTeam is the team of the winner(s), 0 if unknown
+	WinnerTeam byte
+
+	// RepSaverPlayerID is the player ID of the replay saver, if known
+	RepSaverPlayerID *byte `json:",omitempty"`
+
+	// PlayerDescs contains the command counts, APM and EAPM of the players
+	PlayerDescs []*rep.PlayerDesc
+
+	// Teams of the game
+	Teams []*rep.Team
+}
+
+// newSidecar creates the sidecar of a replay.
+func newSidecar(r *rep.Replay, fi os.FileInfo) *sidecar {
+	// Compute on a shallow copy so the Computed field of the replay (computed according to the flags) is untouched:
+	rc := *r
+	rc.Computed = nil
+	rc.ComputeConfig(sidecarComputeCfg)
+
+	return &sidecar{
+		SidecarVersion: sidecarVersion,
+		Generator:      appName + " " + appVersion,
+		ReplaySize:     fi.Size(),
+		ReplayModTime:  fi.ModTime().UTC(),
+		Header:         r.Header,
+		Summary: &sidecarSummary{
+			Matchup:          r.Header.Matchup(),
+			WinnerTeam:       rc.Computed.WinnerTeam,
+			RepSaverPlayerID: rc.Computed.RepSaverPlayerID,
+			PlayerDescs:      rc.Computed.PlayerDescs,
+			Teams:            rc.Computed.Teams,
+		},
+	}
+}
+
+// writeSidecar writes the sidecar file of the given replay file.
+// It must be called before the output is created (newOutput() may clear the header).
+func writeSidecar(name string, r *rep.Replay) error {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(newSidecar(r, fi))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name+sidecarExt, append(data, '\n'), 0644)
+}
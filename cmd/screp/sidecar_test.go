@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcmd"
+	"github.com/icza/screp/rep/repcore"
+)
+
+func TestWriteSidecar(t *testing.T) {
+	p1 := &rep.Player{ID: 0, Name: "Flash", Team: 1, Race: repcore.RaceTerran, Type: repcore.PlayerTypeHuman}
+	p2 := &rep.Player{ID: 1, Name: "Jaedong", Team: 2, Race: repcore.RaceZerg, Type: repcore.PlayerTypeHuman}
+	r := &rep.Replay{
+		Header: &rep.Header{
+			Map:        "Fighting Spirit",
+			Frames:     24000,
+			Players:    []*rep.Player{p1, p2},
+			PIDPlayers: map[byte]*rep.Player{p1.ID: p1, p2.ID: p2},
+		},
+		Commands: &rep.Commands{Cmds: []repcmd.Cmd{
+			&repcmd.LeaveGameCmd{Base: &repcmd.Base{Frame: 23000, PlayerID: 1, Type: repcmd.TypeLeaveGame}, Reason: repcmd.LeaveReasonByID(1)},
+		}},
+	}
+
+	name := filepath.Join(t.TempDir(), "game.rep")
+	if err := os.WriteFile(name, []byte("replay data"), 0644); err != nil {
+		t.Fatalf("Failed to write replay: %v", err)
+	}
+	if err := writeSidecar(name, r); err != nil {
+		t.Fatalf("Failed to write sidecar: %v", err)
+	}
+	if r.Computed != nil {
+		t.Errorf("Expected the replay not to be computed")
+	}
+
+	data, err := os.ReadFile(name + ".json")
+	if err != nil {
+		t.Fatalf("Failed to read sidecar: %v", err)
+	}
+	var sc struct {
+		SidecarVersion int
+		ReplaySize     int64
+		ReplayModTime  time.Time
+		Header         struct{ Map string }
+		Summary        struct {
+			Matchup     string
+			WinnerTeam  byte
+			PlayerDescs []struct{ PlayerID byte }
+		}
+		Commands any
+	}
+	if err := json.Unmarshal(data, &sc); err != nil {
+		t.Fatalf("Failed to decode sidecar: %v", err)
+	}
+
+	for i, c := range []struct {
+		got, exp any
+	}{
+		{sc.SidecarVersion, sidecarVersion},
+		{sc.ReplaySize, int64(len("replay data"))},
+		{sc.ReplayModTime.IsZero(), false},
+		{sc.Header.Map, "Fighting Spirit"},
+		{sc.Summary.Matchup, "TvZ"},
+		{sc.Summary.WinnerTeam, byte(1)},
+		{len(sc.Summary.PlayerDescs), 2},
+		{sc.Commands, nil},
+	} {
+		if c.got != c.exp {
+			t.Errorf("[%d] Expected: %v, got: %v", i, c.exp, c.got)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+// This file contains the stats subcommand which aggregates statistics of a replay corpus.
+
+package main
+
+import (
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/icza/screp/repindex"
+)
+
+// corpusStats are the statistics of a replay corpus.
+type corpusStats struct {
+	// Games is the number of (distinct) games
+	Games int
+
+	// Matchups are the matchup frequencies
+	Matchups []*statsCount
+
+	// Maps is the map popularity
+	Maps []*statsCount
+
+	// Races are the win rates of races
+	Races []*raceStats
+
+	// APMPercentiles are the percentiles of the APM of (non-observer) players
+	APMPercentiles []*statsPercentile
+
+	// Lengths are the game length distribution in 10 minute buckets
+	Lengths []*statsCount
+
+	// MedianLength is the median game length
+	MedianLength string
+}
+
+// statsCount is the number of occurrences of a value.
+type statsCount struct {
+	Name    string
+	Count   int
+	Percent float64
+}
+
+// raceStats are the statistics of a race.
+type raceStats struct {
+	Race string
+
+	// Games is the number of times the race was played in games having a known winner,
+	// Wins is the number of those won
+	Games, Wins int
+
+	WinRate float64
+}
+
+// statsPercentile is a percentile value.
+type statsPercentile struct {
+	Percentile int
+	Value      int32
+}
+
+// apmPercentiles are the percentiles reported in the statistics.
+var apmPercentiles = []int{10, 25, 50, 75, 90}
+
+// lengthBucket is the size of game length buckets.
+const lengthBucket = 10 * time.Minute
+
+// computeStats computes the statistics of the given games.
+func computeStats(games []*repindex.Game) *corpusStats {
+	st := &corpusStats{Games: len(games)}
+
+	counts := func(key func(g *repindex.Game) string) []*statsCount {
+		m := map[string]*statsCount{}
+		var list []*statsCount
+		for _, g := range games {
+			k := key(g)
+			c := m[k]
+			if c == nil {
+				c = &statsCount{Name: k}
+				m[k] = c
+				list = append(list, c)
+			}
+			c.Count++
+		}
+		for _, c := range list {
+			c.Percent = percent(c.Count, len(games))
+		}
+		return list
+	}
+	byCount := func(a, b *statsCount) int {
+		if c := cmp.Compare(b.Count, a.Count); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Name, b.Name)
+	}
+
+	st.Matchups = counts(func(g *repindex.Game) string { return g.Matchup })
+	slices.SortFunc(st.Matchups, byCount)
+	st.Maps = counts(func(g *repindex.Game) string { return g.Map })
+	slices.SortFunc(st.Maps, byCount)
+
+	// Bucket keys are made sortable by the bucket start
+	st.Lengths = counts(func(g *repindex.Game) string {
+		start := g.Frames.Duration() / lengthBucket * lengthBucket
+		return fmt.Sprintf("%02d-%02d min", int(start.Minutes()), int((start + lengthBucket).Minutes()))
+	})
+	slices.SortFunc(st.Lengths, func(a, b *statsCount) int { return cmp.Compare(a.Name, b.Name) })
+
+	races := map[string]*raceStats{}
+	var apms []int32
+	var lengths []time.Duration
+	for _, g := range games {
+		lengths = append(lengths, g.Frames.Duration())
+		for _, p := range g.Players {
+			if p.Observer {
+				continue
+			}
+			apms = append(apms, p.APM)
+			if g.WinnerTeam == 0 {
+				continue
+			}
+			rs := races[p.Race]
+			if rs == nil {
+				rs = &raceStats{Race: p.Race}
+				races[p.Race] = rs
+				st.Races = append(st.Races, rs)
+			}
+			rs.Games++
+			if p.Winner {
+				rs.Wins++
+			}
+		}
+	}
+	for _, rs := range st.Races {
+		rs.WinRate = percent(rs.Wins, rs.Games)
+	}
+	slices.SortFunc(st.Races, func(a, b *raceStats) int { return cmp.Compare(a.Race, b.Race) })
+
+	if len(apms) > 0 {
+		slices.Sort(apms)
+		for _, p := range apmPercentiles {
+			st.APMPercentiles = append(st.APMPercentiles, &statsPercentile{Percentile: p, Value: apms[(len(apms)-1)*p/100]})
+		}
+	}
+	if len(lengths) > 0 {
+		slices.Sort(lengths)
+		st.MedianLength = lengths[(len(lengths)-1)/2].Truncate(time.Second).String()
+	}
+
+	return st
+}
+
+// percent returns n in percentage of total, rounded to 2 decimal places.
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n*10000/total) / 100
+}
+
+// writeStatsCSV writes the statistics in CSV format, 1 row per value:
+// the columns are the category, the name of the value, the count and the value.
+func writeStatsCSV(w io.Writer, st *corpusStats) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"category", "name", "count", "value"})
+	cw.Write([]string{"games", "", strconv.Itoa(st.Games), ""})
+	writeCounts := func(category string, counts []*statsCount) {
+		for _, c := range counts {
+			cw.Write([]string{category, c.Name, strconv.Itoa(c.Count), fmt.Sprint(c.Percent)})
+		}
+	}
+	writeCounts("matchup", st.Matchups)
+	writeCounts("map", st.Maps)
+	for _, rs := range st.Races {
+		cw.Write([]string{"race_winrate", rs.Race, strconv.Itoa(rs.Games), fmt.Sprint(rs.WinRate)})
+	}
+	for _, p := range st.APMPercentiles {
+		cw.Write([]string{"apm_percentile", strconv.Itoa(p.Percentile), "", fmt.Sprint(p.Value)})
+	}
+	writeCounts("length", st.Lengths)
+	cw.Write([]string{"median_length", "", "", st.MedianLength})
+	cw.Flush()
+	return cw.Error()
+}
+
+// stats runs the stats subcommand.
+func stats(args []string) {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	recursive := fs.Bool("r", false, "search replays recursively in the subfolders of folder arguments")
+	indexFile := fs.String("index", "", "optional index file to use (and update), see the index subcommand")
+	csvOut := fs.Bool("csv", false, "print the statistics in CSV format instead of JSON")
+	fs.Usage = func() {
+		fmt.Println("Usage:")
+		fmt.Printf("\t%s stats [FLAGS] replayfolder...\n", os.Args[0])
+		fmt.Println("\tReplays of the same game are counted once.")
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(ExitCodeMissingArguments)
+	}
+
+	games := corpusGames(fs.Args(), *recursive, *indexFile)
+	st := computeStats(games)
+
+	if *csvOut {
+		if err := writeStatsCSV(os.Stdout, st); err != nil {
+			fmt.Printf("Failed to write CSV output: %v\n", err)
+		}
+		return
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(st); err != nil {
+		fmt.Printf("Failed to encode output: %v\n", err)
+	}
+}
+
+// corpusGames returns the games of the replays in the given folders.
+// If indexFile is not empty, the index file is updated and used, else replays are indexed in memory.
+func corpusGames(folders []string, recursive bool, indexFile string) []*repindex.Game {
+	idx := repindex.New()
+	if indexFile != "" {
+		idx = loadIndex(indexFile)
+	}
+	if _, err := idx.Update(folders, recursive); err != nil {
+		fmt.Printf("Failed to index replays: %v\n", err)
+		os.Exit(ExitCodeMissingArguments)
+	}
+	if indexFile != "" {
+		if err := idx.Save(indexFile); err != nil {
+			fmt.Printf("Failed to save index: %v\n", err)
+			os.Exit(ExitCodeFailedToCreateOutputFile)
+		}
+	}
+
+	// The index may hold replays of other folders too
+	return idx.Query(repindex.Query{Folders: folders, Recursive: recursive})
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/icza/screp/rep/repcore"
+	"github.com/icza/screp/repindex"
+)
+
+func TestComputeStats(t *testing.T) {
+	minute := repcore.Duration2Frame(time.Minute)
+	game := func(matchup, mapName string, frames repcore.Frame, p1, p2 *repindex.Player) *repindex.Game {
+		return &repindex.Game{Matchup: matchup, Map: mapName, Frames: frames, WinnerTeam: 1, Players: []*repindex.Player{p1, p2}}
+	}
+	t1 := &repindex.Player{Race: "Terran", APM: 100, Winner: true}
+	z2 := &repindex.Player{Race: "Zerg", APM: 200}
+	p1 := &repindex.Player{Race: "Protoss", APM: 300, Winner: true}
+	t2 := &repindex.Player{Race: "Terran", APM: 400}
+
+	st := computeStats([]*repindex.Game{
+		game("TvZ", "Fighting Spirit", 5*minute, t1, z2),
+		game("PvT", "Fighting Spirit", 15*minute, p1, t2),
+		game("TvZ", "Circuit Breaker", 16*minute, t1, z2),
+	})
+
+	if st.Games != 3 {
+		t.Errorf("Expected: %v, got: %v", 3, st.Games)
+	}
+	if m := st.Matchups[0]; m.Name != "TvZ" || m.Count != 2 || m.Percent != 66.66 {
+		t.Errorf("Expected: %v, got: %+v", "TvZ 2 66.66", m)
+	}
+	if m := st.Maps[0]; m.Name != "Fighting Spirit" || m.Count != 2 {
+		t.Errorf("Expected: %v, got: %+v", "Fighting Spirit 2", m)
+	}
+	if rs := st.Races[1]; rs.Race != "Terran" || rs.Games != 3 || rs.Wins != 2 {
+		t.Errorf("Expected: %v, got: %+v", "Terran 3 2", rs)
+	}
+	if p := st.APMPercentiles[2]; p.Percentile != 50 || p.Value != 200 {
+		t.Errorf("Expected: %v, got: %+v", "50 200", p)
+	}
+	if l := st.Lengths[1]; l.Name != "10-20 min" || l.Count != 2 {
+		t.Errorf("Expected: %v, got: %+v", "10-20 min 2", l)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := writeStatsCSV(buf, st); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if exp := "matchup,TvZ,2,66.66\n"; !strings.Contains(buf.String(), exp) {
+		t.Errorf("Expected: %v, got: %v", exp, buf)
+	}
+}
@@ -0,0 +1,95 @@
+// This file contains the info, cmds, map and export subcommands,
+// which are focused views of the default replay processing.
+//
+// Each subcommand has its own flag set, holding only the flags it uses (see the add*Flags() functions),
+// so they behave exactly like the equivalent flag combinations of the default command.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// newSubcommand creates the flag set of a subcommand having the input and output flags.
+func newSubcommand(name, argsUsage, desc string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	addInputFlags(fs)
+	addLogFlags(fs)
+	addOutputFlags(fs)
+	fs.Usage = func() {
+		fmt.Println("Usage:")
+		fmt.Printf("\t%s %s [FLAGS] %s\n", os.Args[0], name, argsUsage)
+		fmt.Printf("\t%s\n", desc)
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// runSubcommand parses the arguments of a subcommand, and runs the replay processing.
+func runSubcommand(fs *flag.FlagSet, args []string) {
+	fs.Parse(args)
+
+	if fs.NArg() == 0 && !stdin {
+		fs.Usage()
+		os.Exit(ExitCodeMissingArguments)
+	}
+
+	run(fs.Args())
+}
+
+// info runs the info subcommand.
+func info(args []string) {
+	fs := newSubcommand("info", "repfiles...", "Prints the header and computed data of replays (same as running without a subcommand).")
+	addInfoFlags(fs)
+	addMapDataHashFlag(fs)
+	runSubcommand(fs, args)
+}
+
+// cmdsCmd runs the cmds subcommand.
+func cmdsCmd(args []string) {
+	cmds, header = true, false
+	fs := newSubcommand("cmds", "repfiles...", "Prints the player commands of replays.")
+	addCmdsFormatFlag(fs, "format")
+	runSubcommand(fs, args)
+}
+
+// mapCmd runs the map subcommand.
+func mapCmd(args []string) {
+	mapData, header = true, false
+	fs := newSubcommand("map", "repfiles...", "Prints the map data and map analysis of replays.")
+	addMapOutputFlags(fs, "")
+	addMapFlags(fs, "")
+	addMapDataHashFlag(fs)
+	runSubcommand(fs, args)
+}
+
+// export runs the export subcommand.
+func export(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	chk := fs.Bool("chk", false, "export the raw map data (CHK) instead of a playable map file")
+	fs.Usage = func() {
+		fmt.Println("Usage:")
+		fmt.Printf("\t%s export [FLAGS] repfile.rep output.scx\n", os.Args[0])
+		fmt.Println("\tExports the map of a replay as a playable map file (*.scm / *.scx) or as raw map data.")
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(ExitCodeMissingArguments)
+	}
+
+	if *chk {
+		dumpMapData = true
+	} else {
+		exportMap = true
+	}
+	outFile = fs.Arg(1)
+
+	run(fs.Args()[:1])
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestSubcommandFlags(t *testing.T) {
+	defer func() { mapTiles, indent = false, true }()
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	addMapOutputFlags(fs, "")
+	addOutputFlags(fs)
+	if err := fs.Parse([]string{"-tiles", "-indent=false", "a.rep"}); err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	if !mapTiles || indent {
+		t.Errorf("Expected: %v %v, got: %v %v", true, false, mapTiles, indent)
+	}
+
+	// Flags of other commands are not registered:
+	if err := fs.Parse([]string{"-overview", "a.rep"}); err == nil {
+		t.Errorf("Expected error for unregistered flag")
+	}
+}
+
+func TestSubcommand(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+	if err := os.WriteFile("map", nil, 0o644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	cases := []struct {
+		args []string
+		exp  bool // Tells if a subcommand is expected
+	}{
+		{nil, false},
+		{[]string{"info", "a.rep"}, true},
+		{[]string{"a.rep"}, false},
+		{[]string{"-cmds", "a.rep"}, false},
+		{[]string{"map"}, false}, // Existing file named like the subcommand
+	}
+	for _, c := range cases {
+		if got := subcommand(c.args) != nil; got != c.exp {
+			t.Errorf("[%v] Expected: %v, got: %v", c.args, c.exp, got)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+// This file contains the Go template output.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/icza/screp/rep"
+)
+
+// newTemplate parses the value of the template flag: the template text,
+// or the name of the template file prefixed with '@'.
+func newTemplate(s string) (*template.Template, error) {
+	if name, ok := strings.CutPrefix(s, "@"); ok {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		s = string(data)
+	}
+
+	return template.New("output").Parse(s)
+}
+
+// executeTemplate renders a replay through the template.
+// The template context is the replay output (rep.Replay fields are promoted),
+// the replay is computed.
+// A newline is appended if the rendered text does not end with one.
+func executeTemplate(w io.Writer, t *template.Template, file string, r *rep.Replay) error {
+	computeReplay(r)
+
+	buf := &bytes.Buffer{}
+	if err := t.Execute(buf, &output{File: file, Replay: r}); err != nil {
+		return err
+	}
+	if !bytes.HasSuffix(buf.Bytes(), []byte{'\n'}) {
+		buf.WriteByte('\n')
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
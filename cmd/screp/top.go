@@ -0,0 +1,221 @@
+// This file contains the top subcommand which computes a player leaderboard of a replay corpus.
+
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/icza/screp/repindex"
+)
+
+// playerAggr are the aggregated statistics of a player.
+type playerAggr struct {
+	Name string
+
+	// Games is the number of games played (not as an observer),
+	// Decided is the number of those having a known winner, Wins is the number of those won
+	Games, Decided, Wins int
+
+	WinRate float64
+
+	AvgAPM, AvgEAPM int32
+
+	FavoriteRace, FavoriteMap string
+
+	apmSum, eapmSum int64
+	races, maps     map[string]int
+}
+
+// aliasFunc maps a player name to the canonical name of the player,
+// so statistics of the aliases (e.g. different accounts) of a player are merged.
+type aliasFunc func(name string) string
+
+// parseAliases parses player aliases, 1 player per line in the form of:
+//
+//	Name = alias1, alias2, ...
+//
+// Empty lines and lines starting with '#' are ignored. Names are matched case-insensitively.
+func parseAliases(r io.Reader) (aliasFunc, error) {
+	canonical := map[string]string{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		name, aliases, ok := strings.Cut(text, "=")
+		if name = strings.TrimSpace(name); !ok || name == "" {
+			return nil, fmt.Errorf("invalid alias definition in line %d: %q", line, text)
+		}
+		for _, alias := range strings.Split(aliases, ",") {
+			if alias = strings.TrimSpace(alias); alias != "" {
+				canonical[strings.ToLower(alias)] = name
+			}
+		}
+		canonical[strings.ToLower(name)] = name
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return func(name string) string {
+		if c, ok := canonical[strings.ToLower(name)]; ok {
+			return c
+		}
+		return name
+	}, nil
+}
+
+// Leaderboard sort orders
+const (
+	topSortGames   = "games"
+	topSortWinRate = "winrate"
+	topSortAPM     = "apm"
+)
+
+const validTopSorts = "valid values are 'games', 'winrate', 'apm'"
+
+// computeLeaderboard computes the per-player aggregates of the games.
+// Players are merged by their names (case-insensitively) after applying alias (if not nil).
+// Players having less than minGames games are excluded.
+func computeLeaderboard(games []*repindex.Game, alias aliasFunc, minGames int, sortBy string) []*playerAggr {
+	m := map[string]*playerAggr{}
+	var list []*playerAggr
+	for _, g := range games {
+		for _, p := range g.Players {
+			if p.Observer {
+				continue
+			}
+			name := p.Name
+			if alias != nil {
+				name = alias(name)
+			}
+			pa := m[strings.ToLower(name)]
+			if pa == nil {
+				pa = &playerAggr{Name: name, races: map[string]int{}, maps: map[string]int{}}
+				m[strings.ToLower(name)] = pa
+				list = append(list, pa)
+			}
+			pa.Games++
+			if g.WinnerTeam != 0 {
+				pa.Decided++
+				if p.Winner {
+					pa.Wins++
+				}
+			}
+			pa.apmSum += int64(p.APM)
+			pa.eapmSum += int64(p.EAPM)
+			pa.races[p.Race]++
+			pa.maps[g.Map]++
+		}
+	}
+
+	list = slices.DeleteFunc(list, func(pa *playerAggr) bool { return pa.Games < minGames })
+	for _, pa := range list {
+		pa.WinRate = percent(pa.Wins, pa.Decided)
+		pa.AvgAPM = int32(pa.apmSum / int64(pa.Games))
+		pa.AvgEAPM = int32(pa.eapmSum / int64(pa.Games))
+		pa.FavoriteRace, pa.FavoriteMap = favorite(pa.races), favorite(pa.maps)
+	}
+
+	slices.SortFunc(list, func(a, b *playerAggr) int {
+		var c int
+		switch sortBy {
+		case topSortWinRate:
+			c = cmp.Compare(b.WinRate, a.WinRate)
+		case topSortAPM:
+			c = cmp.Compare(b.AvgAPM, a.AvgAPM)
+		}
+		if c == 0 {
+			c = cmp.Compare(b.Games, a.Games)
+		}
+		if c == 0 {
+			c = cmp.Compare(a.Name, b.Name)
+		}
+		return c
+	})
+	return list
+}
+
+// favorite returns the key having the highest count (the first in alphabetical order in case of a tie).
+func favorite(counts map[string]int) (fav string) {
+	maxCount := 0
+	for k, c := range counts {
+		if c > maxCount || c == maxCount && k < fav {
+			fav, maxCount = k, c
+		}
+	}
+	return
+}
+
+// top runs the top subcommand.
+func top(args []string) {
+	fs := flag.NewFlagSet("top", flag.ExitOnError)
+	recursive := fs.Bool("r", false, "search replays recursively in the subfolders of folder arguments")
+	indexFile := fs.String("index", "", "optional index file to use (and update), see the index subcommand")
+	aliasesFile := fs.String("aliases", "", "optional file of player aliases to merge, 1 player per line in the form of\n'Name = alias1, alias2'")
+	sortBy := fs.String("sort", topSortGames, "order of the players;\n"+validTopSorts)
+	minGames := fs.Int("min", 1, "minimum number of games of listed players")
+	limit := fs.Int("n", 20, "max number of players to list (0 means no limit)")
+	jsonOut := fs.Bool("json", false, "print the leaderboard in JSON format")
+	fs.Usage = func() {
+		fmt.Println("Usage:")
+		fmt.Printf("\t%s top [FLAGS] replayfolder...\n", os.Args[0])
+		fmt.Println("\tPrints a leaderboard of players. Replays of the same game are counted once.")
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(ExitCodeMissingArguments)
+	}
+	switch *sortBy {
+	case topSortGames, topSortWinRate, topSortAPM:
+	default:
+		fmt.Printf("Invalid sort: %v\n", *sortBy)
+		fmt.Println(validTopSorts)
+		os.Exit(ExitCodeInvalidFormat)
+	}
+
+	var alias aliasFunc
+	if *aliasesFile != "" {
+		f, err := os.Open(*aliasesFile)
+		if err == nil {
+			alias, err = parseAliases(f)
+			f.Close()
+		}
+		if err != nil {
+			fmt.Printf("Failed to load aliases: %v\n", err)
+			os.Exit(ExitCodeInvalidFormat)
+		}
+	}
+
+	board := computeLeaderboard(corpusGames(fs.Args(), *recursive, *indexFile), alias, *minGames, *sortBy)
+	if *limit > 0 && len(board) > *limit {
+		board = board[:*limit]
+	}
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(board); err != nil {
+			fmt.Printf("Failed to encode output: %v\n", err)
+		}
+		return
+	}
+
+	fmt.Println("  # Name                  Games  Win%   APM  EAPM  Race     Map")
+	for i, pa := range board {
+		fmt.Printf("%3d %-20s %6d %5.1f %5d %5d  %-7s  %s\n", i+1, pa.Name, pa.Games, pa.WinRate, pa.AvgAPM, pa.AvgEAPM, pa.FavoriteRace, pa.FavoriteMap)
+	}
+}
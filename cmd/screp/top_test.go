@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/icza/screp/repindex"
+)
+
+func TestParseAliases(t *testing.T) {
+	alias, err := parseAliases(strings.NewReader("# Comment\n\nFlash = FlaSh, ShinyFlash\nJaedong=\n"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	cases := []struct{ name, exp string }{
+		{"flash", "Flash"},
+		{"SHINYFLASH", "Flash"},
+		{"jaedong", "Jaedong"},
+		{"Bisu", "Bisu"},
+	}
+	for _, c := range cases {
+		if got := alias(c.name); got != c.exp {
+			t.Errorf("[%s] Expected: %v, got: %v", c.name, c.exp, got)
+		}
+	}
+
+	if _, err := parseAliases(strings.NewReader("no equal sign")); err == nil {
+		t.Errorf("Expected: %v, got: %v", "error", err)
+	}
+}
+
+func TestComputeLeaderboard(t *testing.T) {
+	game := func(mapName string, winnerTeam byte, players ...*repindex.Player) *repindex.Game {
+		for _, p := range players {
+			p.Winner = winnerTeam != 0 && p.Team == winnerTeam
+		}
+		return &repindex.Game{Map: mapName, WinnerTeam: winnerTeam, Players: players}
+	}
+	games := []*repindex.Game{
+		game("Fighting Spirit", 1,
+			&repindex.Player{Name: "Flash", Race: "Terran", Team: 1, APM: 300, EAPM: 200},
+			&repindex.Player{Name: "Jaedong", Race: "Zerg", Team: 2, APM: 400, EAPM: 300}),
+		game("Circuit Breaker", 2,
+			&repindex.Player{Name: "ShinyFlash", Race: "Terran", Team: 1, APM: 100, EAPM: 100},
+			&repindex.Player{Name: "Bisu", Race: "Protoss", Team: 2, APM: 350, EAPM: 250},
+			&repindex.Player{Name: "Obs", Team: 3, Observer: true}),
+		game("Fighting Spirit", 0,
+			&repindex.Player{Name: "flash", Race: "Protoss", Team: 1, APM: 200, EAPM: 150}),
+	}
+	alias, _ := parseAliases(strings.NewReader("Flash = ShinyFlash"))
+
+	board := computeLeaderboard(games, alias, 1, topSortGames)
+	if len(board) != 3 {
+		t.Fatalf("Expected: %v, got: %v", 3, len(board))
+	}
+	pa := board[0]
+	if pa.Name != "Flash" || pa.Games != 3 || pa.Decided != 2 || pa.Wins != 1 || pa.WinRate != 50 ||
+		pa.AvgAPM != 200 || pa.AvgEAPM != 150 || pa.FavoriteRace != "Terran" || pa.FavoriteMap != "Fighting Spirit" {
+		t.Errorf("Expected: %v, got: %+v", "Flash aggregates", pa)
+	}
+
+	// Names are merged case-insensitively even without aliases
+	board = computeLeaderboard(games, nil, 2, topSortAPM)
+	if len(board) != 1 || board[0].Games != 2 {
+		t.Errorf("Expected: %v, got: %v", "Flash with 2 games", board)
+	}
+
+	board = computeLeaderboard(games, alias, 1, topSortAPM)
+	if names := board[0].Name + "," + board[1].Name; names != "Jaedong,Bisu" {
+		t.Errorf("Expected: %v, got: %v", "Jaedong,Bisu", names)
+	}
+}
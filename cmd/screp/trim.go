@@ -0,0 +1,68 @@
+// This file contains the trim subcommand which writes a truncated copy of a replay.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/icza/screp/rep/repcore"
+	"github.com/icza/screp/repparser"
+)
+
+// trim runs the trim subcommand.
+func trim(args []string) {
+	fs := flag.NewFlagSet("trim", flag.ExitOnError)
+	from := fs.String("from", "0", "start of the game time range to keep;\n"+
+		"only 0 is supported: playback simulates the game from the start, so earlier commands cannot be dropped")
+	to := fs.String("to", "", "end of the game time range to keep, e.g. \"12:00\" or \"12m\" (required)")
+	fs.Usage = func() {
+		fmt.Println("Usage:")
+		fmt.Printf("\t%s trim [FLAGS] repfile.rep output.rep\n", os.Args[0])
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 2 || *to == "" {
+		fs.Usage()
+		os.Exit(ExitCodeMissingArguments)
+	}
+
+	fromFrame, err := repcore.ParseFrame(*from)
+	if err == nil && fromFrame != 0 {
+		err = errors.New("only 0 is supported as -from")
+	}
+	var toFrame repcore.Frame
+	if err == nil {
+		toFrame, err = repcore.ParseFrame(*to)
+	}
+	if err != nil {
+		fmt.Println(err)
+		fs.Usage()
+		os.Exit(ExitCodeMissingArguments)
+	}
+
+	repData, err := readReplayArg(fs.Arg(0))
+	if err != nil {
+		fmt.Printf("Failed to read replay: %v\n", err)
+		os.Exit(ExitCodeFailedToParseReplay)
+	}
+
+	trimmed, err := repparser.Trim(repData, toFrame)
+	if err == nil {
+		// Verify the result is a valid replay:
+		_, err = repparser.Parse(trimmed)
+	}
+	if err != nil {
+		fmt.Printf("Failed to trim replay: %v\n", err)
+		os.Exit(ExitCodeFailedToParseReplay)
+	}
+
+	if err := os.WriteFile(fs.Arg(1), trimmed, 0644); err != nil {
+		fmt.Printf("Failed to write output file: %v\n", err)
+		os.Exit(ExitCodeFailedToCreateOutputFile)
+	}
+}
@@ -0,0 +1,75 @@
+// This file contains the watch mode which processes new replays as they appear.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// watchReplays watches the given folders, and calls process for each new replay
+// appearing in them. Replays present when watching starts are not processed.
+//
+// Folders are polled in the given interval. A new replay is considered complete
+// (and is processed) when its size did not change between 2 polls.
+//
+// watchReplays returns when stop is closed (a nil stop means to watch forever).
+func watchReplays(dirs []string, recursive bool, interval time.Duration, stop <-chan struct{}, process func(name string)) {
+	list := func() []string {
+		files, _, err := replayFiles(dirs, recursive)
+		if err != nil {
+			fmt.Printf("Failed to list replay files: %v\n", err)
+		}
+		return files
+	}
+
+	seen := map[string]bool{}
+	for _, name := range list() {
+		seen[name] = true
+	}
+	pending := map[string]int64{} // Last seen size of new replays
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		for _, name := range list() {
+			if seen[name] {
+				continue
+			}
+			fi, err := os.Stat(name)
+			if err != nil {
+				continue
+			}
+			if size, ok := pending[name]; ok && size == fi.Size() && size > 0 {
+				delete(pending, name)
+				seen[name] = true
+				process(name)
+				continue
+			}
+			pending[name] = fi.Size()
+		}
+	}
+}
+
+// runCommand runs the given command with the replay file name appended as the last argument.
+// The command's output is passed through.
+func runCommand(command, name string) error {
+	parts := strings.Fields(command)
+	if len(parts) == 0 {
+		return errors.New("empty command")
+	}
+	cmd := exec.Command(parts[0], append(parts[1:], name)...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	return cmd.Run()
+}
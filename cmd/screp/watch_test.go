@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchReplays(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "existing.rep")
+	if err := os.WriteFile(existing, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	stop, done := make(chan struct{}), make(chan struct{})
+	processed := make(chan string, 10)
+	go func() {
+		watchReplays([]string{dir}, false, 5*time.Millisecond, stop, func(name string) { processed <- name })
+		close(done)
+	}()
+
+	time.Sleep(20 * time.Millisecond) // Let watching start
+	newRep := filepath.Join(dir, "new.rep")
+	if err := os.WriteFile(newRep, []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "other.txt"), []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case name := <-processed:
+		if name != newRep {
+			t.Errorf("Expected: %v, got: %v", newRep, name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timeout waiting for new replay")
+	}
+
+	close(stop)
+	<-done
+	if len(processed) != 0 {
+		t.Errorf("Expected: %v more processed, got: %v", 0, len(processed))
+	}
+}
@@ -0,0 +1,79 @@
+// screplib is a C shared library of screp, exposing the replay parser through a minimal C ABI,
+// so bindings can be written in other languages (e.g. Python, C#, Rust).
+//
+// Build it with:
+//
+//	go build -buildmode=c-shared -o libscrep.so ./cmd/screplib
+//
+// (use the .dll or .dylib extension on Windows and macOS). This also generates the libscrep.h header.
+//
+// Exported functions:
+//
+//	// screp_parse parses size bytes of replay data, and returns the JSON encoding of the replay.
+//	// options is an optional, NUL-terminated JSON object of parsing options (the fields of repwasm.Options),
+//	// it may be NULL. On error NULL is returned, and if err is not NULL, *err is set to the error message.
+//	char* screp_parse(void* data, int size, char* options, char** err);
+//
+//	// screp_free frees a string returned by screp_parse (the result or the error message).
+//	void screp_free(char* s);
+//
+// Example using it from Python:
+//
+//	import ctypes, json
+//
+//	lib = ctypes.CDLL("./libscrep.so")
+//	lib.screp_parse.restype = ctypes.c_void_p
+//	data = open("sample.rep", "rb").read()
+//	err = ctypes.c_void_p()
+//	res = lib.screp_parse(data, len(data), b'{"camelCase":true}', ctypes.byref(err))
+//	if not res:
+//	    msg = ctypes.string_at(err.value).decode()
+//	    lib.screp_free(err)
+//	    raise Exception(msg)
+//	replay = json.loads(ctypes.string_at(res))
+//	lib.screp_free(ctypes.c_void_p(res))
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"unsafe"
+
+	"github.com/icza/screp/repwasm"
+)
+
+//export screp_parse
+func screp_parse(data unsafe.Pointer, size C.int, options *C.char, err **C.char) *C.char {
+	res, e := parse(C.GoBytes(data, size), options)
+	if e != nil {
+		if err != nil {
+			*err = C.CString(e.Error())
+		}
+		return nil
+	}
+	return (*C.char)(C.CBytes(append(res, 0)))
+}
+
+//export screp_free
+func screp_free(s *C.char) {
+	C.free(unsafe.Pointer(s))
+}
+
+// parse parses the replay data with the given (optional) options.
+func parse(repData []byte, options *C.char) ([]byte, error) {
+	var optsJSON []byte
+	if options != nil {
+		optsJSON = []byte(C.GoString(options))
+	}
+	opts, err := repwasm.ParseOptions(optsJSON)
+	if err != nil {
+		return nil, err
+	}
+	return repwasm.Parse(repData, opts)
+}
+
+// main is required by the c-shared build mode, it is not called.
+func main() {}
@@ -0,0 +1,20 @@
+//go:build js && wasm
+
+// screpwasm is the WebAssembly module of screp, exposing the replay parser to JavaScript.
+//
+// Build it with:
+//
+//	GOOS=js GOARCH=wasm go build -o screp.wasm ./cmd/screpwasm
+//
+// and load it with the repwasm/screp.js wrapper (which requires the wasm_exec.js
+// support file of the Go distribution, found in $(go env GOROOT)/lib/wasm/).
+package main
+
+import "github.com/icza/screp/repwasm"
+
+func main() {
+	repwasm.Register()
+
+	// Keep the module alive so the registered functions remain callable.
+	select {}
+}
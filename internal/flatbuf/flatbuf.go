@@ -0,0 +1,194 @@
+// Package flatbuf implements a minimal FlatBuffers builder
+// (used by the repflatbuf and reparrow packages).
+package flatbuf
+
+import (
+	"encoding/binary"
+	"slices"
+)
+
+// Builder builds a FlatBuffers buffer.
+//
+// Unlike the official builders (which build buffers back to front), objects are written
+// front to back: a parent is written first (with placeholder offsets), then its children,
+// patching the offsets (which must point forward, to higher addresses). Vtables are written
+// right before their tables.
+type Builder struct {
+	buf []byte
+}
+
+// Object is an object that can be referenced by an offset: a table, a vector or a string.
+type Object interface {
+	// write writes the object and returns its position (the position offsets must point to).
+	write(b *Builder) int
+}
+
+// pad pads the buffer so that its length plus extra is a multiple of align.
+func (b *Builder) pad(align, extra int) {
+	for (len(b.buf)+extra)%align != 0 {
+		b.buf = append(b.buf, 0)
+	}
+}
+
+// writeRef writes a referenced object and patches the offset at pos to point to it.
+func (b *Builder) writeRef(pos int, o Object) {
+	target := o.write(b)
+	binary.LittleEndian.PutUint32(b.buf[pos:], uint32(target-pos))
+}
+
+// Finish writes the root table with the given file identifier (optional), and returns the buffer.
+func (b *Builder) Finish(root Object, identifier string) []byte {
+	b.buf = append(b.buf, 0, 0, 0, 0) // Root offset
+	b.buf = append(b.buf, identifier...)
+	b.writeRef(0, root)
+	return b.buf
+}
+
+// field is a field of a table: either a scalar or a reference to an object.
+type field struct {
+	// size of the scalar value in bytes (1, 2, 4 or 8), 0 for references
+	size int
+
+	// bits of the scalar value
+	bits uint64
+
+	// ref is the referenced object
+	ref Object
+}
+
+// Table is a FlatBuffers table. Fields are indexed by their IDs, absent fields are nil.
+type Table []*field
+
+// Set sets a scalar field having a default value of 0. Zero values are omitted.
+func (t *Table) Set(id, size int, bits uint64) {
+	if bits != 0 {
+		t.SetAlways(id, size, bits)
+	}
+}
+
+// SetAlways sets a scalar field even if it is zero (for fields having a non-zero default value).
+func (t *Table) SetAlways(id, size int, bits uint64) {
+	for len(*t) <= id {
+		*t = append(*t, nil)
+	}
+	(*t)[id] = &field{size: size, bits: bits}
+}
+
+// SetRef sets a reference field (o may be nil, in which case the field is absent).
+func (t *Table) SetRef(id int, o Object) {
+	if o == nil {
+		return
+	}
+	for len(*t) <= id {
+		*t = append(*t, nil)
+	}
+	(*t)[id] = &field{ref: o}
+}
+
+func (t Table) write(b *Builder) int {
+	// Lay out the fields after the vtable offset, largest first to minimize padding
+	order := make([]int, 0, len(t))
+	for id, f := range t {
+		if f != nil {
+			order = append(order, id)
+		}
+	}
+	slices.SortStableFunc(order, func(i, j int) int { return t[j].alignment() - t[i].alignment() })
+	offsets := make([]int, len(t))
+	size := 4
+	for _, id := range order {
+		a := t[id].alignment()
+		size = (size + a - 1) / a * a
+		offsets[id] = size
+		size += a
+	}
+
+	// vtable
+	vtSize := 4 + 2*len(t)
+	b.pad(2, 0)
+	b.pad(8, vtSize) // The table starts right after the vtable, 8-aligned
+	vtPos := len(b.buf)
+	b.buf = binary.LittleEndian.AppendUint16(b.buf, uint16(vtSize))
+	b.buf = binary.LittleEndian.AppendUint16(b.buf, uint16(size))
+	for _, off := range offsets {
+		b.buf = binary.LittleEndian.AppendUint16(b.buf, uint16(off))
+	}
+
+	// table
+	pos := len(b.buf)
+	b.buf = append(b.buf, make([]byte, size)...)
+	binary.LittleEndian.PutUint32(b.buf[pos:], uint32(int32(pos-vtPos)))
+	for _, id := range order {
+		if f := t[id]; f.ref == nil {
+			putScalar(b.buf[pos+offsets[id]:], f.size, f.bits)
+		}
+	}
+	for _, id := range order {
+		if f := t[id]; f.ref != nil {
+			b.writeRef(pos+offsets[id], f.ref)
+		}
+	}
+	return pos
+}
+
+// alignment returns the alignment (and size) of the inline value of the field.
+func (f *field) alignment() int {
+	if f.ref != nil {
+		return 4
+	}
+	return f.size
+}
+
+// putScalar puts a little-endian scalar value of the given size.
+func putScalar(b []byte, size int, bits uint64) {
+	for i := 0; i < size; i++ {
+		b[i] = byte(bits >> (8 * i))
+	}
+}
+
+// Str is a string object.
+type Str string
+
+func (s Str) write(b *Builder) int {
+	b.pad(4, 0)
+	pos := len(b.buf)
+	b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(s)))
+	b.buf = append(b.buf, s...)
+	b.buf = append(b.buf, 0) // Strings are zero-terminated
+	return pos
+}
+
+// InlineVector is a vector of scalars or structs, elements are stored inline.
+type InlineVector struct {
+	// Count is the number of elements
+	Count int
+
+	// Align is the alignment of the elements
+	Align int
+
+	// Data is the encoded elements
+	Data []byte
+}
+
+func (v *InlineVector) write(b *Builder) int {
+	b.pad(4, 0)
+	b.pad(v.Align, 4) // The elements follow the length
+	pos := len(b.buf)
+	b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(v.Count))
+	b.buf = append(b.buf, v.Data...)
+	return pos
+}
+
+// TableVector is a vector of tables.
+type TableVector []Table
+
+func (v TableVector) write(b *Builder) int {
+	b.pad(4, 0)
+	pos := len(b.buf)
+	b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(v)))
+	b.buf = append(b.buf, make([]byte, 4*len(v))...)
+	for i, t := range v {
+		b.writeRef(pos+4+4*i, t)
+	}
+	return pos
+}
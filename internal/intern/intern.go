@@ -0,0 +1,54 @@
+// Package intern implements interning of frequently repeated values produced during parsing
+// (used by the rep and repparser packages), so replays held in memory share them.
+//
+// The number of interned values is limited, values are not interned beyond the limit,
+// so crafted replays cannot grow the memory usage unbounded.
+package intern
+
+import "sync"
+
+// MaxSize is the max number of values interned by a Pool.
+const MaxSize = 1 << 16
+
+// Pool is a pool of interned values keyed by K.
+// The zero value is ready for use. A Pool is safe for concurrent use.
+type Pool[K comparable, V any] struct {
+	mu     sync.RWMutex
+	values map[K]V
+}
+
+// Get returns the interned value of key.
+// If there is no interned value yet, one is created by calling create.
+func (p *Pool[K, V]) Get(key K, create func(key K) V) V {
+	p.mu.RLock()
+	v, ok := p.values[key]
+	p.mu.RUnlock()
+	if ok {
+		return v
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if v, ok := p.values[key]; ok {
+		return v
+	}
+	v = create(key)
+	if len(p.values) < MaxSize {
+		if p.values == nil {
+			p.values = map[K]V{}
+		}
+		p.values[key] = v
+	}
+	return v
+}
+
+// stringPool is the pool of interned strings.
+var stringPool Pool[string, string]
+
+// String returns the interned s.
+func String(s string) string {
+	if s == "" {
+		return ""
+	}
+	return stringPool.Get(s, func(s string) string { return s })
+}
@@ -0,0 +1,34 @@
+package intern
+
+import (
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+func TestString(t *testing.T) {
+	s1 := String(strings.Repeat("a", 3))
+	s2 := String(strings.Repeat("a", 3))
+	if s1 != "aaa" || unsafe.StringData(s1) != unsafe.StringData(s2) {
+		t.Errorf("Expected same interned string, got: %q, %q", s1, s2)
+	}
+}
+
+func TestPool(t *testing.T) {
+	var p Pool[int, *int]
+	create := func(k int) *int { return &k }
+
+	if p1, p2 := p.Get(1, create), p.Get(1, create); p1 != p2 || *p1 != 1 {
+		t.Errorf("Expected same pointer, got: %p, %p", p1, p2)
+	}
+
+	for i := range MaxSize + 10 {
+		p.Get(i, create)
+	}
+	if len(p.values) != MaxSize {
+		t.Errorf("Expected: %v, got: %v", MaxSize, len(p.values))
+	}
+	if p1, p2 := p.Get(MaxSize+5, create), p.Get(MaxSize+5, create); p1 == p2 {
+		t.Errorf("Expected different pointers beyond max size")
+	}
+}
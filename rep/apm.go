@@ -0,0 +1,41 @@
+// This file contains the APM time series calculation.
+
+package rep
+
+import "github.com/icza/screp/rep/repcore"
+
+// APMSeries returns the APM and EAPM of a player over time, calculated in consecutive
+// windows of the given length (the last window may be shorter) covering the whole game.
+//
+// Commands must be parsed and the replay must be computed (so ineffective commands are known).
+func (r *Replay) APMSeries(playerID byte, window repcore.Frame) (apm, eapm []int32) {
+	if window <= 0 || r.Header.Frames <= 0 {
+		return nil, nil
+	}
+
+	n := int((r.Header.Frames + window - 1) / window)
+	cmdCounts, effCounts := make([]int, n), make([]int, n)
+	if r.Commands != nil {
+		for _, cmd := range r.Commands.Cmds {
+			base := cmd.BaseCmd()
+			if base.PlayerID != playerID {
+				continue
+			}
+			i := min(int(base.Frame/window), n-1)
+			cmdCounts[i]++
+			if base.IneffKind.Effective() {
+				effCounts[i]++
+			}
+		}
+	}
+
+	apm, eapm = make([]int32, n), make([]int32, n)
+	for i := range n {
+		length := min(window, r.Header.Frames-repcore.Frame(i)*window)
+		mins := length.Duration().Minutes()
+		apm[i] = int32(float64(cmdCounts[i])/mins + 0.5)
+		eapm[i] = int32(float64(effCounts[i])/mins + 0.5)
+	}
+
+	return
+}
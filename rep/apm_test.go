@@ -0,0 +1,43 @@
+package rep
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/icza/screp/rep/repcmd"
+	"github.com/icza/screp/rep/repcore"
+)
+
+func TestAPMSeries(t *testing.T) {
+	minute := repcore.Duration2Frame(60e9) // 1428 frames
+	var cmds []repcmd.Cmd
+	add := func(pid byte, frame repcore.Frame, ineff repcore.IneffKind) {
+		cmds = append(cmds, &repcmd.SelectCmd{Base: &repcmd.Base{PlayerID: pid, Frame: frame, IneffKind: ineff}})
+	}
+	// 1st minute: 30 commands, 10 ineffective
+	for i := range 30 {
+		var ineff repcore.IneffKind
+		if i%3 == 0 {
+			ineff = repcore.IneffKindFastRepetition
+		}
+		add(0, repcore.Frame(i), ineff)
+	}
+	add(1, 10, 0) // Other player
+	// 2nd minute: no commands. Last half minute: 5 commands.
+	for i := range 5 {
+		add(0, 2*minute+repcore.Frame(i), 0)
+	}
+
+	r := &Replay{
+		Header:   &Header{Frames: 2*minute + minute/2},
+		Commands: &Commands{Cmds: cmds},
+	}
+	apm, eapm := r.APMSeries(0, minute)
+	if got, exp := fmt.Sprint(apm, eapm), "[30 0 10] [20 0 10]"; got != exp {
+		t.Errorf("Expected: %v, got: %v", exp, got)
+	}
+
+	if apm, eapm := r.APMSeries(0, 0); apm != nil || eapm != nil {
+		t.Errorf("Expected: nil for invalid window, got: %v %v", apm, eapm)
+	}
+}
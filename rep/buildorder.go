@@ -0,0 +1,129 @@
+// This file contains the build order extraction.
+
+package rep
+
+import (
+	"github.com/icza/screp/rep/repcmd"
+	"github.com/icza/screp/rep/repcore"
+)
+
+// BuildOrderItem is an item of a player's build order.
+type BuildOrderItem struct {
+	// Frame at which the item was ordered
+	Frame repcore.Frame
+
+	// Supply is the estimated supply used by the player when the item was ordered
+	Supply int
+
+	// Name of the unit, building, tech or upgrade
+	Name string
+
+	// Cmd is the command that ordered the item
+	Cmd repcmd.Cmd `json:"-"`
+}
+
+// startSupply is the supply used by the 4 workers at the start of melee games.
+const startSupply = 4
+
+// unitIDSupply maps from unit ID to the supply change caused by producing the unit.
+// Zergling and Scourge values are for the pair hatching from a larva,
+// morphs (e.g. Lurker from Hydralisk) only count the difference.
+var unitIDSupply = map[uint16]int{
+	0x00: 1, // Marine
+	0x01: 1, // Ghost
+	0x02: 2, // Vulture
+	0x03: 2, // Goliath
+	0x05: 2, // Siege Tank
+	0x07: 1, // SCV
+	0x08: 2, // Wraith
+	0x09: 2, // Science Vessel
+	0x0B: 2, // Dropship
+	0x0C: 6, // Battlecruiser
+	0x20: 1, // Firebat
+	0x22: 1, // Medic
+	0x3A: 3, // Valkyrie
+
+	0x25: 1, // Zergling
+	0x26: 1, // Hydralisk
+	0x27: 4, // Ultralisk
+	0x29: 1, // Drone
+	0x2B: 2, // Mutalisk
+	0x2D: 2, // Queen
+	0x2E: 2, // Defiler
+	0x2F: 1, // Scourge
+	0x67: 1, // Lurker
+
+	0x40: 1, // Probe
+	0x41: 2, // Zealot
+	0x42: 2, // Dragoon
+	0x43: 2, // High Templar
+	0x3D: 2, // Dark Templar
+	0x45: 2, // Shuttle
+	0x53: 4, // Reaver
+	0x54: 1, // Observer
+	0x46: 3, // Scout
+	0x48: 6, // Carrier
+	0x47: 4, // Arbiter
+	0x3C: 2, // Corsair
+}
+
+// BuildOrder returns the build order of the player with the given ID:
+// the effective build, train, morph, tech and upgrade commands in order.
+//
+// Commands must be parsed and the replay must be computed (so ineffective commands are known).
+// Supply values are estimated from the ordered units (assuming a melee start),
+// they do not account for lost units or for orders that were never completed.
+func (r *Replay) BuildOrder(playerID byte) []*BuildOrderItem {
+	items := []*BuildOrderItem{}
+	if r.Commands == nil {
+		return items
+	}
+
+	supply := startSupply
+	for _, cmd := range r.Commands.Cmds {
+		base := cmd.BaseCmd()
+		if base.PlayerID != playerID || !base.IneffKind.Effective() {
+			continue
+		}
+
+		var name string
+		var delta int
+		switch x := cmd.(type) {
+		case *repcmd.BuildCmd:
+			if x.Unit == nil {
+				continue
+			}
+			name = x.Unit.Name
+			if x.Unit.ID >= repcmd.UnitIDHatchery && x.Unit.ID <= repcmd.UnitIDExtractor {
+				delta = -1 // Drone morphs into the building
+			}
+		case *repcmd.TrainCmd:
+			if x.Unit == nil {
+				continue
+			}
+			name, delta = x.Unit.Name, unitIDSupply[x.Unit.ID]
+		case *repcmd.BuildingMorphCmd:
+			if x.Unit == nil {
+				continue
+			}
+			name = x.Unit.Name
+		case *repcmd.TechCmd:
+			if x.Tech == nil {
+				continue
+			}
+			name = x.Tech.Name
+		case *repcmd.UpgradeCmd:
+			if x.Upgrade == nil {
+				continue
+			}
+			name = x.Upgrade.Name
+		default:
+			continue
+		}
+
+		items = append(items, &BuildOrderItem{Frame: base.Frame, Supply: supply, Name: name, Cmd: cmd})
+		supply += delta
+	}
+
+	return items
+}
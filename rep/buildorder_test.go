@@ -0,0 +1,42 @@
+package rep
+
+import (
+	"testing"
+
+	"github.com/icza/screp/rep/repcmd"
+	"github.com/icza/screp/rep/repcore"
+)
+
+func TestBuildOrder(t *testing.T) {
+	base := func(pid byte, frame repcore.Frame, ineff repcore.IneffKind) *repcmd.Base {
+		return &repcmd.Base{PlayerID: pid, Frame: frame, IneffKind: ineff}
+	}
+	r := &Replay{Commands: &Commands{Cmds: []repcmd.Cmd{
+		&repcmd.TrainCmd{Base: base(0, 10, 0), Unit: repcmd.UnitByID(0x29)}, // Drone
+		&repcmd.TrainCmd{Base: base(1, 12, 0), Unit: repcmd.UnitByID(0x40)}, // Probe of other player
+		&repcmd.TrainCmd{Base: base(0, 14, repcore.IneffKindFastRepetition), Unit: repcmd.UnitByID(0x29)},
+		&repcmd.SelectCmd{Base: base(0, 15, 0)},
+		&repcmd.BuildCmd{Base: base(0, 20, 0), Unit: repcmd.UnitByID(repcmd.UnitIDSpawningPool)},
+		&repcmd.TrainCmd{Base: base(0, 30, 0), Unit: repcmd.UnitByID(0x25)}, // Zergling
+		&repcmd.BuildingMorphCmd{Base: base(0, 40, 0), Unit: repcmd.UnitByID(repcmd.UnitIDLair)},
+		&repcmd.TrainCmd{Base: base(0, 50, 0), Unit: repcmd.UnitByID(0x29)}, // Drone
+	}}}
+
+	exp := []BuildOrderItem{
+		{Frame: 10, Supply: 4, Name: "Drone"},
+		{Frame: 20, Supply: 5, Name: "Spawning Pool"},
+		{Frame: 30, Supply: 4, Name: "Zergling"},
+		{Frame: 40, Supply: 5, Name: "Lair"},
+		{Frame: 50, Supply: 5, Name: "Drone"},
+	}
+
+	items := r.BuildOrder(0)
+	if len(items) != len(exp) {
+		t.Fatalf("Expected: %v items, got: %v", len(exp), len(items))
+	}
+	for i, item := range items {
+		if item.Frame != exp[i].Frame || item.Supply != exp[i].Supply || item.Name != exp[i].Name {
+			t.Errorf("[%d] Expected: %+v, got: %+v", i, exp[i], *item)
+		}
+	}
+}
@@ -2,7 +2,10 @@
 
 package rep
 
-import "github.com/icza/screp/rep/repcmd"
+import (
+	"github.com/icza/screp/rep/repcmd"
+	"github.com/icza/screp/rep/repcore"
+)
 
 // Commands contains the players' commands.
 type Commands struct {
@@ -14,12 +17,50 @@ type Commands struct {
 	// at the same frame.
 	ParseErrCmds []*repcmd.ParseErrCmd
 
+	// BlockStats holds statistics of the command blocks that were not parsed cleanly,
+	// nil if all command blocks were parsed cleanly.
+	BlockStats *CmdBlockStats `json:",omitempty"`
+
 	// Debug holds optional debug info.
 	Debug *CommandsDebug `json:"-"`
 }
 
+// Reset resets the commands to their zero value, retaining the capacity of the
+// Cmds and ParseErrCmds slices (their elements are cleared so they can be garbage collected).
+func (cs *Commands) Reset() {
+	clear(cs.Cmds)
+	clear(cs.ParseErrCmds)
+	*cs = Commands{Cmds: cs.Cmds[:0], ParseErrCmds: cs.ParseErrCmds[:0]}
+}
+
 // CommandsDebug holds debug info for the commands section.
 type CommandsDebug struct {
 	// Data is the raw, uncompressed data of the section.
 	Data []byte
+
+	// CmdOffsets holds the offsets of the commands of Cmds in Data.
+	CmdOffsets []uint32
+}
+
+// MaxCmdBlockStatsFrames is the max number of frames recorded in CmdBlockStats.Frames.
+const MaxCmdBlockStatsFrames = 100
+
+// CmdBlockStats holds statistics of command blocks that were not parsed cleanly:
+// where parsing a command read past the end of its block (an overrun), or left bytes
+// of the block unread. These may surface systematic mis-parses of specific command types.
+type CmdBlockStats struct {
+	// Overruns is the number of command blocks overrun
+	Overruns int
+
+	// UnreadBlocks is the number of command blocks having unread bytes,
+	// UnreadBytes is the total number of unread bytes
+	UnreadBlocks, UnreadBytes int
+
+	// TypeIDs counts the type IDs of the offending commands: the commands overrunning their block,
+	// and the commands after which bytes of their block were left unread.
+	TypeIDs map[byte]int
+
+	// Frames holds the frames of the affected command blocks
+	// (only the first MaxCmdBlockStatsFrames are recorded).
+	Frames []repcore.Frame
 }
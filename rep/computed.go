@@ -29,6 +29,56 @@ type Computed struct {
 	// Note: all computer players have ID=255, so this won't be accurate for
 	// computer players.
 	PIDPlayerDescs map[byte]*PlayerDesc `json:"-"`
+
+	// ObserverSlots contains the observers who issued commands, ordered by player ID.
+	// Observers have no PlayerDesc, see ObserverSlot.
+	ObserverSlots []*ObserverSlot `json:",omitempty"`
+
+	// PIDObserverSlots maps from player ID to ObserverSlot.
+	PIDObserverSlots map[byte]*ObserverSlot `json:"-"`
+
+	// Teams contains the teams of the game in team order.
+	// Empty if the replay has no players.
+	Teams []*Team `json:",omitempty"`
+
+	// MapAnalysis contains the start location and expansion analysis of the map.
+	// Only available if map data is parsed.
+	MapAnalysis *MapAnalysis `json:",omitempty"`
+}
+
+// Team describes a team of the game.
+type Team struct {
+	// ID of the team, matches Player.Team of its members.
+	ID byte
+
+	// Players of the team in team order.
+	Players []*Player `json:"-"`
+
+	// SlotIDs of the players of the team in team order.
+	SlotIDs []uint16
+
+	// Races of the players of the team in team order.
+	Races []*repcore.Race
+
+	// APM is the total APM of the players of the team.
+	APM int32
+
+	// Result of the team.
+	Result *repcore.Result
+
+	// Observers tells if the team consists of observers.
+	Observers bool
+
+	// Heuristic tells if the team setup was derived by team detection
+	// heuristics (e.g. from Alliance commands) instead of taken from the header.
+	// It is only true if team detection changed the team of at least one player:
+	// if detection ran but confirmed the header teams, it is false.
+	Heuristic bool
+}
+
+// Size returns the number of players in the team.
+func (t *Team) Size() int {
+	return len(t.Players)
 }
 
 // PlayerDesc contains computed / derived data for a player.
@@ -58,6 +108,9 @@ type PlayerDesc struct {
 	// compared to the center of the map, expressed using the clock,
 	// e.g. 1 o'clock, 6 o'clock etc.
 	StartDirection int32
+
+	// BuildOrder of the player, only computed if requested (see ComputeConfig)
+	BuildOrder []*BuildOrderItem `json:",omitempty"`
 }
 
 // Redundancy returns the redundancy percent of the player's commands.
@@ -68,3 +121,54 @@ func (pd *PlayerDesc) Redundancy() int {
 	}
 	return int(float64(pd.CmdCount-pd.EffectiveCmdCount)*100/float64(pd.CmdCount) + 0.5)
 }
+
+// ObserverPlayerIDBase is the player ID of the first observer in commands.
+// Commands of SC:R observers (e.g. chat) have player IDs starting at 128 (2nd observer 129 etc.),
+// which are not the IDs of any players of the header.
+const ObserverPlayerIDBase = 128
+
+// ObserverSlot contains computed / derived data for an observer identified by the player ID
+// of its commands (see ObserverPlayerIDBase).
+type ObserverSlot struct {
+	// PlayerID of the observer's commands.
+	PlayerID byte
+
+	// LastCmdFrame is the frame of the last command of the observer.
+	LastCmdFrame repcore.Frame
+
+	// CmdCount is the number of commands of the observer.
+	CmdCount uint32
+
+	// ChatCount is the number of chat commands of the observer (chat commands are
+	// owned by their receiver, so these are the messages received by the observer).
+	ChatCount uint32
+}
+
+// Index returns the 0-based index of the observer.
+func (s *ObserverSlot) Index() int {
+	return int(s.PlayerID) - ObserverPlayerIDBase
+}
+
+// addObserverCmd attributes a command of an observer to its ObserverSlot (created if needed).
+// Frames is the length of the game, later frames are not used as LastCmdFrame.
+func (c *Computed) addObserverCmd(cmd repcmd.Cmd, frames repcore.Frame) {
+	baseCmd := cmd.BaseCmd()
+	s := c.PIDObserverSlots[baseCmd.PlayerID]
+	if s == nil {
+		if c.PIDObserverSlots == nil {
+			c.PIDObserverSlots = map[byte]*ObserverSlot{}
+		}
+		s = &ObserverSlot{PlayerID: baseCmd.PlayerID}
+		c.PIDObserverSlots[baseCmd.PlayerID] = s
+		c.ObserverSlots = append(c.ObserverSlots, s)
+	}
+
+	s.CmdCount++
+	if _, ok := cmd.(*repcmd.ChatCmd); ok {
+		s.ChatCount++
+	}
+	// Bad parsing or corrupted replay may result in invalid frames, do not use such a bad frame:
+	if baseCmd.Frame <= frames && baseCmd.Frame >= 0 {
+		s.LastCmdFrame = baseCmd.Frame
+	}
+}
@@ -0,0 +1,280 @@
+// This file contains the normalized event stream.
+
+package rep
+
+import (
+	"slices"
+
+	"github.com/icza/screp/rep/repcmd"
+	"github.com/icza/screp/rep/repcore"
+)
+
+// EventKind is the kind of a normalized event.
+type EventKind string
+
+// Event kinds
+const (
+	// EventKindOrder is an order given to units (e.g. move, attack, stop, cast, siege)
+	EventKindOrder EventKind = "Order"
+
+	// EventKindBuild is the placement of a building
+	EventKindBuild EventKind = "Build"
+
+	// EventKindTrain is the training of a unit
+	EventKindTrain EventKind = "Train"
+
+	// EventKindMorph is the morph of a unit or a building
+	EventKindMorph EventKind = "Morph"
+
+	// EventKindResearch is the start of a tech research
+	EventKindResearch EventKind = "Research"
+
+	// EventKindUpgrade is the start of an upgrade
+	EventKindUpgrade EventKind = "Upgrade"
+
+	// EventKindCancel is the cancellation of a construction, morph, training, research, upgrade, addon or nuke
+	EventKindCancel EventKind = "Cancel"
+
+	// EventKindChat is a chat message
+	EventKindChat EventKind = "Chat"
+
+	// EventKindLeave is a player leaving the game
+	EventKindLeave EventKind = "Leave"
+)
+
+// Normalized orders of Order events. Other orders (e.g. spells) are named by their order names.
+const (
+	OrderMove   = "Move"
+	OrderAttack = "Attack"
+	OrderStop   = "Stop"
+	OrderHold   = "Hold"
+	OrderPatrol = "Patrol"
+	OrderRally  = "Rally"
+	OrderSmart  = "Smart" // Right click
+	OrderLand   = "Land"
+	OrderLift   = "Lift"
+)
+
+// Event is a normalized event of the game, decoupled from the encoding of commands:
+// selections are resolved, different commands of the same meaning are normalized to the same event.
+type Event struct {
+	// Frame at which the event occurred
+	Frame repcore.Frame
+
+	// PlayerID of the player causing the event
+	PlayerID byte
+
+	// Kind of the event
+	Kind EventKind
+
+	// Units are the tags of the units the event applies to: the selection of the player
+	// (tracked from the select and hotkey commands) at the time of the event.
+	Units []repcmd.UnitTag `json:",omitempty"`
+
+	// Order is the normalized order of Order events (one of the OrderXXX constants or an order name)
+	Order string `json:",omitempty"`
+
+	// Pos is the target position, if any
+	Pos *repcore.Point `json:",omitempty"`
+
+	// TargetUnit is the tag of the target unit, if any
+	TargetUnit repcmd.UnitTag `json:",omitempty"`
+
+	// Name of the built, trained or morphed unit, the researched tech or the upgrade,
+	// or the name of what is cancelled
+	Name string `json:",omitempty"`
+
+	// Queued tells if the order was queued
+	Queued bool `json:",omitempty"`
+
+	// Message of chat events, the reason of leave events
+	Message string `json:",omitempty"`
+
+	// Cmd is the command the event was derived from
+	Cmd repcmd.Cmd `json:"-"`
+}
+
+// typeIDOrders maps from command type IDs to the normalized orders of commands having no parameters.
+var typeIDOrders = map[byte]string{
+	repcmd.TypeIDStop:            OrderStop,
+	repcmd.TypeIDCarrierStop:     OrderStop,
+	repcmd.TypeIDReaverStop:      OrderStop,
+	repcmd.TypeIDHoldPosition:    OrderHold,
+	repcmd.TypeIDReturnCargo:     "ReturnCargo",
+	repcmd.TypeIDCloack:          "Cloak",
+	repcmd.TypeIDDecloack:        "Decloak",
+	repcmd.TypeIDSiege:           "Siege",
+	repcmd.TypeIDUnsiege:         "Unsiege",
+	repcmd.TypeIDBurrow:          "Burrow",
+	repcmd.TypeIDUnburrow:        "Unburrow",
+	repcmd.TypeIDStim:            "Stim",
+	repcmd.TypeIDUnloadAll:       "UnloadAll",
+	repcmd.TypeIDMergeArchon:     "MergeArchon",
+	repcmd.TypeIDMergeDarkArchon: "MergeDarkArchon",
+	repcmd.TypeIDTrainFighter:    "TrainFighter",
+	repcmd.TypeIDOrderNothing:    "Nothing",
+}
+
+// typeIDCancels maps from command type IDs of cancel commands to what they cancel.
+var typeIDCancels = map[byte]string{
+	repcmd.TypeIDCancelBuild:   "Build",
+	repcmd.TypeIDCancelMorph:   "Morph",
+	repcmd.TypeIDCancelTrain:   "Train",
+	repcmd.TypeIDCancelTech:    "Research",
+	repcmd.TypeIDCancelUpgrade: "Upgrade",
+	repcmd.TypeIDCancelAddon:   "Addon",
+	repcmd.TypeIDCancelNuke:    "Nuke",
+}
+
+// normalizeOrder returns the normalized order of an order.
+func normalizeOrder(o *repcmd.Order) string {
+	switch {
+	case repcmd.IsOrderIDKindStop(o.ID):
+		return OrderStop
+	case repcmd.IsOrderIDKindHold(o.ID):
+		return OrderHold
+	case repcmd.IsOrderIDKindAttack(o.ID):
+		return OrderAttack
+	}
+	switch o.ID {
+	case repcmd.OrderIDMove:
+		return OrderMove
+	case repcmd.OrderIDRallyPointUnit, repcmd.OrderIDRallyPointTile:
+		return OrderRally
+	case repcmd.OrderIDBuildingLand:
+		return OrderLand
+	case repcmd.OrderIDPatrol:
+		return OrderPatrol
+	}
+	return o.Name
+}
+
+// Events returns the normalized events of the game (of all players) in order.
+// Commands having no meaning in terms of events (e.g. selections, hotkeys, sync, lobby commands)
+// produce no events, but selections and hotkeys are tracked to resolve the units of events.
+//
+// Commands must be parsed.
+func (r *Replay) Events() []*Event {
+	events := []*Event{}
+	if r.Commands == nil {
+		return events
+	}
+
+	selections := map[byte][]repcmd.UnitTag{}
+	groups := map[byte]map[byte][]repcmd.UnitTag{} // Hotkey groups by player ID
+
+	for _, cmd := range r.Commands.Cmds {
+		base := cmd.BaseCmd()
+		pid := base.PlayerID
+		sel := selections[pid]
+
+		e := &Event{Frame: base.Frame, PlayerID: pid, Cmd: cmd}
+		pos := func(p repcore.Point) { e.Pos = &p }
+
+		switch x := cmd.(type) {
+		case *repcmd.SelectCmd:
+			switch x.Type.ID {
+			case repcmd.TypeIDSelect, repcmd.TypeIDSelect121:
+				sel = slices.Clone(x.UnitTags)
+			case repcmd.TypeIDSelectAdd, repcmd.TypeIDSelectAdd121:
+				sel = addUnits(sel, x.UnitTags)
+			case repcmd.TypeIDSelectRemove, repcmd.TypeIDSelectRemove121:
+				sel = slices.DeleteFunc(slices.Clone(sel), func(tag repcmd.UnitTag) bool { return slices.Contains(x.UnitTags, tag) })
+			}
+			selections[pid] = sel
+			continue
+		case *repcmd.HotkeyCmd:
+			if groups[pid] == nil {
+				groups[pid] = map[byte][]repcmd.UnitTag{}
+			}
+			switch x.HotkeyType.ID {
+			case repcmd.HotkeyTypeIDAssign:
+				groups[pid][x.Group] = slices.Clone(sel)
+			case repcmd.HotkeyTypeIDAdd:
+				groups[pid][x.Group] = addUnits(groups[pid][x.Group], sel)
+			case repcmd.HotkeyTypeIDSelect:
+				selections[pid] = slices.Clone(groups[pid][x.Group])
+			}
+			continue
+		case *repcmd.RightClickCmd:
+			e.Kind, e.Order, e.TargetUnit, e.Queued = EventKindOrder, OrderSmart, x.UnitTag, x.Queued
+			pos(x.Pos)
+		case *repcmd.TargetedOrderCmd:
+			e.Kind, e.Order, e.TargetUnit, e.Queued = EventKindOrder, normalizeOrder(x.Order), x.UnitTag, x.Queued
+			pos(x.Pos)
+		case *repcmd.BuildCmd:
+			e.Kind = EventKindBuild
+			pos(x.Pos)
+			if x.Unit != nil {
+				e.Name = x.Unit.Name
+			}
+		case *repcmd.LandCmd:
+			e.Kind, e.Order = EventKindOrder, OrderLand
+			pos(x.Pos)
+			if x.Unit != nil {
+				e.Name = x.Unit.Name
+			}
+		case *repcmd.LiftOffCmd:
+			e.Kind, e.Order = EventKindOrder, OrderLift
+			pos(x.Pos)
+		case *repcmd.UnloadCmd:
+			e.Kind, e.Order, e.TargetUnit = EventKindOrder, "Unload", x.UnitTag
+		case *repcmd.TrainCmd:
+			e.Kind = EventKindTrain
+			if x.Type.ID == repcmd.TypeIDUnitMorph {
+				e.Kind = EventKindMorph
+			}
+			if x.Unit != nil {
+				e.Name = x.Unit.Name
+			}
+		case *repcmd.BuildingMorphCmd:
+			e.Kind = EventKindMorph
+			if x.Unit != nil {
+				e.Name = x.Unit.Name
+			}
+		case *repcmd.TechCmd:
+			e.Kind, e.Name = EventKindResearch, x.Tech.Name
+		case *repcmd.UpgradeCmd:
+			e.Kind, e.Name = EventKindUpgrade, x.Upgrade.Name
+		case *repcmd.ChatCmd:
+			e.Kind, e.Message = EventKindChat, x.Message
+		case *repcmd.LeaveGameCmd:
+			e.Kind, e.Message = EventKindLeave, x.Reason.Name
+		case *repcmd.CancelTrainCmd:
+			e.Kind, e.Name, e.TargetUnit = EventKindCancel, typeIDCancels[x.Type.ID], x.UnitTag
+		case *repcmd.QueueableCmd:
+			if order, ok := typeIDOrders[x.Type.ID]; ok {
+				e.Kind, e.Order, e.Queued = EventKindOrder, order, x.Queued
+			}
+		default:
+			if order, ok := typeIDOrders[base.Type.ID]; ok {
+				e.Kind, e.Order = EventKindOrder, order
+			} else if name, ok := typeIDCancels[base.Type.ID]; ok {
+				e.Kind, e.Name = EventKindCancel, name
+			}
+		}
+
+		if e.Kind == "" {
+			continue
+		}
+		switch e.Kind {
+		case EventKindChat, EventKindLeave:
+		default:
+			e.Units = slices.Clone(sel)
+		}
+		events = append(events, e)
+	}
+
+	return events
+}
+
+// addUnits adds the units to the selection (units already in the selection are not added again).
+func addUnits(sel, units []repcmd.UnitTag) []repcmd.UnitTag {
+	sel = slices.Clone(sel)
+	for _, tag := range units {
+		if !slices.Contains(sel, tag) {
+			sel = append(sel, tag)
+		}
+	}
+	return sel
+}
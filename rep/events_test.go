@@ -0,0 +1,56 @@
+package rep
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/icza/screp/rep/repcmd"
+	"github.com/icza/screp/rep/repcore"
+)
+
+func TestEvents(t *testing.T) {
+	base := func(pid byte, frame repcore.Frame, typeID byte) *repcmd.Base {
+		return &repcmd.Base{PlayerID: pid, Frame: frame, Type: repcmd.TypeByID(typeID)}
+	}
+	tags := func(ts ...repcmd.UnitTag) []repcmd.UnitTag { return ts }
+
+	r := &Replay{Commands: &Commands{Cmds: []repcmd.Cmd{
+		&repcmd.SelectCmd{Base: base(0, 1, repcmd.TypeIDSelect121), UnitTags: tags(1, 2)},
+		&repcmd.HotkeyCmd{Base: base(0, 2, repcmd.TypeIDHotkey), HotkeyType: repcmd.HotkeyTypeByID(repcmd.HotkeyTypeIDAssign), Group: 1},
+		&repcmd.SelectCmd{Base: base(1, 3, repcmd.TypeIDSelect), UnitTags: tags(9)},
+		&repcmd.SelectCmd{Base: base(0, 4, repcmd.TypeIDSelectAdd), UnitTags: tags(2, 3)},
+		&repcmd.TargetedOrderCmd{Base: base(0, 5, repcmd.TypeIDTargetedOrder121), Pos: repcore.Point{X: 10, Y: 20}, Order: repcmd.OrderByID(repcmd.OrderIDAttackMove)},
+		&repcmd.QueueableCmd{Base: base(1, 6, repcmd.TypeIDCarrierStop), Queued: true},
+		&repcmd.SelectCmd{Base: base(0, 7, repcmd.TypeIDSelectRemove), UnitTags: tags(1)},
+		&repcmd.BuildCmd{Base: base(0, 8, repcmd.TypeIDBuild), Pos: repcore.Point{X: 5, Y: 6}, Unit: repcmd.UnitByID(repcmd.UnitIDSpawningPool)},
+		&repcmd.HotkeyCmd{Base: base(0, 9, repcmd.TypeIDHotkey), HotkeyType: repcmd.HotkeyTypeByID(repcmd.HotkeyTypeIDSelect), Group: 1},
+		&repcmd.RightClickCmd{Base: base(0, 10, repcmd.TypeIDRightClick), UnitTag: 7},
+		&repcmd.TrainCmd{Base: base(0, 11, repcmd.TypeIDUnitMorph), Unit: repcmd.UnitByID(0x25)},
+		&repcmd.GeneralCmd{Base: base(0, 12, repcmd.TypeIDCancelMorph)},
+		&repcmd.GeneralCmd{Base: base(0, 13, repcmd.TypeIDSync)},
+		&repcmd.ChatCmd{Base: base(1, 14, repcmd.TypeIDChat), Message: "gg"},
+		&repcmd.LeaveGameCmd{Base: base(1, 15, repcmd.TypeIDLeaveGame), Reason: repcmd.LeaveReasonByID(1)},
+	}}}
+
+	exp := []Event{
+		{Frame: 5, PlayerID: 0, Kind: EventKindOrder, Units: tags(1, 2, 3), Order: OrderAttack, Pos: &repcore.Point{X: 10, Y: 20}},
+		{Frame: 6, PlayerID: 1, Kind: EventKindOrder, Units: tags(9), Order: OrderStop, Queued: true},
+		{Frame: 8, PlayerID: 0, Kind: EventKindBuild, Units: tags(2, 3), Pos: &repcore.Point{X: 5, Y: 6}, Name: "Spawning Pool"},
+		{Frame: 10, PlayerID: 0, Kind: EventKindOrder, Units: tags(1, 2), Order: OrderSmart, Pos: &repcore.Point{}, TargetUnit: 7},
+		{Frame: 11, PlayerID: 0, Kind: EventKindMorph, Units: tags(1, 2), Name: "Zergling"},
+		{Frame: 12, PlayerID: 0, Kind: EventKindCancel, Units: tags(1, 2), Name: "Morph"},
+		{Frame: 14, PlayerID: 1, Kind: EventKindChat, Message: "gg"},
+		{Frame: 15, PlayerID: 1, Kind: EventKindLeave, Message: repcmd.LeaveReasonByID(1).Name},
+	}
+
+	events := r.Events()
+	if len(events) != len(exp) {
+		t.Fatalf("Expected: %v events, got: %v", len(exp), len(events))
+	}
+	for i, e := range events {
+		e.Cmd = nil
+		if !reflect.DeepEqual(*e, exp[i]) {
+			t.Errorf("[%d] Expected: %+v, got: %+v", i, exp[i], *e)
+		}
+	}
+}
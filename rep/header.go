@@ -3,7 +3,11 @@
 package rep
 
 import (
+	"cmp"
+	"crypto/sha1"
+	"encoding/hex"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
@@ -27,9 +31,14 @@ type Header struct {
 	// a second. (1 frame = 0.042 second to be exact).
 	Frames repcore.Frame
 
-	// StartTime is the timestamp when the game started
+	// StartTime is the timestamp when the game started, in the time zone given
+	// to the parser (local time zone by default)
 	StartTime time.Time
 
+	// StartTimeUnix is StartTime as stored in the replay: seconds since the Unix epoch.
+	// Unlike StartTime, it does not depend on time zones.
+	StartTimeUnix int64
+
 	// Title is the game name / title
 	Title string
 
@@ -80,10 +89,24 @@ type Header struct {
 	// computer players.
 	PIDPlayers map[byte]*Player `json:"-"`
 
+	// Warnings lists the implausible values found in the header, see HeaderWarning.
+	Warnings []*HeaderWarning `json:",omitempty"`
+
 	// Debug holds optional debug info.
 	Debug *HeaderDebug `json:"-"`
 }
 
+// HeaderWarning describes an implausible value found in the header, e.g. a game length
+// shorter than the frame of the last command, or a map size differing from the map data's.
+// The value of the affected field (and data computed from it) is to be treated with caution.
+type HeaderWarning struct {
+	// Field is the name of the affected Header field, e.g. "Frames"
+	Field string
+
+	// Details of the warning, e.g. the conflicting values
+	Details string
+}
+
 // Duration returns the game duration.
 func (h *Header) Duration() time.Duration {
 	return h.Frames.Duration()
@@ -94,6 +117,16 @@ func (h *Header) MapSize() string {
 	return fmt.Sprint(h.MapWidth, "x", h.MapHeight)
 }
 
+// StyledTitle returns the game title parsed as styled text (see repcore.ParseStyledText()).
+func (h *Header) StyledTitle() *repcore.StyledText {
+	return repcore.ParseStyledText(h.Title)
+}
+
+// StyledMap returns the map name parsed as styled text (see repcore.ParseStyledText()).
+func (h *Header) StyledMap() *repcore.StyledText {
+	return repcore.ParseStyledText(h.Map)
+}
+
 // Matchup returns the matchup, the race letters of players in team order,
 // inserting 'v' between different teams, e.g. "PvT" or "PTZvZTP".
 // Observers are excluded from the matchup.
@@ -132,6 +165,27 @@ func (h *Header) PlayerNames() string {
 	return buf.String()
 }
 
+// GameFingerprint returns a fingerprint identifying the game: replays of the same game
+// saved by different players (or at different times) have the same fingerprint,
+// even though their length and content differ.
+//
+// The fingerprint is the hex encoded SHA-1 hash of the start time, host, title, map name,
+// and the slots, names and races of the players.
+// The undecoded strings are used (e.g. RawHost), or the decoded ones if the former are not retained
+// (see repparser.Config.SkipRawStrings), which results in a different fingerprint for replays
+// having strings that are not valid UTF-8.
+func (h *Header) GameFingerprint() string {
+	players := slices.Clone(h.Players)
+	slices.SortFunc(players, func(a, b *Player) int { return cmp.Compare(a.SlotID, b.SlotID) })
+
+	hasher := sha1.New()
+	fmt.Fprintf(hasher, "%d|%s|%s|%s", h.StartTime.Unix(), cmp.Or(h.RawHost, h.Host), cmp.Or(h.RawTitle, h.Title), cmp.Or(h.RawMap, h.Map))
+	for _, p := range players {
+		fmt.Fprintf(hasher, "|%d,%s,%d", p.SlotID, cmp.Or(p.RawName, p.Name), p.Race.ID)
+	}
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
 // Player represents a player of the game.
 type Player struct {
 	// SlotID is the slot ID
@@ -156,9 +210,30 @@ type Player struct {
 	// RawName is the undecoded Name data. It may differ from Name if the latter is invalid UTF-8.
 	RawName string `json:"-"`
 
+	// NameEncoding is the legacy text encoding detected for Name (e.g. "EUC-KR" or "GBK"),
+	// empty if Name is stored as valid UTF-8 (e.g. ASCII names and names in modern replays).
+	// Chat messages sent by the player are decoded preferring this encoding.
+	NameEncoding string `json:",omitempty"`
+
+	// NameEncodingConfidence is the confidence of the detection of NameEncoding in the range 0..1:
+	// the ratio of common characters of the language of the encoding (e.g. Hangul for EUC-KR),
+	// halved if another encoding fits equally well.
+	NameEncodingConfidence float64 `json:",omitempty"`
+
 	// Color of the player
 	Color *repcore.Color
 
+	// ColorSource tells where Color comes from; nil if Color is not known.
+	//
+	// Colors are reconciled by the following rule: the player colors section (CCLR)
+	// of modern replays holds the actual in-game colors and takes precedence.
+	// Else in UMS games colors defined by the map (COLR entries differing from
+	// the default color of the slot) override the header colors, as the game forces them;
+	// in other game types map colors are only used if the header color is unknown.
+	// The header only stores colors of the first 8 slots, slots 9-12 have the default
+	// color of their slot (see repcore.ColorSourceDefault) unless CCLR tells otherwise.
+	ColorSource *repcore.ColorSource `json:",omitempty"`
+
 	// Observer tells if the player only observes the game and should be excluded
 	// from matchup.
 	// This is not stored in replays, this is a calculated property.
@@ -0,0 +1,47 @@
+package rep
+
+import (
+	"testing"
+	"time"
+
+	"github.com/icza/screp/rep/repcore"
+)
+
+func TestGameFingerprint(t *testing.T) {
+	newHeader := func(frames repcore.Frame, reversed bool) *Header {
+		players := []*Player{
+			{SlotID: 0, RawName: "Flash", Race: repcore.RaceTerran},
+			{SlotID: 1, RawName: "Jaedong", Race: repcore.RaceZerg},
+		}
+		if reversed {
+			players[0], players[1] = players[1], players[0]
+		}
+		return &Header{
+			Frames:    frames,
+			StartTime: time.Date(2023, 5, 6, 7, 8, 9, 0, time.UTC),
+			RawHost:   "Flash",
+			RawMap:    "Fighting Spirit",
+			Players:   players,
+		}
+	}
+
+	fp := newHeader(1000, false).GameFingerprint()
+	if len(fp) != 40 {
+		t.Errorf("Expected length: %v, got: %v", 40, len(fp))
+	}
+	// Same game saved by the other player: different length, different player order
+	if fp2 := newHeader(1200, true).GameFingerprint(); fp2 != fp {
+		t.Errorf("Expected: %v, got: %v", fp, fp2)
+	}
+
+	h := newHeader(1000, false)
+	h.StartTime = h.StartTime.Add(time.Second)
+	if fp2 := h.GameFingerprint(); fp2 == fp {
+		t.Errorf("Expected different fingerprint for different start time")
+	}
+	h = newHeader(1000, false)
+	h.Players[1].Race = repcore.RaceProtoss
+	if fp2 := h.GameFingerprint(); fp2 == fp {
+		t.Errorf("Expected different fingerprint for different race")
+	}
+}
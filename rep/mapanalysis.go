@@ -0,0 +1,390 @@
+// This file contains the start location and expansion analysis of the map.
+
+package rep
+
+import "github.com/icza/screp/rep/repcore"
+
+const (
+	// expansionClusterDist is the max distance (in pixels) between resources of the same expansion.
+	expansionClusterDist = 8 * 32
+
+	// mainMaxDist is the max distance (in pixels) between a start location and its main's resources.
+	mainMaxDist = 12 * 32
+)
+
+// MapAnalysis contains the analysis of the start locations and expansions of the map.
+type MapAnalysis struct {
+	// AirDistances between start locations in pixels.
+	// AirDistances[i][j] is the distance between MapData.StartLocations[i] and MapData.StartLocations[j].
+	AirDistances [][]int
+
+	// GroundDistances between start locations in pixels, similar to AirDistances.
+	// An element is -1 if the start locations are not connected by ground.
+	// Computing ground distances requires the walkability grid, see ComputeGroundDistances();
+	// nil if not computed.
+	GroundDistances [][]int `json:",omitempty"`
+
+	// Connectivity tells how the start locations are connected by ground.
+	// Requires the walkability grid similarly to GroundDistances; nil if not computed.
+	Connectivity *repcore.MapConnectivity `json:",omitempty"`
+
+	// DistanceMode tells which distances were used to detect the mains, naturals and thirds:
+	// Ground if the walkability grid of the map was available, else Air.
+	DistanceMode *repcore.DistanceMode
+
+	// IslandExpansionIDs are the IDs of the expansions not reachable by ground
+	// from any of the start locations.
+	// Requires the walkability grid similarly to GroundDistances.
+	IslandExpansionIDs []int `json:",omitempty"`
+
+	// Expansions are the resource clusters of the map.
+	Expansions []*Expansion
+
+	// Mains are the main expansions of the start locations, in the order of MapData.StartLocations.
+	// An element is nil if the start location has no resources nearby.
+	Mains []*Expansion
+
+	// Naturals are the likely natural expansions of the start locations,
+	// in the order of MapData.StartLocations. An element is nil if not found.
+	Naturals []*Expansion
+
+	// Thirds are the likely third expansions of the start locations,
+	// in the order of MapData.StartLocations. An element is nil if not found.
+	Thirds []*Expansion
+}
+
+// Expansion describes a resource cluster on the map.
+type Expansion struct {
+	// ID of the expansion, 1-based
+	ID int
+
+	// Center of the expansion: the average position of its resources
+	Center repcore.Point
+
+	// MineralFields is the number of mineral fields
+	MineralFields int
+
+	// Geysers is the number of vespene geysers
+	Geysers int
+
+	// Minerals is the total amount of minerals
+	Minerals uint32
+
+	// Gas is the total amount of vespene gas
+	Gas uint32
+}
+
+// NewMapAnalysis analyzes the start locations and expansions of the map.
+// Ground distances are only computed if the walkability grid of the map is available,
+// else air distances are used to detect naturals; DistanceMode tells which one was used.
+func NewMapAnalysis(md *MapData) *MapAnalysis {
+	ma := &MapAnalysis{}
+
+	sls := md.StartLocations
+	ma.AirDistances = make([][]int, len(sls))
+	for i := range sls {
+		ma.AirDistances[i] = make([]int, len(sls))
+		for j := range sls {
+			ma.AirDistances[i][j] = sls[i].Distance(sls[j].Point)
+		}
+	}
+
+	ma.Expansions = clusterResources(md)
+
+	if md.Walkability != nil {
+		ma.ComputeGroundDistances(md)
+	} else {
+		ma.DistanceMode = repcore.DistanceModeAir
+		ma.DetectNaturals(func(slIdx int, exp *Expansion) int {
+			return sls[slIdx].Distance(exp.Center)
+		})
+	}
+
+	return ma
+}
+
+// ComputeGroundDistances computes the ground distances between the start locations
+// using the walkability grid of the map (MapData.Walkability), and redetects
+// the mains and naturals using ground distances.
+// It's a no-op if the walkability grid is not available.
+func (ma *MapAnalysis) ComputeGroundDistances(md *MapData) {
+	walk := md.Walkability
+	if walk == nil {
+		return
+	}
+
+	sls := md.StartLocations
+	fields := make([][]int32, len(sls))
+	for i := range sls {
+		fields[i] = groundDistanceField(walk, sls[i].Point)
+	}
+
+	ma.GroundDistances = make([][]int, len(sls))
+	for i := range sls {
+		ma.GroundDistances[i] = make([]int, len(sls))
+		for j := range sls {
+			ma.GroundDistances[i][j] = fieldDistance(walk, fields[i], sls[j].Point)
+		}
+	}
+
+	ma.Connectivity = repcore.MapConnectivityGround
+	if pairs, connected := len(sls)*(len(sls)-1)/2, ma.connectedPairs(); pairs > 0 && connected < pairs {
+		if connected == 0 {
+			ma.Connectivity = repcore.MapConnectivityIsland
+		} else {
+			ma.Connectivity = repcore.MapConnectivitySemiIsland
+		}
+	}
+
+	ma.IslandExpansionIDs = nil
+	for _, exp := range ma.Expansions {
+		island := true
+		for i := range sls {
+			if fieldDistance(walk, fields[i], exp.Center) >= 0 {
+				island = false
+				break
+			}
+		}
+		if island {
+			ma.IslandExpansionIDs = append(ma.IslandExpansionIDs, exp.ID)
+		}
+	}
+
+	ma.DistanceMode = repcore.DistanceModeGround
+	ma.DetectNaturals(func(slIdx int, exp *Expansion) int {
+		return fieldDistance(walk, fields[slIdx], exp.Center)
+	})
+}
+
+// connectedPairs returns the number of start location pairs connected by ground.
+func (ma *MapAnalysis) connectedPairs() (count int) {
+	for i, dists := range ma.GroundDistances {
+		for j := i + 1; j < len(dists); j++ {
+			if dists[j] >= 0 {
+				count++
+			}
+		}
+	}
+	return
+}
+
+// GroundConnected tells if the start locations given by their indices are connected by ground.
+// known is false if ground distances are not computed (or the indices are invalid).
+func (ma *MapAnalysis) GroundConnected(i, j int) (connected, known bool) {
+	if i < 0 || j < 0 || i >= len(ma.GroundDistances) || j >= len(ma.GroundDistances[i]) {
+		return false, false
+	}
+	return ma.GroundDistances[i][j] >= 0, true
+}
+
+const (
+	// Costs of a straight and a diagonal step between mini-tiles, in pixels.
+	straightStepCost, diagonalStepCost = 8, 11
+
+	// walkableSearchRadius is the max distance (in mini-tiles) to look for a walkable
+	// mini-tile around a point that is on unwalkable terrain (e.g. a resource center).
+	walkableSearchRadius = 8
+)
+
+// groundDistanceField computes the ground distance (in pixels) of each mini-tile
+// from the given point, -1 for unreachable mini-tiles.
+func groundDistanceField(walk *Grid, from repcore.Point) []int32 {
+	w := walk.Width
+	dists := make([]int32, w*walk.Height)
+	for i := range dists {
+		dists[i] = -1
+	}
+
+	start := walkableIndex(walk, from)
+	if start < 0 {
+		return dists
+	}
+
+	// Dijkstra with a bucket queue: step costs are small integers.
+	buckets := make([][]int, diagonalStepCost+1)
+	dists[start] = 0
+	buckets[0] = []int{start}
+	for cur, pending := int32(0), 1; pending > 0; cur++ {
+		b := &buckets[int(cur)%len(buckets)]
+		for len(*b) > 0 {
+			idx := (*b)[len(*b)-1]
+			*b = (*b)[:len(*b)-1]
+			pending--
+			if dists[idx] != cur {
+				continue // Stale entry, a shorter path was found since
+			}
+			x, y := idx%w, idx/w
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					nx, ny := x+dx, y+dy
+					if dx == 0 && dy == 0 || !walk.At(nx, ny) {
+						continue
+					}
+					cost := int32(straightStepCost)
+					if dx != 0 && dy != 0 {
+						// No corner cutting
+						if !walk.At(x+dx, y) || !walk.At(x, y+dy) {
+							continue
+						}
+						cost = diagonalStepCost
+					}
+					nidx := ny*w + nx
+					if nd := cur + cost; dists[nidx] < 0 || nd < dists[nidx] {
+						dists[nidx] = nd
+						nb := &buckets[int(nd)%len(buckets)]
+						*nb = append(*nb, nidx)
+						pending++
+					}
+				}
+			}
+		}
+	}
+
+	return dists
+}
+
+// fieldDistance returns the ground distance of the given point from a distance field
+// computed by groundDistanceField(), -1 if unreachable.
+func fieldDistance(walk *Grid, field []int32, pt repcore.Point) int {
+	idx := walkableIndex(walk, pt)
+	if idx < 0 {
+		return -1
+	}
+	return int(field[idx])
+}
+
+// walkableIndex returns the index of the walkable mini-tile closest to the given point
+// (in pixels), searching within walkableSearchRadius. Returns -1 if not found.
+func walkableIndex(walk *Grid, pt repcore.Point) int {
+	x, y := int(pt.X)/8, int(pt.Y)/8
+	best, bestDist := -1, 0
+	for dy := -walkableSearchRadius; dy <= walkableSearchRadius; dy++ {
+		for dx := -walkableSearchRadius; dx <= walkableSearchRadius; dx++ {
+			if !walk.At(x+dx, y+dy) {
+				continue
+			}
+			if d := dx*dx + dy*dy; best < 0 || d < bestDist {
+				best, bestDist = (y+dy)*walk.Width+x+dx, d
+			}
+		}
+	}
+	return best
+}
+
+// DetectNaturals detects the mains, naturals and thirds of the start locations
+// using the given distance function, which must return the distance between
+// a start location (given by its index) and an expansion, -1 if unreachable.
+// Results are stored in Mains, Naturals and Thirds.
+func (ma *MapAnalysis) DetectNaturals(dist func(slIdx int, exp *Expansion) int) {
+	ma.Mains = make([]*Expansion, len(ma.AirDistances))
+	ma.Naturals = make([]*Expansion, len(ma.AirDistances))
+	ma.Thirds = make([]*Expansion, len(ma.AirDistances))
+
+	// Mains: the closest expansion of each start location, if close enough
+	isMain := map[*Expansion]bool{}
+	for i := range ma.Mains {
+		var best *Expansion
+		bestDist := -1
+		for _, exp := range ma.Expansions {
+			if d := dist(i, exp); d >= 0 && d <= mainMaxDist && (best == nil || d < bestDist) {
+				best, bestDist = exp, d
+			}
+		}
+		ma.Mains[i] = best
+		if best != nil {
+			isMain[best] = true
+		}
+	}
+
+	// closest returns the closest expansion having a geyser (or at least a decent mineral line
+	// if no such expansion is reachable), excluding the ones for which skip returns true.
+	closest := func(slIdx int, skip func(exp *Expansion) bool) (best *Expansion) {
+		bestDist, bestFull := -1, false
+		for _, exp := range ma.Expansions {
+			if skip(exp) {
+				continue
+			}
+			d := dist(slIdx, exp)
+			if d < 0 {
+				continue
+			}
+			full := exp.Geysers > 0 && exp.MineralFields >= 4
+			if best == nil || full && !bestFull || full == bestFull && d < bestDist {
+				best, bestDist, bestFull = exp, d, full
+			}
+		}
+		return
+	}
+
+	// Naturals: the closest non-main expansion
+	isNatural := map[*Expansion]bool{}
+	for i := range ma.Naturals {
+		ma.Naturals[i] = closest(i, func(exp *Expansion) bool { return isMain[exp] })
+		if ma.Naturals[i] != nil {
+			isNatural[ma.Naturals[i]] = true
+		}
+	}
+
+	// Thirds: the closest expansion which is neither a main nor a natural
+	for i := range ma.Thirds {
+		ma.Thirds[i] = closest(i, func(exp *Expansion) bool { return isMain[exp] || isNatural[exp] })
+	}
+}
+
+// Expansions groups the resources of the map into expansions (base locations),
+// see MapAnalysis.Expansions.
+func (md *MapData) Expansions() []*Expansion {
+	return clusterResources(md)
+}
+
+// clusterResources groups the resources of the map into expansions.
+// Resources closer than expansionClusterDist to any resource of a cluster belong to the cluster.
+func clusterResources(md *MapData) (exps []*Expansion) {
+	type res struct {
+		Resource
+		geyser bool
+	}
+	var all []res
+	for _, mf := range md.MineralFields {
+		all = append(all, res{Resource: mf})
+	}
+	for _, g := range md.Geysers {
+		all = append(all, res{Resource: g, geyser: true})
+	}
+
+	clustered := make([]bool, len(all))
+	for i := range all {
+		if clustered[i] {
+			continue
+		}
+		clustered[i] = true
+		cluster := []int{i}
+		for k := 0; k < len(cluster); k++ {
+			for j := range all {
+				if !clustered[j] && all[cluster[k]].Distance(all[j].Point) <= expansionClusterDist {
+					clustered[j] = true
+					cluster = append(cluster, j)
+				}
+			}
+		}
+
+		exp := &Expansion{ID: len(exps) + 1}
+		var sumX, sumY int
+		for _, j := range cluster {
+			r := all[j]
+			sumX += int(r.X)
+			sumY += int(r.Y)
+			if r.geyser {
+				exp.Geysers++
+				exp.Gas += r.Amount
+			} else {
+				exp.MineralFields++
+				exp.Minerals += r.Amount
+			}
+		}
+		exp.Center = repcore.Point{X: uint16(sumX / len(cluster)), Y: uint16(sumY / len(cluster))}
+		exps = append(exps, exp)
+	}
+
+	return
+}
@@ -0,0 +1,197 @@
+package rep
+
+import (
+	"testing"
+
+	"github.com/icza/screp/rep/repcore"
+)
+
+func pt(x, y uint16) repcore.Point {
+	return repcore.Point{X: x, Y: y}
+}
+
+func TestClusterResources(t *testing.T) {
+	md := &MapData{
+		MineralFields: []Resource{
+			{pt(100, 100), 1500}, {pt(132, 100), 1500}, {pt(164, 100), 1000},
+			{pt(2000, 2000), 1500}, {pt(2032, 2000), 1500},
+		},
+		Geysers: []Resource{
+			{pt(100, 200), 5000},
+			{pt(4000, 100), 5000}, // Lone geyser
+		},
+	}
+
+	exps := md.Expansions()
+	if len(exps) != 3 {
+		t.Fatalf("Expected: %v expansions, got: %v", 3, len(exps))
+	}
+
+	cases := []struct {
+		mfs, geysers  int
+		minerals, gas uint32
+		center        repcore.Point
+	}{
+		{3, 1, 4000, 5000, pt(124, 125)},
+		{2, 0, 3000, 0, pt(2016, 2000)},
+		{0, 1, 0, 5000, pt(4000, 100)},
+	}
+	for i, c := range cases {
+		exp := exps[i]
+		if exp.ID != i+1 {
+			t.Errorf("[%d] Expected ID: %v, got: %v", i, i+1, exp.ID)
+		}
+		if exp.MineralFields != c.mfs || exp.Geysers != c.geysers {
+			t.Errorf("[%d] Expected resources: %v/%v, got: %v/%v", i, c.mfs, c.geysers, exp.MineralFields, exp.Geysers)
+		}
+		if exp.Minerals != c.minerals || exp.Gas != c.gas {
+			t.Errorf("[%d] Expected amounts: %v/%v, got: %v/%v", i, c.minerals, c.gas, exp.Minerals, exp.Gas)
+		}
+		if exp.Center != c.center {
+			t.Errorf("[%d] Expected center: %v, got: %v", i, c.center, exp.Center)
+		}
+	}
+}
+
+func TestDetectNaturals(t *testing.T) {
+	main1 := &Expansion{ID: 1, Center: pt(100, 100), MineralFields: 8, Geysers: 1}
+	main2 := &Expansion{ID: 2, Center: pt(3000, 3000), MineralFields: 8, Geysers: 1}
+	mineralOnly := &Expansion{ID: 3, Center: pt(300, 300), MineralFields: 6}
+	nat1 := &Expansion{ID: 4, Center: pt(600, 600), MineralFields: 7, Geysers: 1}
+	nat2 := &Expansion{ID: 5, Center: pt(2500, 2500), MineralFields: 7, Geysers: 1}
+	third1 := &Expansion{ID: 6, Center: pt(1200, 600), MineralFields: 8, Geysers: 1}
+	third2 := &Expansion{ID: 7, Center: pt(2000, 2900), MineralFields: 8, Geysers: 1}
+
+	ma := &MapAnalysis{
+		AirDistances: make([][]int, 3),
+		Expansions:   []*Expansion{main1, main2, mineralOnly, nat1, nat2, third1, third2},
+	}
+	sls := []repcore.Point{pt(150, 150), pt(2950, 2950), pt(9000, 9000)}
+	ma.DetectNaturals(func(slIdx int, exp *Expansion) int {
+		return sls[slIdx].Distance(exp.Center)
+	})
+
+	cases := []struct {
+		main, natural, third *Expansion
+	}{
+		{main1, nat1, third1}, // Mineral only expansion is closer, but a full expansion is preferred
+		{main2, nat2, third2},
+		{nil, nat2, third2}, // No main in range
+	}
+	for i, c := range cases {
+		if ma.Mains[i] != c.main {
+			t.Errorf("[%d] Expected main: %v, got: %v", i, c.main, ma.Mains[i])
+		}
+		if ma.Naturals[i] != c.natural {
+			t.Errorf("[%d] Expected natural: %v, got: %v", i, c.natural, ma.Naturals[i])
+		}
+		if ma.Thirds[i] != c.third {
+			t.Errorf("[%d] Expected third: %v, got: %v", i, c.third, ma.Thirds[i])
+		}
+	}
+}
+
+func TestGroundDistanceField(t *testing.T) {
+	// 16x16 mini-tiles, a wall at x=8 with a gap at the bottom row.
+	walk := NewGrid(16, 16)
+	for i := range walk.Data {
+		walk.Data[i] = true
+	}
+	for y := 0; y < 15; y++ {
+		walk.Set(8, y, false)
+	}
+
+	field := groundDistanceField(walk, pt(4, 4)) // Mini-tile (0, 0)
+
+	// Around the wall: 7 diagonal steps to (7, 7), down to the bottom row,
+	// through the gap (no corner cutting) and up to (9, 0).
+
+	cases := []struct {
+		name string
+		pt   repcore.Point
+		dist int
+	}{
+		{"start", pt(4, 4), 0},
+		{"straight", pt(4*8+4, 4), 4 * straightStepCost},
+		{"diagonal", pt(3*8+4, 3*8+4), 3 * diagonalStepCost},
+		{"around wall", pt(9*8+4, 4), 7*diagonalStepCost + 25*straightStepCost},
+		{"outside", pt(1000, 1000), -1},
+	}
+	for _, c := range cases {
+		if got := fieldDistance(walk, field, c.pt); got != c.dist {
+			t.Errorf("[%s] Expected: %v, got: %v", c.name, c.dist, got)
+		}
+	}
+
+	// Fully separated halves:
+	walk.Set(8, 15, false)
+	field = groundDistanceField(walk, pt(4, 4))
+	if got := field[9]; got != -1 {
+		t.Errorf("Expected: %v, got: %v", -1, got)
+	}
+}
+
+func TestConnectivity(t *testing.T) {
+	// 32x16 mini-tiles (256x128 pixels), walls at x=10 and x=20 (full height).
+	newWalk := func(wallXs ...int) *Grid {
+		walk := NewGrid(32, 16)
+		for i := range walk.Data {
+			walk.Data[i] = true
+		}
+		for _, x := range wallXs {
+			for y := 0; y < 16; y++ {
+				walk.Set(x, y, false)
+			}
+		}
+		return walk
+	}
+	sls := []StartLocation{{Point: pt(20, 20)}, {Point: pt(60, 100)}, {Point: pt(200, 60)}}
+	mfs := []Resource{{pt(130, 60), 1500}} // Expansion between the walls
+
+	cases := []struct {
+		name         string
+		walk         *Grid
+		connectivity *repcore.MapConnectivity
+		connected01  bool
+		connected02  bool
+		islandExpIDs []int
+	}{
+		{"ground", newWalk(), repcore.MapConnectivityGround, true, true, nil},
+		{"semi-island", newWalk(20), repcore.MapConnectivitySemiIsland, true, false, nil},
+		{"island expansion", newWalk(10, 20), repcore.MapConnectivitySemiIsland, true, false, []int{1}},
+	}
+	for _, c := range cases {
+		md := &MapData{StartLocations: sls, MineralFields: mfs, Walkability: c.walk}
+		ma := NewMapAnalysis(md)
+		if ma.Connectivity != c.connectivity {
+			t.Errorf("[%s] Expected: %v, got: %v", c.name, c.connectivity, ma.Connectivity)
+		}
+		if ma.DistanceMode != repcore.DistanceModeGround {
+			t.Errorf("[%s] Expected: %v, got: %v", c.name, repcore.DistanceModeGround, ma.DistanceMode)
+		}
+		if conn, known := ma.GroundConnected(0, 1); conn != c.connected01 || !known {
+			t.Errorf("[%s] Expected: %v, got: %v %v", c.name, c.connected01, conn, known)
+		}
+		if conn, known := ma.GroundConnected(0, 2); conn != c.connected02 || !known {
+			t.Errorf("[%s] Expected: %v, got: %v %v", c.name, c.connected02, conn, known)
+		}
+		if len(ma.IslandExpansionIDs) != len(c.islandExpIDs) {
+			t.Errorf("[%s] Expected: %v, got: %v", c.name, c.islandExpIDs, ma.IslandExpansionIDs)
+		}
+	}
+
+	// Island: all start locations separated
+	md := &MapData{StartLocations: []StartLocation{sls[0], sls[2]}, Walkability: newWalk(20)}
+	if ma := NewMapAnalysis(md); ma.Connectivity != repcore.MapConnectivityIsland {
+		t.Errorf("Expected: %v, got: %v", repcore.MapConnectivityIsland, ma.Connectivity)
+	}
+
+	// Not computed (no walkability grid, e.g. tileset data is not available)
+	ma := NewMapAnalysis(&MapData{StartLocations: sls})
+	if _, known := ma.GroundConnected(0, 1); known || ma.Connectivity != nil || ma.GroundDistances != nil {
+		t.Errorf("Expected unknown connectivity")
+	}
+	if ma.DistanceMode != repcore.DistanceModeAir {
+		t.Errorf("Expected: %v, got: %v", repcore.DistanceModeAir, ma.DistanceMode)
+	}
+}
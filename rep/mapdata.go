@@ -2,7 +2,10 @@
 
 package rep
 
-import "github.com/icza/screp/rep/repcore"
+import (
+	"github.com/icza/screp/rep/repcmd"
+	"github.com/icza/screp/rep/repcore"
+)
 
 // MapData describes the map and objects on it.
 type MapData struct {
@@ -20,6 +23,20 @@ type MapData struct {
 
 	TileSetMissing bool `json:"tileSetMissing,omitempty"`
 
+	// CHKHash is the hex encoded SHA-1 hash of the map data (the scenario CHK) embedded in the replay.
+	// It identifies the map content, so it may be used to join replays against map databases
+	// keyed by the CHK hash.
+	//
+	// Note: this is not the hash of the map file (e.g. BWAPI's map hash is the SHA-1 of the whole
+	// MPQ archive of the map file), as the map file itself is not stored in replays.
+	CHKHash string
+
+	// Fingerprint identifies the map by its terrain and resource layout: it is the hex encoded
+	// SHA-1 hash of the tile set, map size, tiles and mineral field and geyser locations.
+	// Names, strings, forces and other settings are excluded, so localized and observer
+	// variants of the same map have the same fingerprint.
+	Fingerprint string
+
 	// Scenario name
 	Name string
 
@@ -32,10 +49,30 @@ type MapData struct {
 	// PlayerSides defines the player sides (player races).
 	PlayerSides []*repcore.PlayerSide
 
+	// Colors defines the map-forced player colors (from the COLR sub-section),
+	// one for each of the first 8 slots.
+	Colors []*repcore.Color `json:",omitempty"`
+
 	// Tiles is the tile data of the map (within the tile set): width x height elements.
 	// 1 Tile is 32 units (pixel)
 	Tiles []uint16 `json:",omitempty"`
 
+	// FogMask is the initial fog of war mask of the map: width x height elements, one for each tile.
+	// Bit n is set if the tile is covered by fog for the player in slot n (slots 0..7);
+	// if bit n is cleared, the tile is initially explored for that player.
+	// The length is not validated against the map size: the MASK sub-section may be
+	// shorter or longer than width x height; tiles not covered by it are to be treated as fogged.
+	FogMask repcmd.Bytes `json:",omitempty"`
+
+	// Walkability is the walkability grid of the map, one element for each mini-tile
+	// (a mini-tile is 8x8 pixels, a tile consists of 4x4 mini-tiles).
+	// Not filled by the parser, tileset data is required to compute it, see package repmap.
+	Walkability *Grid `json:"-"`
+
+	// Buildability is the buildability grid of the map, one element for each tile.
+	// Not filled by the parser, tileset data is required to compute it, see package repmap.
+	Buildability *Grid `json:"-"`
+
 	// Mineral field locations on the map
 	MineralFields []Resource `json:",omitempty"`
 
@@ -45,6 +82,62 @@ type MapData struct {
 	// StartLocations on the map
 	StartLocations []StartLocation
 
+	// Forces of the map. UMS team semantics come from forces.
+	Forces []*Force `json:",omitempty"`
+
+	// Locations are the named locations (rectangles) defined by the map.
+	// Unused locations are excluded.
+	Locations []*Location `json:",omitempty"`
+
+	// UnitSettings contains the unit properties overridden by the map
+	// (from the UNIS / UNIx sub-sections). Only units with non-default settings are included.
+	UnitSettings []*UnitSetting `json:",omitempty"`
+
+	// WeaponSettings contains the weapon damage settings of the map
+	// (from the UNIS / UNIx sub-sections) that are in effect:
+	// those of the weapons of units listed in UnitSettings.
+	WeaponSettings []*WeaponSetting `json:",omitempty"`
+
+	// UpgradeSettings contains the upgrade costs overridden by the map
+	// (from the UPGS / UPGx sub-sections). Only upgrades with non-default settings are included.
+	UpgradeSettings []*UpgradeSetting `json:",omitempty"`
+
+	// TechSettings contains the tech costs overridden by the map
+	// (from the TECS / TECx sub-sections). Only techs with non-default settings are included.
+	TechSettings []*TechSetting `json:",omitempty"`
+
+	// UpgradeRestrictions contains the upgrade availability per player
+	// (from the UPGR / PUPx sub-sections). Only parsed if requested (repparser.Config.MapRestrictions),
+	// as it contains all upgrades.
+	UpgradeRestrictions []*UpgradeRestriction `json:",omitempty"`
+
+	// TechRestrictions contains the tech availability per player
+	// (from the PTEC / PTEx sub-sections). Only parsed if requested (repparser.Config.MapRestrictions),
+	// as it contains all techs.
+	TechRestrictions []*TechRestriction `json:",omitempty"`
+
+	// Switches lists the named switches of the map (from the SWNM sub-section).
+	Switches []*Switch `json:",omitempty"`
+
+	// WAVs lists the sound file paths used by the map (from the WAV sub-section).
+	WAVs []string `json:",omitempty"`
+
+	// Strings is the strings table of the map (from the STR / STRx sub-sections),
+	// decoded the same way as other map strings (UTF-8, falling back to the best fitting legacy encoding, e.g. EUC-KR).
+	// Strings[i] is the string with index i+1 (string indices are 1-based, 0 means no string).
+	// Only retained if requested (repparser.Config.MapStrings).
+	Strings []string `json:",omitempty"`
+
+	// Triggers of the map.
+	Triggers []*Trigger `json:",omitempty"`
+
+	// BriefingTriggers are the mission briefing triggers of the map.
+	BriefingTriggers []*Trigger `json:",omitempty"`
+
+	// Anomalies lists the map protection / corruption artifacts found in the map data.
+	// Data parsed from the affected sub-sections may be unreliable, see MapAnomaly.
+	Anomalies []*MapAnomaly `json:",omitempty"`
+
 	// MapGraphics holds data for map image rendering.
 	MapGraphics *MapGraphics `json:",omitempty"`
 
@@ -52,6 +145,11 @@ type MapData struct {
 	Debug *MapDataDebug `json:"-"`
 }
 
+// StyledName returns the scenario name parsed as styled text (see repcore.ParseStyledText()).
+func (md *MapData) StyledName() *repcore.StyledText {
+	return repcore.ParseStyledText(md.Name)
+}
+
 // MaxHumanPlayers returns the max number of human players on the map.
 func (md *MapData) MaxHumanPlayers() (count int) {
 	for _, owner := range md.PlayerOwners {
@@ -62,6 +160,36 @@ func (md *MapData) MaxHumanPlayers() (count int) {
 	return
 }
 
+// Grid is a 2-dimensional grid of boolean values.
+type Grid struct {
+	// Width and Height of the grid
+	Width, Height int
+
+	// Data holds the elements of the grid in row-major order.
+	Data []bool
+}
+
+// NewGrid creates a new Grid of the given size.
+func NewGrid(width, height int) *Grid {
+	return &Grid{Width: width, Height: height, Data: make([]bool, width*height)}
+}
+
+// At returns the element at the given position. Returns false if the position is outside of the grid.
+func (g *Grid) At(x, y int) bool {
+	if x < 0 || y < 0 || x >= g.Width || y >= g.Height {
+		return false
+	}
+	return g.Data[y*g.Width+x]
+}
+
+// Set sets the element at the given position. Positions outside of the grid are ignored.
+func (g *Grid) Set(x, y int, v bool) {
+	if x < 0 || y < 0 || x >= g.Width || y >= g.Height {
+		return
+	}
+	g.Data[y*g.Width+x] = v
+}
+
 // Resource describes a resource (mineral field of vespene geyser).
 type Resource struct {
 	// Location of the resource
@@ -80,6 +208,79 @@ type StartLocation struct {
 	SlotID byte
 }
 
+// Force describes a force (team) defined by the map.
+type Force struct {
+	// ID of the force (0-based)
+	ID byte
+
+	// Name of the force. Unnamed forces get the default name used by the game ("Force N").
+	Name string
+
+	// SlotIDs of the players assigned to this force
+	SlotIDs repcmd.Bytes
+
+	// RandomStartLocation tells if start locations are randomized within the force
+	RandomStartLocation bool
+
+	// Allied tells if the players of the force are allied
+	Allied bool
+
+	// AlliedVictory tells if the players of the force have allied victory
+	AlliedVictory bool
+
+	// SharedVision tells if the players of the force share vision
+	SharedVision bool
+}
+
+// Location describes a location (a named rectangle) defined by the map.
+type Location struct {
+	// ID of the location. This is the 1-based index used by triggers.
+	ID uint16
+
+	// Name of the location
+	Name string
+
+	// Boundaries of the location rectangle in pixels
+	Left, Top, Right, Bottom uint32
+
+	// ElevationFlags tells which elevation levels the location applies to.
+	// Bit set means the level is excluded: 0x01: low ground, 0x02: medium ground,
+	// 0x04: high ground, 0x08: low air, 0x10: medium air, 0x20: high air.
+	ElevationFlags uint16 `json:",omitempty"`
+}
+
+// Switch describes a named switch of the map.
+type Switch struct {
+	// ID of the switch. This is the 0-based switch number used by triggers
+	// (TriggerCondition.SwitchID and TriggerAction.SwitchID).
+	ID uint16
+
+	// Name of the switch
+	Name string
+}
+
+// MapAnomaly describes a map protection / corruption artifact found in the map data.
+//
+// Map protectors use tricks which the game tolerates but which confuse editors and parsers.
+// The parser handles them the way the game does where possible, but data of the affected
+// sub-section is to be treated with caution:
+//   - Duplicate Section: later sub-sections override (STR and MTXM: overwrite the beginning of) earlier ones
+//   - Missing Section: data of the sub-section is missing (e.g. TileSet defaults to Twilight)
+//   - Invalid Section Size: the sub-section is truncated, has an unexpected size, or has a negative size
+//     in which case parsing stops at the sub-section
+//   - Unknown Section: junk sub-section, ignored
+//   - Invalid String: strings of the strings table point outside of it, they are treated as empty
+type MapAnomaly struct {
+	// Type of the anomaly
+	Type *repcore.MapAnomalyType
+
+	// Section is the ID of the affected sub-section
+	Section string
+
+	// Details of the anomaly
+	Details string `json:",omitempty"`
+}
+
 // MapDataDebug holds debug info for the map data section.
 type MapDataDebug struct {
 	// Data is the raw, uncompressed data of the section.
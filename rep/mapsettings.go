@@ -0,0 +1,178 @@
+// This file contains the types describing unit, upgrade and tech settings of the map.
+
+package rep
+
+import "github.com/icza/screp/rep/repcmd"
+
+// UnitSettingByID returns the unit setting of the unit given by its ID.
+// Returns nil if the map does not override the settings of the unit.
+func (md *MapData) UnitSettingByID(unitID uint16) *UnitSetting {
+	for _, us := range md.UnitSettings {
+		if us.Unit.ID == unitID {
+			return us
+		}
+	}
+	return nil
+}
+
+// UpgradeSettingByID returns the upgrade setting of the upgrade given by its ID.
+// Returns nil if the map does not override the settings of the upgrade.
+func (md *MapData) UpgradeSettingByID(upgradeID byte) *UpgradeSetting {
+	for _, us := range md.UpgradeSettings {
+		if us.Upgrade.ID == upgradeID {
+			return us
+		}
+	}
+	return nil
+}
+
+// TechSettingByID returns the tech setting of the tech given by its ID.
+// Returns nil if the map does not override the settings of the tech.
+func (md *MapData) TechSettingByID(techID byte) *TechSetting {
+	for _, ts := range md.TechSettings {
+		if ts.Tech.ID == techID {
+			return ts
+		}
+	}
+	return nil
+}
+
+// UpgradeMaxLevel returns the max level of the upgrade given by its ID
+// the player in the given slot may research.
+// ok is false if restriction info for the upgrade is not available (see repparser.Config.MapRestrictions).
+func (md *MapData) UpgradeMaxLevel(slotID byte, upgradeID byte) (level byte, ok bool) {
+	for _, ur := range md.UpgradeRestrictions {
+		if ur.Upgrade.ID == upgradeID && int(slotID) < len(ur.MaxLevels) {
+			return ur.MaxLevels[slotID], true
+		}
+	}
+	return 0, false
+}
+
+// TechAvailable tells if the tech given by its ID is available for
+// the player in the given slot (either for research or researched at start).
+// ok is false if restriction info for the tech is not available (see repparser.Config.MapRestrictions).
+func (md *MapData) TechAvailable(slotID byte, techID byte) (available, ok bool) {
+	for _, tr := range md.TechRestrictions {
+		if tr.Tech.ID == techID && int(slotID) < len(tr.Available) {
+			return tr.Available[slotID] || tr.Researched[slotID], true
+		}
+	}
+	return false, false
+}
+
+// UnitSetting describes unit properties overridden by the map.
+type UnitSetting struct {
+	// Unit whose properties are overridden
+	Unit *repcmd.Unit
+
+	// Name is the custom name of the unit, empty if not renamed
+	Name string `json:",omitempty"`
+
+	// HitPoints of the unit
+	HitPoints uint32
+
+	// ShieldPoints of the unit
+	ShieldPoints uint16
+
+	// Armor of the unit
+	Armor byte
+
+	// BuildTime of the unit in frames
+	BuildTime uint16
+
+	// MineralCost of the unit
+	MineralCost uint16
+
+	// GasCost of the unit
+	GasCost uint16
+}
+
+// WeaponSetting describes weapon damage settings of the map.
+//
+// Weapon settings only take effect for weapons of units whose settings are overridden
+// (listed in MapData.UnitSettings), so only those are included.
+// Weapons of hero and special units are not included.
+type WeaponSetting struct {
+	// WeaponID is the ID of the weapon (index in weapons.dat)
+	WeaponID byte
+
+	// Name of the weapon
+	Name string
+
+	// Unit owning the weapon
+	Unit *repcmd.Unit
+
+	// BaseDamage of the weapon
+	BaseDamage uint16
+
+	// UpgradeDamage is the bonus damage per upgrade
+	UpgradeDamage uint16
+}
+
+// UpgradeSetting describes upgrade costs overridden by the map.
+// Cost of level n is: base + (n-1)*factor.
+type UpgradeSetting struct {
+	// Upgrade whose costs are overridden
+	Upgrade *repcmd.Upgrade
+
+	// MineralCost is the base mineral cost
+	MineralCost uint16
+
+	// MineralFactor is the mineral cost increment per level
+	MineralFactor uint16
+
+	// GasCost is the base gas cost
+	GasCost uint16
+
+	// GasFactor is the gas cost increment per level
+	GasFactor uint16
+
+	// Time is the base research time in frames
+	Time uint16
+
+	// TimeFactor is the research time increment per level in frames
+	TimeFactor uint16
+}
+
+// TechSetting describes tech costs overridden by the map.
+type TechSetting struct {
+	// Tech whose costs are overridden
+	Tech *repcmd.Tech
+
+	// MineralCost of the research
+	MineralCost uint16
+
+	// GasCost of the research
+	GasCost uint16
+
+	// Time of the research in frames
+	Time uint16
+
+	// EnergyCost of using the tech
+	EnergyCost uint16
+}
+
+// UpgradeRestriction describes the availability of an upgrade for the players.
+type UpgradeRestriction struct {
+	// Upgrade this restriction applies to
+	Upgrade *repcmd.Upgrade
+
+	// MaxLevels is the max level that can be researched, one for each slot (12 elements).
+	MaxLevels repcmd.Bytes
+
+	// StartLevels is the level researched at start, one for each slot (12 elements).
+	StartLevels repcmd.Bytes
+}
+
+// TechRestriction describes the availability of a tech for the players.
+type TechRestriction struct {
+	// Tech this restriction applies to
+	Tech *repcmd.Tech
+
+	// Available tells if the tech can be researched, one for each slot (12 elements).
+	Available []bool
+
+	// Researched tells if the tech is researched at start, one for each slot (12 elements).
+	Researched []bool
+}
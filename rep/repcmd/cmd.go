@@ -82,11 +82,17 @@ func c(verbose bool, verboseFmt, nonVerboseFmt string) string {
 // ParseErrCmd represents a command where parsing error encountered.
 // It stores a reference to the preceding command for debugging purposes
 // (often a parse error is the result of improperly parsing the preceding command).
+// Its Type is the type of the command that could not be parsed (e.g. an unknown type).
 type ParseErrCmd struct {
 	*Base
 
 	// PrevCmd is the command preceding the parse error command.
 	PrevCmd Cmd
+
+	// SkippedBytes are the raw bytes skipped from the command block (following the type ID),
+	// holding the data of the command and of the commands following it in the same frame.
+	// Allows analyzing replays offline to add support for new commands.
+	SkippedBytes Bytes `json:",omitempty"`
 }
 
 // Params implements Cmd.Params().
@@ -2,7 +2,10 @@
 
 package repcmd
 
-import "github.com/icza/screp/rep/repcore"
+import (
+	"github.com/icza/screp/internal/intern"
+	"github.com/icza/screp/rep/repcore"
+)
 
 // HotkeyType describes the hotkey type.
 type HotkeyType struct {
@@ -26,12 +29,15 @@ const (
 	HotkeyTypeIDAdd    = 0x02
 )
 
+// unknownHotkeyTypes holds the HotkeyTypes of unknown IDs.
+var unknownHotkeyTypes intern.Pool[byte, *HotkeyType]
+
 // HotkeyTypeByID returns the HotkeyType for a given ID.
-// A new HotkeyType with Unknown name is returned if one is not found
-// for the given ID (preserving the unknown ID).
+// A HotkeyType with Unknown name is returned if one is not found
+// for the given ID (preserving the unknown ID), the same one for the same ID.
 func HotkeyTypeByID(ID byte) *HotkeyType {
 	if int(ID) < len(HotkeyTypes) {
 		return HotkeyTypes[ID]
 	}
-	return &HotkeyType{repcore.UnknownEnum(ID), ID}
+	return unknownHotkeyTypes.Get(ID, func(ID byte) *HotkeyType { return &HotkeyType{repcore.UnknownEnum(ID), ID} })
 }
@@ -2,7 +2,10 @@
 
 package repcmd
 
-import "github.com/icza/screp/rep/repcore"
+import (
+	"github.com/icza/screp/internal/intern"
+	"github.com/icza/screp/rep/repcore"
+)
 
 // Latency describes the latency.
 type Latency struct {
@@ -19,12 +22,15 @@ var Latencies = []*Latency{
 	{e("Extra High"), 0x02},
 }
 
+// unknownLatencies holds the Latencies of unknown IDs.
+var unknownLatencies intern.Pool[byte, *Latency]
+
 // LatencyTypeByID returns the Latency for a given ID.
-// A new Latency with Unknown name is returned if one is not found
-// for the given ID (preserving the unknown ID).
+// A Latency with Unknown name is returned if one is not found
+// for the given ID (preserving the unknown ID), the same one for the same ID.
 func LatencyTypeByID(ID byte) *Latency {
 	if int(ID) < len(Latencies) {
 		return Latencies[ID]
 	}
-	return &Latency{repcore.UnknownEnum(ID), ID}
+	return unknownLatencies.Get(ID, func(ID byte) *Latency { return &Latency{repcore.UnknownEnum(ID), ID} })
 }
@@ -2,7 +2,10 @@
 
 package repcmd
 
-import "github.com/icza/screp/rep/repcore"
+import (
+	"github.com/icza/screp/internal/intern"
+	"github.com/icza/screp/rep/repcore"
+)
 
 // LeaveReason describes the leave reason.
 type LeaveReason struct {
@@ -22,13 +25,16 @@ var LeaveReasons = []*LeaveReason{
 	{e("Dropped"), 0x06},
 }
 
+// unknownLeaveReasons holds the LeaveReasons of unknown IDs.
+var unknownLeaveReasons intern.Pool[byte, *LeaveReason]
+
 // LeaveReasonByID returns the LeaveReason for a given ID.
-// A new LeaveReason with Unknown name is returned if one is not found
-// for the given ID (preserving the unknown ID).
+// A LeaveReason with Unknown name is returned if one is not found
+// for the given ID (preserving the unknown ID), the same one for the same ID.
 func LeaveReasonByID(ID byte) *LeaveReason {
 	// Known reason IDs start from 1!
 	if ID > 0 && int(ID) <= len(LeaveReasons) {
 		return LeaveReasons[ID-1]
 	}
-	return &LeaveReason{repcore.UnknownEnum(ID), ID}
+	return unknownLeaveReasons.Get(ID, func(ID byte) *LeaveReason { return &LeaveReason{repcore.UnknownEnum(ID), ID} })
 }
@@ -2,7 +2,10 @@
 
 package repcmd
 
-import "github.com/icza/screp/rep/repcore"
+import (
+	"github.com/icza/screp/internal/intern"
+	"github.com/icza/screp/rep/repcore"
+)
 
 // Order describes the unit order.
 type Order struct {
@@ -206,14 +209,17 @@ var Orders = []*Order{
 	{e("None"), 0xbd},
 }
 
+// unknownOrders holds the Orders of unknown IDs.
+var unknownOrders intern.Pool[byte, *Order]
+
 // OrderByID returns the Order for a given ID.
-// A new Order with Unknown name is returned if one is not found
-// for the given ID (preserving the unknown ID).
+// An Order with Unknown name is returned if one is not found
+// for the given ID (preserving the unknown ID), the same one for the same ID.
 func OrderByID(ID byte) *Order {
 	if int(ID) < len(Orders) {
 		return Orders[ID]
 	}
-	return &Order{repcore.UnknownEnum(ID), ID}
+	return unknownOrders.Get(ID, func(ID byte) *Order { return &Order{repcore.UnknownEnum(ID), ID} })
 }
 
 // Order IDs
@@ -243,6 +249,7 @@ const (
 	OrderIDNukeLaunch           = 0x7d
 	OrderIDCastRecall           = 0x89
 	OrderIDCastScannerSweep     = 0x8b
+	OrderIDPatrol               = 0x98
 	OrderIDMedicHoldPosition    = 0xb2
 )
 
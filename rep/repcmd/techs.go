@@ -2,7 +2,10 @@
 
 package repcmd
 
-import "github.com/icza/screp/rep/repcore"
+import (
+	"github.com/icza/screp/internal/intern"
+	"github.com/icza/screp/rep/repcore"
+)
 
 // Tech describes the tech (research).
 type Tech struct {
@@ -51,12 +54,15 @@ var Techs = []*Tech{
 	{e("Healing"), 0x22},
 }
 
+// unknownTechs holds the Techs of unknown IDs.
+var unknownTechs intern.Pool[byte, *Tech]
+
 // TechByID returns the Tech for a given ID.
-// A new Tech with Unknown name is returned if one is not found
-// for the given ID (preserving the unknown ID).
+// A Tech with Unknown name is returned if one is not found
+// for the given ID (preserving the unknown ID), the same one for the same ID.
 func TechByID(ID byte) *Tech {
 	if int(ID) < len(Techs) {
 		return Techs[ID]
 	}
-	return &Tech{repcore.UnknownEnum(ID), ID}
+	return unknownTechs.Get(ID, func(ID byte) *Tech { return &Tech{repcore.UnknownEnum(ID), ID} })
 }
@@ -2,7 +2,10 @@
 
 package repcmd
 
-import "github.com/icza/screp/rep/repcore"
+import (
+	"github.com/icza/screp/internal/intern"
+	"github.com/icza/screp/rep/repcore"
+)
 
 // Type IDs of command types
 const (
@@ -273,12 +276,15 @@ func init() {
 	}
 }
 
+// unknownTypes holds the Types of unknown IDs.
+var unknownTypes intern.Pool[byte, *Type]
+
 // TypeByID returns the Type for a given ID.
-// A new Type with Unknown name is returned if one is not found
-// for the given ID (preserving the unknown ID).
+// A Type with Unknown name is returned if one is not found
+// for the given ID (preserving the unknown ID), the same one for the same ID.
 func TypeByID(ID byte) *Type {
 	if t := typeIDType[ID]; t != nil {
 		return t
 	}
-	return &Type{repcore.UnknownEnum(ID), ID}
+	return unknownTypes.Get(ID, func(ID byte) *Type { return &Type{repcore.UnknownEnum(ID), ID} })
 }
@@ -2,7 +2,10 @@
 
 package repcmd
 
-import "github.com/icza/screp/rep/repcore"
+import (
+	"github.com/icza/screp/internal/intern"
+	"github.com/icza/screp/rep/repcore"
+)
 
 // Unit describes the unit.
 type Unit struct {
@@ -325,14 +328,17 @@ const (
 	UnitIDNone = 0xE4
 )
 
+// unknownUnits holds the Units of unknown IDs.
+var unknownUnits intern.Pool[uint16, *Unit]
+
 // UnitByID returns the Unit for a given ID.
-// A new Unit with Unknown name is returned if one is not found
-// for the given ID (preserving the unknown ID).
+// An Unit with Unknown name is returned if one is not found
+// for the given ID (preserving the unknown ID), the same one for the same ID.
 func UnitByID(ID uint16) *Unit {
 	if u := unitIDUnit[ID]; u != nil {
 		return u
 	}
-	return &Unit{repcore.UnknownEnum(ID), ID}
+	return unknownUnits.Get(ID, func(ID uint16) *Unit { return &Unit{repcore.UnknownEnum(ID), ID} })
 }
 
 // unitIDRace maps from unit ID to owner race.
@@ -2,7 +2,10 @@
 
 package repcmd
 
-import "github.com/icza/screp/rep/repcore"
+import (
+	"github.com/icza/screp/internal/intern"
+	"github.com/icza/screp/rep/repcore"
+)
 
 // Upgrade describes the upgrade.
 type Upgrade struct {
@@ -75,12 +78,15 @@ func init() {
 	}
 }
 
+// unknownUpgrades holds the Upgrades of unknown IDs.
+var unknownUpgrades intern.Pool[byte, *Upgrade]
+
 // UpgradeByID returns the Upgrade for a given ID.
-// A new Upgrade with Unknown name is returned if one is not found
-// for the given ID (preserving the unknown ID).
+// An Upgrade with Unknown name is returned if one is not found
+// for the given ID (preserving the unknown ID), the same one for the same ID.
 func UpgradeByID(ID byte) *Upgrade {
 	if u := upgradeIDUpgrade[ID]; u != nil {
 		return u
 	}
-	return &Upgrade{repcore.UnknownEnum(ID), ID}
+	return unknownUpgrades.Get(ID, func(ID byte) *Upgrade { return &Upgrade{repcore.UnknownEnum(ID), ID} })
 }
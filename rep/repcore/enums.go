@@ -5,6 +5,9 @@ package repcore
 import (
 	"bytes"
 	"fmt"
+	"time"
+
+	"github.com/icza/screp/internal/intern"
 )
 
 // Enum is the base / common part of enum types.
@@ -23,9 +26,9 @@ func (e Enum) String() string {
 //
 //	"Unknown 0xID"
 //
-// ID must be an integer number.
+// ID must be an integer number. Names are interned, so the enums of the same unknown ID share their name.
 func UnknownEnum(ID any) Enum {
-	return Enum{fmt.Sprintf("Unknown 0x%x", ID)}
+	return Enum{intern.String(fmt.Sprintf("Unknown 0x%x", ID))}
 }
 
 // Engine is the StarCraft engine / extension.
@@ -101,6 +104,19 @@ func SpeedByID(ID byte) *Speed {
 	return &Speed{UnknownEnum(ID), ID}
 }
 
+// speedFrameMillis holds the (real-time) duration of a frame in milliseconds for each speed ID.
+var speedFrameMillis = []int64{167, 111, 83, 67, 56, 48, frameMillis}
+
+// FrameDuration returns the (real-time) duration of a frame at the speed.
+// The duration at Fastest speed is returned for unknown speeds and for nil.
+func (s *Speed) FrameDuration() time.Duration {
+	ms := int64(frameMillis)
+	if s != nil && int(s.ID) < len(speedFrameMillis) {
+		ms = speedFrameMillis[s.ID]
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
 // GameType is the game type.
 type GameType struct {
 	Enum
@@ -352,6 +368,56 @@ func ColorByFootprint(footprint []byte) *Color {
 	return nil
 }
 
+// Footprint returns the footprint of the color in the player colors section,
+// nil if it's not known.
+func (c *Color) Footprint() []byte {
+	return bytes.Clone(c.footprint)
+}
+
+// ColorSource describes where the color of a player comes from.
+// This is not stored in replays, this is a calculated property.
+type ColorSource struct {
+	Enum
+
+	// ID of the color source
+	ID byte
+}
+
+// ColorSources is an enumeration of the possible color sources
+var ColorSources = []*ColorSource{
+	{Enum{"Header"}, 0x00},
+	{Enum{"CCLR"}, 0x01},
+	{Enum{"Map"}, 0x02},
+	{Enum{"Default"}, 0x03},
+}
+
+// Named color sources
+var (
+	// ColorSourceHeader means the color comes from the replay header.
+	ColorSourceHeader = ColorSources[0]
+
+	// ColorSourceCCLR means the color comes from the player colors section (CCLR) of modern replays.
+	ColorSourceCCLR = ColorSources[1]
+
+	// ColorSourceMap means the color is forced by the map (COLR sub-section).
+	ColorSourceMap = ColorSources[2]
+
+	// ColorSourceDefault means the color is the default color of the slot: the header
+	// only stores colors of the first 8 slots, slots 9-12 (used e.g. by UMS games)
+	// have the color of their slot unless the player colors section (CCLR) tells otherwise.
+	ColorSourceDefault = ColorSources[3]
+)
+
+// ColorSourceByID returns the ColorSource for a given ID.
+// A new ColorSource with Unknown name is returned if one is not found
+// for the given ID (preserving the unknown ID).
+func ColorSourceByID(ID byte) *ColorSource {
+	if int(ID) < len(ColorSources) {
+		return ColorSources[ID]
+	}
+	return &ColorSource{UnknownEnum(ID), ID}
+}
+
 // TileSet describes a tile set.
 type TileSet struct {
 	Enum
@@ -479,3 +545,144 @@ func PlayerSideByID(ID uint8) *PlayerSide {
 	}
 	return &PlayerSide{UnknownEnum(ID), ID}
 }
+
+// Result describes the result of a game (for a player or a team).
+// This is not stored in replays, this is a calculated property.
+type Result struct {
+	Enum
+
+	// ID of the result
+	ID byte
+}
+
+// Results is an enumeration of the possible results
+var Results = []*Result{
+	{Enum{"Unknown"}, 0x00},
+	{Enum{"Win"}, 0x01},
+	{Enum{"Loss"}, 0x02},
+}
+
+// Named results
+var (
+	ResultUnknown = Results[0]
+	ResultWin     = Results[1]
+	ResultLoss    = Results[2]
+)
+
+// ResultByID returns the Result for a given ID.
+// A new Result with Unknown name is returned if one is not found
+// for the given ID (preserving the unknown ID).
+func ResultByID(ID byte) *Result {
+	if int(ID) < len(Results) {
+		return Results[ID]
+	}
+	return &Result{UnknownEnum(ID), ID}
+}
+
+// MapAnomalyType describes a type of map protection / corruption artifact found in map data.
+type MapAnomalyType struct {
+	Enum
+
+	// ID of the map anomaly type
+	ID byte
+}
+
+// MapAnomalyTypes is an enumeration of the possible map anomaly types.
+var MapAnomalyTypes = []*MapAnomalyType{
+	{Enum{"Duplicate Section"}, 0x00},
+	{Enum{"Missing Section"}, 0x01},
+	{Enum{"Invalid Section Size"}, 0x02},
+	{Enum{"Unknown Section"}, 0x03},
+	{Enum{"Invalid String"}, 0x04},
+}
+
+// Named map anomaly types
+var (
+	MapAnomalyTypeDuplicateSection = MapAnomalyTypes[0]
+	MapAnomalyTypeMissingSection   = MapAnomalyTypes[1]
+	MapAnomalyTypeInvalidSize      = MapAnomalyTypes[2]
+	MapAnomalyTypeUnknownSection   = MapAnomalyTypes[3]
+	MapAnomalyTypeInvalidString    = MapAnomalyTypes[4]
+)
+
+// MapAnomalyTypeByID returns the MapAnomalyType for a given ID.
+// A new MapAnomalyType with Unknown name is returned if one is not found
+// for the given ID (preserving the unknown ID).
+func MapAnomalyTypeByID(ID byte) *MapAnomalyType {
+	if int(ID) < len(MapAnomalyTypes) {
+		return MapAnomalyTypes[ID]
+	}
+	return &MapAnomalyType{UnknownEnum(ID), ID}
+}
+
+// MapConnectivity describes the ground connectivity of the start locations of a map.
+// This is not stored in replays, this is a calculated property.
+type MapConnectivity struct {
+	Enum
+
+	// ID of the map connectivity
+	ID byte
+}
+
+// MapConnectivities is an enumeration of the possible map connectivities.
+var MapConnectivities = []*MapConnectivity{
+	{Enum{"Ground"}, 0x00},
+	{Enum{"Semi-Island"}, 0x01},
+	{Enum{"Island"}, 0x02},
+}
+
+// Named map connectivities
+var (
+	// MapConnectivityGround means all start locations are connected by ground.
+	MapConnectivityGround = MapConnectivities[0]
+
+	// MapConnectivitySemiIsland means some start locations are connected by ground, but not all.
+	MapConnectivitySemiIsland = MapConnectivities[1]
+
+	// MapConnectivityIsland means no start locations are connected by ground.
+	MapConnectivityIsland = MapConnectivities[2]
+)
+
+// MapConnectivityByID returns the MapConnectivity for a given ID.
+// A new MapConnectivity with Unknown name is returned if one is not found
+// for the given ID (preserving the unknown ID).
+func MapConnectivityByID(ID byte) *MapConnectivity {
+	if int(ID) < len(MapConnectivities) {
+		return MapConnectivities[ID]
+	}
+	return &MapConnectivity{UnknownEnum(ID), ID}
+}
+
+// DistanceMode tells how distances on a map are measured.
+// This is not stored in replays, this is a calculated property.
+type DistanceMode struct {
+	Enum
+
+	// ID of the distance mode
+	ID byte
+}
+
+// DistanceModes is an enumeration of the possible distance modes.
+var DistanceModes = []*DistanceMode{
+	{Enum{"Air"}, 0x00},
+	{Enum{"Ground"}, 0x01},
+}
+
+// Named distance modes
+var (
+	// DistanceModeAir means straight line distances, ignoring terrain.
+	DistanceModeAir = DistanceModes[0]
+
+	// DistanceModeGround means walking distances computed on the walkability grid.
+	DistanceModeGround = DistanceModes[1]
+)
+
+// DistanceModeByID returns the DistanceMode for a given ID.
+// A new DistanceMode with Unknown name is returned if one is not found
+// for the given ID (preserving the unknown ID).
+func DistanceModeByID(ID byte) *DistanceMode {
+	if int(ID) < len(DistanceModes) {
+		return DistanceModes[ID]
+	}
+	return &DistanceMode{UnknownEnum(ID), ID}
+}
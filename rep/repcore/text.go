@@ -0,0 +1,116 @@
+// This file contains the styled text model of game and map names.
+
+package repcore
+
+import "strings"
+
+// TextColor describes a text color control code.
+type TextColor struct {
+	Enum
+
+	// ID is the control code (byte value) as it appears in texts
+	ID byte
+
+	// RGB is the red, green, blue component of the color
+	RGB uint32
+}
+
+// TextColors is an enumeration of the known text color control codes.
+var TextColors = []*TextColor{
+	{Enum{"Default"}, 0x01, 0xb8b8e8},
+	{Enum{"Pale Blue"}, 0x02, 0xb8b8e8},
+	{Enum{"Yellow"}, 0x03, 0xdcdc3c},
+	{Enum{"White"}, 0x04, 0xffffff},
+	{Enum{"Grey"}, 0x05, 0x848474},
+	{Enum{"Red"}, 0x06, 0xc81818},
+	{Enum{"Green"}, 0x07, 0x10fc18},
+	{Enum{"Red (Player 1)"}, 0x08, 0xf40404},
+	{Enum{"Invisible"}, 0x0b, 0},
+	{Enum{"Blue (Player 2)"}, 0x0e, 0x0c48cc},
+	{Enum{"Teal (Player 3)"}, 0x0f, 0x2cb494},
+	{Enum{"Purple (Player 4)"}, 0x10, 0x88409c},
+	{Enum{"Orange (Player 5)"}, 0x11, 0xf88c14},
+	{Enum{"Invisible"}, 0x14, 0},
+	{Enum{"Brown (Player 6)"}, 0x15, 0x703014},
+	{Enum{"White (Player 7)"}, 0x16, 0xcce0d0},
+	{Enum{"Yellow (Player 8)"}, 0x17, 0xfcfc38},
+	{Enum{"Green (Player 9)"}, 0x18, 0x088008},
+	{Enum{"Pale Yellow (Player 10)"}, 0x19, 0xfcfc7c},
+	{Enum{"Tan (Player 11)"}, 0x1b, 0xecc4b0},
+	{Enum{"Aqua (Player 12)"}, 0x1c, 0x4068d4},
+	{Enum{"Pale Green"}, 0x1d, 0x74a47c},
+	{Enum{"Blueish Grey"}, 0x1e, 0x9090b8},
+	{Enum{"Cyan"}, 0x1f, 0x00e4fc},
+}
+
+// textColorsByID maps from control code to text color.
+var textColorsByID = map[byte]*TextColor{}
+
+func init() {
+	for _, tc := range TextColors {
+		textColorsByID[tc.ID] = tc
+	}
+}
+
+// TextColorByID returns the TextColor for a given control code.
+// nil is returned if the control code is not a known text color.
+func TextColorByID(ID byte) *TextColor {
+	return textColorsByID[ID]
+}
+
+// StyledText is a text with its color control codes resolved.
+type StyledText struct {
+	// Plain is the text without any control codes
+	Plain string
+
+	// Segments of the text, each having a single color
+	Segments []*TextSegment
+}
+
+// TextSegment is a part of a styled text having a single color.
+type TextSegment struct {
+	// Color of the segment, nil means the default color
+	Color *TextColor `json:",omitempty"`
+
+	// Text of the segment (without control codes)
+	Text string
+}
+
+// ParseStyledText parses a text embedding color and formatting control codes
+// (bytes below 0x20) such as game and map names.
+//
+// A color code applies to the text following it, up to the next color code.
+// Tabs and newlines are preserved, other control codes (e.g. alignment) are dropped.
+func ParseStyledText(s string) *StyledText {
+	st := &StyledText{}
+
+	var (
+		plain, seg strings.Builder
+		color      *TextColor
+	)
+	flush := func() {
+		if seg.Len() == 0 {
+			return
+		}
+		st.Segments = append(st.Segments, &TextSegment{Color: color, Text: seg.String()})
+		seg.Reset()
+	}
+
+	// Control codes are ASCII, so iterating over bytes is safe for UTF-8 texts:
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if ch >= 0x20 || ch == '\t' || ch == '\n' {
+			plain.WriteByte(ch)
+			seg.WriteByte(ch)
+			continue
+		}
+		if tc := TextColorByID(ch); tc != nil && tc != color {
+			flush()
+			color = tc
+		}
+	}
+	flush()
+
+	st.Plain = plain.String()
+	return st
+}
@@ -0,0 +1,40 @@
+package repcore
+
+import "testing"
+
+func TestParseStyledText(t *testing.T) {
+	cases := []struct {
+		name     string
+		s        string
+		plain    string
+		segments []*TextSegment
+	}{
+		{"empty", "", "", nil},
+		{"plain", "Fighting Spirit", "Fighting Spirit", []*TextSegment{{nil, "Fighting Spirit"}}},
+		{
+			"colored", "\x03Polypoid \x06v1.65\x06!", "Polypoid v1.65!",
+			[]*TextSegment{{TextColorByID(0x03), "Polypoid "}, {TextColorByID(0x06), "v1.65!"}},
+		},
+		{
+			"formatting", "\x13\x08Red\x12\tEnd\x03", "Red\tEnd",
+			[]*TextSegment{{TextColorByID(0x08), "Red\tEnd"}},
+		},
+		{"unicode", "\x1f투혼", "투혼", []*TextSegment{{TextColorByID(0x1f), "투혼"}}},
+	}
+
+	for _, c := range cases {
+		st := ParseStyledText(c.s)
+		if st.Plain != c.plain {
+			t.Errorf("[%s] Expected: %q, got: %q", c.name, c.plain, st.Plain)
+		}
+		if len(st.Segments) != len(c.segments) {
+			t.Errorf("[%s] Expected: %d segments, got: %d", c.name, len(c.segments), len(st.Segments))
+			continue
+		}
+		for i, seg := range st.Segments {
+			if *seg != *c.segments[i] {
+				t.Errorf("[%s] [%d] Expected: %+v, got: %+v", c.name, i, c.segments[i], seg)
+			}
+		}
+	}
+}
@@ -0,0 +1,392 @@
+// This file contains enum types used by map triggers.
+
+package repcore
+
+// TriggerConditionType describes a trigger condition type.
+type TriggerConditionType struct {
+	Enum
+
+	// ID as it appears in map data
+	ID byte
+}
+
+// TriggerConditionTypes is an enumeration of the possible trigger condition types.
+var TriggerConditionTypes = []*TriggerConditionType{
+	{Enum{"None"}, 0x00},
+	{Enum{"Countdown Timer"}, 0x01},
+	{Enum{"Command"}, 0x02},
+	{Enum{"Bring"}, 0x03},
+	{Enum{"Accumulate"}, 0x04},
+	{Enum{"Kill"}, 0x05},
+	{Enum{"Command the Most"}, 0x06},
+	{Enum{"Command the Most At"}, 0x07},
+	{Enum{"Most Kills"}, 0x08},
+	{Enum{"Highest Score"}, 0x09},
+	{Enum{"Most Resources"}, 0x0a},
+	{Enum{"Switch"}, 0x0b},
+	{Enum{"Elapsed Time"}, 0x0c},
+	{Enum{"Mission Briefing"}, 0x0d},
+	{Enum{"Opponents"}, 0x0e},
+	{Enum{"Deaths"}, 0x0f},
+	{Enum{"Command the Least"}, 0x10},
+	{Enum{"Command the Least At"}, 0x11},
+	{Enum{"Least Kills"}, 0x12},
+	{Enum{"Lowest Score"}, 0x13},
+	{Enum{"Least Resources"}, 0x14},
+	{Enum{"Score"}, 0x15},
+	{Enum{"Always"}, 0x16},
+	{Enum{"Never"}, 0x17},
+}
+
+// TriggerConditionTypeByID returns the TriggerConditionType for a given ID.
+// A new TriggerConditionType with Unknown name is returned if one is not found
+// for the given ID (preserving the unknown ID).
+func TriggerConditionTypeByID(ID byte) *TriggerConditionType {
+	if int(ID) < len(TriggerConditionTypes) {
+		return TriggerConditionTypes[ID]
+	}
+	return &TriggerConditionType{UnknownEnum(ID), ID}
+}
+
+// TriggerActionType describes a trigger action type.
+type TriggerActionType struct {
+	Enum
+
+	// ID as it appears in map data
+	ID byte
+}
+
+// TriggerActionTypes is an enumeration of the possible trigger action types.
+var TriggerActionTypes = []*TriggerActionType{
+	{Enum{"No Action"}, 0x00},
+	{Enum{"Victory"}, 0x01},
+	{Enum{"Defeat"}, 0x02},
+	{Enum{"Preserve Trigger"}, 0x03},
+	{Enum{"Wait"}, 0x04},
+	{Enum{"Pause Game"}, 0x05},
+	{Enum{"Unpause Game"}, 0x06},
+	{Enum{"Transmission"}, 0x07},
+	{Enum{"Play WAV"}, 0x08},
+	{Enum{"Display Text Message"}, 0x09},
+	{Enum{"Center View"}, 0x0a},
+	{Enum{"Create Unit with Properties"}, 0x0b},
+	{Enum{"Set Mission Objectives"}, 0x0c},
+	{Enum{"Set Switch"}, 0x0d},
+	{Enum{"Set Countdown Timer"}, 0x0e},
+	{Enum{"Run AI Script"}, 0x0f},
+	{Enum{"Run AI Script At Location"}, 0x10},
+	{Enum{"Leader Board (Control)"}, 0x11},
+	{Enum{"Leader Board (Control At Location)"}, 0x12},
+	{Enum{"Leader Board (Resources)"}, 0x13},
+	{Enum{"Leader Board (Kills)"}, 0x14},
+	{Enum{"Leader Board (Points)"}, 0x15},
+	{Enum{"Kill Unit"}, 0x16},
+	{Enum{"Kill Unit At Location"}, 0x17},
+	{Enum{"Remove Unit"}, 0x18},
+	{Enum{"Remove Unit At Location"}, 0x19},
+	{Enum{"Set Resources"}, 0x1a},
+	{Enum{"Set Score"}, 0x1b},
+	{Enum{"Minimap Ping"}, 0x1c},
+	{Enum{"Talking Portrait"}, 0x1d},
+	{Enum{"Mute Unit Speech"}, 0x1e},
+	{Enum{"Unmute Unit Speech"}, 0x1f},
+	{Enum{"Leaderboard Computer Players"}, 0x20},
+	{Enum{"Leaderboard Goal (Control)"}, 0x21},
+	{Enum{"Leaderboard Goal (Control At Location)"}, 0x22},
+	{Enum{"Leaderboard Goal (Resources)"}, 0x23},
+	{Enum{"Leaderboard Goal (Kills)"}, 0x24},
+	{Enum{"Leaderboard Goal (Points)"}, 0x25},
+	{Enum{"Move Location"}, 0x26},
+	{Enum{"Move Unit"}, 0x27},
+	{Enum{"Leaderboard (Greed)"}, 0x28},
+	{Enum{"Set Next Scenario"}, 0x29},
+	{Enum{"Set Doodad State"}, 0x2a},
+	{Enum{"Set Invincibility"}, 0x2b},
+	{Enum{"Create Unit"}, 0x2c},
+	{Enum{"Set Deaths"}, 0x2d},
+	{Enum{"Order"}, 0x2e},
+	{Enum{"Comment"}, 0x2f},
+	{Enum{"Give Units to Player"}, 0x30},
+	{Enum{"Modify Unit Hit Points"}, 0x31},
+	{Enum{"Modify Unit Energy"}, 0x32},
+	{Enum{"Modify Unit Shield Points"}, 0x33},
+	{Enum{"Modify Unit Resource Amount"}, 0x34},
+	{Enum{"Modify Unit Hanger Count"}, 0x35},
+	{Enum{"Pause Timer"}, 0x36},
+	{Enum{"Unpause Timer"}, 0x37},
+	{Enum{"Draw"}, 0x38},
+	{Enum{"Set Alliance Status"}, 0x39},
+	{Enum{"Disable Debug Mode"}, 0x3a},
+	{Enum{"Enable Debug Mode"}, 0x3b},
+}
+
+// TriggerActionTypeByID returns the TriggerActionType for a given ID.
+// A new TriggerActionType with Unknown name is returned if one is not found
+// for the given ID (preserving the unknown ID).
+func TriggerActionTypeByID(ID byte) *TriggerActionType {
+	if int(ID) < len(TriggerActionTypes) {
+		return TriggerActionTypes[ID]
+	}
+	return &TriggerActionType{UnknownEnum(ID), ID}
+}
+
+// BriefingActionTypes is an enumeration of the possible mission briefing trigger action types.
+// Briefing triggers (from the MBRF sub-section) use the same TriggerActionType model,
+// but their action type IDs have different meanings.
+var BriefingActionTypes = []*TriggerActionType{
+	{Enum{"No Action"}, 0x00},
+	{Enum{"Wait"}, 0x01},
+	{Enum{"Play WAV"}, 0x02},
+	{Enum{"Text Message"}, 0x03},
+	{Enum{"Mission Objectives"}, 0x04},
+	{Enum{"Show Portrait"}, 0x05},
+	{Enum{"Hide Portrait"}, 0x06},
+	{Enum{"Display Speaking Portrait"}, 0x07},
+	{Enum{"Transmission"}, 0x08},
+	{Enum{"Skip Tutorial Enabled"}, 0x09},
+}
+
+// BriefingActionTypeByID returns the briefing TriggerActionType for a given ID.
+// A new TriggerActionType with Unknown name is returned if one is not found
+// for the given ID (preserving the unknown ID).
+func BriefingActionTypeByID(ID byte) *TriggerActionType {
+	if int(ID) < len(BriefingActionTypes) {
+		return BriefingActionTypes[ID]
+	}
+	return &TriggerActionType{UnknownEnum(ID), ID}
+}
+
+// PlayerGroup describes a player or a group of players triggers may refer to.
+type PlayerGroup struct {
+	Enum
+
+	// ID as it appears in map data
+	ID uint32
+}
+
+// PlayerGroups is an enumeration of the possible player groups.
+var PlayerGroups = []*PlayerGroup{
+	{Enum{"Player 1"}, 0x00},
+	{Enum{"Player 2"}, 0x01},
+	{Enum{"Player 3"}, 0x02},
+	{Enum{"Player 4"}, 0x03},
+	{Enum{"Player 5"}, 0x04},
+	{Enum{"Player 6"}, 0x05},
+	{Enum{"Player 7"}, 0x06},
+	{Enum{"Player 8"}, 0x07},
+	{Enum{"Player 9"}, 0x08},
+	{Enum{"Player 10"}, 0x09},
+	{Enum{"Player 11"}, 0x0a},
+	{Enum{"Player 12"}, 0x0b},
+	{Enum{"None"}, 0x0c},
+	{Enum{"Current Player"}, 0x0d},
+	{Enum{"Foes"}, 0x0e},
+	{Enum{"Allies"}, 0x0f},
+	{Enum{"Neutral Players"}, 0x10},
+	{Enum{"All Players"}, 0x11},
+	{Enum{"Force 1"}, 0x12},
+	{Enum{"Force 2"}, 0x13},
+	{Enum{"Force 3"}, 0x14},
+	{Enum{"Force 4"}, 0x15},
+	{Enum{"Unused 1"}, 0x16},
+	{Enum{"Unused 2"}, 0x17},
+	{Enum{"Unused 3"}, 0x18},
+	{Enum{"Unused 4"}, 0x19},
+	{Enum{"Non Allied Victory Players"}, 0x1a},
+}
+
+// PlayerGroupByID returns the PlayerGroup for a given ID.
+// A new PlayerGroup with Unknown name is returned if one is not found
+// for the given ID (preserving the unknown ID).
+func PlayerGroupByID(ID uint32) *PlayerGroup {
+	if ID < uint32(len(PlayerGroups)) {
+		return PlayerGroups[ID]
+	}
+	return &PlayerGroup{UnknownEnum(ID), ID}
+}
+
+// Comparison describes a numeric comparison or a switch state used in trigger conditions.
+type Comparison struct {
+	Enum
+
+	// ID as it appears in map data
+	ID byte
+}
+
+// Comparisons is an enumeration of the possible comparisons.
+// Switch conditions store the expected switch state here (Set / Not Set).
+var Comparisons = []*Comparison{
+	{Enum{"At Least"}, 0x00},
+	{Enum{"At Most"}, 0x01},
+	{Enum{"Set"}, 0x02},
+	{Enum{"Not Set"}, 0x03},
+	{Enum{"Exactly"}, 0x0a},
+}
+
+// ComparisonByID returns the Comparison for a given ID.
+// A new Comparison with Unknown name is returned if one is not found
+// for the given ID (preserving the unknown ID).
+func ComparisonByID(ID byte) *Comparison {
+	for _, c := range Comparisons {
+		if c.ID == ID {
+			return c
+		}
+	}
+	return &Comparison{UnknownEnum(ID), ID}
+}
+
+// Modifier describes a number or state modifier used in trigger actions.
+type Modifier struct {
+	Enum
+
+	// ID as it appears in map data
+	ID byte
+}
+
+// Modifiers is an enumeration of the possible number and state modifiers.
+var Modifiers = []*Modifier{
+	{Enum{"Enable"}, 0x04},
+	{Enum{"Disable"}, 0x05},
+	{Enum{"Toggle"}, 0x06},
+	{Enum{"Set To"}, 0x07},
+	{Enum{"Add"}, 0x08},
+	{Enum{"Subtract"}, 0x09},
+}
+
+// ModifierByID returns the Modifier for a given ID.
+// A new Modifier with Unknown name is returned if one is not found
+// for the given ID (preserving the unknown ID).
+func ModifierByID(ID byte) *Modifier {
+	for _, m := range Modifiers {
+		if m.ID == ID {
+			return m
+		}
+	}
+	return &Modifier{UnknownEnum(ID), ID}
+}
+
+// SwitchModifiers is an enumeration of the possible switch modifiers
+// (used by the Set Switch action).
+var SwitchModifiers = []*Modifier{
+	{Enum{"Set"}, 0x04},
+	{Enum{"Clear"}, 0x05},
+	{Enum{"Toggle"}, 0x06},
+	{Enum{"Randomize"}, 0x0b},
+}
+
+// SwitchModifierByID returns the switch Modifier for a given ID.
+// A new Modifier with Unknown name is returned if one is not found
+// for the given ID (preserving the unknown ID).
+func SwitchModifierByID(ID byte) *Modifier {
+	for _, m := range SwitchModifiers {
+		if m.ID == ID {
+			return m
+		}
+	}
+	return &Modifier{UnknownEnum(ID), ID}
+}
+
+// ResourceType describes a resource type used in triggers.
+type ResourceType struct {
+	Enum
+
+	// ID as it appears in map data
+	ID uint16
+}
+
+// ResourceTypes is an enumeration of the possible resource types.
+var ResourceTypes = []*ResourceType{
+	{Enum{"Ore"}, 0x00},
+	{Enum{"Gas"}, 0x01},
+	{Enum{"Ore and Gas"}, 0x02},
+}
+
+// ResourceTypeByID returns the ResourceType for a given ID.
+// A new ResourceType with Unknown name is returned if one is not found
+// for the given ID (preserving the unknown ID).
+func ResourceTypeByID(ID uint16) *ResourceType {
+	if int(ID) < len(ResourceTypes) {
+		return ResourceTypes[ID]
+	}
+	return &ResourceType{UnknownEnum(ID), ID}
+}
+
+// ScoreType describes a score type used in triggers.
+type ScoreType struct {
+	Enum
+
+	// ID as it appears in map data
+	ID uint16
+}
+
+// ScoreTypes is an enumeration of the possible score types.
+var ScoreTypes = []*ScoreType{
+	{Enum{"Total"}, 0x00},
+	{Enum{"Units"}, 0x01},
+	{Enum{"Buildings"}, 0x02},
+	{Enum{"Units and Buildings"}, 0x03},
+	{Enum{"Kills"}, 0x04},
+	{Enum{"Razings"}, 0x05},
+	{Enum{"Kills and Razings"}, 0x06},
+	{Enum{"Custom"}, 0x07},
+}
+
+// ScoreTypeByID returns the ScoreType for a given ID.
+// A new ScoreType with Unknown name is returned if one is not found
+// for the given ID (preserving the unknown ID).
+func ScoreTypeByID(ID uint16) *ScoreType {
+	if int(ID) < len(ScoreTypes) {
+		return ScoreTypes[ID]
+	}
+	return &ScoreType{UnknownEnum(ID), ID}
+}
+
+// AllianceStatus describes an alliance status used by the Set Alliance Status action.
+type AllianceStatus struct {
+	Enum
+
+	// ID as it appears in map data
+	ID uint16
+}
+
+// AllianceStatuses is an enumeration of the possible alliance statuses.
+var AllianceStatuses = []*AllianceStatus{
+	{Enum{"Enemy"}, 0x00},
+	{Enum{"Ally"}, 0x01},
+	{Enum{"Allied Victory"}, 0x02},
+}
+
+// AllianceStatusByID returns the AllianceStatus for a given ID.
+// A new AllianceStatus with Unknown name is returned if one is not found
+// for the given ID (preserving the unknown ID).
+func AllianceStatusByID(ID uint16) *AllianceStatus {
+	if int(ID) < len(AllianceStatuses) {
+		return AllianceStatuses[ID]
+	}
+	return &AllianceStatus{UnknownEnum(ID), ID}
+}
+
+// TriggerOrder describes a unit order issued by the Order action.
+type TriggerOrder struct {
+	Enum
+
+	// ID as it appears in map data
+	ID byte
+}
+
+// TriggerOrders is an enumeration of the possible trigger orders.
+var TriggerOrders = []*TriggerOrder{
+	{Enum{"Move"}, 0x00},
+	{Enum{"Patrol"}, 0x01},
+	{Enum{"Attack"}, 0x02},
+}
+
+// TriggerOrderByID returns the TriggerOrder for a given ID.
+// A new TriggerOrder with Unknown name is returned if one is not found
+// for the given ID (preserving the unknown ID).
+func TriggerOrderByID(ID byte) *TriggerOrder {
+	if int(ID) < len(TriggerOrders) {
+		return TriggerOrders[ID]
+	}
+	return &TriggerOrder{UnknownEnum(ID), ID}
+}
@@ -4,14 +4,21 @@ package repcore
 
 import (
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
 	"time"
 )
 
 // Frame is the basic time unit in StarCraft.
 // There are approximately ~23.81 frames in a second;
 // 1 frame = 0.042 second = 42 ms to be exact.
+// This is at Fastest speed (which multiplayer games are played at), see Speed.FrameDuration().
 type Frame int32
 
+// frameMillis is the duration of a frame in milliseconds at Fastest speed.
+const frameMillis = 42
+
 // Seconds returns the time equivalent to the frames in seconds.
 func (f Frame) Seconds() float64 {
 	return float64(f.Milliseconds()) / 1000
@@ -19,7 +26,7 @@ func (f Frame) Seconds() float64 {
 
 // Milliseconds returns the time equivalent to the frames in milliseconds.
 func (f Frame) Milliseconds() int64 {
-	return int64(f) * 42
+	return int64(f) * frameMillis
 }
 
 // Duration returns the frame as a time.Duration value.
@@ -38,11 +45,113 @@ func (f Frame) String() string {
 	return fmt.Sprintf("%d:%02d:%02d", min/60, min%60, sec%60)
 }
 
+// Add returns the frame f+d (d is rounded down to frames).
+func (f Frame) Add(d time.Duration) Frame {
+	return f + Duration2Frame(d)
+}
+
+// Sub returns the duration f-g.
+func (f Frame) Sub(g Frame) time.Duration {
+	return (f - g).Duration()
+}
+
+// DurationAt returns the (real-time) duration of the frames at the given speed,
+// see Speed.FrameDuration().
+func (f Frame) DurationAt(speed *Speed) time.Duration {
+	return time.Duration(f) * speed.FrameDuration()
+}
+
 // Duration2Frame converts a Duration value to Frame.
 func Duration2Frame(d time.Duration) Frame {
-	return Frame(d.Milliseconds() / 42)
+	return Frame(d.Milliseconds() / frameMillis)
+}
+
+// FrameFromDuration converts a (real-time) duration to Frame at the given speed
+// (rounded down), see Speed.FrameDuration().
+func FrameFromDuration(d time.Duration, speed *Speed) Frame {
+	return Frame(d / speed.FrameDuration())
+}
+
+// ParseFrame parses a game time given in the format of Frame.String() ("mm:ss" or "h:mm:ss"),
+// or as a Go duration (e.g. "12m30s"). The game time is rounded down to frames.
+func ParseFrame(s string) (Frame, error) {
+	if !strings.Contains(s, ":") {
+		d, err := time.ParseDuration(s)
+		if err != nil || d < 0 {
+			return 0, fmt.Errorf("invalid game time: %q", s)
+		}
+		return Duration2Frame(d), nil
+	}
+
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid game time: %q", s)
+	}
+	var sec int
+	for _, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid game time: %q", s)
+		}
+		sec = sec*60 + n
+	}
+	return Duration2Frame(time.Duration(sec) * time.Second), nil
+}
+
+// FrameRange is a range of frames: From is inclusive, To is exclusive.
+type FrameRange struct {
+	From, To Frame
+}
+
+// ParseFrameRange parses a frame range given as 2 game times separated by a dash
+// (e.g. "12:00-15:30" or "5m-10m"), see ParseFrame().
+func ParseFrameRange(s string) (FrameRange, error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return FrameRange{}, fmt.Errorf("invalid frame range: %q", s)
+	}
+	var fr FrameRange
+	var err error
+	if fr.From, err = ParseFrame(from); err != nil {
+		return FrameRange{}, err
+	}
+	if fr.To, err = ParseFrame(to); err != nil {
+		return FrameRange{}, err
+	}
+	if fr.To < fr.From {
+		return FrameRange{}, fmt.Errorf("invalid frame range (end before start): %q", s)
+	}
+	return fr, nil
+}
+
+// Contains tells if f is in the range.
+func (fr FrameRange) Contains(f Frame) bool {
+	return fr.From <= f && f < fr.To
+}
+
+// Len returns the number of frames in the range (0 if To is not after From).
+func (fr FrameRange) Len() Frame {
+	return max(fr.To-fr.From, 0)
 }
 
+// Duration returns the duration of the range.
+func (fr FrameRange) Duration() time.Duration {
+	return fr.Len().Duration()
+}
+
+// Overlaps tells if the range has frames in common with the other range.
+func (fr FrameRange) Overlaps(other FrameRange) bool {
+	return max(fr.From, other.From) < min(fr.To, other.To)
+}
+
+// String returns the range in the form of "From-To", e.g. "12:00-15:30".
+func (fr FrameRange) String() string {
+	return fr.From.String() + "-" + fr.To.String()
+}
+
+// TileSize is the size of a tile in pixels (the unit of Point coordinates).
+const TileSize = 32
+
 // Point describes a point in the map.
 type Point struct {
 	// X and Y coordinates of the point
@@ -50,6 +159,31 @@ type Point struct {
 	X, Y uint16
 }
 
+// Distance returns the air (Euclidean) distance between 2 points in pixels, rounded to the nearest integer.
+func (p Point) Distance(q Point) int {
+	dx, dy := float64(p.X)-float64(q.X), float64(p.Y)-float64(q.Y)
+	return int(math.Round(math.Sqrt(dx*dx + dy*dy)))
+}
+
+// TileCoords returns the coordinates of the tile containing the point.
+func (p Point) TileCoords() (x, y uint16) {
+	return p.X / TileSize, p.Y / TileSize
+}
+
+// WithinRect tells if the point is within the rectangle given by its boundaries in pixels
+// (e.g. the boundaries of a location). Left and Top are inclusive, Right and Bottom are exclusive.
+// Inverted boundaries (e.g. Left > Right) are swapped.
+func (p Point) WithinRect(left, top, right, bottom uint32) bool {
+	if left > right {
+		left, right = right, left
+	}
+	if top > bottom {
+		top, bottom = bottom, top
+	}
+	x, y := uint32(p.X), uint32(p.Y)
+	return left <= x && x < right && top <= y && y < bottom
+}
+
 // String returns a string representation of the point in the format:
 //
 //	"x=X, y=Y"
@@ -0,0 +1,171 @@
+package repcore
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseFrame(t *testing.T) {
+	cases := []struct {
+		s     string
+		frame Frame
+		ok    bool
+	}{
+		{"0", 0, true},
+		{"12:00", 17142, true},
+		{"1:02:03", 88642, true},
+		{"12m", 17142, true},
+		{"90s", 2142, true},
+		{"", 0, false},
+		{"12:x", 0, false},
+		{"-1m", 0, false},
+		{"1:2:3:4", 0, false},
+	}
+
+	for _, c := range cases {
+		frame, err := ParseFrame(c.s)
+		if ok := err == nil; ok != c.ok || frame != c.frame {
+			t.Errorf("[%q] Expected: %v %v, got: %v %v", c.s, c.frame, c.ok, frame, ok)
+		}
+	}
+}
+
+func TestFrameArithmetic(t *testing.T) {
+	f := Frame(1000)
+	if got, exp := f.Add(time.Second), Frame(1023); got != exp {
+		t.Errorf("Expected: %v, got: %v", exp, got)
+	}
+	if got, exp := f.Add(-420*time.Millisecond), Frame(990); got != exp {
+		t.Errorf("Expected: %v, got: %v", exp, got)
+	}
+	if got, exp := f.Sub(900), 4200*time.Millisecond; got != exp {
+		t.Errorf("Expected: %v, got: %v", exp, got)
+	}
+	if got, exp := Frame(900).Sub(f), -4200*time.Millisecond; got != exp {
+		t.Errorf("Expected: %v, got: %v", exp, got)
+	}
+}
+
+func TestFrameSpeed(t *testing.T) {
+	cases := []struct {
+		speed *Speed
+		d     time.Duration
+		frame Frame
+	}{
+		{SpeedFastest, time.Minute, 1428},
+		{nil, time.Minute, 1428},
+		{SpeedByID(0), time.Minute, 359},     // Slowest
+		{SpeedByID(4), time.Minute, 1071},    // Normal
+		{SpeedByID(0xff), time.Minute, 1428}, // Unknown
+		{SpeedFastest, 41 * time.Millisecond, 0},
+	}
+
+	for _, c := range cases {
+		if got := FrameFromDuration(c.d, c.speed); got != c.frame {
+			t.Errorf("[%v] Expected: %v, got: %v", c.speed, c.frame, got)
+		}
+		// Converting back loses at most a frame's duration:
+		if back := c.frame.DurationAt(c.speed); back > c.d || c.d-back >= c.speed.FrameDuration() {
+			t.Errorf("[%v] Expected: ~%v, got: %v", c.speed, c.d, back)
+		}
+	}
+}
+
+func TestFrameRange(t *testing.T) {
+	fr, err := ParseFrameRange("12:00-15m")
+	if err != nil {
+		t.Fatalf("Failed to parse frame range: %v", err)
+	}
+	if exp := (FrameRange{17142, 21428}); fr != exp {
+		t.Errorf("Expected: %v, got: %v", exp, fr)
+	}
+	if got, exp := fr.String(), "11:59-14:59"; got != exp { // Frames are rounded down both ways
+		t.Errorf("Expected: %v, got: %v", exp, got)
+	}
+	if got, exp := (FrameRange{0, 1428}).String(), "00:00-00:59"; got != exp {
+		t.Errorf("Expected: %v, got: %v", exp, got)
+	}
+	if got, exp := fr.Len(), Frame(4286); got != exp {
+		t.Errorf("Expected: %v, got: %v", exp, got)
+	}
+
+	for _, s := range []string{"12:00", "15:00-12:00", "x-12:00", "12:00-"} {
+		if _, err := ParseFrameRange(s); err == nil {
+			t.Errorf("[%q] Expected error", s)
+		}
+	}
+
+	containsCases := []struct {
+		f   Frame
+		exp bool
+	}{{17141, false}, {17142, true}, {21427, true}, {21428, false}}
+	for _, c := range containsCases {
+		if got := fr.Contains(c.f); got != c.exp {
+			t.Errorf("[%v] Expected: %v, got: %v", c.f, c.exp, got)
+		}
+	}
+
+	overlapCases := []struct {
+		other FrameRange
+		exp   bool
+	}{
+		{FrameRange{0, 17142}, false},
+		{FrameRange{0, 17143}, true},
+		{FrameRange{18000, 19000}, true},
+		{FrameRange{21427, 30000}, true},
+		{FrameRange{21428, 30000}, false},
+		{FrameRange{18000, 18000}, false}, // Empty
+	}
+	for _, c := range overlapCases {
+		if got := fr.Overlaps(c.other); got != c.exp {
+			t.Errorf("[%v] Expected: %v, got: %v", c.other, c.exp, got)
+		}
+	}
+}
+
+func TestPoint(t *testing.T) {
+	p := Point{X: 100, Y: 70}
+
+	distCases := []struct {
+		q   Point
+		exp int
+	}{
+		{p, 0},
+		{Point{X: 130, Y: 110}, 50},
+		{Point{X: 0, Y: 0}, 122},
+		{Point{X: 65535, Y: 65535}, 92560},
+	}
+	for _, c := range distCases {
+		if got := p.Distance(c.q); got != c.exp {
+			t.Errorf("[%v] Expected: %v, got: %v", c.q, c.exp, got)
+		}
+		if got := c.q.Distance(p); got != c.exp {
+			t.Errorf("[%v] Expected: %v, got: %v", c.q, c.exp, got)
+		}
+	}
+
+	if x, y := p.TileCoords(); x != 3 || y != 2 {
+		t.Errorf("Expected: %v %v, got: %v %v", 3, 2, x, y)
+	}
+	if x, y := (Point{X: 31, Y: 32}).TileCoords(); x != 0 || y != 1 {
+		t.Errorf("Expected: %v %v, got: %v %v", 0, 1, x, y)
+	}
+
+	rectCases := []struct {
+		name                     string
+		left, top, right, bottom uint32
+		exp                      bool
+	}{
+		{"inside", 0, 0, 200, 200, true},
+		{"left top edge", 100, 70, 200, 200, true},
+		{"right edge", 0, 0, 100, 200, false},
+		{"bottom edge", 0, 0, 200, 70, false},
+		{"outside", 200, 200, 300, 300, false},
+		{"inverted", 200, 200, 0, 0, true},
+	}
+	for _, c := range rectCases {
+		if got := p.WithinRect(c.left, c.top, c.right, c.bottom); got != c.exp {
+			t.Errorf("[%s] Expected: %v, got: %v", c.name, c.exp, got)
+		}
+	}
+}
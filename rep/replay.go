@@ -5,6 +5,7 @@ package rep
 
 import (
 	"bytes"
+	"cmp"
 	"fmt"
 	"math"
 	"slices"
@@ -40,6 +41,18 @@ type Replay struct {
 	ShieldBattery *ShieldBattery `json:",omitempty"`
 }
 
+// Reset resets the replay to its zero value except its Commands, which are reset (see Commands.Reset())
+// and retained, so long-running services may reuse replays (e.g. from a sync.Pool) when parsing
+// (see repparser.ParseConfigInto()) without reallocating the large commands slice.
+func (r *Replay) Reset() {
+	cs := r.Commands
+	*r = Replay{}
+	if cs != nil {
+		cs.Reset()
+		r.Commands = cs
+	}
+}
+
 // Set of lowered and cleaned map names that use the UMS random teams feature.
 // Transformation on map names to obtain keys: strings.ToLower(stringsx.Clean(mapName))
 var exactUMSTeamsAIMaps = map[string]bool{
@@ -51,11 +64,42 @@ var exactUMSTeamsAIMaps = map[string]bool{
 	"big game hunters":            true, // Multiple BGH versions have random team assignment, always try if UMS
 }
 
+// ComputeConfig tells which optional computations Replay.ComputeConfig() performs.
+// Command counts, APMs, leave game and chat commands, the replay saver,
+// start locations and teams are always computed.
+type ComputeConfig struct {
+	// EAPM tells if commands are to be classified as effective / ineffective,
+	// and EAPM values are to be calculated
+	EAPM bool
+
+	// Winners tells if observers, teams (of UMS and melee games) and winners are to be detected
+	Winners bool
+
+	// BuildOrders tells if build orders of players are to be extracted (see BuildOrder()).
+	// Implies EAPM as build orders only contain effective commands.
+	BuildOrders bool
+
+	// MapAnalysis tells if the map is to be analyzed (requires map data).
+	// It is opt-in as it's costly (e.g. ground distances of the start locations).
+	MapAnalysis bool
+
+	_ struct{} // To prevent unkeyed literals
+}
+
 // Compute creates and computes the Computed field.
+// All computations are performed except extracting build orders and map analysis (see ComputeConfig).
 func (r *Replay) Compute() {
+	r.ComputeConfig(ComputeConfig{EAPM: true, Winners: true})
+}
+
+// ComputeConfig creates and computes the Computed field, performing the computations
+// enabled in the given configuration.
+// It does nothing if the Computed field already exists.
+func (r *Replay) ComputeConfig(cfg ComputeConfig) {
 	if r.Computed != nil {
 		return
 	}
+	classify := cfg.EAPM || cfg.BuildOrders
 
 	players := r.Header.Players
 	numPlayers := len(players)
@@ -74,6 +118,8 @@ func (r *Replay) Compute() {
 		c.PIDPlayerDescs[p.ID] = pd
 	}
 
+	teamsHeuristic := false
+
 	if r.Commands != nil {
 		// We need to gather player's commands separately for EAPM calculation.
 		// We could use a map, mapping from pid to player's commands, but then when building it,
@@ -81,26 +127,45 @@ func (r *Replay) Compute() {
 		type pidCmdsWrapper struct {
 			cmds []repcmd.Cmd
 		}
+		cmds := r.Commands.Cmds
+
+		// Preallocate the exact capacity of the players' commands (counting is much cheaper
+		// than repeatedly growing the slices), all backed by a single array:
+		var pidCmdsCounts [256]int
+		for _, cmd := range cmds {
+			pidCmdsCounts[cmd.BaseCmd().PlayerID]++
+		}
+		total := 0
+		for _, p := range players {
+			total += pidCmdsCounts[p.ID]
+		}
+		backing := make([]repcmd.Cmd, total)
+
 		pidCmdsWrappers := make(map[byte]*pidCmdsWrapper, numPlayers)
 		pidBuilds := make(map[byte]int, numPlayers) // Build commands count per player
 		for _, p := range players {
-			pidCmdsWrappers[p.ID] = &pidCmdsWrapper{
-				cmds: make([]repcmd.Cmd, 0, len(r.Commands.Cmds)/numPlayers), // Estimate even cmd distribution for fewer reallocations
-			}
+			n := pidCmdsCounts[p.ID]
+			pidCmdsWrappers[p.ID] = &pidCmdsWrapper{cmds: backing[:0:n]}
+			backing = backing[n:]
 		}
 
-		cmds := r.Commands.Cmds
 		for _, cmd := range cmds {
 			// Observers' commands (e.g. chat) have PlayerID starting with 128 (2nd obs 129 etc.)
 			// We don't have PlayerDescs for them, so must check:
 			baseCmd := cmd.BaseCmd()
-			if pd := c.PIDPlayerDescs[baseCmd.PlayerID]; pd != nil {
+			pd := c.PIDPlayerDescs[baseCmd.PlayerID]
+			if pd == nil && baseCmd.PlayerID >= ObserverPlayerIDBase {
+				c.addObserverCmd(cmd, r.Header.Frames)
+			}
+			if pd != nil {
 				pd.CmdCount++
 				pidCmdsWrapper := pidCmdsWrappers[baseCmd.PlayerID]
 				pidCmdsWrapper.cmds = append(pidCmdsWrapper.cmds, cmd)
-				baseCmd.IneffKind = CmdIneffKind(pidCmdsWrapper.cmds, len(pidCmdsWrapper.cmds)-1)
-				if baseCmd.IneffKind.Effective() {
-					pd.EffectiveCmdCount++
+				if classify {
+					baseCmd.IneffKind = CmdIneffKind(pidCmdsWrapper.cmds, len(pidCmdsWrapper.cmds)-1)
+					if baseCmd.IneffKind.Effective() {
+						pd.EffectiveCmdCount++
+					}
 				}
 			}
 			switch x := cmd.(type) {
@@ -113,6 +178,10 @@ func (r *Replay) Compute() {
 			}
 		}
 
+		slices.SortFunc(c.ObserverSlots, func(a, b *ObserverSlot) int {
+			return int(a.PlayerID) - int(b.PlayerID)
+		})
+
 		// Detect replay saver:
 		// Replay saver is the one who receives the chat messages.
 		// (Note chat is saved since patch 1.16, released on 2008-11-25.)
@@ -157,41 +226,20 @@ func (r *Replay) Compute() {
 			}
 			mins := pd.LastCmdFrame.Duration().Minutes()
 			pd.APM = int32(float64(pd.CmdCount)/mins + 0.5)
-			pd.EAPM = int32(float64(pd.EffectiveCmdCount)/mins + 0.5)
-		}
-
-		switch r.Header.Type {
-
-		case repcore.GameTypeUMS:
-			mapName := r.Header.Map
-			if r.MapData != nil {
-				mapName = r.MapData.Name
+			if classify {
+				pd.EAPM = int32(float64(pd.EffectiveCmdCount)/mins + 0.5)
 			}
-			// counter-examples: " \aai \x04hunters \x02remastered \x062.0", "\x03(XB2)\x06 Big Game Hunters"
-			mapName = strings.ToLower(stringsx.Clean(mapName))
-			// "[ai]" maps are special, we can do better than in general:
-			switch {
-
-			case exactUMSTeamsAIMaps[mapName] ||
-				strings.HasPrefix(mapName, "王牌猎人") || strings.HasPrefix(mapName, "j_big game hunters") ||
-				strings.Contains(mapName, "宏图") || // "grand plan"; e.g. "South Korea's grand plan" (韩国宏图) or "中国宏图" ("China's grand plan")
-				strings.Contains(mapName, "随机分组") || // "random grouping"
-				strings.Contains(mapName, "[ai]") || strings.Contains(mapName, "ai hunters") || strings.Contains(mapName, "bgh random teams") || strings.Contains(mapName, "big game hunters [r]") ||
-				strings.Contains(mapName, "new super random team") || strings.Contains(mapName, "new super ◆random team") || strings.Contains(mapName, "fa§te§t random team") ||
-				strings.Contains(mapName, "random forces"):
-				r.detectObservers(pidBuilds, obsProfileUMSAI)
-				r.computeUMSTeamsAI()
+		}
 
-			default:
-				r.computeUMSTeams()
+		if cfg.BuildOrders {
+			for _, pd := range c.PlayerDescs {
+				pd.BuildOrder = r.BuildOrder(pd.PlayerID)
 			}
-
-		case repcore.GameTypeMelee:
-			r.detectObservers(pidBuilds, obsProfileMelee)
-			r.computeMeleeTeams()
 		}
 
-		r.computeWinners()
+		if cfg.Winners {
+			teamsHeuristic = r.computeWinnersAndTeams(pidBuilds)
+		}
 	}
 
 	if r.MapData != nil {
@@ -212,6 +260,111 @@ func (r *Replay) Compute() {
 				}
 			}
 		}
+
+		if cfg.MapAnalysis {
+			c.MapAnalysis = NewMapAnalysis(r.MapData)
+		}
+	}
+
+	r.computeTeams(teamsHeuristic)
+}
+
+// computeWinnersAndTeams detects observers, teams (of UMS and melee games) and winners.
+// pidBuilds holds the build commands count of players.
+// Returns true if team detection changed the team of at least one player.
+func (r *Replay) computeWinnersAndTeams(pidBuilds map[byte]int) (heuristic bool) {
+	players := r.Header.Players
+
+	// Record original teams so we can tell if team detection changed them:
+	origTeams := make(map[*Player]byte, len(players))
+	for _, p := range players {
+		origTeams[p] = p.Team
+	}
+
+	switch r.Header.Type {
+
+	case repcore.GameTypeUMS:
+		mapName := r.Header.Map
+		if r.MapData != nil {
+			mapName = r.MapData.Name
+		}
+		// counter-examples: " \aai \x04hunters \x02remastered \x062.0", "\x03(XB2)\x06 Big Game Hunters"
+		mapName = strings.ToLower(stringsx.Clean(mapName))
+		// "[ai]" maps are special, we can do better than in general:
+		switch {
+
+		case exactUMSTeamsAIMaps[mapName] ||
+			strings.HasPrefix(mapName, "王牌猎人") || strings.HasPrefix(mapName, "j_big game hunters") ||
+			strings.Contains(mapName, "宏图") || // "grand plan"; e.g. "South Korea's grand plan" (韩国宏图) or "中国宏图" ("China's grand plan")
+			strings.Contains(mapName, "随机分组") || // "random grouping"
+			strings.Contains(mapName, "[ai]") || strings.Contains(mapName, "ai hunters") || strings.Contains(mapName, "bgh random teams") || strings.Contains(mapName, "big game hunters [r]") ||
+			strings.Contains(mapName, "new super random team") || strings.Contains(mapName, "new super ◆random team") || strings.Contains(mapName, "fa§te§t random team") ||
+			strings.Contains(mapName, "random forces"):
+			r.detectObservers(pidBuilds, obsProfileUMSAI)
+			r.computeUMSTeamsAI()
+
+		default:
+			r.computeUMSTeams()
+		}
+
+	case repcore.GameTypeMelee:
+		r.detectObservers(pidBuilds, obsProfileMelee)
+		r.computeMeleeTeams()
+	}
+
+	r.computeWinners()
+
+	for _, p := range players {
+		if p.Team != origTeams[p] {
+			heuristic = true
+			break
+		}
+	}
+
+	return
+}
+
+// computeTeams builds the Computed.Teams from the players' (final) team assignments.
+// heuristic tells if teams were altered by team detection algorithms.
+//
+// Teammates do not necessarily occupy consecutive slots (e.g. team detection of melee games
+// doesn't reorder players), so players are grouped by team ID, and teams are ordered by team ID.
+func (r *Replay) computeTeams(heuristic bool) {
+	c := r.Computed
+
+	teams := map[byte]*Team{}
+	for i, p := range r.Header.Players {
+		t := teams[p.Team]
+		if t == nil {
+			t = &Team{
+				ID:        p.Team,
+				Observers: true,
+				Heuristic: heuristic,
+				Result:    repcore.ResultUnknown,
+			}
+			teams[p.Team] = t
+			c.Teams = append(c.Teams, t)
+		}
+		t.Players = append(t.Players, p)
+		t.SlotIDs = append(t.SlotIDs, p.SlotID)
+		t.Races = append(t.Races, p.Race)
+		t.APM += c.PlayerDescs[i].APM
+		t.Observers = t.Observers && p.Observer
+	}
+	slices.SortFunc(c.Teams, func(a, b *Team) int { return cmp.Compare(a.ID, b.ID) })
+
+	if c.WinnerTeam == 0 {
+		return
+	}
+	for _, t := range c.Teams {
+		if t.Observers {
+			continue
+		}
+		if t.ID == c.WinnerTeam {
+			t.Result = repcore.ResultWin
+		} else {
+			t.Result = repcore.ResultLoss
+		}
 	}
 }
 
@@ -2,7 +2,11 @@ package rep
 
 import (
 	"math"
+	"slices"
 	"testing"
+
+	"github.com/icza/screp/rep/repcmd"
+	"github.com/icza/screp/rep/repcore"
 )
 
 func TestAngleToClock(t *testing.T) {
@@ -28,3 +32,209 @@ func TestAngleToClock(t *testing.T) {
 		}
 	}
 }
+
+func TestComputeTeams(t *testing.T) {
+	players := []*Player{
+		{SlotID: 0, Team: 1, Race: repcore.RaceTerran},
+		{SlotID: 1, Team: 1, Race: repcore.RaceZerg},
+		{SlotID: 2, Team: 2, Race: repcore.RaceProtoss},
+		{SlotID: 3, Team: 3, Race: repcore.RaceTerran, Observer: true},
+		{SlotID: 4, Team: 3, Race: repcore.RaceZerg, Observer: true},
+	}
+	// Teammates in non-consecutive slots, e.g. a melee 2v2 with alliances 0+2 and 1+3:
+	alternating := []*Player{
+		{SlotID: 0, Team: 1, Race: repcore.RaceTerran},
+		{SlotID: 1, Team: 2, Race: repcore.RaceZerg},
+		{SlotID: 2, Team: 1, Race: repcore.RaceProtoss},
+		{SlotID: 3, Team: 2, Race: repcore.RaceTerran},
+	}
+	newReplay := func(players []*Player, winnerTeam byte) *Replay {
+		r := &Replay{
+			Header:   &Header{Players: players},
+			Computed: &Computed{WinnerTeam: winnerTeam},
+		}
+		for i := range players {
+			r.Computed.PlayerDescs = append(r.Computed.PlayerDescs, &PlayerDesc{APM: int32(100 * (i + 1))})
+		}
+		return r
+	}
+
+	type teamCase struct {
+		id        byte
+		slotIDs   []uint16
+		apm       int32
+		observers bool
+		result    *repcore.Result
+	}
+	cases := []struct {
+		name       string
+		players    []*Player
+		winnerTeam byte
+		heuristic  bool
+		teams      []teamCase
+	}{
+		{
+			name:       "winner",
+			players:    players,
+			winnerTeam: 2,
+			teams: []teamCase{
+				{1, []uint16{0, 1}, 300, false, repcore.ResultLoss},
+				{2, []uint16{2}, 300, false, repcore.ResultWin},
+				{3, []uint16{3, 4}, 900, true, repcore.ResultUnknown},
+			},
+		},
+		{
+			name:      "unknown winner, heuristic",
+			players:   players,
+			heuristic: true,
+			teams: []teamCase{
+				{1, []uint16{0, 1}, 300, false, repcore.ResultUnknown},
+				{2, []uint16{2}, 300, false, repcore.ResultUnknown},
+				{3, []uint16{3, 4}, 900, true, repcore.ResultUnknown},
+			},
+		},
+		{
+			name:       "non-consecutive teammates",
+			players:    alternating,
+			winnerTeam: 1,
+			heuristic:  true,
+			teams: []teamCase{
+				{1, []uint16{0, 2}, 400, false, repcore.ResultWin},
+				{2, []uint16{1, 3}, 600, false, repcore.ResultLoss},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		r := newReplay(c.players, c.winnerTeam)
+		r.computeTeams(c.heuristic)
+		teams := r.Computed.Teams
+		if len(teams) != len(c.teams) {
+			t.Errorf("[%s] Expected: %v teams, got: %v", c.name, len(c.teams), len(teams))
+			continue
+		}
+		for i, tc := range c.teams {
+			team := teams[i]
+			if team.ID != tc.id || !slices.Equal(team.SlotIDs, tc.slotIDs) || team.Size() != len(tc.slotIDs) {
+				t.Errorf("[%s] Expected team: %v %v, got: %v %v", c.name, tc.id, tc.slotIDs, team.ID, team.SlotIDs)
+			}
+			if team.APM != tc.apm {
+				t.Errorf("[%s] Expected APM: %v, got: %v", c.name, tc.apm, team.APM)
+			}
+			if team.Observers != tc.observers {
+				t.Errorf("[%s] Expected observers: %v, got: %v", c.name, tc.observers, team.Observers)
+			}
+			if team.Result != tc.result {
+				t.Errorf("[%s] Expected result: %v, got: %v", c.name, tc.result, team.Result)
+			}
+			if team.Heuristic != c.heuristic {
+				t.Errorf("[%s] Expected heuristic: %v, got: %v", c.name, c.heuristic, team.Heuristic)
+			}
+		}
+	}
+}
+
+func TestComputeConfig(t *testing.T) {
+	newReplay := func() *Replay {
+		var cmds []repcmd.Cmd
+		for i := range 20 {
+			cmds = append(cmds, &repcmd.TrainCmd{
+				Base: &repcmd.Base{PlayerID: 0, Frame: repcore.Frame(100 * (i + 1)), Type: repcmd.TypeByID(repcmd.TypeIDTrain)},
+				Unit: repcmd.UnitByID(0x29), // Drone
+			})
+		}
+		return &Replay{
+			Header:   &Header{Frames: 3000, Players: []*Player{{ID: 0, Team: 1}}},
+			Commands: &Commands{Cmds: cmds},
+		}
+	}
+
+	cases := []struct {
+		name       string
+		cfg        ComputeConfig
+		eapm       bool
+		buildOrder bool
+	}{
+		{"none", ComputeConfig{}, false, false},
+		{"eapm", ComputeConfig{EAPM: true}, true, false},
+		{"buildorders", ComputeConfig{BuildOrders: true}, true, true},
+	}
+
+	for _, c := range cases {
+		r := newReplay()
+		r.ComputeConfig(c.cfg)
+		pd := r.Computed.PlayerDescs[0]
+		if pd.APM == 0 || (pd.EAPM != 0) != c.eapm || (len(pd.BuildOrder) != 0) != c.buildOrder {
+			t.Errorf("[%s] Expected: %v %v, got: %v %v (APM: %v)", c.name, c.eapm, c.buildOrder, pd.EAPM, len(pd.BuildOrder), pd.APM)
+		}
+	}
+}
+
+func TestReset(t *testing.T) {
+	cmd := &repcmd.Base{Frame: 1}
+	cmds := make([]repcmd.Cmd, 2, 10)
+	cmds[0], cmds[1] = cmd, cmd
+	r := &Replay{
+		Header:   &Header{Title: "x"},
+		Commands: &Commands{Cmds: cmds, ParseErrCmds: []*repcmd.ParseErrCmd{{Base: cmd}}, Debug: &CommandsDebug{}},
+		Computed: &Computed{},
+	}
+	cs := r.Commands
+
+	r.Reset()
+	if r.Header != nil || r.Computed != nil {
+		t.Errorf("Expected nil header and computed, got: %v, %v", r.Header, r.Computed)
+	}
+	if r.Commands != cs || len(cs.Cmds) != 0 || cap(cs.Cmds) != 10 || len(cs.ParseErrCmds) != 0 || cs.Debug != nil {
+		t.Errorf("Expected reset commands retaining capacity, got: %+v", r.Commands)
+	}
+	if cmds[0] != nil || cmds[1] != nil {
+		t.Errorf("Expected cleared commands, got: %v", cmds)
+	}
+
+	r = &Replay{Header: &Header{}}
+	r.Reset()
+	if r.Header != nil || r.Commands != nil {
+		t.Errorf("Expected zero replay, got: %+v", r)
+	}
+}
+
+func TestComputeObserverSlots(t *testing.T) {
+	base := func(frame repcore.Frame, pid, typeID byte) *repcmd.Base {
+		return &repcmd.Base{Frame: frame, PlayerID: pid, Type: repcmd.TypeByID(typeID)}
+	}
+	r := &Replay{
+		Header: &Header{Frames: 3000, Players: []*Player{{ID: 0, Team: 1}}},
+		Commands: &Commands{Cmds: []repcmd.Cmd{
+			&repcmd.ChatCmd{Base: base(100, 129, repcmd.TypeIDChat), Message: "hi"},
+			&repcmd.HotkeyCmd{Base: base(200, 0, repcmd.TypeIDHotkey)},
+			&repcmd.ChatCmd{Base: base(300, 128, repcmd.TypeIDChat), Message: "gl"},
+			&repcmd.LeaveGameCmd{Base: base(400, 128, repcmd.TypeIDLeaveGame)},
+			&repcmd.ChatCmd{Base: base(5000, 129, repcmd.TypeIDChat), Message: "bad frame"},
+		}},
+	}
+	r.ComputeConfig(ComputeConfig{})
+
+	c := r.Computed
+	exp := []ObserverSlot{
+		{PlayerID: 128, LastCmdFrame: 400, CmdCount: 2, ChatCount: 1},
+		{PlayerID: 129, LastCmdFrame: 100, CmdCount: 2, ChatCount: 2},
+	}
+	if len(c.ObserverSlots) != len(exp) {
+		t.Fatalf("Expected: %v observer slots, got: %v", len(exp), len(c.ObserverSlots))
+	}
+	for i, s := range c.ObserverSlots {
+		if *s != exp[i] {
+			t.Errorf("Expected: %+v, got: %+v", exp[i], *s)
+		}
+		if c.PIDObserverSlots[s.PlayerID] != s {
+			t.Errorf("Expected: %v, got: %v", s, c.PIDObserverSlots[s.PlayerID])
+		}
+		if s.Index() != i {
+			t.Errorf("Expected: %v, got: %v", i, s.Index())
+		}
+	}
+	if pd := c.PlayerDescs[0]; pd.CmdCount != 1 {
+		t.Errorf("Expected: %v, got: %v", 1, pd.CmdCount)
+	}
+}
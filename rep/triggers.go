@@ -0,0 +1,122 @@
+// This file contains the types describing map triggers.
+
+package rep
+
+import (
+	"github.com/icza/screp/rep/repcmd"
+	"github.com/icza/screp/rep/repcore"
+)
+
+// Trigger describes a map trigger (from the TRIG sub-section)
+// or a mission briefing trigger (from the MBRF sub-section).
+type Trigger struct {
+	// Conditions of the trigger. Unused conditions are excluded.
+	Conditions []*TriggerCondition
+
+	// Actions of the trigger. Unused actions are excluded.
+	Actions []*TriggerAction
+
+	// ExecutionFlags of the trigger (e.g. 0x04: preserve trigger, 0x08: disabled).
+	ExecutionFlags uint32
+
+	// Groups lists the players / player groups executing the trigger.
+	Groups []*repcore.PlayerGroup
+}
+
+// TriggerCondition describes a trigger condition.
+//
+// Parameters are decoded according to Type: fields not used by the condition type are left zero / nil.
+type TriggerCondition struct {
+	// Type of the condition
+	Type *repcore.TriggerConditionType
+
+	// LocationID is the ID of the location (Location.ID) the condition refers to, 0 if none
+	LocationID uint32 `json:",omitempty"`
+
+	// Group is the player / player group the condition applies to
+	Group *repcore.PlayerGroup
+
+	// Amount is the qualified number (e.g. count of units, amount of resources, seconds)
+	Amount uint32
+
+	// Unit the condition refers to, only for unit related conditions (e.g. Bring, Command, Deaths)
+	Unit *repcmd.Unit `json:",omitempty"`
+
+	// Comparison used to compare with Amount, or the expected switch state for Switch conditions;
+	// only for conditions having a comparison
+	Comparison *repcore.Comparison `json:",omitempty"`
+
+	// ResourceType for resource conditions (e.g. Accumulate, Most Resources)
+	ResourceType *repcore.ResourceType `json:",omitempty"`
+
+	// ScoreType for score conditions (e.g. Score, Highest Score)
+	ScoreType *repcore.ScoreType `json:",omitempty"`
+
+	// SwitchID is the 0-based switch number (Switch.ID) for Switch conditions
+	SwitchID *uint16 `json:",omitempty"`
+
+	// Flags of the condition (e.g. 0x02: disabled).
+	Flags byte `json:",omitempty"`
+}
+
+// TriggerAction describes a trigger action.
+//
+// Parameters are decoded according to Type: fields not used by the action type are left zero / nil.
+type TriggerAction struct {
+	// Type of the action
+	Type *repcore.TriggerActionType
+
+	// LocationID is the ID of the location (Location.ID) the action refers to, 0 if none
+	LocationID uint32 `json:",omitempty"`
+
+	// TextID is the strings table index of Text, 0 if none
+	TextID uint32 `json:",omitempty"`
+
+	// Text of the action (e.g. displayed text message, comment)
+	Text string `json:",omitempty"`
+
+	// WAVID is the strings table index of WAV, 0 if none
+	WAVID uint32 `json:",omitempty"`
+
+	// WAV is the sound file path of the action
+	WAV string `json:",omitempty"`
+
+	// Time is the time / duration parameter of the action in milliseconds or seconds depending on Type.
+	Time uint32 `json:",omitempty"`
+
+	// Group is the first player / player group the action applies to
+	Group *repcore.PlayerGroup
+
+	// Group2 is the second group, or destination location, or a number (e.g. amount)
+	// depending on Type.
+	Group2 uint32 `json:",omitempty"`
+
+	// Unit the action refers to, only for unit related actions (e.g. Create Unit, Kill Unit, Set Deaths)
+	Unit *repcmd.Unit `json:",omitempty"`
+
+	// UnitCount is the number of units the action applies to (0 means all),
+	// only for actions affecting a number of units (e.g. Create Unit, Remove Unit At Location)
+	UnitCount byte `json:",omitempty"`
+
+	// ResourceType for resource actions (e.g. Set Resources, Leader Board (Resources))
+	ResourceType *repcore.ResourceType `json:",omitempty"`
+
+	// ScoreType for score actions (e.g. Set Score, Leader Board (Points))
+	ScoreType *repcore.ScoreType `json:",omitempty"`
+
+	// AllianceStatus for the Set Alliance Status action
+	AllianceStatus *repcore.AllianceStatus `json:",omitempty"`
+
+	// SwitchID is the 0-based switch number (Switch.ID) for the Set Switch action
+	SwitchID *uint16 `json:",omitempty"`
+
+	// Modifier is the number or state modifier of the action
+	// (e.g. Set To, Add, Enable, Toggle; Set / Clear for the Set Switch action)
+	Modifier *repcore.Modifier `json:",omitempty"`
+
+	// Order issued by the Order action
+	Order *repcore.TriggerOrder `json:",omitempty"`
+
+	// Flags of the action (e.g. 0x02: disabled).
+	Flags byte `json:",omitempty"`
+}
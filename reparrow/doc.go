@@ -0,0 +1,24 @@
+/*
+
+Package reparrow implements writing the command streams and per-player stats of replays
+as Apache Arrow record batches, in the Arrow IPC streaming format
+(https://arrow.apache.org/docs/format/Columnar.html#ipc-streaming-format).
+
+The streams can be loaded with zero copy into pandas, Polars and other Arrow based tools
+for corpus-scale studies, e.g. with pyarrow:
+
+	import pyarrow as pa
+
+	with pa.ipc.open_stream("cmds.arrow") as reader:
+		df = reader.read_pandas()
+
+A Writer writes a table: one record batch per replay (so many replays can be written to the same stream),
+having the file name of the replay in the "file" column. NewCommandsWriter creates a writer of the
+commands table (1 row per command), NewPlayersWriter creates a writer of the players table
+(1 row per player with the player's computed stats).
+
+The format is written by this package (without depending on the Arrow libraries),
+columns are non-nullable and uncompressed.
+
+*/
+package reparrow
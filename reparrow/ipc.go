@@ -0,0 +1,197 @@
+// This file contains the Arrow columns and the IPC stream format.
+
+package reparrow
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/icza/screp/internal/flatbuf"
+)
+
+// Kinds of columns (values of the Type union of the Arrow schema).
+const (
+	kindInt  = 2
+	kindUtf8 = 5
+	kindBool = 6
+)
+
+// Arrow message constants
+const (
+	metadataVersionV5  = 4
+	messageSchema      = 1
+	messageRecordBatch = 3
+	continuationMarker = 0xffffffff
+	bufferAlignment    = 8
+)
+
+// column is a non-nullable column of a table.
+type column struct {
+	name string
+	kind int
+
+	// bitWidth and signed describe the type of int columns
+	bitWidth int
+	signed   bool
+
+	// length is the number of values
+	length int
+
+	// values holds the fixed width values (or the bits of bool values, or the data of utf8 values)
+	values []byte
+
+	// offsets holds the offsets of utf8 values
+	offsets []byte
+}
+
+func intColumn(name string, bitWidth int, signed bool) *column {
+	return &column{name: name, kind: kindInt, bitWidth: bitWidth, signed: signed}
+}
+
+func strColumn(name string) *column {
+	return &column{name: name, kind: kindUtf8}
+}
+
+func boolColumn(name string) *column {
+	return &column{name: name, kind: kindBool}
+}
+
+// addInt adds a value to an int column.
+func (c *column) addInt(v int64) {
+	for i := 0; i < c.bitWidth/8; i++ {
+		c.values = append(c.values, byte(v>>(8*i)))
+	}
+	c.length++
+}
+
+// addStr adds a value to a utf8 column.
+func (c *column) addStr(s string) {
+	if c.length == 0 {
+		c.offsets = binary.LittleEndian.AppendUint32(c.offsets[:0], 0)
+	}
+	c.values = append(c.values, s...)
+	c.offsets = binary.LittleEndian.AppendUint32(c.offsets, uint32(len(c.values)))
+	c.length++
+}
+
+// addBool adds a value to a bool column.
+func (c *column) addBool(b bool) {
+	if c.length%8 == 0 {
+		c.values = append(c.values, 0)
+	}
+	if b {
+		c.values[c.length/8] |= 1 << (c.length % 8)
+	}
+	c.length++
+}
+
+// reset removes the values of the column.
+func (c *column) reset() {
+	c.length, c.values, c.offsets = 0, c.values[:0], c.offsets[:0]
+}
+
+// buffers returns the buffers of the column (the validity bitmap is always empty).
+func (c *column) buffers() [][]byte {
+	if c.kind == kindUtf8 {
+		if c.length == 0 {
+			return [][]byte{nil, {0, 0, 0, 0}, nil}
+		}
+		return [][]byte{nil, c.offsets, c.values}
+	}
+	return [][]byte{nil, c.values}
+}
+
+// field returns the Field table of the column.
+func (c *column) field() flatbuf.Table {
+	typ := flatbuf.Table{}
+	if c.kind == kindInt {
+		typ.Set(0, 4, uint64(c.bitWidth))
+		typ.Set(1, 1, boolBits(c.signed))
+	}
+
+	f := flatbuf.Table{}
+	f.SetRef(0, flatbuf.Str(c.name))
+	f.Set(2, 1, uint64(c.kind))
+	f.SetRef(3, typ)
+	f.SetRef(5, flatbuf.TableVector{}) // Children must be present
+	return f
+}
+
+// writeMessage writes an encapsulated message having the given header and body.
+func writeMessage(w io.Writer, headerType int, header flatbuf.Table, body []byte) error {
+	msg := flatbuf.Table{}
+	msg.Set(0, 2, metadataVersionV5)
+	msg.Set(1, 1, uint64(headerType))
+	msg.SetRef(2, header)
+	msg.Set(3, 8, uint64(len(body)))
+	meta := (&flatbuf.Builder{}).Finish(msg, "")
+	for len(meta)%bufferAlignment != 0 {
+		meta = append(meta, 0)
+	}
+
+	prefix := binary.LittleEndian.AppendUint32(nil, continuationMarker)
+	prefix = binary.LittleEndian.AppendUint32(prefix, uint32(len(meta)))
+	for _, data := range [][]byte{prefix, meta, body} {
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeSchema writes the Schema message of the columns.
+func writeSchema(w io.Writer, cols []*column) error {
+	fields := make(flatbuf.TableVector, len(cols))
+	for i, c := range cols {
+		fields[i] = c.field()
+	}
+	schema := flatbuf.Table{}
+	schema.SetRef(1, fields)
+	return writeMessage(w, messageSchema, schema, nil)
+}
+
+// writeRecordBatch writes a RecordBatch message of the values of the columns.
+// All columns must have the same number of values.
+func writeRecordBatch(w io.Writer, cols []*column) error {
+	var length int
+	if len(cols) > 0 {
+		length = cols[0].length
+	}
+
+	nodes := &flatbuf.InlineVector{Count: len(cols), Align: 8}
+	bufs := &flatbuf.InlineVector{Align: 8}
+	var body []byte
+	for _, c := range cols {
+		nodes.Data = binary.LittleEndian.AppendUint64(nodes.Data, uint64(c.length))
+		nodes.Data = binary.LittleEndian.AppendUint64(nodes.Data, 0) // Null count
+		for _, buf := range c.buffers() {
+			bufs.Data = binary.LittleEndian.AppendUint64(bufs.Data, uint64(len(body)))
+			bufs.Data = binary.LittleEndian.AppendUint64(bufs.Data, uint64(len(buf)))
+			bufs.Count++
+			body = append(body, buf...)
+			for len(body)%bufferAlignment != 0 {
+				body = append(body, 0)
+			}
+		}
+	}
+
+	batch := flatbuf.Table{}
+	batch.Set(0, 8, uint64(length))
+	batch.SetRef(1, nodes)
+	batch.SetRef(2, bufs)
+	return writeMessage(w, messageRecordBatch, batch, body)
+}
+
+// writeEndOfStream writes the end-of-stream marker.
+func writeEndOfStream(w io.Writer) error {
+	_, err := w.Write([]byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0})
+	return err
+}
+
+// boolBits returns the scalar bits of a bool value.
+func boolBits(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
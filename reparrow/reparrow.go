@@ -0,0 +1,143 @@
+package reparrow
+
+import (
+	"errors"
+	"io"
+
+	"github.com/icza/screp/rep"
+)
+
+// ErrClosed is returned when writing to a closed Writer.
+var ErrClosed = errors.New("writer is closed")
+
+// Writer writes a table of replays as an Arrow IPC stream.
+// The schema is written before the first record batch, the end-of-stream marker by Close().
+type Writer struct {
+	w    io.Writer
+	cols []*column
+
+	// fill adds the rows of a replay to the columns
+	fill func(file string, r *rep.Replay)
+
+	schemaWritten bool
+	closed        bool
+}
+
+// NewCommandsWriter returns a new Writer writing the commands table to w.
+//
+// Columns: file (utf8), frame (int32), player_id (uint8), player (utf8), type_id (uint8),
+// type (utf8), effective (bool), params (utf8).
+//
+// The effective column is only accurate for computed replays (see rep.Replay.Compute()).
+func NewCommandsWriter(w io.Writer) *Writer {
+	file, frame, playerID, player := strColumn("file"), intColumn("frame", 32, true), intColumn("player_id", 8, false), strColumn("player")
+	typeID, typ, effective, params := intColumn("type_id", 8, false), strColumn("type"), boolColumn("effective"), strColumn("params")
+
+	fill := func(fileName string, r *rep.Replay) {
+		if r.Commands == nil {
+			return
+		}
+		for _, cmd := range r.Commands.Cmds {
+			b := cmd.BaseCmd()
+			var name string
+			if p := r.Header.PIDPlayers[b.PlayerID]; p != nil {
+				name = p.Name
+			}
+			file.addStr(fileName)
+			frame.addInt(int64(b.Frame))
+			playerID.addInt(int64(b.PlayerID))
+			player.addStr(name)
+			typeID.addInt(int64(b.Type.ID))
+			typ.addStr(b.Type.Name)
+			effective.addBool(b.IneffKind.Effective())
+			params.addStr(cmd.Params(false))
+		}
+	}
+
+	return &Writer{w: w, cols: []*column{file, frame, playerID, player, typeID, typ, effective, params}, fill: fill}
+}
+
+// NewPlayersWriter returns a new Writer writing the players table to w.
+// Replays are computed if they aren't yet (see rep.Replay.Compute()).
+//
+// Columns: file (utf8), player_id (uint8), slot_id (uint16), name (utf8), race (utf8), team (uint8),
+// observer (bool), winner (bool), cmd_count (uint32), effective_cmd_count (uint32),
+// apm (int32), eapm (int32), last_cmd_frame (int32).
+func NewPlayersWriter(w io.Writer) *Writer {
+	file, playerID, slotID, name := strColumn("file"), intColumn("player_id", 8, false), intColumn("slot_id", 16, false), strColumn("name")
+	race, team, observer, winner := strColumn("race"), intColumn("team", 8, false), boolColumn("observer"), boolColumn("winner")
+	cmdCount, effCmdCount := intColumn("cmd_count", 32, false), intColumn("effective_cmd_count", 32, false)
+	apm, eapm, lastCmdFrame := intColumn("apm", 32, true), intColumn("eapm", 32, true), intColumn("last_cmd_frame", 32, true)
+
+	fill := func(fileName string, r *rep.Replay) {
+		r.Compute()
+		for i, p := range r.Header.Players {
+			pd := &rep.PlayerDesc{}
+			if i < len(r.Computed.PlayerDescs) {
+				pd = r.Computed.PlayerDescs[i]
+			}
+			var raceName string
+			if p.Race != nil {
+				raceName = p.Race.Name
+			}
+			file.addStr(fileName)
+			playerID.addInt(int64(p.ID))
+			slotID.addInt(int64(p.SlotID))
+			name.addStr(p.Name)
+			race.addStr(raceName)
+			team.addInt(int64(p.Team))
+			observer.addBool(p.Observer)
+			winner.addBool(r.Computed.WinnerTeam != 0 && p.Team == r.Computed.WinnerTeam)
+			cmdCount.addInt(int64(pd.CmdCount))
+			effCmdCount.addInt(int64(pd.EffectiveCmdCount))
+			apm.addInt(int64(pd.APM))
+			eapm.addInt(int64(pd.EAPM))
+			lastCmdFrame.addInt(int64(pd.LastCmdFrame))
+		}
+	}
+
+	cols := []*column{file, playerID, slotID, name, race, team, observer, winner, cmdCount, effCmdCount, apm, eapm, lastCmdFrame}
+	return &Writer{w: w, cols: cols, fill: fill}
+}
+
+// Write writes the rows of a replay as a record batch.
+// file is the name of the replay file (the value of the file column).
+func (w *Writer) Write(file string, r *rep.Replay) error {
+	if w.closed {
+		return ErrClosed
+	}
+	if err := w.writeSchema(); err != nil {
+		return err
+	}
+
+	for _, c := range w.cols {
+		c.reset()
+	}
+	w.fill(file, r)
+	return writeRecordBatch(w.w, w.cols)
+}
+
+// Close writes the end-of-stream marker (and the schema if no replays were written).
+// It does not close the underlying writer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return ErrClosed
+	}
+	if err := w.writeSchema(); err != nil {
+		return err
+	}
+	w.closed = true
+	return writeEndOfStream(w.w)
+}
+
+// writeSchema writes the schema if it hasn't been written yet.
+func (w *Writer) writeSchema() error {
+	if w.schemaWritten {
+		return nil
+	}
+	if err := writeSchema(w.w, w.cols); err != nil {
+		return err
+	}
+	w.schemaWritten = true
+	return nil
+}
@@ -0,0 +1,255 @@
+package reparrow
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcmd"
+	"github.com/icza/screp/rep/repcore"
+)
+
+// reader is a minimal FlatBuffers reader, reading buffers the way generated code does.
+type reader []byte
+
+func (r reader) u16(pos int) int { return int(binary.LittleEndian.Uint16(r[pos:])) }
+func (r reader) u32(pos int) int { return int(binary.LittleEndian.Uint32(r[pos:])) }
+func (r reader) u64(pos int) int { return int(binary.LittleEndian.Uint64(r[pos:])) }
+
+// deref returns the position of the object referenced by the offset at pos.
+func (r reader) deref(pos int) int { return pos + r.u32(pos) }
+
+// field returns the position of a field of the table at tbl, 0 if the field is absent.
+func (r reader) field(tbl, id int) int {
+	vt := tbl - int(int32(r.u32(tbl)))
+	if 4+2*id >= r.u16(vt) {
+		return 0
+	}
+	if off := r.u16(vt + 4 + 2*id); off != 0 {
+		return tbl + off
+	}
+	return 0
+}
+
+func (r reader) str(pos int) string {
+	return string(r[pos+4 : pos+4+r.u32(pos)])
+}
+
+// message is a decoded Arrow IPC message.
+type message struct {
+	meta       reader
+	headerType int
+	header     int // Position of the header table in meta
+	body       []byte
+}
+
+// readStream reads the messages of an Arrow IPC stream.
+func readStream(t *testing.T, data []byte) (msgs []*message) {
+	t.Helper()
+	for {
+		if len(data) < 8 || binary.LittleEndian.Uint32(data) != continuationMarker {
+			t.Fatalf("Missing continuation marker")
+		}
+		size := int(binary.LittleEndian.Uint32(data[4:]))
+		if size == 0 {
+			if len(data) != 8 {
+				t.Errorf("Expected: %v, got: %v", 8, len(data))
+			}
+			return
+		}
+		if size%8 != 0 {
+			t.Errorf("Metadata size is not padded: %d", size)
+		}
+		m := &message{meta: reader(data[8 : 8+size])}
+		root := m.meta.deref(0)
+		if got := m.meta.u16(m.meta.field(root, 0)); got != metadataVersionV5 {
+			t.Errorf("Expected: %v, got: %v", metadataVersionV5, got)
+		}
+		m.headerType = int(m.meta[m.meta.field(root, 1)])
+		m.header = m.meta.deref(m.meta.field(root, 2))
+		var bodyLen int
+		if pos := m.meta.field(root, 3); pos != 0 {
+			bodyLen = m.meta.u64(pos)
+		}
+		m.body = data[8+size : 8+size+bodyLen]
+		msgs = append(msgs, m)
+		data = data[8+size+bodyLen:]
+	}
+}
+
+func testReplay(frames ...repcore.Frame) *rep.Replay {
+	players := []*rep.Player{
+		{SlotID: 0, ID: 0, Race: repcore.RaceTerran, Team: 1, Name: "Flash"},
+		{SlotID: 1, ID: 1, Race: repcore.RaceZerg, Team: 2, Name: "Jaedong"},
+	}
+	r := &rep.Replay{
+		Header:   &rep.Header{Frames: 1000, Players: players, PIDPlayers: map[byte]*rep.Player{0: players[0], 1: players[1]}},
+		Commands: &rep.Commands{},
+	}
+	for i, frame := range frames {
+		r.Commands.Cmds = append(r.Commands.Cmds, &repcmd.GeneralCmd{
+			Base: &repcmd.Base{Frame: frame, PlayerID: byte(i % 2), Type: repcmd.TypeChat, IneffKind: repcore.IneffKind(i % 2)},
+		})
+	}
+	return r
+}
+
+func TestCommandsWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := NewCommandsWriter(buf)
+	for i, r := range []*rep.Replay{testReplay(10, 20, 30), testReplay(), testReplay(5, 6, 7, 8, 9, 10, 11, 12, 13)} {
+		if err := w.Write([]string{"a.rep", "b.rep", "c.rep"}[i], r); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if err := w.Write("d.rep", testReplay()); err != ErrClosed {
+		t.Errorf("Expected: %v, got: %v", ErrClosed, err)
+	}
+
+	msgs := readStream(t, buf.Bytes())
+	if len(msgs) != 4 {
+		t.Fatalf("Expected: %v, got: %v", 4, len(msgs))
+	}
+
+	// Schema
+	schema := msgs[0]
+	if schema.headerType != messageSchema {
+		t.Errorf("Expected: %v, got: %v", messageSchema, schema.headerType)
+	}
+	m := schema.meta
+	fields := m.deref(m.field(schema.header, 1))
+	var names []string
+	var kinds []int
+	for i := 0; i < m.u32(fields); i++ {
+		f := m.deref(fields + 4 + 4*i)
+		names = append(names, m.str(m.deref(m.field(f, 0))))
+		kinds = append(kinds, int(m[m.field(f, 2)]))
+		if m.field(f, 5) == 0 {
+			t.Errorf("Expected: %v, got: %v", "children", nil)
+		}
+	}
+	expNames := []string{"file", "frame", "player_id", "player", "type_id", "type", "effective", "params"}
+	expKinds := []int{kindUtf8, kindInt, kindInt, kindUtf8, kindInt, kindUtf8, kindBool, kindUtf8}
+	if !reflect.DeepEqual(expNames, names) || !reflect.DeepEqual(expKinds, kinds) {
+		t.Errorf("Expected: %v %v, got: %v %v", expNames, expKinds, names, kinds)
+	}
+	frameType := m.deref(m.field(m.deref(fields+4+4), 3))
+	if bitWidth, signed := m.u32(m.field(frameType, 0)), m[m.field(frameType, 1)]; bitWidth != 32 || signed != 1 {
+		t.Errorf("Expected: %v %v, got: %v %v", 32, 1, bitWidth, signed)
+	}
+
+	// Record batches
+	cases := []struct {
+		length    int
+		file      string
+		frames    []int32
+		players   []string
+		effective []bool
+	}{
+		{3, "a.rep", []int32{10, 20, 30}, []string{"Flash", "Jaedong", "Flash"}, []bool{true, false, true}},
+		{0, "", nil, nil, nil},
+		{9, "c.rep", []int32{5, 6, 7, 8, 9, 10, 11, 12, 13}, nil, []bool{true, false, true, false, true, false, true, false, true}},
+	}
+	for i, c := range cases {
+		msg := msgs[i+1]
+		m := msg.meta
+		if msg.headerType != messageRecordBatch {
+			t.Errorf("[%d] Expected: %v, got: %v", i, messageRecordBatch, msg.headerType)
+		}
+		var length int
+		if pos := m.field(msg.header, 0); pos != 0 {
+			length = m.u64(pos)
+		}
+		if length != c.length {
+			t.Errorf("[%d] Expected: %v, got: %v", i, c.length, length)
+		}
+
+		bufsPos := m.deref(m.field(msg.header, 2))
+		if got := m.u32(bufsPos); got != 20 {
+			t.Errorf("[%d] Expected: %v, got: %v", i, 20, got)
+		}
+		buffer := func(idx int) []byte {
+			pos := bufsPos + 4 + 16*idx
+			off, size := m.u64(pos), m.u64(pos+8)
+			if off%8 != 0 {
+				t.Errorf("[%d] Buffer offset is not aligned: %d", i, off)
+			}
+			return msg.body[off : off+size]
+		}
+		if c.length == 0 {
+			continue
+		}
+
+		offsets, data := buffer(1), buffer(2)
+		if got := string(data[:binary.LittleEndian.Uint32(offsets[4:])]); got != c.file {
+			t.Errorf("[%d] Expected: %v, got: %v", i, c.file, got)
+		}
+		frames := make([]int32, c.length)
+		binary.Read(bytes.NewReader(buffer(4)), binary.LittleEndian, frames)
+		if !reflect.DeepEqual(c.frames, frames) {
+			t.Errorf("[%d] Expected: %v, got: %v", i, c.frames, frames)
+		}
+		if c.players != nil {
+			offsets, data := buffer(8), buffer(9)
+			var players []string
+			for j := 0; j < c.length; j++ {
+				players = append(players, string(data[binary.LittleEndian.Uint32(offsets[4*j:]):binary.LittleEndian.Uint32(offsets[4*j+4:])]))
+			}
+			if !reflect.DeepEqual(c.players, players) {
+				t.Errorf("[%d] Expected: %v, got: %v", i, c.players, players)
+			}
+		}
+		bits := buffer(16)
+		var effective []bool
+		for j := 0; j < c.length; j++ {
+			effective = append(effective, bits[j/8]&(1<<(j%8)) != 0)
+		}
+		if !reflect.DeepEqual(c.effective, effective) {
+			t.Errorf("[%d] Expected: %v, got: %v", i, c.effective, effective)
+		}
+	}
+}
+
+func TestPlayersWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := NewPlayersWriter(buf)
+	r := testReplay(10, 20, 30)
+	if err := w.Write("a.rep", r); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if r.Computed == nil {
+		t.Errorf("Expected: %v, got: %v", "computed replay", nil)
+	}
+
+	msgs := readStream(t, buf.Bytes())
+	if len(msgs) != 2 {
+		t.Fatalf("Expected: %v, got: %v", 2, len(msgs))
+	}
+	msg := msgs[1]
+	m := msg.meta
+	nodes := m.deref(m.field(msg.header, 1))
+	if got := m.u32(nodes); got != 13 {
+		t.Errorf("Expected: %v, got: %v", 13, got)
+	}
+	if got := m.u64(nodes + 4); got != 2 {
+		t.Errorf("Expected: %v, got: %v", 2, got)
+	}
+}
+
+func TestCloseEmpty(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := NewCommandsWriter(buf).Close(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if msgs := readStream(t, buf.Bytes()); len(msgs) != 1 || msgs[0].headerType != messageSchema {
+		t.Errorf("Expected: %v, got: %v", "schema only", msgs)
+	}
+}
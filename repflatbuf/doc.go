@@ -0,0 +1,13 @@
+/*
+
+Package repflatbuf implements writing the command stream and computed data of replays
+in FlatBuffers format, so consumers can access replay data without a deserialization step.
+
+The schema is in screp.fbs (also available as Schema), generate readers for it with flatc.
+The buffers are written by this package (without depending on the FlatBuffers runtime).
+
+Commands are stored as a vector of structs, so the command stream can be
+accessed with zero copy. Type specific parameters of commands are not stored.
+
+*/
+package repflatbuf
@@ -0,0 +1,136 @@
+package repflatbuf
+
+import (
+	_ "embed"
+	"encoding/binary"
+	"io"
+
+	"github.com/icza/screp/internal/flatbuf"
+	"github.com/icza/screp/rep"
+)
+
+// Schema is the FlatBuffers schema of the written buffers.
+//
+//go:embed screp.fbs
+var Schema string
+
+// FileIdentifier is the file identifier of the written buffers.
+const FileIdentifier = "SCRP"
+
+// Field IDs (in schema declaration order)
+const (
+	replayFrames = iota
+	replayPlayers
+	replayCommandTypes
+	replayCommands
+	replayComputed
+)
+
+// commandSize is the size of the Command struct.
+const commandSize = 8
+
+// Marshal returns the FlatBuffers encoding (a Replay root table) of the command stream
+// and computed data of the replay.
+// Commands and computed data are absent if the replay has none.
+func Marshal(r *rep.Replay) []byte {
+	root := flatbuf.Table{}
+	if r.Header != nil {
+		root.Set(replayFrames, 4, uint64(r.Header.Frames))
+		var players flatbuf.TableVector
+		for _, p := range r.Header.Players {
+			pt := flatbuf.Table{}
+			pt.Set(0, 1, uint64(p.ID))
+			pt.SetRef(1, optStr(p.Name))
+			if p.Race != nil {
+				pt.SetRef(2, optStr(p.Race.Name))
+			}
+			pt.Set(3, 1, uint64(p.Team))
+			pt.Set(4, 1, boolBits(p.Observer))
+			players = append(players, pt)
+		}
+		root.SetRef(replayPlayers, players)
+	}
+
+	if r.Commands != nil {
+		var types flatbuf.TableVector
+		seen := map[byte]bool{}
+		cmds := &flatbuf.InlineVector{Count: len(r.Commands.Cmds), Align: 4, Data: make([]byte, 0, commandSize*len(r.Commands.Cmds))}
+		for _, cmd := range r.Commands.Cmds {
+			base := cmd.BaseCmd()
+			cmds.Data = binary.LittleEndian.AppendUint32(cmds.Data, uint32(base.Frame))
+			cmds.Data = append(cmds.Data, base.PlayerID, base.Type.ID, byte(base.IneffKind), 0)
+			if !seen[base.Type.ID] {
+				seen[base.Type.ID] = true
+				tt := flatbuf.Table{}
+				tt.Set(0, 1, uint64(base.Type.ID))
+				tt.SetRef(1, optStr(base.Type.Name))
+				types = append(types, tt)
+			}
+		}
+		root.SetRef(replayCommandTypes, types)
+		root.SetRef(replayCommands, cmds)
+	}
+
+	if c := r.Computed; c != nil {
+		ct := flatbuf.Table{}
+		ct.Set(0, 1, uint64(c.WinnerTeam))
+		if c.RepSaverPlayerID != nil {
+			ct.SetAlways(1, 2, uint64(*c.RepSaverPlayerID))
+		}
+		var pds flatbuf.TableVector
+		for _, pd := range c.PlayerDescs {
+			pt := flatbuf.Table{}
+			pt.Set(0, 1, uint64(pd.PlayerID))
+			pt.Set(1, 4, uint64(uint32(pd.LastCmdFrame)))
+			pt.Set(2, 4, uint64(pd.CmdCount))
+			pt.Set(3, 4, uint64(uint32(pd.APM)))
+			pt.Set(4, 4, uint64(pd.EffectiveCmdCount))
+			pt.Set(5, 4, uint64(uint32(pd.EAPM)))
+			pt.Set(6, 4, uint64(uint32(pd.StartDirection)))
+			pds = append(pds, pt)
+		}
+		ct.SetRef(2, pds)
+		var teams flatbuf.TableVector
+		for _, t := range c.Teams {
+			tt := flatbuf.Table{}
+			tt.Set(0, 1, uint64(t.ID))
+			slotIDs := &flatbuf.InlineVector{Count: len(t.SlotIDs), Align: 2}
+			for _, id := range t.SlotIDs {
+				slotIDs.Data = binary.LittleEndian.AppendUint16(slotIDs.Data, id)
+			}
+			tt.SetRef(1, slotIDs)
+			tt.Set(2, 4, uint64(uint32(t.APM)))
+			if t.Result != nil {
+				tt.SetRef(3, optStr(t.Result.Name))
+			}
+			tt.Set(4, 1, boolBits(t.Observers))
+			tt.Set(5, 1, boolBits(t.Heuristic))
+			teams = append(teams, tt)
+		}
+		ct.SetRef(3, teams)
+		root.SetRef(replayComputed, ct)
+	}
+
+	return (&flatbuf.Builder{}).Finish(root, FileIdentifier)
+}
+
+// Write writes the FlatBuffers encoding of the replay to w, see Marshal().
+func Write(w io.Writer, r *rep.Replay) error {
+	_, err := w.Write(Marshal(r))
+	return err
+}
+
+// optStr returns the string object of s, nil if s is empty (so the field is absent).
+func optStr(s string) flatbuf.Object {
+	if s == "" {
+		return nil
+	}
+	return flatbuf.Str(s)
+}
+
+func boolBits(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,129 @@
+package repflatbuf
+
+import (
+	"encoding/binary"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcmd"
+	"github.com/icza/screp/rep/repcore"
+)
+
+// reader is a minimal FlatBuffers reader, reading buffers the way generated code does.
+type reader []byte
+
+func (r reader) u16(pos int) int { return int(binary.LittleEndian.Uint16(r[pos:])) }
+func (r reader) u32(pos int) int { return int(binary.LittleEndian.Uint32(r[pos:])) }
+
+// deref returns the position of the object referenced by the offset at pos.
+func (r reader) deref(pos int) int { return pos + r.u32(pos) }
+
+// field returns the position of a field of the table at tbl, 0 if the field is absent.
+func (r reader) field(tbl, id int) int {
+	vt := tbl - int(int32(r.u32(tbl)))
+	if 4+2*id >= r.u16(vt) {
+		return 0
+	}
+	if off := r.u16(vt + 4 + 2*id); off != 0 {
+		return tbl + off
+	}
+	return 0
+}
+
+func (r reader) str(pos int) string {
+	return string(r[pos+4 : pos+4+r.u32(pos)])
+}
+
+func TestMarshal(t *testing.T) {
+	repSaver := byte(0)
+	rp := &rep.Replay{
+		Header: &rep.Header{
+			Frames: 12345,
+			Players: []*rep.Player{
+				{ID: 0, Name: "Flash", Race: repcore.RaceTerran, Team: 1},
+				{ID: 1, Name: "Jaedong", Race: repcore.RaceZerg, Team: 2},
+			},
+		},
+		Commands: &rep.Commands{Cmds: []repcmd.Cmd{
+			&repcmd.GeneralCmd{Base: &repcmd.Base{Frame: 10, PlayerID: 0, Type: repcmd.TypeChat}},
+			&repcmd.GeneralCmd{Base: &repcmd.Base{Frame: 20, PlayerID: 1, Type: repcmd.TypeBuild, IneffKind: repcore.IneffKindFastCancel}},
+			&repcmd.GeneralCmd{Base: &repcmd.Base{Frame: 30, PlayerID: 0, Type: repcmd.TypeChat}},
+		}},
+		Computed: &rep.Computed{
+			WinnerTeam:       2,
+			RepSaverPlayerID: &repSaver,
+			PlayerDescs:      []*rep.PlayerDesc{{PlayerID: 0, APM: 300, LastCmdFrame: -1}, {PlayerID: 1, APM: 250}},
+			Teams:            []*rep.Team{{ID: 1, SlotIDs: []uint16{0, 2}, Result: repcore.ResultLoss}, {ID: 2, SlotIDs: []uint16{1}}},
+		},
+	}
+
+	r := reader(Marshal(rp))
+	if id := string(r[4:8]); id != FileIdentifier {
+		t.Errorf("Expected: %v, got: %v", FileIdentifier, id)
+	}
+	root := r.deref(0)
+	if root%8 != 0 {
+		t.Errorf("Table is not aligned: %d", root)
+	}
+
+	if got := int32(r.u32(r.field(root, replayFrames))); got != 12345 {
+		t.Errorf("Expected: %v, got: %v", 12345, got)
+	}
+
+	players := r.deref(r.field(root, replayPlayers))
+	var names []string
+	for i := 0; i < r.u32(players); i++ {
+		p := r.deref(players + 4 + 4*i)
+		names = append(names, r.str(r.deref(r.field(p, 1)))+"/"+r.str(r.deref(r.field(p, 2))))
+	}
+	if got, exp := strings.Join(names, ","), "Flash/Terran,Jaedong/Zerg"; got != exp {
+		t.Errorf("Expected: %v, got: %v", exp, got)
+	}
+
+	types := r.deref(r.field(root, replayCommandTypes))
+	if got := r.u32(types); got != 2 {
+		t.Errorf("Expected: %v, got: %v", 2, got)
+	}
+	if got := r.str(r.deref(r.field(r.deref(types+4), 1))); got != "Chat" {
+		t.Errorf("Expected: %v, got: %v", "Chat", got)
+	}
+
+	cmds := r.deref(r.field(root, replayCommands))
+	if got := r.u32(cmds); got != 3 {
+		t.Errorf("Expected: %v, got: %v", 3, got)
+	}
+	cmd := cmds + 4 + commandSize // Second command
+	if cmd%4 != 0 {
+		t.Errorf("Struct is not aligned: %d", cmd)
+	}
+	if got, exp := []int{r.u32(cmd), int(r[cmd+4]), int(r[cmd+5]), int(r[cmd+6])}, []int{20, 1, int(repcmd.TypeIDBuild), int(repcore.IneffKindFastCancel)}; !slices.Equal(got, exp) {
+		t.Errorf("Expected: %v, got: %v", exp, got)
+	}
+
+	computed := r.deref(r.field(root, replayComputed))
+	if got := r[r.field(computed, 0)]; got != 2 {
+		t.Errorf("Expected: %v, got: %v", 2, got)
+	}
+	if pos := r.field(computed, 1); pos == 0 || r.u16(pos) != 0 {
+		t.Errorf("Expected present rep saver 0")
+	}
+	pds := r.deref(r.field(computed, 2))
+	pd0 := r.deref(pds + 4)
+	if got := int32(r.u32(r.field(pd0, 1))); got != -1 {
+		t.Errorf("Expected: %v, got: %v", -1, got)
+	}
+	if got := r.field(r.deref(pds+8), 1); got != 0 {
+		t.Errorf("Expected absent field, got position: %v", got)
+	}
+	teams := r.deref(r.field(computed, 3))
+	team0 := r.deref(teams + 4)
+	slotIDs := r.deref(r.field(team0, 1))
+	if got, exp := []int{r.u32(slotIDs), r.u16(slotIDs + 4), r.u16(slotIDs + 6)}, []int{2, 0, 2}; !slices.Equal(got, exp) {
+		t.Errorf("Expected: %v, got: %v", exp, got)
+	}
+	if got := r.str(r.deref(r.field(team0, 3))); got != "Loss" {
+		t.Errorf("Expected: %v, got: %v", "Loss", got)
+	}
+}
@@ -0,0 +1,36 @@
+/*
+
+Package repgraphql implements querying parsed replays and a replay library via GraphQL.
+
+Web frontends may fetch exactly the nested fields they need (e.g. players, chat messages,
+build orders) instead of the full JSON output of replays. The schema mirrors the Go types
+of the rep package: field names match struct field and method names case-insensitively
+(e.g. header { mapWidth }), and type names are the Go type names (e.g. ... on ChatCmd).
+Fields of list type accept the first and skip arguments.
+
+The root fields (see Query):
+
+	replay(file: String, data: String): Replay
+	games(player: String, map: String, matchup: String, after: String, before: String): [Game]
+	game(fingerprint: String): Game
+
+where Game is an indexed game (see repindex.Game) having a replay field. Example query:
+
+	{
+		games(player: "Dakota", first: 10) {
+			startTime
+			map
+			replay {
+				computed {
+					chatCmds { frame playerID message }
+					playerDescs { playerID buildOrder(first: 20) { frame name supply } }
+				}
+			}
+		}
+	}
+
+The package implements the query language without the type system: introspection,
+mutations and subscriptions are not supported, and values are not validated against a schema.
+
+*/
+package repgraphql
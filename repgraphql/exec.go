@@ -0,0 +1,547 @@
+// This file contains the executor of GraphQL queries.
+
+package repgraphql
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Args holds the arguments of a field.
+// Resolver methods having an Args parameter receive the arguments of their field.
+//
+// Argument values are: string (also for enum values), int64, float64, bool, nil,
+// []any and map[string]any. Numbers of variables may be float64 even if integral.
+type Args map[string]any
+
+// Response is the response of a GraphQL request.
+type Response struct {
+	// Data is the result of the operation, nil if the operation could not be executed
+	Data any `json:"data,omitempty"`
+
+	// Errors that occurred during parsing, validating or executing the operation
+	Errors []*Error `json:"errors,omitempty"`
+}
+
+// Error is a GraphQL error.
+type Error struct {
+	Message string `json:"message"`
+
+	// Path of the response field the error belongs to (field names and list indices)
+	Path []any `json:"path,omitempty"`
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Execute executes the operation of the query document against root, and returns the response.
+//
+// Fields of Go values are resolved by reflection: a field name matches (case-insensitively)
+// an exported struct field (not excluded from JSON) or an exported method. Methods must
+// have no parameters or a single Args parameter, and return a value optionally followed by
+// an error. Pointers and interfaces are resolved to the values they point to, the name of
+// the concrete Go type is the GraphQL type name (used by __typename and type conditions).
+//
+// Fields of list type accept the first and skip arguments to page their elements.
+//
+// operationName may be empty if the document contains a single operation.
+// Only query operations are supported.
+func Execute(root any, query, operationName string, variables map[string]any) *Response {
+	doc, err := parseDocument(query)
+	if err != nil {
+		return &Response{Errors: []*Error{{Message: err.Error()}}}
+	}
+
+	var op *operation
+	for _, o := range doc.operations {
+		if operationName == "" && len(doc.operations) > 1 {
+			return errResponse("operationName is required for documents with multiple operations")
+		}
+		if operationName == "" || o.name == operationName {
+			op = o
+			break
+		}
+	}
+	if op == nil {
+		return errResponse("unknown operation: %s", operationName)
+	}
+	if op.kind != "query" {
+		return errResponse("unsupported operation type: %s", op.kind)
+	}
+
+	e := &executor{doc: doc, vars: map[string]any{}, defined: map[string]bool{}}
+	for _, vd := range op.varDefs {
+		e.defined[vd.name] = true
+		if v, ok := variables[vd.name]; ok {
+			e.vars[vd.name] = v
+		} else if vd.defaultValue != nil {
+			if e.vars[vd.name], err = e.value(vd.defaultValue); err != nil {
+				return errResponse("%v", err)
+			}
+		} else if vd.nonNull {
+			return errResponse("variable $%s of required type was not provided", vd.name)
+		}
+	}
+
+	v := reflect.ValueOf(root)
+	for v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return errResponse("root must be a struct, got: %T", root)
+	}
+
+	data := e.selectObject(v, op.selections, nil)
+	return &Response{Data: data, Errors: e.errs}
+}
+
+// errResponse returns a response with a single error created from format and args.
+func errResponse(format string, args ...any) *Response {
+	return &Response{Errors: []*Error{{Message: fmt.Sprintf(format, args...)}}}
+}
+
+// object is an object in the response, marshaled with its fields in selection order.
+type object struct {
+	fields []objectField
+}
+
+// objectField is a field of an object.
+type objectField struct {
+	key   string
+	value any
+}
+
+// MarshalJSON implements json.Marshaler.
+func (o *object) MarshalJSON() ([]byte, error) {
+	buf := []byte{'{'}
+	for i, f := range o.fields {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		key, err := json.Marshal(f.key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+		buf = append(append(append(buf, key...), ':'), value...)
+	}
+	return append(buf, '}'), nil
+}
+
+// executor executes an operation.
+type executor struct {
+	doc *document
+
+	// vars holds the values of variables
+	vars map[string]any
+
+	// defined tells the variables defined by the operation
+	defined map[string]bool
+
+	errs []*Error
+}
+
+var (
+	argsType     = reflect.TypeFor[Args]()
+	errorType    = reflect.TypeFor[error]()
+	timeType     = reflect.TypeFor[time.Time]()
+	durationType = reflect.TypeFor[time.Duration]()
+)
+
+// addError records an error of the field at path.
+func (e *executor) addError(err error, path []any) {
+	e.errs = append(e.errs, &Error{Message: err.Error(), Path: path})
+}
+
+// selectObject executes the selection set on the struct value v.
+// Fields that fail to resolve are null, and their errors are recorded.
+func (e *executor) selectObject(v reflect.Value, sels []selection, path []any) *object {
+	var keys []string
+	fields := map[string][]*field{}
+	if err := e.collectFields(v.Type().Name(), sels, &keys, fields, map[string]bool{}); err != nil {
+		e.addError(err, path)
+		return nil
+	}
+
+	obj := &object{fields: make([]objectField, 0, len(keys))}
+	for _, key := range keys {
+		fpath := append(path[:len(path):len(path)], key)
+		value, err := e.resolveField(v, fields[key], fpath)
+		if err != nil {
+			e.addError(err, fpath)
+			value = nil
+		}
+		obj.fields = append(obj.fields, objectField{key: key, value: value})
+	}
+	return obj
+}
+
+// collectFields collects the fields of the selection set applicable to the type
+// with the given name, grouped by response key. Fragments are expanded.
+func (e *executor) collectFields(typeName string, sels []selection, keys *[]string, fields map[string][]*field, visited map[string]bool) error {
+	for _, s := range sels {
+		var ds []*directive
+		var typeCond string
+		var sub []selection
+		switch s := s.(type) {
+		case *field:
+			ok, err := e.included(s.directives)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+			key := s.responseKey()
+			if _, ok := fields[key]; !ok {
+				*keys = append(*keys, key)
+			}
+			fields[key] = append(fields[key], s)
+			continue
+		case *fragmentSpread:
+			f := e.doc.fragments[s.name]
+			if f == nil {
+				return fmt.Errorf("unknown fragment: %s", s.name)
+			}
+			if visited[s.name] {
+				continue
+			}
+			visited[s.name] = true
+			ds, typeCond, sub = slices.Concat(s.directives, f.directives), f.typeCond, f.selections
+		case *inlineFragment:
+			ds, typeCond, sub = s.directives, s.typeCond, s.selections
+		}
+
+		if typeCond != "" && typeCond != typeName {
+			continue
+		}
+		ok, err := e.included(ds)
+		if err != nil {
+			return err
+		}
+		if ok {
+			if err := e.collectFields(typeName, sub, keys, fields, visited); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// included evaluates the @skip and @include directives.
+func (e *executor) included(ds []*directive) (bool, error) {
+	for _, d := range ds {
+		if d.name != "skip" && d.name != "include" {
+			return false, fmt.Errorf("unknown directive: @%s", d.name)
+		}
+		args, err := e.args(d.args)
+		if err != nil {
+			return false, err
+		}
+		cond, ok := args["if"].(bool)
+		if !ok || len(args) != 1 {
+			return false, fmt.Errorf("directive @%s requires a single Boolean argument: if", d.name)
+		}
+		if cond == (d.name == "skip") {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// resolveField resolves the field on the struct value v, and completes its value.
+// fs are the fields with the same response key, their selection sets are merged.
+func (e *executor) resolveField(v reflect.Value, fs []*field, path []any) (any, error) {
+	f := fs[0]
+	var sels []selection
+	for _, f := range fs {
+		sels = append(sels, f.selections...)
+	}
+
+	args, err := e.args(f.args)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.name == "__typename" {
+		if len(args) > 0 || len(sels) > 0 {
+			return nil, errors.New("__typename takes no arguments and no selection")
+		}
+		return v.Type().Name(), nil
+	}
+
+	first, skip, paged, err := pageArgs(args)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := resolve(v, f.name, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if paged {
+		for result.Kind() == reflect.Pointer || result.Kind() == reflect.Interface {
+			result = result.Elem()
+		}
+		if result.Kind() != reflect.Slice && result.Kind() != reflect.Array {
+			return nil, fmt.Errorf("first and skip arguments are only allowed on lists, field: %s", f.name)
+		}
+		n := result.Len()
+		low := min(skip, n)
+		high := n
+		if first >= 0 {
+			high = min(low+first, n)
+		}
+		if result.Kind() == reflect.Array && !result.CanAddr() { // Slicing arrays requires addressability
+			a := reflect.New(result.Type()).Elem()
+			a.Set(result)
+			result = a
+		}
+		result = result.Slice(low, high)
+	}
+
+	return e.complete(result, sels, path)
+}
+
+// pageArgs extracts (and removes) the first and skip arguments from args.
+// first is -1 if not given.
+func pageArgs(args Args) (first, skip int, paged bool, err error) {
+	first = -1
+	for _, name := range []string{"first", "skip"} {
+		value, ok := args[name]
+		if !ok {
+			continue
+		}
+		delete(args, name)
+		n, ok := intValue(value)
+		if !ok || n < 0 {
+			return 0, 0, false, fmt.Errorf("argument %s must be a non-negative Int", name)
+		}
+		if name == "first" {
+			first = n
+		} else {
+			skip = n
+		}
+		paged = true
+	}
+	return
+}
+
+// intValue returns the int value of an argument value.
+func intValue(value any) (n int, ok bool) {
+	switch value := value.(type) {
+	case int64:
+		return int(value), true
+	case float64:
+		if value == math.Trunc(value) && math.Abs(value) <= math.MaxInt32 {
+			return int(value), true
+		}
+	}
+	return 0, false
+}
+
+// resolve resolves the field with the given name on the struct value v.
+func resolve(v reflect.Value, name string, args Args) (result reflect.Value, err error) {
+	t := v.Type()
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = reflect.Value{}, fmt.Errorf("failed to resolve field %s of type %s: %v", name, t.Name(), r)
+		}
+	}()
+
+	match := func(s string) bool { return strings.EqualFold(s, name) }
+
+	if sf, ok := t.FieldByNameFunc(match); ok && sf.IsExported() && sf.Tag.Get("json") != "-" {
+		if len(args) > 0 {
+			return reflect.Value{}, fmt.Errorf("field %s of type %s takes no arguments", name, t.Name())
+		}
+		fv, err := v.FieldByIndexErr(sf.Index)
+		if err != nil {
+			return reflect.Value{}, nil // Field of nil embedded pointer
+		}
+		return fv, nil
+	}
+
+	// Pointer receiver methods are in the method set of the pointer type
+	pv := v
+	if v.CanAddr() {
+		pv = v.Addr()
+	} else {
+		pv = reflect.New(t)
+		pv.Elem().Set(v)
+	}
+	pt := pv.Type()
+	for i := range pt.NumMethod() {
+		m := pt.Method(i)
+		if !match(m.Name) || !resolverMethod(m.Type) {
+			continue
+		}
+		var in []reflect.Value
+		if m.Type.NumIn() == 2 {
+			if args == nil {
+				args = Args{}
+			}
+			in = append(in, reflect.ValueOf(args))
+		} else if len(args) > 0 {
+			return reflect.Value{}, fmt.Errorf("field %s of type %s takes no arguments", name, t.Name())
+		}
+		out := pv.Method(i).Call(in)
+		if len(out) == 2 && !out[1].IsNil() {
+			return reflect.Value{}, out[1].Interface().(error)
+		}
+		return out[0], nil
+	}
+
+	return reflect.Value{}, fmt.Errorf("unknown field %s on type %s", name, t.Name())
+}
+
+// resolverMethod tells if the method type (including the receiver) is eligible
+// to resolve fields: an optional Args parameter, and a value result
+// optionally followed by an error.
+func resolverMethod(mt reflect.Type) bool {
+	switch mt.NumIn() {
+	case 1:
+	case 2:
+		if mt.In(1) != argsType {
+			return false
+		}
+	default:
+		return false
+	}
+	switch mt.NumOut() {
+	case 1:
+	case 2:
+		if mt.Out(1) != errorType {
+			return false
+		}
+	default:
+		return false
+	}
+	return mt.Out(0) != errorType
+}
+
+// complete completes the value of a field: objects are selected, lists are completed
+// element-wise, scalars are converted to JSON values.
+func (e *executor) complete(v reflect.Value, sels []selection, path []any) (any, error) {
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return nil, nil
+	}
+
+	t := v.Type()
+	leaf := func(value any) (any, error) {
+		if len(sels) > 0 {
+			return nil, fmt.Errorf("field of scalar type %s must not have a selection", t)
+		}
+		return value, nil
+	}
+
+	switch t {
+	case timeType:
+		return leaf(v.Interface().(time.Time).Format(time.RFC3339))
+	case durationType:
+		return leaf(v.Interface().(time.Duration).String())
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if len(sels) == 0 {
+			return nil, fmt.Errorf("field of object type %s must have a selection of subfields", t.Name())
+		}
+		return e.selectObject(v, sels, path), nil
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && v.IsNil() {
+			return nil, nil
+		}
+		list := make([]any, v.Len())
+		for i := range list {
+			item, err := e.complete(v.Index(i), sels, append(path[:len(path):len(path)], i))
+			if err != nil {
+				return nil, err
+			}
+			list[i] = item
+		}
+		return list, nil
+	case reflect.Map:
+		return leaf(v.Interface())
+	case reflect.Bool:
+		return leaf(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return leaf(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return leaf(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return leaf(v.Float())
+	case reflect.String:
+		return leaf(v.String())
+	}
+	return nil, fmt.Errorf("unsupported type: %s", t)
+}
+
+// args evaluates the arguments.
+func (e *executor) args(as []*argument) (Args, error) {
+	if len(as) == 0 {
+		return nil, nil
+	}
+	args := make(Args, len(as))
+	for _, a := range as {
+		if _, ok := args[a.name]; ok {
+			return nil, fmt.Errorf("duplicate argument: %s", a.name)
+		}
+		value, err := e.value(a.value)
+		if err != nil {
+			return nil, err
+		}
+		args[a.name] = value
+	}
+	return args, nil
+}
+
+// value evaluates a value: variables are substituted, enum values become strings.
+func (e *executor) value(v any) (any, error) {
+	switch v := v.(type) {
+	case variable:
+		if !e.defined[string(v)] {
+			return nil, fmt.Errorf("undefined variable: $%s", v)
+		}
+		return e.vars[string(v)], nil
+	case enumValue:
+		return string(v), nil
+	case []any:
+		list := make([]any, len(v))
+		for i, item := range v {
+			var err error
+			if list[i], err = e.value(item); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	case map[string]any:
+		obj := make(map[string]any, len(v))
+		for k, item := range v {
+			var err error
+			if obj[k], err = e.value(item); err != nil {
+				return nil, err
+			}
+		}
+		return obj, nil
+	}
+	return v, nil
+}
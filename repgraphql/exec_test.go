@@ -0,0 +1,172 @@
+package repgraphql
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+type testRoot struct {
+	Items   []*testItem
+	Animals []testAnimal
+	Hidden  string `json:"-"`
+	Time    time.Time
+	Dur     time.Duration
+	Counts  [3]int
+}
+
+type testItem struct {
+	ID   int
+	Name string
+	Tags []string
+}
+
+// Hex returns the name hex encoded.
+func (it *testItem) Hex() string { return fmt.Sprintf("%X", it.Name) }
+
+// Find returns the item with the id argument.
+func (r *testRoot) Find(args Args) (*testItem, error) {
+	id, ok := intValue(args["id"])
+	if !ok {
+		return nil, errors.New("id required")
+	}
+	for _, it := range r.Items {
+		if it.ID == id {
+			return it, nil
+		}
+	}
+	return nil, nil
+}
+
+type testAnimal interface{ Sound() string }
+
+type Dog struct{ Name string }
+
+func (Dog) Sound() string { return "woof" }
+
+type Cat struct{ Lives int }
+
+func (Cat) Sound() string { return "meow" }
+
+func TestExecute(t *testing.T) {
+	root := &testRoot{
+		Items: []*testItem{
+			{ID: 1, Name: "ab", Tags: []string{"x"}},
+			{ID: 2, Name: "cd"},
+			{ID: 3, Name: "ef"},
+		},
+		Animals: []testAnimal{Dog{Name: "Rex"}, Cat{Lives: 9}},
+		Time:    time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Dur:     90 * time.Second,
+		Counts:  [3]int{4, 5, 6},
+	}
+
+	cases := []struct {
+		name  string
+		query string
+		op    string
+		vars  map[string]any
+		exp   string // Expected JSON response
+	}{
+		{
+			name:  "fields",
+			query: "{ items { id name tags } }",
+			exp:   `{"data":{"items":[{"id":1,"name":"ab","tags":["x"]},{"id":2,"name":"cd","tags":null},{"id":3,"name":"ef","tags":null}]}}`,
+		},
+		{
+			name:  "aliases, case-insensitivity, methods",
+			query: "{ all: Items { ID n: HEX } }",
+			exp:   `{"data":{"all":[{"ID":1,"n":"6162"},{"ID":2,"n":"6364"},{"ID":3,"n":"6566"}]}}`,
+		},
+		{
+			name:  "paging",
+			query: "{ items(skip: 1, first: 1) { id } counts(first: 2) }",
+			exp:   `{"data":{"items":[{"id":2}],"counts":[4,5]}}`,
+		},
+		{
+			name:  "args and variables",
+			query: "query Q($id: Int = 1) { find(id: $id) { name } }",
+			vars:  map[string]any{"id": 3.0},
+			exp:   `{"data":{"find":{"name":"ef"}}}`,
+		},
+		{
+			name:  "default variable",
+			query: "query Q($id: Int = 2) { find(id: $id) { name } }",
+			exp:   `{"data":{"find":{"name":"cd"}}}`,
+		},
+		{
+			name:  "fragments and typename",
+			query: "{ animals { __typename sound ... on Dog { name } ...C } } fragment C on Cat { lives }",
+			exp:   `{"data":{"animals":[{"__typename":"Dog","sound":"woof","name":"Rex"},{"__typename":"Cat","sound":"meow","lives":9}]}}`,
+		},
+		{
+			name:  "merged fields",
+			query: "{ items(first: 1) { id } items(first: 1) { name } }",
+			exp:   `{"data":{"items":[{"id":1,"name":"ab"}]}}`,
+		},
+		{
+			name:  "directives",
+			query: "query ($s: Boolean!) { time @skip(if: $s) dur @include(if: $s) }",
+			vars:  map[string]any{"s": true},
+			exp:   `{"data":{"dur":"1m30s"}}`,
+		},
+		{
+			name:  "time",
+			query: "{ time }",
+			exp:   `{"data":{"time":"2020-01-02T03:04:05Z"}}`,
+		},
+		{
+			name:  "operation name",
+			query: "query A { dur } query B { counts }",
+			op:    "B",
+			exp:   `{"data":{"counts":[4,5,6]}}`,
+		},
+		{
+			name:  "field errors",
+			query: "{ hidden unknown items(first: 1) { id name { x } } find { id } dur }",
+			exp: `{"data":{"hidden":null,"unknown":null,"items":[{"id":1,"name":null}],"find":null,"dur":"1m30s"},"errors":[` +
+				`{"message":"unknown field hidden on type testRoot","path":["hidden"]},` +
+				`{"message":"unknown field unknown on type testRoot","path":["unknown"]},` +
+				`{"message":"field of scalar type string must not have a selection","path":["items",0,"name"]},` +
+				`{"message":"id required","path":["find"]}]}`,
+		},
+		{
+			name:  "missing selection",
+			query: "{ items }",
+			exp:   `{"data":{"items":null},"errors":[{"message":"field of object type testItem must have a selection of subfields","path":["items"]}]}`,
+		},
+		{
+			name:  "syntax error",
+			query: "{ items {",
+			exp:   `{"errors":[{"message":"syntax error at 1:10: expected name, found \"\u003cEOF\u003e\""}]}`,
+		},
+		{
+			name:  "missing operation name",
+			query: "query A { dur } query B { counts }",
+			exp:   `{"errors":[{"message":"operationName is required for documents with multiple operations"}]}`,
+		},
+		{
+			name:  "missing variable",
+			query: "query ($id: Int!) { find(id: $id) { id } }",
+			exp:   `{"errors":[{"message":"variable $id of required type was not provided"}]}`,
+		},
+		{
+			name:  "mutation",
+			query: "mutation { dur }",
+			exp:   `{"errors":[{"message":"unsupported operation type: mutation"}]}`,
+		},
+	}
+
+	for _, c := range cases {
+		data, err := json.Marshal(Execute(root, c.query, c.op, c.vars))
+		if err != nil {
+			t.Errorf("[%s] Expected no error, got: %v", c.name, err)
+			continue
+		}
+		if got := string(data); got != c.exp {
+			t.Errorf("[%s] Expected: %v, got: %v", c.name, c.exp, got)
+		}
+	}
+}
@@ -0,0 +1,560 @@
+// This file contains the parser of GraphQL query documents.
+
+package repgraphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// document is a parsed GraphQL document.
+type document struct {
+	operations []*operation
+	fragments  map[string]*fragment
+}
+
+// operation is an operation definition of a document.
+type operation struct {
+	kind       string // "query", "mutation" or "subscription"
+	name       string
+	varDefs    []*varDef
+	selections []selection
+}
+
+// varDef is a variable definition of an operation.
+type varDef struct {
+	name         string
+	nonNull      bool
+	defaultValue any // nil if there is no default value
+}
+
+// fragment is a named fragment definition.
+type fragment struct {
+	name       string
+	typeCond   string
+	directives []*directive
+	selections []selection
+}
+
+// selection is a *field, a *fragmentSpread or an *inlineFragment.
+type selection any
+
+// field is a selected field.
+type field struct {
+	alias, name string
+	args        []*argument
+	directives  []*directive
+	selections  []selection
+}
+
+// responseKey returns the key of the field in the response.
+func (f *field) responseKey() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	return f.name
+}
+
+// fragmentSpread is a spread of a named fragment.
+type fragmentSpread struct {
+	name       string
+	directives []*directive
+}
+
+// inlineFragment is an inline fragment, typeCond is optional.
+type inlineFragment struct {
+	typeCond   string
+	directives []*directive
+	selections []selection
+}
+
+// argument is an argument of a field or directive.
+type argument struct {
+	name  string
+	value any
+}
+
+// directive is a directive, e.g. @include(if: $x).
+type directive struct {
+	name string
+	args []*argument
+}
+
+// Literal values are represented as: string, int64, float64, bool, nil,
+// enumValue, variable, []any and map[string]any.
+type (
+	// enumValue is an enum literal.
+	enumValue string
+
+	// variable is a variable reference.
+	variable string
+)
+
+// token kinds
+const (
+	tokEOF = iota
+	tokPunct
+	tokName
+	tokInt
+	tokFloat
+	tokString
+)
+
+// token is a lexical token.
+type token struct {
+	kind int
+	text string // The value for tokString
+	pos  int
+}
+
+// parser parses a GraphQL document.
+type parser struct {
+	src string
+	pos int // Position of the next token
+	tok token
+}
+
+// parseDocument parses a GraphQL query document.
+func parseDocument(src string) (doc *document, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			pe, ok := r.(syntaxError)
+			if !ok {
+				panic(r)
+			}
+			doc, err = nil, pe
+		}
+	}()
+
+	p := &parser{src: src}
+	p.next()
+
+	doc = &document{fragments: map[string]*fragment{}}
+	for p.tok.kind != tokEOF {
+		switch {
+		case p.peek("{"):
+			doc.operations = append(doc.operations, &operation{kind: "query", selections: p.selectionSet()})
+		case p.peekName("query"), p.peekName("mutation"), p.peekName("subscription"):
+			doc.operations = append(doc.operations, p.operation())
+		case p.peekName("fragment"):
+			p.next()
+			f := &fragment{name: p.name()}
+			if f.name == "on" {
+				p.errorf("invalid fragment name: on")
+			}
+			p.expectName("on")
+			f.typeCond = p.name()
+			f.directives = p.directives()
+			f.selections = p.selectionSet()
+			if doc.fragments[f.name] != nil {
+				p.errorf("duplicate fragment: %s", f.name)
+			}
+			doc.fragments[f.name] = f
+		default:
+			p.errorf("unexpected %q", p.tok.text)
+		}
+	}
+	if len(doc.operations) == 0 {
+		p.errorf("no operation")
+	}
+	return doc, nil
+}
+
+// syntaxError is a syntax error of a document.
+type syntaxError string
+
+func (e syntaxError) Error() string { return string(e) }
+
+// errorf reports a syntax error at the current token.
+func (p *parser) errorf(format string, args ...any) {
+	line, col := 1, 1
+	for _, r := range p.src[:p.tok.pos] {
+		if r == '\n' {
+			line, col = line+1, 1
+		} else {
+			col++
+		}
+	}
+	panic(syntaxError(fmt.Sprintf("syntax error at %d:%d: %s", line, col, fmt.Sprintf(format, args...))))
+}
+
+// operation parses an operation definition starting with its kind.
+func (p *parser) operation() *operation {
+	op := &operation{kind: p.name()}
+	if p.tok.kind == tokName {
+		op.name = p.name()
+	}
+	if p.skip("(") {
+		for !p.skip(")") {
+			p.expect("$")
+			vd := &varDef{name: p.name()}
+			p.expect(":")
+			vd.nonNull = p.typeRef()
+			if p.skip("=") {
+				vd.defaultValue = p.value(true)
+			}
+			op.varDefs = append(op.varDefs, vd)
+		}
+	}
+	p.directives()
+	op.selections = p.selectionSet()
+	return op
+}
+
+// typeRef parses a type reference, and tells if it's non-null.
+func (p *parser) typeRef() (nonNull bool) {
+	if p.skip("[") {
+		p.typeRef()
+		p.expect("]")
+	} else {
+		p.name()
+	}
+	return p.skip("!")
+}
+
+// selectionSet parses a selection set.
+func (p *parser) selectionSet() (sels []selection) {
+	p.expect("{")
+	for !p.skip("}") {
+		if p.skip("...") {
+			if p.tok.kind == tokName && p.tok.text != "on" {
+				sels = append(sels, &fragmentSpread{name: p.name(), directives: p.directives()})
+				continue
+			}
+			f := &inlineFragment{}
+			if p.peekName("on") {
+				p.next()
+				f.typeCond = p.name()
+			}
+			f.directives = p.directives()
+			f.selections = p.selectionSet()
+			sels = append(sels, f)
+			continue
+		}
+
+		f := &field{name: p.name()}
+		if p.skip(":") {
+			f.alias, f.name = f.name, p.name()
+		}
+		f.args = p.arguments(false)
+		f.directives = p.directives()
+		if p.peek("{") {
+			f.selections = p.selectionSet()
+		}
+		sels = append(sels, f)
+	}
+	if len(sels) == 0 {
+		p.errorf("empty selection set")
+	}
+	return
+}
+
+// arguments parses optional arguments.
+func (p *parser) arguments(constant bool) (args []*argument) {
+	if !p.skip("(") {
+		return nil
+	}
+	for !p.skip(")") {
+		a := &argument{name: p.name()}
+		p.expect(":")
+		a.value = p.value(constant)
+		args = append(args, a)
+	}
+	return
+}
+
+// directives parses optional directives.
+func (p *parser) directives() (ds []*directive) {
+	for p.skip("@") {
+		ds = append(ds, &directive{name: p.name(), args: p.arguments(false)})
+	}
+	return
+}
+
+// value parses a value. Variables are not allowed in constant values.
+func (p *parser) value(constant bool) any {
+	t := p.tok
+	switch t.kind {
+	case tokInt:
+		p.next()
+		n, err := strconv.ParseInt(t.text, 10, 64)
+		if err != nil {
+			p.errorf("invalid int: %s", t.text)
+		}
+		return n
+	case tokFloat:
+		p.next()
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			p.errorf("invalid float: %s", t.text)
+		}
+		return f
+	case tokString:
+		p.next()
+		return t.text
+	case tokName:
+		p.next()
+		switch t.text {
+		case "true":
+			return true
+		case "false":
+			return false
+		case "null":
+			return nil
+		}
+		return enumValue(t.text)
+	}
+
+	switch {
+	case p.skip("$"):
+		if constant {
+			p.errorf("variable in constant value")
+		}
+		return variable(p.name())
+	case p.skip("["):
+		list := []any{}
+		for !p.skip("]") {
+			list = append(list, p.value(constant))
+		}
+		return list
+	case p.skip("{"):
+		obj := map[string]any{}
+		for !p.skip("}") {
+			name := p.name()
+			p.expect(":")
+			obj[name] = p.value(constant)
+		}
+		return obj
+	}
+	p.errorf("unexpected %q", t.text)
+	return nil
+}
+
+// peek tells if the current token is the given punctuator.
+func (p *parser) peek(punct string) bool {
+	return p.tok.kind == tokPunct && p.tok.text == punct
+}
+
+// peekName tells if the current token is the given name.
+func (p *parser) peekName(name string) bool {
+	return p.tok.kind == tokName && p.tok.text == name
+}
+
+// skip skips the current token if it's the given punctuator, and tells if it did.
+func (p *parser) skip(punct string) bool {
+	if p.peek(punct) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+// expect skips the given punctuator, reports an error if the current token is something else.
+func (p *parser) expect(punct string) {
+	if !p.skip(punct) {
+		p.errorf("expected %q, found %q", punct, p.tok.text)
+	}
+}
+
+// expectName skips the given name, reports an error if the current token is something else.
+func (p *parser) expectName(name string) {
+	if !p.peekName(name) {
+		p.errorf("expected %q, found %q", name, p.tok.text)
+	}
+	p.next()
+}
+
+// name returns the current name token and advances.
+func (p *parser) name() string {
+	if p.tok.kind != tokName {
+		p.errorf("expected name, found %q", p.tok.text)
+	}
+	name := p.tok.text
+	p.next()
+	return name
+}
+
+// next reads the next token.
+func (p *parser) next() {
+	src := p.src
+	// Skip ignored tokens: white space, line terminators, commas, comments and the BOM.
+	for p.pos < len(src) {
+		switch c := src[p.pos]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			p.pos++
+		case c == '#':
+			for p.pos < len(src) && src[p.pos] != '\n' && src[p.pos] != '\r' {
+				p.pos++
+			}
+		case strings.HasPrefix(src[p.pos:], "\uFEFF"):
+			p.pos += len("\uFEFF")
+		default:
+			goto scan
+		}
+	}
+scan:
+	start := p.pos
+	p.tok = token{pos: start}
+	if p.pos >= len(src) {
+		p.tok.kind = tokEOF
+		p.tok.text = "<EOF>"
+		return
+	}
+
+	c := src[p.pos]
+	switch {
+	case strings.HasPrefix(src[p.pos:], "..."):
+		p.pos += 3
+		p.tok.kind, p.tok.text = tokPunct, "..."
+	case strings.IndexByte("!$()&:=@[]{}|", c) >= 0:
+		p.pos++
+		p.tok.kind, p.tok.text = tokPunct, string(c)
+	case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+		for p.pos < len(src) && isNameChar(src[p.pos]) {
+			p.pos++
+		}
+		p.tok.kind, p.tok.text = tokName, src[start:p.pos]
+	case c == '-' || c >= '0' && c <= '9':
+		p.number()
+	case strings.HasPrefix(src[p.pos:], `"""`):
+		p.blockString()
+	case c == '"':
+		p.string()
+	default:
+		r, _ := utf8.DecodeRuneInString(src[p.pos:])
+		p.errorf("unexpected character %q", r)
+	}
+}
+
+// isNameChar tells if c may be part of a name.
+func isNameChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// number scans an int or float token.
+func (p *parser) number() {
+	src, start := p.src, p.pos
+	digits := func() {
+		for p.pos < len(src) && src[p.pos] >= '0' && src[p.pos] <= '9' {
+			p.pos++
+		}
+	}
+
+	p.tok.kind = tokInt
+	if src[p.pos] == '-' {
+		p.pos++
+	}
+	digits()
+	if p.pos < len(src) && src[p.pos] == '.' {
+		p.tok.kind = tokFloat
+		p.pos++
+		digits()
+	}
+	if p.pos < len(src) && (src[p.pos] == 'e' || src[p.pos] == 'E') {
+		p.tok.kind = tokFloat
+		p.pos++
+		if p.pos < len(src) && (src[p.pos] == '+' || src[p.pos] == '-') {
+			p.pos++
+		}
+		digits()
+	}
+	p.tok.text = src[start:p.pos]
+}
+
+// string scans a string token.
+func (p *parser) string() {
+	src := p.src
+	p.pos++ // Opening quote
+	sb := &strings.Builder{}
+	for {
+		if p.pos >= len(src) || src[p.pos] == '\n' || src[p.pos] == '\r' {
+			p.errorf("unterminated string")
+		}
+		c := src[p.pos]
+		p.pos++
+		switch c {
+		case '"':
+			p.tok.kind, p.tok.text = tokString, sb.String()
+			return
+		case '\\':
+			if p.pos >= len(src) {
+				p.errorf("unterminated string")
+			}
+			e := src[p.pos]
+			p.pos++
+			switch e {
+			case '"', '\\', '/':
+				sb.WriteByte(e)
+			case 'b':
+				sb.WriteByte('\b')
+			case 'f':
+				sb.WriteByte('\f')
+			case 'n':
+				sb.WriteByte('\n')
+			case 'r':
+				sb.WriteByte('\r')
+			case 't':
+				sb.WriteByte('\t')
+			case 'u':
+				if p.pos+4 > len(src) {
+					p.errorf("invalid unicode escape")
+				}
+				n, err := strconv.ParseUint(src[p.pos:p.pos+4], 16, 16)
+				if err != nil {
+					p.errorf("invalid unicode escape")
+				}
+				p.pos += 4
+				sb.WriteRune(rune(n))
+			default:
+				p.errorf("invalid escape: \\%c", e)
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+}
+
+// blockString scans a block string token.
+// The common indentation and leading / trailing blank lines are removed.
+func (p *parser) blockString() {
+	src := p.src
+	p.pos += 3
+	end := strings.Index(src[p.pos:], `"""`)
+	for end >= 0 && end > 0 && src[p.pos+end-1] == '\\' { // Escaped triple quote
+		next := strings.Index(src[p.pos+end+3:], `"""`)
+		if next < 0 {
+			end = -1
+			break
+		}
+		end += 3 + next
+	}
+	if end < 0 {
+		p.errorf("unterminated block string")
+	}
+	raw := strings.ReplaceAll(src[p.pos:p.pos+end], `\"""`, `"""`)
+	p.pos += end + 3
+
+	lines := strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n")
+	indent := -1
+	for _, line := range lines[1:] {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" {
+			continue
+		}
+		if n := len(line) - len(trimmed); indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	for i := 1; i < len(lines) && indent > 0; i++ {
+		lines[i] = lines[i][min(indent, len(lines[i])):]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	p.tok.kind, p.tok.text = tokString, strings.Join(lines, "\n")
+}
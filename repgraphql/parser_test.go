@@ -0,0 +1,77 @@
+package repgraphql
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseDocument(t *testing.T) {
+	cases := []struct {
+		name  string
+		src   string
+		ops   int    // Expected number of operations
+		frags int    // Expected number of fragments
+		err   string // Expected error part, empty if no error
+	}{
+		{name: "shorthand", src: "{ a b { c } }", ops: 1},
+		{name: "named", src: "query Q($x: Int = 3, $y: [String!]!) @d { a(x: $x) }", ops: 1},
+		{name: "multiple", src: "query A { a } query B { b }", ops: 2},
+		{name: "fragments", src: "{ ...F ... on T { a } ... @skip(if: true) { b } } fragment F on T { c }", ops: 1, frags: 1},
+		{name: "values", src: `{ a(i: -1, f: 1.5e3, s: "x\"A", b: """ block """, e: ENUM, n: null, l: [1, 2], o: {k: true}) }`, ops: 1},
+		{name: "comments", src: "# comment\n{ a, b # another\n }", ops: 1},
+		{name: "empty", src: "  ", err: "no operation"},
+		{name: "empty selection", src: "{ }", err: "empty selection set"},
+		{name: "unterminated", src: `{ a(s: "x) }`, err: "unterminated string"},
+		{name: "bad char", src: "{ a ~ }", err: "unexpected character"},
+		{name: "variable in const", src: "query ($x: Int = $y) { a }", err: "variable in constant value"},
+		{name: "duplicate fragment", src: "{ a } fragment F on T { a } fragment F on T { b }", err: "duplicate fragment"},
+		{name: "missing brace", src: "{ a { b }", err: `expected name, found "<EOF>"`},
+		{name: "position", src: "{\n  a(:)\n}", err: "at 2:5"},
+	}
+
+	for _, c := range cases {
+		doc, err := parseDocument(c.src)
+		if c.err != "" {
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("[%s] Expected error: %v, got: %v", c.name, c.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("[%s] Expected no error, got: %v", c.name, err)
+			continue
+		}
+		if len(doc.operations) != c.ops || len(doc.fragments) != c.frags {
+			t.Errorf("[%s] Expected: %d ops, %d fragments, got: %d, %d", c.name, c.ops, c.frags, len(doc.operations), len(doc.fragments))
+		}
+	}
+}
+
+func TestParseValues(t *testing.T) {
+	doc, err := parseDocument(`{ a(s: "x\"A\n", b: """
+		first
+		  second
+	""", e: ENUM, v: $v, l: [1, 2.5]) }`)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	args := doc.operations[0].selections[0].(*field).args
+
+	cases := []struct {
+		name  string
+		value any
+	}{
+		{"s", "x\"A\n"},
+		{"b", "first\n  second"},
+		{"e", enumValue("ENUM")},
+		{"v", variable("v")},
+	}
+	for i, c := range cases {
+		if args[i].name != c.name || args[i].value != c.value {
+			t.Errorf("Expected: %s=%q, got: %s=%q", c.name, c.value, args[i].name, args[i].value)
+		}
+	}
+	if l := args[4].value.([]any); len(l) != 2 || l[0] != int64(1) || l[1] != 2.5 {
+		t.Errorf("Expected: %v, got: %v", []any{1, 2.5}, l)
+	}
+}
@@ -0,0 +1,276 @@
+// This file contains the root of the schema and the HTTP handler.
+
+package repgraphql
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/repindex"
+	"github.com/icza/screp/repparser"
+)
+
+// MaxRequestSize is the max size of request bodies accepted by the handler.
+// Large enough for base64 encoded replays of 32 MB.
+const MaxRequestSize = 48 << 20
+
+var (
+	// ErrNoIndex is returned if a field requiring the replay index is queried without one.
+	ErrNoIndex = errors.New("no replay index")
+)
+
+// Query is the root of the schema.
+//
+// Replays are parsed with commands and map data, and computed with build orders
+// (but without map analysis). A Query caches the replays it parses, so a Query
+// should be created for each request (see NewQuery()).
+type Query struct {
+	idx *repindex.Index
+
+	// replays holds the parsed replays by file path
+	replays map[string]*rep.Replay
+}
+
+// NewQuery returns a new Query. idx is optional, fields of the library require it.
+func NewQuery(idx *repindex.Index) *Query {
+	return &Query{idx: idx, replays: map[string]*rep.Replay{}}
+}
+
+// Replay returns a parsed replay.
+//
+// Arguments (exactly one of them is required):
+//   - file: path of an indexed replay file
+//   - data: base64 encoded content of the replay
+func (q *Query) Replay(args Args) (*rep.Replay, error) {
+	if err := checkArgs(args, "file", "data"); err != nil {
+		return nil, err
+	}
+	file, err := stringArg(args, "file")
+	if err != nil {
+		return nil, err
+	}
+	data, err := stringArg(args, "data")
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case file != "" && data == "":
+		if q.idx == nil {
+			return nil, ErrNoIndex
+		}
+		if q.idx.Files[file] == nil {
+			return nil, fmt.Errorf("not an indexed replay file: %s", file)
+		}
+		return q.parseFile(file)
+	case data != "" && file == "":
+		repData, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid data: %w", err)
+		}
+		r, err := repparser.ParseConfig(repData, parseConfig)
+		if err != nil {
+			return nil, err
+		}
+		computeReplay(r)
+		return r, nil
+	}
+	return nil, errors.New("exactly one of the file and data arguments is required")
+}
+
+// Games returns the indexed games in start time order.
+//
+// Optional arguments filter the games (see repindex.Query):
+//   - player: name of a player of the game
+//   - map: part of the map name
+//   - matchup: matchup of the game, e.g. "TvZ"
+//   - after, before: limits of the start time (RFC 3339)
+func (q *Query) Games(args Args) ([]*Game, error) {
+	if q.idx == nil {
+		return nil, ErrNoIndex
+	}
+	if err := checkArgs(args, "player", "map", "matchup", "after", "before"); err != nil {
+		return nil, err
+	}
+
+	var iq repindex.Query
+	var err error
+	for _, a := range []struct {
+		name string
+		dst  *string
+	}{{"player", &iq.Player}, {"map", &iq.Map}, {"matchup", &iq.Matchup}} {
+		if *a.dst, err = stringArg(args, a.name); err != nil {
+			return nil, err
+		}
+	}
+	for _, a := range []struct {
+		name string
+		dst  *time.Time
+	}{{"after", &iq.After}, {"before", &iq.Before}} {
+		s, err := stringArg(args, a.name)
+		if err != nil || s == "" {
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if *a.dst, err = time.Parse(time.RFC3339, s); err != nil {
+			return nil, fmt.Errorf("invalid %s argument: %w", a.name, err)
+		}
+	}
+
+	games := q.idx.Query(iq)
+	result := make([]*Game, len(games))
+	for i, g := range games {
+		result[i] = &Game{Game: g, q: q}
+	}
+	return result, nil
+}
+
+// Game returns the indexed game with the given fingerprint argument, or nil if there is no such game.
+func (q *Query) Game(args Args) (*Game, error) {
+	if q.idx == nil {
+		return nil, ErrNoIndex
+	}
+	if err := checkArgs(args, "fingerprint"); err != nil {
+		return nil, err
+	}
+	fingerprint, err := stringArg(args, "fingerprint")
+	if err != nil {
+		return nil, err
+	}
+	g := q.idx.Games[fingerprint]
+	if g == nil {
+		return nil, nil
+	}
+	return &Game{Game: g, q: q}, nil
+}
+
+// Game is an indexed game.
+type Game struct {
+	*repindex.Game
+
+	q *Query
+}
+
+// Replay returns the parsed replay of the game: that of its first file that can be parsed.
+func (g *Game) Replay() (*rep.Replay, error) {
+	var err error
+	for _, file := range g.Files {
+		var r *rep.Replay
+		if r, err = g.q.parseFile(file); err == nil {
+			return r, nil
+		}
+	}
+	if err == nil {
+		err = errors.New("game has no replay files")
+	}
+	return nil, err
+}
+
+// parseConfig is the config used to parse replays.
+var parseConfig = repparser.Config{Commands: true, MapData: true}
+
+// parseFile parses and computes the replay file, using the cache of q.
+func (q *Query) parseFile(name string) (*rep.Replay, error) {
+	if r := q.replays[name]; r != nil {
+		return r, nil
+	}
+	r, err := repparser.ParseFileConfig(name, parseConfig)
+	if err != nil {
+		return nil, err
+	}
+	computeReplay(r)
+	q.replays[name] = r
+	return r, nil
+}
+
+// computeReplay computes the replay with build orders.
+func computeReplay(r *rep.Replay) {
+	r.ComputeConfig(rep.ComputeConfig{EAPM: true, Winners: true, BuildOrders: true})
+}
+
+// checkArgs checks that args only contains arguments with the given names.
+func checkArgs(args Args, names ...string) error {
+	for name := range args {
+		if !slices.Contains(names, name) {
+			return fmt.Errorf("unknown argument: %s", name)
+		}
+	}
+	return nil
+}
+
+// stringArg returns the string argument with the given name, empty string if it's missing or null.
+func stringArg(args Args, name string) (string, error) {
+	switch v := args[name].(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	}
+	return "", fmt.Errorf("argument %s must be a String", name)
+}
+
+// request is a GraphQL request.
+type request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+// Handler returns an HTTP handler executing GraphQL requests against NewQuery(idx).
+// idx is optional, it must not be modified while the handler is in use.
+//
+// Requests are accepted as POST requests with a JSON body (having query,
+// operationName and variables fields), or as GET requests with the same URL
+// parameters (variables being JSON encoded).
+func Handler(idx *repindex.Index) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		sendResp := func(status int, resp *Response) {
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(resp)
+		}
+
+		var greq request
+		switch req.Method {
+		case http.MethodPost:
+			data, err := io.ReadAll(http.MaxBytesReader(w, req.Body, MaxRequestSize))
+			if err == nil {
+				err = json.Unmarshal(data, &greq)
+			}
+			if err != nil {
+				sendResp(http.StatusBadRequest, errResponse("invalid request: %v", err))
+				return
+			}
+		case http.MethodGet:
+			params := req.URL.Query()
+			greq.Query = params.Get("query")
+			greq.OperationName = params.Get("operationName")
+			if vars := params.Get("variables"); vars != "" {
+				if err := json.Unmarshal([]byte(vars), &greq.Variables); err != nil {
+					sendResp(http.StatusBadRequest, errResponse("invalid variables: %v", err))
+					return
+				}
+			}
+		default:
+			sendResp(http.StatusMethodNotAllowed, errResponse("method not allowed: %s", req.Method))
+			return
+		}
+
+		resp := Execute(NewQuery(idx), greq.Query, greq.OperationName, greq.Variables)
+		status := http.StatusOK
+		if resp.Data == nil {
+			status = http.StatusBadRequest
+		}
+		sendResp(status, resp)
+	})
+}
@@ -0,0 +1,173 @@
+package repgraphql
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcmd"
+	"github.com/icza/screp/rep/repcore"
+	"github.com/icza/screp/repindex"
+	"github.com/icza/screp/repwriter"
+)
+
+// testRepData returns the content of a test replay.
+func testRepData(t *testing.T) []byte {
+	p1 := &rep.Player{SlotID: 0, ID: 0, Type: repcore.PlayerTypeHuman, Race: repcore.RaceZerg, Team: 1, Name: "Alice", Color: repcore.ColorRed}
+	p2 := &rep.Player{SlotID: 1, ID: 1, Type: repcore.PlayerTypeHuman, Race: repcore.RaceTerran, Team: 2, Name: "Bob", Color: repcore.ColorBlue}
+	base := func(frame repcore.Frame, pid byte, typeID byte) *repcmd.Base {
+		return &repcmd.Base{Frame: frame, PlayerID: pid, Type: repcmd.TypeByID(typeID)}
+	}
+
+	r := &rep.Replay{
+		Header: &rep.Header{
+			Engine:      repcore.EngineBroodWar,
+			Frames:      2000,
+			StartTime:   time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+			Title:       "Test game",
+			MapWidth:    64,
+			MapHeight:   64,
+			Speed:       repcore.SpeedFastest,
+			Type:        repcore.GameTypeMelee,
+			Host:        "Alice",
+			Map:         "Test map",
+			OrigPlayers: []*rep.Player{p1, p2},
+		},
+		Commands: &rep.Commands{Cmds: []repcmd.Cmd{
+			&repcmd.TrainCmd{Base: base(20, 0, repcmd.TypeIDTrain), Unit: repcmd.UnitByID(0x29)},
+			&repcmd.ChatCmd{Base: base(50, 1, repcmd.TypeIDChat), SenderSlotID: 1, Message: "gl hf"},
+			&repcmd.TrainCmd{Base: base(60, 1, repcmd.TypeIDTrain), Unit: repcmd.UnitByID(0x07)},
+			&repcmd.LeaveGameCmd{Base: base(1900, 1, repcmd.TypeIDLeaveGame), Reason: repcmd.LeaveReasonByID(1)},
+		}},
+	}
+
+	data, err := repwriter.Marshal(r)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	return data
+}
+
+func TestQuery(t *testing.T) {
+	data := testRepData(t)
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.rep")
+	if err := os.WriteFile(file, data, 0o644); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	idx := repindex.New()
+	if _, err := idx.Update([]string{dir}, false); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	cases := []struct {
+		name  string
+		idx   *repindex.Index
+		query string
+		vars  map[string]any
+		exp   string // Expected JSON response
+	}{
+		{
+			name:  "replay data",
+			query: `query ($data: String) { replay(data: $data) { header { title players { name race { shortName } } } } }`,
+			vars:  map[string]any{"data": base64.StdEncoding.EncodeToString(data)},
+			exp: `{"data":{"replay":{"header":{"title":"Test game","players":[` +
+				`{"name":"Alice","race":{"shortName":"zerg"}},{"name":"Bob","race":{"shortName":"ran"}}]}}}}`,
+		},
+		{
+			name:  "games",
+			idx:   idx,
+			query: `{ games(player: "bob") { map matchup replay { computed { chatCmds { frame playerID message } } } } }`,
+			exp:   `{"data":{"games":[{"map":"Test map","matchup":"ZvT","replay":{"computed":{"chatCmds":[{"frame":50,"playerID":1,"message":"gl hf"}]}}}]}}`,
+		},
+		{
+			name:  "build orders",
+			idx:   idx,
+			query: `query ($file: String) { replay(file: $file) { computed { playerDescs { buildOrder { frame name } } } } }`,
+			vars:  map[string]any{"file": file},
+			exp:   `{"data":{"replay":{"computed":{"playerDescs":[{"buildOrder":[{"frame":20,"name":"Drone"}]},{"buildOrder":[{"frame":60,"name":"SCV"}]}]}}}}`,
+		},
+		{
+			name:  "no games",
+			idx:   idx,
+			query: `{ games(map: "other", after: "2020-01-01T00:00:00Z") { map } }`,
+			exp:   `{"data":{"games":[]}}`,
+		},
+		{
+			name:  "no index",
+			query: `{ games { map } }`,
+			exp:   `{"data":{"games":null},"errors":[{"message":"no replay index","path":["games"]}]}`,
+		},
+		{
+			name:  "not indexed",
+			idx:   idx,
+			query: `{ replay(file: "b.rep") { header { title } } }`,
+			exp:   `{"data":{"replay":null},"errors":[{"message":"not an indexed replay file: b.rep","path":["replay"]}]}`,
+		},
+		{
+			name:  "unknown argument",
+			idx:   idx,
+			query: `{ game(id: "x") { map } }`,
+			exp:   `{"data":{"game":null},"errors":[{"message":"unknown argument: id","path":["game"]}]}`,
+		},
+	}
+
+	for _, c := range cases {
+		data, err := json.Marshal(Execute(NewQuery(c.idx), c.query, "", c.vars))
+		if err != nil {
+			t.Errorf("[%s] Expected no error, got: %v", c.name, err)
+			continue
+		}
+		if got := string(data); got != c.exp {
+			t.Errorf("[%s] Expected: %v, got: %v", c.name, c.exp, got)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	srv := httptest.NewServer(Handler(nil))
+	defer srv.Close()
+
+	body, _ := json.Marshal(map[string]any{
+		"query":     `query ($data: String) { replay(data: $data) { header { frames } } }`,
+		"variables": map[string]any{"data": base64.StdEncoding.EncodeToString(testRepData(t))},
+	})
+	post := func() (*http.Response, error) {
+		return http.Post(srv.URL, "application/json", bytes.NewReader(body))
+	}
+	get := func() (*http.Response, error) {
+		return http.Get(srv.URL + "?query=" + url.QueryEscape("{ replay { header { frames } } }"))
+	}
+
+	cases := []struct {
+		name   string
+		do     func() (*http.Response, error)
+		status int
+		exp    string
+	}{
+		{"post", post, http.StatusOK, `{"data":{"replay":{"header":{"frames":2000}}}}`},
+		{"get", get, http.StatusOK, `{"data":{"replay":null},"errors":[{"message":"exactly one of the file and data arguments is required","path":["replay"]}]}`},
+	}
+
+	for _, c := range cases {
+		resp, err := c.do()
+		if err != nil {
+			t.Errorf("[%s] Expected no error, got: %v", c.name, err)
+			continue
+		}
+		var got bytes.Buffer
+		got.ReadFrom(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != c.status || got.String() != c.exp+"\n" {
+			t.Errorf("[%s] Expected: %d %v, got: %d %v", c.name, c.status, c.exp, resp.StatusCode, got.String())
+		}
+	}
+}
@@ -0,0 +1,30 @@
+/*
+
+Package repgrpc implements the gRPC server of the ReplayService defined in proto/screp.proto.
+
+Messages are encoded and decoded by the repproto package, so the server needs no code
+generated from proto/screp.proto; clients may use the stubs generated in any language.
+NewServer returns a gRPC server having the service registered:
+
+	lis, err := net.Listen("tcp", "localhost:8081")
+	if err != nil {
+		// Handle error
+	}
+	err = repgrpc.NewServer().Serve(lis)
+
+Go clients may call the service without generated stubs too, passing serialized messages
+and forcing Codec, e.g.:
+
+	req := &repproto.ParseRequest{Replay: data, Computed: true}
+	var resp []byte
+	err := conn.Invoke(ctx, repgrpc.MethodParse, req.Marshal(), &resp, grpc.ForceCodec(repgrpc.Codec{}))
+	r, err := repproto.DecodeParseResponse(resp)
+
+ParseStream decodes the commands on demand (see repparser.CmdReader), and streams them as they
+are decoded, so clients can render progress and process huge replays without waiting for
+or holding the full response.
+
+The screp command line tool runs the server with its grpc subcommand.
+
+*/
+package repgrpc
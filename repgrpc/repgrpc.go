@@ -0,0 +1,231 @@
+package repgrpc
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcmd"
+	"github.com/icza/screp/repparser"
+	"github.com/icza/screp/repproto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// ServiceName is the full name of the ReplayService.
+	ServiceName = "screp.v1.ReplayService"
+
+	// MethodParse is the full method name of ReplayService.Parse.
+	MethodParse = "/" + ServiceName + "/Parse"
+
+	// MethodParseStream is the full method name of ReplayService.ParseStream.
+	MethodParseStream = "/" + ServiceName + "/ParseStream"
+)
+
+// MaxRequestSize is the max size of requests accepted by the server.
+// Large enough for replays of 32 MB.
+const MaxRequestSize = 33 << 20
+
+// Codec is the gRPC codec of serialized messages: it passes []byte values as-is.
+// Marshal accepts []byte and *[]byte values, Unmarshal accepts *[]byte values.
+//
+// Its name is "proto" as the messages are in protocol buffer wire format.
+type Codec struct{}
+
+// Marshal implements encoding.Codec.
+func (Codec) Marshal(v any) ([]byte, error) {
+	switch x := v.(type) {
+	case []byte:
+		return x, nil
+	case *[]byte:
+		return *x, nil
+	}
+	return nil, fmt.Errorf("unsupported message type: %T", v)
+}
+
+// Unmarshal implements encoding.Codec.
+func (Codec) Unmarshal(data []byte, v any) error {
+	p, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("unsupported message type: %T", v)
+	}
+	*p = data
+	return nil
+}
+
+// Name implements encoding.Codec.
+func (Codec) Name() string {
+	return "proto"
+}
+
+// serviceDesc describes the ReplayService for gRPC.
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*any)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Parse", Handler: parseHandler},
+	},
+	Streams: []grpc.StreamDesc{
+		{StreamName: "ParseStream", Handler: parseStreamHandler, ServerStreams: true},
+	},
+	Metadata: "proto/screp.proto",
+}
+
+// NewServer returns a new gRPC server having the ReplayService registered.
+//
+// The server uses Codec and accepts requests up to MaxRequestSize.
+// The given options are applied after these, so they may override them.
+func NewServer(opts ...grpc.ServerOption) *grpc.Server {
+	opts = append([]grpc.ServerOption{grpc.ForceServerCodec(Codec{}), grpc.MaxRecvMsgSize(MaxRequestSize)}, opts...)
+	s := grpc.NewServer(opts...)
+	s.RegisterService(&serviceDesc, nil)
+	return s
+}
+
+// parseHandler is the handler of ReplayService.Parse.
+func parseHandler(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	var req []byte
+	if err := dec(&req); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return parse(req)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: MethodParse}
+	return interceptor(ctx, req, info, func(ctx context.Context, req any) (any, error) {
+		return parse(req.([]byte))
+	})
+}
+
+// parse parses the replay of a serialized ParseRequest, and returns the serialized ParseResponse.
+func parse(data []byte) ([]byte, error) {
+	req, err := repproto.DecodeParseRequest(data)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	r, err := parseReplay(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := repproto.ParseResponse(r)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return resp, nil
+}
+
+// parseReplay parses (and computes) the replay of the request.
+// Commands are also parsed if only computed data is requested, but they are not returned.
+func parseReplay(req *repproto.ParseRequest) (*rep.Replay, error) {
+	cfg := repparser.Config{Commands: req.Commands || req.Computed, MapData: req.MapData}
+	r, err := repparser.ParseConfig(req.Replay, cfg)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if req.Computed {
+		r.Compute()
+	}
+	if !req.Commands {
+		r.Commands = nil
+	}
+	return r, nil
+}
+
+// parseStreamHandler is the handler of ReplayService.ParseStream.
+func parseStreamHandler(srv any, stream grpc.ServerStream) error {
+	var req []byte
+	if err := stream.RecvMsg(&req); err != nil {
+		return err
+	}
+	return parseStream(req, func(event []byte) error { return stream.SendMsg(event) })
+}
+
+// parseStream parses the replay of a serialized ParseRequest, and sends its serialized ParseEvent messages.
+//
+// Commands are decoded on demand by a repparser.CmdReader, and are sent as soon as they are decoded.
+// They are classified (see rep.CmdIneffKind()) as they are read, so the analysis events of build and
+// train commands are accurate. The server only holds the commands of the players for classification,
+// and all the commands if computed data is requested (which is sent after the commands).
+func parseStream(data []byte, send func(event []byte) error) error {
+	req, err := repproto.DecodeParseRequest(data)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	r, cr, err := repparser.ParseConfigLazy(req.Replay, repparser.Config{MapData: req.MapData})
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	s := repproto.NewStream(req.ProgressFrames, send)
+	if err := s.Header(r.Header); err != nil {
+		return err
+	}
+
+	if req.Commands || req.Computed {
+		var cmds []repcmd.Cmd
+		var pecs []*repcmd.ParseErrCmd
+		// Commands of the players by player ID for classification (only if commands are streamed,
+		// Compute() classifies them otherwise), observers' commands are not classified
+		pidCmds := make(map[byte][]repcmd.Cmd, len(r.Header.Players))
+		if req.Commands {
+			for _, p := range r.Header.Players {
+				pidCmds[p.ID] = nil
+			}
+		}
+
+		for cmd, err := range cr.All() {
+			if err != nil {
+				break // Truncated commands data, keep the commands read so far (like the parser)
+			}
+			if pec, ok := cmd.(*repcmd.ParseErrCmd); ok {
+				if req.Computed {
+					pecs = append(pecs, pec)
+				}
+			} else {
+				base := cmd.BaseCmd()
+				if pcmds, ok := pidCmds[base.PlayerID]; ok {
+					pcmds = append(pcmds, cmd)
+					pidCmds[base.PlayerID] = pcmds
+					base.IneffKind = rep.CmdIneffKind(pcmds, len(pcmds)-1)
+				}
+				if req.Computed {
+					cmds = append(cmds, cmd)
+				}
+			}
+			if req.Commands {
+				if err := s.Cmd(cmd); err != nil {
+					return err
+				}
+			}
+		}
+		if req.Commands {
+			if err := s.EndCmds(); err != nil {
+				return err
+			}
+		}
+
+		if req.Computed {
+			r.Commands = &rep.Commands{Cmds: cmds, ParseErrCmds: pecs, BlockStats: cr.BlockStats()}
+		}
+	}
+
+	if r.MapData != nil {
+		if err := s.MapData(r.MapData); err != nil {
+			return err
+		}
+	}
+	if req.Computed {
+		r.Compute()
+		if err := s.Computed(r.Computed); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
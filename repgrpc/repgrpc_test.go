@@ -0,0 +1,160 @@
+package repgrpc
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/icza/screp/repparser"
+	"github.com/icza/screp/repproto"
+	"github.com/icza/screp/reptest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// testConn starts a server, and returns a client connection to it.
+func testConn(t *testing.T) *grpc.ClientConn {
+	lis := bufconn.Listen(1 << 20)
+	s := NewServer()
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(Codec{}), grpc.MaxCallRecvMsgSize(MaxRequestSize)),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestParse(t *testing.T) {
+	data, err := reptest.Generate(reptest.Options{CmdsPerPlayer: 100, ChatRate: 0.05, MapData: true})
+	if err != nil {
+		t.Fatalf("Failed to generate replay: %v", err)
+	}
+	exp, err := repparser.ParseConfig(data, repparser.Config{Commands: true, MapData: true})
+	if err != nil {
+		t.Fatalf("Failed to parse replay: %v", err)
+	}
+	exp.Compute()
+
+	conn := testConn(t)
+	ctx := context.Background()
+
+	cases := []struct {
+		name string
+		req  *repproto.ParseRequest
+	}{
+		{"header", &repproto.ParseRequest{Replay: data}},
+		{"all", &repproto.ParseRequest{Replay: data, Commands: true, MapData: true, Computed: true}},
+		{"computed only", &repproto.ParseRequest{Replay: data, Computed: true}},
+	}
+	for _, c := range cases {
+		var resp []byte
+		if err := conn.Invoke(ctx, MethodParse, c.req.Marshal(), &resp); err != nil {
+			t.Errorf("[%s] Expected no error, got: %v", c.name, err)
+			continue
+		}
+		r, err := repproto.DecodeParseResponse(resp)
+		if err != nil {
+			t.Errorf("[%s] Failed to decode response: %v", c.name, err)
+			continue
+		}
+
+		if r.Header == nil || r.Header.Map != exp.Header.Map || len(r.Header.Players) != len(exp.Header.Players) {
+			t.Errorf("[%s] Expected header: %+v, got: %+v", c.name, exp.Header, r.Header)
+		}
+		if got := r.Commands != nil; got != c.req.Commands {
+			t.Errorf("[%s] Expected commands: %v, got: %v", c.name, c.req.Commands, got)
+		} else if got && len(r.Commands.Cmds) != len(exp.Commands.Cmds) {
+			t.Errorf("[%s] Expected: %d commands, got: %d", c.name, len(exp.Commands.Cmds), len(r.Commands.Cmds))
+		}
+		if got := r.MapData != nil; got != c.req.MapData {
+			t.Errorf("[%s] Expected map data: %v, got: %v", c.name, c.req.MapData, got)
+		}
+		if got := r.Computed != nil; got != c.req.Computed {
+			t.Errorf("[%s] Expected computed: %v, got: %v", c.name, c.req.Computed, got)
+		} else if got && r.Computed.PlayerDescs[0].APM != exp.Computed.PlayerDescs[0].APM {
+			t.Errorf("[%s] Expected APM: %d, got: %d", c.name, exp.Computed.PlayerDescs[0].APM, r.Computed.PlayerDescs[0].APM)
+		}
+	}
+
+	// Invalid replay
+	var resp []byte
+	req := &repproto.ParseRequest{Replay: []byte("not a replay")}
+	err = conn.Invoke(ctx, MethodParse, req.Marshal(), &resp)
+	if code := status.Code(err); code != codes.InvalidArgument {
+		t.Errorf("Expected: %v, got: %v", codes.InvalidArgument, err)
+	}
+}
+
+func TestParseStream(t *testing.T) {
+	data, err := reptest.Generate(reptest.Options{CmdsPerPlayer: 100, ChatRate: 0.05, MapData: true})
+	if err != nil {
+		t.Fatalf("Failed to generate replay: %v", err)
+	}
+	r, err := repparser.ParseConfig(data, repparser.Config{Commands: true, MapData: true})
+	if err != nil {
+		t.Fatalf("Failed to parse replay: %v", err)
+	}
+	r.Compute()
+
+	// The stream must match the events of the fully parsed and computed replay
+	var exp [][]byte
+	if err := repproto.StreamEvents(r, 1000, func(event []byte) error {
+		exp = append(exp, event)
+		return nil
+	}); err != nil {
+		t.Fatalf("Failed to produce events: %v", err)
+	}
+
+	conn := testConn(t)
+	req := &repproto.ParseRequest{Replay: data, Commands: true, MapData: true, Computed: true, ProgressFrames: 1000}
+	stream, err := conn.NewStream(context.Background(), &grpc.StreamDesc{ServerStreams: true}, MethodParseStream)
+	if err != nil {
+		t.Fatalf("Failed to create stream: %v", err)
+	}
+	if err := stream.SendMsg(req.Marshal()); err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatalf("Failed to close send: %v", err)
+	}
+
+	var got [][]byte
+	for {
+		var event []byte
+		if err := stream.RecvMsg(&event); err != nil {
+			if err != io.EOF {
+				t.Fatalf("Failed to receive event: %v", err)
+			}
+			break
+		}
+		got = append(got, event)
+	}
+
+	if len(got) != len(exp) {
+		t.Fatalf("Expected: %d events, got: %d", len(exp), len(got))
+	}
+	for i := range exp {
+		if !bytes.Equal(got[i], exp[i]) {
+			t.Errorf("Event #%d differs", i)
+		}
+	}
+
+	// Invalid replay
+	req = &repproto.ParseRequest{Replay: []byte("not a replay")}
+	err = parseStream(req.Marshal(), func(event []byte) error { return nil })
+	if code := status.Code(err); code != codes.InvalidArgument {
+		t.Errorf("Expected: %v, got: %v", codes.InvalidArgument, err)
+	}
+}
@@ -0,0 +1,23 @@
+/*
+
+Package repindex implements an incremental index of replay libraries.
+
+An Index holds the metadata of the games of replays found in folders, keyed by game fingerprint
+(see rep.Header.GameFingerprint()), so replays of the same game saved by different players
+are indexed once. Updating the index only parses new and modified replay files.
+
+The index can be queried by player, map, matchup and date range.
+
+The index is stored in a file of JSON values: a header followed by the changes of the index
+(an indexed or removed replay file, along with its game). Saving an index appends only the changes
+made since it was loaded, the file is rewritten (compacted) when it holds a lot more changes than
+indexed files. A JSON file is used instead of an embedded database (e.g. bbolt or SQLite) to keep
+screp free of dependencies and the index easy to inspect.
+
+The trade-off is that the whole index is held in memory and Load() reads the whole file:
+an indexed game takes roughly 0.5-1.5 KB (depending on the number of players), so a library of
+100,000 replays results in an index file of about 100 MB, taking a few seconds to load.
+The index file must not be updated by multiple processes concurrently.
+
+*/
+package repindex
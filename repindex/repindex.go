@@ -0,0 +1,327 @@
+package repindex
+
+import (
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcore"
+	"github.com/icza/screp/repparser"
+)
+
+// Version is the version of the index format.
+// Indices of other versions are rebuilt.
+const Version = 2
+
+// Index is an index of replays.
+type Index struct {
+	// Version of the index format
+	Version int
+
+	// Games of the indexed replays by game fingerprint
+	Games map[string]*Game
+
+	// Files are the indexed replay files by path
+	Files map[string]*File
+
+	// store is the state of the index file
+	store store
+}
+
+// Game is the indexed metadata of a game.
+type Game struct {
+	// Fingerprint of the game (see rep.Header.GameFingerprint())
+	Fingerprint string
+
+	// Files are the replay files of the game
+	Files []string
+
+	StartTime time.Time
+
+	// Frames is the length of the game, the longest of its replays
+	Frames repcore.Frame
+
+	// Title of the game
+	Title string
+
+	// Map name (without formatting)
+	Map string
+
+	// Type name of the game
+	Type string
+
+	// Matchup of the game (see rep.Header.Matchup())
+	Matchup string
+
+	// WinnerTeam is the team of the winner, 0 if unknown
+	WinnerTeam byte
+
+	Players []*Player
+}
+
+// Player is the indexed metadata of a player of a game.
+type Player struct {
+	Name     string
+	Race     string
+	Team     byte
+	Observer bool
+
+	// APM and EAPM of the player
+	APM, EAPM int32
+
+	// Winner tells if the player's team won the game
+	Winner bool
+}
+
+// File is an indexed replay file.
+type File struct {
+	// Size and ModTime are used to detect modified files
+	Size    int64
+	ModTime time.Time
+
+	// Fingerprint of the game of the replay, empty if the replay could not be parsed
+	Fingerprint string `json:",omitempty"`
+
+	// Error is the parsing error, if the replay could not be parsed
+	Error string `json:",omitempty"`
+}
+
+// New returns a new, empty index.
+func New() *Index {
+	return &Index{Version: Version, Games: map[string]*Game{}, Files: map[string]*File{}}
+}
+
+// UpdateStats are the statistics of an index update.
+type UpdateStats struct {
+	Added, Updated, Removed, Unchanged, Failed int
+}
+
+// Update updates the index with the replays found in the given folders
+// (searching subfolders too if recursive is true).
+// Only new and modified replays are parsed, indexed files in the folders which no longer exist are removed.
+func (idx *Index) Update(folders []string, recursive bool) (*UpdateStats, error) {
+	stats := &UpdateStats{}
+	found := map[string]bool{}
+
+	for _, folder := range folders {
+		folder = filepath.Clean(folder)
+		err := filepath.WalkDir(folder, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if path != folder && !recursive {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !strings.EqualFold(filepath.Ext(path), ".rep") {
+				return nil
+			}
+			fi, err := d.Info()
+			if err != nil {
+				return err
+			}
+			found[path] = true
+
+			old := idx.Files[path]
+			if old != nil && old.Size == fi.Size() && old.ModTime.Equal(fi.ModTime()) {
+				stats.Unchanged++
+				return nil
+			}
+			if old != nil {
+				idx.removeFile(path)
+				idx.logChange(path)
+				stats.Updated++
+			} else {
+				stats.Added++
+			}
+
+			f := &File{Size: fi.Size(), ModTime: fi.ModTime()}
+			if err := idx.addFile(path, f); err != nil {
+				f.Error = err.Error()
+				stats.Failed++
+			}
+			idx.Files[path] = f
+			idx.logChange(path)
+			return nil
+		})
+		if err != nil {
+			return stats, err
+		}
+
+		// Remove files of the folder which no longer exist
+		for path := range idx.Files {
+			if !found[path] && inFolder(path, folder, recursive) {
+				idx.removeFile(path)
+				idx.logChange(path)
+				stats.Removed++
+			}
+		}
+	}
+
+	return stats, nil
+}
+
+// inFolder tells if the path is in the given folder (or in its subfolders if recursive is true).
+func inFolder(path, folder string, recursive bool) bool {
+	dir := filepath.Dir(path)
+	if dir == folder {
+		return true
+	}
+	return recursive && strings.HasPrefix(dir, folder+string(filepath.Separator))
+}
+
+// addFile parses and adds a replay file to the index.
+func (idx *Index) addFile(path string, f *File) error {
+	r, err := repparser.ParseFileConfig(path, repparser.Config{Commands: true})
+	if err != nil {
+		return err
+	}
+	r.ComputeConfig(rep.ComputeConfig{EAPM: true, Winners: true})
+
+	f.Fingerprint = r.Header.GameFingerprint()
+	g := idx.Games[f.Fingerprint]
+	if g == nil {
+		g = newGame(r, f.Fingerprint)
+		idx.Games[f.Fingerprint] = g
+	} else if r.Header.Frames > g.Frames {
+		// Prefer the metadata of the longest replay (the most complete one)
+		g2 := newGame(r, f.Fingerprint)
+		g2.Files = g.Files
+		*g = *g2
+	}
+	g.Files = append(g.Files, path)
+	slices.Sort(g.Files)
+	return nil
+}
+
+// removeFile removes a replay file from the index.
+func (idx *Index) removeFile(path string) {
+	f := idx.Files[path]
+	delete(idx.Files, path)
+	if f == nil || f.Fingerprint == "" {
+		return
+	}
+	g := idx.Games[f.Fingerprint]
+	if g == nil {
+		return
+	}
+	g.Files = slices.DeleteFunc(g.Files, func(s string) bool { return s == path })
+	if len(g.Files) == 0 {
+		delete(idx.Games, f.Fingerprint)
+	}
+}
+
+// newGame creates the game metadata of a computed replay.
+func newGame(r *rep.Replay, fingerprint string) *Game {
+	h := r.Header
+	g := &Game{
+		Fingerprint: fingerprint,
+		StartTime:   h.StartTime,
+		Frames:      h.Frames,
+		Title:       h.StyledTitle().Plain,
+		Map:         h.StyledMap().Plain,
+		Type:        h.Type.Name,
+		Matchup:     h.Matchup(),
+		WinnerTeam:  r.Computed.WinnerTeam,
+	}
+	for i, p := range h.Players {
+		pd := r.Computed.PlayerDescs[i]
+		g.Players = append(g.Players, &Player{
+			Name:     p.Name,
+			Race:     p.Race.Name,
+			Team:     p.Team,
+			Observer: p.Observer,
+			APM:      pd.APM,
+			EAPM:     pd.EAPM,
+			Winner:   g.WinnerTeam != 0 && p.Team == g.WinnerTeam,
+		})
+	}
+	return g
+}
+
+// Query is a query of games. Zero value fields match all games.
+type Query struct {
+	// Player name, matched case-insensitively (observers do not match)
+	Player string
+
+	// Map name part, matched case-insensitively
+	Map string
+
+	// Matchup, e.g. "TvZ", the order of teams does not matter
+	Matchup string
+
+	// After and Before limit the start time of games (both exclusive)
+	After, Before time.Time
+
+	// Folders limit games to those having replays in the given folders
+	// (or in their subfolders if Recursive is true)
+	Folders   []string
+	Recursive bool
+}
+
+// Match tells if the game matches the query.
+func (q *Query) Match(g *Game) bool {
+	if q.Player != "" && !slices.ContainsFunc(g.Players, func(p *Player) bool {
+		return !p.Observer && strings.EqualFold(p.Name, q.Player)
+	}) {
+		return false
+	}
+	if q.Map != "" && !strings.Contains(strings.ToLower(g.Map), strings.ToLower(q.Map)) {
+		return false
+	}
+	if q.Matchup != "" && !MatchupEqual(g.Matchup, q.Matchup) {
+		return false
+	}
+	if !q.After.IsZero() && !g.StartTime.After(q.After) {
+		return false
+	}
+	if !q.Before.IsZero() && !g.StartTime.Before(q.Before) {
+		return false
+	}
+	if len(q.Folders) > 0 && !slices.ContainsFunc(g.Files, func(path string) bool {
+		return slices.ContainsFunc(q.Folders, func(folder string) bool {
+			return inFolder(path, filepath.Clean(folder), q.Recursive)
+		})
+	}) {
+		return false
+	}
+	return true
+}
+
+// Query returns the games matching the query, in start time order.
+func (idx *Index) Query(q Query) []*Game {
+	var games []*Game
+	for _, g := range idx.Games {
+		if q.Match(g) {
+			games = append(games, g)
+		}
+	}
+	slices.SortFunc(games, func(a, b *Game) int {
+		if c := a.StartTime.Compare(b.StartTime); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Fingerprint, b.Fingerprint)
+	})
+	return games
+}
+
+// MatchupEqual tells if 2 matchups are equal regardless of the order of the teams
+// and the order of races within teams, e.g. "TvZ" equals "zvt", "PTvZZ" equals "ZZvTP".
+func MatchupEqual(a, b string) bool {
+	normalize := func(m string) string {
+		teams := strings.Split(strings.ToUpper(m), "V")
+		for i, t := range teams {
+			races := []byte(t)
+			slices.Sort(races)
+			teams[i] = string(races)
+		}
+		slices.Sort(teams)
+		return strings.Join(teams, "v")
+	}
+	return normalize(a) == normalize(b)
+}
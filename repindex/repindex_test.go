@@ -0,0 +1,107 @@
+package repindex
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMatchupEqual(t *testing.T) {
+	cases := []struct {
+		a, b  string
+		equal bool
+	}{
+		{"TvZ", "TvZ", true},
+		{"TvZ", "zvt", true},
+		{"PTvZZ", "ZZvTP", true},
+		{"TvZ", "TvP", false},
+		{"TvZ", "TTvZ", false},
+	}
+
+	for _, c := range cases {
+		if got := MatchupEqual(c.a, c.b); got != c.equal {
+			t.Errorf("[%s, %s] Expected: %v, got: %v", c.a, c.b, c.equal, got)
+		}
+	}
+}
+
+func TestQuery(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2023, 1, d, 0, 0, 0, 0, time.UTC) }
+	idx := New()
+	idx.Games["a"] = &Game{Fingerprint: "a", Files: []string{filepath.Join("reps", "sub", "a.rep")}, StartTime: day(2), Map: "Fighting Spirit", Matchup: "TvZ",
+		Players: []*Player{{Name: "Flash", Race: "Terran"}, {Name: "Jaedong", Race: "Zerg"}, {Name: "Obs", Observer: true}}}
+	idx.Games["b"] = &Game{Fingerprint: "b", Files: []string{filepath.Join("reps", "b.rep")}, StartTime: day(1), Map: "Circuit Breaker", Matchup: "PvT",
+		Players: []*Player{{Name: "Bisu", Race: "Protoss"}, {Name: "Flash", Race: "Terran"}}}
+
+	cases := []struct {
+		name string
+		q    Query
+		exp  string // Fingerprints of the matching games
+	}{
+		{"all", Query{}, "ba"},
+		{"player", Query{Player: "flash"}, "ba"},
+		{"observer", Query{Player: "Obs"}, ""},
+		{"map", Query{Map: "spirit"}, "a"},
+		{"matchup", Query{Matchup: "TvP"}, "b"},
+		{"after", Query{After: day(1)}, "a"},
+		{"before", Query{Before: day(2)}, "b"},
+		{"combined", Query{Player: "Flash", Map: "Circuit"}, "b"},
+		{"folder", Query{Folders: []string{"reps/"}}, "b"},
+		{"folder recursive", Query{Folders: []string{"reps"}, Recursive: true}, "ba"},
+	}
+
+	for _, c := range cases {
+		got := ""
+		for _, g := range idx.Query(c.q) {
+			got += g.Fingerprint
+		}
+		if got != c.exp {
+			t.Errorf("[%s] Expected: %v, got: %v", c.name, c.exp, got)
+		}
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "bad.rep")
+	if err := os.WriteFile(name, []byte("not a replay, just some text of at least 30 bytes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "not-a-replay.txt"), nil, 0644)
+
+	idx := New()
+	check := func(step string, exp UpdateStats) {
+		t.Helper()
+		stats, err := idx.Update([]string{dir}, false)
+		if err != nil {
+			t.Fatalf("[%s] Unexpected error: %v", step, err)
+		}
+		if *stats != exp {
+			t.Errorf("[%s] Expected: %+v, got: %+v", step, exp, *stats)
+		}
+	}
+
+	check("first", UpdateStats{Added: 1, Failed: 1})
+	if f := idx.Files[name]; f == nil || f.Error == "" {
+		t.Errorf("Expected: %v, got: %v", "file with error", f)
+	}
+	check("second", UpdateStats{Unchanged: 1})
+
+	// Save and load
+	dbName := filepath.Join(t.TempDir(), "index.json")
+	if err := idx.Save(dbName); err != nil {
+		t.Fatalf("Failed to save index: %v", err)
+	}
+	var err error
+	if idx, err = Load(dbName); err != nil {
+		t.Fatalf("Failed to load index: %v", err)
+	}
+	check("loaded", UpdateStats{Unchanged: 1})
+
+	os.Remove(name)
+	check("removed", UpdateStats{Removed: 1})
+	if len(idx.Files) != 0 {
+		t.Errorf("Expected: %v, got: %v", 0, len(idx.Files))
+	}
+}
@@ -0,0 +1,208 @@
+// This file contains the incremental storage of the index.
+
+package repindex
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+)
+
+// compactMinRecords is the min number of records of an index file before it may be compacted.
+const compactMinRecords = 1000
+
+// header is the first value of an index file.
+type header struct {
+	Version int
+}
+
+// record is a change of the index: an indexed or removed replay file.
+type record struct {
+	// Path of the replay file
+	Path string
+
+	// File is the indexed file, nil if the file is removed from the index
+	File *File `json:",omitempty"`
+
+	// Game is the game of the file after the change, nil if the file could not be parsed
+	Game *Game `json:",omitempty"`
+}
+
+// store holds the state of the index file an Index was loaded from or saved to.
+type store struct {
+	// name of the index file
+	name string
+
+	// size of the index file, -1 if it has to be rewritten
+	size int64
+
+	// records is the number of records in the index file
+	records int
+
+	// pending are the encoded records not yet written to the index file
+	pending [][]byte
+}
+
+// logChange records the current state of the given replay file of the index
+// to be written by the next Save().
+func (idx *Index) logChange(path string) {
+	rec := &record{Path: path, File: idx.Files[path]}
+	if rec.File != nil && rec.File.Fingerprint != "" {
+		rec.Game = idx.Games[rec.File.Fingerprint]
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		// Can't happen, only encodable values are recorded. Force a rewrite to be safe.
+		idx.store.size = -1
+		return
+	}
+	idx.store.pending = append(idx.store.pending, append(data, '\n'))
+}
+
+// apply applies a record to the index.
+func (idx *Index) apply(rec *record) {
+	if rec.File == nil {
+		idx.removeFile(rec.Path)
+		return
+	}
+	idx.Files[rec.Path] = rec.File
+	if rec.Game != nil {
+		idx.Games[rec.Game.Fingerprint] = rec.Game
+	}
+}
+
+// Load loads an index from a file.
+// A new, empty index is returned if the file does not exist or it holds an index of another version.
+//
+// The index file consists of a header and the changes of the index, written by Save().
+// A truncated last change (e.g. due to an interrupted Save()) is dropped.
+func Load(name string) (*Index, error) {
+	idx := New()
+	idx.store = store{name: name}
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			idx.store.size = -1
+			return idx, nil
+		}
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	var h header
+	if err := dec.Decode(&h); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("invalid index header: %w", err)
+	}
+	if h.Version != Version { // Also the case for an empty file
+		idx.store.size = -1
+		return idx, nil
+	}
+
+	idx.store.size = int64(len(data))
+	for {
+		rec := &record{}
+		if err := dec.Decode(rec); err != nil {
+			if err == io.EOF {
+				break
+			}
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				idx.store.size = -1 // Drop the truncated record by rewriting the file
+				break
+			}
+			return nil, fmt.Errorf("invalid index record: %w", err)
+		}
+		idx.apply(rec)
+		idx.store.records++
+	}
+
+	return idx, nil
+}
+
+// Save saves the index to a file.
+//
+// If the index was loaded from or last saved to the same file and the file was not modified since,
+// only the changes made by Update() are appended to it. Else, or if the file holds a lot more changes
+// than indexed files, the file is rewritten holding the current state of the index.
+// Changes made to Games and Files directly are only saved when the file is rewritten.
+func (idx *Index) Save(name string) error {
+	st := &idx.store
+	if st.name == name && st.size >= 0 && st.records+len(st.pending) <= max(compactMinRecords, 2*len(idx.Files)) {
+		if fi, err := os.Stat(name); err == nil && fi.Size() == st.size {
+			return idx.appendPending()
+		}
+	}
+	return idx.rewrite(name)
+}
+
+// appendPending appends the pending records to the index file.
+func (idx *Index) appendPending() error {
+	st := &idx.store
+	if len(st.pending) == 0 {
+		return nil
+	}
+
+	f, err := os.OpenFile(st.name, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	data := bytes.Join(st.pending, nil)
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		st.size = -1 // Partial write, rewrite next time
+		return err
+	}
+	if err := f.Close(); err != nil {
+		st.size = -1
+		return err
+	}
+
+	st.size += int64(len(data))
+	st.records += len(st.pending)
+	st.pending = nil
+	return nil
+}
+
+// rewrite writes the current state of the index to the given file.
+// A temporary file is written first which is then renamed, so the file is replaced atomically.
+func (idx *Index) rewrite(name string) error {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	if err := enc.Encode(header{Version: Version}); err != nil {
+		return err
+	}
+
+	// Each game is written once, with its first file.
+	paths := make([]string, 0, len(idx.Files))
+	for path := range idx.Files {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+	written := map[string]bool{}
+	for _, path := range paths {
+		rec := &record{Path: path, File: idx.Files[path]}
+		if fp := rec.File.Fingerprint; fp != "" && !written[fp] {
+			rec.Game, written[fp] = idx.Games[fp], true
+		}
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+
+	tmpName := name + ".tmp"
+	if err := os.WriteFile(tmpName, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpName, name); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+
+	idx.store = store{name: name, size: int64(buf.Len()), records: len(paths)}
+	return nil
+}
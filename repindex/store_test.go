@@ -0,0 +1,80 @@
+package repindex
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "index.json")
+	load := func(step string, exp *Index) *Index { // exp is nil if not to be checked
+		t.Helper()
+		idx, err := Load(name)
+		if err != nil {
+			t.Fatalf("[%s] Failed to load index: %v", step, err)
+		}
+		if exp != nil && (!reflect.DeepEqual(idx.Games, exp.Games) || !reflect.DeepEqual(idx.Files, exp.Files)) {
+			t.Errorf("[%s] Expected: %v %v, got: %v %v", step, exp.Games, exp.Files, idx.Games, idx.Files)
+		}
+		return idx
+	}
+	save := func(step string, idx *Index) []byte {
+		t.Helper()
+		if err := idx.Save(name); err != nil {
+			t.Fatalf("[%s] Failed to save index: %v", step, err)
+		}
+		data, _ := os.ReadFile(name)
+		return data
+	}
+
+	idx := load("not exists", New())
+	idx.Games["g"] = &Game{Fingerprint: "g", Files: []string{"a.rep"}, Map: "Fighting Spirit"}
+	idx.Files["a.rep"] = &File{Size: 1, Fingerprint: "g"}
+	idx.logChange("a.rep")
+	idx.Files["bad.rep"] = &File{Size: 2, Error: "not a replay"}
+	idx.logChange("bad.rep")
+	data1 := save("first", idx)
+	idx = load("first", idx)
+
+	// Changes are appended
+	idx.Games["g"].Files = append(idx.Games["g"].Files, "b.rep")
+	idx.Files["b.rep"] = &File{Size: 3, Fingerprint: "g"}
+	idx.logChange("b.rep")
+	idx.removeFile("a.rep")
+	idx.logChange("a.rep")
+	data2 := save("append", idx)
+	if !bytes.HasPrefix(data2, data1) || len(data2) == len(data1) {
+		t.Errorf("Expected appended changes")
+	}
+	idx = load("append", idx)
+	if got := idx.store.records; got != 4 {
+		t.Errorf("Expected: %v records, got: %v", 4, got)
+	}
+
+	// Truncated last record is dropped, and the file is rewritten on save
+	os.WriteFile(name, data2[:len(data2)-5], 0644)
+	truncated := load("truncated", nil) // Removal of a.rep is lost
+	if len(truncated.Files) != 3 || truncated.store.size != -1 {
+		t.Errorf("Expected: 3 files and rewrite, got: %v %v", len(truncated.Files), truncated.store.size)
+	}
+	os.WriteFile(name, data2, 0644)
+
+	// Compaction
+	idx.store.records = compactMinRecords
+	idx.logChange("bad.rep")
+	data3 := save("compacted", idx)
+	if len(data3) >= len(data2) {
+		t.Errorf("Expected compacted file, got size: %v (was: %v)", len(data3), len(data2))
+	}
+	idx = load("compacted", idx)
+	if got := idx.store.records; got != 2 {
+		t.Errorf("Expected: %v records, got: %v", 2, got)
+	}
+
+	// Old versions are rebuilt
+	os.WriteFile(name, []byte(`{"Version":1,"Games":{},"Files":{}}`), 0644)
+	load("old version", New())
+}
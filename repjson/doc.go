@@ -0,0 +1,16 @@
+/*
+
+Package repjson implements JSON encoding of replays with configurable conventions.
+
+With the zero value of Options, the output is the same as the output of encoding/json.
+Options can change how frames and enumerations are encoded, include the Raw* and Debug
+fields (which are excluded by their struct tags), add the tile coordinates of points,
+and use lowerCamelCase keys, so consumers having different conventions don't have to
+post-process the output.
+
+Values are encoded following the rules of encoding/json (struct tags, omitempty,
+embedded structs, json.Marshaler implementations). Frame and enumeration options
+are not applied to the output of custom marshalers, lowerCamelCase keys are.
+
+*/
+package repjson
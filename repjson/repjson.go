@@ -0,0 +1,555 @@
+package repjson
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/icza/screp/rep/repcore"
+)
+
+// FrameFormat tells how frames (repcore.Frame values) are encoded.
+type FrameFormat int
+
+// Frame formats
+const (
+	// FramesAsFrames encodes frames as the number of frames (the default).
+	FramesAsFrames FrameFormat = iota
+
+	// FramesAsSeconds encodes frames as seconds (a number), e.g. 192.36
+	FramesAsSeconds
+
+	// FramesAsClock encodes frames as a "mm:ss" (or "h:mm:ss") string, see repcore.Frame.String().
+	FramesAsClock
+)
+
+// EnumFormat tells how enumerations (types embedding repcore.Enum having an ID, e.g. repcore.Race) are encoded.
+type EnumFormat int
+
+// Enum formats
+const (
+	// EnumsAsStructs encodes enumerations as objects of all their fields (the default).
+	EnumsAsStructs EnumFormat = iota
+
+	// EnumsAsIDName encodes enumerations as objects of their ID and Name only.
+	EnumsAsIDName
+
+	// EnumsAsID encodes enumerations as their IDs.
+	EnumsAsID
+)
+
+// Options are the JSON encoding options. The zero value is the encoding of encoding/json.
+type Options struct {
+	Frames FrameFormat
+	Enums  EnumFormat
+
+	// Raw tells to include the Raw* and Debug fields (e.g. rep.Header.RawTitle, rep.Header.Debug).
+	Raw bool
+
+	// CamelCase tells to use lowerCamelCase keys, e.g. "playerID" instead of "PlayerID".
+	CamelCase bool
+
+	// TileCoords tells to add the tile coordinates of points (repcore.Point values, also embedded ones)
+	// as TileX and TileY next to their X and Y pixel coordinates, see repcore.Point.TileCoords().
+	TileCoords bool
+}
+
+var (
+	frameType         = reflect.TypeFor[repcore.Frame]()
+	pointType         = reflect.TypeFor[repcore.Point]()
+	enumType          = reflect.TypeFor[repcore.Enum]()
+	marshalerType     = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+	numberType        = reflect.TypeFor[json.Number]()
+)
+
+// Marshal returns the JSON encoding of v using the given options.
+func Marshal(v any, opts Options) ([]byte, error) {
+	e := &encodeState{opts: opts}
+	if err := e.value(reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return e.buf.Bytes(), nil
+}
+
+// Encoder writes JSON values to an output stream, like json.Encoder.
+type Encoder struct {
+	w              io.Writer
+	opts           Options
+	prefix, indent string
+}
+
+// NewEncoder returns a new encoder that writes to w using the given options.
+func NewEncoder(w io.Writer, opts Options) *Encoder {
+	return &Encoder{w: w, opts: opts}
+}
+
+// SetIndent instructs the encoder to format each subsequent encoded value
+// as if indented by json.Indent.
+func (enc *Encoder) SetIndent(prefix, indent string) {
+	enc.prefix, enc.indent = prefix, indent
+}
+
+// Encode writes the JSON encoding of v to the stream, followed by a newline character.
+func (enc *Encoder) Encode(v any) error {
+	data, err := Marshal(v, enc.opts)
+	if err != nil {
+		return err
+	}
+	if enc.prefix != "" || enc.indent != "" {
+		buf := &bytes.Buffer{}
+		if err := json.Indent(buf, data, enc.prefix, enc.indent); err != nil {
+			return err
+		}
+		data = buf.Bytes()
+	}
+	_, err = enc.w.Write(append(data, '\n'))
+	return err
+}
+
+// encodeState holds the state of an encoding.
+type encodeState struct {
+	opts Options
+	buf  bytes.Buffer
+}
+
+// value encodes a value.
+func (e *encodeState) value(v reflect.Value) error {
+	if !v.IsValid() {
+		e.buf.WriteString("null")
+		return nil
+	}
+	t := v.Type()
+
+	if t == frameType && e.opts.Frames != FramesAsFrames {
+		f := repcore.Frame(v.Int())
+		if e.opts.Frames == FramesAsSeconds {
+			return e.leaf(f.Seconds())
+		}
+		return e.leaf(f.String())
+	}
+
+	if (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+		e.buf.WriteString("null")
+		return nil
+	}
+
+	if e.opts.Enums != EnumsAsStructs && isEnum(t) {
+		return e.enum(reflect.Indirect(v))
+	}
+
+	if t.Implements(marshalerType) || v.CanAddr() && reflect.PointerTo(t).Implements(marshalerType) {
+		if !t.Implements(marshalerType) {
+			v = v.Addr()
+		}
+		data, err := v.Interface().(json.Marshaler).MarshalJSON()
+		if err != nil {
+			return err
+		}
+		if e.opts.CamelCase && bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+			if data, err = camelKeys(data); err != nil {
+				return err
+			}
+		}
+		buf := &bytes.Buffer{}
+		if err := json.Compact(buf, data); err != nil {
+			return err
+		}
+		e.buf.Write(buf.Bytes())
+		return nil
+	}
+	if t.Implements(textMarshalerType) || v.CanAddr() && reflect.PointerTo(t).Implements(textMarshalerType) {
+		if !t.Implements(textMarshalerType) {
+			v = v.Addr()
+		}
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return err
+		}
+		return e.leaf(string(text))
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return e.value(v.Elem())
+	case reflect.Struct:
+		return e.structValue(v)
+	case reflect.Map:
+		return e.mapValue(v)
+	case reflect.Slice:
+		if v.IsNil() {
+			e.buf.WriteString("null")
+			return nil
+		}
+		if t.Elem().Kind() == reflect.Uint8 {
+			return e.leaf(v.Bytes()) // base64
+		}
+		fallthrough
+	case reflect.Array:
+		e.buf.WriteByte('[')
+		for i := range v.Len() {
+			if i > 0 {
+				e.buf.WriteByte(',')
+			}
+			if err := e.value(v.Index(i)); err != nil {
+				return err
+			}
+		}
+		e.buf.WriteByte(']')
+		return nil
+	case reflect.Bool:
+		return e.leaf(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return e.leaf(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return e.leaf(v.Uint())
+	case reflect.Float32:
+		return e.leaf(float32(v.Float()))
+	case reflect.Float64:
+		return e.leaf(v.Float())
+	case reflect.String:
+		if t == numberType {
+			return e.leaf(json.Number(v.String()))
+		}
+		return e.leaf(v.String())
+	}
+	return fmt.Errorf("unsupported type: %v", t)
+}
+
+// leaf encodes a non-composite value with encoding/json.
+func (e *encodeState) leaf(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	e.buf.Write(data)
+	return nil
+}
+
+// key writes an object key.
+func (e *encodeState) key(name string) {
+	if e.opts.CamelCase {
+		name = LowerCamel(name)
+	}
+	e.leaf(name)
+	e.buf.WriteByte(':')
+}
+
+// isEnum tells if t (or the type t points to) is an enumeration:
+// a struct embedding repcore.Enum and having an ID field.
+func isEnum(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	f, ok := t.FieldByName("Enum")
+	if !ok || !f.Anonymous || f.Type != enumType {
+		return false
+	}
+	_, ok = t.FieldByName("ID")
+	return ok
+}
+
+// enum encodes an enumeration according to the enum format.
+func (e *encodeState) enum(v reflect.Value) error {
+	if e.opts.Enums == EnumsAsID {
+		return e.value(v.FieldByName("ID"))
+	}
+	e.buf.WriteByte('{')
+	e.key("ID")
+	if err := e.value(v.FieldByName("ID")); err != nil {
+		return err
+	}
+	e.buf.WriteByte(',')
+	e.key("Name")
+	e.leaf(v.FieldByName("Name").String())
+	e.buf.WriteByte('}')
+	return nil
+}
+
+func (e *encodeState) structValue(v reflect.Value) error {
+	e.buf.WriteByte('{')
+	first := true
+	for _, f := range cachedFields(v.Type(), e.opts.Raw, e.opts.TileCoords) {
+		fv, ok := fieldByIndex(v, f.index)
+		if !ok || f.omitEmpty && isEmpty(fv) {
+			continue
+		}
+		if !first {
+			e.buf.WriteByte(',')
+		}
+		first = false
+		e.key(f.name)
+		if f.tile {
+			e.leaf(fv.Uint() / repcore.TileSize)
+			continue
+		}
+		if err := e.value(fv); err != nil {
+			return err
+		}
+	}
+	e.buf.WriteByte('}')
+	return nil
+}
+
+// fieldByIndex returns the field of a struct by its index sequence.
+// ok is false if the field is in a nil embedded struct pointer.
+func fieldByIndex(v reflect.Value, index []int) (fv reflect.Value, ok bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+func (e *encodeState) mapValue(v reflect.Value) error {
+	if v.IsNil() {
+		e.buf.WriteString("null")
+		return nil
+	}
+
+	type entry struct {
+		key string
+		v   reflect.Value
+	}
+	entries := make([]entry, 0, v.Len())
+	for iter := v.MapRange(); iter.Next(); {
+		k := iter.Key()
+		var key string
+		switch {
+		case k.Kind() == reflect.String:
+			key = k.String()
+		case k.Type().Implements(textMarshalerType):
+			text, err := k.Interface().(encoding.TextMarshaler).MarshalText()
+			if err != nil {
+				return err
+			}
+			key = string(text)
+		case k.CanInt():
+			key = strconv.FormatInt(k.Int(), 10)
+		case k.CanUint():
+			key = strconv.FormatUint(k.Uint(), 10)
+		default:
+			return fmt.Errorf("unsupported map key type: %v", k.Type())
+		}
+		entries = append(entries, entry{key, iter.Value()})
+	}
+	slices.SortFunc(entries, func(a, b entry) int { return strings.Compare(a.key, b.key) })
+
+	e.buf.WriteByte('{')
+	for i, en := range entries {
+		if i > 0 {
+			e.buf.WriteByte(',')
+		}
+		e.leaf(en.key) // Map keys are data, they are not converted to camel case
+		e.buf.WriteByte(':')
+		if err := e.value(en.v); err != nil {
+			return err
+		}
+	}
+	e.buf.WriteByte('}')
+	return nil
+}
+
+// isEmpty tells if the value is empty as defined by the omitempty option of encoding/json.
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	}
+	return false
+}
+
+// field is an encoded field of a struct.
+type field struct {
+	name      string
+	index     []int
+	omitEmpty bool
+	tagged    bool
+	depth     int
+
+	// tile tells if the field is a pixel coordinate of a point to be encoded as a tile coordinate
+	tile bool
+}
+
+// fieldsKey is the key of the cache of struct fields.
+type fieldsKey struct {
+	t     reflect.Type
+	raw   bool
+	tiles bool
+}
+
+var fieldsCache sync.Map // map[fieldsKey][]*field
+
+// cachedFields returns the encoded fields of a struct type, see typeFields.
+func cachedFields(t reflect.Type, raw, tiles bool) []*field {
+	key := fieldsKey{t, raw, tiles}
+	if fs, ok := fieldsCache.Load(key); ok {
+		return fs.([]*field)
+	}
+	fs, _ := fieldsCache.LoadOrStore(key, typeFields(t, raw, tiles))
+	return fs.([]*field)
+}
+
+// typeFields returns the encoded fields of a struct type following the rules of encoding/json:
+// fields of embedded structs are promoted, on name conflicts the shallowest field wins
+// (or the tagged one if there are multiple at the same depth; if none, the name is dropped).
+// If raw is true, the Raw* and Debug fields are also included (despite their "-" tag).
+// If tiles is true, the TileX and TileY fields are added to points (see Options.TileCoords).
+func typeFields(t reflect.Type, raw, tiles bool) []*field {
+	var all []*field
+	var walk func(t reflect.Type, index []int)
+	walk = func(t reflect.Type, index []int) {
+		for i := range t.NumField() {
+			sf := t.Field(i)
+			idx := append(slices.Clone(index), i)
+			tag := sf.Tag.Get("json")
+			if tag == "-" {
+				if raw && sf.IsExported() && (strings.HasPrefix(sf.Name, "Raw") || sf.Name == "Debug") {
+					all = append(all, &field{name: sf.Name, index: idx, depth: len(index)})
+				}
+				continue
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+			ft := sf.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if sf.Anonymous {
+				if name == "" && ft.Kind() == reflect.Struct {
+					walk(ft, idx)
+					continue
+				}
+				if !sf.IsExported() {
+					continue
+				}
+			} else if !sf.IsExported() {
+				continue
+			}
+			f := &field{name: name, index: idx, tagged: name != "", depth: len(index)}
+			if name == "" {
+				f.name = sf.Name
+			}
+			f.omitEmpty = strings.Contains(","+opts+",", ",omitempty,")
+			all = append(all, f)
+		}
+		if tiles && t == pointType {
+			for _, name := range []string{"X", "Y"} {
+				sf, _ := t.FieldByName(name)
+				idx := append(slices.Clone(index), sf.Index...)
+				all = append(all, &field{name: "Tile" + name, index: idx, depth: len(index), tile: true})
+			}
+		}
+	}
+	walk(t, nil)
+
+	// Resolve name conflicts
+	byName := map[string][]*field{}
+	for _, f := range all {
+		byName[f.name] = append(byName[f.name], f)
+	}
+	var fields []*field
+	for _, f := range all {
+		dominant := true
+		for _, g := range byName[f.name] {
+			if g == f {
+				continue
+			}
+			if g.depth < f.depth || g.depth == f.depth && (!f.tagged || g.tagged) {
+				dominant = false
+				break
+			}
+		}
+		if dominant {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// LowerCamel converts a field name to lowerCamelCase:
+// the leading upper case run is lowered, except its last letter if it starts a new word,
+// e.g. "PlayerID" => "playerID", "CHKHash" => "chkHash", "EAPM" => "eapm".
+func LowerCamel(s string) string {
+	r := []rune(s)
+	n := 0
+	for n < len(r) && unicode.IsUpper(r[n]) {
+		n++
+	}
+	if n > 1 && n < len(r) && unicode.IsLower(r[n]) && string(r[n:]) != "s" {
+		n-- // The last upper case letter starts the next word (plural acronyms like "WAVs" excluded)
+	}
+	for i := range n {
+		r[i] = unicode.ToLower(r[i])
+	}
+	return string(r)
+}
+
+// camelKeys converts the keys of the objects in the JSON data to lowerCamelCase.
+func camelKeys(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	buf := &bytes.Buffer{}
+	var convert func() error
+	convert = func() error {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		delim, ok := tok.(json.Delim)
+		if !ok {
+			data, err := json.Marshal(tok)
+			buf.Write(data)
+			return err
+		}
+		buf.WriteRune(rune(delim))
+		for i := 0; dec.More(); i++ {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if delim == '{' {
+				key, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				data, _ := json.Marshal(LowerCamel(key.(string)))
+				buf.Write(data)
+				buf.WriteByte(':')
+			}
+			if err := convert(); err != nil {
+				return err
+			}
+		}
+		end, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		buf.WriteRune(rune(end.(json.Delim)))
+		return nil
+	}
+	if err := convert(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
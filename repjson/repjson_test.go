@@ -0,0 +1,139 @@
+package repjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcmd"
+	"github.com/icza/screp/rep/repcore"
+)
+
+// testReplay returns a replay having most kinds of fields.
+func testReplay() *rep.Replay {
+	repSaver := byte(1)
+	return &rep.Replay{
+		Header: &rep.Header{
+			Engine:    repcore.EngineBroodWar,
+			Frames:    repcore.Duration2Frame(3*time.Minute + 12*time.Second),
+			StartTime: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+			Title:     "<Title>",
+			RawTitle:  "<Title>",
+			Type:      repcore.GameTypeMelee,
+			Players: []*rep.Player{
+				{SlotID: 1, ID: 1, Race: repcore.RaceZerg, Name: "Jaedong", Color: repcore.ColorByID(1)},
+			},
+		},
+		Commands: &rep.Commands{Cmds: []repcmd.Cmd{
+			&repcmd.ChatCmd{Base: &repcmd.Base{Frame: 10, Type: repcmd.TypeChat}, Message: "gl hf"},
+			&repcmd.GeneralCmd{Base: &repcmd.Base{Frame: 20, Type: repcmd.TypeByID(0x99), IneffKind: repcore.IneffKindFastCancel}, Data: []byte{1, 2}},
+		}},
+		MapData: &rep.MapData{
+			TileSet: repcore.TileSetJungle,
+			FogMask: repcmd.Bytes{1, 2, 3},
+			Geysers: []rep.Resource{{Point: repcore.Point{X: 1, Y: 2}, Amount: 5000}},
+		},
+		Computed: &rep.Computed{RepSaverPlayerID: &repSaver, PlayerDescs: []*rep.PlayerDesc{{PlayerID: 1, LastCmdFrame: 100}}},
+	}
+}
+
+func TestMarshalDefault(t *testing.T) {
+	v := struct {
+		File string `json:",omitempty"`
+		*rep.Replay
+		Custom map[string]any `json:",omitempty"`
+		Empty  []int
+	}{Replay: testReplay(), Custom: map[string]any{"b": 1.5, "a": []byte{1}, "c": json.Number("12")}}
+
+	exp, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	got, err := Marshal(v, Options{})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !bytes.Equal(exp, got) {
+		t.Errorf("Expected: %s, got: %s", exp, got)
+	}
+}
+
+func TestMarshalOptions(t *testing.T) {
+	r := testReplay()
+
+	cases := []struct {
+		name string
+		v    any
+		opts Options
+		exp  string
+	}{
+		{"frames as seconds", r.Computed.PlayerDescs[0], Options{Frames: FramesAsSeconds},
+			`{"PlayerID":1,"LastCmdFrame":4.2,"CmdCount":0,"APM":0,"EffectiveCmdCount":0,"EAPM":0,"StartLocation":null,"StartDirection":0}`},
+		{"frames as clock", r.Computed.PlayerDescs[0], Options{Frames: FramesAsClock},
+			`{"PlayerID":1,"LastCmdFrame":"00:04","CmdCount":0,"APM":0,"EffectiveCmdCount":0,"EAPM":0,"StartLocation":null,"StartDirection":0}`},
+		{"enums as id and name", r.Header.Players[0], Options{Enums: EnumsAsIDName},
+			`{"SlotID":1,"ID":1,"Type":null,"Race":{"ID":0,"Name":"Zerg"},"Team":0,"Name":"Jaedong","Color":{"ID":1,"Name":"Blue"},"Observer":false}`},
+		{"enums as id", r.Header.Players[0], Options{Enums: EnumsAsID},
+			`{"SlotID":1,"ID":1,"Type":null,"Race":0,"Team":0,"Name":"Jaedong","Color":1,"Observer":false}`},
+		{"raw", r.Header.Players[0], Options{Raw: true, Enums: EnumsAsID},
+			`{"SlotID":1,"ID":1,"Type":null,"Race":0,"Team":0,"Name":"Jaedong","RawName":"","Color":1,"Observer":false}`},
+		{"camel case", r.Header.Players[0], Options{CamelCase: true, Enums: EnumsAsIDName},
+			`{"slotID":1,"id":1,"type":null,"race":{"id":0,"name":"Zerg"},"team":0,"name":"Jaedong","color":{"id":1,"name":"Blue"},"observer":false}`},
+		{"camel case custom marshaler", struct{ Custom json.RawMessage }{json.RawMessage(`{"MapDataHash":"x","A":[{"SlotID":1}]}`)}, Options{CamelCase: true},
+			`{"custom":{"mapDataHash":"x","a":[{"slotID":1}]}}`},
+		{"command", r.Commands.Cmds[1], Options{Frames: FramesAsSeconds, Enums: EnumsAsID, CamelCase: true},
+			`{"frame":0.84,"playerID":0,"type":153,"ineffKind":2,"data":"AQI="}`},
+		{"tile coords", repcore.Point{X: 100, Y: 31}, Options{TileCoords: true},
+			`{"X":100,"Y":31,"TileX":3,"TileY":0}`},
+		{"tile coords embedded", r.MapData.Geysers[0], Options{TileCoords: true, CamelCase: true},
+			`{"x":1,"y":2,"tileX":0,"tileY":0,"amount":5000}`},
+		{"tile coords pointer", struct{ Pos *repcore.Point }{&repcore.Point{X: 64, Y: 96}}, Options{TileCoords: true},
+			`{"Pos":{"X":64,"Y":96,"TileX":2,"TileY":3}}`},
+	}
+
+	for _, c := range cases {
+		got, err := Marshal(c.v, c.opts)
+		if err != nil {
+			t.Errorf("[%s] Expected no error, got: %v", c.name, err)
+			continue
+		}
+		if string(got) != c.exp {
+			t.Errorf("[%s] Expected: %s, got: %s", c.name, c.exp, got)
+		}
+	}
+}
+
+func TestLowerCamel(t *testing.T) {
+	cases := []struct {
+		name, exp string
+	}{
+		{"PlayerID", "playerID"},
+		{"ID", "id"},
+		{"EAPM", "eapm"},
+		{"CHKHash", "chkHash"},
+		{"WAVs", "wavs"},
+		{"X", "x"},
+		{"tileSetMissing", "tileSetMissing"},
+		{"", ""},
+	}
+
+	for _, c := range cases {
+		if got := LowerCamel(c.name); got != c.exp {
+			t.Errorf("[%s] Expected: %v, got: %v", c.name, c.exp, got)
+		}
+	}
+}
+
+func TestEncoder(t *testing.T) {
+	buf := &bytes.Buffer{}
+	enc := NewEncoder(buf, Options{CamelCase: true})
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(repcore.Point{X: 1, Y: 2}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if exp := "{\n  \"x\": 1,\n  \"y\": 2\n}\n"; buf.String() != exp {
+		t.Errorf("Expected: %q, got: %q", exp, buf.String())
+	}
+}
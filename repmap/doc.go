@@ -0,0 +1,16 @@
+/*
+
+Package repmap implements rendering and analysis of the maps of StarCraft: Brood War replays.
+
+The package works on the parsed map data (rep.MapData), so replays must be parsed
+with map data included (repparser.Config.MapData).
+
+Terrain analysis (walkability and buildability grids, see ComputeGrids(), and the
+repterrain package) requires the CV5 and VF4 tileset files of the game. These are game
+assets that are not redistributable, so they are not part of the repository: they must be
+embedded at build time (see the tilesets folder) or loaded at runtime with LoadTileSetDir().
+Without them terrain analysis returns an error wrapping ErrNoTileSetData.
+Rendering (Minimap(), WriteSVG()) works without tileset data.
+
+*/
+package repmap
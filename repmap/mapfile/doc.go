@@ -0,0 +1,17 @@
+/*
+
+Package mapfile implements reading and writing StarCraft: Brood War map files (*.scm, *.scx),
+and verifying them against the map data embedded in replays.
+
+Map files are MPQ archives holding the scenario data (CHK) in the "staredit\scm\scenario.chk" file.
+
+Information sources:
+
+MPQ format:
+
+http://www.zezula.net/en/mpq/mpqformat.html
+
+https://github.com/ladislav-zezula/StormLib
+
+*/
+package mapfile
@@ -0,0 +1,281 @@
+// This file contains the MPQ archive reading.
+
+package mapfile
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/icza/screp/repparser/repdecoder"
+)
+
+var (
+	// ErrNotMapFile is returned if the data is not an MPQ archive.
+	ErrNotMapFile = errors.New("not a map file")
+
+	// ErrNoScenario is returned if the map file does not contain the scenario data.
+	ErrNoScenario = errors.New("no scenario data in map file")
+
+	// ErrUnsupportedCompression is returned if the scenario data is compressed
+	// with an unsupported compression method.
+	ErrUnsupportedCompression = errors.New("unsupported compression")
+)
+
+// ScenarioFileName is the name of the scenario data (CHK) file in map files.
+const ScenarioFileName = `staredit\scm\scenario.chk`
+
+const (
+	mpqHeaderSize = 32
+	mpqEntrySize  = 16 // Size of hash table and block table entries
+)
+
+// Block flags
+const (
+	blockFlagImplode    = 0x00000100
+	blockFlagCompress   = 0x00000200
+	blockFlagEncrypted  = 0x00010000
+	blockFlagFixKey     = 0x00020000
+	blockFlagSingleUnit = 0x01000000
+	blockFlagExists     = 0x80000000
+)
+
+// Compression masks (first byte of compressed sectors having blockFlagCompress)
+const (
+	compressionZlib   = 0x02
+	compressionPKWare = 0x08
+)
+
+// Hash types of hashString()
+const (
+	hashTypeTableOffset = 0
+	hashTypeNameA       = 1
+	hashTypeNameB       = 2
+	hashTypeFileKey     = 3
+)
+
+// cryptTable is the table used by MPQ hashing and encryption.
+var cryptTable [0x500]uint32
+
+func init() {
+	seed := uint32(0x00100001)
+	for i := 0; i < 0x100; i++ {
+		for j := i; j < 0x500; j += 0x100 {
+			seed = (seed*125 + 3) % 0x2AAAAB
+			hi := (seed & 0xFFFF) << 16
+			seed = (seed*125 + 3) % 0x2AAAAB
+			cryptTable[j] = hi | seed&0xFFFF
+		}
+	}
+}
+
+// hashString hashes the given string using the given hash type.
+// File names are case-insensitive and '/' is treated as '\'.
+func hashString(s string, hashType uint32) uint32 {
+	seed1, seed2 := uint32(0x7FED7FED), uint32(0xEEEEEEEE)
+	for _, ch := range []byte(strings.ToUpper(strings.ReplaceAll(s, "/", `\`))) {
+		seed1 = cryptTable[hashType<<8+uint32(ch)] ^ (seed1 + seed2)
+		seed2 = uint32(ch) + seed1 + seed2 + seed2<<5 + 3
+	}
+	return seed1
+}
+
+// decrypt decrypts the given data in place using the given key.
+// Trailing bytes not forming a whole uint32 are left untouched.
+func decrypt(data []byte, key uint32) {
+	seed := uint32(0xEEEEEEEE)
+	for i := 0; i+4 <= len(data); i += 4 {
+		seed += cryptTable[0x400+key&0xFF]
+		v := binary.LittleEndian.Uint32(data[i:]) ^ (key + seed)
+		key = (^key<<21 + 0x11111111) | key>>11
+		seed = v + seed + seed<<5 + 3
+		binary.LittleEndian.PutUint32(data[i:], v)
+	}
+}
+
+// encrypt encrypts the given data in place using the given key.
+// Trailing bytes not forming a whole uint32 are left untouched.
+func encrypt(data []byte, key uint32) {
+	seed := uint32(0xEEEEEEEE)
+	for i := 0; i+4 <= len(data); i += 4 {
+		seed += cryptTable[0x400+key&0xFF]
+		v := binary.LittleEndian.Uint32(data[i:])
+		binary.LittleEndian.PutUint32(data[i:], v^(key+seed))
+		key = (^key<<21 + 0x11111111) | key>>11
+		seed = v + seed + seed<<5 + 3
+	}
+}
+
+// ReadCHKFile reads the scenario data (CHK) from the given map file.
+func ReadCHKFile(name string) ([]byte, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return ReadCHK(data)
+}
+
+// ReadCHK reads the scenario data (CHK) from the given map file content.
+//
+// Common map protection tricks (bogus archive and table sizes, encrypted files) are tolerated.
+// Supported compression methods are PKWARE DCL (implode) and zlib.
+func ReadCHK(mapData []byte) (chk []byte, err error) {
+	// Input is untrusted data, protect against panics caused by malformed archives:
+	defer func() {
+		if r := recover(); r != nil {
+			chk, err = nil, fmt.Errorf("%w: malformed archive: %v", ErrNotMapFile, r)
+		}
+	}()
+
+	// The archive header is at a 512-byte boundary (there may be data before it):
+	start := -1
+	for pos := 0; pos+mpqHeaderSize <= len(mapData); pos += 512 {
+		if string(mapData[pos:pos+4]) == "MPQ\x1a" {
+			start = pos
+			break
+		}
+	}
+	if start < 0 {
+		return nil, ErrNotMapFile
+	}
+	archive := mapData[start:] // Archive size in header is unreliable (protection), ignore it
+
+	le := binary.LittleEndian
+	sectorSize := 512 << le.Uint16(archive[0x0e:])
+	hashTablePos, blockTablePos := le.Uint32(archive[0x10:]), le.Uint32(archive[0x14:])
+	hashTableSize, blockTableSize := le.Uint32(archive[0x18:]), le.Uint32(archive[0x1c:])
+
+	table := func(pos, count uint32, key string) []byte {
+		if uint64(pos) >= uint64(len(archive)) {
+			return nil
+		}
+		size := min(uint64(count)*mpqEntrySize, uint64(len(archive))-uint64(pos))
+		t := make([]byte, size-size%mpqEntrySize)
+		copy(t, archive[pos:])
+		decrypt(t, hashString(key, hashTypeFileKey))
+		return t
+	}
+	hashTable := table(hashTablePos, hashTableSize, "(hash table)")
+	blockTable := table(blockTablePos, blockTableSize, "(block table)")
+
+	// Look up the scenario file in the hash table:
+	entries := uint32(len(hashTable) / mpqEntrySize)
+	if entries == 0 {
+		return nil, ErrNoScenario
+	}
+	nameA, nameB := hashString(ScenarioFileName, hashTypeNameA), hashString(ScenarioFileName, hashTypeNameB)
+	blockIdx := uint32(0xFFFFFFFF)
+	hashStart := hashString(ScenarioFileName, hashTypeTableOffset) & (hashTableSize - 1) % entries
+	for i, idx := uint32(0), hashStart; i < entries; i, idx = i+1, (idx+1)%entries {
+		entry := hashTable[idx*mpqEntrySize:]
+		bi := le.Uint32(entry[12:])
+		if bi == 0xFFFFFFFF {
+			break // Empty entry: end of search
+		}
+		if le.Uint32(entry) == nameA && le.Uint32(entry[4:]) == nameB && bi != 0xFFFFFFFE {
+			blockIdx = bi
+			break
+		}
+	}
+	if blockIdx == 0xFFFFFFFF || uint64(blockIdx+1)*mpqEntrySize > uint64(len(blockTable)) {
+		return nil, ErrNoScenario
+	}
+
+	block := blockTable[blockIdx*mpqEntrySize:]
+	filePos, compSize, fileSize, flags := le.Uint32(block), le.Uint32(block[4:]), le.Uint32(block[8:]), le.Uint32(block[12:])
+	if flags&blockFlagExists == 0 || uint64(filePos) >= uint64(len(archive)) {
+		return nil, ErrNoScenario
+	}
+	fileData := archive[filePos:]
+	fileData = fileData[:min(uint64(compSize), uint64(len(fileData)))]
+
+	var key uint32
+	if flags&blockFlagEncrypted != 0 {
+		key = hashString(`scenario.chk`, hashTypeFileKey) // Key is derived from the name without path
+		if flags&blockFlagFixKey != 0 {
+			key = (key + filePos) ^ fileSize
+		}
+	}
+
+	chk = make([]byte, fileSize)
+	if flags&blockFlagSingleUnit != 0 {
+		sector := append([]byte(nil), fileData...)
+		if flags&blockFlagEncrypted != 0 {
+			decrypt(sector, key)
+		}
+		if err := decompressSector(sector, chk, flags); err != nil {
+			return nil, err
+		}
+		return chk, nil
+	}
+
+	// File is split into sectors, preceded by the sector offset table:
+	sectors := (int(fileSize) + sectorSize - 1) / sectorSize
+	compressed := flags&(blockFlagImplode|blockFlagCompress) != 0
+	var offsets []uint32
+	if compressed {
+		offsetTable := append([]byte(nil), fileData[:(sectors+1)*4]...)
+		if flags&blockFlagEncrypted != 0 {
+			decrypt(offsetTable, key-1)
+		}
+		for i := 0; i <= sectors; i++ {
+			offsets = append(offsets, le.Uint32(offsetTable[i*4:]))
+		}
+	} else {
+		for i := 0; i <= sectors; i++ {
+			offsets = append(offsets, uint32(min(i*sectorSize, int(fileSize))))
+		}
+	}
+
+	for i := 0; i < sectors; i++ {
+		if offsets[i] > offsets[i+1] || int(offsets[i+1]) > len(fileData) {
+			return nil, fmt.Errorf("%w: invalid sector offsets", ErrNotMapFile)
+		}
+		sector := append([]byte(nil), fileData[offsets[i]:offsets[i+1]]...)
+		if flags&blockFlagEncrypted != 0 {
+			decrypt(sector, key+uint32(i))
+		}
+		dst := chk[i*sectorSize : min((i+1)*sectorSize, int(fileSize))]
+		if err := decompressSector(sector, dst, flags); err != nil {
+			return nil, err
+		}
+	}
+
+	return chk, nil
+}
+
+// decompressSector decompresses a sector of a file into dst.
+// The sector is stored uncompressed if its size equals to the size of dst.
+func decompressSector(sector, dst []byte, flags uint32) error {
+	if len(sector) == len(dst) || flags&(blockFlagImplode|blockFlagCompress) == 0 {
+		copy(dst, sector)
+		return nil
+	}
+
+	if flags&blockFlagImplode != 0 {
+		_, err := repdecoder.Explode(sector, dst)
+		return err
+	}
+
+	if len(sector) == 0 {
+		return ErrUnsupportedCompression
+	}
+	switch sector[0] {
+	case compressionPKWare:
+		_, err := repdecoder.Explode(sector[1:], dst)
+		return err
+	case compressionZlib:
+		zr, err := zlib.NewReader(bytes.NewReader(sector[1:]))
+		if err != nil {
+			return err
+		}
+		_, err = io.ReadFull(zr, dst)
+		return err
+	}
+	return fmt.Errorf("%w: 0x%02x", ErrUnsupportedCompression, sector[0])
+}
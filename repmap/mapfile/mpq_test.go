@@ -0,0 +1,143 @@
+package mapfile
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/icza/screp/rep"
+)
+
+func TestHashString(t *testing.T) {
+	cases := []struct {
+		s        string
+		hashType uint32
+		hash     uint32
+	}{
+		{"(hash table)", hashTypeFileKey, 0xC3AF3770},
+		{"(block table)", hashTypeFileKey, 0xEC83B3A3},
+	}
+	for _, c := range cases {
+		if got := hashString(c.s, c.hashType); got != c.hash {
+			t.Errorf("[%s] Expected: 0x%08x, got: 0x%08x", c.s, c.hash, got)
+		}
+	}
+}
+
+// buildMPQ builds an MPQ archive holding the scenario file with the given content.
+// If flags has blockFlagCompress, sectors are zlib compressed.
+func buildMPQ(t *testing.T, chk []byte, flags uint32, sectorShift uint16) []byte {
+	t.Helper()
+	le := binary.LittleEndian
+
+	key := hashString("scenario.chk", hashTypeFileKey)
+	var file []byte
+	if flags&blockFlagCompress == 0 {
+		file = append(file, chk...)
+		if flags&blockFlagEncrypted != 0 {
+			encrypt(file, key)
+		}
+	} else {
+		sectorSize := 512 << sectorShift
+		sectors := (len(chk) + sectorSize - 1) / sectorSize
+		offsetTable := make([]byte, (sectors+1)*4)
+		for i := 0; i < sectors; i++ {
+			le.PutUint32(offsetTable[i*4:], uint32(len(offsetTable)+len(file)))
+			buf := &bytes.Buffer{}
+			zw := zlib.NewWriter(buf)
+			zw.Write(chk[i*sectorSize : min((i+1)*sectorSize, len(chk))])
+			zw.Close()
+			sector := append([]byte{compressionZlib}, buf.Bytes()...)
+			if flags&blockFlagEncrypted != 0 {
+				encrypt(sector, key+uint32(i))
+			}
+			file = append(file, sector...)
+		}
+		le.PutUint32(offsetTable[sectors*4:], uint32(len(offsetTable)+len(file)))
+		if flags&blockFlagEncrypted != 0 {
+			encrypt(offsetTable, key-1)
+		}
+		file = append(offsetTable, file...)
+	}
+
+	const hashEntries = 16
+	hashTable := bytes.Repeat([]byte{0xff}, hashEntries*mpqEntrySize)
+	entry := hashTable[(hashString(ScenarioFileName, hashTypeTableOffset)&(hashEntries-1))*mpqEntrySize:]
+	le.PutUint32(entry, hashString(ScenarioFileName, hashTypeNameA))
+	le.PutUint32(entry[4:], hashString(ScenarioFileName, hashTypeNameB))
+	le.PutUint32(entry[8:], 0) // Locale and platform
+	le.PutUint32(entry[12:], 0)
+	encrypt(hashTable, hashString("(hash table)", hashTypeFileKey))
+
+	block := make([]byte, mpqEntrySize)
+	le.PutUint32(block, mpqHeaderSize)
+	le.PutUint32(block[4:], uint32(len(file)))
+	le.PutUint32(block[8:], uint32(len(chk)))
+	le.PutUint32(block[12:], flags|blockFlagExists)
+	encrypt(block, hashString("(block table)", hashTypeFileKey))
+
+	header := make([]byte, mpqHeaderSize)
+	copy(header, "MPQ\x1a")
+	le.PutUint32(header[4:], mpqHeaderSize)
+	le.PutUint32(header[8:], uint32(mpqHeaderSize+len(file)+len(hashTable)+len(block)))
+	le.PutUint16(header[0x0e:], sectorShift)
+	le.PutUint32(header[0x10:], uint32(mpqHeaderSize+len(file)))
+	le.PutUint32(header[0x14:], uint32(mpqHeaderSize+len(file)+len(hashTable)))
+	le.PutUint32(header[0x18:], hashEntries)
+	le.PutUint32(header[0x1c:], 1)
+
+	return append(append(append(header, file...), hashTable...), block...)
+}
+
+func TestReadCHK(t *testing.T) {
+	chk := make([]byte, 5000)
+	for i := range chk {
+		chk[i] = byte(i * 7)
+	}
+
+	cases := []struct {
+		name    string
+		mapData []byte
+		err     error
+	}{
+		{"single unit", buildMPQ(t, chk, blockFlagSingleUnit, 3), nil},
+		{"single unit encrypted", buildMPQ(t, chk, blockFlagSingleUnit|blockFlagEncrypted, 3), nil},
+		{"zlib sectors", buildMPQ(t, chk, blockFlagCompress, 0), nil},
+		{"zlib sectors encrypted", buildMPQ(t, chk, blockFlagCompress|blockFlagEncrypted, 1), nil},
+		{"user data before archive", append(make([]byte, 1024), buildMPQ(t, chk, blockFlagSingleUnit, 3)...), nil},
+		{"not mpq", make([]byte, 1024), ErrNotMapFile},
+		{"truncated", buildMPQ(t, chk, blockFlagCompress, 0)[:300], ErrNoScenario},
+	}
+	for _, c := range cases {
+		got, err := ReadCHK(c.mapData)
+		if !errors.Is(err, c.err) {
+			t.Errorf("[%s] Expected error: %v, got: %v", c.name, c.err, err)
+			continue
+		}
+		if err == nil && !bytes.Equal(got, chk) {
+			t.Errorf("[%s] Content mismatch", c.name)
+		}
+	}
+}
+
+func TestWriteCHK(t *testing.T) {
+	chk := buildCHK("Map", 1, 2, 3, 4)
+
+	buf := &bytes.Buffer{}
+	if err := WriteReplayMap(buf, &rep.Replay{MapData: &rep.MapData{Debug: &rep.MapDataDebug{Data: chk}}}); err != nil {
+		t.Fatalf("Failed to write map: %v", err)
+	}
+	got, err := ReadCHK(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Failed to read map: %v", err)
+	}
+	if !bytes.Equal(got, chk) {
+		t.Errorf("Content mismatch")
+	}
+
+	if err := WriteReplayMap(buf, &rep.Replay{MapData: &rep.MapData{}}); err != ErrNoRawMapData {
+		t.Errorf("Expected: %v, got: %v", ErrNoRawMapData, err)
+	}
+}
@@ -0,0 +1,111 @@
+// This file contains verifying map files against replays.
+
+package mapfile
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/repmap"
+	"github.com/icza/screp/repparser"
+)
+
+// Verification is the result of verifying a map file against the map of a replay.
+type Verification struct {
+	// CHKHashMatch tells if the scenario data of the map file is identical to the
+	// map data embedded in the replay (see rep.MapData.CHKHash).
+	CHKHashMatch bool
+
+	// FingerprintMatch tells if the terrain and resource layout of the map file
+	// matches the replay's map (see rep.MapData.Fingerprint).
+	// It may be true even if CHKHashMatch is false, e.g. for localized versions of the same map.
+	FingerprintMatch bool
+
+	// Mismatches lists the structural differences between the replay's map and the map file.
+	Mismatches []*Mismatch
+}
+
+// Match tells if the map file matches the replay's map: if the map data is identical,
+// or if there are no structural differences.
+func (v *Verification) Match() bool {
+	return v.CHKHashMatch || len(v.Mismatches) == 0
+}
+
+// Mismatch describes a difference between the replay's map and the map file.
+type Mismatch struct {
+	// Field is the name of the differing property
+	Field string
+
+	// Replay is the value in the replay, MapFile is the value in the map file
+	Replay, MapFile string
+}
+
+// VerifyFile verifies the given map file against the map of the replay.
+// The replay must be parsed with map data included (repparser.Config.MapData).
+func VerifyFile(r *rep.Replay, name string) (*Verification, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return Verify(r, data)
+}
+
+// Verify verifies the given map file content against the map of the replay.
+// The replay must be parsed with map data included (repparser.Config.MapData).
+func Verify(r *rep.Replay, mapData []byte) (*Verification, error) {
+	if r.MapData == nil {
+		return nil, repmap.ErrNoMapData
+	}
+
+	chk, err := ReadCHK(mapData)
+	if err != nil {
+		return nil, err
+	}
+	md, width, height, err := repparser.ParseCHK(chk, repparser.Config{})
+	if err != nil {
+		return nil, err
+	}
+
+	rmd := r.MapData
+	chkHash := sha1.Sum(chk)
+	v := &Verification{
+		CHKHashMatch:     rmd.CHKHash == hex.EncodeToString(chkHash[:]),
+		FingerprintMatch: rmd.Fingerprint == md.Fingerprint,
+	}
+
+	add := func(field string, replay, mapFile any) {
+		rs, ms := fmt.Sprint(replay), fmt.Sprint(mapFile)
+		if rs != ms {
+			v.Mismatches = append(v.Mismatches, &Mismatch{Field: field, Replay: rs, MapFile: ms})
+		}
+	}
+
+	add("Version", rmd.Version, md.Version)
+	add("TileSet", rmd.TileSet, md.TileSet)
+	add("Size", r.Header.MapSize(), fmt.Sprint(width, "x", height))
+	add("Name", rmd.Name, md.Name)
+	add("Description", rmd.Description, md.Description)
+
+	diffTiles := max(len(rmd.Tiles), len(md.Tiles)) - min(len(rmd.Tiles), len(md.Tiles))
+	for i := range min(len(rmd.Tiles), len(md.Tiles)) {
+		if rmd.Tiles[i] != md.Tiles[i] {
+			diffTiles++
+		}
+	}
+	if diffTiles > 0 {
+		v.Mismatches = append(v.Mismatches, &Mismatch{
+			Field:   "Tiles",
+			Replay:  fmt.Sprint(len(rmd.Tiles), " tiles"),
+			MapFile: fmt.Sprint(len(md.Tiles), " tiles, ", diffTiles, " differ"),
+		})
+	}
+
+	add("StartLocations", rmd.StartLocations, md.StartLocations)
+	add("MineralFields", rmd.MineralFields, md.MineralFields)
+	add("Geysers", rmd.Geysers, md.Geysers)
+
+	return v, nil
+}
@@ -0,0 +1,75 @@
+package mapfile
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/repparser"
+)
+
+// buildCHK builds scenario data with the given name and tiles.
+func buildCHK(name string, tiles ...uint16) (chk []byte) {
+	section := func(id string, data []byte) {
+		chk = append(chk, id...)
+		chk = binary.LittleEndian.AppendUint32(chk, uint32(len(data)))
+		chk = append(chk, data...)
+	}
+	section("VER ", []byte{0xcd, 0})
+	section("DIM ", []byte{byte(len(tiles)), 0, 1, 0})
+	section("ERA ", []byte{4, 0})
+	mtxm := []byte{}
+	for _, tile := range tiles {
+		mtxm = binary.LittleEndian.AppendUint16(mtxm, tile)
+	}
+	section("MTXM", mtxm)
+	section("SPRP", []byte{1, 0, 0, 0})
+	section("STR ", append([]byte{1, 0, 4, 0}, name+"\x00"...))
+	return
+}
+
+func TestVerify(t *testing.T) {
+	chk := buildCHK("Fighting Spirit", 1, 2, 3, 4)
+	md, width, height, err := repparser.ParseCHK(chk, repparser.Config{})
+	if err != nil {
+		t.Fatalf("Failed to parse CHK: %v", err)
+	}
+	r := &rep.Replay{Header: &rep.Header{MapWidth: width, MapHeight: height}, MapData: md}
+
+	cases := []struct {
+		name                   string
+		chk                    []byte
+		hashMatch, fingerprint bool
+		mismatches             []string
+	}{
+		{"identical", chk, true, true, nil},
+		{"localized", buildCHK("투혼", 1, 2, 3, 4), false, true, []string{"Name"}},
+		{"other version", buildCHK("Fighting Spirit", 1, 2, 3, 5), false, false, []string{"Tiles"}},
+	}
+	for _, c := range cases {
+		v, err := Verify(r, buildMPQ(t, c.chk, blockFlagSingleUnit, 3))
+		if err != nil {
+			t.Errorf("[%s] Failed to verify: %v", c.name, err)
+			continue
+		}
+		if v.CHKHashMatch != c.hashMatch || v.FingerprintMatch != c.fingerprint {
+			t.Errorf("[%s] Expected: %v %v, got: %v %v", c.name, c.hashMatch, c.fingerprint, v.CHKHashMatch, v.FingerprintMatch)
+		}
+		if len(v.Mismatches) != len(c.mismatches) {
+			t.Errorf("[%s] Expected: %v mismatches, got: %v", c.name, len(c.mismatches), len(v.Mismatches))
+			continue
+		}
+		for i, m := range v.Mismatches {
+			if m.Field != c.mismatches[i] {
+				t.Errorf("[%s] Expected: %v, got: %v", c.name, c.mismatches[i], m.Field)
+			}
+		}
+		if match := c.mismatches == nil; v.Match() != match {
+			t.Errorf("[%s] Expected match: %v, got: %v", c.name, match, v.Match())
+		}
+	}
+
+	if _, err := Verify(&rep.Replay{}, nil); err == nil {
+		t.Errorf("Expected error for replay without map data")
+	}
+}
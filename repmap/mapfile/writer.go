@@ -0,0 +1,94 @@
+// This file contains writing map files.
+
+package mapfile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/icza/screp/rep"
+)
+
+// ErrNoRawMapData is returned if the raw map data of a replay is not available.
+var ErrNoRawMapData = errors.New("no raw map data (replay must be parsed with repparser.Config.Debug)")
+
+// writerSectorShift is the sector size shift used by the writer (4096-byte sectors, the game's default).
+const writerSectorShift = 3
+
+// WriteCHK writes a map file (an MPQ archive) holding the given scenario data (CHK).
+//
+// The archive is minimal: it only contains the scenario file, stored uncompressed.
+// Whether it's a *.scm or *.scx map is decided by the scenario data (version), not by the archive.
+func WriteCHK(w io.Writer, chk []byte) error {
+	const hashEntries = 16 // Must be a power of 2
+	le := binary.LittleEndian
+
+	hashTable := bytes.Repeat([]byte{0xff}, hashEntries*mpqEntrySize)
+	entry := hashTable[(hashString(ScenarioFileName, hashTypeTableOffset)&(hashEntries-1))*mpqEntrySize:]
+	le.PutUint32(entry, hashString(ScenarioFileName, hashTypeNameA))
+	le.PutUint32(entry[4:], hashString(ScenarioFileName, hashTypeNameB))
+	le.PutUint32(entry[8:], 0)  // Locale (neutral) and platform
+	le.PutUint32(entry[12:], 0) // Block index
+	encrypt(hashTable, hashString("(hash table)", hashTypeFileKey))
+
+	blockTable := make([]byte, mpqEntrySize)
+	le.PutUint32(blockTable, mpqHeaderSize)        // File position
+	le.PutUint32(blockTable[4:], uint32(len(chk))) // Compressed size
+	le.PutUint32(blockTable[8:], uint32(len(chk))) // File size
+	le.PutUint32(blockTable[12:], blockFlagExists) // Flags
+	encrypt(blockTable, hashString("(block table)", hashTypeFileKey))
+
+	hashTablePos := uint32(mpqHeaderSize + len(chk))
+	blockTablePos := hashTablePos + uint32(len(hashTable))
+
+	header := make([]byte, mpqHeaderSize)
+	copy(header, "MPQ\x1a")
+	le.PutUint32(header[4:], mpqHeaderSize)
+	le.PutUint32(header[8:], blockTablePos+uint32(len(blockTable))) // Archive size
+	le.PutUint16(header[0x0c:], 0)                                  // Format version
+	le.PutUint16(header[0x0e:], writerSectorShift)
+	le.PutUint32(header[0x10:], hashTablePos)
+	le.PutUint32(header[0x14:], blockTablePos)
+	le.PutUint32(header[0x18:], hashEntries)
+	le.PutUint32(header[0x1c:], 1) // Block table entries
+
+	for _, data := range [][]byte{header, chk, hashTable, blockTable} {
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteReplayMap writes the map embedded in the replay as a playable map file.
+// The replay must be parsed with map data and debug info included
+// (repparser.Config.MapData and repparser.Config.Debug), else ErrNoRawMapData is returned.
+func WriteReplayMap(w io.Writer, r *rep.Replay) error {
+	if r.MapData == nil || r.MapData.Debug == nil {
+		return ErrNoRawMapData
+	}
+	return WriteCHK(w, r.MapData.Debug.Data)
+}
+
+// WriteReplayMapFile writes the map embedded in the replay to a playable map file
+// with the given name. See WriteReplayMap() for requirements.
+func WriteReplayMapFile(name string, r *rep.Replay) (err error) {
+	if r.MapData == nil || r.MapData.Debug == nil {
+		return ErrNoRawMapData
+	}
+
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err2 := f.Close(); err == nil {
+			err = err2
+		}
+	}()
+
+	return WriteReplayMap(f, r)
+}
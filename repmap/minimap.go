@@ -0,0 +1,220 @@
+// This file contains a fast, low-resolution minimap renderer that does not require tileset assets.
+
+package repmap
+
+import (
+	"errors"
+	"image"
+	"image/color"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcore"
+)
+
+// ErrNoMapData is returned if the replay has no map data or the map data has no tiles.
+var ErrNoMapData = errors.New("no map data")
+
+// MinimapOptions holds options for minimap rendering.
+type MinimapOptions struct {
+	// TileSize is the size of a tile in pixels in the rendered image.
+	// Defaults to 1 if zero.
+	TileSize int
+
+	// Resources tells if mineral fields and geysers are to be drawn.
+	Resources bool
+
+	// StartLocations tells if start locations are to be drawn.
+	StartLocations bool
+
+	// Heatmap tells if a heatmap of command target positions (see CmdPos()) is to be drawn
+	// over the terrain. Commands must be parsed.
+	Heatmap bool
+}
+
+// tileSetBaseColors holds the characteristic color of tile sets.
+var tileSetBaseColors = map[*repcore.TileSet]color.RGBA{
+	repcore.TileSetBadlands:      {0x8c, 0x6c, 0x44, 0xff},
+	repcore.TileSetSpacePlatform: {0x64, 0x64, 0x74, 0xff},
+	repcore.TileSetInstallation:  {0x7c, 0x7c, 0x7c, 0xff},
+	repcore.TileSetAshworld:      {0x84, 0x44, 0x34, 0xff},
+	repcore.TileSetJungle:        {0x4c, 0x7c, 0x34, 0xff},
+	repcore.TileSetDesert:        {0xb4, 0x94, 0x5c, 0xff},
+	repcore.TileSetArctic:        {0xb4, 0xbc, 0xc8, 0xff},
+	repcore.TileSetTwilight:      {0x6c, 0x54, 0x7c, 0xff},
+}
+
+var (
+	mineralColor = color.RGBA{0x00, 0xd0, 0xf8, 0xff}
+	geyserColor  = color.RGBA{0x10, 0xfc, 0x18, 0xff}
+	startColor   = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	heatColor    = color.RGBA{0xff, 0x30, 0x00, 0xff}
+)
+
+// terrainShades holds the shade deltas of the coarse terrain classes.
+var terrainShades = map[TerrainClass]int{
+	TerrainUnwalkable: -72,
+	TerrainLow:        -24,
+	TerrainMid:        8,
+	TerrainHigh:       40,
+}
+
+// TileColor returns the color of the given tile value on the given tile set.
+//
+// If tileset data of the tile set is registered (see RegisterTileSetData()),
+// the color is a shade of the tile set's characteristic color according to the coarse terrain class
+// of the tile (unwalkable, low, middle or high ground).
+//
+// Else the terrain of the tile can't be determined: tiles of the same tile group get the same,
+// arbitrary shade of the tile set's characteristic color. This only makes tile group boundaries
+// visible, the shades do not reflect terrain (e.g. high ground is not necessarily lighter).
+func TileColor(ts *repcore.TileSet, tile uint16) color.RGBA {
+	base, ok := tileSetBaseColors[ts]
+	if !ok {
+		base = tileSetBaseColors[repcore.TileSetTwilight]
+	}
+
+	var shade int
+	if tsd := TileSetDataOf(ts); tsd != nil {
+		shade = terrainShades[tsd.terrainClass(tile)]
+	} else {
+		group := uint32(tile >> 4)
+		// Spread consecutive groups to different shades (Knuth's multiplicative hash):
+		shade = int((group*2654435761)>>24)%96 - 48 // -48..47
+	}
+
+	return color.RGBA{shadeComp(base.R, shade), shadeComp(base.G, shade), shadeComp(base.B, shade), 0xff}
+}
+
+// shadeComp shades a color component by the given delta, clamping to 0..255.
+func shadeComp(c uint8, delta int) uint8 {
+	return uint8(max(0, min(255, int(c)+delta)))
+}
+
+// Minimap renders a low-resolution minimap of the replay's map.
+//
+// Tile colors are determined by TileColor(), resources and start locations
+// are drawn as dots; start locations use the color of the player owning them if known.
+func Minimap(r *rep.Replay, opts MinimapOptions) (*image.RGBA, error) {
+	md := r.MapData
+	if md == nil || len(md.Tiles) == 0 {
+		return nil, ErrNoMapData
+	}
+	tileSize := max(opts.TileSize, 1)
+	w, h := int(r.Header.MapWidth), int(r.Header.MapHeight)
+
+	img := image.NewRGBA(image.Rect(0, 0, w*tileSize, h*tileSize))
+
+	for ty := 0; ty < h; ty++ {
+		for tx := 0; tx < w; tx++ {
+			var tile uint16
+			if i := ty*w + tx; i < len(md.Tiles) {
+				tile = md.Tiles[i]
+			}
+			fillRect(img, tx*tileSize, ty*tileSize, tileSize, tileSize, TileColor(md.TileSet, tile))
+		}
+	}
+
+	if opts.Heatmap && r.Commands != nil {
+		drawHeatmap(img, r, w, h, tileSize)
+	}
+
+	// Draws a unit given by its center point and size in tiles.
+	drawUnit := func(pt repcore.Point, tilesW, tilesH int, c color.RGBA) {
+		x := int(pt.X)*tileSize/32 - tilesW*tileSize/2
+		y := int(pt.Y)*tileSize/32 - tilesH*tileSize/2
+		fillRect(img, x, y, tilesW*tileSize, tilesH*tileSize, c)
+	}
+
+	if opts.Resources {
+		for _, mf := range md.MineralFields {
+			drawUnit(mf.Point, 2, 1, mineralColor)
+		}
+		for _, g := range md.Geysers {
+			drawUnit(g.Point, 4, 2, geyserColor)
+		}
+	}
+
+	if opts.StartLocations {
+		for _, sl := range md.StartLocations {
+			drawUnit(sl.Point, 4, 3, slotColor(r, uint16(sl.SlotID)))
+		}
+	}
+
+	return img, nil
+}
+
+// drawHeatmap draws the heatmap of command target positions over the map of the given size in tiles.
+// Tiles are tinted with heatColor, tiles having more commands more intensively.
+func drawHeatmap(img *image.RGBA, r *rep.Replay, w, h, tileSize int) {
+	counts := make([]int, w*h)
+	maxCount := 0
+	for _, cmd := range r.Commands.Cmds {
+		pos, ok := CmdPos(cmd)
+		if !ok {
+			continue
+		}
+		x, y := pos.TileCoords()
+		tx, ty := int(x), int(y)
+		if tx >= w || ty >= h {
+			continue
+		}
+		i := ty*w + tx
+		counts[i]++
+		maxCount = max(maxCount, counts[i])
+	}
+
+	for i, count := range counts {
+		if count == 0 {
+			continue
+		}
+		alpha := 0.3 + 0.6*float64(count)/float64(maxCount)
+		tile := image.Rect(0, 0, tileSize, tileSize).Add(image.Pt(i%w*tileSize, i/w*tileSize))
+		for py := tile.Min.Y; py < tile.Max.Y; py++ {
+			for px := tile.Min.X; px < tile.Max.X; px++ {
+				c := img.RGBAAt(px, py)
+				img.SetRGBA(px, py, color.RGBA{
+					blendComp(c.R, heatColor.R, alpha), blendComp(c.G, heatColor.G, alpha), blendComp(c.B, heatColor.B, alpha), 0xff,
+				})
+			}
+		}
+	}
+}
+
+// blendComp blends color component c2 over c1 with the given alpha (0..1).
+func blendComp(c1, c2 uint8, alpha float64) uint8 {
+	return uint8(float64(c1)*(1-alpha) + float64(c2)*alpha + 0.5)
+}
+
+// playerColor returns the color of the given player, startColor if the player or its color is unknown.
+func playerColor(p *rep.Player) color.RGBA {
+	if p == nil || p.Color == nil || p.Color.RGB == 0 {
+		return startColor
+	}
+	return rgbColor(p.Color.RGB)
+}
+
+// slotColor returns the color of the player in the given slot, startColor if unknown.
+func slotColor(r *rep.Replay, slotID uint16) color.RGBA {
+	for _, p := range r.Header.Players {
+		if p.SlotID == slotID {
+			return playerColor(p)
+		}
+	}
+	return startColor
+}
+
+// rgbColor converts a 0xRRGGBB value to color.RGBA.
+func rgbColor(rgb uint32) color.RGBA {
+	return color.RGBA{uint8(rgb >> 16), uint8(rgb >> 8), uint8(rgb), 0xff}
+}
+
+// fillRect fills the given rectangle of the image with a color.
+// The rectangle is clipped to the image bounds.
+func fillRect(img *image.RGBA, x, y, w, h int, c color.RGBA) {
+	r := image.Rect(x, y, x+w, y+h).Intersect(img.Bounds())
+	for py := r.Min.Y; py < r.Max.Y; py++ {
+		for px := r.Min.X; px < r.Max.X; px++ {
+			img.SetRGBA(px, py, c)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package repmap
+
+import (
+	"encoding/binary"
+	"image/color"
+	"testing"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcmd"
+	"github.com/icza/screp/rep/repcore"
+)
+
+// newTestTileSetData creates tileset data with a single tile group
+// whose sub-tile n refers to mega-tile n, having all mini-tiles with the given flags.
+func newTestTileSetData(t *testing.T, groupFlags uint16, megaTileFlags ...uint16) *TileSetData {
+	t.Helper()
+	cv5 := make([]byte, cv5EntrySize)
+	binary.LittleEndian.PutUint16(cv5[2:], groupFlags)
+	vf4 := make([]byte, len(megaTileFlags)*vf4EntrySize)
+	for mt, flags := range megaTileFlags {
+		binary.LittleEndian.PutUint16(cv5[20+mt*2:], uint16(mt))
+		for i := 0; i < 16; i++ {
+			binary.LittleEndian.PutUint16(vf4[mt*vf4EntrySize+i*2:], flags)
+		}
+	}
+	tsd, err := NewTileSetData(cv5, vf4)
+	if err != nil {
+		t.Fatalf("Failed to create tileset data: %v", err)
+	}
+	return tsd
+}
+
+func TestTileColor(t *testing.T) {
+	ts := repcore.TileSetDesert
+	RegisterTileSetData(ts, newTestTileSetData(t, 0,
+		0,                           // Unwalkable
+		vf4FlagWalkable,             // Low ground
+		vf4FlagWalkable|vf4FlagMid,  // Middle ground
+		vf4FlagWalkable|vf4FlagHigh, // High ground
+	))
+	defer RegisterTileSetData(ts, nil)
+
+	brightness := func(c color.RGBA) int { return int(c.R) + int(c.G) + int(c.B) }
+	var prev int
+	for tile := uint16(0); tile < 4; tile++ {
+		b := brightness(TileColor(ts, tile))
+		if tile > 0 && b <= prev {
+			t.Errorf("[%d] Expected brighter than: %v, got: %v", tile, prev, b)
+		}
+		prev = b
+	}
+}
+
+func TestMinimap(t *testing.T) {
+	md := &rep.MapData{
+		TileSet:        repcore.TileSetJungle,
+		Tiles:          make([]uint16, 64*32),
+		MineralFields:  []rep.Resource{{Point: repcore.Point{X: 100, Y: 100}}},
+		StartLocations: []rep.StartLocation{{Point: repcore.Point{X: 1000, Y: 500}, SlotID: 1}},
+	}
+	r := &rep.Replay{
+		Header: &rep.Header{
+			MapWidth: 64, MapHeight: 32,
+			Players: []*rep.Player{{SlotID: 1, Color: repcore.ColorBlue}},
+		},
+		MapData: md,
+	}
+
+	img, err := Minimap(r, MinimapOptions{TileSize: 2, Resources: true, StartLocations: true})
+	if err != nil {
+		t.Fatalf("Failed to render minimap: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 128 || b.Dy() != 64 {
+		t.Errorf("Expected size: 128x64, got: %dx%d", b.Dx(), b.Dy())
+	}
+
+	cases := []struct {
+		name string
+		x, y int
+		c    color.RGBA
+	}{
+		{"terrain", 120, 60, TileColor(repcore.TileSetJungle, 0)},
+		{"mineral", 100 * 2 / 32, 100 * 2 / 32, mineralColor},
+		{"start location", 1000 * 2 / 32, 500 * 2 / 32, rgbColor(repcore.ColorBlue.RGB)},
+	}
+	for _, c := range cases {
+		if got := img.RGBAAt(c.x, c.y); got != c.c {
+			t.Errorf("[%s] Expected: %v, got: %v", c.name, c.c, got)
+		}
+	}
+
+	if _, err := Minimap(&rep.Replay{Header: r.Header}, MinimapOptions{}); err != ErrNoMapData {
+		t.Errorf("Expected: %v, got: %v", ErrNoMapData, err)
+	}
+}
+
+func TestMinimapHeatmap(t *testing.T) {
+	r := &rep.Replay{
+		Header:  &rep.Header{MapWidth: 4, MapHeight: 2},
+		MapData: &rep.MapData{TileSet: repcore.TileSetJungle, Tiles: make([]uint16, 8)},
+		Commands: &rep.Commands{Cmds: []repcmd.Cmd{
+			&repcmd.RightClickCmd{Base: &repcmd.Base{}, Pos: repcore.Point{X: 40, Y: 10}},
+			&repcmd.RightClickCmd{Base: &repcmd.Base{}, Pos: repcore.Point{X: 50, Y: 20}},
+			&repcmd.BuildCmd{Base: &repcmd.Base{}, Pos: repcore.Point{X: 3, Y: 1}},
+			&repcmd.RightClickCmd{Base: &repcmd.Base{}, Pos: repcore.Point{X: 5000, Y: 20}}, // Outside
+		}},
+	}
+
+	img, err := Minimap(r, MinimapOptions{Heatmap: true})
+	if err != nil {
+		t.Fatalf("Failed to render minimap: %v", err)
+	}
+
+	terrain := TileColor(repcore.TileSetJungle, 0)
+	if got := img.RGBAAt(0, 0); got != terrain {
+		t.Errorf("Expected: %v, got: %v", terrain, got)
+	}
+	hot, warm := img.RGBAAt(1, 0), img.RGBAAt(3, 1)
+	if hot == terrain || warm == terrain || hot.R <= warm.R {
+		t.Errorf("Expected hot > warm > terrain, got: %v, %v, %v", hot, warm, terrain)
+	}
+}
@@ -0,0 +1,11 @@
+/*
+
+Package repterrain implements terrain analysis of StarCraft: Brood War maps:
+decomposition of the walkable area into regions separated by chokepoints,
+similar to what BWTA and BWEM do.
+
+The analysis works on the walkability grid of the map (rep.MapData.Walkability),
+which requires tileset data, see repmap.ComputeGrids().
+
+*/
+package repterrain
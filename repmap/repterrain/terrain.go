@@ -0,0 +1,447 @@
+// This file contains the region and chokepoint decomposition.
+
+package repterrain
+
+import (
+	"math"
+	"slices"
+	"sort"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcore"
+	"github.com/icza/screp/repmap"
+)
+
+const (
+	// minRegionArea is the minimum area of a region in mini-tiles.
+	// Smaller regions are merged into their neighbours when they meet.
+	minRegionArea = 64
+
+	// mergeAltitudeRatio tells when 2 meeting regions are to be merged: if the altitude
+	// where they meet is at least this ratio of the smaller max altitude of the 2 regions,
+	// there is no real narrowing between them.
+	mergeAltitudeRatio = 0.9
+)
+
+// Terrain is the result of the terrain analysis of a map.
+type Terrain struct {
+	// Width and Height of the analyzed area in mini-tiles (a mini-tile is 8x8 pixels)
+	Width, Height int
+
+	// Regions of the map. The ID of a region is its index + 1.
+	Regions []*Region
+
+	// Chokepoints of the map. The ID of a chokepoint is its index + 1.
+	Chokepoints []*Chokepoint
+
+	// altitudes holds the distance of each mini-tile from the closest unwalkable mini-tile,
+	// in mini-tiles. It is 0 for unwalkable mini-tiles.
+	altitudes []int32
+
+	// regionIDs holds the region ID of each mini-tile, 0 for unwalkable mini-tiles.
+	regionIDs []int32
+}
+
+// Region is a walkable area of the map, bounded by unwalkable terrain and chokepoints.
+type Region struct {
+	// ID of the region, 1-based
+	ID int
+
+	// Area of the region in mini-tiles
+	Area int
+
+	// Center of the region: its most open point (the point farthest from unwalkable terrain)
+	Center repcore.Point
+
+	// MaxAltitude is the distance of Center from the closest unwalkable terrain in pixels
+	MaxAltitude int
+
+	// Chokepoints of the region
+	Chokepoints []*Chokepoint `json:"-"`
+
+	// NeighborIDs are the IDs of the regions connected to this region via chokepoints
+	NeighborIDs []int
+}
+
+// Chokepoint is a narrowing connecting 2 regions.
+type Chokepoint struct {
+	// ID of the chokepoint, 1-based
+	ID int
+
+	// RegionIDs are the IDs of the 2 connected regions
+	RegionIDs [2]int
+
+	// Regions are the 2 connected regions
+	Regions [2]*Region `json:"-"`
+
+	// Center of the chokepoint
+	Center repcore.Point
+
+	// Ends are the 2 extreme (farthest apart) frontier mini-tiles of the chokepoint,
+	// they are next to (but not on) the unwalkable terrain on both sides
+	Ends [2]repcore.Point
+
+	// Width of the chokepoint in pixels
+	Width int
+}
+
+// AnalyzeReplay analyzes the terrain of the replay's map.
+//
+// If the walkability grid of the map is not yet computed, it is computed
+// using repmap.ComputeGrids(), which requires registered tileset data:
+// without it an error wrapping repmap.ErrNoTileSetData is returned.
+func AnalyzeReplay(r *rep.Replay) (*Terrain, error) {
+	md := r.MapData
+	if md == nil {
+		return nil, repmap.ErrNoMapData
+	}
+	if md.Walkability == nil {
+		if err := repmap.ComputeGrids(r); err != nil {
+			return nil, err
+		}
+	}
+	return Analyze(md.Walkability), nil
+}
+
+// Analyze analyzes the terrain given by its walkability grid (one element for each mini-tile).
+//
+// Regions are grown from the most open areas (watershed on the distance from unwalkable terrain);
+// where 2 regions meet at a narrowing, a chokepoint is formed between them.
+func Analyze(walk *rep.Grid) *Terrain {
+	w, h := walk.Width, walk.Height
+	t := &Terrain{
+		Width:     w,
+		Height:    h,
+		altitudes: computeAltitudes(walk),
+		regionIDs: make([]int32, w*h),
+	}
+
+	// Walkable mini-tiles in descending altitude order:
+	var order []int
+	for i, alt := range t.altitudes {
+		if alt > 0 {
+			order = append(order, i)
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool { return t.altitudes[order[i]] > t.altitudes[order[j]] })
+
+	// Region growing. Labels are region slot+1, slots are merged using union-find.
+	type slot struct {
+		parent int
+		area   int
+		maxAlt int32
+		top    int // Mini-tile index of the highest altitude
+	}
+	var slots []*slot
+	find := func(s int) int {
+		for slots[s].parent != s {
+			slots[s].parent = slots[slots[s].parent].parent
+			s = slots[s].parent
+		}
+		return s
+	}
+	union := func(a, b int) int {
+		sa, sb := slots[a], slots[b]
+		if sa.area < sb.area {
+			a, b, sa, sb = b, a, sb, sa
+		}
+		sb.parent = a
+		sa.area += sb.area
+		if sb.maxAlt > sa.maxAlt {
+			sa.maxAlt, sa.top = sb.maxAlt, sb.top
+		}
+		return a
+	}
+	shouldMerge := func(a, b int, alt int32) bool {
+		sa, sb := slots[a], slots[b]
+		if sa.area < minRegionArea || sb.area < minRegionArea {
+			return true
+		}
+		return float64(alt) >= mergeAltitudeRatio*float64(min(sa.maxAlt, sb.maxAlt))
+	}
+
+	type frontier struct {
+		idx  int
+		a, b int // Slots on the 2 sides
+	}
+	var frontiers []frontier
+
+	labels := make([]int, w*h)
+	for _, idx := range order {
+		x, y := idx%w, idx/w
+		// Neighbouring slots; the one of the highest altitude neighbour comes first
+		// (frontier mini-tiles are assigned to it, so regions don't leak along walls).
+		var roots []int
+		var firstAlt int32
+		for _, d := range [4][2]int{{0, -1}, {-1, 0}, {1, 0}, {0, 1}} {
+			nx, ny := x+d[0], y+d[1]
+			if nx < 0 || ny < 0 || nx >= w || ny >= h {
+				continue
+			}
+			nidx := ny*w + nx
+			if labels[nidx] == 0 {
+				continue
+			}
+			r := find(labels[nidx] - 1)
+			i := slices.Index(roots, r)
+			if i < 0 {
+				i = len(roots)
+				roots = append(roots, r)
+			}
+			if i == 0 {
+				firstAlt = max(firstAlt, t.altitudes[nidx])
+			} else if t.altitudes[nidx] > firstAlt {
+				roots[0], roots[i] = roots[i], roots[0]
+				firstAlt = t.altitudes[nidx]
+			}
+		}
+
+		if len(roots) == 0 {
+			slots = append(slots, &slot{parent: len(slots), area: 1, maxAlt: t.altitudes[idx], top: idx})
+			labels[idx] = len(slots)
+			continue
+		}
+
+		a := roots[0]
+		for _, b := range roots[1:] {
+			if shouldMerge(a, b, t.altitudes[idx]) {
+				a = union(a, b)
+			} else {
+				frontiers = append(frontiers, frontier{idx: idx, a: a, b: b})
+			}
+		}
+		labels[idx] = a + 1
+		slots[a].area++
+	}
+
+	// Assign region IDs to the final slots in the order of their appearance:
+	slotRegions := make([]*Region, len(slots))
+	for idx, l := range labels {
+		if l == 0 {
+			continue
+		}
+		s := find(l - 1)
+		reg := slotRegions[s]
+		if reg == nil {
+			reg = &Region{
+				ID:          len(t.Regions) + 1,
+				Area:        slots[s].area,
+				Center:      t.point(slots[s].top),
+				MaxAltitude: int(slots[s].maxAlt) * 8,
+			}
+			slotRegions[s] = reg
+			t.Regions = append(t.Regions, reg)
+		}
+		t.regionIDs[idx] = int32(reg.ID)
+	}
+
+	// Group frontier mini-tiles by region pairs:
+	type pair struct{ id1, id2 int }
+	var pairs []pair
+	pairIdxs := map[pair][]int{}
+	for _, f := range frontiers {
+		id1, id2 := slotRegions[find(f.a)].ID, slotRegions[find(f.b)].ID
+		if id1 == id2 {
+			continue // Merged later on
+		}
+		p := pair{min(id1, id2), max(id1, id2)}
+		if _, ok := pairIdxs[p]; !ok {
+			pairs = append(pairs, p)
+		}
+		pairIdxs[p] = append(pairIdxs[p], f.idx)
+	}
+
+	// Each cluster of frontier mini-tiles between 2 regions forms a chokepoint:
+	for _, p := range pairs {
+		for _, cluster := range t.clusters(pairIdxs[p]) {
+			r1, r2 := t.Regions[p.id1-1], t.Regions[p.id2-1]
+			cp := t.newChokepoint(cluster)
+			cp.ID = len(t.Chokepoints) + 1
+			cp.RegionIDs = [2]int{r1.ID, r2.ID}
+			cp.Regions = [2]*Region{r1, r2}
+			t.Chokepoints = append(t.Chokepoints, cp)
+
+			r1.Chokepoints = append(r1.Chokepoints, cp)
+			r2.Chokepoints = append(r2.Chokepoints, cp)
+			if !slices.Contains(r1.NeighborIDs, r2.ID) {
+				r1.NeighborIDs = append(r1.NeighborIDs, r2.ID)
+				r2.NeighborIDs = append(r2.NeighborIDs, r1.ID)
+			}
+		}
+	}
+
+	return t
+}
+
+// computeAltitudes computes the distance of each mini-tile from the closest
+// unwalkable mini-tile (or the map edge) in mini-tiles, using 8-directional steps.
+func computeAltitudes(walk *rep.Grid) []int32 {
+	w, h := walk.Width, walk.Height
+	alts := make([]int32, w*h)
+
+	var queue []int
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if !walk.At(x, y) {
+				continue
+			}
+			// Seed: walkable mini-tiles next to unwalkable ones or the map edge
+			for _, d := range neighbors8 {
+				nx, ny := x+d[0], y+d[1]
+				if !walk.At(nx, ny) { // At() returns false outside of the grid
+					alts[y*w+x] = 1
+					queue = append(queue, y*w+x)
+					break
+				}
+			}
+		}
+	}
+
+	for len(queue) > 0 {
+		idx := queue[0]
+		queue = queue[1:]
+		x, y := idx%w, idx/w
+		for _, d := range neighbors8 {
+			nx, ny := x+d[0], y+d[1]
+			if !walk.At(nx, ny) {
+				continue
+			}
+			if nidx := ny*w + nx; alts[nidx] == 0 {
+				alts[nidx] = alts[idx] + 1
+				queue = append(queue, nidx)
+			}
+		}
+	}
+
+	return alts
+}
+
+// neighbors8 holds the offsets of the 8 neighbours of a grid cell.
+var neighbors8 = [8][2]int{{-1, -1}, {0, -1}, {1, -1}, {-1, 0}, {1, 0}, {-1, 1}, {0, 1}, {1, 1}}
+
+// clusters groups the given mini-tiles into clusters: mini-tiles at most 2 steps apart
+// belong to the same cluster.
+func (t *Terrain) clusters(idxs []int) (clusters [][]int) {
+	w := t.Width
+	remaining := make(map[int]bool, len(idxs))
+	for _, idx := range idxs {
+		remaining[idx] = true
+	}
+
+	for _, start := range idxs {
+		if !remaining[start] {
+			continue
+		}
+		delete(remaining, start)
+		cluster := []int{start}
+		for i := 0; i < len(cluster); i++ {
+			x, y := cluster[i]%w, cluster[i]/w
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					nx, ny := x+dx, y+dy
+					if nx < 0 || ny < 0 || nx >= w || ny >= t.Height {
+						continue
+					}
+					if nidx := ny*w + nx; remaining[nidx] {
+						delete(remaining, nidx)
+						cluster = append(cluster, nidx)
+					}
+				}
+			}
+		}
+		clusters = append(clusters, cluster)
+	}
+
+	return
+}
+
+// newChokepoint creates a chokepoint from a cluster of frontier mini-tiles.
+// IDs and regions are not set.
+func (t *Terrain) newChokepoint(cluster []int) *Chokepoint {
+	w := t.Width
+	dist2 := func(i, j int) int {
+		dx, dy := i%w-j%w, i/w-j/w
+		return dx*dx + dy*dy
+	}
+	farthest := func(from int) (far int) {
+		far = from
+		for _, idx := range cluster {
+			if dist2(from, idx) > dist2(from, far) {
+				far = idx
+			}
+		}
+		return
+	}
+
+	center := cluster[0]
+	for _, idx := range cluster {
+		if t.altitudes[idx] > t.altitudes[center] {
+			center = idx
+		}
+	}
+	end1 := farthest(center)
+	end2 := farthest(end1)
+
+	return &Chokepoint{
+		Center: t.point(center),
+		Ends:   [2]repcore.Point{t.point(end1), t.point(end2)},
+		Width:  int(math.Sqrt(float64(dist2(end1, end2))))*8 + 8,
+	}
+}
+
+// point returns the center point (in pixels) of the mini-tile given by its index.
+func (t *Terrain) point(idx int) repcore.Point {
+	return repcore.Point{X: uint16(idx%t.Width*8 + 4), Y: uint16(idx/t.Width*8 + 4)}
+}
+
+// index returns the index of the mini-tile containing the given point, -1 if outside.
+func (t *Terrain) index(pt repcore.Point) int {
+	x, y := int(pt.X)/8, int(pt.Y)/8
+	if x >= t.Width || y >= t.Height {
+		return -1
+	}
+	return y*t.Width + x
+}
+
+// RegionAt returns the region containing the given point (in pixels).
+// Returns nil if the point is on unwalkable terrain or outside of the map.
+func (t *Terrain) RegionAt(pt repcore.Point) *Region {
+	idx := t.index(pt)
+	if idx < 0 || t.regionIDs[idx] == 0 {
+		return nil
+	}
+	return t.Regions[t.regionIDs[idx]-1]
+}
+
+// Altitude returns the distance of the given point (in pixels) from the closest
+// unwalkable terrain in pixels. Returns 0 if the point is on unwalkable terrain or outside of the map.
+func (t *Terrain) Altitude(pt repcore.Point) int {
+	idx := t.index(pt)
+	if idx < 0 {
+		return 0
+	}
+	return int(t.altitudes[idx]) * 8
+}
+
+// Connected tells if the 2 regions are connected by ground (via chokepoints).
+func (t *Terrain) Connected(r1, r2 *Region) bool {
+	if r1 == r2 {
+		return true
+	}
+	visited := map[int]bool{r1.ID: true}
+	queue := []*Region{r1}
+	for len(queue) > 0 {
+		r := queue[0]
+		queue = queue[1:]
+		for _, id := range r.NeighborIDs {
+			if id == r2.ID {
+				return true
+			}
+			if !visited[id] {
+				visited[id] = true
+				queue = append(queue, t.Regions[id-1])
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package repterrain
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcore"
+	"github.com/icza/screp/repmap"
+)
+
+// newGrid creates a walkability grid with the given walkable rectangles (in mini-tiles).
+func newGrid(w, h int, rects ...[4]int) *rep.Grid {
+	g := rep.NewGrid(w, h)
+	for _, r := range rects {
+		for y := r[1]; y < r[1]+r[3]; y++ {
+			for x := r[0]; x < r[0]+r[2]; x++ {
+				g.Set(x, y, true)
+			}
+		}
+	}
+	return g
+}
+
+func TestAnalyze(t *testing.T) {
+	cases := []struct {
+		name        string
+		walk        *rep.Grid
+		regions     int
+		chokepoints int
+	}{
+		{
+			name:    "open field",
+			walk:    newGrid(40, 40, [4]int{0, 0, 40, 40}),
+			regions: 1,
+		},
+		{
+			name:        "two rooms and a corridor",
+			walk:        newGrid(90, 40, [4]int{0, 0, 40, 40}, [4]int{40, 18, 10, 4}, [4]int{50, 0, 40, 40}),
+			regions:     2,
+			chokepoints: 1,
+		},
+		{
+			name:    "L-shape",
+			walk:    newGrid(40, 40, [4]int{0, 0, 20, 40}, [4]int{20, 20, 20, 20}),
+			regions: 1,
+		},
+		{
+			name:    "separated rooms",
+			walk:    newGrid(90, 40, [4]int{0, 0, 40, 40}, [4]int{50, 0, 40, 40}),
+			regions: 2,
+		},
+	}
+
+	for _, c := range cases {
+		tr := Analyze(c.walk)
+		if len(tr.Regions) != c.regions || len(tr.Chokepoints) != c.chokepoints {
+			t.Errorf("[%s] Expected: %v regions, %v chokepoints, got: %v regions, %v chokepoints",
+				c.name, c.regions, c.chokepoints, len(tr.Regions), len(tr.Chokepoints))
+		}
+	}
+}
+
+func TestAnalyzeCorridor(t *testing.T) {
+	// 2 rooms of 40x40 mini-tiles connected by a corridor of 4 mini-tiles wide (rows 18-21).
+	tr := Analyze(newGrid(90, 40, [4]int{0, 0, 40, 40}, [4]int{40, 18, 10, 4}, [4]int{50, 0, 40, 40}))
+	if len(tr.Regions) != 2 || len(tr.Chokepoints) != 1 {
+		t.Fatalf("Expected: 2 regions, 1 chokepoint, got: %v regions, %v chokepoints", len(tr.Regions), len(tr.Chokepoints))
+	}
+
+	r1, r2 := tr.RegionAt(repcore.Point{X: 160, Y: 160}), tr.RegionAt(repcore.Point{X: 560, Y: 160})
+	if r1 == nil || r2 == nil || r1 == r2 {
+		t.Fatalf("Expected 2 different regions, got: %v, %v", r1, r2)
+	}
+	if r := tr.RegionAt(repcore.Point{X: 360, Y: 20}); r != nil {
+		t.Errorf("Expected no region on unwalkable terrain, got: %v", r.ID)
+	}
+	if !tr.Connected(r1, r2) {
+		t.Errorf("Expected connected regions")
+	}
+
+	cp := tr.Chokepoints[0]
+	if cp.RegionIDs != [2]int{r1.ID, r2.ID} {
+		t.Errorf("Expected: %v, got: %v", [2]int{r1.ID, r2.ID}, cp.RegionIDs)
+	}
+	// Center and ends must be around the corridor rows (pixels 144..176):
+	for _, pt := range []repcore.Point{cp.Center, cp.Ends[0], cp.Ends[1]} {
+		if pt.Y < 144-8 || pt.Y > 176+8 {
+			t.Errorf("Expected point around the corridor, got: %v", pt)
+		}
+	}
+	if cp.Width < 4*8 || cp.Width > 6*8 {
+		t.Errorf("Expected width around the corridor width, got: %v", cp.Width)
+	}
+}
+
+func TestAnalyzeReplay(t *testing.T) {
+	md := &rep.MapData{TileSet: repcore.TileSetJungle, Tiles: make([]uint16, 10*10)}
+	r := &rep.Replay{Header: &rep.Header{MapWidth: 10, MapHeight: 10}, MapData: md}
+
+	// No tileset data in the repository, so the walkability grid can't be computed:
+	if _, err := AnalyzeReplay(r); !errors.Is(err, repmap.ErrNoTileSetData) {
+		t.Errorf("Expected: %v, got: %v", repmap.ErrNoTileSetData, err)
+	}
+	if _, err := AnalyzeReplay(&rep.Replay{Header: r.Header}); err != repmap.ErrNoMapData {
+		t.Errorf("Expected: %v, got: %v", repmap.ErrNoMapData, err)
+	}
+
+	// An available walkability grid is used as-is:
+	md.Walkability = newGrid(40, 40, [4]int{0, 0, 40, 40})
+	tr, err := AnalyzeReplay(r)
+	if err != nil {
+		t.Fatalf("Failed to analyze replay: %v", err)
+	}
+	if len(tr.Regions) != 1 {
+		t.Errorf("Expected: %v regions, got: %v", 1, len(tr.Regions))
+	}
+}
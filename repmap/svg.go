@@ -0,0 +1,149 @@
+// This file contains the SVG map exporter.
+
+package repmap
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"io"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcmd"
+	"github.com/icza/screp/rep/repcore"
+)
+
+// SVGOptions holds options for SVG export.
+type SVGOptions struct {
+	// Resources tells if mineral fields and geysers are to be included.
+	Resources bool
+
+	// StartLocations tells if start locations are to be included.
+	StartLocations bool
+
+	// Commands tells if positions of commands having a target point are to be included
+	// as an overlay. Requires the commands to be parsed.
+	Commands bool
+}
+
+// WriteSVG writes the replay's map as an SVG document to w.
+//
+// The coordinate system of the document is the map's pixel coordinate system
+// (1 tile is 32 pixels). Elements are grouped and classed so that they can be styled:
+//
+//   - g#terrain: terrain as rectangles of the same tile color, see TileColor()
+//   - g#resources: rect.mineral and rect.geyser elements
+//   - g#startlocations: rect.startlocation elements with a data-slot attribute
+//   - g#commands: circle.cmd elements with a data-player attribute and a "p<PlayerID>" class
+func WriteSVG(w io.Writer, r *rep.Replay, opts SVGOptions) error {
+	md := r.MapData
+	if md == nil || len(md.Tiles) == 0 {
+		return ErrNoMapData
+	}
+	tw, th := int(r.Header.MapWidth), int(r.Header.MapHeight)
+
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" width="%d" height="%d" shape-rendering="crispEdges">`+"\n",
+		tw*32, th*32, tw*4, th*4)
+
+	// Terrain: merge horizontal runs of the same color into one rect:
+	bw.WriteString(`<g id="terrain">` + "\n")
+	for ty := 0; ty < th; ty++ {
+		var runColor color.RGBA
+		runStart := 0
+		for tx := 0; tx <= tw; tx++ {
+			var c color.RGBA
+			if tx < tw {
+				var tile uint16
+				if i := ty*tw + tx; i < len(md.Tiles) {
+					tile = md.Tiles[i]
+				}
+				c = TileColor(md.TileSet, tile)
+			}
+			if tx == 0 {
+				runColor = c
+				continue
+			}
+			if tx < tw && c == runColor {
+				continue
+			}
+			fmt.Fprintf(bw, `<rect x="%d" y="%d" width="%d" height="32" fill="%s"/>`+"\n",
+				runStart*32, ty*32, (tx-runStart)*32, hexColor(runColor))
+			runColor, runStart = c, tx
+		}
+	}
+	bw.WriteString("</g>\n")
+
+	// Writes a unit rect given by its center point and size in pixels.
+	writeUnit := func(class string, pt repcore.Point, w, h int, extraAttrs string) {
+		fmt.Fprintf(bw, `<rect class="%s" x="%d" y="%d" width="%d" height="%d"%s/>`+"\n",
+			class, int(pt.X)-w/2, int(pt.Y)-h/2, w, h, extraAttrs)
+	}
+
+	if opts.Resources {
+		bw.WriteString(`<g id="resources">` + "\n")
+		for _, mf := range md.MineralFields {
+			writeUnit("mineral", mf.Point, 64, 32, fmt.Sprintf(` fill="%s" data-amount="%d"`, hexColor(mineralColor), mf.Amount))
+		}
+		for _, g := range md.Geysers {
+			writeUnit("geyser", g.Point, 128, 64, fmt.Sprintf(` fill="%s" data-amount="%d"`, hexColor(geyserColor), g.Amount))
+		}
+		bw.WriteString("</g>\n")
+	}
+
+	if opts.StartLocations {
+		bw.WriteString(`<g id="startlocations">` + "\n")
+		for _, sl := range md.StartLocations {
+			c := slotColor(r, uint16(sl.SlotID))
+			writeUnit("startlocation", sl.Point, 128, 96, fmt.Sprintf(` fill="%s" data-slot="%d"`, hexColor(c), sl.SlotID))
+		}
+		bw.WriteString("</g>\n")
+	}
+
+	if opts.Commands && r.Commands != nil {
+		bw.WriteString(`<g id="commands" fill-opacity="0.5">` + "\n")
+		for _, cmd := range r.Commands.Cmds {
+			pos, ok := CmdPos(cmd)
+			if !ok {
+				continue
+			}
+			pid := cmd.BaseCmd().PlayerID
+			c := playerColor(r.Header.PIDPlayers[pid])
+			fmt.Fprintf(bw, `<circle class="cmd p%d" cx="%d" cy="%d" r="16" fill="%s" data-player="%d" data-frame="%d"/>`+"\n",
+				pid, pos.X, pos.Y, hexColor(c), pid, cmd.BaseCmd().Frame)
+		}
+		bw.WriteString("</g>\n")
+	}
+
+	bw.WriteString("</svg>\n")
+
+	return bw.Flush()
+}
+
+// CmdPos returns the target point of the command in pixels if it has one.
+//
+// Build and land commands store tile positions, those are converted to pixels
+// (the top-left corner of the tile).
+func CmdPos(cmd repcmd.Cmd) (pos repcore.Point, ok bool) {
+	switch x := cmd.(type) {
+	case *repcmd.RightClickCmd:
+		return x.Pos, true
+	case *repcmd.TargetedOrderCmd:
+		return x.Pos, true
+	case *repcmd.BuildCmd:
+		return repcore.Point{X: x.Pos.X * 32, Y: x.Pos.Y * 32}, true
+	case *repcmd.LandCmd:
+		return repcore.Point{X: x.Pos.X * 32, Y: x.Pos.Y * 32}, true
+	case *repcmd.LiftOffCmd:
+		return x.Pos, true
+	case *repcmd.MinimapPingCmd:
+		return x.Pos, true
+	}
+	return
+}
+
+// hexColor returns the "#rrggbb" representation of a color.
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
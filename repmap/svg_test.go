@@ -0,0 +1,110 @@
+package repmap
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcmd"
+	"github.com/icza/screp/rep/repcore"
+)
+
+func TestCmdPos(t *testing.T) {
+	base := &repcmd.Base{}
+	pt := repcore.Point{X: 10, Y: 20}
+
+	cases := []struct {
+		name string
+		cmd  repcmd.Cmd
+		pos  repcore.Point
+		ok   bool
+	}{
+		{"right click", &repcmd.RightClickCmd{Base: base, Pos: pt}, pt, true},
+		{"build", &repcmd.BuildCmd{Base: base, Pos: pt}, repcore.Point{X: 320, Y: 640}, true},
+		{"land", &repcmd.LandCmd{Base: base, Pos: pt}, repcore.Point{X: 320, Y: 640}, true},
+		{"chat", &repcmd.ChatCmd{Base: base}, repcore.Point{}, false},
+	}
+	for _, c := range cases {
+		pos, ok := CmdPos(c.cmd)
+		if pos != c.pos || ok != c.ok {
+			t.Errorf("[%s] Expected: %v %v, got: %v %v", c.name, c.pos, c.ok, pos, ok)
+		}
+	}
+}
+
+func TestWriteSVG(t *testing.T) {
+	p := &rep.Player{SlotID: 1, ID: 3, Color: repcore.ColorRed}
+	r := &rep.Replay{
+		Header: &rep.Header{
+			MapWidth: 4, MapHeight: 2,
+			Players:    []*rep.Player{p},
+			PIDPlayers: map[byte]*rep.Player{p.ID: p},
+		},
+		MapData: &rep.MapData{
+			TileSet:        repcore.TileSetJungle,
+			Tiles:          []uint16{0, 0, 0x10, 0x10, 0, 0, 0, 0},
+			MineralFields:  []rep.Resource{{Point: repcore.Point{X: 40, Y: 20}, Amount: 1500}},
+			StartLocations: []rep.StartLocation{{Point: repcore.Point{X: 64, Y: 32}, SlotID: 1}},
+		},
+		Commands: &rep.Commands{Cmds: []repcmd.Cmd{
+			&repcmd.BuildCmd{Base: &repcmd.Base{PlayerID: p.ID, Frame: 100}, Pos: repcore.Point{X: 2, Y: 1}},
+		}},
+	}
+
+	sb := &strings.Builder{}
+	if err := WriteSVG(sb, r, SVGOptions{Resources: true, StartLocations: true, Commands: true}); err != nil {
+		t.Fatalf("Failed to write SVG: %v", err)
+	}
+
+	var doc struct {
+		ViewBox string `xml:"viewBox,attr"`
+		Groups  []struct {
+			ID    string `xml:"id,attr"`
+			Rects []struct {
+				Class string `xml:"class,attr"`
+				X     int    `xml:"x,attr"`
+				Width int    `xml:"width,attr"`
+				Fill  string `xml:"fill,attr"`
+			} `xml:"rect"`
+			Circles []struct {
+				CX     int    `xml:"cx,attr"`
+				CY     int    `xml:"cy,attr"`
+				Player int    `xml:"data-player,attr"`
+				Fill   string `xml:"fill,attr"`
+			} `xml:"circle"`
+		} `xml:"g"`
+	}
+	if err := xml.Unmarshal([]byte(sb.String()), &doc); err != nil {
+		t.Fatalf("Invalid SVG: %v", err)
+	}
+
+	if doc.ViewBox != "0 0 128 64" {
+		t.Errorf("Expected viewBox: %q, got: %q", "0 0 128 64", doc.ViewBox)
+	}
+	if len(doc.Groups) != 4 {
+		t.Fatalf("Expected: %v groups, got: %v", 4, len(doc.Groups))
+	}
+	ids := []string{"terrain", "resources", "startlocations", "commands"}
+	for i, id := range ids {
+		if doc.Groups[i].ID != id {
+			t.Errorf("[%d] Expected group: %v, got: %v", i, id, doc.Groups[i].ID)
+		}
+	}
+
+	// Terrain: row 0 has 2 runs (2 different tile groups), row 1 has 1 run.
+	if terrain := doc.Groups[0].Rects; len(terrain) != 3 || terrain[0].Width != 64 || terrain[1].X != 64 {
+		t.Errorf("Unexpected terrain: %+v", terrain)
+	}
+	if res := doc.Groups[1].Rects; len(res) != 1 || res[0].Class != "mineral" || res[0].X != 40-32 {
+		t.Errorf("Unexpected resources: %+v", res)
+	}
+	red := hexColor(rgbColor(repcore.ColorRed.RGB))
+	if sls := doc.Groups[2].Rects; len(sls) != 1 || sls[0].Fill != red {
+		t.Errorf("Unexpected start locations: %+v", sls)
+	}
+	// Build command tile position (2, 1) is converted to pixels:
+	if cmds := doc.Groups[3].Circles; len(cmds) != 1 || cmds[0].CX != 64 || cmds[0].CY != 32 || cmds[0].Player != 3 || cmds[0].Fill != red {
+		t.Errorf("Unexpected commands: %+v", cmds)
+	}
+}
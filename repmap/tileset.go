@@ -0,0 +1,324 @@
+// This file contains tileset data handling (CV5 and VF4 tables) and terrain grid derivation.
+
+package repmap
+
+import (
+	"embed"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcore"
+)
+
+// ErrNoTileSetData is returned if tileset data is required but is not registered
+// for the tile set of the map. Tileset data is not part of the repository, see TileSetData.
+var ErrNoTileSetData = errors.New("no tileset data")
+
+const (
+	cv5EntrySize = 52 // Size of a tile group entry in CV5 files
+	vf4EntrySize = 32 // Size of a mega-tile entry in VF4 files (16 mini-tiles, 2 bytes each)
+)
+
+// CV5 tile group flags
+const (
+	cv5FlagUnbuildable = 0x0080
+)
+
+// VF4 mini-tile flags
+const (
+	vf4FlagWalkable = 0x0001
+	vf4FlagMid      = 0x0002
+	vf4FlagHigh     = 0x0004
+	vf4FlagRamp     = 0x0010
+)
+
+// TerrainClass is a coarse terrain class of a tile.
+type TerrainClass byte
+
+// Terrain classes
+const (
+	// TerrainUnwalkable is unwalkable terrain (e.g. cliffs, water, space)
+	TerrainUnwalkable TerrainClass = iota
+
+	// TerrainLow is low ground
+	TerrainLow
+
+	// TerrainMid is middle ground
+	TerrainMid
+
+	// TerrainHigh is high ground
+	TerrainHigh
+)
+
+var terrainClassStrings = []string{
+	TerrainUnwalkable: "unwalkable",
+	TerrainLow:        "low ground",
+	TerrainMid:        "middle ground",
+	TerrainHigh:       "high ground",
+}
+
+// String returns a short string description.
+func (tc TerrainClass) String() string {
+	if int(tc) < len(terrainClassStrings) {
+		return terrainClassStrings[tc]
+	}
+	return fmt.Sprintf("Unknown 0x%x", byte(tc))
+}
+
+// SplitTile splits a tile value (an element of rep.MapData.Tiles) into tile group and variation
+// (the index of the mega-tile reference within the tile group).
+// Tileset data is not required for this.
+func SplitTile(tile uint16) (group, variation int) {
+	return int(tile >> 4), int(tile & 0x0f)
+}
+
+// TileInfo describes a tile resolved using tileset data.
+type TileInfo struct {
+	// Group is the tile group, Variation is the index of the mega-tile reference within the group
+	Group, Variation int
+
+	// MegaTile is the index of the mega-tile of the tile (in the VF4 table)
+	MegaTile int
+
+	// Terrain is the coarse terrain class of the tile: the class of the majority of its mini-tiles
+	Terrain TerrainClass
+
+	// Ramp tells if the tile is (part of) a ramp or stairs: if any of its mini-tiles is flagged as ramp
+	Ramp bool
+
+	// Unbuildable tells if the tile group is flagged as unbuildable
+	// (this alone does not mean the tile is buildable, its mini-tiles must also be walkable)
+	Unbuildable bool
+}
+
+// TileSetData holds the tile group (CV5) and mega-tile (VF4) tables of a tile set.
+//
+// Tileset files are part of the game assets and are not redistributable,
+// they must be obtained from a game installation (e.g. "tileset/jungle.cv5" and "tileset/jungle.vf4").
+// They may be embedded at build time (see the tilesets folder), loaded with LoadTileSetDir(),
+// or registered with RegisterTileSetData().
+type TileSetData struct {
+	// cv5 is the raw content of the CV5 file
+	cv5 []byte
+
+	// vf4 is the raw content of the VF4 file
+	vf4 []byte
+}
+
+// NewTileSetData creates a new TileSetData from the content of a CV5 and a VF4 file.
+func NewTileSetData(cv5, vf4 []byte) (*TileSetData, error) {
+	if len(cv5) == 0 || len(cv5)%cv5EntrySize != 0 {
+		return nil, fmt.Errorf("invalid CV5 size: %d", len(cv5))
+	}
+	if len(vf4) == 0 || len(vf4)%vf4EntrySize != 0 {
+		return nil, fmt.Errorf("invalid VF4 size: %d", len(vf4))
+	}
+	return &TileSetData{cv5: cv5, vf4: vf4}, nil
+}
+
+var (
+	tileSetDatasMu sync.RWMutex
+	tileSetDatas   = map[uint16]*TileSetData{} // Registered tileset data, mapped from tile set ID
+)
+
+// RegisterTileSetData registers tileset data for a tile set.
+// It is safe to call concurrently.
+func RegisterTileSetData(ts *repcore.TileSet, tsd *TileSetData) {
+	tileSetDatasMu.Lock()
+	tileSetDatas[ts.ID] = tsd
+	tileSetDatasMu.Unlock()
+}
+
+// TileSetDataOf returns the registered tileset data of a tile set, nil if not registered.
+func TileSetDataOf(ts *repcore.TileSet) *TileSetData {
+	if ts == nil {
+		return nil
+	}
+	tileSetDatasMu.RLock()
+	defer tileSetDatasMu.RUnlock()
+	return tileSetDatas[ts.ID]
+}
+
+// tileSetFileNames holds the base names of the tileset files, mapped from tile set ID.
+var tileSetFileNames = []string{"badlands", "platform", "install", "ashworld", "jungle", "desert", "ice", "twilight"}
+
+//go:embed tilesets
+var embeddedTileSets embed.FS
+
+// embeddedTileSetsErr is the error loading the embedded tileset data, reported by ComputeGrids().
+var embeddedTileSetsErr error
+
+func init() {
+	// Register embedded tileset data, if any. Missing files are skipped.
+	_, embeddedTileSetsErr = loadTileSetFS(embeddedTileSets, "tilesets")
+}
+
+// LoadTileSetDir loads and registers the tileset data of all tile sets found in the given folder
+// (e.g. the "tileset" folder extracted from a game installation).
+// File names are matched case-insensitively, e.g. "jungle.cv5" and "Jungle.vf4".
+// Returns the number of registered tile sets.
+func LoadTileSetDir(dir string) (count int, err error) {
+	return loadTileSetFS(os.DirFS(dir), ".")
+}
+
+// loadTileSetFS loads and registers the tileset data found in the given folder of fsys.
+func loadTileSetFS(fsys fs.FS, dir string) (count int, err error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return 0, err
+	}
+	// Map from lower-cased name to actual name
+	names := make(map[string]string, len(entries))
+	for _, e := range entries {
+		names[strings.ToLower(e.Name())] = e.Name()
+	}
+
+	for id, name := range tileSetFileNames {
+		cv5Name, vf4Name := names[name+".cv5"], names[name+".vf4"]
+		if cv5Name == "" || vf4Name == "" {
+			continue
+		}
+		cv5, err := fs.ReadFile(fsys, path.Join(dir, cv5Name))
+		if err != nil {
+			return count, err
+		}
+		vf4, err := fs.ReadFile(fsys, path.Join(dir, vf4Name))
+		if err != nil {
+			return count, err
+		}
+		tsd, err := NewTileSetData(cv5, vf4)
+		if err != nil {
+			return count, fmt.Errorf("%s: %w", name, err)
+		}
+		RegisterTileSetData(repcore.TileSetByID(uint16(id)), tsd)
+		count++
+	}
+
+	return count, nil
+}
+
+// groupFlags returns the flags of the given tile group.
+func (tsd *TileSetData) groupFlags(group int) uint16 {
+	pos := group * cv5EntrySize
+	if pos+cv5EntrySize > len(tsd.cv5) {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(tsd.cv5[pos+2:])
+}
+
+// megaTile returns the mega-tile index of the given tile value.
+// ok is false if the tile value is out of range.
+func (tsd *TileSetData) megaTile(tile uint16) (megaTile int, ok bool) {
+	group, variation := SplitTile(tile)
+	pos := group*cv5EntrySize + 20 + variation*2 // Mega-tile references start at offset 20
+	if pos+2 > len(tsd.cv5) {
+		return 0, false
+	}
+	megaTile = int(binary.LittleEndian.Uint16(tsd.cv5[pos:]))
+	return megaTile, (megaTile+1)*vf4EntrySize <= len(tsd.vf4)
+}
+
+// miniTileFlags returns the flags of the mini-tile (0..15 in row-major order)
+// of the given tile value.
+func (tsd *TileSetData) miniTileFlags(tile uint16, miniTile int) uint16 {
+	megaTile, ok := tsd.megaTile(tile)
+	if !ok {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(tsd.vf4[megaTile*vf4EntrySize+miniTile*2:])
+}
+
+// TileInfo resolves the given tile value (an element of rep.MapData.Tiles).
+// ok is false if the tile value is out of range of the tileset data.
+func (tsd *TileSetData) TileInfo(tile uint16) (ti TileInfo, ok bool) {
+	ti.Group, ti.Variation = SplitTile(tile)
+	if ti.MegaTile, ok = tsd.megaTile(tile); !ok {
+		return ti, false
+	}
+	ti.Terrain = tsd.terrainClass(tile)
+	for mt := 0; mt < 16; mt++ {
+		ti.Ramp = ti.Ramp || tsd.miniTileFlags(tile, mt)&vf4FlagRamp != 0
+	}
+	ti.Unbuildable = tsd.groupFlags(ti.Group)&cv5FlagUnbuildable != 0
+	return ti, true
+}
+
+// terrainClass returns the coarse terrain class of the given tile value:
+// the class of the majority of its mini-tiles.
+func (tsd *TileSetData) terrainClass(tile uint16) TerrainClass {
+	var counts [4]int
+	for mt := 0; mt < 16; mt++ {
+		switch flags := tsd.miniTileFlags(tile, mt); {
+		case flags&vf4FlagWalkable == 0:
+			counts[TerrainUnwalkable]++
+		case flags&vf4FlagHigh != 0:
+			counts[TerrainHigh]++
+		case flags&vf4FlagMid != 0:
+			counts[TerrainMid]++
+		default:
+			counts[TerrainLow]++
+		}
+	}
+
+	class := TerrainUnwalkable
+	for c, count := range counts {
+		if count > counts[class] {
+			class = TerrainClass(c)
+		}
+	}
+	return class
+}
+
+// ComputeGrids computes the walkability and buildability grids of the replay's map,
+// and stores them in MapData.Walkability and MapData.Buildability.
+// If the replay is computed, ground distances of Computed.MapAnalysis are also computed.
+//
+// Tileset data of the map's tile set must be registered, else an error wrapping
+// ErrNoTileSetData is returned; there is no fallback that would approximate the grids.
+func ComputeGrids(r *rep.Replay) error {
+	md := r.MapData
+	if md == nil || len(md.Tiles) == 0 {
+		return ErrNoMapData
+	}
+	tsd := TileSetDataOf(md.TileSet)
+	if tsd == nil {
+		if embeddedTileSetsErr != nil {
+			return fmt.Errorf("%w for tile set %v (loading embedded tileset data failed: %v)", ErrNoTileSetData, md.TileSet, embeddedTileSetsErr)
+		}
+		return fmt.Errorf("%w for tile set %v (tileset files must be provided, see LoadTileSetDir())", ErrNoTileSetData, md.TileSet)
+	}
+
+	w, h := int(r.Header.MapWidth), int(r.Header.MapHeight)
+	walk := rep.NewGrid(w*4, h*4)
+	build := rep.NewGrid(w, h)
+
+	for ty := 0; ty < h; ty++ {
+		for tx := 0; tx < w; tx++ {
+			i := ty*w + tx
+			if i >= len(md.Tiles) {
+				continue
+			}
+			tile := md.Tiles[i]
+			allWalkable := true
+			for mt := 0; mt < 16; mt++ {
+				walkable := tsd.miniTileFlags(tile, mt)&vf4FlagWalkable != 0
+				walk.Set(tx*4+mt%4, ty*4+mt/4, walkable)
+				allWalkable = allWalkable && walkable
+			}
+			build.Set(tx, ty, allWalkable && tsd.groupFlags(int(tile>>4))&cv5FlagUnbuildable == 0)
+		}
+	}
+
+	md.Walkability, md.Buildability = walk, build
+	if r.Computed != nil && r.Computed.MapAnalysis != nil {
+		r.Computed.MapAnalysis.ComputeGroundDistances(md)
+	}
+	return nil
+}
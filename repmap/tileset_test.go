@@ -0,0 +1,107 @@
+package repmap
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcore"
+)
+
+func TestLoadTileSetFS(t *testing.T) {
+	cv5 := make([]byte, cv5EntrySize)
+	vf4 := make([]byte, vf4EntrySize)
+	for i := 0; i < 16; i++ {
+		binary.LittleEndian.PutUint16(vf4[i*2:], vf4FlagWalkable)
+	}
+
+	fsys := fstest.MapFS{
+		"tileset/Jungle.cv5": {Data: cv5},
+		"tileset/jungle.VF4": {Data: vf4},
+		"tileset/ice.cv5":    {Data: cv5}, // Missing VF4, skipped
+	}
+	defer RegisterTileSetData(repcore.TileSetJungle, nil)
+
+	count, err := loadTileSetFS(fsys, "tileset")
+	if err != nil {
+		t.Fatalf("Failed to load tilesets: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected: %v, got: %v", 1, count)
+	}
+	if TileSetDataOf(repcore.TileSetJungle) == nil {
+		t.Errorf("Expected registered jungle tileset data")
+	}
+	if TileSetDataOf(repcore.TileSetArctic) != nil {
+		t.Errorf("Expected no arctic tileset data")
+	}
+
+	fsys["tileset/ice.vf4"] = &fstest.MapFile{Data: vf4[:3]}
+	if _, err := loadTileSetFS(fsys, "tileset"); err == nil {
+		t.Errorf("Expected error for invalid VF4")
+	}
+}
+
+func TestTileInfo(t *testing.T) {
+	tsd := newTestTileSetData(t, cv5FlagUnbuildable,
+		0,
+		vf4FlagWalkable|vf4FlagHigh,
+		vf4FlagWalkable|vf4FlagMid|vf4FlagRamp,
+	)
+
+	cases := []struct {
+		tile uint16
+		ti   TileInfo
+		ok   bool
+	}{
+		{0x0000, TileInfo{Terrain: TerrainUnwalkable, Unbuildable: true}, true},
+		{0x0001, TileInfo{Variation: 1, MegaTile: 1, Terrain: TerrainHigh, Unbuildable: true}, true},
+		{0x0002, TileInfo{Variation: 2, MegaTile: 2, Terrain: TerrainMid, Ramp: true, Unbuildable: true}, true},
+		{0x0010, TileInfo{Group: 1}, false}, // Group out of range
+	}
+	for _, c := range cases {
+		ti, ok := tsd.TileInfo(c.tile)
+		if ti != c.ti || ok != c.ok {
+			t.Errorf("[0x%04x] Expected: %+v %v, got: %+v %v", c.tile, c.ti, c.ok, ti, ok)
+		}
+	}
+}
+
+func TestComputeGrids(t *testing.T) {
+	ts := repcore.TileSetArctic
+	r := &rep.Replay{
+		Header:  &rep.Header{MapWidth: 2, MapHeight: 1},
+		MapData: &rep.MapData{TileSet: ts, Tiles: []uint16{0, 1}},
+	}
+	r.Computed = &rep.Computed{MapAnalysis: rep.NewMapAnalysis(r.MapData)}
+
+	// No tileset data registered (the repository has no tileset files):
+	if err := ComputeGrids(r); !errors.Is(err, ErrNoTileSetData) {
+		t.Errorf("Expected: %v, got: %v", ErrNoTileSetData, err)
+	}
+	if r.MapData.Walkability != nil || r.MapData.Buildability != nil {
+		t.Errorf("Expected no grids without tileset data")
+	}
+	if dm := r.Computed.MapAnalysis.DistanceMode; dm != repcore.DistanceModeAir {
+		t.Errorf("Expected: %v, got: %v", repcore.DistanceModeAir, dm)
+	}
+
+	RegisterTileSetData(ts, newTestTileSetData(t, 0, 0, vf4FlagWalkable))
+	defer RegisterTileSetData(ts, nil)
+
+	if err := ComputeGrids(r); err != nil {
+		t.Fatalf("Failed to compute grids: %v", err)
+	}
+	walk, build := r.MapData.Walkability, r.MapData.Buildability
+	if walk.Width != 8 || walk.Height != 4 || build.Width != 2 || build.Height != 1 {
+		t.Errorf("Expected sizes: 8x4 and 2x1, got: %dx%d and %dx%d", walk.Width, walk.Height, build.Width, build.Height)
+	}
+	if walk.At(3, 3) || !walk.At(4, 0) || build.At(0, 0) || !build.At(1, 0) {
+		t.Errorf("Expected tile 0 unwalkable and tile 1 walkable and buildable")
+	}
+	if dm := r.Computed.MapAnalysis.DistanceMode; dm != repcore.DistanceModeGround {
+		t.Errorf("Expected: %v, got: %v", repcore.DistanceModeGround, dm)
+	}
+}
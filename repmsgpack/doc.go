@@ -0,0 +1,21 @@
+/*
+
+Package repmsgpack implements MessagePack encoding and decoding with the same
+field semantics as the JSON encoding of the encoding/json package.
+
+Values are encoded as if they were marshaled to JSON (honoring struct tags and
+custom MarshalJSON methods, e.g. of repcmd.Bytes and time.Time), but
+in the MessagePack format: JSON objects become maps, arrays become arrays,
+numbers become integers (if they are integers) or float64 values.
+The order of object fields is kept.
+
+This makes the MessagePack output of a replay interchangeable with its JSON output
+(decoders of the MessagePack output see the same field names and values),
+while being considerably smaller and faster to decode.
+
+Decoding converts the MessagePack data to JSON, and unmarshals it with encoding/json,
+so types must support JSON unmarshaling (e.g. rep.Replay does not, as commands
+are interfaces, decode it into an any or a custom type).
+
+*/
+package repmsgpack
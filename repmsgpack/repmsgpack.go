@@ -0,0 +1,378 @@
+package repmsgpack
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// Marshal returns the MessagePack encoding of v (the JSON encoding of v in MessagePack format).
+func Marshal(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return FromJSON(data)
+}
+
+// FromJSON converts a JSON value to MessagePack.
+func FromJSON(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return appendValue(nil, dec)
+}
+
+// appendValue appends the MessagePack encoding of the next JSON value of dec.
+func appendValue(b []byte, dec *json.Decoder) ([]byte, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok := tok.(type) {
+	case json.Delim:
+		// The length of maps and arrays precedes the elements, so elements are encoded first
+		var body []byte
+		n := 0
+		for ; dec.More(); n++ {
+			if tok == '{' {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				body = appendString(body, key.(string))
+			}
+			if body, err = appendValue(body, dec); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := dec.Token(); err != nil { // Closing delimiter
+			return nil, err
+		}
+		if tok == '{' {
+			b = appendMapHeader(b, n)
+		} else {
+			b = appendArrayHeader(b, n)
+		}
+		return append(b, body...), nil
+	case string:
+		return appendString(b, tok), nil
+	case json.Number:
+		if i, err := strconv.ParseInt(tok.String(), 10, 64); err == nil {
+			return appendInt(b, i), nil
+		}
+		if u, err := strconv.ParseUint(tok.String(), 10, 64); err == nil {
+			return appendUint(b, u), nil
+		}
+		f, err := tok.Float64()
+		if err != nil {
+			return nil, err
+		}
+		return binary.BigEndian.AppendUint64(append(b, 0xcb), math.Float64bits(f)), nil
+	case bool:
+		if tok {
+			return append(b, 0xc3), nil
+		}
+		return append(b, 0xc2), nil
+	default: // nil
+		return append(b, 0xc0), nil
+	}
+}
+
+func appendUint(b []byte, u uint64) []byte {
+	switch {
+	case u <= 0x7f:
+		return append(b, byte(u))
+	case u <= math.MaxUint8:
+		return append(b, 0xcc, byte(u))
+	case u <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xcd), uint16(u))
+	case u <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, 0xce), uint32(u))
+	}
+	return binary.BigEndian.AppendUint64(append(b, 0xcf), u)
+}
+
+func appendInt(b []byte, i int64) []byte {
+	switch {
+	case i >= 0:
+		return appendUint(b, uint64(i))
+	case i >= -32:
+		return append(b, byte(i))
+	case i >= math.MinInt8:
+		return append(b, 0xd0, byte(i))
+	case i >= math.MinInt16:
+		return binary.BigEndian.AppendUint16(append(b, 0xd1), uint16(i))
+	case i >= math.MinInt32:
+		return binary.BigEndian.AppendUint32(append(b, 0xd2), uint32(i))
+	}
+	return binary.BigEndian.AppendUint64(append(b, 0xd3), uint64(i))
+}
+
+func appendString(b []byte, s string) []byte {
+	switch n := len(s); {
+	case n < 32:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		b = binary.BigEndian.AppendUint16(append(b, 0xda), uint16(n))
+	default:
+		b = binary.BigEndian.AppendUint32(append(b, 0xdb), uint32(n))
+	}
+	return append(b, s...)
+}
+
+func appendArrayHeader(b []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(b, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xdc), uint16(n))
+	}
+	return binary.BigEndian.AppendUint32(append(b, 0xdd), uint32(n))
+}
+
+func appendMapHeader(b []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(b, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xde), uint16(n))
+	}
+	return binary.BigEndian.AppendUint32(append(b, 0xdf), uint32(n))
+}
+
+// Unmarshal decodes the MessagePack data into v, the same way as
+// encoding/json would unmarshal the JSON equivalent of the data.
+func Unmarshal(data []byte, v any) error {
+	js, err := ToJSON(data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(js, v)
+}
+
+// ErrTruncated is returned if the MessagePack data is truncated.
+var ErrTruncated = errors.New("truncated MessagePack data")
+
+// ToJSON converts a MessagePack value to JSON.
+// Binary values are converted to base64 encoded strings (as encoding/json encodes []byte values),
+// extension types are not supported.
+func ToJSON(data []byte) ([]byte, error) {
+	d := &decoder{data: data}
+	buf := &bytes.Buffer{}
+	if err := d.value(buf); err != nil {
+		return nil, err
+	}
+	if len(d.data) > 0 {
+		return nil, fmt.Errorf("%d bytes of trailing data", len(d.data))
+	}
+	return buf.Bytes(), nil
+}
+
+// decoder decodes MessagePack data.
+type decoder struct {
+	data []byte
+}
+
+// next returns the next n bytes.
+func (d *decoder) next(n int) ([]byte, error) {
+	if n < 0 || len(d.data) < n {
+		return nil, ErrTruncated
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b, nil
+}
+
+// uint reads a big-endian unsigned integer of size bytes.
+func (d *decoder) uint(size int) (uint64, error) {
+	b, err := d.next(size)
+	if err != nil {
+		return 0, err
+	}
+	var u uint64
+	for _, v := range b {
+		u = u<<8 | uint64(v)
+	}
+	return u, nil
+}
+
+// value converts the next value to JSON.
+func (d *decoder) value(buf *bytes.Buffer) error {
+	b, err := d.next(1)
+	if err != nil {
+		return err
+	}
+
+	// size returns the size (length) encoded in n bytes (in case of non-fix types)
+	size := func(n int) (int, error) {
+		u, err := d.uint(n)
+		return int(u), err
+	}
+
+	switch c := b[0]; {
+	case c <= 0x7f:
+		buf.WriteString(strconv.Itoa(int(c)))
+	case c >= 0xe0:
+		buf.WriteString(strconv.Itoa(int(int8(c))))
+	case c&0xf0 == 0x80:
+		return d.object(buf, int(c&0x0f))
+	case c&0xf0 == 0x90:
+		return d.array(buf, int(c&0x0f))
+	case c&0xe0 == 0xa0:
+		return d.str(buf, int(c&0x1f))
+	case c == 0xc0:
+		buf.WriteString("null")
+	case c == 0xc2:
+		buf.WriteString("false")
+	case c == 0xc3:
+		buf.WriteString("true")
+	case c >= 0xc4 && c <= 0xc6: // bin 8, 16, 32
+		n, err := size(1 << (c - 0xc4))
+		if err != nil {
+			return err
+		}
+		bin, err := d.next(n)
+		if err != nil {
+			return err
+		}
+		buf.WriteByte('"')
+		buf.WriteString(base64.StdEncoding.EncodeToString(bin))
+		buf.WriteByte('"')
+	case c == 0xca, c == 0xcb: // float 32, 64
+		var f float64
+		if c == 0xca {
+			u, err := d.uint(4)
+			if err != nil {
+				return err
+			}
+			f = float64(math.Float32frombits(uint32(u)))
+		} else {
+			u, err := d.uint(8)
+			if err != nil {
+				return err
+			}
+			f = math.Float64frombits(u)
+		}
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return fmt.Errorf("unsupported float value: %v", f)
+		}
+		buf.WriteString(strconv.FormatFloat(f, 'g', -1, 64))
+	case c >= 0xcc && c <= 0xcf: // uint 8, 16, 32, 64
+		u, err := d.uint(1 << (c - 0xcc))
+		if err != nil {
+			return err
+		}
+		buf.WriteString(strconv.FormatUint(u, 10))
+	case c >= 0xd0 && c <= 0xd3: // int 8, 16, 32, 64
+		n := 1 << (c - 0xd0)
+		u, err := d.uint(n)
+		if err != nil {
+			return err
+		}
+		i := int64(u<<(64-8*n)) >> (64 - 8*n) // Sign extension
+		buf.WriteString(strconv.FormatInt(i, 10))
+	case c >= 0xd9 && c <= 0xdb: // str 8, 16, 32
+		n, err := size(1 << (c - 0xd9))
+		if err != nil {
+			return err
+		}
+		return d.str(buf, n)
+	case c == 0xdc, c == 0xdd: // array 16, 32
+		n, err := size(2 << (c - 0xdc))
+		if err != nil {
+			return err
+		}
+		return d.array(buf, n)
+	case c == 0xde, c == 0xdf: // map 16, 32
+		n, err := size(2 << (c - 0xde))
+		if err != nil {
+			return err
+		}
+		return d.object(buf, n)
+	default:
+		return fmt.Errorf("unsupported MessagePack type: 0x%02x", c)
+	}
+	return nil
+}
+
+// str converts a string of n bytes to JSON.
+func (d *decoder) str(buf *bytes.Buffer, n int) error {
+	s, err := d.next(n)
+	if err != nil {
+		return err
+	}
+	js, err := json.Marshal(string(s))
+	if err != nil {
+		return err
+	}
+	buf.Write(js)
+	return nil
+}
+
+// array converts an array of n elements to JSON.
+func (d *decoder) array(buf *bytes.Buffer, n int) error {
+	buf.WriteByte('[')
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if err := d.value(buf); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte(']')
+	return nil
+}
+
+// object converts a map of n entries to JSON. Keys must be strings.
+func (d *decoder) object(buf *bytes.Buffer, n int) error {
+	buf.WriteByte('{')
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		start := buf.Len()
+		if err := d.value(buf); err != nil {
+			return err
+		}
+		if buf.Bytes()[start] != '"' {
+			return errors.New("map keys must be strings")
+		}
+		buf.WriteByte(':')
+		if err := d.value(buf); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+// Encoder writes MessagePack values to an output stream.
+type Encoder struct {
+	w io.Writer
+}
+
+// NewEncoder returns a new encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes the MessagePack encoding of v to the stream.
+// Consecutive values are simply concatenated (as MessagePack streams are).
+func (enc *Encoder) Encode(v any) error {
+	data, err := Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = enc.w.Write(data)
+	return err
+}
@@ -0,0 +1,116 @@
+package repmsgpack
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcore"
+)
+
+func TestFromJSON(t *testing.T) {
+	cases := []struct {
+		json string
+		exp  []byte
+	}{
+		{`null`, []byte{0xc0}},
+		{`true`, []byte{0xc3}},
+		{`false`, []byte{0xc2}},
+		{`0`, []byte{0x00}},
+		{`127`, []byte{0x7f}},
+		{`128`, []byte{0xcc, 0x80}},
+		{`65536`, []byte{0xce, 0, 1, 0, 0}},
+		{`18446744073709551615`, []byte{0xcf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{`-1`, []byte{0xff}},
+		{`-33`, []byte{0xd0, 0xdf}},
+		{`-129`, []byte{0xd1, 0xff, 0x7f}},
+		{`1.5`, []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+		{`"ab"`, []byte{0xa2, 'a', 'b'}},
+		{`[1,"a"]`, []byte{0x92, 0x01, 0xa1, 'a'}},
+		{`{"b":1,"a":[]}`, []byte{0x82, 0xa1, 'b', 0x01, 0xa1, 'a', 0x90}},
+		{`"` + strings.Repeat("x", 40) + `"`, append([]byte{0xd9, 40}, strings.Repeat("x", 40)...)},
+	}
+
+	for _, c := range cases {
+		got, err := FromJSON([]byte(c.json))
+		if err != nil {
+			t.Errorf("[%s] Expected no error, got: %v", c.json, err)
+			continue
+		}
+		if !bytes.Equal(got, c.exp) {
+			t.Errorf("[%s] Expected: % x, got: % x", c.json, c.exp, got)
+		}
+		js, err := ToJSON(got)
+		if err != nil {
+			t.Errorf("[%s] Expected no error, got: %v", c.json, err)
+			continue
+		}
+		if string(js) != c.json {
+			t.Errorf("[%s] Expected: %s, got: %s", c.json, c.json, js)
+		}
+	}
+}
+
+func TestToJSON(t *testing.T) {
+	cases := []struct {
+		name string
+		data []byte
+		exp  string // Expected JSON, or error if starts with "error:"
+	}{
+		{"int 32", []byte{0xd2, 0xff, 0xff, 0xff, 0xfe}, `-2`},
+		{"float 32", []byte{0xca, 0x3f, 0xc0, 0, 0}, `1.5`},
+		{"bin 8", []byte{0xc4, 2, 1, 2}, `"AQI="`},
+		{"map 16", []byte{0xde, 0, 1, 0xa1, 'a', 0xc0}, `{"a":null}`},
+		{"truncated", []byte{0x92, 0x01}, "error:" + ErrTruncated.Error()},
+		{"non-string key", []byte{0x81, 0x01, 0x01}, "error:map keys must be strings"},
+		{"ext", []byte{0xd4, 0x01, 0x01}, "error:unsupported MessagePack type: 0xd4"},
+		{"trailing", []byte{0x01, 0x02}, "error:1 bytes of trailing data"},
+		{"NaN", []byte{0xcb, 0x7f, 0xf8, 0, 0, 0, 0, 0, 1}, fmt.Sprint("error:unsupported float value: ", math.NaN())},
+	}
+
+	for _, c := range cases {
+		js, err := ToJSON(c.data)
+		got := string(js)
+		if err != nil {
+			got = "error:" + err.Error()
+		}
+		if got != c.exp {
+			t.Errorf("[%s] Expected: %s, got: %s", c.name, c.exp, got)
+		}
+	}
+}
+
+func TestJSONSemantics(t *testing.T) {
+	h := &rep.Header{
+		Engine:    repcore.EngineBroodWar,
+		Frames:    12345,
+		StartTime: time.Unix(1600000000, 0).UTC(),
+		RawTitle:  "not encoded",
+		Players:   []*rep.Player{{Name: "Flash", Race: repcore.RaceTerran}},
+	}
+
+	data, err := Marshal(h)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	var fromMsgpack, fromJSON any
+	if err := Unmarshal(data, &fromMsgpack); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	js, _ := json.Marshal(h)
+	json.Unmarshal(js, &fromJSON)
+
+	exp, _ := json.Marshal(fromJSON)
+	got, _ := json.Marshal(fromMsgpack)
+	if string(exp) != string(got) {
+		t.Errorf("Expected: %s, got: %s", exp, got)
+	}
+	if len(data) >= len(js) {
+		t.Errorf("Expected smaller than JSON (%d bytes), got: %d bytes", len(js), len(data))
+	}
+}
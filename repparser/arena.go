@@ -0,0 +1,135 @@
+// This file contains the arena used to allocate commands in bulk.
+
+package repparser
+
+import (
+	"unicode/utf8"
+	"unsafe"
+
+	"github.com/icza/screp/rep/repcmd"
+)
+
+const (
+	// minSlabChunk is the size of the first chunk of slabs.
+	minSlabChunk = 16
+
+	// maxSlabChunk is the max size of chunks of slabs.
+	maxSlabChunk = 4096
+)
+
+// slab holds a chunk of values of type T from which values are handed out.
+// When the chunk is exhausted, a new chunk is allocated (doubling its size up to maxSlabChunk),
+// the old one is kept alive by the values handed out from it.
+type slab[T any] struct {
+	chunk []T
+}
+
+// grow allocates a new chunk if the current one has no room for n more values.
+func (s *slab[T]) grow(n int) {
+	if cap(s.chunk)-len(s.chunk) >= n {
+		return
+	}
+	size := min(max(2*cap(s.chunk), minSlabChunk), maxSlabChunk)
+	s.chunk = make([]T, 0, max(size, n))
+}
+
+// cmdArena is the arena of a commands section parsed with Config.Arena.
+// It holds a slab for each command type and for slices of commands.
+//
+// If the arena is not enabled, values are allocated individually.
+type cmdArena struct {
+	enabled bool
+
+	bases          slab[repcmd.Base]
+	rightClicks    slab[repcmd.RightClickCmd]
+	selects        slab[repcmd.SelectCmd]
+	hotkeys        slab[repcmd.HotkeyCmd]
+	trains         slab[repcmd.TrainCmd]
+	targetedOrders slab[repcmd.TargetedOrderCmd]
+	builds         slab[repcmd.BuildCmd]
+	queueables     slab[repcmd.QueueableCmd]
+	leaveGames     slab[repcmd.LeaveGameCmd]
+	minimapPings   slab[repcmd.MinimapPingCmd]
+	chats          slab[repcmd.ChatCmd]
+	visions        slab[repcmd.VisionCmd]
+	alliances      slab[repcmd.AllianceCmd]
+	gameSpeeds     slab[repcmd.GameSpeedCmd]
+	cancelTrains   slab[repcmd.CancelTrainCmd]
+	unloads        slab[repcmd.UnloadCmd]
+	liftOffs       slab[repcmd.LiftOffCmd]
+	techs          slab[repcmd.TechCmd]
+	upgrades       slab[repcmd.UpgradeCmd]
+	buildingMorphs slab[repcmd.BuildingMorphCmd]
+	latencies      slab[repcmd.LatencyCmd]
+	generals       slab[repcmd.GeneralCmd]
+	parseErrs      slab[repcmd.ParseErrCmd]
+
+	unitTags slab[repcmd.UnitTag]
+	bytes    slab[byte]
+}
+
+// alloc returns a pointer to a new value initialized to v, allocated from s if the arena is enabled.
+func alloc[T any](a *cmdArena, s *slab[T], v T) *T {
+	if !a.enabled {
+		p := new(T)
+		*p = v
+		return p
+	}
+	s.grow(1)
+	s.chunk = append(s.chunk, v)
+	return &s.chunk[len(s.chunk)-1]
+}
+
+// allocSlice returns a new zeroed slice of n elements, allocated from s if the arena is enabled.
+// The capacity of the slice is n, so appending to it does not overwrite other slices.
+func allocSlice[T any](a *cmdArena, s *slab[T], n int) []T {
+	if !a.enabled {
+		return make([]T, n)
+	}
+	s.grow(n)
+	l := len(s.chunk)
+	s.chunk = s.chunk[:l+n]
+	return s.chunk[l : l+n : l+n]
+}
+
+// slotIDs returns a copy of ids, nil if ids is empty.
+func (a *cmdArena) slotIDs(ids []byte) repcmd.Bytes {
+	if len(ids) == 0 {
+		return nil
+	}
+	bs := allocSlice(a, &a.bytes, len(ids))
+	copy(bs, ids)
+	return bs
+}
+
+// readSlice returns the next size bytes of sr as a slice.
+// If the arena is enabled, the slice borrows the data of sr (capped to the next size bytes),
+// and it is nil if there are less than size bytes available.
+func (a *cmdArena) readSlice(sr *sliceReader, size uint32) []byte {
+	if !a.enabled {
+		return sr.readSlice(size)
+	}
+	return sr.next(size)
+}
+
+// cString returns a 0x00 byte terminated string from data, see cStringEnc().
+// If the arena is enabled and the string is valid UTF-8, the string borrows data.
+func (a *cmdArena) cString(data []byte, hint *textEncoding) string {
+	if a.enabled {
+		str := data
+		for i, ch := range str {
+			if ch == 0 {
+				str = str[:i]
+				break
+			}
+		}
+		if utf8.Valid(str) {
+			if len(str) == 0 {
+				return ""
+			}
+			return unsafe.String(&str[0], len(str))
+		}
+	}
+	s, _, _, _ := cStringEnc(data, hint)
+	return s
+}
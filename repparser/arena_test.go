@@ -0,0 +1,67 @@
+package repparser
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcmd"
+)
+
+// testCmdsData returns the data of a commands section having many kinds of commands.
+func testCmdsData() []byte {
+	var cmds []byte
+	for frame := uint32(0); frame < 500; frame++ {
+		cmds = append(cmds, cmdBlock(frame,
+			[]byte{0, repcmd.TypeIDSelect, 2, 1, 0, 2, 0},
+			[]byte{0, repcmd.TypeIDRightClick, 10, 0, 20, 0, 0, 0, 0xe4, 0, 0},
+			[]byte{1, repcmd.TypeIDHotkey, 0, 1},
+			[]byte{1, repcmd.TypeIDTrain, 0x29, 0},
+			[]byte{1, repcmd.TypeIDVision, 0x03, 0},
+			[]byte{1, repcmd.TypeIDCheat, 1, 2, 3, 4},
+		)...)
+	}
+	cmds = append(cmds, cmdBlock(500, chatCmdData(1, "gl hf"))...)
+	return append(cmds, cmdBlock(501, []byte{1, repcmd.TypeIDAlliance, 0, 0, 0, 0})...)
+}
+
+func TestArena(t *testing.T) {
+	repData := buildReplay(t, testCmdsData(), buildCHK())
+
+	r1, err := ParseConfig(repData, Config{Commands: true})
+	if err != nil {
+		t.Fatalf("Failed to parse replay: %v", err)
+	}
+	r2, err := ParseConfig(repData, Config{Commands: true, Arena: true})
+	if err != nil {
+		t.Fatalf("Failed to parse replay: %v", err)
+	}
+
+	if len(r1.Commands.Cmds) != len(r2.Commands.Cmds) {
+		t.Fatalf("Expected: %d cmds, got: %d", len(r1.Commands.Cmds), len(r2.Commands.Cmds))
+	}
+	for i, cmd := range r1.Commands.Cmds {
+		if !reflect.DeepEqual(cmd, r2.Commands.Cmds[i]) {
+			t.Errorf("Expected: %+v, got: %+v", cmd, r2.Commands.Cmds[i])
+		}
+	}
+
+	// Appending to a slice of a command must not overwrite others
+	sel1, sel2 := r2.Commands.Cmds[0].(*repcmd.SelectCmd), r2.Commands.Cmds[6].(*repcmd.SelectCmd)
+	_ = append(sel1.UnitTags, 9)
+	if sel2.UnitTags[0] != 1 {
+		t.Errorf("Expected: %v, got: %v", 1, sel2.UnitTags[0])
+	}
+}
+
+func TestArenaAllocs(t *testing.T) {
+	data := testCmdsData()
+	allocs := func(arena bool) float64 {
+		return testing.AllocsPerRun(10, func() {
+			parseCommands(data, new(rep.Replay), Config{Arena: arena})
+		})
+	}
+	if regular, arena := allocs(false), allocs(true); arena*4 > regular {
+		t.Errorf("Expected at least 4 times fewer allocations, got: %v (regular: %v)", arena, regular)
+	}
+}
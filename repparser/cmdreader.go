@@ -0,0 +1,539 @@
+// This file contains the command reader which decodes commands on demand.
+
+package repparser
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"iter"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcmd"
+	"github.com/icza/screp/rep/repcore"
+)
+
+// CmdReader reads the commands of a commands section, decoding them on demand,
+// so consumers that stop early (e.g. analyzing openings) do not pay for decoding all commands.
+//
+// Commands that could not be parsed are returned as *repcmd.ParseErrCmd values
+// (see rep.Commands.ParseErrCmds), the commands following them in the same frame are skipped.
+//
+// A CmdReader is not safe for concurrent use.
+type CmdReader struct {
+	cfg Config
+	ar  *cmdArena
+	sr  sliceReader
+
+	// frame of the current command block, and the end position of the block
+	frame    uint32
+	blockEnd uint32
+
+	// cmdPos is the position of the last command read, typeID is its type ID
+	cmdPos uint32
+	typeID byte
+
+	// prev is the last successfully parsed command
+	prev repcmd.Cmd
+
+	// err is the error of reading, sticky
+	err error
+
+	// blockStats holds the statistics of command blocks not parsed cleanly, lazily allocated
+	blockStats *rep.CmdBlockStats
+
+	// slotEncodings holds the detected encodings of the names of the players by slot ID,
+	// preferred when decoding their chat messages
+	slotEncodings [12]*textEncoding
+}
+
+// NewCmdReader returns a new CmdReader reading the commands of the given (decoded) commands section data,
+// e.g. rep.Commands.Debug.Data. Config.Arena, Config.Logger and Config.Hooks.OnWarning are used.
+// data must not be modified while it is being read.
+func NewCmdReader(data []byte, cfg Config) *CmdReader {
+	return &CmdReader{cfg: cfg, ar: &cmdArena{enabled: cfg.Arena}, sr: sliceReader{b: data}}
+}
+
+// ParseConfigLazy parses an SC:BW replay from the given byte slice based on the given parser
+// configuration, except for the commands which are decoded on demand by the returned CmdReader
+// (Config.Commands is ignored, the returned replay has no Commands).
+// The commands section is decompressed, but none of its commands are decoded by this function.
+func ParseConfigLazy(repData []byte, cfg Config) (*rep.Replay, *CmdReader, error) {
+	var cmdsData []byte
+	onSection := cfg.Hooks.OnSection
+	cfg.Hooks.OnSection = func(s *Section, data []byte) {
+		if s == SectionCommands {
+			cmdsData = data // Section data is not reused by decoders, may be retained
+		}
+		if onSection != nil {
+			onSection(s, data)
+		}
+	}
+	cfg.Commands = false
+
+	r, err := ParseConfig(repData, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	cr := NewCmdReader(cmdsData, cfg)
+	cr.setPlayerEncodings(r.Header)
+	return r, cr, nil
+}
+
+// setPlayerEncodings sets the encodings of the names of the players of the header (if not nil),
+// see rep.Player.NameEncoding.
+func (cr *CmdReader) setPlayerEncodings(h *rep.Header) {
+	if h == nil {
+		return
+	}
+	for _, p := range h.Slots {
+		if int(p.SlotID) < len(cr.slotEncodings) {
+			cr.slotEncodings[p.SlotID] = textEncodingByName(p.NameEncoding)
+		}
+	}
+}
+
+// Next returns the next command. io.EOF is returned if there are no more commands.
+// A *ParseError wrapping ErrParsing is returned if the commands data is corrupt
+// (in which case no more commands can be read).
+func (cr *CmdReader) Next() (cmd repcmd.Cmd, err error) {
+	if cr.err != nil {
+		return nil, cr.err
+	}
+
+	// Malformed input is reported by next() as an error, this protects against implementation bugs.
+	defer func() {
+		if r := recover(); r != nil {
+			cr.cfg.logger().Error("Parsing error", "error", r)
+			cmd, err = nil, cr.parseError(nil, ErrParsing)
+		}
+		if pe, ok := err.(*ParseError); ok {
+			pe.Section = SectionCommands
+		}
+		if err != nil {
+			cr.err = err
+		}
+	}()
+
+	return cr.next()
+}
+
+// BlockStats returns the statistics of the command blocks read so far that were not parsed cleanly,
+// nil if all were parsed cleanly.
+func (cr *CmdReader) BlockStats() *rep.CmdBlockStats {
+	return cr.blockStats
+}
+
+// All returns an iterator over the remaining commands.
+// The iteration stops at the end of the commands or at the first error (other than io.EOF),
+// in which case the error is yielded with a nil command.
+func (cr *CmdReader) All() iter.Seq2[repcmd.Cmd, error] {
+	return func(yield func(repcmd.Cmd, error) bool) {
+		for {
+			cmd, err := cr.Next()
+			if err == io.EOF {
+				return
+			}
+			if !yield(cmd, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Seek positions the reader to the first command having a frame greater than or equal
+// to the given frame. Command blocks before it are skipped without decoding their commands
+// (only the headers of command blocks are read from the start of the data).
+// PrevCmd of a ParseErrCmd following a Seek() refers to commands read after the Seek() only.
+func (cr *CmdReader) Seek(frame repcore.Frame) {
+	b := cr.sr.b
+	var pos uint32
+	for int(pos)+5 <= len(b) {
+		if repcore.Frame(binary.LittleEndian.Uint32(b[pos:])) >= frame {
+			break
+		}
+		pos += 5 + uint32(b[pos+4]) // Frame, block size and the block
+	}
+	pos = min(pos, uint32(len(b))) // The last block may overrun the data
+
+	cr.sr.pos, cr.sr.err, cr.blockEnd, cr.frame = pos, nil, pos, 0
+	cr.prev, cr.err = nil, nil
+}
+
+// next reads the next command.
+// If the commands data is truncated, a *ParseError wrapping ErrParsing is returned.
+func (cr *CmdReader) next() (repcmd.Cmd, error) {
+	sr, ar, cfg := &cr.sr, cr.ar, cr.cfg
+
+	for sr.pos >= cr.blockEnd {
+		if sr.pos > cr.blockEnd {
+			// Bad parsing overran the block
+			cr.recordBlockIssue(cr.typeID).Overruns++
+			sr.pos = cr.blockEnd
+		}
+		if sr.pos >= uint32(len(sr.b)) {
+			return nil, io.EOF
+		}
+
+		// Command block in this frame
+		cr.frame = sr.getUint32()
+		cmdBlockSize := sr.getByte()                // cmd block size (remaining)
+		cr.blockEnd = sr.pos + uint32(cmdBlockSize) // Cmd block end position
+	}
+	cmdBlockEndPos := cr.blockEnd
+	cr.cmdPos = sr.pos
+
+	var cmd repcmd.Cmd
+	base := alloc(ar, &ar.bases, repcmd.Base{
+		Frame: repcore.Frame(cr.frame),
+	})
+	base.PlayerID = sr.getByte()
+	cr.typeID = sr.getByte()
+	base.Type = repcmd.TypeByID(cr.typeID)
+	if sr.err != nil {
+		return nil, cr.parseError(nil, fmt.Errorf("%w: %w", ErrParsing, sr.err))
+	}
+
+	switch base.Type.ID { // Try to list in frequency order:
+
+	case repcmd.TypeIDRightClick:
+		rccmd := alloc(ar, &ar.rightClicks, repcmd.RightClickCmd{Base: base})
+		rccmd.Pos.X = sr.getUint16()
+		rccmd.Pos.Y = sr.getUint16()
+		rccmd.UnitTag = repcmd.UnitTag(sr.getUint16())
+		rccmd.Unit = repcmd.UnitByID(sr.getUint16())
+		rccmd.Queued = sr.getByte() != 0
+		cmd = rccmd
+
+	case repcmd.TypeIDSelect, repcmd.TypeIDSelectAdd, repcmd.TypeIDSelectRemove:
+		count := sr.getByte()
+		selectCmd := alloc(ar, &ar.selects, repcmd.SelectCmd{
+			Base:     base,
+			UnitTags: allocSlice(ar, &ar.unitTags, int(count)),
+		})
+		for i := byte(0); i < count; i++ {
+			selectCmd.UnitTags[i] = repcmd.UnitTag(sr.getUint16())
+		}
+		cmd = selectCmd
+
+	case repcmd.TypeIDHotkey:
+		hotkeyCmd := alloc(ar, &ar.hotkeys, repcmd.HotkeyCmd{Base: base})
+		hotkeyCmd.HotkeyType = repcmd.HotkeyTypeByID(sr.getByte())
+		hotkeyCmd.Group = sr.getByte()
+		cmd = hotkeyCmd
+
+	case repcmd.TypeIDTrain, repcmd.TypeIDUnitMorph:
+		cmd = alloc(ar, &ar.trains, repcmd.TrainCmd{
+			Base: base,
+			Unit: repcmd.UnitByID(sr.getUint16()),
+		})
+
+	case repcmd.TypeIDTargetedOrder:
+		tocmd := alloc(ar, &ar.targetedOrders, repcmd.TargetedOrderCmd{Base: base})
+		tocmd.Pos.X = sr.getUint16()
+		tocmd.Pos.Y = sr.getUint16()
+		tocmd.UnitTag = repcmd.UnitTag(sr.getUint16())
+		tocmd.Unit = repcmd.UnitByID(sr.getUint16())
+		tocmd.Order = repcmd.OrderByID(sr.getByte())
+		tocmd.Queued = sr.getByte() != 0
+		cmd = tocmd
+
+	case repcmd.TypeIDBuild:
+		buildCmd := alloc(ar, &ar.builds, repcmd.BuildCmd{Base: base})
+		buildCmd.Order = repcmd.OrderByID(sr.getByte())
+		buildCmd.Pos.X = sr.getUint16()
+		buildCmd.Pos.Y = sr.getUint16()
+		buildCmd.Unit = repcmd.UnitByID(sr.getUint16())
+		if buildCmd.Order.ID == repcmd.OrderIDBuildingLand {
+			// It's actually a Land command:
+			landCmd := (*repcmd.LandCmd)(buildCmd) // Fields are identical, we may simply convert it
+			landCmd.Base.Type = repcmd.TypeLand
+			cmd = landCmd
+		} else {
+			// It's truly a build command
+			cmd = buildCmd
+		}
+
+	case repcmd.TypeIDStop, repcmd.TypeIDBurrow, repcmd.TypeIDUnburrow,
+		repcmd.TypeIDReturnCargo, repcmd.TypeIDHoldPosition, repcmd.TypeIDUnloadAll,
+		repcmd.TypeIDUnsiege, repcmd.TypeIDSiege, repcmd.TypeIDCloack, repcmd.TypeIDDecloack:
+		cmd = alloc(ar, &ar.queueables, repcmd.QueueableCmd{
+			Base:   base,
+			Queued: sr.getByte() != 0,
+		})
+
+	case repcmd.TypeIDLeaveGame:
+		cmd = alloc(ar, &ar.leaveGames, repcmd.LeaveGameCmd{
+			Base:   base,
+			Reason: repcmd.LeaveReasonByID(sr.getByte()),
+		})
+
+	case repcmd.TypeIDMinimapPing:
+		pingCmd := alloc(ar, &ar.minimapPings, repcmd.MinimapPingCmd{Base: base})
+		pingCmd.Pos.X = sr.getUint16()
+		pingCmd.Pos.Y = sr.getUint16()
+		cmd = pingCmd
+
+	case repcmd.TypeIDChat:
+		chatCmd := alloc(ar, &ar.chats, repcmd.ChatCmd{Base: base})
+		chatCmd.SenderSlotID = sr.getByte()
+		var hint *textEncoding
+		if int(chatCmd.SenderSlotID) < len(cr.slotEncodings) {
+			hint = cr.slotEncodings[chatCmd.SenderSlotID]
+		}
+		chatCmd.Message = ar.cString(ar.readSlice(sr, 80), hint)
+		cmd = chatCmd
+
+	case repcmd.TypeIDVision:
+		data := sr.getUint16()
+		visionCmd := alloc(ar, &ar.visions, repcmd.VisionCmd{
+			Base: base,
+		})
+		// There is 1 bit for each slot, 0x01: shared vision for that slot
+		var slotIDs [12]byte
+		n := 0
+		for i := byte(0); i < 12; i++ {
+			if data&0x01 != 0 {
+				slotIDs[n], n = i, n+1
+			}
+			data >>= 1
+		}
+		visionCmd.SlotIDs = ar.slotIDs(slotIDs[:n])
+		cmd = visionCmd
+
+	case repcmd.TypeIDAlliance:
+		data := sr.getUint32()
+		allianceCmd := alloc(ar, &ar.alliances, repcmd.AllianceCmd{
+			Base: base,
+		})
+		// There are 2 bits for each slot, 0x00: not allied, 0x1: allied, 0x02: allied victory
+		var slotIDs [11]byte
+		n := 0
+		for i := byte(0); i < 11; i++ { // only 11 slots, 12th is always 0x01 or 0x02
+			if x := data & 0x03; x != 0 {
+				slotIDs[n], n = i, n+1
+				if x == 2 {
+					allianceCmd.AlliedVictory = true
+				}
+			}
+			data >>= 2
+		}
+		allianceCmd.SlotIDs = ar.slotIDs(slotIDs[:n])
+		cmd = allianceCmd
+
+	case repcmd.TypeIDGameSpeed:
+		cmd = alloc(ar, &ar.gameSpeeds, repcmd.GameSpeedCmd{
+			Base:  base,
+			Speed: repcore.SpeedByID(sr.getByte()),
+		})
+
+	case repcmd.TypeIDCancelTrain:
+		cmd = alloc(ar, &ar.cancelTrains, repcmd.CancelTrainCmd{
+			Base:    base,
+			UnitTag: repcmd.UnitTag(sr.getUint16()),
+		})
+
+	case repcmd.TypeIDUnload:
+		cmd = alloc(ar, &ar.unloads, repcmd.UnloadCmd{
+			Base:    base,
+			UnitTag: repcmd.UnitTag(sr.getUint16()),
+		})
+
+	case repcmd.TypeIDLiftOff:
+		liftOffCmd := alloc(ar, &ar.liftOffs, repcmd.LiftOffCmd{Base: base})
+		liftOffCmd.Pos.X = sr.getUint16()
+		liftOffCmd.Pos.Y = sr.getUint16()
+		cmd = liftOffCmd
+
+	case repcmd.TypeIDTech:
+		cmd = alloc(ar, &ar.techs, repcmd.TechCmd{
+			Base: base,
+			Tech: repcmd.TechByID(sr.getByte()),
+		})
+
+	case repcmd.TypeIDUpgrade:
+		cmd = alloc(ar, &ar.upgrades, repcmd.UpgradeCmd{
+			Base:    base,
+			Upgrade: repcmd.UpgradeByID(sr.getByte()),
+		})
+
+	case repcmd.TypeIDBuildingMorph:
+		cmd = alloc(ar, &ar.buildingMorphs, repcmd.BuildingMorphCmd{
+			Base: base,
+			Unit: repcmd.UnitByID(sr.getUint16()),
+		})
+
+	case repcmd.TypeIDLatency:
+		cmd = alloc(ar, &ar.latencies, repcmd.LatencyCmd{
+			Base:    base,
+			Latency: repcmd.LatencyTypeByID(sr.getByte()),
+		})
+
+	case repcmd.TypeIDCheat:
+		cmd = alloc(ar, &ar.generals, repcmd.GeneralCmd{
+			Base: base,
+			Data: ar.readSlice(sr, 4),
+		})
+
+	case repcmd.TypeIDSaveGame, repcmd.TypeIDLoadGame:
+		sr.skip(sr.getUint32())
+
+	// NO ADDITIONAL DATA:
+
+	case repcmd.TypeIDKeepAlive:
+	case repcmd.TypeIDRestartGame:
+	case repcmd.TypeIDPause:
+	case repcmd.TypeIDResume:
+	case repcmd.TypeIDCancelBuild:
+	case repcmd.TypeIDCancelMorph:
+	case repcmd.TypeIDCarrierStop:
+	case repcmd.TypeIDReaverStop:
+	case repcmd.TypeIDOrderNothing:
+	case repcmd.TypeIDTrainFighter:
+	case repcmd.TypeIDMergeArchon:
+	case repcmd.TypeIDCancelNuke:
+	case repcmd.TypeIDCancelTech:
+	case repcmd.TypeIDCancelUpgrade:
+	case repcmd.TypeIDCancelAddon:
+	case repcmd.TypeIDStim:
+	case repcmd.TypeIDVoiceEnable:
+	case repcmd.TypeIDVoiceDisable:
+	case repcmd.TypeIDStartGame:
+	case repcmd.TypeIDBriefingStart:
+	case repcmd.TypeIDMergeDarkArchon:
+	case repcmd.TypeIDMakeGamePublic:
+
+	// DON'T CARE COMMANDS:
+
+	case repcmd.TypeIDSync:
+		sr.skip(6)
+	case repcmd.TypeIDVoiceSquelch:
+		sr.skip(1)
+	case repcmd.TypeIDVoiceUnsquelch:
+		sr.skip(1)
+	case repcmd.TypeIDDownloadPercentage:
+		sr.skip(1)
+	case repcmd.TypeIDChangeGameSlot:
+		sr.skip(5)
+	case repcmd.TypeIDNewNetPlayer:
+		sr.skip(7)
+	case repcmd.TypeIDJoinedGame:
+		sr.skip(17)
+	case repcmd.TypeIDChangeRace:
+		sr.skip(2)
+	case repcmd.TypeIDTeamGameTeam:
+		sr.skip(1)
+	case repcmd.TypeIDUMSTeam:
+		sr.skip(1)
+	case repcmd.TypeIDMeleeTeam:
+		sr.skip(2)
+	case repcmd.TypeIDSwapPlayers:
+		sr.skip(2)
+	case repcmd.TypeIDSavedData:
+		sr.skip(12)
+	case repcmd.TypeIDReplaySpeed:
+		sr.skip(9)
+
+	// New commands introduced in 1.21
+
+	case repcmd.TypeIDRightClick121:
+		rccmd := alloc(ar, &ar.rightClicks, repcmd.RightClickCmd{Base: base})
+		rccmd.Pos.X = sr.getUint16()
+		rccmd.Pos.Y = sr.getUint16()
+		rccmd.UnitTag = repcmd.UnitTag(sr.getUint16())
+		sr.getUint16() // Unknown, always 0?
+		rccmd.Unit = repcmd.UnitByID(sr.getUint16())
+		rccmd.Queued = sr.getByte() != 0
+		cmd = rccmd
+
+	case repcmd.TypeIDTargetedOrder121:
+		tocmd := alloc(ar, &ar.targetedOrders, repcmd.TargetedOrderCmd{Base: base})
+		tocmd.Pos.X = sr.getUint16()
+		tocmd.Pos.Y = sr.getUint16()
+		tocmd.UnitTag = repcmd.UnitTag(sr.getUint16())
+		sr.getUint16() // Unknown, always 0?
+		tocmd.Unit = repcmd.UnitByID(sr.getUint16())
+		tocmd.Order = repcmd.OrderByID(sr.getByte())
+		tocmd.Queued = sr.getByte() != 0
+		cmd = tocmd
+
+	case repcmd.TypeIDUnload121:
+		ucmd := alloc(ar, &ar.unloads, repcmd.UnloadCmd{Base: base})
+		ucmd.UnitTag = repcmd.UnitTag(sr.getUint16())
+		sr.getUint16() // Unknown, always 0?
+		cmd = ucmd
+
+	case repcmd.TypeIDSelect121, repcmd.TypeIDSelectAdd121, repcmd.TypeIDSelectRemove121:
+		count := sr.getByte()
+		selectCmd := alloc(ar, &ar.selects, repcmd.SelectCmd{
+			Base:     base,
+			UnitTags: allocSlice(ar, &ar.unitTags, int(count)),
+		})
+		for i := byte(0); i < count; i++ {
+			selectCmd.UnitTags[i] = repcmd.UnitTag(sr.getUint16())
+			sr.getUint16() // Unknown, always 0?
+		}
+		cmd = selectCmd
+
+	default:
+		// We don't know how to parse this command, we have to skip
+		// to the end of the command block
+		// (potentially skipping additional commands...)
+		var remBytes []byte
+		if sr.pos <= cmdBlockEndPos && cmdBlockEndPos <= uint32(len(sr.b)) { // Due to "bad" parsing these must be checked...
+			remBytes = sr.b[sr.pos:cmdBlockEndPos]
+		}
+		var remaining int64 // May be negative if we already read past the end of the block
+		if sr.pos > cmdBlockEndPos {
+			remaining = -int64(sr.pos - cmdBlockEndPos)
+		} else {
+			remaining = int64(cmdBlockEndPos - sr.pos)
+		}
+		cfg.warn("Skipping unknown command", "typeID", fmt.Sprintf("%#x", base.Type.ID), "frame", base.Frame,
+			"playerID", base.PlayerID, "remainingBytes", remaining, "bytes", fmt.Sprintf("% x", remBytes))
+		if remaining > 0 {
+			bs := cr.recordBlockIssue(base.Type.ID)
+			bs.UnreadBlocks++
+			bs.UnreadBytes += int(remaining)
+		}
+		pec := alloc(ar, &ar.parseErrs, repcmd.ParseErrCmd{Base: base, PrevCmd: cr.prev})
+		if len(remBytes) > 0 {
+			pec.SkippedBytes = ar.readSlice(sr, uint32(len(remBytes))) // Copied unless the arena is enabled
+		}
+		sr.pos = cmdBlockEndPos
+		return pec, nil
+	}
+
+	if sr.err != nil {
+		return nil, cr.parseError(base, fmt.Errorf("%w: %w", ErrParsing, sr.err))
+	}
+
+	if cmd == nil {
+		cmd = base
+	}
+	cr.prev = cmd
+	return cmd, nil
+}
+
+// recordBlockIssue records an issue of the current command block caused by the command
+// of the given type ID, and returns the block stats to record the kind of the issue.
+func (cr *CmdReader) recordBlockIssue(typeID byte) *rep.CmdBlockStats {
+	bs := cr.blockStats
+	if bs == nil {
+		bs = &rep.CmdBlockStats{TypeIDs: map[byte]int{}}
+		cr.blockStats = bs
+	}
+	bs.TypeIDs[typeID]++
+	frame := repcore.Frame(cr.frame)
+	if n := len(bs.Frames); n < rep.MaxCmdBlockStatsFrames && (n == 0 || bs.Frames[n-1] != frame) {
+		bs.Frames = append(bs.Frames, frame)
+	}
+	return bs
+}
+
+// parseError returns a *ParseError of the last command read.
+// base is the command being parsed, nil if unknown.
+// Section is not set (referring to SectionCommands here would be an initialization cycle).
+func (cr *CmdReader) parseError(base *repcmd.Base, err error) *ParseError {
+	return &ParseError{Offset: int(cr.cmdPos), Cmd: base, Err: err}
+}
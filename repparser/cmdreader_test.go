@@ -0,0 +1,188 @@
+package repparser
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcmd"
+	"github.com/icza/screp/rep/repcore"
+)
+
+func TestCmdReader(t *testing.T) {
+	cmdsData := append(testCmdsData(), cmdBlock(600, []byte{1, 0xff, 1, 2})...) // Unknown command
+	repData := buildReplay(t, cmdsData, buildCHK())
+
+	r, err := ParseConfig(repData, Config{Commands: true})
+	if err != nil {
+		t.Fatalf("Failed to parse replay: %v", err)
+	}
+
+	r2, cr, err := ParseConfigLazy(repData, Config{Commands: true})
+	if err != nil {
+		t.Fatalf("Failed to parse replay: %v", err)
+	}
+	if r2.Commands != nil || r2.Header.Host != "Alice" {
+		t.Errorf("Expected no commands and parsed header, got: %v, %v", r2.Commands, r2.Header)
+	}
+
+	var cmds []repcmd.Cmd
+	var pecs []*repcmd.ParseErrCmd
+	for cmd, err := range cr.All() {
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if pec, ok := cmd.(*repcmd.ParseErrCmd); ok {
+			pecs = append(pecs, pec)
+		} else {
+			cmds = append(cmds, cmd)
+		}
+	}
+	if !reflect.DeepEqual(cmds, r.Commands.Cmds) || !reflect.DeepEqual(pecs, r.Commands.ParseErrCmds) {
+		t.Errorf("Expected same commands as parsed, got: %d, %d", len(cmds), len(pecs))
+	}
+	if _, err := cr.Next(); err != io.EOF {
+		t.Errorf("Expected: %v, got: %v", io.EOF, err)
+	}
+
+	cases := []struct {
+		frame repcore.Frame
+		exp   repcore.Frame // Expected frame of the next command, -1 for EOF
+	}{
+		{250, 250},
+		{0, 0},
+		{499, 499},
+		{502, 600},
+		{601, -1},
+	}
+	for _, c := range cases {
+		cr.Seek(c.frame)
+		cmd, err := cr.Next()
+		got := repcore.Frame(-1)
+		if err == nil {
+			got = cmd.BaseCmd().Frame
+		}
+		if got != c.exp {
+			t.Errorf("Expected: %v, got: %v (err: %v)", c.exp, got, err)
+		}
+	}
+}
+
+func TestCmdReaderCorrupt(t *testing.T) {
+	cmds := append(cmdBlock(5, []byte{0, repcmd.TypeIDHotkey, 0, 1}), cmdBlock(10, []byte{0, repcmd.TypeIDSelect, 5, 1, 0})...)
+	cr := NewCmdReader(cmds, Config{})
+	cr.Seek(10)
+	for range 2 { // Error is sticky
+		if _, err := cr.Next(); !errors.Is(err, ErrParsing) {
+			t.Errorf("Expected: %v, got: %v", ErrParsing, err)
+		}
+	}
+
+	// Seek clears the error:
+	cr.Seek(0)
+	if cmd, err := cr.Next(); err != nil || cmd.BaseCmd().Type != repcmd.TypeHotkey {
+		t.Errorf("Expected: Hotkey, got: %v, %v", cmd, err)
+	}
+}
+
+func TestCmdReaderTruncatedSkip(t *testing.T) {
+	cr := NewCmdReader(cmdBlock(10, []byte{0, repcmd.TypeIDSync, 1, 2}), Config{}) // Sync has 6 bytes
+	if _, err := cr.Next(); !errors.Is(err, ErrParsing) {
+		t.Errorf("Expected: %v, got: %v", ErrParsing, err)
+	}
+}
+
+func TestCmdReaderUnknownOverrun(t *testing.T) {
+	// The type of the unknown command is read from the next block (frame 0xff):
+	cmds := append(cmdBlock(10, []byte{0}), cmdBlock(0xff, []byte{0, repcmd.TypeIDHotkey, 0, 1})...)
+	var remaining []any
+	cfg := Config{Hooks: Hooks{OnWarning: func(msg string, args ...any) {
+		for i := 0; i+1 < len(args); i += 2 {
+			if args[i] == "remainingBytes" {
+				remaining = append(remaining, args[i+1])
+			}
+		}
+	}}}
+	cr := NewCmdReader(cmds, cfg)
+	if cmd, err := cr.Next(); err != nil || cmd.BaseCmd().Type.ID != 0xff {
+		t.Errorf("Expected: unknown command, got: %v, %v", cmd, err)
+	}
+	if exp := []any{int64(-1)}; !reflect.DeepEqual(remaining, exp) {
+		t.Errorf("Expected: %v, got: %v", exp, remaining)
+	}
+}
+
+func TestParseTruncatedCommands(t *testing.T) {
+	cmds := cmdBlock(10, []byte{0, repcmd.TypeIDHotkey, 0, 1}, chatCmdData(1, "gl hf"))
+	repData := buildReplay(t, cmds[:len(cmds)-20], buildCHK())
+
+	var warnings []string
+	cfg := Config{Commands: true, Hooks: Hooks{OnWarning: func(msg string, args ...any) {
+		warnings = append(warnings, msg)
+	}}}
+	r, err := ParseConfig(repData, cfg)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(r.Commands.Cmds) != 1 {
+		t.Errorf("Expected: %v cmds, got: %v", 1, len(r.Commands.Cmds))
+	}
+	if exp := []string{"Truncated commands data"}; !reflect.DeepEqual(warnings, exp) {
+		t.Errorf("Expected: %v, got: %v", exp, warnings)
+	}
+}
+
+func TestCmdBlockStats(t *testing.T) {
+	overrun := cmdBlock(10, []byte{0, repcmd.TypeIDHotkey, 0}) // Hotkey command reads 1 more byte
+	cmds := slices.Concat(overrun, cmdBlock(20, []byte{1, 0xff, 1, 2}), cmdBlock(30, []byte{0, repcmd.TypeIDHotkey, 0, 1}))
+	r, err := ParseConfig(buildReplay(t, cmds, buildCHK()), Config{Commands: true})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	exp := &rep.CmdBlockStats{
+		Overruns:     1,
+		UnreadBlocks: 1,
+		UnreadBytes:  2,
+		TypeIDs:      map[byte]int{repcmd.TypeIDHotkey: 1, 0xff: 1},
+		Frames:       []repcore.Frame{10, 20},
+	}
+	if got := r.Commands.BlockStats; !reflect.DeepEqual(got, exp) {
+		t.Errorf("Expected: %+v, got: %+v", exp, got)
+	}
+	if n := len(r.Commands.Cmds); n != 2 {
+		t.Errorf("Expected: %v cmds, got: %v", 2, n)
+	}
+
+	// Clean blocks:
+	r, err = ParseConfig(buildReplay(t, testCmdsData(), buildCHK()), Config{Commands: true})
+	if err != nil || r.Commands.BlockStats != nil {
+		t.Errorf("Expected: no error and no block stats, got: %v, %+v", err, r.Commands.BlockStats)
+	}
+}
+
+func TestParseErrCmdSkippedBytes(t *testing.T) {
+	// Unknown command followed by a hotkey command in the same block, both are skipped:
+	cmds := cmdBlock(20, []byte{1, 0xfe, 1, 2}, []byte{1, repcmd.TypeIDHotkey, 0, 1})
+	repData := buildReplay(t, cmds, buildCHK())
+
+	for _, arena := range []bool{false, true} {
+		r, err := ParseConfig(repData, Config{Commands: true, Arena: arena})
+		if err != nil {
+			t.Fatalf("[arena: %v] Expected no error, got: %v", arena, err)
+		}
+		if n := len(r.Commands.ParseErrCmds); n != 1 {
+			t.Fatalf("[arena: %v] Expected: %v parse error cmds, got: %v", arena, 1, n)
+		}
+		pec := r.Commands.ParseErrCmds[0]
+		if pec.Type.ID != 0xfe || pec.PlayerID != 1 || pec.Frame != 20 {
+			t.Errorf("[arena: %v] Expected: %#x %v %v, got: %#x %v %v", arena, 0xfe, 1, 20, pec.Type.ID, pec.PlayerID, pec.Frame)
+		}
+		if exp := (repcmd.Bytes{1, 2, 1, repcmd.TypeIDHotkey, 0, 1}); !slices.Equal(pec.SkippedBytes, exp) {
+			t.Errorf("[arena: %v] Expected: %v, got: %v", arena, exp, pec.SkippedBytes)
+		}
+	}
+}
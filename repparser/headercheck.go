@@ -0,0 +1,58 @@
+// This file contains the sanity checks of the header values.
+
+package repparser
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcore"
+)
+
+const (
+	// maxMapSize is the max width and height of maps (in tiles).
+	maxMapSize = 256
+
+	// maxStartTimeAhead is how much StartTime may be ahead of the current time
+	// (clocks of computers may be off).
+	maxStartTimeAhead = 24 * time.Hour
+)
+
+// minStartTime is the release date of StarCraft, games cannot start before it.
+var minStartTime = time.Date(1998, 3, 31, 0, 0, 0, 0, time.UTC)
+
+// addHeaderWarning attaches a warning about the given field to the header, and logs it.
+func addHeaderWarning(h *rep.Header, cfg Config, field, format string, args ...any) {
+	details := fmt.Sprintf(format, args...)
+	h.Warnings = append(h.Warnings, &rep.HeaderWarning{Field: field, Details: details})
+	cfg.warn("Implausible header value", "field", field, "details", details)
+}
+
+// checkHeader checks the plausibility of the header values which do not depend on other sections.
+// Values depending on other sections are checked when those are parsed (see parseCommands()
+// and parseMapData()).
+func checkHeader(h *rep.Header, cfg Config) {
+	players := 0
+	for _, p := range h.OrigPlayers {
+		if p.Type == repcore.PlayerTypeHuman || p.Type == repcore.PlayerTypeComputer {
+			players++
+		}
+	}
+	if int(h.AvailSlotsCount) < players || int(h.AvailSlotsCount) > len(h.Slots) {
+		addHeaderWarning(h, cfg, "AvailSlotsCount", "available slots: %d, players: %d", h.AvailSlotsCount, players)
+	}
+
+	for _, size := range []struct {
+		field string
+		value uint16
+	}{{"MapWidth", h.MapWidth}, {"MapHeight", h.MapHeight}} {
+		if size.value == 0 || size.value > maxMapSize {
+			addHeaderWarning(h, cfg, size.field, "map size: %s", h.MapSize())
+		}
+	}
+
+	if h.StartTime.Before(minStartTime) || h.StartTime.After(time.Now().Add(maxStartTimeAhead)) {
+		addHeaderWarning(h, cfg, "StartTime", "start time: %s", h.StartTime.UTC().Format(time.RFC3339))
+	}
+}
@@ -0,0 +1,83 @@
+package repparser
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcmd"
+	"github.com/icza/screp/rep/repcore"
+)
+
+func TestCheckHeader(t *testing.T) {
+	valid := func() *rep.Header {
+		return &rep.Header{
+			StartTime:       time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			MapWidth:        128,
+			MapHeight:       96,
+			AvailSlotsCount: 2,
+			Slots:           make([]*rep.Player, 12),
+			OrigPlayers: []*rep.Player{
+				{Type: repcore.PlayerTypeHuman},
+				{Type: repcore.PlayerTypeComputer},
+			},
+		}
+	}
+
+	cases := []struct {
+		name   string
+		modify func(h *rep.Header)
+		exp    []string // Expected warning fields
+	}{
+		{"valid", func(h *rep.Header) {}, nil},
+		{"less slots than players", func(h *rep.Header) { h.AvailSlotsCount = 1 }, []string{"AvailSlotsCount"}},
+		{"more slots than slots", func(h *rep.Header) { h.AvailSlotsCount = 13 }, []string{"AvailSlotsCount"}},
+		{"zero map width", func(h *rep.Header) { h.MapWidth = 0 }, []string{"MapWidth"}},
+		{"huge map", func(h *rep.Header) { h.MapWidth, h.MapHeight = 512, 512 }, []string{"MapWidth", "MapHeight"}},
+		{"zero start time", func(h *rep.Header) { h.StartTime = time.Unix(0, 0) }, []string{"StartTime"}},
+		{"future start time", func(h *rep.Header) { h.StartTime = time.Now().Add(48 * time.Hour) }, []string{"StartTime"}},
+	}
+
+	for _, c := range cases {
+		h := valid()
+		c.modify(h)
+		checkHeader(h, Config{})
+		var fields []string
+		for _, w := range h.Warnings {
+			fields = append(fields, w.Field)
+		}
+		if !reflect.DeepEqual(fields, c.exp) {
+			t.Errorf("[%s] Expected: %v, got: %v", c.name, c.exp, fields)
+		}
+	}
+}
+
+func TestHeaderWarnings(t *testing.T) {
+	dim := binary.LittleEndian.AppendUint16(nil, 128)
+	dim = binary.LittleEndian.AppendUint16(dim, 64)
+	// Command after the last frame (1000) of the header, and a map larger than the header's (64x64):
+	cmds := cmdBlock(2000, []byte{0, repcmd.TypeIDHotkey, 0, 1})
+	repData := buildReplay(t, cmds, buildCHK(chkSection{"DIM ", dim}))
+
+	var warnings []string
+	cfg := Config{Commands: true, MapData: true, Hooks: Hooks{OnWarning: func(msg string, args ...any) {
+		warnings = append(warnings, msg)
+	}}}
+	r, err := ParseConfig(repData, cfg)
+	if err != nil {
+		t.Fatalf("Failed to parse replay: %v", err)
+	}
+
+	var fields []string
+	for _, w := range r.Header.Warnings {
+		fields = append(fields, w.Field)
+	}
+	if exp := []string{"Frames", "MapWidth"}; !reflect.DeepEqual(fields, exp) {
+		t.Errorf("Expected: %v, got: %v", exp, fields)
+	}
+	if exp := []string{"Implausible header value", "Implausible header value"}; !reflect.DeepEqual(warnings, exp) {
+		t.Errorf("Expected: %v, got: %v", exp, warnings)
+	}
+}
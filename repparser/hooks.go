@@ -0,0 +1,45 @@
+// This file contains the parsing hooks.
+
+package repparser
+
+import (
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcmd"
+)
+
+// UnknownSectionID is the ID of the Section passed to Hooks.OnSection for
+// modern sections unknown to the parser (e.g. custom sections of 3rd party vendors).
+const UnknownSectionID = -1
+
+// Hooks holds optional callbacks called during parsing, so applications may collect
+// custom data (e.g. statistics) in a single pass, without post-processing the returned Replay.
+//
+// Callbacks are called synchronously, in the order of the data in the replay.
+// Values passed to them must not be modified.
+type Hooks struct {
+	// OnSection is called with the data of each section before it is processed, also for sections
+	// not parsed due to the configuration. For modern sections unknown to the parser, a Section
+	// having UnknownSectionID and the section's StrID is passed.
+	// data is only valid during the call, it must not be retained.
+	OnSection func(s *Section, data []byte)
+
+	// OnPlayer is called with each player (in slot order) once the header is parsed.
+	// Names and colors of players may be updated by later sections of modern replays.
+	OnPlayer func(p *rep.Player)
+
+	// OnCommand is called with each parsed command, also with the *repcmd.ParseErrCmd
+	// of commands that could not be parsed.
+	OnCommand func(cmd repcmd.Cmd)
+
+	// OnWarning is called with each warning of the parser, with the same message and
+	// key-value pairs as logged to Config.Logger.
+	OnWarning func(msg string, args ...any)
+}
+
+// warn logs a warning and calls the OnWarning hook.
+func (cfg Config) warn(msg string, args ...any) {
+	cfg.logger().Warn(msg, args...)
+	if cfg.Hooks.OnWarning != nil {
+		cfg.Hooks.OnWarning(msg, args...)
+	}
+}
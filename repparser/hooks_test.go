@@ -0,0 +1,57 @@
+package repparser
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcmd"
+	"github.com/icza/screp/repparser/repencoder"
+)
+
+func TestHooks(t *testing.T) {
+	cmds := cmdBlock(10, []byte{0, repcmd.TypeIDHotkey, 0, 1}, chatCmdData(1, "gl hf"))
+	cmds = append(cmds, cmdBlock(20, []byte{1, 0xff, 1, 2})...)                              // Unknown command
+	custom := &repencoder.Section{StrID: 0x44434241, Data: []byte("vendor data"), Raw: true} // "ABCD"
+	repData := buildReplay(t, cmds, buildCHK(), custom)
+
+	var sections, players, commands, warnings []string
+	cfg := Config{Commands: true, Hooks: Hooks{
+		OnSection: func(s *Section, data []byte) {
+			sections = append(sections, fmt.Sprintf("%d%s:%d", s.ID, s.StrID, len(data)))
+		},
+		OnPlayer: func(p *rep.Player) {
+			players = append(players, p.Name)
+		},
+		OnCommand: func(cmd repcmd.Cmd) {
+			commands = append(commands, fmt.Sprintf("%d:%s", cmd.BaseCmd().Frame, cmd.BaseCmd().Type.Name))
+		},
+		OnWarning: func(msg string, args ...any) {
+			warnings = append(warnings, msg)
+		},
+	}}
+	r, err := ParseConfig(repData, cfg)
+	if err != nil {
+		t.Fatalf("Failed to parse replay: %v", err)
+	}
+
+	cases := []struct {
+		name     string
+		exp, got []string
+	}{
+		{"sections", []string{"0:4", "1:633", fmt.Sprintf("2:%d", len(cmds)), "3:0", "4:768", "10Sbat:11", "-1ABCD:11"}, sections},
+		{"players", []string{"Alice", "Bob"}, players},
+		{"commands", []string{"10:Hotkey", "10:Chat", "20:Unknown 0xff"}, commands},
+		{"warnings", []string{"Skipping unknown command", "Unknown modern section"}, warnings},
+	}
+	for _, c := range cases {
+		if !slices.Equal(c.exp, c.got) {
+			t.Errorf("[%s] Expected: %v, got: %v", c.name, c.exp, c.got)
+		}
+	}
+
+	if len(r.Commands.Cmds) != 2 || len(r.Commands.ParseErrCmds) != 1 {
+		t.Errorf("Expected: 2 cmds, 1 parse error cmd, got: %d, %d", len(r.Commands.Cmds), len(r.Commands.ParseErrCmds))
+	}
+}
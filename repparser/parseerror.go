@@ -0,0 +1,70 @@
+// This file contains the error type describing where parsing failed.
+
+package repparser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/icza/screp/rep/repcmd"
+)
+
+// ParseError describes where parsing a replay failed, so bug reports and automated triage
+// can pinpoint the exact location. Errors of parsing are returned as *ParseError values
+// (use errors.As() to access it), wrapping the cause, so errors.Is() can be used to check
+// for e.g. ErrNotReplayFile or ErrParsing.
+type ParseError struct {
+	// Section being parsed, nil if unknown (e.g. a modern section could not be decoded)
+	Section *Section
+
+	// Offset is the byte offset in the (decoded) section data, -1 if unknown
+	Offset int
+
+	// Cmd is the command being parsed if the error occurred in the commands section,
+	// nil if unknown. Only its Frame, PlayerID and Type fields are set.
+	Cmd *repcmd.Base
+
+	// Err is the cause of the error
+	Err error
+}
+
+// Error returns the error message, prefixed with the location of the error (if known).
+func (pe *ParseError) Error() string {
+	var loc []string
+	if pe.Section != nil {
+		loc = append(loc, fmt.Sprintf("section %d (%s)", pe.Section.ID, pe.Section.Name()))
+	}
+	if pe.Offset >= 0 {
+		loc = append(loc, fmt.Sprintf("offset %#x", pe.Offset))
+	}
+	if pe.Cmd != nil {
+		loc = append(loc, fmt.Sprintf("frame %d", pe.Cmd.Frame), fmt.Sprintf("player %d", pe.Cmd.PlayerID))
+		if pe.Cmd.Type != nil {
+			loc = append(loc, "command "+pe.Cmd.Type.Name)
+		}
+	}
+	if len(loc) == 0 {
+		return pe.Err.Error()
+	}
+	return strings.Join(loc, ", ") + ": " + pe.Err.Error()
+}
+
+// Unwrap returns the cause of the error.
+func (pe *ParseError) Unwrap() error {
+	return pe.Err
+}
+
+// sectionNames holds the names of Sections.
+var sectionNames = []string{"replay ID", "header", "commands", "map data", "player names"}
+
+// Name returns the name of the section: its StrID if it has one, else a descriptive name
+// (e.g. "commands").
+func (s *Section) Name() string {
+	if s.StrID != "" {
+		return s.StrID
+	}
+	if s.ID >= 0 && s.ID < len(sectionNames) {
+		return sectionNames[s.ID]
+	}
+	return "unknown"
+}
@@ -0,0 +1,55 @@
+package repparser
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/icza/screp/rep/repcmd"
+)
+
+func TestParseError(t *testing.T) {
+	cases := []struct {
+		name string
+		pe   *ParseError
+		exp  string
+	}{
+		{"no location", &ParseError{Offset: -1, Err: ErrParsing}, "parsing"},
+		{"section", &ParseError{Section: SectionHeader, Offset: -1, Err: ErrParsing}, "section 1 (header): parsing"},
+		{"modern section", &ParseError{Section: ModernSections[1398033740], Offset: 4, Err: ErrParsing}, "section 6 (LMTS), offset 0x4: parsing"},
+		{"command", &ParseError{Section: SectionCommands, Offset: 0x10, Err: ErrParsing,
+			Cmd: &repcmd.Base{Frame: 10, PlayerID: 1, Type: repcmd.TypeChat}},
+			"section 2 (commands), offset 0x10, frame 10, player 1, command Chat: parsing"},
+	}
+
+	for _, c := range cases {
+		if got := c.pe.Error(); got != c.exp {
+			t.Errorf("[%s] Expected: %v, got: %v", c.name, c.exp, got)
+		}
+		if !errors.Is(c.pe, ErrParsing) {
+			t.Errorf("[%s] Expected: %v, got: %v", c.name, ErrParsing, c.pe)
+		}
+	}
+}
+
+func TestParseErrorLocation(t *testing.T) {
+	cmds := cmdBlock(10, []byte{0, repcmd.TypeIDHotkey, 0, 1}, chatCmdData(1, "gl hf"))
+	cr := NewCmdReader(cmds[:len(cmds)-20], Config{})
+	cr.Next()
+	_, err := cr.Next()
+
+	var pe *ParseError
+	if !errors.As(err, &pe) {
+		t.Fatalf("Expected: *ParseError, got: %T", err)
+	}
+	if pe.Section != SectionCommands || pe.Offset != 9 || pe.Cmd == nil || pe.Cmd.Frame != 10 || pe.Cmd.Type != repcmd.TypeChat {
+		t.Errorf("Unexpected location: %v", pe)
+	}
+
+	_, err = ParseConfig([]byte("not a replay, just some text of at least 30 bytes"), Config{})
+	if !errors.As(err, &pe) || !errors.Is(err, ErrNotReplayFile) {
+		t.Fatalf("Expected: *ParseError wrapping %v, got: %v", ErrNotReplayFile, err)
+	}
+	if pe.Section != SectionReplayID {
+		t.Errorf("Expected: %v, got: %v", SectionReplayID, pe.Section)
+	}
+}
@@ -11,5 +11,11 @@ SC BW replays are basically divided into 2 types:
 The type detection and utilization of the proper decoder is automatic
 and transparent to the package user.
 
+If built with the mmap build tag (on unix-like systems), replay files are memory-mapped
+instead of read, which avoids copying the data of very large replays, and makes scanning
+many files in parallel cheap:
+
+	go build -tags mmap ./...
+
 */
 package repdecoder
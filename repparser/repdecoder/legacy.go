@@ -161,6 +161,28 @@ func (d *legacyDecoder) Section(size int32) (result []byte, sectionID int32, err
 	return result, sectionID, nil
 }
 
+// Explode decompresses PKWARE Data Compression Library compressed data (binary mode only)
+// into dst, e.g. sectors of MPQ archives. dst must be big enough to hold the decompressed data.
+// Returns the number of decompressed bytes.
+func Explode(src, dst []byte) (n int, err error) {
+	if len(src) < 4 {
+		return 0, ErrInvalidCompressedData
+	}
+
+	d := &legacyDecoder{}
+	d.initEsi()
+	rep := &d.esi.m24
+	rep.src = src
+	rep.m10 = int32(len(src))
+	rep.m08 = dst
+	rep.m14 = int32(len(dst))
+
+	if d.repSection() != 0 || rep.m0C > rep.m14 {
+		return 0, ErrInvalidCompressedData
+	}
+	return int(rep.m0C), nil
+}
+
 // initEsi initializes (zeroes) the esi struct.
 func (d *legacyDecoder) initEsi() {
 	if d.esi.data == nil {
@@ -0,0 +1,51 @@
+package repdecoder
+
+import "testing"
+
+// implodeLiterals encodes the given data in PKWARE DCL binary mode using literals only.
+// There is no end marker: the stream is padded so the decoder has its 8 bits look-ahead
+// for the last literal, but not enough bits for another one.
+func implodeLiterals(data []byte) []byte {
+	out := []byte{0, 4} // Binary mode, dictionary size bits
+	var acc, bits uint32
+	for _, b := range data {
+		acc |= uint32(b) << (bits + 1) // Flag bit 0 means literal
+		bits += 9
+		for bits >= 8 {
+			out = append(out, byte(acc))
+			acc >>= 8
+			bits -= 8
+		}
+	}
+	for size := 2 + (len(data)*9+8+7)/8; len(out) < size; acc >>= 8 {
+		out = append(out, byte(acc))
+	}
+	return out
+}
+
+func TestExplode(t *testing.T) {
+	data := []byte("StarCraft: Brood War scenario data")
+
+	cases := []struct {
+		name string
+		src  []byte
+		n    int
+		err  error
+	}{
+		{"literals", implodeLiterals(data), len(data), nil},
+		{"too short", []byte{0, 4}, 0, ErrInvalidCompressedData},
+		{"ascii mode", append([]byte{1}, implodeLiterals(data)[1:]...), 0, ErrInvalidCompressedData},
+		{"invalid dictionary", append([]byte{0, 7}, implodeLiterals(data)[2:]...), 0, ErrInvalidCompressedData},
+	}
+	for _, c := range cases {
+		dst := make([]byte, 100)
+		n, err := Explode(c.src, dst)
+		if n != c.n || err != c.err {
+			t.Errorf("[%s] Expected: %v %v, got: %v %v", c.name, c.n, c.err, n, err)
+			continue
+		}
+		if string(dst[:n]) != string(data[:n]) {
+			t.Errorf("[%s] Expected: %q, got: %q", c.name, data[:n], dst[:n])
+		}
+	}
+}
@@ -0,0 +1,46 @@
+//go:build mmap && (linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+// This file contains the memory-mapped input of NewFromFile(), enabled by the mmap build tag.
+
+package repdecoder
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"syscall"
+)
+
+// Mmap tells if NewFromFile() memory-maps replay files (if screp is built with the mmap build tag).
+const Mmap = true
+
+// mappedReader reads a memory-mapped file, which is unmapped when closed.
+type mappedReader struct {
+	*bytes.Buffer
+	data []byte
+}
+
+// Close unmaps the file.
+func (mr *mappedReader) Close() error {
+	if mr.data == nil {
+		return nil
+	}
+	data := mr.data
+	mr.Buffer, mr.data = bytes.NewBuffer(nil), nil
+	return syscall.Munmap(data)
+}
+
+// newFileReader returns a reader of the given file having the given size,
+// and tells if the returned reader reads the file memory-mapped.
+// The file is closed if it is mapped (the mapping remains valid until the returned reader is closed).
+func newFileReader(f *os.File, size int64) (r io.Reader, mapped bool, err error) {
+	if size <= 0 || int64(int(size)) != size {
+		return f, false, nil // Empty files can't be mapped
+	}
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, false, err
+	}
+	f.Close()
+	return &mappedReader{Buffer: bytes.NewBuffer(data), data: data}, true, nil
+}
@@ -9,7 +9,10 @@ package repdecoder
 import (
 	"bytes"
 	"compress/zlib"
+	"errors"
 	"io"
+	"runtime"
+	"sync"
 )
 
 // modernDecoder is the Decoder implementation for modern replays.
@@ -39,7 +42,12 @@ func (d *modernDecoder) Section(size int32) (result []byte, sectionID int32, err
 		sizeHint := knownModernSectionIDSizeHints[sectionID]
 		if sizeHint == 0 {
 			// It's not a known, SCR section, but some custom section.
-			// Don't assume anything about its format, return the raw data:
+			// Don't assume anything about its format, return the raw data
+			// (referencing mapped files, in-memory sources are owned by the caller so copied):
+			if d.mapped {
+				result, err = d.readSlice(rawSize, false)
+				return
+			}
 			result = make([]byte, rawSize)
 			_, err = io.ReadFull(d.r, result)
 			return
@@ -55,6 +63,30 @@ func (d *modernDecoder) Section(size int32) (result []byte, sectionID int32, err
 	resBuf := bytes.NewBuffer(make([]byte, 0, size))
 
 	var zr io.ReadCloser // zlib reader
+	defer func() {
+		if zr != nil {
+			releaseZlibReader(zr)
+		}
+	}()
+
+	if count >= minParallelChunks {
+		// Chunks are independent, decompress them concurrently:
+		var chunks [][]byte
+		if chunks, err = d.readChunks(count); err != nil {
+			return nil, sectionID, err
+		}
+		var ok bool
+		if result, ok, err = decodeChunksParallel(chunks, size); ok || err != nil {
+			return result, sectionID, err
+		}
+		// Irregular chunks, decode them sequentially:
+		for _, chunk := range chunks {
+			if err = appendChunk(resBuf, chunk, &zr); err != nil {
+				return nil, sectionID, err
+			}
+		}
+		return resBuf.Bytes(), sectionID, nil
+	}
 
 	for ; count > 0; count-- {
 		var length int32 // compressed length of the chunk
@@ -62,35 +94,166 @@ func (d *modernDecoder) Section(size int32) (result []byte, sectionID int32, err
 			return
 		}
 
-		if int32(len(d.buf)) < length {
-			d.buf = make([]byte, length)
-		}
-		compressed := d.buf[:length]
-		if _, err = io.ReadFull(d.r, compressed); err != nil {
+		var chunk []byte
+		if chunk, err = d.readSlice(length, true); err != nil {
 			return nil, sectionID, err
 		}
-		if length > 4 && compressed[0] == 0x78 { // Is it compressed? (0x78 zlib magic)
-			if resetter, ok := zr.(zlib.Resetter); ok {
-				err = resetter.Reset(bytes.NewBuffer(compressed), nil)
-			} else {
-				zr, err = zlib.NewReader(bytes.NewBuffer(compressed))
-				if zr != nil {
-					defer zr.Close()
-				}
-			}
-			if err != nil {
-				return nil, sectionID, err
-			}
-			if _, err = io.Copy(resBuf, zr); err != nil {
-				return nil, sectionID, err
-			}
-		} else {
-			// it's not compressed
-			if _, err = resBuf.Write(compressed); err != nil {
-				return
-			}
+		if err = appendChunk(resBuf, chunk, &zr); err != nil {
+			return nil, sectionID, err
 		}
 	}
 
 	return resBuf.Bytes(), sectionID, nil
 }
+
+// readChunks reads the given number of chunks of a section.
+// Chunks are not read into the general buffer, so they remain valid.
+func (d *modernDecoder) readChunks(count int32) (chunks [][]byte, err error) {
+	chunks = make([][]byte, 0, min(count, 1024)) // count is not trusted
+	for ; count > 0; count-- {
+		var length int32 // compressed length of the chunk
+		if length, err = d.readInt32(); err != nil {
+			return
+		}
+		var chunk []byte
+		if chunk, err = d.readSlice(length, false); err != nil {
+			return
+		}
+		chunks = append(chunks, chunk)
+	}
+	return
+}
+
+// isCompressed tells if a chunk is compressed (starts with the 0x78 zlib magic).
+func isCompressed(chunk []byte) bool {
+	return len(chunk) > 4 && chunk[0] == 0x78
+}
+
+// appendChunk decodes a chunk and appends the result to dst.
+// The zlib reader zr is created or reset if needed.
+func appendChunk(dst *bytes.Buffer, chunk []byte, zr *io.ReadCloser) (err error) {
+	if !isCompressed(chunk) {
+		_, err = dst.Write(chunk)
+		return
+	}
+
+	if *zr != nil {
+		err = (*zr).(zlib.Resetter).Reset(bytes.NewBuffer(chunk), nil)
+	} else {
+		*zr, err = newZlibReader(bytes.NewBuffer(chunk))
+	}
+	if err != nil {
+		return
+	}
+	_, err = io.Copy(dst, *zr)
+	return
+}
+
+// chunkSize is the size of the decoded data of chunks (except for the last chunk of sections).
+const chunkSize = 0x2000
+
+// minParallelChunks is the min number of chunks of a section to decompress them concurrently.
+const minParallelChunks = 4
+
+// errIrregularChunk is returned by decodeChunk() if the decoded size of a chunk is not the expected one.
+var errIrregularChunk = errors.New("irregular chunk")
+
+// decodeChunksParallel decodes the chunks of a section of the given size concurrently,
+// directly into their place in the result (each chunk except for the last one holds chunkSize bytes).
+// ok is false if the decoded size of the chunks is not regular, in which case they have to be
+// decoded sequentially.
+func decodeChunksParallel(chunks [][]byte, size int32) (result []byte, ok bool, err error) {
+	count := len(chunks)
+	if count == 0 || int64(count-1)*chunkSize >= int64(size) || int64(count)*chunkSize < int64(size) {
+		return nil, false, nil
+	}
+
+	result = make([]byte, size)
+	errs := make([]error, count)
+
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+	var wg sync.WaitGroup
+	for i, chunk := range chunks {
+		dst := result[i*chunkSize:]
+		if len(dst) > chunkSize {
+			dst = dst[:chunkSize]
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			errs[i] = decodeChunk(dst, chunk)
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err == errIrregularChunk {
+			return nil, false, nil
+		}
+		if err != nil {
+			return nil, true, err
+		}
+	}
+	return result, true, nil
+}
+
+// decodeChunk decodes a chunk into dst, which must be exactly the size of the decoded chunk
+// (else errIrregularChunk is returned).
+func decodeChunk(dst, chunk []byte) error {
+	if !isCompressed(chunk) {
+		if len(chunk) != len(dst) {
+			return errIrregularChunk
+		}
+		copy(dst, chunk)
+		return nil
+	}
+
+	zr, err := newZlibReader(bytes.NewReader(chunk))
+	if err != nil {
+		return err
+	}
+	defer releaseZlibReader(zr)
+
+	if _, err := io.ReadFull(zr, dst); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return errIrregularChunk
+		}
+		return err
+	}
+	// The chunk must end here (reading till the end also verifies the checksum):
+	var b [1]byte
+	switch n, err := io.ReadFull(zr, b[:]); {
+	case n > 0:
+		return errIrregularChunk
+	case err != io.EOF:
+		return err
+	}
+	return nil
+}
+
+// zlibReaderPool is the pool of zlib readers (implementing zlib.Resetter),
+// so decoding many replays does not allocate new decompressors.
+var zlibReaderPool sync.Pool
+
+// newZlibReader returns a zlib reader reading from r, obtained from zlibReaderPool if possible.
+// The returned reader should be released with releaseZlibReader().
+func newZlibReader(r io.Reader) (io.ReadCloser, error) {
+	if zr, ok := zlibReaderPool.Get().(io.ReadCloser); ok {
+		if err := zr.(zlib.Resetter).Reset(r, nil); err != nil {
+			zlibReaderPool.Put(zr)
+			return nil, err
+		}
+		return zr, nil
+	}
+	return zlib.NewReader(r)
+}
+
+// releaseZlibReader closes the zlib reader and puts it into zlibReaderPool.
+func releaseZlibReader(zr io.ReadCloser) {
+	zr.Close()
+	zlibReaderPool.Put(zr)
+}
@@ -0,0 +1,68 @@
+package repdecoder
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"slices"
+	"testing"
+)
+
+// compress returns data compressed with zlib.
+func compress(data []byte) []byte {
+	buf := &bytes.Buffer{}
+	zw := zlib.NewWriter(buf)
+	zw.Write(data)
+	zw.Close()
+	return buf.Bytes()
+}
+
+// sectionData returns the encoded data of a section having the given chunks.
+func sectionData(chunks ...[]byte) []byte {
+	data := binary.LittleEndian.AppendUint32(nil, 0) // Checksum
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(chunks)))
+	for _, c := range chunks {
+		data = binary.LittleEndian.AppendUint32(data, uint32(len(c)))
+		data = append(data, c...)
+	}
+	return data
+}
+
+func TestSectionChunks(t *testing.T) {
+	var data []byte
+	for i := range 5*chunkSize + 100 {
+		data = append(data, byte(i*i>>7))
+	}
+	chunk := func(i int) []byte {
+		if i == 5 {
+			return data[i*chunkSize:] // Last chunk
+		}
+		return data[i*chunkSize : (i+1)*chunkSize]
+	}
+
+	small := []byte("small chunks of irregular size")
+
+	cases := []struct {
+		name   string
+		chunks [][]byte
+		size   int
+		exp    []byte
+		expErr bool
+	}{
+		{"sequential", [][]byte{compress(chunk(0)), chunk(1)}, 2 * chunkSize, data[:2*chunkSize], false},
+		{"parallel", [][]byte{compress(chunk(0)), chunk(1), compress(chunk(2)), compress(chunk(3)), compress(chunk(4)), compress(chunk(5))}, len(data), data, false},
+		{"irregular", [][]byte{compress(small), compress(small), small, compress(small)}, 4 * len(small), bytes.Repeat(small, 4), false},
+		{"overrun", [][]byte{compress(chunk(0)), compress(data[:chunkSize+1]), compress(chunk(2)), compress(chunk(3))}, 4 * chunkSize, slices.Concat(chunk(0), data[:chunkSize+1], chunk(2), chunk(3)), false},
+		{"corrupt", [][]byte{compress(chunk(0)), compress(chunk(1)), {0x78, 1, 2, 3, 4, 5}, compress(chunk(3))}, 4 * chunkSize, nil, true},
+	}
+
+	for _, c := range cases {
+		d := newDecoder(bytes.NewBuffer(sectionData(c.chunks...)), RepFormatModern, false)
+		d.NewSection()
+		got, _, err := d.Section(int32(c.size))
+		if (err != nil) != c.expErr || !bytes.Equal(got, c.exp) {
+			t.Errorf("[%s] Expected: %d bytes (error: %v), got: %d bytes (error: %v)", c.name, len(c.exp), c.expErr, len(got), err)
+		}
+		d.Close()
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !mmap || !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+// This file contains the file input of NewFromFile() if memory-mapping is not enabled.
+
+package repdecoder
+
+import (
+	"io"
+	"os"
+)
+
+// Mmap tells if NewFromFile() memory-maps replay files (if screp is built with the mmap build tag).
+const Mmap = false
+
+// newFileReader returns a reader of the given file having the given size,
+// and tells if the returned reader reads the file memory-mapped.
+func newFileReader(f *os.File, size int64) (r io.Reader, mapped bool, err error) {
+	return f, false, nil
+}
@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sync"
 )
 
 var (
@@ -27,6 +28,9 @@ var (
 
 	// ErrNoMoreSections is returned by Decoder.NewSection() if there are no more sections.
 	ErrNoMoreSections = errors.New("no more sections")
+
+	// ErrInvalidCompressedData is returned by Explode() if the compressed data is invalid or unsupported.
+	ErrInvalidCompressedData = errors.New("invalid compressed data")
 )
 
 // Decoder wraps a Section method for decoding a section of a given size.
@@ -47,6 +51,11 @@ type Decoder interface {
 
 // NewFromFile creates a new Decoder that reads and decompresses data form a
 // file.
+//
+// If screp is built with the mmap build tag (see Mmap), the file is memory-mapped:
+// the data of raw sections returned by Decoder.Section() references the mapped file,
+// which is only valid until the decoder is closed. The file must not be truncated
+// while the decoder is open.
 func NewFromFile(name string) (d Decoder, err error) {
 	var f *os.File
 	f, err = os.Open(name)
@@ -81,7 +90,12 @@ func NewFromFile(name string) (d Decoder, err error) {
 		}
 	}
 
-	return newDecoder(f, rf), nil
+	r, mapped, err := newFileReader(f, stat.Size())
+	if err != nil {
+		return
+	}
+
+	return newDecoder(r, rf, mapped), nil
 }
 
 // New creates a new Decoder that reads and decompresses data from the
@@ -92,7 +106,7 @@ func New(repData []byte) Decoder {
 		rf = detectRepFormat(repData[:30])
 	}
 
-	return newDecoder(bytes.NewBuffer(repData), rf)
+	return newDecoder(bytes.NewBuffer(repData), rf, false)
 }
 
 // RepFormat identifies the replay format
@@ -139,15 +153,33 @@ func detectRepFormat(fileHeader []byte) RepFormat {
 	return RepFormatModern
 }
 
+// bufSize is the size of the general buffer of decoders (the legacy format relies on it).
+const bufSize = 0x2000 // 8 KB buffer
+
+// maxPooledBufSize is the max size of general buffers put back into bufPool.
+const maxPooledBufSize = 1 << 20
+
+// bufPool is the pool of general buffers of decoders (*[]byte values),
+// so decoding many replays does not allocate a buffer for each.
+var bufPool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, bufSize)
+		return &buf
+	},
+}
+
 // newDecoder creates a new Decoder that reads and decompresses data from the given Reader.
 // The source is treated as a modern replay if modern is true, else as a
-// legacy replay.
-func newDecoder(r io.Reader, rf RepFormat) Decoder {
+// legacy replay. mapped tells if r reads a memory-mapped file.
+func newDecoder(r io.Reader, rf RepFormat, mapped bool) Decoder {
+	bufp := bufPool.Get().(*[]byte)
 	dec := decoder{
 		r:        r,
 		rf:       rf,
 		int32Buf: make([]byte, 4),
-		buf:      make([]byte, 0x2000), // 8 KB buffer
+		buf:      (*bufp)[:bufSize], // Modern decoders may have grown it
+		bufp:     bufp,
+		mapped:   mapped,
 	}
 
 	switch rf {
@@ -179,6 +211,12 @@ type decoder struct {
 
 	// buf is a general buffer (re)used in decoding several sections
 	buf []byte
+
+	// bufp is the pointer of buf obtained from bufPool, nil if buf has been released
+	bufp *[]byte
+
+	// mapped tells if r reads a memory-mapped file
+	mapped bool
 }
 
 func (d *decoder) RepFormat() RepFormat {
@@ -195,6 +233,48 @@ func (d *decoder) readInt32() (n int32, err error) {
 	return
 }
 
+// inMemory is implemented by in-memory sources (e.g. *bytes.Buffer),
+// which can return their next bytes without copying.
+type inMemory interface {
+	Len() int
+	Next(n int) []byte
+}
+
+// readSlice reads the next n bytes from the underlying Reader.
+// If the source is in memory (see inMemory), the returned slice references the source.
+// Else the bytes are read into buf (grown if needed) if reuse is true, or into a new slice.
+func (d *decoder) readSlice(n int32, reuse bool) ([]byte, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("invalid data size: %d", n)
+	}
+
+	if im, ok := d.r.(inMemory); ok {
+		if im.Len() < int(n) {
+			err := io.ErrUnexpectedEOF
+			if im.Len() == 0 {
+				err = io.EOF
+			}
+			im.Next(im.Len())
+			return nil, err
+		}
+		return im.Next(int(n)), nil
+	}
+
+	var data []byte
+	if reuse {
+		if int32(cap(d.buf)) < n {
+			d.buf = make([]byte, n)
+		}
+		data = d.buf[:n]
+	} else {
+		data = make([]byte, n)
+	}
+	if _, err := io.ReadFull(d.r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
 func (d *decoder) NewSection() (err error) {
 	d.sectionsCounter++
 
@@ -233,8 +313,17 @@ func (d *decoder) sectionHeader(size int32) (count int32, result []byte, err err
 	return
 }
 
-// Close closes the underlying io.Reader if it implements io.Closer.
+// Close releases the buffers of the decoder, and closes the underlying io.Reader
+// if it implements io.Closer.
 func (d *decoder) Close() error {
+	if d.bufp != nil {
+		if cap(d.buf) <= maxPooledBufSize {
+			*d.bufp = d.buf
+			bufPool.Put(d.bufp)
+		}
+		d.buf, d.bufp = nil, nil
+	}
+
 	if closer, ok := d.r.(io.Closer); ok {
 		return closer.Close()
 	}
@@ -0,0 +1,115 @@
+package repdecoder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/icza/screp/repparser/repencoder"
+)
+
+// decodeAll decodes all sections of a modern replay.
+func decodeAll(d Decoder) (sections [][]byte, err error) {
+	sizes := []int32{4, 0x279, 0, 0, 0x300} // Replay ID, header, commands, map data, player names
+	for i := 0; ; i++ {
+		if err = d.NewSection(); err != nil {
+			return
+		}
+		var size int32
+		if i < len(sizes) {
+			if size = sizes[i]; size == 0 {
+				var sizeData []byte
+				if sizeData, _, err = d.Section(4); err != nil {
+					return
+				}
+				size = int32(binary.LittleEndian.Uint32(sizeData))
+			}
+		}
+		data, _, err := d.Section(size)
+		if err == io.EOF {
+			return sections, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		sections = append(sections, data)
+	}
+}
+
+func TestNewFromFile(t *testing.T) {
+	sections := []*repencoder.Section{
+		{Data: bytes.Repeat([]byte{1}, 0x279)},
+		{Data: bytes.Repeat([]byte{2, 3}, 0x3000)}, // Multiple chunks
+		{Data: []byte("map data")},
+		{Data: bytes.Repeat([]byte{4}, 0x300)},
+		{StrID: 0x44434241, Data: []byte("vendor data"), Raw: true}, // "ABCD"
+	}
+	buf := &bytes.Buffer{}
+	if err := repencoder.Encode(buf, sections); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	name := filepath.Join(t.TempDir(), "a.rep")
+	if err := os.WriteFile(name, buf.Bytes(), 0o644); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	d, err := NewFromFile(name)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	defer d.Close()
+	if mapped := d.(*modernDecoder).mapped; mapped != Mmap {
+		t.Errorf("Expected: %v, got: %v", Mmap, mapped)
+	}
+
+	got, err := decodeAll(d)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	exp := [][]byte{[]byte("reRS")}
+	for _, s := range sections {
+		exp = append(exp, s.Data)
+	}
+	if !reflect.DeepEqual(exp, got) {
+		t.Errorf("Expected: %v, got: %v", exp, got)
+	}
+}
+
+func TestReadSlice(t *testing.T) {
+	data := []byte{1, 2, 3, 4, 5}
+
+	cases := []struct {
+		name     string
+		r        io.Reader
+		n        int32
+		exp      []byte
+		expErr   error
+		expSame  bool // Tells if the result must reference data
+		expReuse bool // Tells if the result must be in buf
+	}{
+		{"in memory", bytes.NewBuffer(data), 3, data[:3], nil, true, false},
+		{"in memory EOF", bytes.NewBuffer(data), 6, nil, io.ErrUnexpectedEOF, false, false},
+		{"reader", struct{ io.Reader }{bytes.NewReader(data)}, 3, data[:3], nil, false, true},
+		{"reader EOF", struct{ io.Reader }{bytes.NewReader(data)}, 6, nil, io.ErrUnexpectedEOF, false, false},
+	}
+
+	for _, c := range cases {
+		d := newDecoder(c.r, RepFormatModern, false).(*modernDecoder)
+		got, err := d.readSlice(c.n, true)
+		if err != c.expErr || !bytes.Equal(got, c.exp) {
+			t.Errorf("[%s] Expected: %v %v, got: %v %v", c.name, c.exp, c.expErr, got, err)
+			continue
+		}
+		if same := len(got) > 0 && &got[0] == &data[0]; same != c.expSame {
+			t.Errorf("[%s] Expected same: %v, got: %v", c.name, c.expSame, same)
+		}
+		if reuse := len(got) > 0 && &got[0] == &d.buf[0]; reuse != c.expReuse {
+			t.Errorf("[%s] Expected reuse: %v, got: %v", c.name, c.expReuse, reuse)
+		}
+		d.Close()
+	}
+}
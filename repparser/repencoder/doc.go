@@ -0,0 +1,12 @@
+/*
+
+Package repencoder implements encoding StarCraft Brood War replay files (*.rep).
+
+Replays are always encoded in the modern (1.18 - 1.20) format: section data
+is split into chunks compressed with zlib.
+
+The input is the uncompressed section data, e.g. as returned by
+repparser.DecodeSections(), so tools can rewrite (parts of) replays.
+
+*/
+package repencoder
@@ -0,0 +1,152 @@
+/*
+
+This file contains the replay encoder.
+
+*/
+
+package repencoder
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+)
+
+// Section is a section of a replay.
+type Section struct {
+	// StrID is the string ID of sections added in modern replays
+	// (e.g. "SKIN" as a little-endian int32), 0 for the base sections.
+	StrID int32
+
+	// Data is the uncompressed data of the section
+	Data []byte
+
+	// Raw tells if Data is to be written as-is, without chunking and compression.
+	// Used for custom modern sections whose format is unknown.
+	Raw bool
+}
+
+// replayID is the content of the replay ID section of modern (pre 1.21) replays.
+var replayID = []byte("reRS")
+
+// chunkSize is the max size of the uncompressed data of a chunk.
+const chunkSize = 0x2000
+
+// Encode writes a replay built from the given sections to w.
+//
+// The replay ID section is written by Encode, sections must hold the base
+// sections in order (header, commands, map data and optionally player names)
+// followed by the modern sections (having non-zero StrID).
+// The size of commands and map data is written by Encode.
+//
+// Replays of 1.21+ are also written in the pre 1.21 format, which screp parses the same way.
+func Encode(w io.Writer, sections []*Section) error {
+	buf := &bytes.Buffer{}
+
+	writeSection(buf, replayID)
+	for i, s := range sections {
+		if s.StrID == 0 {
+			if i == 1 || i == 2 { // Commands and map data are preceded by their size
+				writeSection(buf, binary.LittleEndian.AppendUint32(nil, uint32(len(s.Data))))
+			}
+			writeSection(buf, s.Data)
+			continue
+		}
+
+		data := s.Data
+		if !s.Raw {
+			sbuf := &bytes.Buffer{}
+			writeSection(sbuf, s.Data)
+			data = sbuf.Bytes()
+		}
+		writeInt32(buf, uint32(s.StrID))
+		writeInt32(buf, uint32(len(data)))
+		buf.Write(data)
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// EncodeLegacy writes a legacy (pre 1.18) replay built from the given sections to w.
+//
+// The replay ID section is written by EncodeLegacy, sections must hold the base
+// sections in order (header, commands and map data), other sections are not
+// supported by the legacy format and are ignored.
+// The size of commands and map data is written by EncodeLegacy.
+func EncodeLegacy(w io.Writer, sections []*Section) error {
+	buf := &bytes.Buffer{}
+
+	writeLegacySection(buf, replayID)
+	for i, s := range sections {
+		if i > 2 || s.StrID != 0 {
+			break
+		}
+		if i == 1 || i == 2 { // Commands and map data are preceded by their size
+			writeLegacySection(buf, binary.LittleEndian.AppendUint32(nil, uint32(len(s.Data))))
+		}
+		writeLegacySection(buf, s.Data)
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// writeLegacySection writes a section in the legacy format: a checksum,
+// the number of chunks and the chunks.
+// Chunks are stored uncompressed: legacy decoders (including the game) take
+// chunks whose size equals their uncompressed size as-is, so no PKWARE DCL
+// compression is needed.
+func writeLegacySection(buf *bytes.Buffer, data []byte) {
+	if len(data) == 0 {
+		return // Empty sections have no header
+	}
+
+	writeInt32(buf, crc32.ChecksumIEEE(data)) // Checksum, not verified by decoders
+	writeInt32(buf, uint32((len(data)+chunkSize-1)/chunkSize))
+
+	for len(data) > 0 {
+		chunk := data[:min(len(data), chunkSize)]
+		data = data[len(chunk):]
+
+		writeInt32(buf, uint32(len(chunk)))
+		buf.Write(chunk)
+	}
+}
+
+// writeSection writes a section: a checksum, the number of chunks and the chunks.
+// Chunks longer than 4 bytes are compressed (shorter ones must not be, the
+// replay ID is detected in its raw form).
+func writeSection(buf *bytes.Buffer, data []byte) {
+	if len(data) == 0 {
+		return // Empty sections have no header
+	}
+
+	writeInt32(buf, crc32.ChecksumIEEE(data)) // Checksum, not verified by decoders
+	writeInt32(buf, uint32((len(data)+chunkSize-1)/chunkSize))
+
+	for len(data) > 0 {
+		chunk := data[:min(len(data), chunkSize)]
+		data = data[len(chunk):]
+
+		if len(chunk) <= 4 {
+			writeInt32(buf, uint32(len(chunk)))
+			buf.Write(chunk)
+			continue
+		}
+
+		cbuf := &bytes.Buffer{}
+		zw := zlib.NewWriter(cbuf)
+		zw.Write(chunk) // Writing to a bytes.Buffer never fails
+		zw.Close()
+		writeInt32(buf, uint32(cbuf.Len()))
+		buf.Write(cbuf.Bytes())
+	}
+}
+
+// writeInt32 writes a little-endian 32-bit integer.
+func writeInt32(buf *bytes.Buffer, n uint32) {
+	buf.Write(binary.LittleEndian.AppendUint32(nil, n))
+}
@@ -41,21 +41,24 @@ package repparser
 
 import (
 	"bytes"
+	"crypto/sha1"
 	"encoding/binary"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"runtime"
+	"slices"
 	"sort"
 	"time"
 	"unicode/utf8"
 
+	"github.com/icza/screp/internal/intern"
 	"github.com/icza/screp/rep"
 	"github.com/icza/screp/rep/repcmd"
 	"github.com/icza/screp/rep/repcore"
 	"github.com/icza/screp/repparser/repdecoder"
-	"golang.org/x/text/encoding/korean"
 )
 
 const (
@@ -71,6 +74,10 @@ var (
 	// ErrParsing indicates that an unexpected error occurred, which may be
 	// due to corrupt / invalid replay file, or some implementation error.
 	ErrParsing = errors.New("parsing")
+
+	// ErrNotLegacyRepresentable indicates that the replay cannot be converted
+	// to the legacy format because it has commands introduced in 1.21.
+	ErrNotLegacyRepresentable = errors.New("not representable in the legacy format")
 )
 
 // Config holds parser configuration.
@@ -88,9 +95,90 @@ type Config struct {
 	// MapData must be parsed too.
 	MapGraphics bool
 
+	// MapTriggers tells if map triggers (including mission briefing triggers) are to be parsed.
+	// MapData must be parsed too.
+	MapTriggers bool
+
+	// MapRestrictions tells if upgrade and tech restrictions of the map are to be parsed.
+	// MapData must be parsed too.
+	MapRestrictions bool
+
+	// MapStrings tells if the full strings table of the map is to be retained.
+	// MapData must be parsed too.
+	MapStrings bool
+
+	// Logger is an optional logger of the parser's warnings (e.g. unknown sections,
+	// commands that could not be parsed) and debug records (e.g. processed sections).
+	// If nil, slog.Default() is used.
+	Logger *slog.Logger
+
+	// SectionDone is an optional function called after each (known) section is processed,
+	// with the durations of decoding (reading and decompressing) and parsing the section's data.
+	// Parsing duration is 0 for sections not parsed due to the configuration.
+	SectionDone func(s *Section, decode, parse time.Duration)
+
+	// Hooks are optional callbacks called during parsing.
+	Hooks Hooks
+
+	// Arena tells if commands are to be allocated in bulk (from slabs of the parsing), and if strings
+	// and byte slices of commands are to borrow the data of the commands section instead of copying it.
+	// This greatly reduces allocations and GC pressure when parsing many replays.
+	//
+	// Lifetime rules: commands share the memory of their slabs and of the commands section,
+	// so retaining a single command (or a string or byte slice of a command) keeps the memory
+	// of many commands alive. Copy the data that needs to outlive the replay instead of retaining
+	// parts of it. Byte slices of commands (e.g. GeneralCmd.Data) must not be modified
+	// (they share memory with Commands.Debug.Data).
+	Arena bool
+
+	// CmdsCapacity is the initial capacity of Commands.Cmds, avoiding repeated slice growth
+	// for replays having lots of commands. If 0, it is estimated from the size of the commands section.
+	// If negative, no capacity is preallocated.
+	CmdsCapacity int
+
+	// SkipRawStrings tells if the undecoded duplicates of strings (RawTitle, RawHost and RawMap
+	// of the header, and RawName of players) are not to be retained, trimming the memory of
+	// applications holding many parsed replays (see rep.Header.GameFingerprint()).
+	// Debug data is only retained if Debug is true.
+	SkipRawStrings bool
+
+	// Location is the time zone StartTime of the header is presented in (e.g. time.UTC),
+	// so the parsed replays do not depend on the time zone of the parsing host.
+	// If nil, the local time zone (time.Local) is used.
+	// The raw value is available as rep.Header.StartTimeUnix.
+	Location *time.Location
+
 	_ struct{} // To prevent unkeyed literals
 }
 
+// avgCmdSize is the average size of commands in the commands section (including the
+// frame and size of command blocks), used to estimate the number of commands.
+const avgCmdSize = 10
+
+// cmdsCapacity returns the initial capacity of Commands.Cmds for a commands section of the given size.
+func (cfg Config) cmdsCapacity(size int) int {
+	if cfg.CmdsCapacity != 0 {
+		return cfg.CmdsCapacity
+	}
+	return size / avgCmdSize
+}
+
+// location returns the time zone of StartTime.
+func (cfg Config) location() *time.Location {
+	if cfg.Location != nil {
+		return cfg.Location
+	}
+	return time.Local
+}
+
+// logger returns the logger of the parser.
+func (cfg Config) logger() *slog.Logger {
+	if cfg.Logger != nil {
+		return cfg.Logger
+	}
+	return slog.Default()
+}
+
 // ParseFile parses all sections from an SC:BW replay file.
 func ParseFile(name string) (r *rep.Replay, err error) {
 	return ParseFileConfig(name, Config{Commands: true, MapData: true})
@@ -114,7 +202,7 @@ func ParseFileConfig(name string, cfg Config) (r *rep.Replay, err error) {
 	}
 	defer dec.Close()
 
-	return parseProtected(dec, cfg)
+	return parseProtected(dec, cfg, new(rep.Replay))
 }
 
 // Parse parses all sections of an SC:BW replay from the given byte slice.
@@ -134,29 +222,60 @@ func ParseSections(repData []byte, commands, mapData bool) (*rep.Replay, error)
 
 // ParseConfig parses an SC:BW replay from the given byte sice based on the given parser configuration.
 // Replay ID and header sections are always parsed.
+// Errors of parsing are returned as *ParseError values.
 func ParseConfig(repData []byte, cfg Config) (*rep.Replay, error) {
 	dec := repdecoder.New(repData)
 	defer dec.Close()
 
-	return parseProtected(dec, cfg)
+	return parseProtected(dec, cfg, new(rep.Replay))
+}
+
+// ParseConfigInto parses an SC:BW replay from the given byte slice into r based on the given
+// parser configuration. r is reset first (see rep.Replay.Reset()), so replays may be reused
+// (e.g. from a sync.Pool) retaining the capacity of their commands slice.
+// If an error is returned, the content of r is undefined (but it may be reused).
+// Replay ID and header sections are always parsed.
+func ParseConfigInto(repData []byte, cfg Config, r *rep.Replay) error {
+	dec := repdecoder.New(repData)
+	defer dec.Close()
+
+	_, err := parseProtected(dec, cfg, r)
+	return err
+}
+
+// ParseCHK parses raw map data (a scenario CHK, e.g. extracted from a map file)
+// based on the given parser configuration. Config.MapData is ignored.
+// The map size is returned too, as it is not part of rep.MapData.
+func ParseCHK(chk []byte, cfg Config) (md *rep.MapData, width, height uint16, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			cfg.logger().Error("Parsing error", "error", r)
+			md, err = nil, &ParseError{Section: SectionMapData, Offset: -1, Err: ErrParsing}
+		}
+	}()
+
+	r := &rep.Replay{Header: &rep.Header{}}
+	if err = parseMapData(chk, r, cfg); err != nil {
+		return nil, 0, 0, err
+	}
+	return r.MapData, r.Header.MapWidth, r.Header.MapHeight, nil
 }
 
 // parseProtected calls parse(), but protects the function call from panics,
-// in which case it returns ErrParsing.
-func parseProtected(dec repdecoder.Decoder, cfg Config) (r *rep.Replay, err error) {
+// in which case it returns a *ParseError wrapping ErrParsing.
+func parseProtected(dec repdecoder.Decoder, cfg Config, into *rep.Replay) (r *rep.Replay, err error) {
 	// Input is untrusted data, protect the parsing logic.
 	// It also protects against implementation bugs.
 	defer func() {
 		if r := recover(); r != nil {
-			log.Printf("Parsing error: %v", r)
 			buf := make([]byte, 2000)
 			n := runtime.Stack(buf, false)
-			log.Printf("Stack: %s", buf[:n])
-			err = ErrParsing
+			cfg.logger().Error("Parsing error", "error", r, "stack", string(buf[:n]))
+			err = &ParseError{Offset: -1, Err: ErrParsing}
 		}
 	}()
 
-	return parse(dec, cfg)
+	return parse(dec, cfg, into)
 }
 
 // Section describes a Section of the replay.
@@ -207,10 +326,11 @@ var (
 	SectionPlayerNames = Sections[4]
 )
 
-// parse parses an SC:BW replay using the given Decoder.
-func parse(dec repdecoder.Decoder, cfg Config) (*rep.Replay, error) {
-	r := new(rep.Replay)
+// parse parses an SC:BW replay using the given Decoder into r (which is reset first).
+func parse(dec repdecoder.Decoder, cfg Config, r *rep.Replay) (*rep.Replay, error) {
+	r.Reset()
 	r.RepFormat = dec.RepFormat()
+	commandsParsed := false
 
 	// We have to read all sections, some data (e.g. player colors) are positioned after map data.
 
@@ -220,7 +340,7 @@ func parse(dec repdecoder.Decoder, cfg Config) (*rep.Replay, error) {
 			if err == repdecoder.ErrNoMoreSections {
 				break
 			}
-			return nil, fmt.Errorf("Decoder.NewSection() error: %w", err)
+			return nil, &ParseError{Section: sectionAt(sectionCounter), Offset: -1, Err: fmt.Errorf("Decoder.NewSection() error: %w", err)}
 		}
 
 		var s *Section
@@ -233,14 +353,16 @@ func parse(dec repdecoder.Decoder, cfg Config) (*rep.Replay, error) {
 			if size == 0 {
 				sizeData, _, err := dec.Section(4)
 				if err != nil {
-					return nil, fmt.Errorf("Decoder.Section() error when reading size: %w", err)
+					return nil, &ParseError{Section: s, Offset: -1, Err: fmt.Errorf("Decoder.Section() error when reading size: %w", err)}
 				}
 				size = int32(binary.LittleEndian.Uint32(sizeData))
 			}
 		}
 
 		// Read section data
+		decodeStart := time.Now()
 		data, sectionID, err := dec.Section(size)
+		decodeTime := time.Since(decodeStart)
 		if err != nil {
 			if s != nil && s.ID == SectionReplayID.ID {
 				err = ErrNotReplayFile // In case of Replay ID section return special error
@@ -250,10 +372,10 @@ func parse(dec repdecoder.Decoder, cfg Config) (*rep.Replay, error) {
 			}
 			if sectionCounter >= len(Sections) {
 				// If we got "enough" info, just log the error:
-				log.Printf("Warning: Decoder.Section() error: %v", err)
+				cfg.warn("Decoder.Section() error", "error", err)
 				break
 			}
-			return nil, fmt.Errorf("Decoder.Section() error: %w", err)
+			return nil, &ParseError{Section: s, Offset: -1, Err: fmt.Errorf("Decoder.Section() error: %w", err)}
 		}
 
 		if s == nil {
@@ -262,29 +384,84 @@ func parse(dec repdecoder.Decoder, cfg Config) (*rep.Replay, error) {
 				// Unknown section, just skip it:
 				idBytes := make([]byte, 4)
 				binary.LittleEndian.PutUint32(idBytes, uint32(sectionID))
-				log.Printf("Unknown modern section ID: %s", idBytes)
+				cfg.warn("Unknown modern section", "id", string(idBytes))
+				if cfg.Hooks.OnSection != nil {
+					cfg.Hooks.OnSection(&Section{ID: UnknownSectionID, Size: int32(len(data)), StrID: string(idBytes)}, data)
+				}
 				continue
 			}
 		}
 
+		if cfg.Hooks.OnSection != nil {
+			cfg.Hooks.OnSection(s, data)
+		}
+
 		// Need to process?
+		var parseTime time.Duration
 		switch {
 		case s == SectionCommands && !cfg.Commands:
 		case s == SectionMapData && !cfg.MapData:
 		default:
 			// Process section data
-			if err = s.ParseFunc(data, r, cfg); err != nil {
-				return nil, fmt.Errorf("ParseFunc() error (sectionID: %d): %v", s.ID, err)
+			parseStart := time.Now()
+			if err = callParseFunc(s, data, r, cfg); err != nil {
+				var pe *ParseError
+				if !errors.As(err, &pe) {
+					pe = &ParseError{Offset: -1, Err: err}
+				}
+				pe.Section = s
+				return nil, pe
 			}
+			commandsParsed = commandsParsed || s == SectionCommands
+			parseTime = time.Since(parseStart)
+		}
+		cfg.logger().Debug("Section processed", "id", s.ID, "size", len(data), "decode", decodeTime, "parse", parseTime)
+		if cfg.SectionDone != nil {
+			cfg.SectionDone(s, decodeTime, parseTime)
 		}
 	}
 
 	// Modern sections may or may not exist. Remastered's modern sections are in fixed order,
 	// but we don't rely on it.
 
+	if !commandsParsed {
+		r.Commands = nil // Retained by Reset()
+	}
+
+	if cfg.SkipRawStrings && r.Header != nil {
+		h := r.Header
+		h.RawTitle, h.RawHost, h.RawMap = "", "", ""
+		for _, p := range h.Slots {
+			p.RawName = ""
+		}
+	}
+
 	return r, nil
 }
 
+// sectionAt returns the section at the given index of Sections, nil if it is a modern section.
+func sectionAt(i int) *Section {
+	if i < len(Sections) {
+		return Sections[i]
+	}
+	return nil
+}
+
+// callParseFunc calls the ParseFunc of the given section, protecting the call from panics,
+// in which case it returns a *ParseError wrapping ErrParsing.
+func callParseFunc(s *Section, data []byte, r *rep.Replay, cfg Config) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			buf := make([]byte, 2000)
+			n := runtime.Stack(buf, false)
+			cfg.logger().Error("Parsing error", "error", rec, "sectionID", s.ID, "stack", string(buf[:n]))
+			err = &ParseError{Section: s, Offset: -1, Err: ErrParsing}
+		}
+	}()
+
+	return s.ParseFunc(data, r, cfg)
+}
+
 // repIDs is the possible valid content of the Replay ID section
 var repIDs = [][]byte{
 	[]byte("seRS"), // Starting from 1.21
@@ -354,7 +531,8 @@ func parseHeader(data []byte, r *rep.Replay, cfg Config) error {
 
 	h.Engine = repcore.EngineByID(data[0x00])
 	h.Frames = repcore.Frame(bo.Uint32(data[0x01:]))
-	h.StartTime = time.Unix(int64(bo.Uint32(data[0x08:])), 0) // replay stores seconds since EPOCH
+	h.StartTimeUnix = int64(bo.Uint32(data[0x08:])) // replay stores seconds since EPOCH
+	h.StartTime = time.Unix(h.StartTimeUnix, 0).In(cfg.location())
 	// SC:R uses UTF-8 always (except the map data section which may come from an external source or from the "past").
 	// The game UI allows longer title than what fits into its space in the header. If longer, SC simply "cuts" it,
 	// even in the middle of a multi-byte UTF-8 sequence :S
@@ -371,8 +549,8 @@ func parseHeader(data []byte, r *rep.Replay, cfg Config) error {
 	h.Speed = repcore.SpeedByID(data[0x3a])
 	h.Type = repcore.GameTypeByID(bo.Uint16(data[0x3c:]))
 	h.SubType = bo.Uint16(data[0x3e:])
-	h.Host, h.RawHost = cString(data[0x48 : 0x48+24])
-	h.Map, h.RawMap = cString(data[0x61 : 0x61+26])
+	h.Host, h.RawHost = cStringInterned(data[0x48 : 0x48+24])
+	h.Map, h.RawMap = cStringInterned(data[0x61 : 0x61+26])
 
 	// Parse players
 	const (
@@ -391,10 +569,15 @@ func parseHeader(data []byte, r *rep.Replay, cfg Config) error {
 		p.Type = repcore.PlayerTypeByID(ps[8])
 		p.Race = repcore.RaceByID(ps[9])
 		p.Team = ps[10]
-		p.Name, p.RawName = cString(ps[11 : 11+25])
+		name, orig, enc, confidence := cStringEnc(ps[11:11+25], nil)
+		setPlayerName(p, name, orig, enc, confidence)
 
 		if i < maxPlayers {
 			p.Color = repcore.ColorByID(bo.Uint32(data[0x251+i*4:]))
+			p.ColorSource = repcore.ColorSourceHeader
+		} else {
+			p.Color = repcore.ColorByID(uint32(i))
+			p.ColorSource = repcore.ColorSourceDefault
 		}
 
 		// Filter real players:
@@ -428,337 +611,66 @@ func parseHeader(data []byte, r *rep.Replay, cfg Config) error {
 		return h.Players[i].Team < h.Players[j].Team
 	})
 
+	checkHeader(h, cfg)
+
+	if cfg.Hooks.OnPlayer != nil {
+		for _, p := range h.OrigPlayers {
+			cfg.Hooks.OnPlayer(p)
+		}
+	}
+
 	return nil
 }
 
 // parseCommands processes the players' commands data.
 func parseCommands(data []byte, r *rep.Replay, cfg Config) error {
-	bo := binary.LittleEndian // ByteOrder reader: little-endian
-
-	_ = bo
-	cs := new(rep.Commands)
-	r.Commands = cs
+	cs := r.Commands // Retained by Replay.Reset() for reuse
+	if cs == nil {
+		cs = new(rep.Commands)
+		r.Commands = cs
+	}
 	if cfg.Debug {
 		cs.Debug = &rep.CommandsDebug{Data: data}
 	}
 
-	for sr, size := (sliceReader{b: data}), uint32(len(data)); sr.pos < size; {
-		frame := sr.getUint32()
-
-		// Command block in this frame
-		cmdBlockSize := sr.getByte()                    // cmd block size (remaining)
-		cmdBlockEndPos := sr.pos + uint32(cmdBlockSize) // Cmd block end position
-
-		for sr.pos < cmdBlockEndPos {
-			parseOk := true
-
-			var cmd repcmd.Cmd
-			base := &repcmd.Base{
-				Frame: repcore.Frame(frame),
-			}
-			base.PlayerID = sr.getByte()
-			base.Type = repcmd.TypeByID(sr.getByte())
-
-			switch base.Type.ID { // Try to list in frequency order:
-
-			case repcmd.TypeIDRightClick:
-				rccmd := &repcmd.RightClickCmd{Base: base}
-				rccmd.Pos.X = sr.getUint16()
-				rccmd.Pos.Y = sr.getUint16()
-				rccmd.UnitTag = repcmd.UnitTag(sr.getUint16())
-				rccmd.Unit = repcmd.UnitByID(sr.getUint16())
-				rccmd.Queued = sr.getByte() != 0
-				cmd = rccmd
-
-			case repcmd.TypeIDSelect, repcmd.TypeIDSelectAdd, repcmd.TypeIDSelectRemove:
-				count := sr.getByte()
-				selectCmd := &repcmd.SelectCmd{
-					Base:     base,
-					UnitTags: make([]repcmd.UnitTag, count),
-				}
-				for i := byte(0); i < count; i++ {
-					selectCmd.UnitTags[i] = repcmd.UnitTag(sr.getUint16())
-				}
-				cmd = selectCmd
-
-			case repcmd.TypeIDHotkey:
-				hotkeyCmd := &repcmd.HotkeyCmd{Base: base}
-				hotkeyCmd.HotkeyType = repcmd.HotkeyTypeByID(sr.getByte())
-				hotkeyCmd.Group = sr.getByte()
-				cmd = hotkeyCmd
-
-			case repcmd.TypeIDTrain, repcmd.TypeIDUnitMorph:
-				cmd = &repcmd.TrainCmd{
-					Base: base,
-					Unit: repcmd.UnitByID(sr.getUint16()),
-				}
-
-			case repcmd.TypeIDTargetedOrder:
-				tocmd := &repcmd.TargetedOrderCmd{Base: base}
-				tocmd.Pos.X = sr.getUint16()
-				tocmd.Pos.Y = sr.getUint16()
-				tocmd.UnitTag = repcmd.UnitTag(sr.getUint16())
-				tocmd.Unit = repcmd.UnitByID(sr.getUint16())
-				tocmd.Order = repcmd.OrderByID(sr.getByte())
-				tocmd.Queued = sr.getByte() != 0
-				cmd = tocmd
-
-			case repcmd.TypeIDBuild:
-				buildCmd := &repcmd.BuildCmd{Base: base}
-				buildCmd.Order = repcmd.OrderByID(sr.getByte())
-				buildCmd.Pos.X = sr.getUint16()
-				buildCmd.Pos.Y = sr.getUint16()
-				buildCmd.Unit = repcmd.UnitByID(sr.getUint16())
-				if buildCmd.Order.ID == repcmd.OrderIDBuildingLand {
-					// It's actually a Land command:
-					landCmd := (*repcmd.LandCmd)(buildCmd) // Fields are identical, we may simply convert it
-					landCmd.Base.Type = repcmd.TypeLand
-					cmd = landCmd
-				} else {
-					// It's truly a build command
-					cmd = buildCmd
-				}
-
-			case repcmd.TypeIDStop, repcmd.TypeIDBurrow, repcmd.TypeIDUnburrow,
-				repcmd.TypeIDReturnCargo, repcmd.TypeIDHoldPosition, repcmd.TypeIDUnloadAll,
-				repcmd.TypeIDUnsiege, repcmd.TypeIDSiege, repcmd.TypeIDCloack, repcmd.TypeIDDecloack:
-				cmd = &repcmd.QueueableCmd{
-					Base:   base,
-					Queued: sr.getByte() != 0,
-				}
-
-			case repcmd.TypeIDLeaveGame:
-				cmd = &repcmd.LeaveGameCmd{
-					Base:   base,
-					Reason: repcmd.LeaveReasonByID(sr.getByte()),
-				}
-
-			case repcmd.TypeIDMinimapPing:
-				pingCmd := &repcmd.MinimapPingCmd{Base: base}
-				pingCmd.Pos.X = sr.getUint16()
-				pingCmd.Pos.Y = sr.getUint16()
-				cmd = pingCmd
-
-			case repcmd.TypeIDChat:
-				chatCmd := &repcmd.ChatCmd{Base: base}
-				chatCmd.SenderSlotID = sr.getByte()
-				chatCmd.Message, _ = cString(sr.readSlice(80))
-				cmd = chatCmd
-
-			case repcmd.TypeIDVision:
-				data := sr.getUint16()
-				visionCmd := &repcmd.VisionCmd{
-					Base: base,
-				}
-				// There is 1 bit for each slot, 0x01: shared vision for that slot
-				for i := byte(0); i < 12; i++ {
-					if data&0x01 != 0 {
-						visionCmd.SlotIDs = append(visionCmd.SlotIDs, i)
-					}
-					data >>= 1
-				}
-				cmd = visionCmd
-
-			case repcmd.TypeIDAlliance:
-				data := sr.getUint32()
-				allianceCmd := &repcmd.AllianceCmd{
-					Base: base,
-				}
-				// There are 2 bits for each slot, 0x00: not allied, 0x1: allied, 0x02: allied victory
-				for i := byte(0); i < 11; i++ { // only 11 slots, 12th is always 0x01 or 0x02
-					if x := data & 0x03; x != 0 {
-						allianceCmd.SlotIDs = append(allianceCmd.SlotIDs, i)
-						if x == 2 {
-							allianceCmd.AlliedVictory = true
-						}
-					}
-					data >>= 2
-				}
-				cmd = allianceCmd
-
-			case repcmd.TypeIDGameSpeed:
-				cmd = &repcmd.GameSpeedCmd{
-					Base:  base,
-					Speed: repcore.SpeedByID(sr.getByte()),
-				}
-
-			case repcmd.TypeIDCancelTrain:
-				cmd = &repcmd.CancelTrainCmd{
-					Base:    base,
-					UnitTag: repcmd.UnitTag(sr.getUint16()),
-				}
-
-			case repcmd.TypeIDUnload:
-				cmd = &repcmd.UnloadCmd{
-					Base:    base,
-					UnitTag: repcmd.UnitTag(sr.getUint16()),
-				}
-
-			case repcmd.TypeIDLiftOff:
-				liftOffCmd := &repcmd.LiftOffCmd{Base: base}
-				liftOffCmd.Pos.X = sr.getUint16()
-				liftOffCmd.Pos.Y = sr.getUint16()
-				cmd = liftOffCmd
-
-			case repcmd.TypeIDTech:
-				cmd = &repcmd.TechCmd{
-					Base: base,
-					Tech: repcmd.TechByID(sr.getByte()),
-				}
-
-			case repcmd.TypeIDUpgrade:
-				cmd = &repcmd.UpgradeCmd{
-					Base:    base,
-					Upgrade: repcmd.UpgradeByID(sr.getByte()),
-				}
-
-			case repcmd.TypeIDBuildingMorph:
-				cmd = &repcmd.BuildingMorphCmd{
-					Base: base,
-					Unit: repcmd.UnitByID(sr.getUint16()),
-				}
-
-			case repcmd.TypeIDLatency:
-				cmd = &repcmd.LatencyCmd{
-					Base:    base,
-					Latency: repcmd.LatencyTypeByID(sr.getByte()),
-				}
-
-			case repcmd.TypeIDCheat:
-				cmd = &repcmd.GeneralCmd{
-					Base: base,
-					Data: sr.readSlice(4),
-				}
+	if n := cfg.cmdsCapacity(len(data)); n > 0 {
+		cs.Cmds = slices.Grow(cs.Cmds, n)
+		if cfg.Debug {
+			cs.Debug.CmdOffsets = make([]uint32, 0, n)
+		}
+	}
 
-			case repcmd.TypeIDSaveGame, repcmd.TypeIDLoadGame:
-				count := sr.getUint32()
-				sr.pos += count
-
-			// NO ADDITIONAL DATA:
-
-			case repcmd.TypeIDKeepAlive:
-			case repcmd.TypeIDRestartGame:
-			case repcmd.TypeIDPause:
-			case repcmd.TypeIDResume:
-			case repcmd.TypeIDCancelBuild:
-			case repcmd.TypeIDCancelMorph:
-			case repcmd.TypeIDCarrierStop:
-			case repcmd.TypeIDReaverStop:
-			case repcmd.TypeIDOrderNothing:
-			case repcmd.TypeIDTrainFighter:
-			case repcmd.TypeIDMergeArchon:
-			case repcmd.TypeIDCancelNuke:
-			case repcmd.TypeIDCancelTech:
-			case repcmd.TypeIDCancelUpgrade:
-			case repcmd.TypeIDCancelAddon:
-			case repcmd.TypeIDStim:
-			case repcmd.TypeIDVoiceEnable:
-			case repcmd.TypeIDVoiceDisable:
-			case repcmd.TypeIDStartGame:
-			case repcmd.TypeIDBriefingStart:
-			case repcmd.TypeIDMergeDarkArchon:
-			case repcmd.TypeIDMakeGamePublic:
-
-			// DON'T CARE COMMANDS:
-
-			case repcmd.TypeIDSync:
-				sr.pos += 6
-			case repcmd.TypeIDVoiceSquelch:
-				sr.pos++
-			case repcmd.TypeIDVoiceUnsquelch:
-				sr.pos++
-			case repcmd.TypeIDDownloadPercentage:
-				sr.pos++
-			case repcmd.TypeIDChangeGameSlot:
-				sr.pos += 5
-			case repcmd.TypeIDNewNetPlayer:
-				sr.pos += 7
-			case repcmd.TypeIDJoinedGame:
-				sr.pos += 17
-			case repcmd.TypeIDChangeRace:
-				sr.pos += 2
-			case repcmd.TypeIDTeamGameTeam:
-				sr.pos++
-			case repcmd.TypeIDUMSTeam:
-				sr.pos++
-			case repcmd.TypeIDMeleeTeam:
-				sr.pos += 2
-			case repcmd.TypeIDSwapPlayers:
-				sr.pos += 2
-			case repcmd.TypeIDSavedData:
-				sr.pos += 12
-			case repcmd.TypeIDReplaySpeed:
-				sr.pos += 9
-
-			// New commands introduced in 1.21
-
-			case repcmd.TypeIDRightClick121:
-				rccmd := &repcmd.RightClickCmd{Base: base}
-				rccmd.Pos.X = sr.getUint16()
-				rccmd.Pos.Y = sr.getUint16()
-				rccmd.UnitTag = repcmd.UnitTag(sr.getUint16())
-				sr.getUint16() // Unknown, always 0?
-				rccmd.Unit = repcmd.UnitByID(sr.getUint16())
-				rccmd.Queued = sr.getByte() != 0
-				cmd = rccmd
-
-			case repcmd.TypeIDTargetedOrder121:
-				tocmd := &repcmd.TargetedOrderCmd{Base: base}
-				tocmd.Pos.X = sr.getUint16()
-				tocmd.Pos.Y = sr.getUint16()
-				tocmd.UnitTag = repcmd.UnitTag(sr.getUint16())
-				sr.getUint16() // Unknown, always 0?
-				tocmd.Unit = repcmd.UnitByID(sr.getUint16())
-				tocmd.Order = repcmd.OrderByID(sr.getByte())
-				tocmd.Queued = sr.getByte() != 0
-				cmd = tocmd
-
-			case repcmd.TypeIDUnload121:
-				ucmd := &repcmd.UnloadCmd{Base: base}
-				ucmd.UnitTag = repcmd.UnitTag(sr.getUint16())
-				sr.getUint16() // Unknown, always 0?
-				cmd = ucmd
-
-			case repcmd.TypeIDSelect121, repcmd.TypeIDSelectAdd121, repcmd.TypeIDSelectRemove121:
-				count := sr.getByte()
-				selectCmd := &repcmd.SelectCmd{
-					Base:     base,
-					UnitTags: make([]repcmd.UnitTag, count),
-				}
-				for i := byte(0); i < count; i++ {
-					selectCmd.UnitTags[i] = repcmd.UnitTag(sr.getUint16())
-					sr.getUint16() // Unknown, always 0?
-				}
-				cmd = selectCmd
-
-			default:
-				// We don't know how to parse this command, we have to skip
-				// to the end of the command block
-				// (potentially skipping additional commands...)
-				var remBytes []byte
-				if sr.pos <= cmdBlockEndPos && cmdBlockEndPos <= uint32(len(sr.b)) { // Due to "bad" parsing these must be checked...
-					remBytes = sr.b[sr.pos:cmdBlockEndPos]
-				}
-				fmt.Printf("skipping typeID: %#v, frame: %d, playerID: %d, remaining bytes: %d [% x]\n", base.Type.ID, base.Frame, base.PlayerID, cmdBlockEndPos-sr.pos, remBytes)
-				pec := &repcmd.ParseErrCmd{Base: base}
-				if len(cs.Cmds) > 0 {
-					pec.PrevCmd = cs.Cmds[len(cs.Cmds)-1]
-				}
-				cs.ParseErrCmds = append(cs.ParseErrCmds, pec)
-				sr.pos = cmdBlockEndPos
-				parseOk = false
-			}
+	cr := NewCmdReader(data, cfg)
+	cr.setPlayerEncodings(r.Header)
+	for {
+		cmd, err := cr.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// Truncated commands data, keep the commands parsed so far
+			cfg.warn("Truncated commands data", "error", err)
+			break
+		}
 
-			if parseOk {
-				if cmd == nil {
-					cs.Cmds = append(cs.Cmds, base)
-				} else {
-					cs.Cmds = append(cs.Cmds, cmd)
-				}
+		if pec, ok := cmd.(*repcmd.ParseErrCmd); ok {
+			cs.ParseErrCmds = append(cs.ParseErrCmds, pec)
+		} else {
+			cs.Cmds = append(cs.Cmds, cmd)
+			if cfg.Debug {
+				cs.Debug.CmdOffsets = append(cs.Debug.CmdOffsets, cr.cmdPos)
 			}
 		}
+		if cfg.Hooks.OnCommand != nil {
+			cfg.Hooks.OnCommand(cmd)
+		}
+	}
+	cs.BlockStats = cr.BlockStats()
 
-		sr.pos = cmdBlockEndPos
+	if n := len(cs.Cmds); n > 0 && r.Header != nil {
+		if last := cs.Cmds[n-1].BaseCmd().Frame; last > r.Header.Frames {
+			addHeaderWarning(r.Header, cfg, "Frames", "game length: %d frames, last command at frame %d", r.Header.Frames, last)
+		}
 	}
 
 	return nil
@@ -766,7 +678,8 @@ func parseCommands(data []byte, r *rep.Replay, cfg Config) error {
 
 // parseMapData processes the map data data.
 func parseMapData(data []byte, r *rep.Replay, cfg Config) error {
-	md := new(rep.MapData)
+	chkHash := sha1.Sum(data)
+	md := &rep.MapData{CHKHash: hex.EncodeToString(chkHash[:])}
 	r.MapData = md
 	if cfg.Debug {
 		md.Debug = &rep.MapDataDebug{Data: data}
@@ -782,12 +695,26 @@ func parseMapData(data []byte, r *rep.Replay, cfg Config) error {
 	md.TileSetMissing = true
 
 	var (
-		scenarioNameIdx        uint16 // String index
-		scenarioDescriptionIdx uint16 // String index
-		stringsData            []byte
-		extendedStringsData    bool
+		scenarioNameIdx        uint16   // String index
+		scenarioDescriptionIdx uint16   // String index
+		strData, strxData      []byte   // Strings data of the "STR " and "STRx" sub-sections
+		forceNameIdxs          []uint16 // String indices
+		locationNameIdxs       []uint16 // String indices
+		unitNameIdxs           []uint16 // String indices
+		switchNameIdxs         []uint32 // String indices
+		wavIdxs                []uint32 // String indices
+		unitSettingsExtended   bool     // Tells if unit settings were parsed from UNIx
+		upgSettingsExtended    bool     // Tells if upgrade settings were parsed from UPGx
+		techSettingsExtended   bool     // Tells if tech settings were parsed from TECx
+		upgRestrExtended       bool     // Tells if upgrade restrictions were parsed from PUPx
+		techRestrExtended      bool     // Tells if tech restrictions were parsed from PTEx
 	)
 
+	sectionCounts := map[string]int{}
+	addAnomaly := func(t *repcore.MapAnomalyType, section, details string) {
+		md.Anomalies = append(md.Anomalies, &rep.MapAnomaly{Type: t, Section: section, Details: details})
+	}
+
 	// Map data section is a sequence of sub-sections:
 	for sr, size := (sliceReader{b: data}), uint32(len(data)); sr.pos < size; {
 		id := sr.getString(4)
@@ -798,6 +725,24 @@ func parseMapData(data []byte, r *rep.Replay, cfg Config) error {
 		ssSize := sr.getUint32()    // sub-section size (remaining)
 		ssEndPos := sr.pos + ssSize // sub-section end position
 
+		sectionCounts[id]++
+		switch {
+		case !knownSections[id]:
+			addAnomaly(repcore.MapAnomalyTypeUnknownSection, id, "")
+		case sectionCounts[id] == 2: // Only report once
+			addAnomaly(repcore.MapAnomalyTypeDuplicateSection, id, "")
+		}
+		if ssEndPos < sr.pos {
+			// Negative size: the game would jump back, we can't follow that safely.
+			addAnomaly(repcore.MapAnomalyTypeInvalidSize, id, fmt.Sprint("negative size: ", int32(ssSize)))
+			break
+		}
+		if ssEndPos > size {
+			addAnomaly(repcore.MapAnomalyTypeInvalidSize, id, fmt.Sprintf("size: %d, available: %d", ssSize, size-sr.pos))
+		} else if expSize, ok := fixedSectionSizes[id]; ok && ssSize != expSize {
+			addAnomaly(repcore.MapAnomalyTypeInvalidSize, id, fmt.Sprintf("size: %d, expected: %d", ssSize, expSize))
+		}
+
 		switch id {
 		case "VER ":
 			md.Version = sr.getUint16()
@@ -809,6 +754,14 @@ func parseMapData(data []byte, r *rep.Replay, cfg Config) error {
 			// invalid map size, this is the correct one.
 			width := sr.getUint16()
 			height := sr.getUint16()
+			// Header size is checked for plausibility only if set (e.g. ParseCHK() has no header):
+			if h := r.Header; h.MapWidth != 0 && h.MapHeight != 0 && (width != h.MapWidth || height != h.MapHeight) {
+				field := "MapWidth"
+				if width == h.MapWidth {
+					field = "MapHeight"
+				}
+				addHeaderWarning(h, cfg, field, "header map size: %s, map data size: %dx%d", h.MapSize(), width, height)
+			}
 			if width <= 256 && height <= 256 {
 				if width > r.Header.MapWidth {
 					r.Header.MapWidth = width
@@ -837,6 +790,16 @@ func parseMapData(data []byte, r *rep.Replay, cfg Config) error {
 			for i, id := range sides {
 				md.PlayerSides[i] = repcore.PlayerSideByID(id)
 			}
+		case "COLR": // Player colors
+			count := uint32(8) // 8 bytes, 1 for each player
+			if count > ssSize {
+				count = ssSize
+			}
+			colors := sr.readSlice(count)
+			md.Colors = make([]*repcore.Color, len(colors))
+			for i, id := range colors {
+				md.Colors[i] = repcore.ColorByID(uint32(id))
+			}
 		case "MTXM": // Tile sub-section
 			// map_width*map_height (a tile is an uint16 value)
 			maxI := ssSize / 2
@@ -853,6 +816,9 @@ func parseMapData(data []byte, r *rep.Replay, cfg Config) error {
 			for i := uint32(0); i < maxI; i++ {
 				md.Tiles[i] = sr.getUint16()
 			}
+		case "MASK": // Fog of war layer
+			// map_width*map_height (1 byte for each tile)
+			md.FogMask = sr.readSlice(min(ssSize, size-sr.pos))
 		case "UNIT": // Placed units
 			for sr.pos+36 <= ssEndPos { // Loop while we have a complete unit
 				unitEndPos := sr.pos + 36 // 36 bytes for each unit
@@ -924,6 +890,140 @@ func parseMapData(data []byte, r *rep.Replay, cfg Config) error {
 					sr.pos = spriteEndPos
 				}
 			}
+		case "FORC": // Forces
+			// Size may be less than 20 bytes, missing bytes are treated as 0.
+			forc := make([]byte, 20)
+			copy(forc, sr.readSlice(min(ssSize, 20, size-sr.pos)))
+			md.Forces = make([]*rep.Force, 4)
+			forceNameIdxs = make([]uint16, 4)
+			for i := range md.Forces {
+				props := forc[16+i]
+				md.Forces[i] = &rep.Force{
+					ID:                  byte(i),
+					RandomStartLocation: props&0x01 != 0,
+					Allied:              props&0x02 != 0,
+					AlliedVictory:       props&0x04 != 0,
+					SharedVision:        props&0x08 != 0,
+				}
+				forceNameIdxs[i] = binary.LittleEndian.Uint16(forc[8+i*2:])
+			}
+			// Force assignment of the 8 players:
+			for slotID, forceID := range forc[:8] {
+				if int(forceID) < len(md.Forces) {
+					f := md.Forces[forceID]
+					f.SlotIDs = append(f.SlotIDs, byte(slotID))
+				}
+			}
+		case "MRGN": // Locations
+			md.Locations, locationNameIdxs = nil, nil
+			endPos := min(ssEndPos, size)                    // Sub-section might be truncated
+			for id := uint16(1); sr.pos+20 <= endPos; id++ { // Loop while we have a complete location
+				loc := &rep.Location{ID: id}
+				loc.Left = sr.getUint32()
+				loc.Top = sr.getUint32()
+				loc.Right = sr.getUint32()
+				loc.Bottom = sr.getUint32()
+				nameIdx := sr.getUint16()
+				loc.ElevationFlags = sr.getUint16()
+				if nameIdx == 0 && loc.Left == 0 && loc.Top == 0 && loc.Right == 0 && loc.Bottom == 0 {
+					continue // Unused location
+				}
+				md.Locations = append(md.Locations, loc)
+				locationNameIdxs = append(locationNameIdxs, nameIdx)
+			}
+		case "UNIS", "UNIx": // Unit settings
+			// UNIx is the Brood War version of UNIS with more weapons, prefer that over UNIS:
+			extended := id == "UNIx"
+			if unitSettingsExtended && !extended {
+				break
+			}
+			weaponsCount := uint32(100)
+			if extended {
+				weaponsCount = 130
+			}
+			if min(ssEndPos, size)-sr.pos < 228*16+weaponsCount*4 {
+				break // Incomplete sub-section
+			}
+			unitSettingsExtended = extended
+			md.UnitSettings, md.WeaponSettings, unitNameIdxs = parseUnitSettings(&sr, weaponsCount)
+		case "UPGS", "UPGx": // Upgrade settings
+			// UPGx is the Brood War version of UPGS with more upgrades, prefer that over UPGS:
+			extended := id == "UPGx"
+			if upgSettingsExtended && !extended {
+				break
+			}
+			count, padding := uint32(46), uint32(0)
+			if extended {
+				count, padding = 61, 1
+			}
+			if min(ssEndPos, size)-sr.pos < count*13+padding {
+				break // Incomplete sub-section
+			}
+			upgSettingsExtended = extended
+			md.UpgradeSettings = parseUpgradeSettings(&sr, count, padding)
+		case "TECS", "TECx": // Tech settings
+			// TECx is the Brood War version of TECS with more techs, prefer that over TECS:
+			extended := id == "TECx"
+			if techSettingsExtended && !extended {
+				break
+			}
+			count := uint32(24)
+			if extended {
+				count = 44
+			}
+			if min(ssEndPos, size)-sr.pos < count*9 {
+				break // Incomplete sub-section
+			}
+			techSettingsExtended = extended
+			md.TechSettings = parseTechSettings(&sr, count)
+		case "UPGR", "PUPx": // Upgrade restrictions
+			// PUPx is the Brood War version of UPGR with more upgrades, prefer that over UPGR:
+			extended := id == "PUPx"
+			if upgRestrExtended && !extended {
+				break
+			}
+			count := uint32(46)
+			if extended {
+				count = 61
+			}
+			if !cfg.MapRestrictions || min(ssEndPos, size)-sr.pos < count*38 {
+				break // Not requested or incomplete sub-section
+			}
+			upgRestrExtended = extended
+			md.UpgradeRestrictions = parseUpgradeRestrictions(&sr, count)
+		case "PTEC", "PTEx": // Tech restrictions
+			// PTEx is the Brood War version of PTEC with more techs, prefer that over PTEC:
+			extended := id == "PTEx"
+			if techRestrExtended && !extended {
+				break
+			}
+			count := uint32(24)
+			if extended {
+				count = 44
+			}
+			if !cfg.MapRestrictions || min(ssEndPos, size)-sr.pos < count*38 {
+				break // Not requested or incomplete sub-section
+			}
+			techRestrExtended = extended
+			md.TechRestrictions = parseTechRestrictions(&sr, count)
+		case "SWNM": // Switch names
+			switchNameIdxs = switchNameIdxs[:0]
+			for endPos := min(ssEndPos, size); sr.pos+4 <= endPos && len(switchNameIdxs) < 256; {
+				switchNameIdxs = append(switchNameIdxs, sr.getUint32())
+			}
+		case "WAV ": // WAV string indices
+			wavIdxs = wavIdxs[:0]
+			for endPos := min(ssEndPos, size); sr.pos+4 <= endPos && len(wavIdxs) < 512; {
+				wavIdxs = append(wavIdxs, sr.getUint32())
+			}
+		case "TRIG": // Triggers
+			if cfg.MapTriggers {
+				md.Triggers = parseTriggers(&sr, min(ssEndPos, size), false)
+			}
+		case "MBRF": // Mission briefing triggers
+			if cfg.MapTriggers {
+				md.BriefingTriggers = parseTriggers(&sr, min(ssEndPos, size), true)
+			}
 		case "SPRP": // Scenario properties
 			// Strings section might be after this, so we just record the string indices for now:
 			scenarioNameIdx = sr.getUint16()
@@ -931,63 +1031,597 @@ func parseMapData(data []byte, r *rep.Replay, cfg Config) error {
 		case "STR ": // String data
 			// There might be multiple "STR " sections, subsequent sections overwrite the
 			// beginning of earlier sections.
-			stringsStart := int(sr.pos)
-			// count := sr.getUint16() // Number of following offsets (uint16 values)
-			if len(stringsData) < int(ssEndPos)-stringsStart {
-				stringsData = make([]byte, int(ssEndPos)-stringsStart)
-			}
-			copy(stringsData, data[stringsStart:ssEndPos])
+			strData = overlaySection(strData, data[sr.pos:min(ssEndPos, size)])
 		case "STRx": // Extended String data
 			// This section is identical to "STR " except that all uint16 values are uint32 values.
-			stringsStart := int(sr.pos)
-			// count := sr.getUint32() // Number of following offsets (uint32 values)
-			if len(stringsData) < int(ssEndPos)-stringsStart {
-				stringsData = make([]byte, int(ssEndPos)-stringsStart)
-			}
-			copy(stringsData, data[stringsStart:ssEndPos])
-			extendedStringsData = true
+			// If present, it is used instead of "STR " (which may be kept for older editors).
+			strxData = overlaySection(strxData, data[sr.pos:min(ssEndPos, size)])
+		}
+
+		if sr.err != nil {
+			break // Sub-section is truncated, there is no more data
 		}
 
 		// Part or all of the sub-section might be unprocessed, skip the unprocessed bytes
 		sr.pos = ssEndPos
 	}
 
+	st, stringsSection := newStringsTable(strData, false), "STR " // Strings table and ID of its sub-section
+	if strxData != nil {
+		st, stringsSection = newStringsTable(strxData, true), "STRx"
+	}
+
 	// Get a string from the strings identified by its index.
-	getString := func(idx uint16) string {
-		if idx == 0 {
-			return ""
+	getString := func(idx uint32) string {
+		s, validIdx, validOffset := st.get(idx)
+		switch {
+		case !validIdx:
+			cfg.warn("Invalid strings index", "index", idx, "map", r.Header.Map)
+		case !validOffset:
+			cfg.warn("Invalid strings offset", "offset", st.offsets[idx-1], "index", idx, "map", r.Header.Map)
 		}
-		var offsetSize uint32
-		if extendedStringsData {
-			offsetSize = 4
-		} else {
-			offsetSize = 2
+		return s
+	}
+
+	// Reconcile header and map colors (see rep.Player.ColorSource).
+	// CCLR section is parsed after map data, so it will take precedence.
+	ums := r.Header.Type == repcore.GameTypeUMS
+	for i, c := range md.Colors {
+		if i >= len(r.Header.Slots) || int(c.ID) >= len(repcore.Colors) {
+			continue
 		}
-		pos := uint32(idx) * offsetSize // idx is 1-based (0th offset is not included), but stringsData contains the offsets count too
-		if int(pos+offsetSize-1) >= len(stringsData) {
-			log.Printf("Invalid strings index: %d, map: %s", idx, r.Header.Map)
-			return ""
+		p := r.Header.Slots[i]
+		headerUnknown := p.Color == nil || int(p.Color.ID) >= len(repcore.Colors)
+		if headerUnknown || ums && c.ID != uint32(i) {
+			p.Color, p.ColorSource = c, repcore.ColorSourceMap
 		}
-		var offset uint32
-		if extendedStringsData {
-			offset = (&sliceReader{b: stringsData, pos: pos}).getUint32()
-		} else {
-			offset = uint32((&sliceReader{b: stringsData, pos: pos}).getUint16())
+	}
+
+	for _, ids := range requiredSections {
+		if !slices.ContainsFunc(ids, func(id string) bool { return sectionCounts[id] > 0 }) {
+			addAnomaly(repcore.MapAnomalyTypeMissingSection, ids[0], "")
 		}
-		if int(offset) >= len(stringsData) {
-			log.Printf("Invalid strings offset: %d, strings index: %d, map: %s", offset, idx, r.Header.Map)
-			return ""
+	}
+
+	// Number of strings having an offset present:
+	stringsCount := uint32(len(st.offsets))
+	if stringsCount < st.declared {
+		addAnomaly(repcore.MapAnomalyTypeInvalidSize, stringsSection,
+			fmt.Sprintf("strings: %d, offsets present: %d", st.declared, stringsCount))
+	}
+	if invalids := st.invalidOffsets(); invalids > 0 {
+		addAnomaly(repcore.MapAnomalyTypeInvalidString, stringsSection,
+			fmt.Sprintf("string offsets out of range: %d", invalids))
+	}
+
+	if cfg.MapStrings && stringsCount > 0 {
+		md.Strings = make([]string, stringsCount)
+		for i := range md.Strings {
+			md.Strings[i] = getString(uint32(i + 1))
 		}
-		s, _ := cString(stringsData[offset:])
-		return s
 	}
 
-	md.Name = getString(scenarioNameIdx)
-	md.Description = getString(scenarioDescriptionIdx)
+	md.Name = getString(uint32(scenarioNameIdx))
+	md.Description = getString(uint32(scenarioDescriptionIdx))
+	for i, f := range md.Forces {
+		if f.Name = getString(uint32(forceNameIdxs[i])); f.Name == "" {
+			f.Name = fmt.Sprint("Force ", i+1) // Default name used by the game
+		}
+	}
+	for i, loc := range md.Locations {
+		loc.Name = getString(uint32(locationNameIdxs[i]))
+	}
+	for i, idx := range switchNameIdxs {
+		if name := getString(idx); name != "" {
+			md.Switches = append(md.Switches, &rep.Switch{ID: uint16(i), Name: name})
+		}
+	}
+	for _, idx := range wavIdxs {
+		if wav := getString(idx); wav != "" {
+			md.WAVs = append(md.WAVs, wav)
+		}
+	}
+	for i, us := range md.UnitSettings {
+		us.Name = getString(uint32(unitNameIdxs[i]))
+	}
+	for _, triggers := range [][]*rep.Trigger{md.Triggers, md.BriefingTriggers} {
+		for _, t := range triggers {
+			for _, a := range t.Actions {
+				// Unused text / WAV fields may hold garbage, don't log those as invalid indices:
+				if int(a.TextID) < len(st.data) {
+					a.Text = getString(a.TextID)
+				}
+				if int(a.WAVID) < len(st.data) {
+					a.WAV = getString(a.WAVID)
+				}
+			}
+		}
+	}
+
+	md.Fingerprint = mapFingerprint(md, r.Header.MapWidth, r.Header.MapHeight)
 
 	return nil
 }
 
+// mapFingerprint computes the fingerprint of the map, see rep.MapData.Fingerprint.
+func mapFingerprint(md *rep.MapData, width, height uint16) string {
+	h := sha1.New()
+	buf := binary.LittleEndian.AppendUint16(nil, md.TileSet.ID)
+	buf = binary.LittleEndian.AppendUint16(buf, width)
+	buf = binary.LittleEndian.AppendUint16(buf, height)
+	for _, tile := range md.Tiles {
+		buf = binary.LittleEndian.AppendUint16(buf, tile)
+	}
+	h.Write(buf)
+
+	// Order of placed units is irrelevant, so sort the resource locations:
+	for _, ress := range [][]rep.Resource{md.MineralFields, md.Geysers} {
+		pts := make([]repcore.Point, len(ress))
+		for i, res := range ress {
+			pts[i] = res.Point
+		}
+		sort.Slice(pts, func(i, j int) bool {
+			if pts[i].Y != pts[j].Y {
+				return pts[i].Y < pts[j].Y
+			}
+			return pts[i].X < pts[j].X
+		})
+		buf = binary.LittleEndian.AppendUint32(buf[:0], uint32(len(pts)))
+		for _, pt := range pts {
+			buf = binary.LittleEndian.AppendUint16(buf, pt.X)
+			buf = binary.LittleEndian.AppendUint16(buf, pt.Y)
+		}
+		h.Write(buf)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// knownSections holds the IDs of the known map data sub-sections.
+var knownSections = map[string]bool{}
+
+func init() {
+	for _, id := range []string{
+		"TYPE", "VER ", "IVER", "IVE2", "VCOD", "IOWN", "OWNR", "ERA ", "DIM ", "SIDE", "MTXM", "PUNI",
+		"UPGR", "PTEC", "UNIT", "ISOM", "TILE", "DD2 ", "THG2", "MASK", "STR ", "UPRP", "UPUS", "MRGN",
+		"TRIG", "MBRF", "SPRP", "FORC", "WAV ", "UNIS", "UPGS", "TECS", "SWNM", "COLR", "PUPx", "PTEx",
+		"UNIx", "UPGx", "TECx", "STRx", "CRGB", "OSTR", "KSTR", "KTRG", "KTGP",
+	} {
+		knownSections[id] = true
+	}
+}
+
+// requiredSections lists the sub-sections required by the game to play the map.
+// Elements are alternatives, of which at least one must be present.
+var requiredSections = [][]string{
+	{"VER "}, {"DIM "}, {"ERA "}, {"OWNR"}, {"SIDE"}, {"MTXM"}, {"UNIT"}, {"STR ", "STRx"},
+}
+
+// fixedSectionSizes holds the size of the map data sub-sections having a fixed size.
+var fixedSectionSizes = map[string]uint32{
+	"VER ": 2, "ERA ": 2, "DIM ": 4, "OWNR": 12, "SIDE": 12, "COLR": 8, "SPRP": 4,
+}
+
+// weapon identifies a weapon (an entry of the weapon settings).
+type weapon struct {
+	id   byte
+	name string
+}
+
+// unitWeapons maps from unit ID to its (ground and air) weapons, for the regular armed units.
+var unitWeapons = map[uint16][]weapon{
+	0x00: {{0x00, "Gauss Rifle"}},                                          // Marine
+	0x01: {{0x02, "C-10 Canister Rifle"}},                                  // Ghost
+	0x02: {{0x04, "Fragmentation Grenade"}},                                // Vulture
+	0x03: {{0x07, "Twin Autocannons"}, {0x08, "Hellfire Missile Pack"}},    // Goliath
+	0x05: {{0x0b, "Arclite Cannon"}},                                       // Siege Tank (Tank Mode)
+	0x07: {{0x0d, "Fusion Cutter"}},                                        // SCV
+	0x08: {{0x10, "Burst Lasers"}, {0x0f, "Gemini Missiles"}},              // Wraith
+	0x0c: {{0x13, "ATS Laser Battery"}, {0x14, "ATA Laser Battery"}},       // Battlecruiser
+	0x0d: {{0x06, "Spider Mines"}},                                         // Spider Mine
+	0x1e: {{0x1b, "Arclite Shock Cannon"}},                                 // Siege Tank (Siege Mode)
+	0x20: {{0x19, "Flame Thrower"}},                                        // Firebat
+	0x25: {{0x23, "Claws"}},                                                // Zergling
+	0x26: {{0x26, "Needle Spines"}},                                        // Hydralisk
+	0x27: {{0x28, "Kaiser Blades"}},                                        // Ultralisk
+	0x28: {{0x2a, "Toxic Spores"}},                                         // Broodling
+	0x29: {{0x2b, "Spines"}},                                               // Drone
+	0x2b: {{0x30, "Glave Wurm"}},                                           // Mutalisk
+	0x2c: {{0x2e, "Acid Spore"}},                                           // Guardian
+	0x2f: {{0x37, "Suicide (Scourge)"}},                                    // Scourge
+	0x32: {{0x36, "Suicide (Infested Terran)"}},                            // Infested Terran
+	0x3a: {{0x67, "Halo Rockets"}},                                         // Valkyrie
+	0x3c: {{0x64, "Neutron Flare"}},                                        // Corsair
+	0x3d: {{0x6f, "Warp Blades"}},                                          // Dark Templar
+	0x3e: {{0x68, "Corrosive Acid"}},                                       // Devourer
+	0x40: {{0x3e, "Particle Beam"}},                                        // Probe
+	0x41: {{0x40, "Psi Blades"}},                                           // Zealot
+	0x42: {{0x42, "Phase Disruptor"}},                                      // Dragoon
+	0x44: {{0x46, "Psionic Shockwave"}},                                    // Archon
+	0x46: {{0x49, "Dual Photon Blasters"}, {0x4a, "Anti-matter Missiles"}}, // Scout
+	0x49: {{0x4f, "Pulse Cannon"}},                                         // Interceptor
+	0x55: {{0x52, "Scarab"}},                                               // Scarab
+	0x67: {{0x6d, "Subterranean Spines"}},                                  // Lurker
+	0x7c: {{0x1d, "Longbolt Missile"}},                                     // Missile Turret
+	0x90: {{0x34, "Seeker Spores"}},                                        // Spore Colony
+	0x92: {{0x35, "Subterranean Tentacle"}},                                // Sunken Colony
+	0xa2: {{0x50, "STS Photon Cannon"}, {0x51, "STA Photon Cannon"}},       // Photon Cannon
+}
+
+// parseUnitSettings parses unit settings from the UNIS or UNIx sub-section.
+// Only units with non-default settings are returned, along with their name string indices.
+func parseUnitSettings(sr *sliceReader, weaponsCount uint32) (uss []*rep.UnitSetting, wss []*rep.WeaponSetting, nameIdxs []uint16) {
+	const unitsCount = 228
+
+	start := sr.pos
+	useDefaults := sr.readSlice(unitsCount)
+	// Properties are stored in arrays, one element for each unit:
+	get16 := func(arrayPos, i uint32) uint16 {
+		return (&sliceReader{b: sr.b, pos: arrayPos + i*2}).getUint16()
+	}
+	hpPos := start + unitsCount
+	shieldsPos := hpPos + unitsCount*4
+	armorPos := shieldsPos + unitsCount*2
+	buildTimePos := armorPos + unitsCount
+	mineralsPos := buildTimePos + unitsCount*2
+	gasPos := mineralsPos + unitsCount*2
+	namesPos := gasPos + unitsCount*2
+	damagesPos := namesPos + unitsCount*2
+	bonusesPos := damagesPos + weaponsCount*2
+
+	for i, useDefault := range useDefaults {
+		if useDefault != 0 {
+			continue
+		}
+		u := uint32(i)
+		uss = append(uss, &rep.UnitSetting{
+			Unit:         repcmd.UnitByID(uint16(i)),
+			HitPoints:    (&sliceReader{b: sr.b, pos: hpPos + u*4}).getUint32() >> 8, // Stored in 1/256 units
+			ShieldPoints: get16(shieldsPos, u),
+			Armor:        sr.b[armorPos+u],
+			BuildTime:    get16(buildTimePos, u),
+			MineralCost:  get16(mineralsPos, u),
+			GasCost:      get16(gasPos, u),
+		})
+		nameIdxs = append(nameIdxs, get16(namesPos, u))
+	}
+
+	// Weapon settings only take effect for weapons of units having non-default settings:
+	for _, us := range uss {
+		for _, w := range unitWeapons[us.Unit.ID] {
+			if uint32(w.id) >= weaponsCount {
+				continue
+			}
+			wss = append(wss, &rep.WeaponSetting{
+				WeaponID:      w.id,
+				Name:          w.name,
+				Unit:          us.Unit,
+				BaseDamage:    get16(damagesPos, uint32(w.id)),
+				UpgradeDamage: get16(bonusesPos, uint32(w.id)),
+			})
+		}
+	}
+
+	return
+}
+
+// parseUpgradeSettings parses upgrade settings from the UPGS or UPGx sub-section.
+// Only upgrades with non-default settings are returned.
+// padding is the number of unused bytes following the use default flags.
+func parseUpgradeSettings(sr *sliceReader, count, padding uint32) (uss []*rep.UpgradeSetting) {
+	useDefaults := sr.readSlice(count)
+	arraysPos := sr.pos + padding
+	// Properties are stored in arrays, one element for each upgrade:
+	get16 := func(array, i uint32) uint16 {
+		return (&sliceReader{b: sr.b, pos: arraysPos + array*count*2 + i*2}).getUint16()
+	}
+
+	for i, useDefault := range useDefaults {
+		if useDefault != 0 {
+			continue
+		}
+		u := uint32(i)
+		uss = append(uss, &rep.UpgradeSetting{
+			Upgrade:       repcmd.UpgradeByID(byte(i)),
+			MineralCost:   get16(0, u),
+			MineralFactor: get16(1, u),
+			GasCost:       get16(2, u),
+			GasFactor:     get16(3, u),
+			Time:          get16(4, u),
+			TimeFactor:    get16(5, u),
+		})
+	}
+
+	return
+}
+
+// parseTechSettings parses tech settings from the TECS or TECx sub-section.
+// Only techs with non-default settings are returned.
+func parseTechSettings(sr *sliceReader, count uint32) (tss []*rep.TechSetting) {
+	useDefaults := sr.readSlice(count)
+	arraysPos := sr.pos
+	// Properties are stored in arrays, one element for each tech:
+	get16 := func(array, i uint32) uint16 {
+		return (&sliceReader{b: sr.b, pos: arraysPos + array*count*2 + i*2}).getUint16()
+	}
+
+	for i, useDefault := range useDefaults {
+		if useDefault != 0 {
+			continue
+		}
+		t := uint32(i)
+		tss = append(tss, &rep.TechSetting{
+			Tech:        repcmd.TechByID(byte(i)),
+			MineralCost: get16(0, t),
+			GasCost:     get16(1, t),
+			Time:        get16(2, t),
+			EnergyCost:  get16(3, t),
+		})
+	}
+
+	return
+}
+
+// parseUpgradeRestrictions parses upgrade restrictions from the UPGR or PUPx sub-section.
+// The returned restrictions contain the effective values for each slot.
+func parseUpgradeRestrictions(sr *sliceReader, count uint32) (urs []*rep.UpgradeRestriction) {
+	const slotsCount = 12
+
+	playerMaxLevels := sr.readSlice(slotsCount * count)
+	playerStartLevels := sr.readSlice(slotsCount * count)
+	globalMaxLevels := sr.readSlice(count)
+	globalStartLevels := sr.readSlice(count)
+	useDefaults := sr.readSlice(slotsCount * count)
+
+	for i := uint32(0); i < count; i++ {
+		ur := &rep.UpgradeRestriction{
+			Upgrade:     repcmd.UpgradeByID(byte(i)),
+			MaxLevels:   make(repcmd.Bytes, slotsCount),
+			StartLevels: make(repcmd.Bytes, slotsCount),
+		}
+		for slot := uint32(0); slot < slotsCount; slot++ {
+			if idx := slot*count + i; useDefaults[idx] != 0 {
+				ur.MaxLevels[slot], ur.StartLevels[slot] = globalMaxLevels[i], globalStartLevels[i]
+			} else {
+				ur.MaxLevels[slot], ur.StartLevels[slot] = playerMaxLevels[idx], playerStartLevels[idx]
+			}
+		}
+		urs = append(urs, ur)
+	}
+
+	return
+}
+
+// parseTechRestrictions parses tech restrictions from the PTEC or PTEx sub-section.
+// The returned restrictions contain the effective values for each slot.
+func parseTechRestrictions(sr *sliceReader, count uint32) (trs []*rep.TechRestriction) {
+	const slotsCount = 12
+
+	playerAvailable := sr.readSlice(slotsCount * count)
+	playerResearched := sr.readSlice(slotsCount * count)
+	globalAvailable := sr.readSlice(count)
+	globalResearched := sr.readSlice(count)
+	useDefaults := sr.readSlice(slotsCount * count)
+
+	for i := uint32(0); i < count; i++ {
+		tr := &rep.TechRestriction{
+			Tech:       repcmd.TechByID(byte(i)),
+			Available:  make([]bool, slotsCount),
+			Researched: make([]bool, slotsCount),
+		}
+		for slot := uint32(0); slot < slotsCount; slot++ {
+			if idx := slot*count + i; useDefaults[idx] != 0 {
+				tr.Available[slot], tr.Researched[slot] = globalAvailable[i] != 0, globalResearched[i] != 0
+			} else {
+				tr.Available[slot], tr.Researched[slot] = playerAvailable[idx] != 0, playerResearched[idx] != 0
+			}
+		}
+		trs = append(trs, tr)
+	}
+
+	return
+}
+
+// Trigger parameter kinds, telling which raw parameters of a condition / action
+// are used and how they are to be decoded.
+const (
+	paramUnit = 1 << iota
+	paramUnitCount
+	paramComparison
+	paramResourceType
+	paramScoreType
+	paramAllianceStatus
+	paramSwitch
+	paramModifier
+	paramSwitchModifier
+	paramOrder
+)
+
+// conditionParams maps from trigger condition type ID to its parameter kinds.
+var conditionParams = map[byte]int{
+	0x01: paramComparison,                     // Countdown Timer
+	0x02: paramUnit | paramComparison,         // Command
+	0x03: paramUnit | paramComparison,         // Bring
+	0x04: paramResourceType | paramComparison, // Accumulate
+	0x05: paramUnit | paramComparison,         // Kill
+	0x06: paramUnit,                           // Command the Most
+	0x07: paramUnit,                           // Command the Most At
+	0x08: paramUnit,                           // Most Kills
+	0x09: paramScoreType,                      // Highest Score
+	0x0a: paramResourceType,                   // Most Resources
+	0x0b: paramSwitch | paramComparison,       // Switch
+	0x0c: paramComparison,                     // Elapsed Time
+	0x0e: paramComparison,                     // Opponents
+	0x0f: paramUnit | paramComparison,         // Deaths
+	0x10: paramUnit,                           // Command the Least
+	0x11: paramUnit,                           // Command the Least At
+	0x12: paramUnit,                           // Least Kills
+	0x13: paramScoreType,                      // Lowest Score
+	0x14: paramResourceType,                   // Least Resources
+	0x15: paramScoreType | paramComparison,    // Score
+}
+
+// actionParams maps from trigger action type ID to its parameter kinds.
+var actionParams = map[byte]int{
+	0x07: paramUnit | paramModifier,         // Transmission
+	0x0b: paramUnit | paramUnitCount,        // Create Unit with Properties
+	0x0d: paramSwitch | paramSwitchModifier, // Set Switch
+	0x0e: paramModifier,                     // Set Countdown Timer
+	0x11: paramUnit,                         // Leader Board (Control)
+	0x12: paramUnit,                         // Leader Board (Control At Location)
+	0x13: paramResourceType,                 // Leader Board (Resources)
+	0x14: paramUnit,                         // Leader Board (Kills)
+	0x15: paramScoreType,                    // Leader Board (Points)
+	0x16: paramUnit,                         // Kill Unit
+	0x17: paramUnit | paramUnitCount,        // Kill Unit At Location
+	0x18: paramUnit,                         // Remove Unit
+	0x19: paramUnit | paramUnitCount,        // Remove Unit At Location
+	0x1a: paramResourceType | paramModifier, // Set Resources
+	0x1b: paramScoreType | paramModifier,    // Set Score
+	0x1d: paramUnit,                         // Talking Portrait
+	0x20: paramModifier,                     // Leaderboard Computer Players
+	0x21: paramUnit,                         // Leaderboard Goal (Control)
+	0x22: paramUnit,                         // Leaderboard Goal (Control At Location)
+	0x23: paramResourceType,                 // Leaderboard Goal (Resources)
+	0x24: paramUnit,                         // Leaderboard Goal (Kills)
+	0x25: paramScoreType,                    // Leaderboard Goal (Points)
+	0x27: paramUnit | paramUnitCount,        // Move Unit
+	0x2a: paramUnit | paramModifier,         // Set Doodad State
+	0x2b: paramUnit | paramModifier,         // Set Invincibility
+	0x2c: paramUnit | paramUnitCount,        // Create Unit
+	0x2d: paramUnit | paramModifier,         // Set Deaths
+	0x2e: paramUnit | paramOrder,            // Order
+	0x30: paramUnit | paramUnitCount,        // Give Units to Player
+	0x31: paramUnit | paramUnitCount,        // Modify Unit Hit Points
+	0x32: paramUnit | paramUnitCount,        // Modify Unit Energy
+	0x33: paramUnit | paramUnitCount,        // Modify Unit Shield Points
+	0x34: paramUnit | paramUnitCount,        // Modify Unit Resource Amount
+	0x35: paramUnit | paramUnitCount,        // Modify Unit Hanger Count
+	0x39: paramAllianceStatus,               // Set Alliance Status
+}
+
+// briefingActionParams maps from briefing trigger action type ID to its parameter kinds.
+var briefingActionParams = map[byte]int{
+	0x05: paramUnit,                 // Show Portrait
+	0x07: paramUnit,                 // Display Speaking Portrait
+	0x08: paramUnit | paramModifier, // Transmission
+}
+
+// parseTriggers parses triggers from the TRIG or MBRF sub-section, reading until endPos.
+// briefing tells if mission briefing triggers are parsed (from the MBRF sub-section).
+func parseTriggers(sr *sliceReader, endPos uint32, briefing bool) (triggers []*rep.Trigger) {
+	const (
+		conditionsCount = 16
+		actionsCount    = 64
+		groupsCount     = 27
+	)
+
+	for sr.pos+2400 <= endPos { // Loop while we have a complete trigger
+		trigEndPos := sr.pos + 2400 // 2400 bytes for each trigger
+		t := new(rep.Trigger)
+
+		for i := 0; i < conditionsCount; i++ {
+			condEndPos := sr.pos + 20 // 20 bytes for each condition
+			c := new(rep.TriggerCondition)
+			c.LocationID = sr.getUint32()
+			c.Group = repcore.PlayerGroupByID(sr.getUint32())
+			c.Amount = sr.getUint32()
+			unitID := sr.getUint16()
+			comparison := sr.getByte()
+			c.Type = repcore.TriggerConditionTypeByID(sr.getByte())
+			resType := sr.getByte() // Resource type, score type or switch number
+			c.Flags = sr.getByte()
+			if c.Type.ID != 0 { // 0: No condition
+				params := conditionParams[c.Type.ID]
+				if params&paramUnit != 0 {
+					c.Unit = repcmd.UnitByID(unitID)
+				}
+				if params&paramComparison != 0 {
+					c.Comparison = repcore.ComparisonByID(comparison)
+				}
+				if params&paramResourceType != 0 {
+					c.ResourceType = repcore.ResourceTypeByID(uint16(resType))
+				}
+				if params&paramScoreType != 0 {
+					c.ScoreType = repcore.ScoreTypeByID(uint16(resType))
+				}
+				if params&paramSwitch != 0 {
+					switchID := uint16(resType)
+					c.SwitchID = &switchID
+				}
+				t.Conditions = append(t.Conditions, c)
+			}
+			sr.pos = condEndPos
+		}
+
+		for i := 0; i < actionsCount; i++ {
+			actionEndPos := sr.pos + 32 // 32 bytes for each action
+			a := new(rep.TriggerAction)
+			a.LocationID = sr.getUint32()
+			a.TextID = sr.getUint32()
+			a.WAVID = sr.getUint32()
+			a.Time = sr.getUint32()
+			a.Group = repcore.PlayerGroupByID(sr.getUint32())
+			a.Group2 = sr.getUint32()
+			unitID := sr.getUint16() // Unit, resource type, score type or alliance status
+			var params int
+			if briefing {
+				a.Type = repcore.BriefingActionTypeByID(sr.getByte())
+				params = briefingActionParams[a.Type.ID]
+			} else {
+				a.Type = repcore.TriggerActionTypeByID(sr.getByte())
+				params = actionParams[a.Type.ID]
+			}
+			modifier := sr.getByte() // Number of units, modifier or order
+			a.Flags = sr.getByte()
+			if a.Type.ID != 0 { // 0: No action
+				if params&paramUnit != 0 {
+					a.Unit = repcmd.UnitByID(unitID)
+				}
+				if params&paramUnitCount != 0 {
+					a.UnitCount = modifier
+				}
+				if params&paramResourceType != 0 {
+					a.ResourceType = repcore.ResourceTypeByID(unitID)
+				}
+				if params&paramScoreType != 0 {
+					a.ScoreType = repcore.ScoreTypeByID(unitID)
+				}
+				if params&paramAllianceStatus != 0 {
+					a.AllianceStatus = repcore.AllianceStatusByID(unitID)
+				}
+				if params&paramSwitch != 0 {
+					switchID := uint16(a.Group2)
+					a.SwitchID = &switchID
+				}
+				if params&paramModifier != 0 {
+					a.Modifier = repcore.ModifierByID(modifier)
+				}
+				if params&paramSwitchModifier != 0 {
+					a.Modifier = repcore.SwitchModifierByID(modifier)
+				}
+				if params&paramOrder != 0 {
+					a.Order = repcore.TriggerOrderByID(modifier)
+				}
+				t.Actions = append(t.Actions, a)
+			}
+			sr.pos = actionEndPos
+		}
+
+		t.ExecutionFlags = sr.getUint32()
+		for i, executes := range sr.readSlice(groupsCount) {
+			if executes != 0 {
+				t.Groups = append(t.Groups, repcore.PlayerGroupByID(uint32(i)))
+			}
+		}
+
+		triggers = append(triggers, t)
+		sr.pos = trigEndPos
+	}
+
+	return
+}
+
 // parsePlayerNames processes the player names data.
 func parsePlayerNames(data []byte, r *rep.Replay, cfg Config) error {
 	// Note: these player names parse well even when decoding is unknown in header
@@ -999,9 +1633,8 @@ func parsePlayerNames(data []byte, r *rep.Replay, cfg Config) error {
 		}
 
 		if p.Type != repcore.PlayerTypeInactive {
-			name, orig := cString(data[pos : pos+96])
-			if name != "" {
-				p.Name, p.RawName = name, orig
+			if name, orig, enc, confidence := cStringEnc(data[pos:pos+96], nil); name != "" {
+				setPlayerName(p, name, orig, enc, confidence)
 			}
 		}
 	}
@@ -1052,7 +1685,7 @@ func parsePlayerColors(data []byte, r *rep.Replay, cfg Config) error {
 			break
 		}
 		if c := repcore.ColorByFootprint(data[pos : pos+16]); c != nil {
-			p.Color = c
+			p.Color, p.ColorSource = c, repcore.ColorSourceCCLR
 		}
 	}
 
@@ -1093,21 +1726,27 @@ func parseShieldBatterySection(data []byte, r *rep.Replay, cfg Config) error {
 	return nil
 }
 
-var koreanDecoder = korean.EUCKR.NewDecoder()
-
 // cString returns a 0x00 byte terminated string from the given buffer.
-// If the string is not valid UTF-8, tries to decode it as EUC-KR (also known as Code Page 949).
+// If the string is not valid UTF-8, tries to decode it using the legacy encoding that fits it best
+// (e.g. EUC-KR, also known as Code Page 949, see decodeText()).
 // Returns both the decoded and the original string.
 func cString(data []byte) (s string, orig string) {
+	s, orig, _, _ = cStringEnc(data, nil)
+	return
+}
+
+// cStringEnc is like cString, but the hint encoding (optional) is preferred when decoding
+// a legacy encoded string, and the detected encoding and its confidence are also returned.
+// enc is nil if the string is valid UTF-8 or no encoding could decode it.
+func cStringEnc(data []byte, hint *textEncoding) (s, orig string, enc *textEncoding, confidence float64) {
 	// Find 0x00 byte:
 	for i, ch := range data {
 		if ch == 0 {
 			data = data[:i] // excludes terminating 0x00
 
 			if !utf8.Valid(data) {
-				// Try korean
-				if krdata, err := koreanDecoder.Bytes(data); err == nil {
-					return string(krdata), string(data)
+				if s, enc, confidence = decodeText(data, hint); enc != nil {
+					return s, string(data), enc, confidence
 				}
 			}
 			break // Either UTF-8 or custom decoding failed
@@ -1121,7 +1760,24 @@ func cString(data []byte) (s string, orig string) {
 	//   - or it is invalid but custom decoding failed
 	// Either way:
 	s = string(data)
-	return s, s
+	return s, s, nil, 0
+}
+
+// setPlayerName sets the name of the player (interned) and the detected encoding of the name
+// (see cStringEnc()).
+func setPlayerName(p *rep.Player, name, orig string, enc *textEncoding, confidence float64) {
+	p.Name, p.RawName = intern.String(name), intern.String(orig)
+	p.NameEncoding, p.NameEncodingConfidence = "", 0
+	if enc != nil {
+		p.NameEncoding, p.NameEncodingConfidence = enc.name, confidence
+	}
+}
+
+// cStringInterned is like cString, but returns interned strings, so strings
+// repeated in many replays (e.g. player and map names) share memory.
+func cStringInterned(data []byte) (s string, orig string) {
+	s, orig = cString(data)
+	return intern.String(s), intern.String(orig)
 }
 
 // cStringUTF8 returns a 0x00 byte terminated string from the given buffer,
@@ -0,0 +1,749 @@
+package repparser
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"slices"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcmd"
+	"github.com/icza/screp/rep/repcore"
+	"github.com/icza/screp/repparser/repencoder"
+)
+
+// chkSection is a sub-section of synthetic map data (CHK).
+type chkSection struct {
+	id   string
+	data []byte
+}
+
+// buildCHK builds synthetic map data from the given sub-sections.
+func buildCHK(sections ...chkSection) (chk []byte) {
+	for _, s := range sections {
+		chk = append(chk, s.id...)
+		chk = binary.LittleEndian.AppendUint32(chk, uint32(len(s.data)))
+		chk = append(chk, s.data...)
+	}
+	return
+}
+
+// buildSTR builds a "STR " sub-section holding the given strings (string index i+1 is strs[i]).
+func buildSTR(strs ...string) chkSection {
+	data := binary.LittleEndian.AppendUint16(nil, uint16(len(strs)))
+	offset := 2 + 2*len(strs)
+	for _, s := range strs {
+		data = binary.LittleEndian.AppendUint16(data, uint16(offset))
+		offset += len(s) + 1
+	}
+	for _, s := range strs {
+		data = append(data, s...)
+		data = append(data, 0)
+	}
+	return chkSection{"STR ", data}
+}
+
+// parseCHK parses the given synthetic map data.
+func parseCHK(t *testing.T, chk []byte, cfg Config) *rep.MapData {
+	t.Helper()
+	r := &rep.Replay{Header: &rep.Header{}}
+	if err := parseMapData(chk, r, cfg); err != nil {
+		t.Fatalf("Failed to parse map data: %v", err)
+	}
+	return r.MapData
+}
+
+func TestParseForces(t *testing.T) {
+	forc := make([]byte, 20)
+	copy(forc, []byte{0, 0, 1, 1, 0, 1, 3, 3}) // Force IDs of the 8 players
+	binary.LittleEndian.PutUint16(forc[8:], 1) // Name of force 1 is string #1
+	forc[16] = 0x02 | 0x04                     // Force 1: allied, allied victory
+	forc[17] = 0x01 | 0x08                     // Force 2: random start location, shared vision
+
+	md := parseCHK(t, buildCHK(chkSection{"FORC", forc}, buildSTR("Attackers")), Config{})
+
+	cases := []struct {
+		name                                 string
+		slotIDs                              []byte
+		random, allied, alliedVictory, share bool
+	}{
+		{"Attackers", []byte{0, 1, 4}, false, true, true, false},
+		{"Force 2", []byte{2, 3, 5}, true, false, false, true},
+		{"Force 3", nil, false, false, false, false},
+		{"Force 4", []byte{6, 7}, false, false, false, false},
+	}
+	if len(md.Forces) != len(cases) {
+		t.Fatalf("Expected: %v forces, got: %v", len(cases), len(md.Forces))
+	}
+	for i, c := range cases {
+		f := md.Forces[i]
+		if f.ID != byte(i) || f.Name != c.name || string(f.SlotIDs) != string(c.slotIDs) {
+			t.Errorf("[%d] Expected: %v %q %v, got: %v %q %v", i, i, c.name, c.slotIDs, f.ID, f.Name, f.SlotIDs)
+		}
+		if f.RandomStartLocation != c.random || f.Allied != c.allied || f.AlliedVictory != c.alliedVictory || f.SharedVision != c.share {
+			t.Errorf("[%d] Expected props: %v %v %v %v, got: %v %v %v %v", i, c.random, c.allied, c.alliedVictory, c.share,
+				f.RandomStartLocation, f.Allied, f.AlliedVictory, f.SharedVision)
+		}
+	}
+}
+
+// location returns the MRGN entry of a location.
+func location(left, top, right, bottom uint32, nameIdx uint16) (loc []byte) {
+	for _, v := range []uint32{left, top, right, bottom} {
+		loc = binary.LittleEndian.AppendUint32(loc, v)
+	}
+	loc = binary.LittleEndian.AppendUint16(loc, nameIdx)
+	return binary.LittleEndian.AppendUint16(loc, 0x08)
+}
+
+func TestParseLocations(t *testing.T) {
+	var mrgn []byte
+	mrgn = append(mrgn, location(0, 0, 64, 32, 1)...)
+	mrgn = append(mrgn, location(0, 0, 0, 0, 0)...) // Unused
+	mrgn = append(mrgn, location(100, 200, 300, 400, 0)...)
+
+	md := parseCHK(t, buildCHK(chkSection{"MRGN", mrgn}, buildSTR("Base")), Config{})
+
+	cases := []rep.Location{
+		{ID: 1, Name: "Base", Right: 64, Bottom: 32, ElevationFlags: 0x08},
+		{ID: 3, Left: 100, Top: 200, Right: 300, Bottom: 400, ElevationFlags: 0x08},
+	}
+	if len(md.Locations) != len(cases) {
+		t.Fatalf("Expected: %v locations, got: %v", len(cases), len(md.Locations))
+	}
+	for i, c := range cases {
+		if *md.Locations[i] != c {
+			t.Errorf("[%d] Expected: %+v, got: %+v", i, c, *md.Locations[i])
+		}
+	}
+
+	// Truncated sub-section: its size exceeds the available data.
+	chk := buildCHK(chkSection{"MRGN", mrgn})
+	md = parseCHK(t, chk[:len(chk)-10], Config{})
+	if len(md.Locations) != 1 {
+		t.Errorf("Expected: %v locations, got: %v", 1, len(md.Locations))
+	}
+}
+
+// trigger returns a TRIG entry with the given conditions (20 bytes each) and actions (32 bytes each),
+// executed by Player 1.
+func trigger(conds, actions [][]byte) []byte {
+	trig := make([]byte, 2400)
+	for i, c := range conds {
+		copy(trig[i*20:], c)
+	}
+	for i, a := range actions {
+		copy(trig[16*20+i*32:], a)
+	}
+	trig[16*20+64*32+4] = 1 // Executed by Player 1
+	return trig
+}
+
+func TestParseTriggers(t *testing.T) {
+	le := binary.LittleEndian
+	cond := func(unitID uint16, comparison, typ, resType byte) []byte {
+		c := make([]byte, 20)
+		le.PutUint32(c[8:], 5) // Amount
+		le.PutUint16(c[12:], unitID)
+		c[14], c[15], c[16] = comparison, typ, resType
+		return c
+	}
+	action := func(textID, group2 uint32, unitID uint16, typ, modifier byte) []byte {
+		a := make([]byte, 32)
+		le.PutUint32(a[4:], textID)
+		le.PutUint32(a[20:], group2)
+		le.PutUint16(a[24:], unitID)
+		a[26], a[27] = typ, modifier
+		return a
+	}
+
+	trig := trigger(
+		[][]byte{
+			cond(37, 0x00, 0x03, 0),  // Bring at least 5 Zerglings
+			cond(0, 0x02, 0x0b, 7),   // Switch 7 is set
+			cond(0, 0x0a, 0x04, 1),   // Accumulate exactly 5 gas
+			cond(123, 0x00, 0x16, 9), // Always (parameters unused)
+		},
+		[][]byte{
+			action(1, 0, 0, 0x09, 0),     // Display Text Message
+			action(0, 3, 0, 0x0d, 0x05),  // Clear switch 3
+			action(0, 0, 2, 0x1a, 0x08),  // Add ore and gas
+			action(0, 0, 37, 0x2c, 4),    // Create 4 Zerglings
+			action(999, 0, 0, 0x2f, 0),   // Comment with invalid text ID
+			action(0, 0, 1, 0x39, 0),     // Set Alliance Status to ally
+			action(0, 0, 37, 0x2e, 0x02), // Order: attack
+		},
+	)
+	md := parseCHK(t, buildCHK(chkSection{"TRIG", trig}, buildSTR("Hello")), Config{MapTriggers: true})
+
+	if len(md.Triggers) != 1 {
+		t.Fatalf("Expected: %v triggers, got: %v", 1, len(md.Triggers))
+	}
+	tr := md.Triggers[0]
+	if len(tr.Conditions) != 4 || len(tr.Actions) != 7 {
+		t.Fatalf("Expected: 4 conditions and 7 actions, got: %v and %v", len(tr.Conditions), len(tr.Actions))
+	}
+	if len(tr.Groups) != 1 || tr.Groups[0].Name != "Player 1" {
+		t.Errorf("Expected: [Player 1], got: %v", tr.Groups)
+	}
+
+	cs := tr.Conditions
+	if cs[0].Unit == nil || cs[0].Unit.Name != "Zergling" || cs[0].Comparison.Name != "At Least" || cs[0].Amount != 5 {
+		t.Errorf("Unexpected Bring condition: %+v", cs[0])
+	}
+	if cs[1].SwitchID == nil || *cs[1].SwitchID != 7 || cs[1].Comparison.Name != "Set" || cs[1].Unit != nil {
+		t.Errorf("Unexpected Switch condition: %+v", cs[1])
+	}
+	if cs[2].ResourceType == nil || cs[2].ResourceType.Name != "Gas" || cs[2].Comparison.Name != "Exactly" {
+		t.Errorf("Unexpected Accumulate condition: %+v", cs[2])
+	}
+	if cs[3].Unit != nil || cs[3].Comparison != nil || cs[3].SwitchID != nil {
+		t.Errorf("Unexpected Always condition: %+v", cs[3])
+	}
+
+	as := tr.Actions
+	if as[0].Text != "Hello" || as[0].Unit != nil || as[0].Modifier != nil {
+		t.Errorf("Unexpected Display Text Message action: %+v", as[0])
+	}
+	if as[1].SwitchID == nil || *as[1].SwitchID != 3 || as[1].Modifier.Name != "Clear" {
+		t.Errorf("Unexpected Set Switch action: %+v", as[1])
+	}
+	if as[2].ResourceType == nil || as[2].ResourceType.Name != "Ore and Gas" || as[2].Modifier.Name != "Add" || as[2].Unit != nil {
+		t.Errorf("Unexpected Set Resources action: %+v", as[2])
+	}
+	if as[3].Unit == nil || as[3].Unit.Name != "Zergling" || as[3].UnitCount != 4 || as[3].Modifier != nil {
+		t.Errorf("Unexpected Create Unit action: %+v", as[3])
+	}
+	if as[4].Text != "" {
+		t.Errorf("Unexpected Comment action: %+v", as[4])
+	}
+	if as[5].AllianceStatus == nil || as[5].AllianceStatus.Name != "Ally" {
+		t.Errorf("Unexpected Set Alliance Status action: %+v", as[5])
+	}
+	if as[6].Order == nil || as[6].Order.Name != "Attack" {
+		t.Errorf("Unexpected Order action: %+v", as[6])
+	}
+
+	// Truncated sub-section: incomplete triggers are skipped.
+	chk := buildCHK(chkSection{"TRIG", append(trig, trig...)})
+	md = parseCHK(t, chk[:len(chk)-100], Config{MapTriggers: true})
+	if len(md.Triggers) != 1 {
+		t.Errorf("Expected: %v triggers, got: %v", 1, len(md.Triggers))
+	}
+}
+
+func TestUnitWeapons(t *testing.T) {
+	// Check unit IDs of the weapons table against unit names:
+	names := map[uint16]string{
+		0x03: "Goliath", 0x0c: "Battlecruiser", 0x25: "Zergling", 0x3d: "Dark Templar",
+		0x46: "Scout", 0x67: "Lurker", 0x92: "Sunken Colony", 0xa2: "Photon Cannon",
+	}
+	for unitID, name := range names {
+		if len(unitWeapons[unitID]) == 0 {
+			t.Errorf("[%s] Expected weapons", name)
+		}
+		if got := repcmd.UnitByID(unitID).Name; got != name {
+			t.Errorf("[%#x] Expected: %v, got: %v", unitID, name, got)
+		}
+	}
+}
+
+func TestParseUnitSettings(t *testing.T) {
+	const unitsCount, weaponsCount = 228, 130
+	le := binary.LittleEndian
+	unix := make([]byte, unitsCount*16+weaponsCount*4)
+	for i := range unitsCount {
+		unix[i] = 1 // Use defaults
+	}
+	const goliath = 0x03
+	unix[goliath] = 0
+	le.PutUint32(unix[unitsCount+goliath*4:], 200<<8)           // Hit points
+	le.PutUint16(unix[unitsCount*14+goliath*2:], 1)             // Name: string #1
+	le.PutUint16(unix[unitsCount*16+0x08*2:], 30)               // Hellfire Missile Pack base damage
+	le.PutUint16(unix[unitsCount*16+weaponsCount*2+0x08*2:], 3) // Hellfire Missile Pack upgrade damage
+	le.PutUint16(unix[unitsCount*16+0x00*2:], 50)               // Gauss Rifle (not in effect)
+
+	md := parseCHK(t, buildCHK(chkSection{"UNIx", unix}, buildSTR("Walker")), Config{})
+
+	if len(md.UnitSettings) != 1 {
+		t.Fatalf("Expected: %v unit settings, got: %v", 1, len(md.UnitSettings))
+	}
+	if us := md.UnitSettings[0]; us.Unit.ID != goliath || us.Name != "Walker" || us.HitPoints != 200 {
+		t.Errorf("Unexpected unit setting: %+v", us)
+	}
+	if len(md.WeaponSettings) != 2 {
+		t.Fatalf("Expected: %v weapon settings, got: %v", 2, len(md.WeaponSettings))
+	}
+	if ws := md.WeaponSettings[1]; ws.WeaponID != 0x08 || ws.Unit.ID != goliath || ws.BaseDamage != 30 || ws.UpgradeDamage != 3 {
+		t.Errorf("Unexpected weapon setting: %+v", ws)
+	}
+
+	// Truncated sub-section is skipped:
+	chk := buildCHK(chkSection{"UNIx", unix})
+	if md = parseCHK(t, chk[:len(chk)-1], Config{}); md.UnitSettings != nil {
+		t.Errorf("Expected no unit settings, got: %v", md.UnitSettings)
+	}
+}
+
+func TestParseUpgradeRestrictions(t *testing.T) {
+	const count, slots = 61, 12
+	pupx := make([]byte, count*38)
+	globalMax := pupx[slots*count*2:]
+	useDefaults := pupx[slots*count*2+count*2:]
+	for i := range count {
+		globalMax[i] = 3
+	}
+	for i := range useDefaults[:slots*count] {
+		useDefaults[i] = 1
+	}
+	const upgID, slot = 2, 1
+	useDefaults[slot*count+upgID] = 0
+	pupx[slot*count+upgID] = 1 // Player max level
+
+	chk := buildCHK(chkSection{"PUPx", pupx})
+	if md := parseCHK(t, chk, Config{}); md.UpgradeRestrictions != nil {
+		t.Errorf("Expected no restrictions if not requested, got: %d", len(md.UpgradeRestrictions))
+	}
+
+	md := parseCHK(t, chk, Config{MapRestrictions: true})
+	if len(md.UpgradeRestrictions) != count {
+		t.Fatalf("Expected: %v restrictions, got: %v", count, len(md.UpgradeRestrictions))
+	}
+	cases := []struct {
+		slot, upgID byte
+		level       byte
+	}{
+		{0, upgID, 3},
+		{slot, upgID, 1},
+		{slot, upgID + 1, 3},
+	}
+	for _, c := range cases {
+		if level, ok := md.UpgradeMaxLevel(c.slot, c.upgID); !ok || level != c.level {
+			t.Errorf("[%d %d] Expected: %v, got: %v (ok: %v)", c.slot, c.upgID, c.level, level, ok)
+		}
+	}
+}
+
+func TestParseSwitches(t *testing.T) {
+	swnm := make([]byte, 256*4)
+	binary.LittleEndian.PutUint32(swnm[3*4:], 1) // Name of switch 3 (0-based) is string #1
+	wav := binary.LittleEndian.AppendUint32(nil, 2)
+
+	chk := buildCHK(chkSection{"SWNM", swnm}, chkSection{"WAV ", wav}, buildSTR("Door open", `sound\door.wav`))
+	md := parseCHK(t, chk, Config{})
+
+	if len(md.Switches) != 1 || md.Switches[0].ID != 3 || md.Switches[0].Name != "Door open" {
+		t.Errorf("Unexpected switches: %+v", md.Switches)
+	}
+	if len(md.WAVs) != 1 || md.WAVs[0] != `sound\door.wav` {
+		t.Errorf("Unexpected WAVs: %v", md.WAVs)
+	}
+
+	// Truncated sub-section:
+	chk = buildCHK(chkSection{"SWNM", swnm})
+	parseCHK(t, chk[:len(chk)-100], Config{})
+}
+
+func TestColorReconciliation(t *testing.T) {
+	colr := []byte{0, 5, 2, 99} // Slot 1: Brown instead of default Blue; slot 3: invalid
+
+	cases := []struct {
+		name      string
+		gameType  *repcore.GameType
+		headerIDs []uint32
+		colors    []*repcore.Color
+		sources   []*repcore.ColorSource
+	}{
+		{
+			name:      "melee",
+			gameType:  repcore.GameTypeMelee,
+			headerIDs: []uint32{0, 1, 200, 3},
+			colors:    []*repcore.Color{repcore.ColorRed, repcore.ColorBlue, repcore.ColorTeal, repcore.ColorPurple},
+			sources:   []*repcore.ColorSource{repcore.ColorSourceHeader, repcore.ColorSourceHeader, repcore.ColorSourceMap, repcore.ColorSourceHeader},
+		},
+		{
+			name:      "ums",
+			gameType:  repcore.GameTypeUMS,
+			headerIDs: []uint32{0, 1, 2, 3},
+			colors:    []*repcore.Color{repcore.ColorRed, repcore.ColorBrown, repcore.ColorTeal, repcore.ColorPurple},
+			sources:   []*repcore.ColorSource{repcore.ColorSourceHeader, repcore.ColorSourceMap, repcore.ColorSourceHeader, repcore.ColorSourceHeader},
+		},
+	}
+
+	for _, c := range cases {
+		r := &rep.Replay{Header: &rep.Header{Type: c.gameType}}
+		for _, id := range c.headerIDs {
+			r.Header.Slots = append(r.Header.Slots, &rep.Player{
+				Color:       repcore.ColorByID(id),
+				ColorSource: repcore.ColorSourceHeader,
+			})
+		}
+		if err := parseMapData(buildCHK(chkSection{"COLR", colr}), r, Config{}); err != nil {
+			t.Fatalf("[%s] Failed to parse map data: %v", c.name, err)
+		}
+		for i, p := range r.Header.Slots {
+			if p.Color != c.colors[i] || p.ColorSource != c.sources[i] {
+				t.Errorf("[%s][%d] Expected: %v (%v), got: %v (%v)", c.name, i, c.colors[i], c.sources[i], p.Color, p.ColorSource)
+			}
+		}
+	}
+}
+
+func TestSlotColors(t *testing.T) {
+	// Player colors section with an extended SC:R color for slot 10 only:
+	cclr := make([]byte, 12*16)
+	copy(cclr[9*16:], repcore.ColorMagenta.Footprint())
+	repData := buildReplay(t, testCmdsData(), buildCHK(), &repencoder.Section{StrID: 1380729667, Data: cclr}) // "CCLR"
+
+	r, err := Parse(repData)
+	if err != nil {
+		t.Fatalf("Failed to parse replay: %v", err)
+	}
+
+	cases := []struct {
+		slot   int
+		color  *repcore.Color
+		source *repcore.ColorSource
+	}{
+		{7, repcore.ColorRed, repcore.ColorSourceHeader}, // Header color ID 0
+		{8, repcore.ColorGreen, repcore.ColorSourceDefault},
+		{9, repcore.ColorMagenta, repcore.ColorSourceCCLR},
+		{10, repcore.ColorTan, repcore.ColorSourceDefault},
+		{11, repcore.ColorAqua, repcore.ColorSourceDefault},
+	}
+	for _, c := range cases {
+		p := r.Header.Slots[c.slot]
+		if p.Color != c.color || p.ColorSource != c.source {
+			t.Errorf("[%d] Expected: %v (%v), got: %v (%v)", c.slot, c.color, c.source, p.Color, p.ColorSource)
+		}
+	}
+}
+
+func TestCHKHash(t *testing.T) {
+	chk := buildCHK(buildSTR("Map"))
+	md := parseCHK(t, chk, Config{})
+
+	sum := sha1.Sum(chk)
+	if exp := hex.EncodeToString(sum[:]); md.CHKHash != exp {
+		t.Errorf("Expected: %v, got: %v", exp, md.CHKHash)
+	}
+}
+
+// placedUnit builds a placed unit entry of the "UNIT" sub-section.
+func placedUnit(unitID, x, y uint16) []byte {
+	u := make([]byte, 36)
+	binary.LittleEndian.PutUint16(u[4:], x)
+	binary.LittleEndian.PutUint16(u[6:], y)
+	binary.LittleEndian.PutUint16(u[8:], unitID)
+	binary.LittleEndian.PutUint32(u[16:], 1500)
+	return u
+}
+
+func TestMapFingerprint(t *testing.T) {
+	era := chkSection{"ERA ", []byte{4, 0}}
+	mtxm := chkSection{"MTXM", []byte{1, 0, 2, 0, 3, 0, 4, 0}}
+	mineral := placedUnit(repcmd.UnitIDMineralField1, 100, 200)
+	geyser := placedUnit(repcmd.UnitIDVespeneGeyser, 300, 200)
+	forc := chkSection{"FORC", make([]byte, 20)}
+
+	fingerprint := func(sections ...chkSection) string {
+		return parseCHK(t, buildCHK(sections...), Config{}).Fingerprint
+	}
+
+	base := fingerprint(era, mtxm, chkSection{"UNIT", append(mineral, geyser...)}, buildSTR("Map"))
+	cases := []struct {
+		name string
+		fp   string
+		same bool
+	}{
+		{"strings and forces", fingerprint(era, forc, mtxm, chkSection{"UNIT", append(geyser, mineral...)}, buildSTR("맵 이름")), true},
+		{"tile set", fingerprint(chkSection{"ERA ", []byte{5, 0}}, mtxm, chkSection{"UNIT", append(mineral, geyser...)}), false},
+		{"tiles", fingerprint(era, chkSection{"MTXM", []byte{1, 0, 2, 0, 3, 0, 5, 0}}, chkSection{"UNIT", append(mineral, geyser...)}), false},
+		{"resources", fingerprint(era, mtxm, chkSection{"UNIT", mineral}), false},
+	}
+	for _, c := range cases {
+		if same := c.fp == base; same != c.same {
+			t.Errorf("[%s] Expected same: %v, got: %v", c.name, c.same, same)
+		}
+	}
+}
+
+func TestParseStrings(t *testing.T) {
+	chk := buildCHK(buildSTR("Map", "", "맵"))
+
+	if md := parseCHK(t, chk, Config{}); md.Strings != nil {
+		t.Errorf("Expected no strings, got: %v", md.Strings)
+	}
+
+	md := parseCHK(t, chk, Config{MapStrings: true})
+	exp := []string{"Map", "", "맵"}
+	if len(md.Strings) != len(exp) {
+		t.Fatalf("Expected: %v strings, got: %v", len(exp), len(md.Strings))
+	}
+	for i, s := range exp {
+		if md.Strings[i] != s {
+			t.Errorf("[%d] Expected: %q, got: %q", i, s, md.Strings[i])
+		}
+	}
+}
+
+func TestMapAnomalies(t *testing.T) {
+	required := []chkSection{
+		{"VER ", []byte{0xce, 0}}, {"UNIT", nil}, {"DIM ", []byte{64, 0, 64, 0}}, {"ERA ", []byte{4, 0}},
+		{"OWNR", make([]byte, 12)}, {"SIDE", make([]byte, 12)}, {"MTXM", make([]byte, 8)},
+	}
+	str := buildSTR("Map")
+
+	badSTR := buildSTR("Map", "Desc")
+	binary.LittleEndian.PutUint16(badSTR.data[4:], 1000) // Offset of string #2 out of range
+	badSTR.data[0] = 100                                 // Strings count: way more than offsets present
+
+	type anomaly struct {
+		t       *repcore.MapAnomalyType
+		section string
+	}
+	cases := []struct {
+		name      string
+		sections  []chkSection
+		anomalies []anomaly
+	}{
+		{"valid", append(required, str), nil},
+		{"missing", required[1:], []anomaly{
+			{repcore.MapAnomalyTypeMissingSection, "VER "},
+			{repcore.MapAnomalyTypeMissingSection, "STR "},
+		}},
+		{"duplicate and unknown", append(required, str, chkSection{"ERA ", []byte{5, 0}}, chkSection{"JUNK", nil}, str), []anomaly{
+			{repcore.MapAnomalyTypeDuplicateSection, "ERA "},
+			{repcore.MapAnomalyTypeUnknownSection, "JUNK"},
+			{repcore.MapAnomalyTypeDuplicateSection, "STR "},
+		}},
+		{"size", append(required, str, chkSection{"SIDE", make([]byte, 8)}), []anomaly{
+			{repcore.MapAnomalyTypeDuplicateSection, "SIDE"},
+			{repcore.MapAnomalyTypeInvalidSize, "SIDE"},
+		}},
+		{"strings", append(required, badSTR), []anomaly{
+			{repcore.MapAnomalyTypeInvalidSize, "STR "},
+			{repcore.MapAnomalyTypeInvalidString, "STR "},
+		}},
+	}
+
+	for _, c := range cases {
+		md := parseCHK(t, buildCHK(c.sections...), Config{})
+		if len(md.Anomalies) != len(c.anomalies) {
+			t.Errorf("[%s] Expected: %d anomalies, got: %d", c.name, len(c.anomalies), len(md.Anomalies))
+			continue
+		}
+		for i, a := range md.Anomalies {
+			if a.Type != c.anomalies[i].t || a.Section != c.anomalies[i].section {
+				t.Errorf("[%s] [%d] Expected: %v %q, got: %v %q", c.name, i, c.anomalies[i].t, c.anomalies[i].section, a.Type, a.Section)
+			}
+		}
+	}
+
+	// Negative size: parsing must stop at the section
+	chk := buildCHK(append(required, str)...)
+	chk = append(chk, "MRGN\xf0\xff\xff\xff"...)
+	chk = append(chk, make([]byte, 16)...)
+	md := parseCHK(t, chk, Config{})
+	if len(md.Anomalies) != 1 || md.Anomalies[0].Type != repcore.MapAnomalyTypeInvalidSize || md.Anomalies[0].Details != "negative size: -16" {
+		t.Errorf("Unexpected anomalies: %+v", md.Anomalies)
+	}
+}
+
+func TestParseTruncatedMapData(t *testing.T) {
+	cases := []struct {
+		name    string
+		section chkSection
+		cut     int // Number of bytes cut from the end
+		check   func(md *rep.MapData) bool
+	}{
+		{"VER ", chkSection{"VER ", []byte{0xce, 0}}, 1, func(md *rep.MapData) bool { return md.Version == 0 }},
+		{"MTXM", chkSection{"MTXM", []byte{1, 0, 2, 0, 3, 0, 4, 0}}, 3, func(md *rep.MapData) bool {
+			return slices.Equal(md.Tiles, []uint16{1, 2, 0, 0})
+		}},
+		{"STR ", buildSTR("Map"), 2, func(md *rep.MapData) bool { return md.Name == "Ma" }},
+	}
+
+	for _, c := range cases {
+		chk := buildCHK(chkSection{"SPRP", []byte{1, 0, 0, 0}}, c.section)
+		md := parseCHK(t, chk[:len(chk)-c.cut], Config{})
+		if !c.check(md) {
+			t.Errorf("[%s] Unexpected map data: %+v", c.name, md)
+		}
+		if !slices.ContainsFunc(md.Anomalies, func(a *rep.MapAnomaly) bool {
+			return a.Type == repcore.MapAnomalyTypeInvalidSize && a.Section == c.section.id
+		}) {
+			t.Errorf("[%s] Expected: invalid size anomaly, got: %v", c.name, md.Anomalies)
+		}
+	}
+}
+
+func TestSectionDone(t *testing.T) {
+	repData := buildReplay(t, cmdBlock(10, []byte{0, repcmd.TypeIDHotkey, 0, 1}), buildCHK())
+
+	var parsed []*Section
+	cfg := Config{Commands: true, SectionDone: func(s *Section, decode, parse time.Duration) {
+		if parse > 0 {
+			parsed = append(parsed, s)
+		}
+	}}
+	if _, err := ParseConfig(repData, cfg); err != nil {
+		t.Fatalf("Failed to parse replay: %v", err)
+	}
+
+	// Map data is not parsed due to the config
+	exp := []*Section{SectionReplayID, SectionHeader, SectionCommands, SectionPlayerNames}
+	if len(parsed) < len(exp) {
+		t.Fatalf("Expected: %v, got: %v", exp, parsed)
+	}
+	for i, s := range exp {
+		if parsed[i] != s {
+			t.Errorf("Expected: %v, got: %v", s.ID, parsed[i].ID)
+		}
+	}
+}
+
+func TestParseConfigInto(t *testing.T) {
+	repData := buildReplay(t, cmdBlock(10, []byte{0, repcmd.TypeIDHotkey, 0, 1}, chatCmdData(1, "gl hf")), buildCHK())
+
+	r := new(rep.Replay)
+	if err := ParseConfigInto(repData, Config{Commands: true}, r); err != nil {
+		t.Fatalf("Failed to parse replay: %v", err)
+	}
+	cs := r.Commands
+	if len(cs.Cmds) != 2 {
+		t.Fatalf("Expected: %d cmds, got: %d", 2, len(cs.Cmds))
+	}
+	r.ComputeConfig(rep.ComputeConfig{})
+
+	// Commands are not retained if they are not parsed:
+	if err := ParseConfigInto(repData, Config{}, r); err != nil {
+		t.Fatalf("Failed to parse replay: %v", err)
+	}
+	if r.Commands != nil || r.Computed != nil || r.Header.Host != "Alice" {
+		t.Errorf("Expected no commands and computed data, got: %v, %v", r.Commands, r.Computed)
+	}
+
+	// Commands are reused:
+	r.Commands = cs
+	if err := ParseConfigInto(repData, Config{Commands: true}, r); err != nil {
+		t.Fatalf("Failed to parse replay: %v", err)
+	}
+	if r.Commands != cs || len(cs.Cmds) != 2 || cs.Cmds[1].(*repcmd.ChatCmd).Message != "gl hf" {
+		t.Errorf("Expected reused commands, got: %+v", r.Commands)
+	}
+
+	if err := ParseConfigInto([]byte("invalid"), Config{Commands: true}, r); err == nil {
+		t.Errorf("Expected error, got: %v", err)
+	}
+}
+
+func TestInterning(t *testing.T) {
+	repData := buildReplay(t, cmdBlock(10, []byte{0, 0xff, 1, 2}), buildCHK()) // Unknown command
+
+	var rs [2]*rep.Replay
+	for i := range rs {
+		var err error
+		if rs[i], err = ParseConfig(repData, Config{Commands: true, MapData: true}); err != nil {
+			t.Fatalf("Failed to parse replay: %v", err)
+		}
+	}
+
+	cases := []struct {
+		name   string
+		s1, s2 string
+	}{
+		{"host", rs[0].Header.Host, rs[1].Header.Host},
+		{"player name", rs[0].Header.Players[1].Name, rs[1].Header.Players[1].Name},
+	}
+	for _, c := range cases {
+		if c.s1 == "" || unsafe.StringData(c.s1) != unsafe.StringData(c.s2) {
+			t.Errorf("[%s] Expected shared strings, got: %q, %q", c.name, c.s1, c.s2)
+		}
+	}
+
+	t1 := rs[0].Commands.ParseErrCmds[0].Type
+	if t2 := rs[1].Commands.ParseErrCmds[0].Type; t1 != t2 {
+		t.Errorf("Expected same unknown type, got: %p, %p", t1, t2)
+	}
+}
+
+func TestCmdsCapacity(t *testing.T) {
+	cmds := cmdBlock(10, []byte{0, repcmd.TypeIDHotkey, 0, 1}, []byte{0, repcmd.TypeIDHotkey, 0, 2})
+	repData := buildReplay(t, bytes.Repeat(cmds, 10), buildCHK())
+
+	cases := []struct {
+		capacity int
+		expCap   int
+	}{
+		{0, len(cmds) * 10 / avgCmdSize},
+		{100, 100},
+		{-1, 20}, // Grown by append
+	}
+	for _, c := range cases {
+		r, err := ParseConfig(repData, Config{Commands: true, Debug: true, CmdsCapacity: c.capacity})
+		if err != nil {
+			t.Fatalf("Failed to parse replay: %v", err)
+		}
+		if len(r.Commands.Cmds) != 20 || cap(r.Commands.Cmds) < c.expCap || c.capacity > 0 && cap(r.Commands.Debug.CmdOffsets) != c.expCap {
+			t.Errorf("[%d] Expected: %d cap, got: %d (len: %d)", c.capacity, c.expCap, cap(r.Commands.Cmds), len(r.Commands.Cmds))
+		}
+	}
+}
+
+func TestSkipRawStrings(t *testing.T) {
+	repData := buildReplay(t, nil, buildCHK())
+
+	r, err := ParseConfig(repData, Config{})
+	if err != nil {
+		t.Fatalf("Failed to parse replay: %v", err)
+	}
+	r2, err := ParseConfig(repData, Config{SkipRawStrings: true})
+	if err != nil {
+		t.Fatalf("Failed to parse replay: %v", err)
+	}
+
+	h, h2 := r.Header, r2.Header
+	if h.RawHost == "" || h2.RawHost != "" || h2.RawTitle != "" || h2.RawMap != "" || h2.Players[0].RawName != "" {
+		t.Errorf("Expected no raw strings, got: %q, %q, %q, %q", h2.RawHost, h2.RawTitle, h2.RawMap, h2.Players[0].RawName)
+	}
+	if h2.Host != h.Host || h2.Players[0].Name != h.Players[0].Name {
+		t.Errorf("Expected: %q, %q, got: %q, %q", h.Host, h.Players[0].Name, h2.Host, h2.Players[0].Name)
+	}
+	if fp, fp2 := h.GameFingerprint(), h2.GameFingerprint(); fp != fp2 {
+		t.Errorf("Expected: %v, got: %v", fp, fp2)
+	}
+}
+
+func TestStartTimeLocation(t *testing.T) {
+	repData := buildReplay(t, testCmdsData(), buildCHK())
+	tokyo := time.FixedZone("Tokyo", 9*60*60)
+
+	cases := []struct {
+		name string
+		loc  *time.Location
+		exp  *time.Location
+	}{
+		{"default", nil, time.Local},
+		{"utc", time.UTC, time.UTC},
+		{"custom", tokyo, tokyo},
+	}
+
+	for _, c := range cases {
+		r, err := ParseConfig(repData, Config{Location: c.loc})
+		if err != nil {
+			t.Fatalf("[%s] Failed to parse replay: %v", c.name, err)
+		}
+		h := r.Header
+		if h.StartTime.Location() != c.exp {
+			t.Errorf("[%s] Expected: %v, got: %v", c.name, c.exp, h.StartTime.Location())
+		}
+		if h.StartTimeUnix != 1700000000 || h.StartTime.Unix() != h.StartTimeUnix {
+			t.Errorf("[%s] Expected: %v, got: %v (%v)", c.name, 1700000000, h.StartTimeUnix, h.StartTime.Unix())
+		}
+	}
+}
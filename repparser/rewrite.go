@@ -0,0 +1,339 @@
+// This file contains functions rewriting replays.
+
+package repparser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"unicode/utf8"
+
+	"github.com/icza/screp/rep/repcmd"
+	"github.com/icza/screp/rep/repcore"
+	"github.com/icza/screp/repparser/repdecoder"
+	"github.com/icza/screp/repparser/repencoder"
+)
+
+// DecodeSections decodes the sections of an SC:BW replay without parsing them.
+// The replay ID section is validated but not returned, the returned sections
+// can be encoded with repencoder.Encode().
+// If decoding panics, the recovered value is returned in an error wrapping ErrParsing.
+func DecodeSections(repData []byte) (sections []*repencoder.Section, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			sections, err = nil, fmt.Errorf("%w: decoding error: %v", ErrParsing, r)
+		}
+	}()
+
+	dec := repdecoder.New(repData)
+	defer dec.Close()
+
+	for sectionCounter := 0; ; sectionCounter++ {
+		if err := dec.NewSection(); err != nil {
+			if err == repdecoder.ErrNoMoreSections {
+				break
+			}
+			return nil, fmt.Errorf("Decoder.NewSection() error: %w", err)
+		}
+
+		var size int32
+		if sectionCounter < len(Sections) {
+			if size = Sections[sectionCounter].Size; size == 0 {
+				sizeData, _, err := dec.Section(4)
+				if err != nil {
+					return nil, fmt.Errorf("Decoder.Section() error when reading size: %w", err)
+				}
+				size = int32(binary.LittleEndian.Uint32(sizeData))
+			}
+		}
+
+		data, sectionID, err := dec.Section(size)
+		if sectionCounter == SectionReplayID.ID {
+			if err != nil || parseReplayID(data, nil, Config{}) != nil {
+				return nil, ErrNotReplayFile
+			}
+			continue
+		}
+		if err != nil {
+			if err == io.EOF || sectionCounter >= len(Sections) {
+				break // New sections with StrID are optional
+			}
+			return nil, fmt.Errorf("Decoder.Section() error: %w", err)
+		}
+
+		s := &repencoder.Section{Data: data}
+		if sectionCounter >= len(Sections) {
+			s.StrID = sectionID
+			// Only sections with known size are compressed, the rest is returned as-is by the decoder:
+			ms := ModernSections[sectionID]
+			s.Raw = ms == nil || ms.Size == 0
+		}
+		sections = append(sections, s)
+	}
+
+	return sections, nil
+}
+
+// Anonymize returns a copy of the replay with identities removed, suitable for sharing games.
+// Player names are replaced with "Player 1", "Player 2" etc., the host is replaced
+// with the new name of the host player, the game title is cleared,
+// chat messages and custom sections of 3rd party vendors (which may contain user IDs) are removed.
+//
+// Chat messages in command blocks that fail to parse cannot be found and are retained.
+// The result is encoded by repencoder.Encode().
+func Anonymize(repData []byte) ([]byte, error) {
+	sections, err := DecodeSections(repData)
+	if err != nil {
+		return nil, err
+	}
+	if len(sections) < 3 {
+		return nil, ErrParsing
+	}
+	r, err := ParseConfig(repData, Config{Commands: true, Debug: true})
+	if err != nil {
+		return nil, err
+	}
+
+	header := sections[0].Data
+	var playerNames []byte
+	if len(sections) > 3 && sections[3].StrID == 0 {
+		playerNames = sections[3].Data
+	}
+
+	clear(header[0x18 : 0x18+28]) // Title
+	host := header[0x48 : 0x48+24]
+	hostName := bytes.Clone(cBytes(host))
+	clear(host)
+
+	for i, count := 0, 0; i < 12; i++ {
+		rawName := header[0xa1+i*36+11 : 0xa1+i*36+11+25]
+		if rawName[0] == 0 {
+			continue
+		}
+		count++
+		name := fmt.Sprint("Player ", count)
+		if len(hostName) > 0 && bytes.Equal(cBytes(rawName), hostName) {
+			copy(host, name)
+		}
+		clear(rawName)
+		copy(rawName, name)
+
+		if pos := i * 96; pos+96 <= len(playerNames) && playerNames[pos] != 0 {
+			clear(playerNames[pos : pos+96])
+			copy(playerNames[pos:], name)
+		}
+	}
+
+	chatCmds := map[uint32]uint32{}
+	for i, cmd := range r.Commands.Cmds {
+		if _, ok := cmd.(*repcmd.ChatCmd); ok {
+			chatCmds[r.Commands.Debug.CmdOffsets[i]] = chatCmdSize
+		}
+	}
+	sections[1].Data = removeCmds(sections[1].Data, chatCmds)
+
+	kept := sections[:0]
+	for _, s := range sections {
+		if !s.Raw {
+			kept = append(kept, s)
+		}
+	}
+
+	return encodeSections(kept)
+}
+
+// Trim returns a copy of the replay truncated at the given frame:
+// commands after the frame are removed and the frames count of the header is adjusted.
+//
+// The result is encoded by repencoder.Encode().
+func Trim(repData []byte, to repcore.Frame) ([]byte, error) {
+	sections, err := DecodeSections(repData)
+	if err != nil {
+		return nil, err
+	}
+	if len(sections) < 3 {
+		return nil, ErrParsing
+	}
+
+	header := sections[0].Data
+	if frames := repcore.Frame(binary.LittleEndian.Uint32(header[0x01:])); frames > to {
+		binary.LittleEndian.PutUint32(header[0x01:], uint32(to))
+	}
+
+	cmds := sections[1].Data
+	for pos, size := uint32(0), uint32(len(cmds)); pos+5 <= size; pos += 5 + uint32(cmds[pos+4]) {
+		if repcore.Frame(binary.LittleEndian.Uint32(cmds[pos:])) > to {
+			sections[1].Data = cmds[:pos]
+			break
+		}
+	}
+
+	return encodeSections(sections)
+}
+
+// ToModern converts a legacy (pre 1.18) replay into the modern replay format.
+// All sections are retained as-is, except that strings of the header stored in
+// a legacy encoding (e.g. EUC-KR, see cString()) are converted to UTF-8 (used by modern replays),
+// and the player names section (holding names not fitting into the header) is added.
+//
+// Modern replays are returned unchanged.
+// The result is encoded by repencoder.Encode().
+func ToModern(repData []byte) ([]byte, error) {
+	dec := repdecoder.New(repData)
+	repFormat := dec.RepFormat()
+	dec.Close()
+	if repFormat != repdecoder.RepFormatLegacy {
+		return repData, nil
+	}
+
+	sections, err := DecodeSections(repData)
+	if err != nil {
+		return nil, err
+	}
+	if len(sections) < 3 {
+		return nil, ErrParsing
+	}
+	header := sections[0].Data
+	if len(header) < 0xa1+12*36 {
+		return nil, ErrParsing
+	}
+
+	toUTF8(header[0x18 : 0x18+28]) // Title
+	toUTF8(header[0x48 : 0x48+24]) // Host
+	toUTF8(header[0x61 : 0x61+26]) // Map
+
+	playerNames := make([]byte, 0x300)
+	for i := 0; i < 12; i++ {
+		rawName := header[0xa1+i*36+11 : 0xa1+i*36+11+25]
+		name, _ := cString(rawName)
+		toUTF8(rawName)
+		if pos := i * 96; pos+96 <= len(playerNames) {
+			putCString(playerNames[pos:pos+96], name)
+		}
+	}
+
+	sections = append(sections[:3], append([]*repencoder.Section{{Data: playerNames}}, sections[3:]...)...)
+
+	return encodeSections(sections)
+}
+
+// ToLegacy converts a modern replay into the legacy (pre 1.18) replay format,
+// playable on 1.16.1. The header, commands and map data are retained as-is,
+// the player names section and the modern sections are dropped (the legacy format
+// has no place for them). Strings are not converted from UTF-8.
+//
+// ErrNotLegacyRepresentable is returned if the replay has commands introduced in 1.21.
+// Legacy replays are returned unchanged.
+// The result is encoded by repencoder.EncodeLegacy().
+func ToLegacy(repData []byte) ([]byte, error) {
+	r, err := ParseConfig(repData, Config{Commands: true})
+	if err != nil {
+		return nil, err
+	}
+	if r.RepFormat == repdecoder.RepFormatLegacy {
+		return repData, nil
+	}
+
+	for _, cmd := range r.Commands.Cmds {
+		if is121Cmd(cmd) {
+			return nil, ErrNotLegacyRepresentable
+		}
+	}
+	for _, cmd := range r.Commands.ParseErrCmds {
+		if is121Cmd(cmd) {
+			return nil, ErrNotLegacyRepresentable
+		}
+	}
+
+	sections, err := DecodeSections(repData)
+	if err != nil {
+		return nil, err
+	}
+	if len(sections) < 3 {
+		return nil, ErrParsing
+	}
+
+	buf := &bytes.Buffer{}
+	if err := repencoder.EncodeLegacy(buf, sections[:3]); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// is121Cmd tells if the command is of a type introduced in 1.21.
+func is121Cmd(cmd repcmd.Cmd) bool {
+	switch cmd.BaseCmd().Type.ID {
+	case repcmd.TypeIDRightClick121, repcmd.TypeIDTargetedOrder121, repcmd.TypeIDUnload121,
+		repcmd.TypeIDSelect121, repcmd.TypeIDSelectAdd121, repcmd.TypeIDSelectRemove121:
+		return true
+	}
+	return false
+}
+
+// toUTF8 converts the 0x00 terminated string in data to UTF-8 in place
+// if it is stored in a legacy encoding (see cString()).
+func toUTF8(data []byte) {
+	if s, orig := cString(data); s != orig {
+		putCString(data, s)
+	}
+}
+
+// putCString writes s into data as a 0x00 terminated string,
+// truncated at a rune boundary if it does not fit.
+func putCString(data []byte, s string) {
+	if len(s) > len(data)-1 {
+		n := len(data) - 1
+		for n > 0 && !utf8.RuneStart(s[n]) {
+			n--
+		}
+		s = s[:n]
+	}
+	clear(data)
+	copy(data, s)
+}
+
+// encodeSections encodes the given sections using repencoder.Encode().
+func encodeSections(sections []*repencoder.Section) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := repencoder.Encode(buf, sections); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// chatCmdSize is the size of a chat command:
+// player ID, type ID, sender slot ID and the message.
+const chatCmdSize = 1 + 1 + 1 + 80
+
+// removeCmds returns the commands data without the commands at the given offsets
+// (the values are the command sizes). Command blocks left empty are removed.
+func removeCmds(data []byte, removed map[uint32]uint32) []byte {
+	var result []byte
+	for pos, size := uint32(0), uint32(len(data)); pos+5 <= size; {
+		blockEndPos := min(pos+5+uint32(data[pos+4]), size)
+		block := append([]byte{}, data[pos:pos+5]...) // Frame and block size
+		for p := pos + 5; p < blockEndPos; {
+			if cmdSize, ok := removed[p]; ok {
+				p += cmdSize
+				continue
+			}
+			block = append(block, data[p])
+			p++
+		}
+		if len(block) > 5 {
+			block[4] = byte(len(block) - 5)
+			result = append(result, block...)
+		}
+		pos = blockEndPos
+	}
+	return result
+}
+
+// cBytes returns the bytes of a 0x00 terminated string (excluding the terminating 0x00).
+func cBytes(data []byte) []byte {
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		return data[:i]
+	}
+	return data
+}
@@ -0,0 +1,342 @@
+package repparser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/icza/screp/rep/repcmd"
+	"github.com/icza/screp/rep/repcore"
+	"github.com/icza/screp/repparser/repdecoder"
+	"github.com/icza/screp/repparser/repencoder"
+)
+
+// buildReplay builds a synthetic modern replay of 2 players ("Alice" hosting the game and "Bob")
+// having the given commands and map data, and optional extra sections.
+func buildReplay(t *testing.T, cmds, chk []byte, extra ...*repencoder.Section) []byte {
+	t.Helper()
+
+	header := make([]byte, 0x279)
+	binary.LittleEndian.PutUint32(header[0x01:], 1000)       // Frames
+	binary.LittleEndian.PutUint32(header[0x08:], 1700000000) // Start time
+	copy(header[0x18:], "Secret game")
+	binary.LittleEndian.PutUint16(header[0x34:], 64) // Map width
+	binary.LittleEndian.PutUint16(header[0x36:], 64) // Map height
+	header[0x39] = 2                                 // Available slots
+	copy(header[0x48:], "Alice")
+	for i, name := range []string{"Alice", "Bob"} {
+		ps := header[0xa1+i*36:]
+		binary.LittleEndian.PutUint16(ps, uint16(i)) // Slot ID
+		ps[4] = byte(i)                              // Player ID
+		ps[8] = 2                                    // Human
+		ps[10] = byte(i + 1)                         // Team
+		copy(ps[11:], name)
+	}
+	playerNames := make([]byte, 0x300)
+	copy(playerNames, "Alice")
+	copy(playerNames[96:], "Bob")
+
+	buf := &bytes.Buffer{}
+	err := repencoder.Encode(buf, append([]*repencoder.Section{
+		{Data: header},
+		{Data: cmds},
+		{Data: chk},
+		{Data: playerNames},
+		{StrID: 1952539219, Data: []byte("custom data"), Raw: true}, // "Sbat"
+	}, extra...))
+	if err != nil {
+		t.Fatalf("Failed to encode replay: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// chatCmdData returns the data of a chat command.
+func chatCmdData(playerID byte, msg string) []byte {
+	data := make([]byte, chatCmdSize)
+	data[0], data[1], data[2] = playerID, repcmd.TypeIDChat, playerID
+	copy(data[3:], msg)
+	return data
+}
+
+// cmdBlock returns a command block of the given frame holding the given commands.
+func cmdBlock(frame uint32, cmds ...[]byte) []byte {
+	block := binary.LittleEndian.AppendUint32(nil, frame)
+	block = append(block, 0)
+	for _, cmd := range cmds {
+		block = append(block, cmd...)
+	}
+	block[4] = byte(len(block) - 5)
+	return block
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	hotkey := []byte{1, repcmd.TypeIDHotkey, 0, 1}
+	cmds := append(cmdBlock(10, hotkey, chatCmdData(0, "gl hf")), cmdBlock(20, chatCmdData(1, "gg"))...)
+
+	// Commands data longer than a chunk:
+	for frame := uint32(30); len(cmds) < 3*0x2000; frame++ {
+		cmds = append(cmds, cmdBlock(frame, hotkey)...)
+	}
+
+	repData := buildReplay(t, cmds, nil)
+	r, err := ParseConfig(repData, Config{Commands: true})
+	if err != nil {
+		t.Fatalf("Failed to parse replay: %v", err)
+	}
+	h := r.Header
+	if h.Frames != 1000 || h.Title != "Secret game" || h.Host != "Alice" || len(h.Players) != 2 ||
+		h.Players[0].Name != "Alice" || h.Players[1].Name != "Bob" {
+		t.Errorf("Unexpected header: %+v", h)
+	}
+	if len(r.Commands.Cmds) != 3+(len(cmds)-5-4-chatCmdSize-5-chatCmdSize)/9 {
+		t.Errorf("Unexpected number of commands: %d", len(r.Commands.Cmds))
+	}
+
+	sections, err := DecodeSections(repData)
+	if err != nil {
+		t.Fatalf("Failed to decode sections: %v", err)
+	}
+	if len(sections) != 5 || !bytes.Equal(sections[1].Data, cmds) || string(sections[4].Data) != "custom data" ||
+		!sections[4].Raw || sections[4].StrID != 1952539219 {
+		t.Errorf("Unexpected sections: %d", len(sections))
+	}
+}
+
+func TestDecodeSectionsCorrupt(t *testing.T) {
+	cmds := cmdBlock(10, []byte{0, repcmd.TypeIDHotkey, 0, 1})
+	repData := buildReplay(t, cmds, buildCHK())
+
+	// The commands size is stored as a raw 4-byte chunk, make it negative:
+	sizeChunk := binary.LittleEndian.AppendUint32([]byte{1, 0, 0, 0, 4, 0, 0, 0}, uint32(len(cmds)))
+	i := bytes.Index(repData, sizeChunk)
+	if i < 0 {
+		t.Fatalf("Commands size not found")
+	}
+	binary.LittleEndian.PutUint32(repData[i+8:], 0xfffffff0)
+
+	if _, err := DecodeSections(repData); !errors.Is(err, ErrParsing) {
+		t.Errorf("Expected: %v, got: %v", ErrParsing, err)
+	}
+}
+
+func TestAnonymize(t *testing.T) {
+	hotkey := []byte{1, repcmd.TypeIDHotkey, 0, 1}
+	cmds := append(cmdBlock(10, chatCmdData(0, "gl hf"), hotkey, chatCmdData(1, "hf")), cmdBlock(20, chatCmdData(1, "gg"))...)
+
+	anon, err := Anonymize(buildReplay(t, cmds, nil))
+	if err != nil {
+		t.Fatalf("Failed to anonymize replay: %v", err)
+	}
+
+	r, err := ParseConfig(anon, Config{Commands: true})
+	if err != nil {
+		t.Fatalf("Failed to parse anonymized replay: %v", err)
+	}
+	h := r.Header
+	if h.Title != "" || h.Host != "Player 1" || len(h.Players) != 2 ||
+		h.Players[0].Name != "Player 1" || h.Players[1].Name != "Player 2" {
+		t.Errorf("Unexpected header: %+v", h)
+	}
+	if len(r.Commands.Cmds) != 1 || r.Commands.Cmds[0].BaseCmd().Type.ID != repcmd.TypeIDHotkey {
+		t.Errorf("Expected: only the hotkey command, got: %d commands", len(r.Commands.Cmds))
+	}
+	if bytes.Contains(anon, []byte("custom data")) {
+		t.Errorf("Expected custom section to be removed")
+	}
+}
+
+func TestTrim(t *testing.T) {
+	hotkey := []byte{1, repcmd.TypeIDHotkey, 0, 1}
+	var cmds []byte
+	for _, frame := range []uint32{10, 20, 30, 40} {
+		cmds = append(cmds, cmdBlock(frame, hotkey, hotkey)...)
+	}
+	repData := buildReplay(t, cmds, nil)
+
+	cases := []struct {
+		to           repcore.Frame
+		frames       repcore.Frame
+		cmdsCount    int
+		lastCmdFrame repcore.Frame
+	}{
+		{0, 0, 0, 0},
+		{10, 10, 2, 10},
+		{25, 25, 4, 20},
+		{40, 40, 8, 40},
+		{5000, 1000, 8, 40},
+	}
+	for _, c := range cases {
+		trimmed, err := Trim(repData, c.to)
+		if err != nil {
+			t.Fatalf("[to: %d] Failed to trim replay: %v", c.to, err)
+		}
+		r, err := ParseConfig(trimmed, Config{Commands: true})
+		if err != nil {
+			t.Fatalf("[to: %d] Failed to parse trimmed replay: %v", c.to, err)
+		}
+		var lastCmdFrame repcore.Frame
+		if n := len(r.Commands.Cmds); n > 0 {
+			lastCmdFrame = r.Commands.Cmds[n-1].BaseCmd().Frame
+		}
+		if r.Header.Frames != c.frames || len(r.Commands.Cmds) != c.cmdsCount || lastCmdFrame != c.lastCmdFrame {
+			t.Errorf("[to: %d] Expected: %d %d %d, got: %d %d %d", c.to, c.frames, c.cmdsCount, c.lastCmdFrame,
+				r.Header.Frames, len(r.Commands.Cmds), lastCmdFrame)
+		}
+	}
+}
+
+// buildLegacyReplay builds a synthetic legacy (pre 1.18) replay from the given header,
+// commands and map data. Chunks are stored uncompressed.
+func buildLegacyReplay(header, cmds, chk []byte) []byte {
+	buf := &bytes.Buffer{}
+	writeSection := func(data []byte) {
+		if len(data) == 0 {
+			return
+		}
+		buf.Write(binary.LittleEndian.AppendUint32(nil, 0)) // Checksum
+		buf.Write(binary.LittleEndian.AppendUint32(nil, uint32((len(data)+0x1fff)/0x2000)))
+		for len(data) > 0 {
+			chunk := data[:min(len(data), 0x2000)]
+			data = data[len(chunk):]
+			buf.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(chunk))))
+			buf.Write(chunk)
+		}
+	}
+
+	writeSection([]byte("reRS"))
+	writeSection(header)
+	writeSection(binary.LittleEndian.AppendUint32(nil, uint32(len(cmds))))
+	writeSection(cmds)
+	writeSection(binary.LittleEndian.AppendUint32(nil, uint32(len(chk))))
+	writeSection(chk)
+	return buf.Bytes()
+}
+
+func TestToModern(t *testing.T) {
+	header := make([]byte, 0x279)
+	binary.LittleEndian.PutUint32(header[0x01:], 1000) // Frames
+	copy(header[0x18:], "Legacy game")
+	copy(header[0x48:], "Alice")
+	for i, name := range []string{"Alice", "Bob"} {
+		ps := header[0xa1+i*36:]
+		binary.LittleEndian.PutUint16(ps, uint16(i)) // Slot ID
+		ps[4] = byte(i)                              // Player ID
+		ps[8] = 2                                    // Human
+		ps[10] = byte(i + 1)                         // Team
+		copy(ps[11:], name)
+	}
+	cmds := cmdBlock(10, chatCmdData(0, "gl hf"))
+	chk := buildCHK(chkSection{"VER ", []byte{0xcd, 0}})
+
+	legacy := buildLegacyReplay(header, cmds, chk)
+	r, err := ParseConfig(legacy, Config{Commands: true, MapData: true})
+	if err != nil {
+		t.Fatalf("Failed to parse legacy replay: %v", err)
+	}
+	if r.RepFormat != repdecoder.RepFormatLegacy {
+		t.Fatalf("Expected: legacy replay, got: %v", r.RepFormat)
+	}
+
+	modern, err := ToModern(legacy)
+	if err != nil {
+		t.Fatalf("Failed to convert replay: %v", err)
+	}
+	r2, err := ParseConfig(modern, Config{Commands: true, MapData: true})
+	if err != nil {
+		t.Fatalf("Failed to parse converted replay: %v", err)
+	}
+
+	h, h2 := r.Header, r2.Header
+	for i, c := range []struct {
+		got, exp any
+	}{
+		{r2.RepFormat, repdecoder.RepFormatModern},
+		{h2.Title, "Legacy game"},
+		{h2.Title, h.Title},
+		{h2.Frames, h.Frames},
+		{h2.Host, h.Host},
+		{len(h2.Players), 2},
+		{h2.Players[1].Name, "Bob"},
+		{h2.Players[1].Name, h.Players[1].Name},
+		{len(r2.Commands.Cmds), 1},
+		{r2.MapData.Version, uint16(0xcd)},
+	} {
+		if c.got != c.exp {
+			t.Errorf("[%d] Expected: %v, got: %v", i, c.exp, c.got)
+		}
+	}
+
+	if same, err := ToModern(modern); err != nil || !bytes.Equal(same, modern) {
+		t.Errorf("Expected modern replay to be returned unchanged, got error: %v", err)
+	}
+}
+
+func TestPutCString(t *testing.T) {
+	cases := []struct {
+		s, exp string
+	}{
+		{"abc", "abc"},
+		{"abcdef", "abcde"},
+		{"abcdé", "abcd"}, // é is 2 bytes in UTF-8, must not be cut in half
+		{"", ""},
+	}
+
+	for _, c := range cases {
+		data := []byte("xxxxxx")
+		putCString(data, c.s)
+		if got := string(cBytes(data)); got != c.exp {
+			t.Errorf("[%q] Expected: %q, got: %q", c.s, c.exp, got)
+		}
+	}
+}
+
+func TestToLegacy(t *testing.T) {
+	hotkey := []byte{1, repcmd.TypeIDHotkey, 0, 1}
+	cmds := append(cmdBlock(10, hotkey, chatCmdData(0, "gl hf")), cmdBlock(20, chatCmdData(1, "gg"))...)
+	chk := buildCHK(chkSection{"VER ", []byte{0xcd, 0}})
+	modern := buildReplay(t, cmds, chk)
+
+	legacy, err := ToLegacy(modern)
+	if err != nil {
+		t.Fatalf("Failed to convert replay: %v", err)
+	}
+	r, err := ParseConfig(legacy, Config{Commands: true, MapData: true})
+	if err != nil {
+		t.Fatalf("Failed to parse converted replay: %v", err)
+	}
+	h := r.Header
+	for i, c := range []struct {
+		got, exp any
+	}{
+		{r.RepFormat, repdecoder.RepFormatLegacy},
+		{h.Frames, repcore.Frame(1000)},
+		{h.Title, "Secret game"},
+		{len(h.Players), 2},
+		{h.Players[1].Name, "Bob"},
+		{len(r.Commands.Cmds), 3},
+		{r.MapData.Version, uint16(0xcd)},
+		{bytes.Contains(legacy, []byte("custom data")), false},
+	} {
+		if c.got != c.exp {
+			t.Errorf("[%d] Expected: %v, got: %v", i, c.exp, c.got)
+		}
+	}
+
+	sections, err := DecodeSections(legacy)
+	if err != nil {
+		t.Fatalf("Failed to decode sections: %v", err)
+	}
+	if len(sections) != 3 || !bytes.Equal(sections[1].Data, cmds) || !bytes.Equal(sections[2].Data, chk) {
+		t.Errorf("Unexpected sections: %d", len(sections))
+	}
+
+	if same, err := ToLegacy(legacy); err != nil || !bytes.Equal(same, legacy) {
+		t.Errorf("Expected legacy replay to be returned unchanged, got error: %v", err)
+	}
+
+	select121 := []byte{0, repcmd.TypeIDSelect121, 1, 1, 0, 0, 0}
+	if _, err := ToLegacy(buildReplay(t, cmdBlock(10, select121), chk)); err != ErrNotLegacyRepresentable {
+		t.Errorf("Expected: %v, got: %v", ErrNotLegacyRepresentable, err)
+	}
+}
@@ -2,44 +2,87 @@
 
 package repparser
 
-import "encoding/binary"
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
 
-// sliceReader aids reading data from a byte slice
+// errUnexpectedEnd is the error of reading past the end of the data of a sliceReader.
+var errUnexpectedEnd = errors.New("unexpected end of data")
+
+// sliceReader aids reading data from a byte slice.
+//
+// Reading past the end of the data does not panic: zero values are returned,
+// err is set and pos is moved past the end of the data, so parsing loops terminate.
 type sliceReader struct {
 	// b is the byte slice to read from
 	b []byte
 
 	// pos is the index of the next byte to read
 	pos uint32
+
+	// err is the error of the first read past the end of the data, sticky
+	err error
+}
+
+// next returns the next n bytes (capped), and advances pos.
+// If there are less than n bytes available, nil is returned and err is set.
+func (sr *sliceReader) next(n uint32) []byte {
+	end := uint64(sr.pos) + uint64(n)
+	if end > uint64(len(sr.b)) {
+		if sr.err == nil {
+			sr.err = fmt.Errorf("%w: reading %d bytes at offset %d, size: %d", errUnexpectedEnd, n, sr.pos, len(sr.b))
+		}
+		sr.pos = max(sr.pos, uint32(len(sr.b)))
+		return nil
+	}
+	r := sr.b[sr.pos:end:end]
+	sr.pos = uint32(end)
+	return r
+}
+
+// skip skips the next n bytes.
+func (sr *sliceReader) skip(n uint32) {
+	sr.next(n)
 }
 
 // getByte returns the next byte.
-func (sr *sliceReader) getByte() (r byte) {
-	r, sr.pos = sr.b[sr.pos], sr.pos+1
-	return
+func (sr *sliceReader) getByte() byte {
+	if b := sr.next(1); b != nil {
+		return b[0]
+	}
+	return 0
 }
 
 // getUint16 returns the next 2 bytes as an uint16 value.
-func (sr *sliceReader) getUint16() (r uint16) {
-	r, sr.pos = binary.LittleEndian.Uint16(sr.b[sr.pos:]), sr.pos+2
-	return
+func (sr *sliceReader) getUint16() uint16 {
+	if b := sr.next(2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
 }
 
 // getUint32 returns the next 4 bytes as an uint32 value.
-func (sr *sliceReader) getUint32() (r uint32) {
-	r, sr.pos = binary.LittleEndian.Uint32(sr.b[sr.pos:]), sr.pos+4
-	return
+func (sr *sliceReader) getUint32() uint32 {
+	if b := sr.next(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
 }
 
 // getString returns the next size bytes as a string.
-func (sr *sliceReader) getString(size uint32) (r string) {
-	r, sr.pos = string(sr.b[sr.pos:sr.pos+size]), sr.pos+size
-	return
+func (sr *sliceReader) getString(size uint32) string {
+	return string(sr.next(size))
 }
 
-// readSlice returns a the next size bytes as a slice.
+// readSlice returns a copy of the next size bytes as a slice.
+// If there are less than size bytes available, the missing bytes are zero (and err is set).
 func (sr *sliceReader) readSlice(size uint32) (r []byte) {
 	r = make([]byte, size)
-	sr.pos += uint32(copy(r, sr.b[sr.pos:]))
+	if int(sr.pos) < len(sr.b) {
+		copy(r, sr.b[sr.pos:])
+	}
+	sr.next(size)
 	return
 }
@@ -0,0 +1,76 @@
+package repparser
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestSliceReader(t *testing.T) {
+	sr := &sliceReader{b: []byte{1, 2, 3, 4, 5, 6, 7, 8, 9}}
+
+	cases := []struct {
+		name string
+		read func() any
+		exp  any
+		pos  uint32
+		err  bool
+	}{
+		{"byte", func() any { return sr.getByte() }, byte(1), 1, false},
+		{"uint16", func() any { return sr.getUint16() }, uint16(0x0302), 3, false},
+		{"uint32", func() any { return sr.getUint32() }, uint32(0x07060504), 7, false},
+		{"uint32 out of range", func() any { return sr.getUint32() }, uint32(0), 9, true},
+		{"byte after error", func() any { return sr.getByte() }, byte(0), 9, true},
+		{"string out of range", func() any { return sr.getString(1) }, "", 9, true},
+	}
+
+	for _, c := range cases {
+		if got := c.read(); got != c.exp {
+			t.Errorf("[%s] Expected: %v, got: %v", c.name, c.exp, got)
+		}
+		if sr.pos != c.pos {
+			t.Errorf("[%s] Expected pos: %v, got: %v", c.name, c.pos, sr.pos)
+		}
+		if got := sr.err != nil; got != c.err {
+			t.Errorf("[%s] Expected error: %v, got: %v", c.name, c.err, sr.err)
+		}
+	}
+	if !errors.Is(sr.err, errUnexpectedEnd) {
+		t.Errorf("Expected: %v, got: %v", errUnexpectedEnd, sr.err)
+	}
+}
+
+func TestSliceReaderReadSlice(t *testing.T) {
+	cases := []struct {
+		name string
+		pos  uint32
+		size uint32
+		exp  []byte
+		err  bool
+	}{
+		{"in range", 1, 2, []byte{2, 3}, false},
+		{"empty", 4, 0, []byte{}, false},
+		{"truncated", 2, 4, []byte{3, 4, 0, 0}, true},
+		{"past the end", 10, 2, []byte{0, 0}, true},
+	}
+
+	for _, c := range cases {
+		sr := &sliceReader{b: []byte{1, 2, 3, 4}, pos: c.pos}
+		if got := sr.readSlice(c.size); !slices.Equal(got, c.exp) {
+			t.Errorf("[%s] Expected: %v, got: %v", c.name, c.exp, got)
+		}
+		if got := sr.err != nil; got != c.err {
+			t.Errorf("[%s] Expected error: %v, got: %v", c.name, c.err, sr.err)
+		}
+		if sr.err != nil && sr.pos < uint32(len(sr.b)) {
+			t.Errorf("[%s] Expected pos at least: %v, got: %v", c.name, len(sr.b), sr.pos)
+		}
+	}
+
+	// Size must not overflow the position:
+	sr := &sliceReader{b: []byte{1, 2}, pos: 1}
+	if got := sr.next(math.MaxUint32); got != nil || sr.err == nil || sr.pos != 2 {
+		t.Errorf("Expected: nil, error, pos 2, got: %v, %v, pos %v", got, sr.err, sr.pos)
+	}
+}
@@ -0,0 +1,101 @@
+// This file contains the strings table of map data.
+
+package repparser
+
+import "encoding/binary"
+
+// stringsTable is the index of the strings sub-section of map data ("STR " or "STRx"),
+// built once so strings are looked up without re-reading their offsets, and each string
+// is decoded at most once.
+type stringsTable struct {
+	// data of the strings sub-section, starting with the strings count
+	data []byte
+
+	// declared number of strings
+	declared uint32
+
+	// offsets of the strings present, offsets[i] is the offset of string index i+1
+	offsets []uint32
+
+	// strs holds the decoded strings, decoded tells if a string has been decoded
+	strs    []string
+	decoded []bool
+}
+
+// newStringsTable builds the strings table of the given strings sub-section data.
+// extended tells if data is of an "STRx" sub-section (having uint32 counts and offsets).
+func newStringsTable(data []byte, extended bool) *stringsTable {
+	st := &stringsTable{data: data}
+
+	offsetSize := uint32(2)
+	if extended {
+		offsetSize = 4
+	}
+	if uint32(len(data)) < offsetSize {
+		return st
+	}
+
+	bo := binary.LittleEndian
+	if extended {
+		st.declared = bo.Uint32(data)
+	} else {
+		st.declared = uint32(bo.Uint16(data))
+	}
+
+	st.offsets = make([]uint32, min(st.declared, uint32(len(data))/offsetSize-1))
+	for i := range st.offsets {
+		pos := uint32(i+1) * offsetSize
+		if extended {
+			st.offsets[i] = bo.Uint32(data[pos:])
+		} else {
+			st.offsets[i] = uint32(bo.Uint16(data[pos:]))
+		}
+	}
+	st.strs = make([]string, len(st.offsets))
+	st.decoded = make([]bool, len(st.offsets))
+
+	return st
+}
+
+// invalidOffsets returns the number of string offsets out of range.
+func (st *stringsTable) invalidOffsets() (count int) {
+	for _, offset := range st.offsets {
+		if int(offset) >= len(st.data) {
+			count++
+		}
+	}
+	return
+}
+
+// get returns the string of the given (1-based) index.
+// Index 0 is the empty string. validIdx tells if the index is valid,
+// validOffset tells if the offset of the string is valid.
+func (st *stringsTable) get(idx uint32) (s string, validIdx, validOffset bool) {
+	if idx == 0 {
+		return "", true, true
+	}
+	if idx > uint32(len(st.offsets)) {
+		return "", false, false
+	}
+
+	i := idx - 1
+	offset := st.offsets[i]
+	if int(offset) >= len(st.data) {
+		return "", true, false
+	}
+	if !st.decoded[i] {
+		st.strs[i], _ = cStringInterned(st.data[offset:])
+		st.decoded[i] = true
+	}
+	return st.strs[i], true, true
+}
+
+// overlaySection returns dst overwritten with the data of a subsequent sub-section having the same ID:
+// the beginning of dst is overwritten, and dst is extended if data is longer.
+func overlaySection(dst, data []byte) []byte {
+	if len(dst) < len(data) {
+		dst = make([]byte, len(data))
+	}
+	copy(dst, data)
+	return dst
+}
@@ -0,0 +1,74 @@
+package repparser
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// buildSTRx builds an "STRx" sub-section holding the given strings (string index i+1 is strs[i]).
+func buildSTRx(strs ...string) chkSection {
+	data := binary.LittleEndian.AppendUint32(nil, uint32(len(strs)))
+	offset := 4 + 4*len(strs)
+	for _, s := range strs {
+		data = binary.LittleEndian.AppendUint32(data, uint32(offset))
+		offset += len(s) + 1
+	}
+	for _, s := range strs {
+		data = append(data, s...)
+		data = append(data, 0)
+	}
+	return chkSection{"STRx", data}
+}
+
+func TestStringsTable(t *testing.T) {
+	str := buildSTR("a", "bc", "d")
+	binary.LittleEndian.PutUint16(str.data[6:], 1000) // Offset of string #3 out of range
+	st := newStringsTable(str.data, false)
+
+	cases := []struct {
+		idx                      uint32
+		exp                      string
+		expValidIdx, expValidOff bool
+	}{
+		{0, "", true, true},
+		{1, "a", true, true},
+		{2, "bc", true, true},
+		{3, "", true, false},
+		{4, "", false, false},
+		{1 << 20, "", false, false},
+	}
+	for _, c := range cases {
+		s, validIdx, validOff := st.get(c.idx)
+		if s != c.exp || validIdx != c.expValidIdx || validOff != c.expValidOff {
+			t.Errorf("[%d] Expected: %q %v %v, got: %q %v %v", c.idx, c.exp, c.expValidIdx, c.expValidOff, s, validIdx, validOff)
+		}
+	}
+	if n := st.invalidOffsets(); n != 1 {
+		t.Errorf("Expected: %v, got: %v", 1, n)
+	}
+
+	if st := newStringsTable([]byte{1}, true); len(st.offsets) != 0 || st.declared != 0 {
+		t.Errorf("Expected empty table, got: %+v", st)
+	}
+}
+
+func TestParseStringSections(t *testing.T) {
+	cases := []struct {
+		name     string
+		sections []chkSection
+		exp      []string
+	}{
+		{"STR", []chkSection{buildSTR("Map", "Desc")}, []string{"Map", "Desc"}},
+		{"STRx", []chkSection{buildSTRx("Map", "Desc")}, []string{"Map", "Desc"}},
+		{"STRx preferred", []chkSection{buildSTRx("Map x", "Desc x"), buildSTR("Map", "Desc")}, []string{"Map x", "Desc x"}},
+		{"STR overlay", []chkSection{buildSTR("Longer map", "Desc"), buildSTR("M", "D")}, []string{"M", "D"}},
+	}
+
+	for _, c := range cases {
+		chk := buildCHK(append(c.sections, chkSection{"SPRP", []byte{1, 0, 2, 0}})...)
+		md := parseCHK(t, chk, Config{MapStrings: true})
+		if md.Name != c.exp[0] || md.Description != c.exp[1] || len(md.Strings) != 2 {
+			t.Errorf("[%s] Expected: %q, got: %q, %q, %q", c.name, c.exp, md.Name, md.Description, md.Strings)
+		}
+	}
+}
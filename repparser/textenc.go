@@ -0,0 +1,146 @@
+// This file contains the detection of legacy text encodings.
+
+package repparser
+
+import (
+	"sync"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+)
+
+// textEncoding is a legacy (non UTF-8) text encoding strings of replays may be stored in.
+type textEncoding struct {
+	// name of the encoding, e.g. "EUC-KR"
+	name string
+
+	// dec is the decoder of the encoding (decoders of these encodings are stateless,
+	// so they may be used concurrently)
+	dec *encoding.Decoder
+
+	// common returns the set of common characters of the encoding's language
+	common func() map[rune]bool
+}
+
+// byteRange is a range of 2-byte character codes: lead bytes and trail bytes.
+type byteRange struct {
+	leadMin, leadMax, trailMin, trailMax byte
+}
+
+// newTextEncoding returns a new textEncoding whose common characters are the ones in the given ranges.
+//
+// The character sets of these encodings are supersets of older, smaller character sets
+// (e.g. Code Page 949 of KS X 1001), and almost any byte pair is valid in them.
+// Texts of the encoding's language mostly consist of the common characters of the original sets
+// (e.g. the 2350 Hangul syllables of KS X 1001), so these tell which encoding fits a text.
+func newTextEncoding(name string, enc encoding.Encoding, ranges ...byteRange) *textEncoding {
+	te := &textEncoding{name: name, dec: enc.NewDecoder()}
+	te.common = sync.OnceValue(func() map[rune]bool {
+		set := map[rune]bool{}
+		for _, br := range ranges {
+			for lead := int(br.leadMin); lead <= int(br.leadMax); lead++ {
+				for trail := int(br.trailMin); trail <= int(br.trailMax); trail++ {
+					decoded, err := te.dec.Bytes([]byte{byte(lead), byte(trail)})
+					if err != nil {
+						continue
+					}
+					if r, size := utf8.DecodeRune(decoded); r != utf8.RuneError && size == len(decoded) {
+						set[r] = true
+					}
+				}
+			}
+		}
+		return set
+	})
+	return te
+}
+
+// textEncodings are the legacy encodings tried by decodeText(), in order of preference
+// (used when multiple encodings decode a string equally well).
+// EUC-KR is the first: it is the most common legacy encoding of replays.
+var textEncodings = []*textEncoding{
+	newTextEncoding("EUC-KR", korean.EUCKR, byteRange{0xb0, 0xc8, 0xa1, 0xfe}),          // Hangul of KS X 1001
+	newTextEncoding("GBK", simplifiedchinese.GBK, byteRange{0xb0, 0xf7, 0xa1, 0xfe}),    // Hanzi of GB 2312
+	newTextEncoding("Big5", traditionalchinese.Big5, byteRange{0xa4, 0xc6, 0x40, 0xfe}), // Frequent hanzi of Big5
+	newTextEncoding("Shift_JIS", japanese.ShiftJIS,
+		byteRange{0x82, 0x83, 0x40, 0xfc}, // Kana
+		byteRange{0x88, 0x98, 0x40, 0xfc}, // Level 1 kanji of JIS X 0208
+	),
+}
+
+// textEncodingByName returns the textEncoding of the given name, nil if there is no such encoding.
+func textEncodingByName(name string) *textEncoding {
+	for _, te := range textEncodings {
+		if te.name == name {
+			return te
+		}
+	}
+	return nil
+}
+
+// score decodes data and returns the ratio of the common characters among the non-ASCII characters
+// of the decoded text. ok is false if data is invalid in the encoding.
+func (te *textEncoding) score(data []byte) (s string, score float64, ok bool) {
+	decoded, err := te.dec.Bytes(data)
+	if err != nil {
+		return "", 0, false
+	}
+	common := te.common()
+	var nonASCII, commons int
+	for _, r := range string(decoded) {
+		switch {
+		case r == utf8.RuneError:
+			return "", 0, false
+		case r < utf8.RuneSelf:
+		default:
+			nonASCII++
+			if common[r] {
+				commons++
+			}
+		}
+	}
+	if nonASCII > 0 {
+		score = float64(commons) / float64(nonASCII)
+	}
+	return string(decoded), score, true
+}
+
+// decodeText decodes data which is invalid UTF-8 using the legacy encoding that fits it best:
+// the one whose decoded text has the highest ratio of common characters of its language
+// (e.g. Hangul for EUC-KR). The hint encoding (optional) is preferred if it fits equally well
+// (e.g. the encoding detected for the name of the player who sent a chat message).
+//
+// The returned confidence is the score of the chosen encoding (in the range 0..1),
+// halved if another encoding fits equally well.
+// enc is nil if data is invalid in all encodings.
+func decodeText(data []byte, hint *textEncoding) (s string, enc *textEncoding, confidence float64) {
+	ambiguous := false
+	try := func(te *textEncoding) {
+		ts, score, ok := te.score(data)
+		switch {
+		case !ok:
+		case enc == nil || score > confidence:
+			s, enc, confidence, ambiguous = ts, te, score, false
+		case score == confidence:
+			ambiguous = true
+		}
+	}
+
+	if hint != nil {
+		try(hint)
+	}
+	for _, te := range textEncodings {
+		if te != hint {
+			try(te)
+		}
+	}
+
+	if ambiguous {
+		confidence /= 2
+	}
+	return
+}
@@ -0,0 +1,79 @@
+package repparser
+
+import (
+	"testing"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcmd"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+func TestDecodeText(t *testing.T) {
+	encode := func(enc encoding.Encoding, s string) []byte {
+		data, err := enc.NewEncoder().Bytes([]byte(s))
+		if err != nil {
+			t.Fatalf("Failed to encode %q: %v", s, err)
+		}
+		return data
+	}
+
+	cases := []struct {
+		name       string
+		data       []byte
+		hint       string
+		exp        string
+		enc        string
+		confidence float64
+	}{
+		{"korean", encode(korean.EUCKR, "이영호"), "", "이영호", "EUC-KR", 0.5}, // Also valid common GB 2312 hanzi
+		{"chinese", encode(simplifiedchinese.GBK, "张伟"), "", "张伟", "GBK", 1},
+		{"chinese hint", encode(simplifiedchinese.GBK, "李"), "GBK", "李", "GBK", 0.5},
+		{"japanese kana", encode(japanese.ShiftJIS, "さくら"), "", "さくら", "Shift_JIS", 1},
+		{"japanese kanji", encode(japanese.ShiftJIS, "山田"), "", "山田", "Shift_JIS", 1},
+		{"mixed ascii", encode(korean.EUCKR, "[GG]홍진호"), "", "[GG]홍진호", "EUC-KR", 0.5},
+		{"invalid", []byte{0xff, 0xff}, "", "", "", 0},
+	}
+
+	for _, c := range cases {
+		s, enc, confidence := decodeText(c.data, textEncodingByName(c.hint))
+		name := ""
+		if enc != nil {
+			name = enc.name
+		}
+		if s != c.exp || name != c.enc || confidence != c.confidence {
+			t.Errorf("[%s] Expected: %q %s %v, got: %q %s %v", c.name, c.exp, c.enc, c.confidence, s, name, confidence)
+		}
+	}
+}
+
+func TestChatEncodingHint(t *testing.T) {
+	// Valid common characters both in EUC-KR and GBK, decoded as EUC-KR by default:
+	msg, _ := simplifiedchinese.GBK.NewEncoder().String("李")
+	cmds := cmdBlock(10, chatCmdData(1, msg))
+
+	cases := []struct {
+		name     string
+		encoding string // Encoding of the sender's name
+		exp      string
+	}{
+		{"no hint", "", "쟀"},
+		{"gbk sender", "GBK", "李"},
+	}
+
+	for _, c := range cases {
+		for _, arena := range []bool{false, true} {
+			cr := NewCmdReader(cmds, Config{Arena: arena})
+			cr.setPlayerEncodings(&rep.Header{Slots: []*rep.Player{{SlotID: 0}, {SlotID: 1, NameEncoding: c.encoding}}})
+			cmd, err := cr.Next()
+			if err != nil {
+				t.Fatalf("[%s] Failed to read command: %v", c.name, err)
+			}
+			if got := cmd.(*repcmd.ChatCmd).Message; got != c.exp {
+				t.Errorf("[%s][arena: %v] Expected: %q, got: %q", c.name, arena, c.exp, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,116 @@
+// This file contains replay validation.
+
+package repparser
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/icza/screp/rep/repcore"
+)
+
+// Severity of validation issues.
+const (
+	// SeverityError means the replay is corrupt, truncated or inconsistent
+	SeverityError = "error"
+
+	// SeverityWarning means the replay is parsable but has suspicious content
+	SeverityWarning = "warning"
+)
+
+// Issue is a problem found by Validate().
+type Issue struct {
+	// Severity of the issue, one of SeverityError and SeverityWarning
+	Severity string
+
+	// Section the issue is found in, e.g. "Header" or "Commands"
+	Section string
+
+	// Message describes the issue
+	Message string
+}
+
+// String returns a human-friendly representation of the issue.
+func (i *Issue) String() string {
+	return fmt.Sprintf("%s: %s: %s", i.Severity, i.Section, i.Message)
+}
+
+// Validate validates a replay: it decodes and parses all its sections,
+// and checks the consistency of the parsed data.
+// The returned issues are empty if no problems are found.
+func Validate(repData []byte) []*Issue {
+	var issues []*Issue
+	add := func(severity, section, format string, a ...any) {
+		issues = append(issues, &Issue{Severity: severity, Section: section, Message: fmt.Sprintf(format, a...)})
+	}
+
+	sections, err := DecodeSections(repData)
+	if err != nil {
+		add(SeverityError, "Replay", "failed to decode sections: %v", err)
+		return issues
+	}
+	if len(sections) < 3 {
+		add(SeverityError, "Replay", "truncated replay: only %d sections after the replay ID", len(sections))
+		return issues
+	}
+
+	// Walk the command blocks to detect truncated data:
+	cmds := sections[1].Data
+	for pos, size := uint32(0), uint32(len(cmds)); pos < size; pos += 5 + uint32(cmds[pos+4]) {
+		if pos+5 > size || pos+5+uint32(cmds[pos+4]) > size {
+			add(SeverityError, "Commands", "truncated command block at offset %d", pos)
+			break
+		}
+	}
+
+	r, err := ParseConfig(repData, Config{Commands: true, MapData: true, Debug: true})
+	if err != nil {
+		add(SeverityError, "Replay", "failed to parse: %v", err)
+		return issues
+	}
+
+	h := r.Header
+	if len(h.Players) == 0 {
+		add(SeverityError, "Header", "no players")
+	}
+	if h.Frames == 0 {
+		add(SeverityWarning, "Header", "game length is 0 frames")
+	}
+	headerData := h.Debug.Data
+	if binary.LittleEndian.Uint16(headerData[0x34:]) == 0 || binary.LittleEndian.Uint16(headerData[0x36:]) == 0 {
+		add(SeverityError, "Header", "invalid map size: %dx%d",
+			binary.LittleEndian.Uint16(headerData[0x34:]), binary.LittleEndian.Uint16(headerData[0x36:]))
+	}
+
+	if n := len(r.Commands.ParseErrCmds); n > 0 {
+		add(SeverityWarning, "Commands", "%d commands failed to parse, first at frame %d", n, r.Commands.ParseErrCmds[0].Frame)
+	}
+	if bs := r.Commands.BlockStats; bs != nil && bs.Overruns > 0 {
+		add(SeverityWarning, "Commands", "%d command blocks overrun by their commands", bs.Overruns)
+	}
+	var prevFrame repcore.Frame
+	unknownPIDs := map[byte]bool{}
+	for _, cmd := range r.Commands.Cmds {
+		base := cmd.BaseCmd()
+		if base.Frame < prevFrame {
+			add(SeverityError, "Commands", "command frames are not in order at frame %d", base.Frame)
+			break
+		}
+		prevFrame = base.Frame
+		if h.PIDPlayers[base.PlayerID] == nil && !unknownPIDs[base.PlayerID] {
+			unknownPIDs[base.PlayerID] = true
+			add(SeverityWarning, "Commands", "commands of unknown player ID %d", base.PlayerID)
+		}
+	}
+	if prevFrame > h.Frames {
+		add(SeverityError, "Commands", "command at frame %d beyond game length (%d frames)", prevFrame, h.Frames)
+	}
+
+	if len(r.MapData.Debug.Data) == 0 {
+		add(SeverityError, "MapData", "missing map data")
+	} else if r.MapData.TileSetMissing {
+		add(SeverityWarning, "MapData", "missing tile set")
+	}
+
+	return issues
+}
@@ -0,0 +1,39 @@
+package repparser
+
+import (
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"github.com/icza/screp/rep/repcmd"
+)
+
+func TestValidate(t *testing.T) {
+	dim := binary.LittleEndian.AppendUint16(binary.LittleEndian.AppendUint16(nil, 64), 64)
+	chk := buildCHK(chkSection{"ERA ", []byte{0, 0}}, chkSection{"DIM ", dim})
+	hotkey := func(playerID byte) []byte { return []byte{playerID, repcmd.TypeIDHotkey, 0, 1} }
+
+	cases := []struct {
+		name    string
+		repData []byte
+		issues  []string // Severity and section of the expected issues
+	}{
+		{"valid", buildReplay(t, cmdBlock(10, hotkey(0), hotkey(1)), chk), nil},
+		{"not a replay", []byte("not a replay, just some text of at least 30 bytes"), []string{"error Replay"}},
+		{"truncated commands", buildReplay(t, cmdBlock(10, hotkey(0))[:7], chk), []string{"error Commands"}},
+		{"overrun", buildReplay(t, append(cmdBlock(10, hotkey(0)[:3]), cmdBlock(20, hotkey(0))...), chk), []string{"warning Commands"}},
+		{"unknown player", buildReplay(t, cmdBlock(10, hotkey(5), hotkey(5)), chk), []string{"warning Commands"}},
+		{"beyond game length", buildReplay(t, cmdBlock(2000, hotkey(0)), chk), []string{"error Commands"}},
+		{"missing map", buildReplay(t, cmdBlock(10, hotkey(0)), nil), []string{"error MapData"}},
+	}
+
+	for _, c := range cases {
+		var issues []string
+		for _, issue := range Validate(c.repData) {
+			issues = append(issues, issue.Severity+" "+issue.Section)
+		}
+		if fmt.Sprint(issues) != fmt.Sprint(c.issues) {
+			t.Errorf("[%s] Expected: %v, got: %v", c.name, c.issues, issues)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+/*
+
+Package repproto implements converting replays to and from the protocol buffer
+messages defined in proto/screp.proto (package screp.v1).
+
+ToProto encodes a replay as a serialized Replay message, FromProto decodes one.
+StreamEvents produces the ParseEvent messages of a replay, the stream of the
+ReplayService.ParseStream server-streaming call; Stream produces them part by part
+as a replay is parsed. ParseRequest and ParseResponse
+are the messages of the ReplayService.Parse call, served by the repgrpc package.
+The wire format is implemented by this package, so it does not depend on
+the protobuf runtime; messages produced here can be decoded with the stubs
+generated from proto/screp.proto in any language, and vice versa.
+
+The messages mirror the JSON output of screp: enumerations are encoded with
+their IDs and names, type specific fields of commands are encoded in JSON.
+
+*/
+package repproto
@@ -0,0 +1,628 @@
+package repproto
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcmd"
+	"github.com/icza/screp/rep/repcore"
+)
+
+// baseCmdFields are the JSON fields of repcmd.Base, which are encoded in
+// dedicated fields of Command messages (and not in params_json).
+var baseCmdFields = []string{"Frame", "PlayerID", "Type", "IneffKind"}
+
+// ToProto returns the serialized Replay message of the replay.
+//
+// Parts of the replay not modelled by the messages (e.g. build orders, map analysis,
+// triggers, debug info) are not encoded.
+func ToProto(r *rep.Replay) ([]byte, error) {
+	e := &encoder{}
+	var err error
+	if r.Header != nil {
+		e.message(1, func(e *encoder) { encodeHeader(e, r.Header) })
+	}
+	if r.Commands != nil {
+		e.message(2, func(e *encoder) { err = encodeCommands(e, r.Commands) })
+		if err != nil {
+			return nil, err
+		}
+	}
+	if r.MapData != nil {
+		e.message(3, func(e *encoder) { encodeMapData(e, r.MapData) })
+	}
+	if r.Computed != nil {
+		e.message(4, func(e *encoder) { encodeComputed(e, r.Computed) })
+	}
+	return e.buf, nil
+}
+
+// enum writes an Enum message field.
+func (e *encoder) enum(num int, id uint64, name string) {
+	e.message(num, func(e *encoder) {
+		e.uint(1, id)
+		e.string(2, name)
+	})
+}
+
+// point writes a Point message field.
+func (e *encoder) point(num int, p repcore.Point) {
+	e.message(num, func(e *encoder) {
+		e.int(1, int64(p.X))
+		e.int(2, int64(p.Y))
+	})
+}
+
+func encodeHeader(e *encoder, h *rep.Header) {
+	if h.Engine != nil {
+		e.enum(1, uint64(h.Engine.ID), h.Engine.Name)
+	}
+	e.string(2, h.Version)
+	e.int(3, int64(h.Frames))
+	if !h.StartTime.IsZero() {
+		e.int(4, h.StartTime.Unix())
+	}
+	e.string(5, h.Title)
+	e.uint(6, uint64(h.MapWidth))
+	e.uint(7, uint64(h.MapHeight))
+	e.uint(8, uint64(h.AvailSlotsCount))
+	if h.Speed != nil {
+		e.enum(9, uint64(h.Speed.ID), h.Speed.Name)
+	}
+	if h.Type != nil {
+		e.enum(10, uint64(h.Type.ID), h.Type.Name)
+	}
+	e.uint(11, uint64(h.SubType))
+	e.string(12, h.Host)
+	e.string(13, h.Map)
+	for _, p := range h.Players {
+		e.message(14, func(e *encoder) {
+			e.uint(1, uint64(p.SlotID))
+			e.uint(2, uint64(p.ID))
+			if p.Type != nil {
+				e.enum(3, uint64(p.Type.ID), p.Type.Name)
+			}
+			if p.Race != nil {
+				e.enum(4, uint64(p.Race.ID), p.Race.Name)
+			}
+			e.uint(5, uint64(p.Team))
+			e.string(6, p.Name)
+			if p.Color != nil {
+				e.enum(7, uint64(p.Color.ID), p.Color.Name)
+			}
+			e.bool(8, p.Observer)
+		})
+	}
+}
+
+func encodeCommands(e *encoder, c *rep.Commands) error {
+	for _, cmd := range c.Cmds {
+		var err error
+		e.message(1, func(e *encoder) { err = encodeCmd(e, cmd) })
+		if err != nil {
+			return err
+		}
+	}
+	for _, pec := range c.ParseErrCmds {
+		e.message(2, func(e *encoder) { encodeParseErrCmd(e, pec) })
+	}
+	return nil
+}
+
+func encodeCmd(e *encoder, cmd repcmd.Cmd) error {
+	params, err := cmdParams(cmd)
+	if err != nil {
+		return err
+	}
+	base := cmd.BaseCmd()
+	e.int(1, int64(base.Frame))
+	e.uint(2, uint64(base.PlayerID))
+	e.enum(3, uint64(base.Type.ID), base.Type.Name)
+	e.uint(4, uint64(base.IneffKind))
+	e.string(5, params)
+	return nil
+}
+
+func encodeParseErrCmd(e *encoder, pec *repcmd.ParseErrCmd) {
+	e.int(1, int64(pec.Frame))
+	e.uint(2, uint64(pec.PlayerID))
+	e.enum(3, uint64(pec.Type.ID), pec.Type.Name)
+	e.string(4, string(pec.SkippedBytes))
+}
+
+// cmdParams returns the type specific fields of the command in JSON format,
+// empty string if the command has no such fields. The order of the fields is kept.
+func cmdParams(cmd repcmd.Cmd) (string, error) {
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		return "", err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if _, err := dec.Token(); err != nil { // Opening brace
+		return "", err
+	}
+	buf := &bytes.Buffer{}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return "", err
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return "", err
+		}
+		if slices.Contains(baseCmdFields, key.(string)) {
+			continue
+		}
+		if buf.Len() == 0 {
+			buf.WriteByte('{')
+		} else {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(key)
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	if buf.Len() > 0 {
+		buf.WriteByte('}')
+	}
+	return buf.String(), nil
+}
+
+func encodeMapData(e *encoder, md *rep.MapData) {
+	e.uint(1, uint64(md.Version))
+	if md.TileSet != nil {
+		e.enum(2, uint64(md.TileSet.ID), md.TileSet.Name)
+	}
+	e.string(3, md.CHKHash)
+	e.string(4, md.Fingerprint)
+	e.string(5, md.Name)
+	e.string(6, md.Description)
+	tiles := make([]uint64, len(md.Tiles))
+	for i, t := range md.Tiles {
+		tiles[i] = uint64(t)
+	}
+	e.packed(7, tiles)
+	encodeResources := func(num int, rs []rep.Resource) {
+		for _, r := range rs {
+			e.message(num, func(e *encoder) {
+				e.point(1, r.Point)
+				e.uint(2, uint64(r.Amount))
+			})
+		}
+	}
+	encodeResources(8, md.MineralFields)
+	encodeResources(9, md.Geysers)
+	for _, sl := range md.StartLocations {
+		e.message(10, func(e *encoder) {
+			e.point(1, sl.Point)
+			e.uint(2, uint64(sl.SlotID))
+		})
+	}
+}
+
+func encodeComputed(e *encoder, c *rep.Computed) {
+	e.uint(1, uint64(c.WinnerTeam))
+	if c.RepSaverPlayerID != nil {
+		e.optionalUint(2, uint64(*c.RepSaverPlayerID))
+	}
+	for _, pd := range c.PlayerDescs {
+		e.message(3, func(e *encoder) {
+			e.uint(1, uint64(pd.PlayerID))
+			e.int(2, int64(pd.LastCmdFrame))
+			e.uint(3, uint64(pd.CmdCount))
+			e.int(4, int64(pd.APM))
+			e.uint(5, uint64(pd.EffectiveCmdCount))
+			e.int(6, int64(pd.EAPM))
+			if pd.StartLocation != nil {
+				e.point(7, *pd.StartLocation)
+			}
+			e.int(8, int64(pd.StartDirection))
+		})
+	}
+	for _, t := range c.Teams {
+		e.message(4, func(e *encoder) {
+			e.uint(1, uint64(t.ID))
+			slotIDs := make([]uint64, len(t.SlotIDs))
+			for i, id := range t.SlotIDs {
+				slotIDs[i] = uint64(id)
+			}
+			e.packed(2, slotIDs)
+			for _, race := range t.Races {
+				e.enum(3, uint64(race.ID), race.Name)
+			}
+			e.int(4, int64(t.APM))
+			if t.Result != nil {
+				e.enum(5, uint64(t.Result.ID), t.Result.Name)
+			}
+			e.bool(6, t.Observers)
+			e.bool(7, t.Heuristic)
+		})
+	}
+}
+
+// Cmd is a command decoded from a Command message.
+// Its type specific fields are kept in JSON format.
+type Cmd struct {
+	*repcmd.Base
+
+	// ParamsJSON is the type specific fields of the command in JSON format (a JSON object),
+	// empty if the command has no such fields.
+	ParamsJSON string
+}
+
+// Params implements repcmd.Cmd.Params(), returning the type specific fields in JSON format.
+func (c *Cmd) Params(verbose bool) string {
+	return c.ParamsJSON
+}
+
+// MarshalJSON marshals the command the same way as the original command was marshaled:
+// the fields of the base command and the type specific fields in one JSON object.
+func (c *Cmd) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(c.Base)
+	if err != nil || c.ParamsJSON == "" || c.ParamsJSON == "{}" {
+		return data, err
+	}
+	buf := bytes.NewBuffer(data[:len(data)-1]) // Cut the closing brace
+	buf.WriteByte(',')
+	buf.WriteString(c.ParamsJSON[1:]) // Cut the opening brace
+	return buf.Bytes(), nil
+}
+
+// FromProto decodes a replay from a serialized Replay message.
+//
+// Enumerations are restored by their IDs. Commands are decoded as *Cmd values,
+// and the PrevCmd field of parse error commands is nil.
+func FromProto(data []byte) (*rep.Replay, error) {
+	r := &rep.Replay{}
+	err := decode(data, func(f *field) (err error) {
+		switch f.num {
+		case 1:
+			r.Header, err = decodeHeader(f.b)
+		case 2:
+			r.Commands, err = decodeCommands(f.b)
+		case 3:
+			r.MapData, err = decodeMapData(f.b)
+		case 4:
+			r.Computed, err = decodeComputed(f.b)
+		}
+		return
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid Replay message: %w", err)
+	}
+
+	// Restore the links of teams to their players
+	if r.Header != nil && r.Computed != nil {
+		for _, t := range r.Computed.Teams {
+			for _, slotID := range t.SlotIDs {
+				for _, p := range r.Header.Players {
+					if p.SlotID == slotID {
+						t.Players = append(t.Players, p)
+					}
+				}
+			}
+		}
+	}
+
+	return r, nil
+}
+
+// decodeEnum decodes the ID of an Enum message.
+func decodeEnum(data []byte) (id uint64, err error) {
+	err = decode(data, func(f *field) error {
+		if f.num == 1 {
+			id = f.v
+		}
+		return nil
+	})
+	return
+}
+
+// decodePoint decodes a Point message.
+func decodePoint(data []byte) (p repcore.Point, err error) {
+	err = decode(data, func(f *field) error {
+		switch f.num {
+		case 1:
+			p.X = uint16(f.v)
+		case 2:
+			p.Y = uint16(f.v)
+		}
+		return nil
+	})
+	return
+}
+
+func decodeHeader(data []byte) (*rep.Header, error) {
+	h := &rep.Header{PIDPlayers: map[byte]*rep.Player{}}
+	err := decode(data, func(f *field) (err error) {
+		var id uint64
+		switch f.num {
+		case 1:
+			id, err = decodeEnum(f.b)
+			h.Engine = repcore.EngineByID(byte(id))
+		case 2:
+			h.Version = string(f.b)
+		case 3:
+			h.Frames = repcore.Frame(f.v)
+		case 4:
+			h.StartTimeUnix = int64(f.v)
+			h.StartTime = time.Unix(h.StartTimeUnix, 0)
+		case 5:
+			h.Title = string(f.b)
+		case 6:
+			h.MapWidth = uint16(f.v)
+		case 7:
+			h.MapHeight = uint16(f.v)
+		case 8:
+			h.AvailSlotsCount = byte(f.v)
+		case 9:
+			id, err = decodeEnum(f.b)
+			h.Speed = repcore.SpeedByID(byte(id))
+		case 10:
+			id, err = decodeEnum(f.b)
+			h.Type = repcore.GameTypeByID(uint16(id))
+		case 11:
+			h.SubType = uint16(f.v)
+		case 12:
+			h.Host = string(f.b)
+		case 13:
+			h.Map = string(f.b)
+		case 14:
+			var p *rep.Player
+			if p, err = decodePlayer(f.b); err == nil {
+				h.Players = append(h.Players, p)
+				h.OrigPlayers = append(h.OrigPlayers, p)
+				h.PIDPlayers[p.ID] = p
+			}
+		}
+		return
+	})
+	return h, err
+}
+
+func decodePlayer(data []byte) (*rep.Player, error) {
+	p := &rep.Player{}
+	err := decode(data, func(f *field) (err error) {
+		var id uint64
+		switch f.num {
+		case 1:
+			p.SlotID = uint16(f.v)
+		case 2:
+			p.ID = byte(f.v)
+		case 3:
+			id, err = decodeEnum(f.b)
+			p.Type = repcore.PlayerTypeByID(byte(id))
+		case 4:
+			id, err = decodeEnum(f.b)
+			p.Race = repcore.RaceByID(byte(id))
+		case 5:
+			p.Team = byte(f.v)
+		case 6:
+			p.Name = string(f.b)
+		case 7:
+			id, err = decodeEnum(f.b)
+			p.Color = repcore.ColorByID(uint32(id))
+		case 8:
+			p.Observer = f.v != 0
+		}
+		return
+	})
+	return p, err
+}
+
+// decodeBaseCmd decodes the fields shared by Command and ParseErrCmd messages.
+// ok is false if the field is not one of them.
+func decodeBaseCmd(b *repcmd.Base, f *field) (ok bool, err error) {
+	switch f.num {
+	case 1:
+		b.Frame = repcore.Frame(f.v)
+	case 2:
+		b.PlayerID = byte(f.v)
+	case 3:
+		var id uint64
+		id, err = decodeEnum(f.b)
+		b.Type = repcmd.TypeByID(byte(id))
+	default:
+		return false, nil
+	}
+	return true, err
+}
+
+func decodeCommands(data []byte) (*rep.Commands, error) {
+	c := &rep.Commands{}
+	err := decode(data, func(f *field) error {
+		switch f.num {
+		case 1:
+			cmd := &Cmd{Base: &repcmd.Base{Type: repcmd.TypeByID(0)}}
+			err := decode(f.b, func(f *field) error {
+				if ok, err := decodeBaseCmd(cmd.Base, f); ok {
+					return err
+				}
+				switch f.num {
+				case 4:
+					cmd.IneffKind = repcore.IneffKind(f.v)
+				case 5:
+					cmd.ParamsJSON = string(f.b)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			c.Cmds = append(c.Cmds, cmd)
+		case 2:
+			pec := &repcmd.ParseErrCmd{Base: &repcmd.Base{Type: repcmd.TypeByID(0)}}
+			err := decode(f.b, func(f *field) error {
+				if ok, err := decodeBaseCmd(pec.Base, f); ok {
+					return err
+				}
+				if f.num == 4 {
+					pec.SkippedBytes = bytes.Clone(f.b)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			c.ParseErrCmds = append(c.ParseErrCmds, pec)
+		}
+		return nil
+	})
+	return c, err
+}
+
+func decodeMapData(data []byte) (*rep.MapData, error) {
+	md := &rep.MapData{}
+	decodeResource := func(data []byte) (r rep.Resource, err error) {
+		err = decode(data, func(f *field) (err error) {
+			switch f.num {
+			case 1:
+				r.Point, err = decodePoint(f.b)
+			case 2:
+				r.Amount = uint32(f.v)
+			}
+			return
+		})
+		return
+	}
+	err := decode(data, func(f *field) (err error) {
+		switch f.num {
+		case 1:
+			md.Version = uint16(f.v)
+		case 2:
+			var id uint64
+			id, err = decodeEnum(f.b)
+			md.TileSet = repcore.TileSetByID(uint16(id))
+		case 3:
+			md.CHKHash = string(f.b)
+		case 4:
+			md.Fingerprint = string(f.b)
+		case 5:
+			md.Name = string(f.b)
+		case 6:
+			md.Description = string(f.b)
+		case 7:
+			var tiles []uint64
+			tiles, err = f.uints()
+			for _, t := range tiles {
+				md.Tiles = append(md.Tiles, uint16(t))
+			}
+		case 8, 9:
+			var r rep.Resource
+			if r, err = decodeResource(f.b); err == nil {
+				if f.num == 8 {
+					md.MineralFields = append(md.MineralFields, r)
+				} else {
+					md.Geysers = append(md.Geysers, r)
+				}
+			}
+		case 10:
+			var sl rep.StartLocation
+			err = decode(f.b, func(f *field) (err error) {
+				switch f.num {
+				case 1:
+					sl.Point, err = decodePoint(f.b)
+				case 2:
+					sl.SlotID = byte(f.v)
+				}
+				return
+			})
+			md.StartLocations = append(md.StartLocations, sl)
+		}
+		return
+	})
+	return md, err
+}
+
+func decodeComputed(data []byte) (*rep.Computed, error) {
+	c := &rep.Computed{PIDPlayerDescs: map[byte]*rep.PlayerDesc{}}
+	err := decode(data, func(f *field) (err error) {
+		switch f.num {
+		case 1:
+			c.WinnerTeam = byte(f.v)
+		case 2:
+			id := byte(f.v)
+			c.RepSaverPlayerID = &id
+		case 3:
+			var pd *rep.PlayerDesc
+			if pd, err = decodePlayerDesc(f.b); err == nil {
+				c.PlayerDescs = append(c.PlayerDescs, pd)
+				c.PIDPlayerDescs[pd.PlayerID] = pd
+			}
+		case 4:
+			var t *rep.Team
+			if t, err = decodeTeam(f.b); err == nil {
+				c.Teams = append(c.Teams, t)
+			}
+		}
+		return
+	})
+	return c, err
+}
+
+func decodePlayerDesc(data []byte) (*rep.PlayerDesc, error) {
+	pd := &rep.PlayerDesc{}
+	err := decode(data, func(f *field) (err error) {
+		switch f.num {
+		case 1:
+			pd.PlayerID = byte(f.v)
+		case 2:
+			pd.LastCmdFrame = repcore.Frame(f.v)
+		case 3:
+			pd.CmdCount = uint32(f.v)
+		case 4:
+			pd.APM = int32(f.v)
+		case 5:
+			pd.EffectiveCmdCount = uint32(f.v)
+		case 6:
+			pd.EAPM = int32(f.v)
+		case 7:
+			var p repcore.Point
+			if p, err = decodePoint(f.b); err == nil {
+				pd.StartLocation = &p
+			}
+		case 8:
+			pd.StartDirection = int32(f.v)
+		}
+		return
+	})
+	return pd, err
+}
+
+func decodeTeam(data []byte) (*rep.Team, error) {
+	t := &rep.Team{}
+	err := decode(data, func(f *field) (err error) {
+		var id uint64
+		switch f.num {
+		case 1:
+			t.ID = byte(f.v)
+		case 2:
+			var ids []uint64
+			ids, err = f.uints()
+			for _, id := range ids {
+				t.SlotIDs = append(t.SlotIDs, uint16(id))
+			}
+		case 3:
+			id, err = decodeEnum(f.b)
+			t.Races = append(t.Races, repcore.RaceByID(byte(id)))
+		case 4:
+			t.APM = int32(f.v)
+		case 5:
+			id, err = decodeEnum(f.b)
+			t.Result = repcore.ResultByID(byte(id))
+		case 6:
+			t.Observers = f.v != 0
+		case 7:
+			t.Heuristic = f.v != 0
+		}
+		return
+	})
+	return t, err
+}
@@ -0,0 +1,102 @@
+package repproto
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcmd"
+	"github.com/icza/screp/rep/repcore"
+)
+
+func TestRoundTrip(t *testing.T) {
+	repSaver := byte(1)
+	players := []*rep.Player{
+		{SlotID: 0, ID: 0, Type: repcore.PlayerTypeHuman, Race: repcore.RaceTerran, Team: 1, Name: "Flash", Color: repcore.ColorByID(0)},
+		{SlotID: 1, ID: 1, Type: repcore.PlayerTypeHuman, Race: repcore.RaceZerg, Team: 2, Name: "Jaedong", Color: repcore.ColorByID(1)},
+		{SlotID: 2, ID: 2, Type: repcore.PlayerTypeHuman, Race: repcore.RaceProtoss, Team: 3, Name: "Obs", Observer: true},
+	}
+	r := &rep.Replay{
+		Header: &rep.Header{
+			Engine:          repcore.EngineBroodWar,
+			Version:         "1.16.1",
+			Frames:          12345,
+			StartTime:       time.Unix(1600000000, 0),
+			StartTimeUnix:   1600000000,
+			Title:           "Title",
+			MapWidth:        128,
+			MapHeight:       96,
+			AvailSlotsCount: 4,
+			Speed:           repcore.SpeedFastest,
+			Type:            repcore.GameTypeMelee,
+			Host:            "Flash",
+			Map:             "Fighting Spirit",
+			Players:         players,
+		},
+		Commands: &rep.Commands{
+			Cmds: []repcmd.Cmd{
+				&repcmd.ChatCmd{Base: &repcmd.Base{Frame: 10, PlayerID: 1, Type: repcmd.TypeChat}, SenderSlotID: 1, Message: "gl hf"},
+				&repcmd.GeneralCmd{Base: &repcmd.Base{Frame: 20, PlayerID: 0, Type: repcmd.TypeByID(0x99), IneffKind: repcore.IneffKindFastCancel}, Data: []byte{1, 2}},
+			},
+			ParseErrCmds: []*repcmd.ParseErrCmd{{Base: &repcmd.Base{Frame: 30, PlayerID: 1, Type: repcmd.TypeChat}, SkippedBytes: repcmd.Bytes{1, 2}}},
+		},
+		MapData: &rep.MapData{
+			Version:        0xcd,
+			TileSet:        repcore.TileSetJungle,
+			Name:           "Fighting Spirit",
+			Tiles:          []uint16{0, 1, 300, 0},
+			MineralFields:  []rep.Resource{{Point: repcore.Point{X: 100, Y: 200}, Amount: 1500}},
+			Geysers:        []rep.Resource{{Point: repcore.Point{X: 300, Y: 400}, Amount: 5000}},
+			StartLocations: []rep.StartLocation{{Point: repcore.Point{X: 500, Y: 600}, SlotID: 1}},
+		},
+		Computed: &rep.Computed{
+			WinnerTeam:       1,
+			RepSaverPlayerID: &repSaver,
+			PlayerDescs: []*rep.PlayerDesc{
+				{PlayerID: 0, LastCmdFrame: 12000, CmdCount: 3000, APM: 300, EffectiveCmdCount: 2000, EAPM: 200, StartLocation: &repcore.Point{X: 500, Y: 600}, StartDirection: 5},
+				{PlayerID: 1, LastCmdFrame: -1},
+			},
+			Teams: []*rep.Team{
+				{ID: 1, SlotIDs: []uint16{0}, Races: []*repcore.Race{repcore.RaceTerran}, APM: 300, Result: repcore.ResultWin},
+				{ID: 3, SlotIDs: []uint16{2}, Races: []*repcore.Race{repcore.RaceProtoss}, Observers: true, Heuristic: true},
+			},
+		},
+	}
+
+	data, err := ToProto(r)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	r2, err := FromProto(data)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	exp, _ := json.Marshal(r)
+	got, _ := json.Marshal(r2)
+	if string(exp) != string(got) {
+		t.Errorf("Expected: %s, got: %s", exp, got)
+	}
+	if len(r2.Computed.Teams[1].Players) != 1 || r2.Computed.Teams[1].Players[0] != r2.Header.Players[2] {
+		t.Errorf("Team players are not restored")
+	}
+}
+
+func TestFromProtoInvalid(t *testing.T) {
+	cases := []struct {
+		name string
+		data []byte
+	}{
+		{"truncated tag", []byte{0x80}},
+		{"truncated length", []byte{0x0a, 0x05, 0x00}},
+		{"truncated nested", []byte{0x0a, 0x02, 0x1a, 0x05}},
+		{"invalid wire type", []byte{0x0b}},
+	}
+
+	for _, c := range cases {
+		if _, err := FromProto(c.data); err == nil {
+			t.Errorf("[%s] Expected error, got: %v", c.name, err)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+// This file contains the request and response messages of the ReplayService calls.
+
+package repproto
+
+import (
+	"fmt"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcore"
+)
+
+// ParseRequest is the request of ReplayService.Parse and ReplayService.ParseStream.
+type ParseRequest struct {
+	// Replay is the replay file content
+	Replay []byte
+
+	// Commands tells if commands are to be parsed and returned
+	Commands bool
+
+	// MapData tells if map data is to be parsed and returned
+	MapData bool
+
+	// Computed tells if computed / derived data is to be returned
+	Computed bool
+
+	// ProgressFrames is the frames between Progress events of ParseStream, 0 means no progress events
+	ProgressFrames repcore.Frame
+}
+
+// Marshal returns the serialized ParseRequest message.
+func (req *ParseRequest) Marshal() []byte {
+	e := &encoder{}
+	e.string(1, string(req.Replay))
+	e.bool(2, req.Commands)
+	e.bool(3, req.MapData)
+	e.bool(4, req.Computed)
+	e.int(5, int64(req.ProgressFrames))
+	return e.buf
+}
+
+// DecodeParseRequest decodes a serialized ParseRequest message.
+// The Replay field of the returned request refers to data.
+func DecodeParseRequest(data []byte) (*ParseRequest, error) {
+	req := &ParseRequest{}
+	err := decode(data, func(f *field) error {
+		switch f.num {
+		case 1:
+			req.Replay = f.b
+		case 2:
+			req.Commands = f.v != 0
+		case 3:
+			req.MapData = f.v != 0
+		case 4:
+			req.Computed = f.v != 0
+		case 5:
+			req.ProgressFrames = repcore.Frame(f.v)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid ParseRequest message: %w", err)
+	}
+	return req, nil
+}
+
+// ParseResponse returns the serialized ParseResponse message of the replay.
+func ParseResponse(r *rep.Replay) ([]byte, error) {
+	data, err := ToProto(r)
+	if err != nil {
+		return nil, err
+	}
+	e := &encoder{}
+	e.message(1, func(e *encoder) { e.buf = data })
+	return e.buf, nil
+}
+
+// DecodeParseResponse decodes the replay of a serialized ParseResponse message (see FromProto()).
+func DecodeParseResponse(data []byte) (*rep.Replay, error) {
+	var replay []byte
+	err := decode(data, func(f *field) error {
+		if f.num == 1 {
+			replay = f.b
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid ParseResponse message: %w", err)
+	}
+	return FromProto(replay)
+}
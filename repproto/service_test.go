@@ -0,0 +1,43 @@
+package repproto
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/icza/screp/rep"
+)
+
+func TestParseRequest(t *testing.T) {
+	for _, req := range []*ParseRequest{
+		{},
+		{Replay: []byte{1, 2, 3}, Commands: true, MapData: true, Computed: true, ProgressFrames: 1000},
+	} {
+		got, err := DecodeParseRequest(req.Marshal())
+		if err != nil {
+			t.Errorf("[%+v] Failed to decode: %v", req, err)
+			continue
+		}
+		if !reflect.DeepEqual(req, got) {
+			t.Errorf("Expected: %+v, got: %+v", req, got)
+		}
+	}
+
+	if _, err := DecodeParseRequest([]byte{0x0a, 5, 1}); err == nil {
+		t.Errorf("Expected error for truncated message")
+	}
+}
+
+func TestParseResponse(t *testing.T) {
+	r := &rep.Replay{Header: &rep.Header{Title: "Title", Map: "Fighting Spirit"}}
+	data, err := ParseResponse(r)
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	got, err := DecodeParseResponse(data)
+	if err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	if got.Header == nil || got.Header.Title != r.Header.Title || got.Header.Map != r.Header.Map {
+		t.Errorf("Expected: %+v, got: %+v", r.Header, got.Header)
+	}
+}
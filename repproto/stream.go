@@ -0,0 +1,205 @@
+package repproto
+
+import (
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcmd"
+	"github.com/icza/screp/rep/repcore"
+)
+
+// Kinds of AnalysisEvent messages
+const (
+	EventKindChat      = "chat"
+	EventKindLeaveGame = "leave_game"
+	EventKindBuild     = "build"
+	EventKindTrain     = "train"
+)
+
+// StreamEvents produces the ParseEvent messages of the replay, the stream of
+// ReplayService.ParseStream, calling send with each serialized message.
+// If send returns an error, streaming is aborted and the error is returned.
+//
+// The stream starts with the header, continues with the commands (and parse error commands)
+// in frame order, each followed by its AnalysisEvent (if any), and ends with the map data
+// and the computed data (those present in the replay).
+// If progressFrames > 0, a Progress message is sent after the commands of every progressFrames frames,
+// and after the last command.
+//
+// Analysis events of build and train commands are only produced for effective commands,
+// so the replay should be computed (see rep.Replay.Compute()) for them to be accurate.
+//
+// See Stream to produce the messages as the parts of the replay are parsed.
+func StreamEvents(r *rep.Replay, progressFrames repcore.Frame, send func(event []byte) error) error {
+	s := NewStream(progressFrames, send)
+
+	if r.Header != nil {
+		if err := s.Header(r.Header); err != nil {
+			return err
+		}
+	}
+
+	if r.Commands != nil {
+		cmds, pecs := r.Commands.Cmds, r.Commands.ParseErrCmds
+		for len(cmds) > 0 || len(pecs) > 0 {
+			var cmd repcmd.Cmd
+			if len(pecs) == 0 || len(cmds) > 0 && cmds[0].BaseCmd().Frame <= pecs[0].Frame {
+				cmd, cmds = cmds[0], cmds[1:]
+			} else {
+				cmd, pecs = pecs[0], pecs[1:]
+			}
+			if err := s.Cmd(cmd); err != nil {
+				return err
+			}
+		}
+		if err := s.EndCmds(); err != nil {
+			return err
+		}
+	}
+
+	if r.MapData != nil {
+		if err := s.MapData(r.MapData); err != nil {
+			return err
+		}
+	}
+	if r.Computed != nil {
+		if err := s.Computed(r.Computed); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Stream produces the ParseEvent messages of a replay part by part, so commands
+// can be streamed as they are decoded (e.g. by repparser.CmdReader) without holding all of them.
+//
+// Its methods must be called in the order of the stream (see StreamEvents()):
+// Header(), Cmd() for each command in frame order, EndCmds(), MapData() and Computed(),
+// skipping the parts not to be streamed.
+type Stream struct {
+	send func(event []byte) error
+
+	// progressFrames is the frames between Progress messages, 0 means no progress messages
+	progressFrames repcore.Frame
+
+	// frames is the total frames of the replay (from the header)
+	frames repcore.Frame
+
+	// cmdCount is the number of commands streamed so far
+	cmdCount int
+
+	// nextProgress is the frame of the command after which the next Progress message is due
+	nextProgress repcore.Frame
+}
+
+// NewStream returns a new Stream calling send with each serialized ParseEvent message.
+// If progressFrames > 0, a Progress message is sent after the commands of every progressFrames frames,
+// and by EndCmds().
+func NewStream(progressFrames repcore.Frame, send func(event []byte) error) *Stream {
+	return &Stream{send: send, progressFrames: progressFrames, nextProgress: progressFrames}
+}
+
+// event sends a ParseEvent message having a field of the given number, written by fn.
+func (s *Stream) event(num int, fn func(e *encoder) error) error {
+	e := &encoder{}
+	var err error
+	e.message(num, func(e *encoder) { err = fn(e) })
+	if err != nil {
+		return err
+	}
+	return s.send(e.buf)
+}
+
+// Header sends the header.
+func (s *Stream) Header(h *rep.Header) error {
+	s.frames = h.Frames
+	return s.event(1, func(e *encoder) error { encodeHeader(e, h); return nil })
+}
+
+// Cmd sends a command followed by its AnalysisEvent (if any), and a Progress message if due.
+// Commands that could not be parsed (*repcmd.ParseErrCmd) are sent as ParseErrCmd messages.
+//
+// Analysis events of build and train commands are only produced for effective commands,
+// so the IneffKind of commands should be classified (see rep.CmdIneffKind()) for them to be accurate.
+func (s *Stream) Cmd(cmd repcmd.Cmd) error {
+	var err error
+	if pec, ok := cmd.(*repcmd.ParseErrCmd); ok {
+		err = s.event(3, func(e *encoder) error { encodeParseErrCmd(e, pec); return nil })
+	} else if err = s.event(2, func(e *encoder) error { return encodeCmd(e, cmd) }); err == nil {
+		err = analysisEvent(cmd, s.event)
+	}
+	if err != nil {
+		return err
+	}
+	s.cmdCount++
+
+	if frame := cmd.BaseCmd().Frame; s.progressFrames > 0 && frame >= s.nextProgress {
+		if err := s.progress(frame); err != nil {
+			return err
+		}
+		s.nextProgress = (frame/s.progressFrames + 1) * s.progressFrames
+	}
+	return nil
+}
+
+// EndCmds sends the final Progress message after the last command (if progress messages are enabled
+// and there were commands).
+func (s *Stream) EndCmds() error {
+	if s.progressFrames > 0 && s.cmdCount > 0 {
+		return s.progress(s.frames)
+	}
+	return nil
+}
+
+// progress sends a Progress message.
+func (s *Stream) progress(frame repcore.Frame) error {
+	return s.event(5, func(e *encoder) error {
+		e.int(1, int64(frame))
+		e.int(2, int64(s.frames))
+		e.uint(3, uint64(s.cmdCount))
+		return nil
+	})
+}
+
+// MapData sends the map data.
+func (s *Stream) MapData(md *rep.MapData) error {
+	return s.event(6, func(e *encoder) error { encodeMapData(e, md); return nil })
+}
+
+// Computed sends the computed data.
+func (s *Stream) Computed(c *rep.Computed) error {
+	return s.event(7, func(e *encoder) error { encodeComputed(e, c); return nil })
+}
+
+// analysisEvent sends the AnalysisEvent of the command using event, if it has one.
+func analysisEvent(cmd repcmd.Cmd, event func(num int, fn func(e *encoder) error) error) error {
+	var kind, detail string
+	switch x := cmd.(type) {
+	case *repcmd.ChatCmd:
+		kind, detail = EventKindChat, x.Message
+	case *repcmd.LeaveGameCmd:
+		kind = EventKindLeaveGame
+		if x.Reason != nil {
+			detail = x.Reason.Name
+		}
+	case *repcmd.BuildCmd:
+		if x.IneffKind.Effective() && x.Unit != nil {
+			kind, detail = EventKindBuild, x.Unit.Name
+		}
+	case *repcmd.TrainCmd:
+		if x.IneffKind.Effective() && x.Unit != nil {
+			kind, detail = EventKindTrain, x.Unit.Name
+		}
+	}
+	if kind == "" {
+		return nil
+	}
+
+	base := cmd.BaseCmd()
+	return event(4, func(e *encoder) error {
+		e.int(1, int64(base.Frame))
+		e.uint(2, uint64(base.PlayerID))
+		e.string(3, kind)
+		e.string(4, detail)
+		return nil
+	})
+}
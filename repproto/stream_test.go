@@ -0,0 +1,83 @@
+package repproto
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcmd"
+	"github.com/icza/screp/rep/repcore"
+)
+
+func TestStreamEvents(t *testing.T) {
+	r := &rep.Replay{
+		Header: &rep.Header{Frames: 200},
+		Commands: &rep.Commands{
+			Cmds: []repcmd.Cmd{
+				&repcmd.ChatCmd{Base: &repcmd.Base{Frame: 10, PlayerID: 1, Type: repcmd.TypeChat}, Message: "gl hf"},
+				&repcmd.BuildCmd{Base: &repcmd.Base{Frame: 50, Type: repcmd.TypeBuild}, Unit: repcmd.UnitByID(repcmd.UnitIDSpawningPool)},
+				&repcmd.TrainCmd{Base: &repcmd.Base{Frame: 150, Type: repcmd.TypeTrain, IneffKind: repcore.IneffKindRepetition}, Unit: repcmd.UnitByID(0)},
+			},
+			ParseErrCmds: []*repcmd.ParseErrCmd{{Base: &repcmd.Base{Frame: 30, Type: repcmd.TypeChat}}},
+		},
+		Computed: &rep.Computed{},
+	}
+
+	// Describe events by their field number and some of their content
+	var got []string
+	err := StreamEvents(r, 100, func(event []byte) error {
+		return decode(event, func(f *field) error {
+			desc := fmt.Sprint(f.num)
+			switch f.num {
+			case 4, 5:
+				err := decode(f.b, func(f *field) error {
+					if f.wireType == wireBytes {
+						desc += " " + string(f.b)
+					} else {
+						desc += fmt.Sprint(" ", f.v)
+					}
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+			}
+			got = append(got, desc)
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	exp := []string{
+		"1",                        // Header
+		"2",                        // Chat command
+		"4 10 1 chat gl hf",        // Chat analysis event
+		"3",                        // Parse error command
+		"2",                        // Build command
+		"4 50 build Spawning Pool", // Build analysis event
+		"2",                        // Ineffective train command, no analysis event
+		"5 150 200 4",              // Progress after frame 100
+		"5 200 200 4",              // Final progress
+		"7",                        // Computed
+	}
+	if !reflect.DeepEqual(exp, got) {
+		t.Errorf("Expected: %q, got: %q", exp, got)
+	}
+
+	// Aborting
+	errAbort := errors.New("abort")
+	count := 0
+	err = StreamEvents(r, 0, func(event []byte) error {
+		if count++; count == 2 {
+			return errAbort
+		}
+		return nil
+	})
+	if err != errAbort || count != 2 {
+		t.Errorf("Expected: %v, %v, got: %v, %v", errAbort, 2, err, count)
+	}
+}
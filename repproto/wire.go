@@ -0,0 +1,164 @@
+// This file contains the protocol buffer wire format encoding and decoding.
+
+package repproto
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// encoder encodes the fields of a message.
+// Following proto3 semantics, fields having zero value are omitted.
+type encoder struct {
+	buf []byte
+}
+
+// tag writes the tag of a field.
+func (e *encoder) tag(num, wireType int) {
+	e.buf = binary.AppendUvarint(e.buf, uint64(num)<<3|uint64(wireType))
+}
+
+// uint writes an unsigned integer field.
+func (e *encoder) uint(num int, v uint64) {
+	if v != 0 {
+		e.optionalUint(num, v)
+	}
+}
+
+// optionalUint writes an unsigned integer field having explicit presence (written even if zero).
+func (e *encoder) optionalUint(num int, v uint64) {
+	e.tag(num, wireVarint)
+	e.buf = binary.AppendUvarint(e.buf, v)
+}
+
+// int writes a signed (int32 or int64) integer field.
+// Negative values are encoded as 10 bytes, as specified by the wire format.
+func (e *encoder) int(num int, v int64) {
+	e.uint(num, uint64(v))
+}
+
+// bool writes a bool field.
+func (e *encoder) bool(num int, v bool) {
+	if v {
+		e.uint(num, 1)
+	}
+}
+
+// string writes a string field.
+func (e *encoder) string(num int, s string) {
+	if s != "" {
+		e.tag(num, wireBytes)
+		e.buf = binary.AppendUvarint(e.buf, uint64(len(s)))
+		e.buf = append(e.buf, s...)
+	}
+}
+
+// message writes an embedded message field whose fields are written by fn.
+// The message is written even if it has no fields (so its presence is kept).
+func (e *encoder) message(num int, fn func(e *encoder)) {
+	sub := &encoder{}
+	fn(sub)
+	e.tag(num, wireBytes)
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(sub.buf)))
+	e.buf = append(e.buf, sub.buf...)
+}
+
+// packed writes a packed repeated unsigned integer field.
+func (e *encoder) packed(num int, vs []uint64) {
+	if len(vs) == 0 {
+		return
+	}
+	var data []byte
+	for _, v := range vs {
+		data = binary.AppendUvarint(data, v)
+	}
+	e.tag(num, wireBytes)
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(data)))
+	e.buf = append(e.buf, data...)
+}
+
+// field is a decoded field of a message.
+type field struct {
+	num      int
+	wireType int
+
+	// v is the value of varint fields
+	v uint64
+
+	// b is the data of length-delimited fields
+	b []byte
+}
+
+// errTruncated is returned if the encoded message is truncated.
+var errTruncated = errors.New("truncated message")
+
+// decode decodes the fields of a message, calling fn for each field.
+// Fixed size fields (unused in screp messages) are skipped.
+func decode(data []byte, fn func(f *field) error) error {
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errTruncated
+		}
+		data = data[n:]
+
+		f := &field{num: int(tag >> 3), wireType: int(tag & 7)}
+		switch f.wireType {
+		case wireVarint:
+			if f.v, n = binary.Uvarint(data); n <= 0 {
+				return errTruncated
+			}
+			data = data[n:]
+		case wireBytes:
+			size, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < size {
+				return errTruncated
+			}
+			f.b, data = data[n:n+int(size)], data[n+int(size):]
+		case wireFixed64, wireFixed32:
+			size := 8
+			if f.wireType == wireFixed32 {
+				size = 4
+			}
+			if len(data) < size {
+				return errTruncated
+			}
+			data = data[size:]
+			continue
+		default:
+			return fmt.Errorf("unsupported wire type %d of field %d", f.wireType, f.num)
+		}
+
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// uints returns the values of a repeated unsigned integer field,
+// which may be packed or not (parsers must accept both).
+func (f *field) uints() ([]uint64, error) {
+	if f.wireType == wireVarint {
+		return []uint64{f.v}, nil
+	}
+	var vs []uint64
+	for data := f.b; len(data) > 0; {
+		v, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, errTruncated
+		}
+		vs = append(vs, v)
+		data = data[n:]
+	}
+	return vs, nil
+}
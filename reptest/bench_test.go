@@ -0,0 +1,83 @@
+package reptest
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/repparser"
+)
+
+// discardLogger is a logger discarding the warnings of the parser (e.g. about custom sections).
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// benchConfigs holds the parser configurations to benchmark.
+var benchConfigs = []struct {
+	name string
+	cfg  repparser.Config
+}{
+	{"header", repparser.Config{Logger: discardLogger}},
+	{"commands", repparser.Config{Commands: true, Logger: discardLogger}},
+	{"full", repparser.Config{Commands: true, MapData: true, Logger: discardLogger}},
+	{"arena", repparser.Config{Commands: true, MapData: true, Arena: true, Logger: discardLogger}},
+}
+
+// benchCases calls f with the data of each case in a sub-benchmark.
+func benchCases(b *testing.B, f func(b *testing.B, data []byte)) {
+	for _, c := range Cases {
+		data, err := Generate(c.Options)
+		if err != nil {
+			b.Fatalf("[%s] Expected no error, got: %v", c.Name, err)
+		}
+		b.Run(c.Name, func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			b.ReportAllocs()
+			f(b, data)
+		})
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	for _, bc := range benchConfigs {
+		b.Run(bc.name, func(b *testing.B) {
+			benchCases(b, func(b *testing.B, data []byte) {
+				for range b.N {
+					if _, err := repparser.ParseConfig(data, bc.cfg); err != nil {
+						b.Fatalf("Expected no error, got: %v", err)
+					}
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkCmdReader(b *testing.B) {
+	benchCases(b, func(b *testing.B, data []byte) {
+		for range b.N {
+			_, cr, err := repparser.ParseConfigLazy(data, repparser.Config{Logger: discardLogger})
+			if err != nil {
+				b.Fatalf("Expected no error, got: %v", err)
+			}
+			for range 100 { // E.g. detecting openings
+				if _, err := cr.Next(); err != nil && err != io.EOF {
+					b.Fatalf("Expected no error, got: %v", err)
+				}
+			}
+		}
+	})
+}
+
+func BenchmarkCompute(b *testing.B) {
+	benchCases(b, func(b *testing.B, data []byte) {
+		r, err := repparser.ParseConfig(data, repparser.Config{Commands: true, MapData: true, Logger: discardLogger})
+		if err != nil {
+			b.Fatalf("Expected no error, got: %v", err)
+		}
+		b.ResetTimer()
+		for range b.N {
+			r.Computed = nil
+			r.ComputeConfig(rep.ComputeConfig{EAPM: true, Winners: true, BuildOrders: true})
+		}
+	})
+}
@@ -0,0 +1,30 @@
+/*
+
+Package reptest implements utilities for testing and benchmarking replay processing.
+
+Replays are generated synthetically (see Generate()) from parameters like the number of players,
+the number of commands and custom sections, so performance regressions and the handling
+of limits can be tested without shipping (copyrighted) replay files.
+Generation is deterministic: the same Options always result in the same replay.
+
+Cases holds a set of representative replays, which is used by the benchmarks of this package:
+
+	go test -bench . github.com/icza/screp/reptest
+
+RoundTrip() checks that parsing, encoding and parsing again a replay results in a semantically
+identical replay, catching regressions of both the parser and the encoder (repwriter).
+Its tests run it on all Cases and on the golden fixtures (including the ones written independently of repwriter).
+
+The golden test of this package parses a maintained set of small fixture replays (legacy, modern,
+1.21, UMS, observers, ShieldBattery) in testdata/golden, and compares their JSON outputs to the golden
+outputs next to them, so behavior changes across all sections are caught and reviewed deliberately.
+Most fixtures are written by repwriter, so they can't catch a misreading shared by the encoder and
+the parser. Some fixtures are therefore written byte by byte following the file format, independently
+of repwriter: a legacy replay compressed with PKWARE DCL, a replay in the 1.21+ container with the
+Remastered sections, and a legacy replay with EUC-KR encoded (Korean) texts.
+Fixtures and outputs are regenerated with:
+
+	go test ./reptest -run Golden -update
+
+*/
+package reptest
@@ -0,0 +1,243 @@
+package reptest
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcmd"
+	"github.com/icza/screp/rep/repcore"
+	"github.com/icza/screp/repparser"
+	"github.com/icza/screp/repwriter"
+	"golang.org/x/text/encoding/korean"
+)
+
+// updateGolden tells if the golden fixtures and outputs are to be (re)generated:
+//
+//	go test ./reptest -run Golden -update
+//
+// Changes of the outputs must be reviewed before committing them.
+var updateGolden = flag.Bool("update", false, "update golden fixtures and outputs in testdata/golden")
+
+// goldenDir is the directory of the golden fixture replays (.rep) and their expected JSON outputs (.json).
+const goldenDir = "testdata/golden"
+
+// goldenFixtures holds the builders of the golden fixture replays.
+// Fixtures are committed, so changes of the encoder do not change them (only -update does).
+// Fixtures built by repwriter share the encoder's understanding of the format, so the ones
+// written independently of it (see raw_test.go) must not be replaced by repwriter output.
+var goldenFixtures = []struct {
+	name  string
+	build func() ([]byte, error)
+}{
+	{"modern", func() ([]byte, error) { return repwriter.Marshal(goldenReplay(repcore.GameType1on1, false)) }},
+	{"legacy", func() ([]byte, error) {
+		data, err := repwriter.Marshal(goldenReplay(repcore.GameType1on1, false))
+		if err != nil {
+			return nil, err
+		}
+		return repparser.ToLegacy(data)
+	}},
+	{"121", func() ([]byte, error) { return repwriter.Marshal(goldenReplay(repcore.GameType1on1, true)) }},
+	{"ums", func() ([]byte, error) {
+		r := goldenReplay(repcore.GameTypeUMS, true)
+		r.MapData.Description = "Defend the base"
+		r.MapData.Colors = []*repcore.Color{repcore.ColorOrange, repcore.ColorTeal}
+		return repwriter.Marshal(r)
+	}},
+	{"obs", func() ([]byte, error) {
+		r := goldenReplay(repcore.GameTypeMelee, true)
+		h := r.Header
+		h.OrigPlayers = append(h.OrigPlayers, &rep.Player{SlotID: 2, ID: 2, Type: repcore.PlayerTypeHuman,
+			Race: repcore.RaceTerran, Team: 3, Name: "Observer", Color: repcore.ColorTeal})
+		// Players need enough build commands not to be detected as observers:
+		var cmds []repcmd.Cmd
+		for i := range 5 {
+			for pid := range byte(2) {
+				cmds = append(cmds, &repcmd.BuildCmd{Base: goldenBase(repcore.Frame(100+i*50), pid, repcmd.TypeIDBuild),
+					Order: repcmd.OrderByID(repcmd.OrderIDPlaceProtossBuilding), Pos: repcore.Point{X: 10, Y: 20}, Unit: repcmd.UnitByID(repcmd.UnitIDPylon)})
+			}
+		}
+		// Observers' commands have player IDs starting at 128:
+		cmds = append(cmds, &repcmd.ChatCmd{Base: goldenBase(400, 128, repcmd.TypeIDChat), SenderSlotID: 2, Message: "obs here"})
+		last := len(r.Commands.Cmds) - 1 // Leave game command
+		r.Commands.Cmds = slices.Insert(r.Commands.Cmds, last, cmds...)
+		return repwriter.Marshal(r)
+	}},
+	{"shieldbattery", func() ([]byte, error) {
+		r := goldenReplay(repcore.GameType1on1, true)
+		r.ShieldBattery = &rep.ShieldBattery{StarCraftExeBuild: 13515, ShieldBatteryVersion: "9.1.0", GameID: "00112233-4455-6677-8899-aabbccddeeff"}
+		return repwriter.Marshal(r)
+	}},
+
+	// Fixtures written byte by byte, independently of repwriter (see raw_test.go):
+	{"pkware", func() ([]byte, error) {
+		r := &rawReplay{
+			header: rawHeader(time.Date(2005, 3, 1, 20, 0, 0, 0, time.UTC), "Raw game", "Alice", "Raw map",
+				rawPlayer{"Alice", repcore.RaceTerran, repcore.ColorRed}, rawPlayer{"Bob", repcore.RaceZerg, repcore.ColorBlue}),
+			cmds: rawCmds(false, "gl hf"),
+			chk:  rawCHK(repcore.TileSetJungle, "Raw map"),
+		}
+		return r.encodeLegacy(), nil
+	}},
+	{"scr121", func() ([]byte, error) {
+		// Names longer than 24 bytes are cut in the header, the player names section holds them in full:
+		const longName = "PlayerWithAVeryLongName42"
+		r := &rawReplay{
+			header: rawHeader(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), "Raw game", "Alice", "Raw map",
+				rawPlayer{"Alice", repcore.RaceProtoss, repcore.ColorRed}, rawPlayer{longName, repcore.RaceTerran, repcore.ColorBlue}),
+			cmds:        rawCmds(true, "gl hf"),
+			chk:         rawCHK(repcore.TileSetBadlands, "Raw map"),
+			playerNames: rawPlayerNames("Alice", longName),
+			modern: []rawModernSection{
+				{"SKIN", make([]byte, 0x15e0)},
+				{"LMTS", make([]byte, 0x1c)},
+				{"BFIX", make([]byte, 0x08)},
+				{"CCLR", rawColors(repcore.ColorOrange, repcore.ColorTeal)},
+				{"GCFG", make([]byte, 0x19)},
+			},
+		}
+		return r.encode121(), nil
+	}},
+	{"euckr", func() ([]byte, error) {
+		enc := korean.EUCKR.NewEncoder()
+		var strs [6]string
+		for i, s := range []string{"한국 게임", "홍길동", "투혼", "이순신", "안녕하세요", "투혼 1.3"} {
+			var err error
+			if strs[i], err = enc.String(s); err != nil {
+				return nil, err
+			}
+		}
+		title, alice, mapName, bob, chat, chkName := strs[0], strs[1], strs[2], strs[3], strs[4], strs[5]
+		r := &rawReplay{
+			header: rawHeader(time.Date(2008, 7, 1, 10, 0, 0, 0, time.UTC), title, alice, mapName,
+				rawPlayer{alice, repcore.RaceZerg, repcore.ColorRed}, rawPlayer{bob, repcore.RaceProtoss, repcore.ColorBlue}),
+			cmds: rawCmds(false, chat),
+			chk:  rawCHK(repcore.TileSetJungle, chkName),
+		}
+		return r.encodeLegacy(), nil
+	}},
+}
+
+// goldenBase returns the base of a command of the golden fixtures.
+func goldenBase(frame repcore.Frame, pid byte, typeID byte) *repcmd.Base {
+	return &repcmd.Base{Frame: frame, PlayerID: pid, Type: repcmd.TypeByID(typeID)}
+}
+
+// goldenReplay returns a small replay of 2 players for the golden fixtures,
+// using commands introduced in 1.21 if cmds121 is true.
+func goldenReplay(gameType *repcore.GameType, cmds121 bool) *rep.Replay {
+	selectID, rightClickID := repcmd.TypeIDSelect, repcmd.TypeIDRightClick
+	if cmds121 {
+		selectID, rightClickID = repcmd.TypeIDSelect121, repcmd.TypeIDRightClick121
+	}
+
+	return &rep.Replay{
+		Header: &rep.Header{
+			Engine:          repcore.EngineBroodWar,
+			Frames:          2000,
+			StartTime:       time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+			Title:           "Golden game",
+			MapWidth:        32,
+			MapHeight:       32,
+			AvailSlotsCount: 4,
+			Speed:           repcore.SpeedFastest,
+			Type:            gameType,
+			SubType:         1,
+			Host:            "Alice",
+			Map:             "Golden map",
+			OrigPlayers: []*rep.Player{
+				{SlotID: 0, ID: 0, Type: repcore.PlayerTypeHuman, Race: repcore.RaceZerg, Team: 1, Name: "Alice", Color: repcore.ColorRed},
+				{SlotID: 1, ID: 1, Type: repcore.PlayerTypeHuman, Race: repcore.RaceProtoss, Team: 2, Name: "Bob", Color: repcore.ColorBlue},
+			},
+		},
+		Commands: &rep.Commands{Cmds: []repcmd.Cmd{
+			&repcmd.SelectCmd{Base: goldenBase(10, 0, selectID), UnitTags: []repcmd.UnitTag{1, 2}},
+			&repcmd.RightClickCmd{Base: goldenBase(10, 0, rightClickID), Pos: repcore.Point{X: 100, Y: 200}, Unit: repcmd.UnitByID(0xe4)},
+			&repcmd.TrainCmd{Base: goldenBase(20, 0, repcmd.TypeIDTrain), Unit: repcmd.UnitByID(0x29)},
+			&repcmd.BuildCmd{Base: goldenBase(30, 1, repcmd.TypeIDBuild), Order: repcmd.OrderByID(repcmd.OrderIDPlaceProtossBuilding),
+				Pos: repcore.Point{X: 10, Y: 20}, Unit: repcmd.UnitByID(repcmd.UnitIDPylon)},
+			&repcmd.HotkeyCmd{Base: goldenBase(40, 1, repcmd.TypeIDHotkey), HotkeyType: repcmd.HotkeyTypeByID(0), Group: 1},
+			&repcmd.ChatCmd{Base: goldenBase(50, 1, repcmd.TypeIDChat), SenderSlotID: 1, Message: "gl hf"},
+			&repcmd.LeaveGameCmd{Base: goldenBase(1900, 1, repcmd.TypeIDLeaveGame), Reason: repcmd.LeaveReasonByID(1)},
+		}},
+		MapData: &rep.MapData{
+			TileSet:        repcore.TileSetJungle,
+			Name:           "Golden map",
+			MineralFields:  []rep.Resource{{Point: repcore.Point{X: 100, Y: 100}, Amount: 1500}},
+			Geysers:        []rep.Resource{{Point: repcore.Point{X: 200, Y: 100}, Amount: 5000}},
+			StartLocations: []rep.StartLocation{{Point: repcore.Point{X: 300, Y: 300}, SlotID: 0}, {Point: repcore.Point{X: 700, Y: 700}, SlotID: 1}},
+		},
+	}
+}
+
+// goldenJSON returns the JSON output of a golden fixture: the replay parsed with all sections, and computed.
+func goldenJSON(data []byte) ([]byte, error) {
+	// Output must not depend on the local time zone:
+	cfg := repparser.Config{Commands: true, MapData: true, MapGraphics: true, Logger: discardLogger, Location: time.UTC}
+	r, err := repparser.ParseConfig(data, cfg)
+	if err != nil {
+		return nil, err
+	}
+	r.Compute()
+	out, err := json.MarshalIndent(r, "", "  ")
+	return append(out, '\n'), err
+}
+
+func TestGolden(t *testing.T) {
+	if *updateGolden {
+		if err := os.MkdirAll(goldenDir, 0o755); err != nil {
+			t.Fatalf("Failed to create golden dir: %v", err)
+		}
+	}
+
+	for _, f := range goldenFixtures {
+		repFile := filepath.Join(goldenDir, f.name+".rep")
+		jsonFile := filepath.Join(goldenDir, f.name+".json")
+
+		if *updateGolden {
+			data, err := f.build()
+			if err != nil {
+				t.Errorf("[%s] Failed to build fixture: %v", f.name, err)
+				continue
+			}
+			if err := os.WriteFile(repFile, data, 0o644); err != nil {
+				t.Errorf("[%s] Failed to write fixture: %v", f.name, err)
+				continue
+			}
+		}
+
+		data, err := os.ReadFile(repFile)
+		if err != nil {
+			t.Errorf("[%s] Failed to read fixture: %v", f.name, err)
+			continue
+		}
+		got, err := goldenJSON(data)
+		if err != nil {
+			t.Errorf("[%s] Failed to parse fixture: %v", f.name, err)
+			continue
+		}
+
+		if *updateGolden {
+			if err := os.WriteFile(jsonFile, got, 0o644); err != nil {
+				t.Errorf("[%s] Failed to write golden output: %v", f.name, err)
+			}
+			continue
+		}
+
+		exp, err := os.ReadFile(jsonFile)
+		if err != nil {
+			t.Errorf("[%s] Failed to read golden output: %v", f.name, err)
+			continue
+		}
+		if !bytes.Equal(exp, got) {
+			t.Errorf("[%s] Output differs from %s (if the change is intended, rerun with -update and review the diff)", f.name, jsonFile)
+		}
+	}
+}
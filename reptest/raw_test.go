@@ -0,0 +1,457 @@
+// This file contains a byte-level writer of replay files, following the file format directly.
+// It is independent of repwriter and repencoder, so the golden fixtures built with it check
+// the parser against data not produced by screp's own encoder: legacy replays compressed with
+// PKWARE DCL (including back-references, unlike repencoder.EncodeLegacy()), and the 1.21+
+// container format ("seRS" replay ID), which repencoder never writes.
+
+package reptest
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"github.com/icza/screp/rep/repcmd"
+	"github.com/icza/screp/rep/repcore"
+	"github.com/icza/screp/repparser/repdecoder"
+)
+
+// rawChunkSize is the max size of the uncompressed data of a chunk.
+const rawChunkSize = 0x2000
+
+// rawReplay holds the (uncompressed) section data of a replay.
+type rawReplay struct {
+	header []byte // 0x279 bytes
+	cmds   []byte
+	chk    []byte
+
+	// Sections of modern replays only:
+	playerNames []byte // 0x300 bytes
+	modern      []rawModernSection
+}
+
+// rawModernSection is a section added in modern replays, identified by a 4-character ID.
+type rawModernSection struct {
+	id   string
+	data []byte
+}
+
+// encodeLegacy returns the replay in the legacy (pre 1.18) format.
+func (r *rawReplay) encodeLegacy() []byte {
+	buf := &bytes.Buffer{}
+	writeLegacySection(buf, []byte("reRS"))
+	writeLegacySection(buf, r.header)
+	writeLegacySection(buf, binary.LittleEndian.AppendUint32(nil, uint32(len(r.cmds))))
+	writeLegacySection(buf, r.cmds)
+	writeLegacySection(buf, binary.LittleEndian.AppendUint32(nil, uint32(len(r.chk))))
+	writeLegacySection(buf, r.chk)
+	return buf.Bytes()
+}
+
+// encode121 returns the replay in the 1.21+ format.
+func (r *rawReplay) encode121() []byte {
+	buf := &bytes.Buffer{}
+	writeModernSection(buf, []byte("seRS"))
+
+	// The header section is preceded by a 4-byte value (its encoded size), which parsers skip:
+	hbuf := &bytes.Buffer{}
+	writeModernSection(hbuf, r.header)
+	buf.Write(binary.LittleEndian.AppendUint32(nil, uint32(hbuf.Len())))
+	buf.Write(hbuf.Bytes())
+
+	writeModernSection(buf, binary.LittleEndian.AppendUint32(nil, uint32(len(r.cmds))))
+	writeModernSection(buf, r.cmds)
+	writeModernSection(buf, binary.LittleEndian.AppendUint32(nil, uint32(len(r.chk))))
+	writeModernSection(buf, r.chk)
+	writeModernSection(buf, r.playerNames)
+
+	for _, ms := range r.modern {
+		sbuf := &bytes.Buffer{}
+		writeModernSection(sbuf, ms.data)
+		buf.WriteString(ms.id)
+		buf.Write(binary.LittleEndian.AppendUint32(nil, uint32(sbuf.Len())))
+		buf.Write(sbuf.Bytes())
+	}
+	return buf.Bytes()
+}
+
+// writeLegacySection writes a section of a legacy replay: a checksum, the number of chunks
+// and the chunks, each imploded independently (chunks which don't compress are stored as-is).
+func writeLegacySection(buf *bytes.Buffer, data []byte) {
+	writeRawSection(buf, data, func(chunk []byte) []byte {
+		if c := implode(chunk); len(c) < len(chunk) {
+			return c
+		}
+		return chunk
+	})
+}
+
+// writeModernSection writes a section of a modern replay: a checksum, the number of chunks
+// and the chunks, each compressed independently with zlib (chunks of at most 4 bytes are stored as-is).
+func writeModernSection(buf *bytes.Buffer, data []byte) {
+	writeRawSection(buf, data, func(chunk []byte) []byte {
+		if len(chunk) <= 4 {
+			return chunk
+		}
+		cbuf := &bytes.Buffer{}
+		zw, _ := zlib.NewWriterLevel(cbuf, zlib.BestCompression) // Valid level, no error
+		zw.Write(chunk)
+		zw.Close()
+		return cbuf.Bytes()
+	})
+}
+
+// writeRawSection writes a section using the given chunk encoder. Empty sections are not written.
+func writeRawSection(buf *bytes.Buffer, data []byte, encodeChunk func(chunk []byte) []byte) {
+	if len(data) == 0 {
+		return
+	}
+	le := binary.LittleEndian
+	buf.Write(le.AppendUint32(nil, crc32.ChecksumIEEE(data)))
+	buf.Write(le.AppendUint32(nil, uint32((len(data)+rawChunkSize-1)/rawChunkSize)))
+	for len(data) > 0 {
+		chunk := encodeChunk(data[:min(len(data), rawChunkSize)])
+		data = data[min(len(data), rawChunkSize):]
+		buf.Write(le.AppendUint32(nil, uint32(len(chunk))))
+		buf.Write(chunk)
+	}
+}
+
+// PKWARE DCL code tables (binary mode): codes are written LSB first.
+var (
+	// implodeLenBits and implodeLenCodes are the bit lengths and codes of the length indices
+	implodeLenBits  = []byte{3, 2, 3, 3, 4, 4, 4, 5, 5, 5, 5, 6, 6, 6, 7, 7}
+	implodeLenCodes = []byte{0x05, 0x03, 0x01, 0x06, 0x0a, 0x02, 0x0c, 0x14, 0x04, 0x18, 0x08, 0x30, 0x10, 0x20, 0x40, 0x00}
+
+	// implodeLenBases and implodeLenExtraBits are the base values and the extra bits of the length indices
+	implodeLenBases     = []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 0x0a, 0x0e, 0x16, 0x26, 0x46, 0x86, 0x106}
+	implodeLenExtraBits = []byte{0, 0, 0, 0, 0, 0, 0, 0, 1, 2, 3, 4, 5, 6, 7, 8}
+
+	// implodeDistBits and implodeDistCodes are the bit lengths and codes of the high bits of distances
+	implodeDistBits = []byte{
+		2, 4, 4, 5, 5, 5, 5, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 7, 7, 7, 7, 7, 7, 7, 7, 7, 7,
+		7, 7, 7, 7, 7, 7, 7, 7, 7, 7, 7, 7, 7, 7, 7, 7, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8,
+	}
+	implodeDistCodes = []byte{
+		0x03, 0x0d, 0x05, 0x19, 0x09, 0x11, 0x01, 0x3e, 0x1e, 0x2e, 0x0e, 0x36, 0x16, 0x26, 0x06, 0x3a,
+		0x1a, 0x2a, 0x0a, 0x32, 0x12, 0x22, 0x42, 0x02, 0x7c, 0x3c, 0x5c, 0x1c, 0x6c, 0x2c, 0x4c, 0x0c,
+		0x74, 0x34, 0x54, 0x14, 0x64, 0x24, 0x44, 0x04, 0x78, 0x38, 0x58, 0x18, 0x68, 0x28, 0x48, 0x08,
+		0xf0, 0x70, 0xb0, 0x30, 0xd0, 0x50, 0x90, 0x10, 0xe0, 0x60, 0xa0, 0x20, 0xc0, 0x40, 0x80, 0x00,
+	}
+)
+
+const (
+	// implodeDictBits is the number of low distance bits written as-is, 6 means a 4 KB dictionary
+	implodeDictBits = 6
+
+	// implodeMaxLen is the max length of a back-reference
+	implodeMaxLen = 518
+
+	// implodeEndOfStream is the length value marking the end of the stream
+	implodeEndOfStream = 0x205
+)
+
+// bitWriter writes bits LSB first.
+type bitWriter struct {
+	out  []byte
+	acc  uint64
+	bits uint
+}
+
+// write writes the n low bits of v.
+func (bw *bitWriter) write(v uint32, n uint) {
+	bw.acc |= uint64(v&(1<<n-1)) << bw.bits
+	for bw.bits += n; bw.bits >= 8; bw.bits -= 8 {
+		bw.out = append(bw.out, byte(bw.acc))
+		bw.acc >>= 8
+	}
+}
+
+// flush writes the remaining bits padded to a byte, and returns the output.
+func (bw *bitWriter) flush() []byte {
+	if bw.bits > 0 {
+		bw.out = append(bw.out, byte(bw.acc))
+	}
+	return bw.out
+}
+
+// implode compresses data with the PKWARE Data Compression Library in binary mode,
+// using a greedy matcher emitting literals and back-references, closed by the end of stream code.
+func implode(data []byte) []byte {
+	bw := &bitWriter{out: []byte{0, implodeDictBits}} // Binary mode, dictionary size bits
+
+	writeLen := func(v int) {
+		idx := len(implodeLenBases) - 1
+		for implodeLenBases[idx] > v {
+			idx--
+		}
+		bw.write(uint32(implodeLenCodes[idx]), uint(implodeLenBits[idx]))
+		bw.write(uint32(v-implodeLenBases[idx]), uint(implodeLenExtraBits[idx]))
+	}
+
+	for i := 0; i < len(data); {
+		length, dist := longestMatch(data, i)
+		if length < 2 || length == 2 && dist > 0x100 {
+			bw.write(0, 1) // Literal
+			bw.write(uint32(data[i]), 8)
+			i++
+			continue
+		}
+
+		bw.write(1, 1) // Back-reference
+		writeLen(length - 2)
+		lowBits := uint(implodeDictBits)
+		if length == 2 {
+			lowBits = 2
+		}
+		high := (dist - 1) >> lowBits
+		bw.write(uint32(implodeDistCodes[high]), uint(implodeDistBits[high]))
+		bw.write(uint32(dist-1), lowBits)
+		i += length
+	}
+
+	bw.write(1, 1)
+	writeLen(implodeEndOfStream)
+	return bw.flush()
+}
+
+// longestMatch returns the longest (and closest) match of the data at pos in the preceding data.
+func longestMatch(data []byte, pos int) (length, dist int) {
+	maxDist := min(pos, 64<<implodeDictBits)
+	maxLen := min(len(data)-pos, implodeMaxLen)
+	for d := 1; d <= maxDist; d++ {
+		l := 0
+		for l < maxLen && data[pos-d+l] == data[pos+l] {
+			l++
+		}
+		if l > length {
+			length, dist = l, d
+			if l == maxLen {
+				break
+			}
+		}
+	}
+	return
+}
+
+// rawPlayer is a player of a raw replay.
+type rawPlayer struct {
+	name  string // In the encoding of the replay
+	race  *repcore.Race
+	color *repcore.Color
+}
+
+// rawHeader returns the header section of a 1on1 game of the given players.
+// Strings must be in the encoding of the replay.
+func rawHeader(start time.Time, title, host, mapName string, players ...rawPlayer) []byte {
+	le := binary.LittleEndian
+	h := make([]byte, 0x279)
+	h[0x00] = repcore.EngineBroodWar.ID
+	le.PutUint32(h[0x01:], 2400) // Frames
+	le.PutUint32(h[0x08:], uint32(start.Unix()))
+	copy(h[0x18:0x18+27], title)
+	le.PutUint16(h[0x34:], 32) // Map width
+	le.PutUint16(h[0x36:], 32) // Map height
+	h[0x39] = 2                // Available slots
+	h[0x3a] = repcore.SpeedFastest.ID
+	le.PutUint16(h[0x3c:], repcore.GameType1on1.ID)
+	le.PutUint16(h[0x3e:], 1) // Sub type
+	copy(h[0x48:0x48+23], host)
+	copy(h[0x61:0x61+25], mapName)
+
+	for i, p := range players {
+		ps := h[0xa1+i*36:]
+		le.PutUint16(ps, uint16(i)) // Slot ID
+		ps[4] = byte(i)             // Player ID
+		ps[8] = repcore.PlayerTypeHuman.ID
+		ps[9] = p.race.ID
+		ps[10] = byte(i + 1) // Team
+		copy(ps[11:11+24], p.name)
+		le.PutUint32(h[0x251+i*4:], p.color.ID)
+	}
+	return h
+}
+
+// rawCmdBlock returns a command block of the given frame holding the given commands.
+func rawCmdBlock(frame uint32, cmds ...[]byte) []byte {
+	block := binary.LittleEndian.AppendUint32(nil, frame)
+	block = append(block, 0)
+	for _, cmd := range cmds {
+		block = append(block, cmd...)
+	}
+	block[4] = byte(len(block) - 5)
+	return block
+}
+
+// rawCmd returns a command of the given player and type, followed by the given fields
+// (a byte field is written as 1 byte, a uint16 field as 2 bytes).
+func rawCmd(pid, typeID byte, fields ...any) []byte {
+	cmd := []byte{pid, typeID}
+	for _, f := range fields {
+		switch f := f.(type) {
+		case byte:
+			cmd = append(cmd, f)
+		case uint16:
+			cmd = binary.LittleEndian.AppendUint16(cmd, f)
+		}
+	}
+	return cmd
+}
+
+// rawCmds returns the commands section of a short game of 2 players, using commands introduced in 1.21
+// if cmds121 is true. chat is sent by the second player, it must be in the encoding of the replay.
+func rawCmds(cmds121 bool, chat string) []byte {
+	selectCmd := rawCmd(0, repcmd.TypeIDSelect, byte(2), uint16(1), uint16(2))
+	rightClick := rawCmd(0, repcmd.TypeIDRightClick, uint16(100), uint16(200), uint16(0), uint16(0xe4), byte(0))
+	if cmds121 {
+		selectCmd = rawCmd(0, repcmd.TypeIDSelect121, byte(2), uint16(1), uint16(0), uint16(2), uint16(0))
+		rightClick = rawCmd(0, repcmd.TypeIDRightClick121, uint16(100), uint16(200), uint16(0), uint16(0), uint16(0xe4), byte(0))
+	}
+	chatCmd := make([]byte, 80)
+	copy(chatCmd[:79], chat)
+	chatCmd = append(rawCmd(1, repcmd.TypeIDChat, byte(1)), chatCmd...)
+
+	var cmds []byte
+	for _, block := range [][]byte{
+		rawCmdBlock(10, selectCmd, rightClick),
+		rawCmdBlock(20, rawCmd(0, repcmd.TypeIDTrain, uint16(0x29))),
+		rawCmdBlock(30, rawCmd(1, repcmd.TypeIDBuild, byte(repcmd.OrderIDPlaceProtossBuilding), uint16(10), uint16(20), uint16(repcmd.UnitIDPylon))),
+		rawCmdBlock(40, rawCmd(1, repcmd.TypeIDHotkey, byte(0), byte(1))),
+		rawCmdBlock(50, chatCmd),
+		rawCmdBlock(2300, rawCmd(1, repcmd.TypeIDLeaveGame, byte(1))),
+	} {
+		cmds = append(cmds, block...)
+	}
+	return cmds
+}
+
+// rawCHK returns the map data section of a 32x32 map of 2 start locations, including the
+// sub-sections the game needs but screp does not process (ISOM, TILE), and a strings section of
+// 1024 strings like the map editor writes, so the section spans more than one chunk.
+// name must be in the encoding of the replay.
+func rawCHK(tileSet *repcore.TileSet, name string) []byte {
+	le := binary.LittleEndian
+	var chk []byte
+	section := func(id string, data []byte) {
+		chk = append(chk, id...)
+		chk = le.AppendUint32(chk, uint32(len(data)))
+		chk = append(chk, data...)
+	}
+
+	section("VER ", le.AppendUint16(nil, 205))
+	section("ERA ", le.AppendUint16(nil, tileSet.ID))
+	section("DIM ", le.AppendUint16(le.AppendUint16(nil, 32), 32))
+	section("OWNR", []byte{6, 6, 6, 6, 6, 6, 6, 6, 0, 0, 0, 7})
+	section("SIDE", []byte{5, 5, 5, 5, 5, 5, 5, 5, 7, 7, 7, 4})
+
+	var isom, tiles []byte
+	for y := range 33 {
+		for x := range 17 * 2 {
+			isom = le.AppendUint16(isom, uint16(0x10*(y%3)+x%5))
+		}
+	}
+	for y := range 32 {
+		for x := range 32 {
+			tiles = le.AppendUint16(tiles, uint16(0x20*((x/8+y/8)%4)+x%16))
+		}
+	}
+	section("ISOM", isom)
+	section("TILE", tiles)
+	section("MTXM", tiles)
+
+	var units []byte
+	for i, u := range []struct {
+		x, y, id uint16
+		owner    byte
+		amount   uint32
+	}{
+		{100, 100, repcmd.UnitIDStartLocation, 0, 0},
+		{900, 900, repcmd.UnitIDStartLocation, 1, 0},
+		{160, 100, repcmd.UnitIDMineralField1, 11, 1500},
+		{840, 900, repcmd.UnitIDMineralField2, 11, 1500},
+		{100, 200, repcmd.UnitIDVespeneGeyser, 11, 5000},
+	} {
+		unit := make([]byte, 36)
+		le.PutUint32(unit, uint32(i+1)) // Serial number
+		le.PutUint16(unit[4:], u.x)
+		le.PutUint16(unit[6:], u.y)
+		le.PutUint16(unit[8:], u.id)
+		le.PutUint16(unit[14:], 0x3f) // Valid elements
+		unit[16] = u.owner
+		unit[17], unit[18], unit[19] = 100, 100, 100 // Hit points, shield, energy %
+		le.PutUint32(unit[20:], u.amount)
+		units = append(units, unit...)
+	}
+	section("UNIT", units)
+
+	// Strings: name, description, and the remaining ones empty, sharing the same offset.
+	const strCount = 1024
+	strs := le.AppendUint16(nil, strCount)
+	offset := 2 + 2*strCount
+	texts := []string{name, "Generated byte by byte"}
+	for i := range strCount {
+		strs = le.AppendUint16(strs, uint16(offset))
+		if i < len(texts) {
+			offset += len(texts[i]) + 1
+		}
+	}
+	for _, text := range texts {
+		strs = append(append(strs, text...), 0)
+	}
+	strs = append(strs, 0) // The empty string
+	section("STR ", strs)
+	section("SPRP", le.AppendUint16(le.AppendUint16(nil, 1), 2))
+
+	return chk
+}
+
+// rawPlayerNames returns the player names section holding the given names.
+func rawPlayerNames(names ...string) []byte {
+	data := make([]byte, 0x300)
+	for i, name := range names {
+		copy(data[i*96:i*96+95], name)
+	}
+	return data
+}
+
+// rawColors returns the player colors section (CCLR) holding the given colors.
+func rawColors(colors ...*repcore.Color) []byte {
+	data := make([]byte, 0xc0)
+	for i, c := range colors {
+		copy(data[i*16:], c.Footprint())
+	}
+	return data
+}
+
+func TestImplode(t *testing.T) {
+	rnd := rand.New(rand.NewPCG(1, 2))
+	random := make([]byte, 3000)
+	for i := range random {
+		random[i] = byte(rnd.IntN(256))
+	}
+
+	cases := []struct {
+		name string
+		data []byte
+	}{
+		{"literals", []byte("StarCraft")},
+		{"short matches", []byte("abXYabZZab")},
+		{"long run", make([]byte, rawChunkSize)},
+		{"text", bytes.Repeat([]byte("gl hf, gg wp! "), 300)},
+		{"random", random},
+		{"mixed", append(append(random[:500:500], make([]byte, 1000)...), random[:700]...)},
+	}
+
+	for _, c := range cases {
+		imploded := implode(c.data)
+		dst := make([]byte, len(c.data)+1)
+		n, err := repdecoder.Explode(imploded, dst)
+		if err != nil || !bytes.Equal(dst[:n], c.data) {
+			t.Errorf("[%s] Expected: %d bytes, got: %d bytes (err: %v)", c.name, len(c.data), n, err)
+		}
+	}
+}
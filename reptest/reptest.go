@@ -0,0 +1,295 @@
+// This file contains the synthetic replay generator.
+
+package reptest
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcmd"
+	"github.com/icza/screp/rep/repcore"
+	"github.com/icza/screp/repwriter"
+)
+
+const (
+	// MaxPlayers is the max number of players of generated replays.
+	MaxPlayers = 8
+
+	// MinMapSize is the min size of generated maps (in tiles).
+	MinMapSize = 64
+
+	// MaxMapSize is the max size of generated maps (in tiles).
+	MaxMapSize = 256
+
+	// maxSelected is the max number of units selected by a select command.
+	maxSelected = 12
+
+	// maxUnitTag is the max unit tag used in commands.
+	maxUnitTag = 1700
+)
+
+// ErrInvalidOptions is returned if options are out of their valid range.
+var ErrInvalidOptions = errors.New("invalid options")
+
+// Options are the parameters of a generated replay.
+// Zero values are substituted with defaults (noted at the fields).
+type Options struct {
+	// Players is the number of players, at most MaxPlayers (2 by default).
+	Players int
+
+	// Frames is the length of the game (10 minutes by default).
+	Frames repcore.Frame
+
+	// CmdsPerPlayer is the number of commands of each player (2000 by default),
+	// spread uniformly over the game.
+	CmdsPerPlayer int
+
+	// ChatRate is the ratio of chat commands in the range of 0..1.
+	ChatRate float64
+
+	// MapSize is the width and height of the map in tiles, in the range of MinMapSize..MaxMapSize (128 by default).
+	MapSize int
+
+	// MapData tells if map data (tiles, resources and start locations) is to be generated,
+	// else only minimal map data is written.
+	MapData bool
+
+	// CustomSections is the number of custom (3rd party vendor) sections.
+	CustomSections int
+
+	// CustomSectionSize is the size of custom sections in bytes.
+	CustomSectionSize int
+
+	// Seed of the random data.
+	Seed uint64
+}
+
+// withDefaults returns a copy of opts having defaults substituted, or an error if opts are invalid.
+func (opts Options) withDefaults() (Options, error) {
+	opts.Players = cmp.Or(opts.Players, 2)
+	opts.Frames = cmp.Or(opts.Frames, repcore.Duration2Frame(10*time.Minute))
+	opts.CmdsPerPlayer = cmp.Or(opts.CmdsPerPlayer, 2000)
+	opts.MapSize = cmp.Or(opts.MapSize, 128)
+
+	switch {
+	case opts.Players < 1 || opts.Players > MaxPlayers:
+		return opts, fmt.Errorf("%w: players must be in range 1..%d", ErrInvalidOptions, MaxPlayers)
+	case opts.Frames < 1:
+		return opts, fmt.Errorf("%w: frames must be positive", ErrInvalidOptions)
+	case opts.CmdsPerPlayer < 0:
+		return opts, fmt.Errorf("%w: commands per player must not be negative", ErrInvalidOptions)
+	case opts.ChatRate < 0 || opts.ChatRate > 1:
+		return opts, fmt.Errorf("%w: chat rate must be in range 0..1", ErrInvalidOptions)
+	case opts.MapSize < MinMapSize || opts.MapSize > MaxMapSize:
+		return opts, fmt.Errorf("%w: map size must be in range %d..%d", ErrInvalidOptions, MinMapSize, MaxMapSize)
+	case opts.CustomSections < 0 || opts.CustomSections > 1000 || opts.CustomSectionSize < 0:
+		return opts, fmt.Errorf("%w: custom sections must be in range 0..1000 having non-negative size", ErrInvalidOptions)
+	}
+	return opts, nil
+}
+
+// Case is a named representative replay.
+type Case struct {
+	Name    string
+	Options Options
+}
+
+// Cases holds the representative replays.
+var Cases = []*Case{
+	{"1v1", Options{Players: 2, Frames: repcore.Duration2Frame(14 * time.Minute), CmdsPerPlayer: 4000, ChatRate: 0.002}},
+	{"1v1-long", Options{Players: 2, Frames: repcore.Duration2Frame(time.Hour), CmdsPerPlayer: 18000, ChatRate: 0.001}},
+	{"4v4", Options{Players: 8, Frames: repcore.Duration2Frame(25 * time.Minute), CmdsPerPlayer: 3000, ChatRate: 0.01, MapSize: 192}},
+	{"ffa-mapdata", Options{Players: 4, CmdsPerPlayer: 1500, MapSize: 256, MapData: true}},
+	{"vendor-sections", Options{Players: 2, CmdsPerPlayer: 1000, CustomSections: 8, CustomSectionSize: 64 << 10}},
+}
+
+// Generate returns the .rep file content of a generated replay.
+func Generate(opts Options) ([]byte, error) {
+	r, err := Replay(opts)
+	if err != nil {
+		return nil, err
+	}
+	opts, _ = opts.withDefaults()
+
+	rnd := rand.New(rand.NewPCG(opts.Seed, 1))
+	custom := make([]*repwriter.Section, opts.CustomSections)
+	for i := range custom {
+		data := make([]byte, opts.CustomSectionSize)
+		for j := range data {
+			data[j] = byte(rnd.Uint32())
+		}
+		custom[i] = &repwriter.Section{ID: fmt.Sprintf("T%03d", i), Data: data}
+	}
+
+	return repwriter.Marshal(r, custom...)
+}
+
+// Replay returns a generated replay. Custom sections are not part of the returned replay,
+// they are only generated by Generate().
+func Replay(opts Options) (*rep.Replay, error) {
+	opts, err := opts.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+	g := &generator{opts: opts, rnd: rand.New(rand.NewPCG(opts.Seed, 0))}
+
+	r := &rep.Replay{Header: g.header()}
+	r.Commands = &rep.Commands{Cmds: g.cmds(r.Header.OrigPlayers)}
+	if opts.MapData {
+		r.MapData = g.mapData(r.Header)
+	}
+	return r, nil
+}
+
+// generator generates the parts of a replay.
+type generator struct {
+	opts Options
+	rnd  *rand.Rand
+}
+
+// header generates the replay header.
+func (g *generator) header() *rep.Header {
+	opts := g.opts
+
+	gameType := repcore.GameTypeMelee
+	if opts.Players == 2 {
+		gameType = repcore.GameType1on1
+	}
+
+	players := make([]*rep.Player, opts.Players)
+	for i := range players {
+		players[i] = &rep.Player{
+			SlotID: uint16(i),
+			ID:     byte(i),
+			Type:   repcore.PlayerTypeHuman,
+			Race:   repcore.Races[g.rnd.IntN(3)],
+			Team:   byte(i%2 + 1),
+			Name:   fmt.Sprintf("Player%d", i+1),
+			Color:  repcore.Colors[i],
+		}
+	}
+
+	return &rep.Header{
+		Engine:          repcore.EngineBroodWar,
+		Frames:          opts.Frames,
+		StartTime:       time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(g.rnd.Int64N(int64(365 * 24 * time.Hour)))),
+		Title:           "Generated game",
+		MapWidth:        uint16(opts.MapSize),
+		MapHeight:       uint16(opts.MapSize),
+		AvailSlotsCount: byte(opts.Players),
+		Speed:           repcore.SpeedFastest,
+		Type:            gameType,
+		SubType:         1,
+		Host:            players[0].Name,
+		Map:             fmt.Sprintf("Generated map %dx%d", opts.MapSize, opts.MapSize),
+		OrigPlayers:     players,
+	}
+}
+
+// workerIDs holds the unit IDs of workers by race ID.
+var workerIDs = []uint16{0x29, 0x07, 0x40} // Drone, SCV, Probe
+
+// buildingIDs holds the unit IDs of buildings by race ID.
+var buildingIDs = []uint16{repcmd.UnitIDSpawningPool, repcmd.UnitIDBarracks, repcmd.UnitIDGateway}
+
+// cmds generates the commands of the players, sorted by frame.
+// Players other than the first one leave the game in the last frame.
+func (g *generator) cmds(players []*rep.Player) []repcmd.Cmd {
+	opts := g.opts
+
+	cmds := make([]repcmd.Cmd, 0, opts.Players*opts.CmdsPerPlayer+opts.Players-1)
+	for _, p := range players {
+		for range opts.CmdsPerPlayer {
+			cmds = append(cmds, g.cmd(p, repcore.Frame(g.rnd.Int32N(int32(opts.Frames)))))
+		}
+	}
+	slices.SortStableFunc(cmds, func(a, b repcmd.Cmd) int {
+		return cmp.Compare(a.BaseCmd().Frame, b.BaseCmd().Frame)
+	})
+
+	for _, p := range players[1:] {
+		cmds = append(cmds, &repcmd.LeaveGameCmd{Base: base(opts.Frames-1, p.ID, repcmd.TypeIDLeaveGame), Reason: repcmd.LeaveReasonByID(1)})
+	}
+	return cmds
+}
+
+// cmd generates a random command of a player.
+func (g *generator) cmd(p *rep.Player, frame repcore.Frame) repcmd.Cmd {
+	if g.opts.ChatRate > 0 && g.rnd.Float64() < g.opts.ChatRate {
+		return &repcmd.ChatCmd{Base: base(frame, p.ID, repcmd.TypeIDChat), SenderSlotID: byte(p.SlotID), Message: fmt.Sprintf("msg %d", g.rnd.IntN(1000))}
+	}
+
+	// Weights roughly follow the distribution of commands in real games.
+	switch n := g.rnd.IntN(100); {
+	case n < 35:
+		tags := make([]repcmd.UnitTag, 1+g.rnd.IntN(maxSelected))
+		for i := range tags {
+			tags[i] = g.unitTag()
+		}
+		return &repcmd.SelectCmd{Base: base(frame, p.ID, repcmd.TypeIDSelect121), UnitTags: tags}
+	case n < 65:
+		return &repcmd.RightClickCmd{Base: base(frame, p.ID, repcmd.TypeIDRightClick121), Pos: g.point(), Unit: repcmd.UnitByID(repcmd.UnitIDNone), Queued: n < 40}
+	case n < 80:
+		return &repcmd.HotkeyCmd{Base: base(frame, p.ID, repcmd.TypeIDHotkey), HotkeyType: repcmd.HotkeyTypeByID(byte(g.rnd.IntN(2))), Group: byte(g.rnd.IntN(10))}
+	case n < 90:
+		return &repcmd.TargetedOrderCmd{Base: base(frame, p.ID, repcmd.TypeIDTargetedOrder121), Pos: g.point(), Unit: repcmd.UnitByID(repcmd.UnitIDNone), Order: repcmd.OrderByID(repcmd.OrderIDAttackMove)}
+	case n < 97:
+		return &repcmd.TrainCmd{Base: base(frame, p.ID, repcmd.TypeIDTrain), Unit: repcmd.UnitByID(workerIDs[p.Race.ID])}
+	default:
+		return &repcmd.BuildCmd{Base: base(frame, p.ID, repcmd.TypeIDBuild), Order: repcmd.OrderByID(repcmd.OrderIDPlaceProtossBuilding), Pos: g.tilePoint(), Unit: repcmd.UnitByID(buildingIDs[p.Race.ID])}
+	}
+}
+
+// unitTag returns a random unit tag.
+func (g *generator) unitTag() repcmd.UnitTag {
+	return repcmd.UnitTag(g.rnd.IntN(maxUnitTag))
+}
+
+// point returns a random point on the map (in pixels).
+func (g *generator) point() repcore.Point {
+	size := g.opts.MapSize * 32
+	return repcore.Point{X: uint16(g.rnd.IntN(size)), Y: uint16(g.rnd.IntN(size))}
+}
+
+// tilePoint returns a random point on the map (in tiles).
+func (g *generator) tilePoint() repcore.Point {
+	return repcore.Point{X: uint16(g.rnd.IntN(g.opts.MapSize)), Y: uint16(g.rnd.IntN(g.opts.MapSize))}
+}
+
+// mapData generates map data: random tiles, and a start location with resources for each player.
+func (g *generator) mapData(h *rep.Header) *rep.MapData {
+	md := &rep.MapData{
+		Version: 205,
+		TileSet: repcore.TileSetJungle,
+		Name:    h.Map,
+		Tiles:   make([]uint16, g.opts.MapSize*g.opts.MapSize),
+	}
+	for i := range md.Tiles {
+		md.Tiles[i] = uint16(g.rnd.IntN(0x1000))
+	}
+
+	for _, p := range h.OrigPlayers {
+		pos := g.point()
+		md.StartLocations = append(md.StartLocations, rep.StartLocation{Point: pos, SlotID: byte(p.SlotID)})
+		for i := range 8 {
+			md.MineralFields = append(md.MineralFields, rep.Resource{Point: offset(pos, -200, -128+32*i), Amount: 1500})
+		}
+		md.Geysers = append(md.Geysers, rep.Resource{Point: offset(pos, 200, 0), Amount: 5000})
+	}
+	return md
+}
+
+// offset returns p offset by dx and dy, clamped to 0.
+func offset(p repcore.Point, dx, dy int) repcore.Point {
+	return repcore.Point{X: uint16(max(int(p.X)+dx, 0)), Y: uint16(max(int(p.Y)+dy, 0))}
+}
+
+// base returns a new command base.
+func base(frame repcore.Frame, pid byte, typeID byte) *repcmd.Base {
+	return &repcmd.Base{Frame: frame, PlayerID: pid, Type: repcmd.TypeByID(typeID)}
+}
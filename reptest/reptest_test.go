@@ -0,0 +1,78 @@
+package reptest
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/icza/screp/repparser"
+)
+
+func TestGenerate(t *testing.T) {
+	for _, c := range Cases {
+		data, err := Generate(c.Options)
+		if err != nil {
+			t.Errorf("[%s] Expected no error, got: %v", c.Name, err)
+			continue
+		}
+		r, err := repparser.ParseConfig(data, repparser.Config{Commands: true, MapData: true, Logger: discardLogger})
+		if err != nil {
+			t.Errorf("[%s] Expected no error, got: %v", c.Name, err)
+			continue
+		}
+		sections, err := repparser.DecodeSections(data)
+		if err != nil {
+			t.Errorf("[%s] Expected no error, got: %v", c.Name, err)
+			continue
+		}
+
+		opts, _ := c.Options.withDefaults()
+		checks := []struct {
+			name     string
+			exp, got any
+		}{
+			{"players", opts.Players, len(r.Header.Players)},
+			{"frames", opts.Frames, r.Header.Frames},
+			{"cmds", opts.Players*opts.CmdsPerPlayer + opts.Players - 1, len(r.Commands.Cmds)},
+			{"parse err cmds", 0, len(r.Commands.ParseErrCmds)},
+			{"map width", uint16(opts.MapSize), r.Header.MapWidth},
+			{"start locations", opts.MapData, len(r.MapData.StartLocations) == opts.Players},
+			{"sections", opts.CustomSections, len(sections) - 5}, // 4 legacy sections and CCLR precede custom sections
+		}
+		for _, ch := range checks {
+			if ch.exp != ch.got {
+				t.Errorf("[%s] %s: Expected: %v, got: %v", c.Name, ch.name, ch.exp, ch.got)
+			}
+		}
+	}
+}
+
+func TestGenerateDeterministic(t *testing.T) {
+	opts := Options{CmdsPerPlayer: 100, MapData: true, CustomSections: 1, CustomSectionSize: 10, Seed: 42}
+	data1, err1 := Generate(opts)
+	data2, err2 := Generate(opts)
+	if err1 != nil || err2 != nil || !bytes.Equal(data1, data2) {
+		t.Errorf("Expected same data, got: %v, %v, %v", err1, err2, bytes.Equal(data1, data2))
+	}
+
+	opts.Seed++
+	if data3, _ := Generate(opts); bytes.Equal(data1, data3) {
+		t.Errorf("Expected different data for different seeds")
+	}
+}
+
+func TestGenerateInvalid(t *testing.T) {
+	cases := []Options{
+		{Players: MaxPlayers + 1},
+		{Frames: -1},
+		{CmdsPerPlayer: -1},
+		{ChatRate: 2},
+		{MapSize: MaxMapSize + 1},
+		{CustomSections: -1},
+	}
+	for i, opts := range cases {
+		if _, err := Generate(opts); !errors.Is(err, ErrInvalidOptions) {
+			t.Errorf("[%d] Expected: %v, got: %v", i, ErrInvalidOptions, err)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+// This file contains the round-trip check of the parser and the encoder.
+
+package reptest
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/repparser"
+	"github.com/icza/screp/repwriter"
+)
+
+// MaxDiffs is the max number of differences returned by RoundTrip().
+const MaxDiffs = 20
+
+// RoundTrip parses a replay, encodes the parsed replay (see repwriter.Marshal()) and parses the result,
+// then compares the 2 parsed replays semantically: by their JSON representation
+// (so e.g. the format of the encoding and debug data are not compared).
+// The encoder always writes the modern format, so fields describing the format the replay was
+// read from are not compared either: Header.Version, and the ColorSource and NameEncoding of players
+// (as e.g. legacy replays have no CCLR section and may use legacy text encodings).
+// This catches regressions of both the parser and the encoder.
+//
+// The given parser configuration is used, with Commands, MapData and Debug enabled
+// (debug data allows the encoder to retain the raw map data and unknown header fields).
+//
+// The returned diffs describe the differences (at most MaxDiffs), empty if the replays are identical.
+func RoundTrip(repData []byte, cfg repparser.Config) (diffs []string, err error) {
+	cfg.Commands, cfg.MapData, cfg.Debug = true, true, true
+
+	r, err := repparser.ParseConfig(repData, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("parsing replay: %w", err)
+	}
+	data, err := repwriter.Marshal(r)
+	if err != nil {
+		return nil, fmt.Errorf("encoding replay: %w", err)
+	}
+	r2, err := repparser.ParseConfig(data, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("parsing encoded replay: %w", err)
+	}
+
+	clearFormatFields(r)
+	clearFormatFields(r2)
+	v, err := jsonValue(r)
+	if err != nil {
+		return nil, err
+	}
+	v2, err := jsonValue(r2)
+	if err != nil {
+		return nil, err
+	}
+	diff("", v, v2, &diffs)
+	return diffs, nil
+}
+
+// clearFormatFields clears the fields of a parsed replay which depend on the format it was read from.
+func clearFormatFields(r *rep.Replay) {
+	r.Header.Version = ""
+	for _, p := range r.Header.Slots {
+		p.ColorSource = nil
+		p.NameEncoding, p.NameEncodingConfidence = "", 0
+	}
+}
+
+// jsonValue returns the generic JSON representation of a replay.
+func jsonValue(r *rep.Replay) (v any, err error) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling replay: %w", err)
+	}
+	err = json.Unmarshal(data, &v)
+	return
+}
+
+// diff appends the differences of the generic JSON values a and b at the given path to diffs.
+func diff(path string, a, b any, diffs *[]string) {
+	if len(*diffs) >= MaxDiffs {
+		return
+	}
+
+	switch a := a.(type) {
+	case map[string]any:
+		if b, ok := b.(map[string]any); ok {
+			keys := make([]string, 0, len(a)+len(b))
+			for k := range a {
+				keys = append(keys, k)
+			}
+			for k := range b {
+				if _, ok := a[k]; !ok {
+					keys = append(keys, k)
+				}
+			}
+			slices.Sort(keys)
+			for _, k := range keys {
+				diff(path+"."+k, a[k], b[k], diffs)
+			}
+			return
+		}
+	case []any:
+		if b, ok := b.([]any); ok {
+			if len(a) != len(b) {
+				*diffs = append(*diffs, fmt.Sprintf("%s: length %d != %d", path, len(a), len(b)))
+				return
+			}
+			for i := range a {
+				diff(fmt.Sprintf("%s[%d]", path, i), a[i], b[i], diffs)
+			}
+			return
+		}
+	}
+
+	if !reflect.DeepEqual(a, b) {
+		*diffs = append(*diffs, fmt.Sprintf("%s: %#v != %#v", path, a, b))
+	}
+}
@@ -0,0 +1,69 @@
+package reptest
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/icza/screp/repparser"
+)
+
+func TestRoundTrip(t *testing.T) {
+	for _, c := range Cases {
+		data, err := Generate(c.Options)
+		if err != nil {
+			t.Errorf("[%s] Expected no error, got: %v", c.Name, err)
+			continue
+		}
+		diffs, err := RoundTrip(data, repparser.Config{Logger: discardLogger})
+		if err != nil {
+			t.Errorf("[%s] Expected no error, got: %v", c.Name, err)
+			continue
+		}
+		for _, d := range diffs {
+			t.Errorf("[%s] Round-trip difference: %s", c.Name, d)
+		}
+	}
+}
+
+func TestRoundTripGolden(t *testing.T) {
+	// Golden fixtures include replays written independently of repwriter.
+	for _, f := range goldenFixtures {
+		data, err := os.ReadFile(filepath.Join(goldenDir, f.name+".rep"))
+		if err != nil {
+			t.Errorf("[%s] Failed to read fixture: %v", f.name, err)
+			continue
+		}
+		diffs, err := RoundTrip(data, repparser.Config{Logger: discardLogger})
+		if err != nil {
+			t.Errorf("[%s] Expected no error, got: %v", f.name, err)
+			continue
+		}
+		for _, d := range diffs {
+			t.Errorf("[%s] Round-trip difference: %s", f.name, d)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	cases := []struct {
+		name  string
+		a, b  any
+		diffs []string
+	}{
+		{"identical", map[string]any{"A": []any{1.0, "x"}}, map[string]any{"A": []any{1.0, "x"}}, nil},
+		{"value", map[string]any{"A": []any{1.0, "x"}}, map[string]any{"A": []any{2.0, "x"}}, []string{".A[0]: 1 != 2"}},
+		{"length", []any{1.0}, []any{1.0, 2.0}, []string{": length 1 != 2"}},
+		{"missing key", map[string]any{"A": 1.0}, map[string]any{"B": 1.0}, []string{".A: 1 != <nil>", ".B: <nil> != 1"}},
+		{"type", map[string]any{"A": 1.0}, map[string]any{"A": "1"}, []string{`.A: 1 != "1"`}},
+	}
+
+	for _, c := range cases {
+		var diffs []string
+		diff("", c.a, c.b, &diffs)
+		if !slices.Equal(diffs, c.diffs) {
+			t.Errorf("[%s] Expected: %v, got: %v", c.name, c.diffs, diffs)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+/*
+
+Package repwasm implements a facade of screp for WebAssembly (js/wasm) hosts,
+so browser-based replay analyzers can parse replays client-side, without a server.
+
+The facade does no file I/O: it parses replay data given as a byte slice
+(a Uint8Array on the JavaScript side) and returns the JSON encoding of the replay.
+Parse() is usable on any platform (the cmd/screplib C shared library uses it too); Register() (available on js/wasm only) exposes it
+to JavaScript as the global screp object:
+
+	screp.parse(data, options)     // returns the JSON text of the replay
+	screp.parseObject(data, options) // returns the replay as a JavaScript object
+
+Both functions return an Error (instead of throwing it) if the replay cannot be parsed.
+The options parameter is optional, its fields are those of Options (in lowerCamelCase).
+
+The cmd/screpwasm command is the WebAssembly module to load, and screp.js is a small
+JavaScript wrapper loading the module and exposing the above functions, throwing the errors.
+
+*/
+package repwasm
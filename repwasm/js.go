@@ -0,0 +1,61 @@
+//go:build js && wasm
+
+package repwasm
+
+import (
+	"syscall/js"
+)
+
+// Register exposes the facade to JavaScript as the global screp object
+// (see the package doc for its functions).
+func Register() {
+	js.Global().Set("screp", js.ValueOf(map[string]any{
+		"parse":       js.FuncOf(jsParse),
+		"parseObject": js.FuncOf(jsParseObject),
+	}))
+}
+
+// jsParse implements screp.parse(data, options).
+func jsParse(this js.Value, args []js.Value) any {
+	data, err := jsCall(args)
+	if err != nil {
+		return jsError(err)
+	}
+	return string(data)
+}
+
+// jsParseObject implements screp.parseObject(data, options).
+func jsParseObject(this js.Value, args []js.Value) any {
+	data, err := jsCall(args)
+	if err != nil {
+		return jsError(err)
+	}
+	return js.Global().Get("JSON").Call("parse", string(data))
+}
+
+// jsCall parses the replay given in the JavaScript arguments (data and optional options).
+func jsCall(args []js.Value) ([]byte, error) {
+	if len(args) == 0 || !args[0].InstanceOf(js.Global().Get("Uint8Array")) {
+		return nil, errNoData
+	}
+	repData := make([]byte, args[0].Length())
+	js.CopyBytesToGo(repData, args[0])
+
+	var optsJSON []byte
+	if len(args) > 1 && args[1].Type() == js.TypeObject {
+		optsJSON = []byte(js.Global().Get("JSON").Call("stringify", args[1]).String())
+	}
+	opts, err := ParseOptions(optsJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(repData, opts)
+}
+
+// jsError returns a JavaScript Error having the message of err.
+// Go functions called from JavaScript must not panic (it would terminate the module),
+// so errors are returned instead of thrown, the JavaScript wrapper throws them.
+func jsError(err error) any {
+	return js.Global().Get("Error").New(err.Error())
+}
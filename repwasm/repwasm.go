@@ -0,0 +1,114 @@
+package repwasm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/repjson"
+	"github.com/icza/screp/repparser"
+)
+
+// errNoData is returned if no replay data is given.
+var errNoData = errors.New("no replay data (Uint8Array) given")
+
+// Options of parsing and encoding a replay.
+type Options struct {
+	// Commands tells if the commands section is to be parsed
+	Commands bool `json:"commands"`
+
+	// MapData tells if the map data section is to be parsed
+	MapData bool `json:"mapData"`
+
+	// Compute tells if the computed data is to be included (see rep.Replay.Compute())
+	Compute bool `json:"compute"`
+
+	// Frames is the encoding of frames: "frames" (default), "seconds" or "clock"
+	Frames string `json:"frames"`
+
+	// Enums is the encoding of enumerations: "struct" (default), "idname" or "id"
+	Enums string `json:"enums"`
+
+	// Raw tells if the Raw* and Debug fields are to be included
+	Raw bool `json:"raw"`
+
+	// CamelCase tells if lowerCamelCase keys are to be used
+	CamelCase bool `json:"camelCase"`
+
+	// TileCoords tells if the tile coordinates of points are to be added
+	TileCoords bool `json:"tileCoords"`
+}
+
+// DefaultOptions returns the default options: all sections are parsed and computed data is included.
+func DefaultOptions() Options {
+	return Options{Commands: true, MapData: true, Compute: true}
+}
+
+// ParseOptions parses options from their JSON encoding.
+// Missing fields are taken from DefaultOptions(). An empty input results in the default options.
+func ParseOptions(data []byte) (opts Options, err error) {
+	opts = DefaultOptions()
+	if len(data) == 0 {
+		return
+	}
+	err = json.Unmarshal(data, &opts)
+	return
+}
+
+// jsonOptions returns the repjson options of opts.
+func (opts Options) jsonOptions() (jo repjson.Options, err error) {
+	jo.Raw, jo.CamelCase, jo.TileCoords = opts.Raw, opts.CamelCase, opts.TileCoords
+
+	switch opts.Frames {
+	case "", "frames":
+		jo.Frames = repjson.FramesAsFrames
+	case "seconds":
+		jo.Frames = repjson.FramesAsSeconds
+	case "clock":
+		jo.Frames = repjson.FramesAsClock
+	default:
+		return jo, fmt.Errorf("invalid frames option: %q", opts.Frames)
+	}
+
+	switch opts.Enums {
+	case "", "struct":
+		jo.Enums = repjson.EnumsAsStructs
+	case "idname":
+		jo.Enums = repjson.EnumsAsIDName
+	case "id":
+		jo.Enums = repjson.EnumsAsID
+	default:
+		return jo, fmt.Errorf("invalid enums option: %q", opts.Enums)
+	}
+
+	return
+}
+
+// Parse parses the given replay data, and returns the JSON encoding of the replay.
+func Parse(repData []byte, opts Options) ([]byte, error) {
+	jo, err := opts.jsonOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := parse(repData, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return repjson.Marshal(r, jo)
+}
+
+// parse parses the given replay data according to opts.
+func parse(repData []byte, opts Options) (*rep.Replay, error) {
+	cfg := repparser.Config{Commands: opts.Commands, MapData: opts.MapData, Debug: opts.Raw}
+	r, err := repparser.ParseConfig(repData, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Compute {
+		r.Compute()
+	}
+	return r, nil
+}
@@ -0,0 +1,82 @@
+package repwasm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/icza/screp/repparser/repencoder"
+)
+
+// testReplay builds a synthetic replay of a player named "Alice".
+func testReplay(t *testing.T) []byte {
+	t.Helper()
+
+	header := make([]byte, 0x279)
+	binary.LittleEndian.PutUint32(header[0x01:], 1000) // Frames
+	ps := header[0xa1:]
+	ps[8] = 2 // Human
+	copy(ps[11:], "Alice")
+
+	buf := &bytes.Buffer{}
+	err := repencoder.Encode(buf, []*repencoder.Section{{Data: header}, {Data: nil}, {Data: nil}, {Data: make([]byte, 0x300)}})
+	if err != nil {
+		t.Fatalf("Failed to encode replay: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestParseOptions(t *testing.T) {
+	cases := []struct {
+		json  string
+		exp   Options
+		valid bool
+	}{
+		{"", DefaultOptions(), true},
+		{"{}", DefaultOptions(), true},
+		{`{"compute":false,"camelCase":true,"frames":"clock"}`, Options{Commands: true, MapData: true, Frames: "clock", CamelCase: true}, true},
+		{`{"compute":1}`, Options{}, false},
+	}
+
+	for _, c := range cases {
+		opts, err := ParseOptions([]byte(c.json))
+		if (err == nil) != c.valid || c.valid && opts != c.exp {
+			t.Errorf("[%s] Expected: %+v, %v, got: %+v, %v", c.json, c.exp, c.valid, opts, err)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	repData := testReplay(t)
+
+	cases := []struct {
+		name    string
+		opts    Options
+		expSubs []string // Expected substrings of the output
+		valid   bool
+	}{
+		{"default", DefaultOptions(), []string{`"Frames":1000`, `"Name":"Alice"`, `"Computed":{`}, true},
+		{"no compute", Options{}, []string{`"Computed":null`}, true},
+		{"options", Options{Frames: "clock", Enums: "id", CamelCase: true}, []string{`"frames":"00:42"`, `"race":0`}, true},
+		{"invalid frames", Options{Frames: "minutes"}, nil, false},
+		{"invalid enums", Options{Enums: "name"}, nil, false},
+	}
+
+	for _, c := range cases {
+		data, err := Parse(repData, c.opts)
+		if (err == nil) != c.valid {
+			t.Errorf("[%s] Expected valid: %v, got error: %v", c.name, c.valid, err)
+			continue
+		}
+		for _, sub := range c.expSubs {
+			if !strings.Contains(string(data), sub) {
+				t.Errorf("[%s] Expected: %s, got: %s", c.name, sub, data)
+			}
+		}
+	}
+
+	if _, err := Parse([]byte{1, 2, 3}, DefaultOptions()); err == nil {
+		t.Errorf("Expected: %v, got: %v", "error", err)
+	}
+}
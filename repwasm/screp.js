@@ -0,0 +1,50 @@
+// screp.js is a small wrapper of the screp WebAssembly module (see the repwasm package).
+//
+// It requires the wasm_exec.js support file of the Go distribution to be loaded first
+// (it defines the global Go class). Usage:
+//
+//   import { load } from "./screp.js";
+//
+//   const screp = await load("screp.wasm");
+//   const replay = screp.parse(new Uint8Array(await file.arrayBuffer()), { camelCase: true });
+//
+// Options are those of repwasm.Options (commands, mapData, compute, frames, enums, raw, camelCase).
+
+let loading = null;
+
+// load loads and starts the WebAssembly module from the given URL (once),
+// and returns the screp API.
+export function load(url = "screp.wasm") {
+  if (loading === null) {
+    loading = start(url);
+  }
+  return loading;
+}
+
+async function start(url) {
+  if (typeof globalThis.Go !== "function") {
+    throw new Error("wasm_exec.js must be loaded before screp.js");
+  }
+  const go = new globalThis.Go();
+  const resp = fetch(url);
+  const { instance } = WebAssembly.instantiateStreaming
+    ? await WebAssembly.instantiateStreaming(resp, go.importObject)
+    : await WebAssembly.instantiate(await (await resp).arrayBuffer(), go.importObject);
+  go.run(instance); // Not awaited: the module keeps running.
+
+  const api = globalThis.screp;
+  return {
+    // parse returns the replay as a JavaScript object.
+    parse: (data, options) => check(api.parseObject(data, options)),
+    // parseJSON returns the JSON text of the replay.
+    parseJSON: (data, options) => check(api.parse(data, options)),
+  };
+}
+
+// check throws the result if it is an error.
+function check(result) {
+  if (result instanceof Error) {
+    throw result;
+  }
+  return result;
+}
@@ -0,0 +1,167 @@
+// This file contains the encoding of map data (CHK).
+
+package repwriter
+
+import (
+	"encoding/binary"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcmd"
+	"github.com/icza/screp/rep/repcore"
+)
+
+// defaultCHKVersion is the version written if map data has no version (1.04+ Brood War).
+const defaultCHKVersion = 205
+
+// Default player owners and sides of the 12 player slots: 8 human slots
+// (races selectable by users), 3 inactive slots and a neutral slot.
+var (
+	defaultOwners = []byte{6, 6, 6, 6, 6, 6, 6, 6, 0, 0, 0, 7}
+	defaultSides  = []byte{5, 5, 5, 5, 5, 5, 5, 5, 7, 7, 7, 4}
+)
+
+// chkUnitSize is the size of a placed unit in the UNIT sub-section.
+const chkUnitSize = 36
+
+// neutralSlotID is the slot ID of the neutral player (owner of resources).
+const neutralSlotID = 11
+
+// encodeMapData encodes the map data section.
+//
+// The raw map data is returned if it is available (md.Debug), else the sub-sections
+// modeled by rep.MapData are built. A minimal map is built from the header if md is nil.
+func encodeMapData(md *rep.MapData, h *rep.Header) []byte {
+	if md != nil && md.Debug != nil && len(md.Debug.Data) > 0 {
+		return md.Debug.Data
+	}
+	if md == nil {
+		md = &rep.MapData{Name: h.Map}
+	}
+
+	bo := binary.LittleEndian
+	w := &chkWriter{}
+
+	version := md.Version
+	if version == 0 {
+		version = defaultCHKVersion
+	}
+	w.section("VER ", bo.AppendUint16(nil, version))
+
+	w.section("DIM ", bo.AppendUint16(bo.AppendUint16(nil, h.MapWidth), h.MapHeight))
+
+	var tileSet uint16
+	if md.TileSet != nil {
+		tileSet = md.TileSet.ID
+	}
+	w.section("ERA ", bo.AppendUint16(nil, tileSet))
+
+	owners := defaultOwners
+	if len(md.PlayerOwners) > 0 {
+		owners = make([]byte, len(defaultOwners))
+		for i, o := range md.PlayerOwners[:min(len(md.PlayerOwners), len(owners))] {
+			owners[i] = o.ID
+		}
+	}
+	w.section("OWNR", owners)
+
+	sides := defaultSides
+	if len(md.PlayerSides) > 0 {
+		sides = make([]byte, len(defaultSides))
+		for i, s := range md.PlayerSides[:min(len(md.PlayerSides), len(sides))] {
+			sides[i] = s.ID
+		}
+	}
+	w.section("SIDE", sides)
+
+	if len(md.Colors) > 0 {
+		colors := make([]byte, maxPlayers)
+		for i, c := range md.Colors[:min(len(md.Colors), len(colors))] {
+			colors[i] = byte(c.ID)
+		}
+		w.section("COLR", colors)
+	}
+
+	tiles := md.Tiles
+	if len(tiles) == 0 {
+		tiles = make([]uint16, int(h.MapWidth)*int(h.MapHeight))
+	}
+	var mtxm []byte
+	for _, t := range tiles {
+		mtxm = bo.AppendUint16(mtxm, t)
+	}
+	w.section("MTXM", mtxm)
+
+	if len(md.FogMask) > 0 {
+		w.section("MASK", md.FogMask)
+	}
+
+	var units []byte
+	for _, mf := range md.MineralFields {
+		units = appendCHKUnit(units, mf.Point, repcmd.UnitIDMineralField1, neutralSlotID, mf.Amount)
+	}
+	for _, g := range md.Geysers {
+		units = appendCHKUnit(units, g.Point, repcmd.UnitIDVespeneGeyser, neutralSlotID, g.Amount)
+	}
+	for _, sl := range md.StartLocations {
+		units = appendCHKUnit(units, sl.Point, repcmd.UnitIDStartLocation, sl.SlotID, 0)
+	}
+	w.section("UNIT", units)
+
+	// Strings: the scenario name (1) and description (2).
+	strs := []string{md.Name, md.Description}
+	w.section("SPRP", bo.AppendUint16(bo.AppendUint16(nil, 1), 2))
+	w.section("STR ", encodeStrings(strs))
+
+	return w.data
+}
+
+// chkWriter writes CHK sub-sections.
+type chkWriter struct {
+	data []byte
+}
+
+// section writes a sub-section: its ID, size and data.
+func (w *chkWriter) section(id string, data []byte) {
+	w.data = append(w.data, id...)
+	w.data = binary.LittleEndian.AppendUint32(w.data, uint32(len(data)))
+	w.data = append(w.data, data...)
+}
+
+// appendCHKUnit appends a placed unit of the UNIT sub-section to data.
+func appendCHKUnit(data []byte, pos repcore.Point, unitID uint16, ownerID byte, resAmount uint32) []byte {
+	bo := binary.LittleEndian
+
+	serial := uint32(len(data) / chkUnitSize)
+	data = bo.AppendUint32(data, serial)
+	data = bo.AppendUint16(data, pos.X)
+	data = bo.AppendUint16(data, pos.Y)
+	data = bo.AppendUint16(data, unitID)
+	data = bo.AppendUint16(data, 0)        // Relation to another building
+	data = bo.AppendUint16(data, 0)        // Flags of special properties
+	data = bo.AppendUint16(data, 0)        // Valid elements flag
+	data = append(data, ownerID, 100, 100) // Owner, hit points %, shield points %
+	data = append(data, 100)               // Energy points %
+	data = bo.AppendUint32(data, resAmount)
+	data = append(data, make([]byte, 4)...) // Hangar unit count, state flags
+	data = bo.AppendUint32(data, 0)         // Unused
+	data = bo.AppendUint32(data, 0)         // Related unit serial
+	return data
+}
+
+// encodeStrings encodes the STR sub-section: the count, the offsets of the
+// strings (relative to the section start), then the 0x00 terminated strings.
+func encodeStrings(strs []string) []byte {
+	bo := binary.LittleEndian
+
+	data := bo.AppendUint16(nil, uint16(len(strs)))
+	offset := 2 + 2*len(strs)
+	for _, s := range strs {
+		data = bo.AppendUint16(data, uint16(offset))
+		offset += len(s) + 1
+	}
+	for _, s := range strs {
+		data = append(data, s...)
+		data = append(data, 0)
+	}
+	return data
+}
@@ -0,0 +1,256 @@
+// This file contains the encoding of player commands.
+
+package repwriter
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/icza/screp/rep/repcmd"
+)
+
+// maxCmdBlockSize is the max size of a command block (its size is stored in a byte).
+const maxCmdBlockSize = 255
+
+// chatMessageSize is the size of the message of chat commands.
+const chatMessageSize = 80
+
+// skippedSizes holds the size of the parameters of commands the parser skips
+// (these are stored as bare *repcmd.Base commands, parameters are written as zeros).
+var skippedSizes = map[byte]int{
+	repcmd.TypeIDSync:               6,
+	repcmd.TypeIDVoiceSquelch:       1,
+	repcmd.TypeIDVoiceUnsquelch:     1,
+	repcmd.TypeIDDownloadPercentage: 1,
+	repcmd.TypeIDChangeGameSlot:     5,
+	repcmd.TypeIDNewNetPlayer:       7,
+	repcmd.TypeIDJoinedGame:         17,
+	repcmd.TypeIDChangeRace:         2,
+	repcmd.TypeIDTeamGameTeam:       1,
+	repcmd.TypeIDUMSTeam:            1,
+	repcmd.TypeIDMeleeTeam:          2,
+	repcmd.TypeIDSwapPlayers:        2,
+	repcmd.TypeIDSavedData:          12,
+	repcmd.TypeIDReplaySpeed:        9,
+	repcmd.TypeIDSaveGame:           4, // Size of the (omitted) save data
+	repcmd.TypeIDLoadGame:           4, // Size of the (omitted) save data
+}
+
+// encodeCmds encodes the commands section.
+// Commands of the same frame are written into the same command block
+// (multiple blocks are used if they don't fit into one).
+func encodeCmds(cmds []repcmd.Cmd) ([]byte, error) {
+	var (
+		data     []byte
+		blockPos = -1 // Position of the current command block
+	)
+
+	for _, cmd := range cmds {
+		base := cmd.BaseCmd()
+		encoded, err := encodeCmd(cmd)
+		if err != nil {
+			return nil, err
+		}
+
+		if blockPos >= 0 {
+			frame := binary.LittleEndian.Uint32(data[blockPos:])
+			if frame != uint32(base.Frame) || len(data)-blockPos-5+len(encoded) > maxCmdBlockSize {
+				blockPos = -1
+			}
+		}
+		if blockPos < 0 {
+			blockPos = len(data)
+			data = binary.LittleEndian.AppendUint32(data, uint32(base.Frame))
+			data = append(data, 0) // Block size, set below
+		}
+		data = append(data, encoded...)
+		data[blockPos+4] = byte(len(data) - blockPos - 5)
+	}
+
+	return data, nil
+}
+
+// encodeCmd encodes a command: player ID, type ID and the parameters.
+func encodeCmd(cmd repcmd.Cmd) ([]byte, error) {
+	bo := binary.LittleEndian
+
+	base := cmd.BaseCmd()
+	if base.Type == nil {
+		return nil, fmt.Errorf("command without type at frame %d", base.Frame)
+	}
+	typeID := base.Type.ID
+	if typeID == repcmd.VirtualTypeIDLand {
+		typeID = repcmd.TypeIDBuild // Land is recorded as a Build command
+	}
+	data := []byte{base.PlayerID, typeID}
+
+	switch x := cmd.(type) {
+	case *repcmd.RightClickCmd:
+		data = bo.AppendUint16(data, x.Pos.X)
+		data = bo.AppendUint16(data, x.Pos.Y)
+		data = bo.AppendUint16(data, uint16(x.UnitTag))
+		if typeID == repcmd.TypeIDRightClick121 {
+			data = bo.AppendUint16(data, 0)
+		}
+		data = bo.AppendUint16(data, unitID(x.Unit))
+		data = append(data, boolByte(x.Queued))
+
+	case *repcmd.SelectCmd:
+		data = append(data, byte(len(x.UnitTags)))
+		for _, tag := range x.UnitTags {
+			data = bo.AppendUint16(data, uint16(tag))
+			if typeID == repcmd.TypeIDSelect121 || typeID == repcmd.TypeIDSelectAdd121 || typeID == repcmd.TypeIDSelectRemove121 {
+				data = bo.AppendUint16(data, 0)
+			}
+		}
+
+	case *repcmd.HotkeyCmd:
+		var hotkeyType byte
+		if x.HotkeyType != nil {
+			hotkeyType = x.HotkeyType.ID
+		}
+		data = append(data, hotkeyType, x.Group)
+
+	case *repcmd.TrainCmd:
+		data = bo.AppendUint16(data, unitID(x.Unit))
+
+	case *repcmd.TargetedOrderCmd:
+		data = bo.AppendUint16(data, x.Pos.X)
+		data = bo.AppendUint16(data, x.Pos.Y)
+		data = bo.AppendUint16(data, uint16(x.UnitTag))
+		if typeID == repcmd.TypeIDTargetedOrder121 {
+			data = bo.AppendUint16(data, 0)
+		}
+		data = bo.AppendUint16(data, unitID(x.Unit))
+		data = append(data, orderID(x.Order), boolByte(x.Queued))
+
+	case *repcmd.BuildCmd:
+		data = append(data, orderID(x.Order))
+		data = bo.AppendUint16(data, x.Pos.X)
+		data = bo.AppendUint16(data, x.Pos.Y)
+		data = bo.AppendUint16(data, unitID(x.Unit))
+
+	case *repcmd.LandCmd:
+		data = append(data, repcmd.OrderIDBuildingLand)
+		data = bo.AppendUint16(data, x.Pos.X)
+		data = bo.AppendUint16(data, x.Pos.Y)
+		data = bo.AppendUint16(data, unitID(x.Unit))
+
+	case *repcmd.QueueableCmd:
+		data = append(data, boolByte(x.Queued))
+
+	case *repcmd.LeaveGameCmd:
+		var reason byte
+		if x.Reason != nil {
+			reason = x.Reason.ID
+		}
+		data = append(data, reason)
+
+	case *repcmd.MinimapPingCmd:
+		data = bo.AppendUint16(data, x.Pos.X)
+		data = bo.AppendUint16(data, x.Pos.Y)
+
+	case *repcmd.ChatCmd:
+		data = append(data, x.SenderSlotID)
+		msg := make([]byte, chatMessageSize)
+		putString(msg, x.Message)
+		data = append(data, msg...)
+
+	case *repcmd.VisionCmd:
+		var slots uint16
+		for _, slotID := range x.SlotIDs {
+			slots |= 1 << slotID
+		}
+		data = bo.AppendUint16(data, slots)
+
+	case *repcmd.AllianceCmd:
+		status := uint32(0x01) // Allied
+		if x.AlliedVictory {
+			status = 0x02
+		}
+		slots := status << 22 // 12th slot is always set
+		for _, slotID := range x.SlotIDs {
+			slots |= status << (2 * uint32(slotID))
+		}
+		data = bo.AppendUint32(data, slots)
+
+	case *repcmd.GameSpeedCmd:
+		var speed byte
+		if x.Speed != nil {
+			speed = x.Speed.ID
+		}
+		data = append(data, speed)
+
+	case *repcmd.CancelTrainCmd:
+		data = bo.AppendUint16(data, uint16(x.UnitTag))
+
+	case *repcmd.UnloadCmd:
+		data = bo.AppendUint16(data, uint16(x.UnitTag))
+		if typeID == repcmd.TypeIDUnload121 {
+			data = bo.AppendUint16(data, 0)
+		}
+
+	case *repcmd.LiftOffCmd:
+		data = bo.AppendUint16(data, x.Pos.X)
+		data = bo.AppendUint16(data, x.Pos.Y)
+
+	case *repcmd.TechCmd:
+		var tech byte
+		if x.Tech != nil {
+			tech = x.Tech.ID
+		}
+		data = append(data, tech)
+
+	case *repcmd.UpgradeCmd:
+		var upgrade byte
+		if x.Upgrade != nil {
+			upgrade = x.Upgrade.ID
+		}
+		data = append(data, upgrade)
+
+	case *repcmd.BuildingMorphCmd:
+		data = bo.AppendUint16(data, unitID(x.Unit))
+
+	case *repcmd.LatencyCmd:
+		var latency byte
+		if x.Latency != nil {
+			latency = x.Latency.ID
+		}
+		data = append(data, latency)
+
+	case *repcmd.GeneralCmd:
+		data = append(data, x.Data...)
+
+	case *repcmd.Base:
+		data = append(data, make([]byte, skippedSizes[typeID])...)
+
+	default:
+		return nil, fmt.Errorf("unsupported command %T at frame %d", cmd, base.Frame)
+	}
+
+	return data, nil
+}
+
+// unitID returns the ID of the unit, 0 if unit is nil.
+func unitID(u *repcmd.Unit) uint16 {
+	if u == nil {
+		return 0
+	}
+	return u.ID
+}
+
+// orderID returns the ID of the order, 0 if order is nil.
+func orderID(o *repcmd.Order) byte {
+	if o == nil {
+		return 0
+	}
+	return o.ID
+}
+
+// boolByte returns 1 for true and 0 for false.
+func boolByte(b bool) byte {
+	if b {
+		return 1
+	}
+	return 0
+}
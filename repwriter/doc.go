@@ -0,0 +1,20 @@
+/*
+
+Package repwriter implements serializing rep.Replay values into .rep files.
+
+The output is a modern (1.18+) replay which can be parsed by screp and played
+back by StarCraft: Remastered. The header, the player commands, the map data and
+the modern sections screp models (player names, player colors and ShieldBattery
+info) are written.
+
+Replays are usually not restored byte-to-byte: commands that failed to parse
+(Commands.ParseErrCmds) and the content of unmodeled sections are lost. The raw
+header and map data are reused if the replay was parsed with Config.Debug
+(fields of the header overwrite the raw data), else the map data (CHK) is built
+from the MapData fields, which is sufficient for melee maps.
+
+3rd party vendors (e.g. launchers) may add their own custom sections (see Section)
+when writing replays, or stamp existing replay files using AppendSections().
+
+*/
+package repwriter
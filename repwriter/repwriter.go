@@ -0,0 +1,223 @@
+// This file contains the replay writer.
+
+package repwriter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcore"
+	"github.com/icza/screp/repparser/repencoder"
+)
+
+var (
+	// ErrNoHeader is returned if the replay to write has no header.
+	ErrNoHeader = errors.New("replay has no header")
+)
+
+const (
+	// headerSize is the size of the header section.
+	headerSize = 0x279
+
+	// slotsCount is the number of player slots in the header.
+	slotsCount = 12
+
+	// maxPlayers is the max number of players (with color and name in the player names section).
+	maxPlayers = 8
+
+	// playerNameSize is the size of a name in the player names section.
+	playerNameSize = 96
+
+	// colorFootprintSize is the size of a color footprint in the player colors section.
+	colorFootprintSize = 16
+)
+
+// String IDs of the modern sections written.
+const (
+	strIDPlayerColors  = 1380729667 // "CCLR"
+	strIDShieldBattery = 1952539219 // "Sbat"
+)
+
+// Marshal returns the .rep file content of the replay.
+// Custom sections are appended after the modern sections.
+func Marshal(r *rep.Replay, custom ...*Section) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := Encode(buf, r, custom...); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Encode writes the replay to w in the modern .rep format.
+//
+// The Commands and MapData of the replay are optional, missing commands result
+// in an empty command section, missing map data results in minimal map data
+// built from the header.
+//
+// Custom sections are appended after the modern sections.
+func Encode(w io.Writer, r *rep.Replay, custom ...*Section) error {
+	if r.Header == nil {
+		return ErrNoHeader
+	}
+
+	slots := headerSlots(r.Header)
+
+	var cmds []byte
+	if r.Commands != nil {
+		var err error
+		if cmds, err = encodeCmds(r.Commands.Cmds); err != nil {
+			return err
+		}
+	}
+
+	sections := []*repencoder.Section{
+		{Data: encodeHeader(r.Header, slots)},
+		{Data: cmds},
+		{Data: encodeMapData(r.MapData, r.Header)},
+		{Data: encodePlayerNames(slots)},
+	}
+	if data := encodePlayerColors(slots); data != nil {
+		sections = append(sections, &repencoder.Section{StrID: strIDPlayerColors, Data: data})
+	}
+	if r.ShieldBattery != nil {
+		sections = append(sections, &repencoder.Section{StrID: strIDShieldBattery, Data: encodeShieldBattery(r.ShieldBattery), Raw: true})
+	}
+
+	used := map[int32]bool{}
+	for _, s := range sections {
+		used[s.StrID] = true
+	}
+	if err := checkSections(custom, used); err != nil {
+		return err
+	}
+	sections = appendCustomSections(sections, custom)
+
+	return repencoder.Encode(w, sections)
+}
+
+// headerSlots returns the players of the header slots.
+// If the header has no slots (e.g. it was not created by the parser),
+// slots are filled with the players in their original order.
+func headerSlots(h *rep.Header) []*rep.Player {
+	if len(h.Slots) == slotsCount {
+		return h.Slots
+	}
+
+	players := h.OrigPlayers
+	if len(players) == 0 {
+		players = h.Players
+	}
+	slots := make([]*rep.Player, slotsCount)
+	copy(slots, players)
+	return slots
+}
+
+// encodeHeader encodes the header section.
+func encodeHeader(h *rep.Header, slots []*rep.Player) []byte {
+	bo := binary.LittleEndian
+
+	data := make([]byte, headerSize)
+	if h.Debug != nil && len(h.Debug.Data) == headerSize {
+		copy(data, h.Debug.Data) // Retain unknown fields
+	}
+
+	if h.Engine != nil {
+		data[0x00] = h.Engine.ID
+	}
+	bo.PutUint32(data[0x01:], uint32(h.Frames))
+	if !h.StartTime.IsZero() {
+		bo.PutUint32(data[0x08:], uint32(h.StartTime.Unix()))
+	}
+	putString(data[0x18:0x18+28], h.Title)
+	bo.PutUint16(data[0x34:], h.MapWidth)
+	bo.PutUint16(data[0x36:], h.MapHeight)
+	data[0x39] = h.AvailSlotsCount
+	if h.Speed != nil {
+		data[0x3a] = h.Speed.ID
+	}
+	if h.Type != nil {
+		bo.PutUint16(data[0x3c:], h.Type.ID)
+	}
+	bo.PutUint16(data[0x3e:], h.SubType)
+	putString(data[0x48:0x48+24], h.Host)
+	putString(data[0x61:0x61+26], h.Map)
+
+	for i, p := range slots {
+		ps := data[0xa1+i*36 : 0xa1+i*36+36]
+		if p == nil {
+			clear(ps)
+			continue
+		}
+		bo.PutUint16(ps, p.SlotID)
+		ps[4] = p.ID
+		if p.Type != nil {
+			ps[8] = p.Type.ID
+		}
+		if p.Race != nil {
+			ps[9] = p.Race.ID
+		}
+		ps[10] = p.Team
+		putString(ps[11:11+25], p.Name)
+
+		if i < maxPlayers && p.Color != nil {
+			bo.PutUint32(data[0x251+i*4:], p.Color.ID)
+		}
+	}
+
+	return data
+}
+
+// encodePlayerNames encodes the player names section.
+// Names in the header are limited to 24 bytes, this section holds the full names.
+func encodePlayerNames(slots []*rep.Player) []byte {
+	data := make([]byte, maxPlayers*playerNameSize)
+	for i, p := range slots[:maxPlayers] {
+		if p != nil {
+			putString(data[i*playerNameSize:(i+1)*playerNameSize], p.Name)
+		}
+	}
+	return data
+}
+
+// encodePlayerColors encodes the player colors section.
+// Default colors of slots (see repcore.ColorSourceDefault) are not encoded.
+// Returns nil if no player has a color with a known footprint.
+func encodePlayerColors(slots []*rep.Player) []byte {
+	data := make([]byte, slotsCount*colorFootprintSize)
+	found := false
+	for i, p := range slots {
+		if p == nil || p.Color == nil || p.ColorSource == repcore.ColorSourceDefault {
+			continue
+		}
+		if fp := p.Color.Footprint(); fp != nil {
+			copy(data[i*colorFootprintSize:], fp)
+			found = true
+		}
+	}
+	if !found {
+		return nil
+	}
+	return data
+}
+
+// encodeShieldBattery encodes the ShieldBattery section (its first, 0x56 bytes long version).
+func encodeShieldBattery(sb *rep.ShieldBattery) []byte {
+	data := make([]byte, 0x56)
+	binary.LittleEndian.PutUint32(data[0x01:], sb.StarCraftExeBuild)
+	putString(data[0x06:0x16], sb.ShieldBatteryVersion)
+	gameID, _ := hex.DecodeString(strings.ReplaceAll(sb.GameID, "-", ""))
+	copy(data[0x26:0x36], gameID)
+	return data
+}
+
+// putString writes s into dst as a 0x00 terminated string.
+// s is truncated if it does not fit into dst.
+func putString(dst []byte, s string) {
+	clear(dst)
+	copy(dst[:len(dst)-1], s)
+}
@@ -0,0 +1,249 @@
+package repwriter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/icza/screp/rep"
+	"github.com/icza/screp/rep/repcmd"
+	"github.com/icza/screp/rep/repcore"
+	"github.com/icza/screp/repparser"
+)
+
+// testReplay returns a replay having all parts the writer encodes.
+func testReplay() *rep.Replay {
+	p1 := &rep.Player{SlotID: 0, ID: 0, Type: repcore.PlayerTypeHuman, Race: repcore.RaceZerg, Team: 1, Name: "Alice", Color: repcore.ColorRed}
+	p2 := &rep.Player{SlotID: 1, ID: 1, Type: repcore.PlayerTypeHuman, Race: repcore.RaceProtoss, Team: 2, Name: "Bob with a name longer than 24 bytes", Color: repcore.ColorBlue}
+
+	base := func(frame repcore.Frame, pid byte, typeID byte) *repcmd.Base {
+		return &repcmd.Base{Frame: frame, PlayerID: pid, Type: repcmd.TypeByID(typeID)}
+	}
+
+	return &rep.Replay{
+		Header: &rep.Header{
+			Engine:          repcore.EngineBroodWar,
+			Frames:          10000,
+			StartTime:       time.Unix(1700000000, 0),
+			Title:           "Test game",
+			MapWidth:        64,
+			MapHeight:       64,
+			AvailSlotsCount: 2,
+			Speed:           repcore.SpeedFastest,
+			Type:            repcore.GameType1on1,
+			SubType:         1,
+			Host:            "Alice",
+			Map:             "Test map",
+			OrigPlayers:     []*rep.Player{p1, p2},
+		},
+		Commands: &rep.Commands{Cmds: []repcmd.Cmd{
+			&repcmd.SelectCmd{Base: base(10, 0, repcmd.TypeIDSelect), UnitTags: []repcmd.UnitTag{1, 2, 3}},
+			&repcmd.RightClickCmd{Base: base(10, 0, repcmd.TypeIDRightClick), Pos: repcore.Point{X: 100, Y: 200}, Unit: repcmd.UnitByID(0xe4), Queued: true},
+			&repcmd.SelectCmd{Base: base(12, 1, repcmd.TypeIDSelect121), UnitTags: []repcmd.UnitTag{4}},
+			&repcmd.TargetedOrderCmd{Base: base(12, 1, repcmd.TypeIDTargetedOrder121), Pos: repcore.Point{X: 1, Y: 2}, UnitTag: 3, Unit: repcmd.UnitByID(0xe4), Order: repcmd.OrderByID(repcmd.OrderIDAttack1)},
+			&repcmd.TrainCmd{Base: base(20, 0, repcmd.TypeIDTrain), Unit: repcmd.UnitByID(0x29)},
+			&repcmd.BuildCmd{Base: base(30, 1, repcmd.TypeIDBuild), Order: repcmd.OrderByID(repcmd.OrderIDPlaceProtossBuilding), Pos: repcore.Point{X: 10, Y: 20}, Unit: repcmd.UnitByID(repcmd.UnitIDPylon)},
+			&repcmd.LandCmd{Base: &repcmd.Base{Frame: 31, PlayerID: 1, Type: repcmd.TypeLand}, Order: repcmd.OrderByID(repcmd.OrderIDBuildingLand), Pos: repcore.Point{X: 5, Y: 6}, Unit: repcmd.UnitByID(repcmd.UnitIDPylon)},
+			&repcmd.HotkeyCmd{Base: base(40, 0, repcmd.TypeIDHotkey), HotkeyType: repcmd.HotkeyTypeByID(0), Group: 1},
+			&repcmd.QueueableCmd{Base: base(41, 0, repcmd.TypeIDStop)},
+			base(42, 0, repcmd.TypeIDSync),
+			&repcmd.ChatCmd{Base: base(50, 1, repcmd.TypeIDChat), SenderSlotID: 1, Message: "gl hf"},
+			&repcmd.VisionCmd{Base: base(51, 1, repcmd.TypeIDVision), SlotIDs: repcmd.Bytes{0, 1}},
+			&repcmd.AllianceCmd{Base: base(52, 1, repcmd.TypeIDAlliance), SlotIDs: repcmd.Bytes{1}, AlliedVictory: true},
+			&repcmd.TechCmd{Base: base(60, 0, repcmd.TypeIDTech), Tech: repcmd.TechByID(0x0b)},
+			&repcmd.UpgradeCmd{Base: base(61, 0, repcmd.TypeIDUpgrade), Upgrade: repcmd.UpgradeByID(0x03)},
+			&repcmd.UnloadCmd{Base: base(62, 0, repcmd.TypeIDUnload121), UnitTag: 7},
+			&repcmd.GeneralCmd{Base: base(63, 0, repcmd.TypeIDCheat), Data: []byte{1, 2, 3, 4}},
+			&repcmd.LeaveGameCmd{Base: base(9000, 1, repcmd.TypeIDLeaveGame), Reason: repcmd.LeaveReasonByID(1)},
+		}},
+		MapData: &rep.MapData{
+			Version:        205,
+			TileSet:        repcore.TileSetJungle,
+			Name:           "Test map",
+			Description:    "Map description",
+			Tiles:          make([]uint16, 64*64),
+			MineralFields:  []rep.Resource{{Point: repcore.Point{X: 100, Y: 100}, Amount: 1500}},
+			Geysers:        []rep.Resource{{Point: repcore.Point{X: 200, Y: 100}, Amount: 5000}},
+			StartLocations: []rep.StartLocation{{Point: repcore.Point{X: 300, Y: 300}, SlotID: 0}, {Point: repcore.Point{X: 1500, Y: 1500}, SlotID: 1}},
+		},
+		ShieldBattery: &rep.ShieldBattery{
+			StarCraftExeBuild:    13515,
+			ShieldBatteryVersion: "9.1.0",
+			GameID:               "00112233-4455-6677-8899-aabbccddeeff",
+		},
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	orig := testReplay()
+
+	data, err := Marshal(orig)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	r, err := repparser.ParseConfig(data, repparser.Config{Commands: true, MapData: true})
+	if err != nil {
+		t.Fatalf("Unexpected parse error: %v", err)
+	}
+
+	h, oh := r.Header, orig.Header
+	for i, c := range []struct {
+		name      string
+		got, want any
+	}{
+		{"Engine", h.Engine, oh.Engine},
+		{"Frames", h.Frames, oh.Frames},
+		{"StartTime", h.StartTime.Unix(), oh.StartTime.Unix()},
+		{"Title", h.Title, oh.Title},
+		{"Speed", h.Speed, oh.Speed},
+		{"Type", h.Type, oh.Type},
+		{"Host", h.Host, oh.Host},
+		{"Map", h.Map, oh.Map},
+		{"Players", len(h.Players), 2},
+		{"Name", h.Players[1].Name, "Bob with a name longer than 24 bytes"},
+		{"Race", h.Players[1].Race, repcore.RaceProtoss},
+		{"Color", h.Players[1].Color, repcore.ColorBlue},
+		{"ColorSource", h.Players[1].ColorSource, repcore.ColorSourceCCLR},
+		{"ShieldBattery", r.ShieldBattery, orig.ShieldBattery},
+		{"MapName", r.MapData.Name, "Test map"},
+		{"Description", r.MapData.Description, "Map description"},
+		{"TileSet", r.MapData.TileSet, repcore.TileSetJungle},
+		{"MineralFields", r.MapData.MineralFields, orig.MapData.MineralFields},
+		{"Geysers", r.MapData.Geysers, orig.MapData.Geysers},
+		{"StartLocations", r.MapData.StartLocations, orig.MapData.StartLocations},
+		{"Anomalies", len(r.MapData.Anomalies), 0},
+		{"ParseErrCmds", len(r.Commands.ParseErrCmds), 0},
+		{"Cmds", len(r.Commands.Cmds), len(orig.Commands.Cmds)},
+	} {
+		if !reflect.DeepEqual(c.got, c.want) {
+			t.Errorf("[%d] %s: Expected: %v, got: %v", i, c.name, c.want, c.got)
+		}
+	}
+
+	for i, cmd := range r.Commands.Cmds {
+		if i >= len(orig.Commands.Cmds) {
+			break
+		}
+		b, ob := cmd.BaseCmd(), orig.Commands.Cmds[i].BaseCmd()
+		got := fmt.Sprint(b.Frame, b.PlayerID, b.Type.Name, cmd.Params(true))
+		exp := fmt.Sprint(ob.Frame, ob.PlayerID, ob.Type.Name, orig.Commands.Cmds[i].Params(true))
+		if got != exp {
+			t.Errorf("[cmd %d] Expected: %v, got: %v", i, exp, got)
+		}
+	}
+}
+
+func TestMarshalIdempotent(t *testing.T) {
+	// Re-encoding a parsed replay must give the same result (the first
+	// encoding of a hand-made replay may differ, e.g. in colors of empty slots).
+	data, err := Marshal(testReplay())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var prev []byte
+	for i := 0; i < 2; i++ {
+		r, err := repparser.ParseConfig(data, repparser.Config{Commands: true, MapData: true})
+		if err != nil {
+			t.Fatalf("Unexpected parse error: %v", err)
+		}
+		prev = data
+		if data, err = Marshal(r); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if !bytes.Equal(prev, data) {
+		t.Errorf("Expected identical re-encoded replays")
+	}
+}
+
+func TestEncodeCmdsBlocks(t *testing.T) {
+	var cmds []repcmd.Cmd
+	for i := 0; i < 10; i++ { // 10 chat commands of 83 bytes: 3 fit into a block
+		cmds = append(cmds, &repcmd.ChatCmd{Base: &repcmd.Base{Frame: 5, Type: repcmd.TypeChat}, Message: "hi"})
+	}
+	cmds = append(cmds, &repcmd.QueueableCmd{Base: &repcmd.Base{Frame: 6, Type: repcmd.TypeStop}})
+
+	data, err := encodeCmds(cmds)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var frames []uint32
+	var sizes []byte
+	for pos := 0; pos < len(data); pos += 5 + int(data[pos+4]) {
+		frames = append(frames, binary.LittleEndian.Uint32(data[pos:]))
+		sizes = append(sizes, data[pos+4])
+	}
+	if exp := []uint32{5, 5, 5, 5, 6}; !reflect.DeepEqual(frames, exp) {
+		t.Errorf("Expected: %v, got: %v", exp, frames)
+	}
+	if exp := []byte{249, 249, 249, 83, 3}; !bytes.Equal(sizes, exp) {
+		t.Errorf("Expected: %v, got: %v", exp, sizes)
+	}
+}
+
+func TestEncodeNoHeader(t *testing.T) {
+	if _, err := Marshal(&rep.Replay{}); err != ErrNoHeader {
+		t.Errorf("Expected: %v, got: %v", ErrNoHeader, err)
+	}
+}
+
+// customSections returns the custom (raw) sections of a replay by their ID.
+func customSections(t *testing.T, repData []byte) map[string]string {
+	t.Helper()
+
+	sections, err := repparser.DecodeSections(repData)
+	if err != nil {
+		t.Fatalf("Failed to decode sections: %v", err)
+	}
+	m := map[string]string{}
+	for _, s := range sections {
+		if s.Raw {
+			m[string(binary.LittleEndian.AppendUint32(nil, uint32(s.StrID)))] = string(s.Data)
+		}
+	}
+	return m
+}
+
+func TestCustomSections(t *testing.T) {
+	data, err := Marshal(testReplay(), &Section{ID: "Lnch", Data: []byte("launcher v1")})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := customSections(t, data)["Lnch"]; got != "launcher v1" {
+		t.Errorf("Expected: %v, got: %v", "launcher v1", got)
+	}
+	if _, err := repparser.Parse(data); err != nil {
+		t.Errorf("Unexpected parse error: %v", err)
+	}
+
+	// Stamp again, the section must be replaced:
+	data, err = AppendSections(data, &Section{ID: "Lnch", Data: []byte("launcher v2")}, &Section{ID: "Misc", Data: []byte{1}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	sections := customSections(t, data)
+	if exp := map[string]string{"Sbat": sections["Sbat"], "Lnch": "launcher v2", "Misc": "\x01"}; !reflect.DeepEqual(sections, exp) {
+		t.Errorf("Expected: %v, got: %v", exp, sections)
+	}
+
+	cases := []struct {
+		name   string
+		custom []*Section
+		err    error
+	}{
+		{"short ID", []*Section{{ID: "abc"}}, ErrInvalidSectionID},
+		{"remastered ID", []*Section{{ID: "CCLR"}}, ErrInvalidSectionID},
+		{"duplicate", []*Section{{ID: "Lnch"}, {ID: "Lnch"}}, ErrDuplicateSection},
+		{"written by the writer", []*Section{{ID: "Sbat"}}, ErrDuplicateSection},
+	}
+	for _, c := range cases {
+		if _, err := Marshal(testReplay(), c.custom...); err != c.err {
+			t.Errorf("[%s] Expected: %v, got: %v", c.name, c.err, err)
+		}
+	}
+}
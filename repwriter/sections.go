@@ -0,0 +1,107 @@
+// This file contains the custom sections API.
+
+package repwriter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+
+	"github.com/icza/screp/repparser"
+	"github.com/icza/screp/repparser/repencoder"
+)
+
+var (
+	// ErrInvalidSectionID is returned if the ID of a custom section is not
+	// 4 bytes long, or if it is the ID of a section added by Remastered.
+	ErrInvalidSectionID = errors.New("invalid section ID")
+
+	// ErrDuplicateSection is returned if multiple sections have the same ID.
+	ErrDuplicateSection = errors.New("duplicate section")
+)
+
+// remasteredSectionIDs holds the IDs of the sections added by Remastered,
+// these cannot be used by custom sections.
+var remasteredSectionIDs = map[string]bool{
+	"SKIN": true, "LMTS": true, "BFIX": true, "CCLR": true, "GCFG": true,
+}
+
+// Section is a custom section added by a 3rd party vendor (e.g. a launcher),
+// like the "Sbat" section of ShieldBattery.
+//
+// Custom sections are written after the modern sections. Their data is written
+// as-is (without compression), preceded by the ID and the size of the section.
+// Custom sections can be read using repparser.DecodeSections().
+type Section struct {
+	// ID is the 4 bytes long string ID of the section, e.g. "Sbat".
+	ID string
+
+	// Data of the section, its format is up to the vendor.
+	Data []byte
+}
+
+// strID returns the int32 string ID of the section (the ID as a little-endian int32).
+func (s *Section) strID() int32 {
+	return int32(binary.LittleEndian.Uint32([]byte(s.ID)))
+}
+
+// AppendSections returns a copy of a replay with the given custom sections added.
+// Existing sections having the same ID are replaced, so a replay can be stamped multiple times.
+//
+// Legacy replays are converted to the modern format (see repparser.ToModern()).
+func AppendSections(repData []byte, custom ...*Section) ([]byte, error) {
+	if err := checkSections(custom, nil); err != nil {
+		return nil, err
+	}
+
+	repData, err := repparser.ToModern(repData)
+	if err != nil {
+		return nil, err
+	}
+	sections, err := repparser.DecodeSections(repData)
+	if err != nil {
+		return nil, err
+	}
+
+	replaced := map[int32]bool{}
+	for _, s := range custom {
+		replaced[s.strID()] = true
+	}
+	kept := sections[:0]
+	for _, s := range sections {
+		if !replaced[s.StrID] {
+			kept = append(kept, s)
+		}
+	}
+	kept = appendCustomSections(kept, custom)
+
+	buf := &bytes.Buffer{}
+	if err := repencoder.Encode(buf, kept); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// checkSections checks the IDs of the custom sections.
+// used holds the IDs of the sections written by the writer.
+func checkSections(custom []*Section, used map[int32]bool) error {
+	ids := map[string]bool{}
+	for _, s := range custom {
+		if len(s.ID) != 4 || remasteredSectionIDs[s.ID] {
+			return ErrInvalidSectionID
+		}
+		if ids[s.ID] || used[s.strID()] {
+			return ErrDuplicateSection
+		}
+		ids[s.ID] = true
+	}
+	return nil
+}
+
+// appendCustomSections appends the custom sections to the sections to encode.
+func appendCustomSections(sections []*repencoder.Section, custom []*Section) []*repencoder.Section {
+	for _, s := range custom {
+		sections = append(sections, &repencoder.Section{StrID: s.strID(), Data: s.Data, Raw: true})
+	}
+	return sections
+}